@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/service"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/config"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/database"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/logger"
+)
+
+var adminID int64
+
+var adminTokenCmd = &cobra.Command{
+	Use:   "admin-token",
+	Short: "Issue a bearer token with the admin role",
+	Long:  `Issues a JWT with the admin role for the /api/v1/admin endpoints and stores it in Redis as the admin's active token. Issuing a new token revokes the previous one of the same admin ID.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		issueAdminToken()
+	},
+}
+
+func init() {
+	adminTokenCmd.Flags().Int64Var(&adminID, "id", 1, "admin ID recorded in the token")
+	rootCmd.AddCommand(adminTokenCmd)
+}
+
+func issueAdminToken() {
+	cfg := config.Load()
+
+	redisDB, err := database.NewRedisDB(cfg.Redis, cfg.ConnectRetry)
+	if err != nil {
+		logger.Fatal("Failed to connect to Redis : ", err)
+	}
+	defer redisDB.Close()
+
+	token, err := service.NewAuthService(redisDB.Client).IssueAdminToken(context.Background(), adminID, cfg.JWT.Keys(), cfg.JWT.Expiration)
+	if err != nil {
+		logger.Fatal("Failed to issue admin token : ", err)
+	}
+
+	fmt.Println(token)
+}
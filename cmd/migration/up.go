@@ -62,13 +62,13 @@ func ExecuteMigration() {
 	migrateDB, err := SQLFromUrl(uri.String())
 	if err != nil {
 		fmt.Println(err)
-		logger.Fatal("Failed to connect to database: %v", err)
+		logger.Fatal("Failed to connect to database: ", err)
 		return
 	}
 	defer migrateDB.Close()
 
 	if err := migrateFromFS(migrateDB, "up", dbConfig.Database, migrationFiles); err != nil {
-		logger.Fatal("Failed to migrate: %v", err)
+		logger.Fatal("Failed to migrate: ", err)
 		return
 	}
 
@@ -6,13 +6,18 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 	"vcs.technonext.com/carrybee/ride_engine/cmd/migration"
 	"vcs.technonext.com/carrybee/ride_engine/pkg/logger"
 
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"vcs.technonext.com/carrybee/ride_engine/internal/api"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/repository/mongodb"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/repository/postgres"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/worker"
 	"vcs.technonext.com/carrybee/ride_engine/pkg/config"
 	"vcs.technonext.com/carrybee/ride_engine/pkg/database"
 )
@@ -34,22 +39,31 @@ func startServer() {
 	// Load configuration
 	cfg := config.Load()
 
+	if err := cfg.JWT.Keys().Validate(); err != nil {
+		logger.Fatal("Invalid JWT configuration : ", err)
+	}
+
+	// MongoDB command logs are written at debug level
+	if cfg.MongoDB.Debug {
+		logger.SetLogLevel(logrus.DebugLevel)
+	}
+
 	// Initialize PostgreSQL
-	postgresDB, err := database.NewPostgresDB(cfg.Postgres)
+	postgresDB, err := database.NewPostgresDB(cfg.Postgres, cfg.ConnectRetry)
 	if err != nil {
 		logger.Fatal("Failed to connect to PostgresSQL : ", err)
 	}
 	defer postgresDB.Close()
 
 	// Initialize MongoDB
-	mongoDB, err := database.NewMongoDB(cfg.MongoDB)
+	mongoDB, err := database.NewMongoDB(cfg.MongoDB, cfg.ConnectRetry)
 	if err != nil {
 		logger.Fatal("Failed to connect to MongoDB : ", err)
 	}
 	defer mongoDB.Close()
 
 	// Initialize Redis
-	redisDB, err := database.NewRedisDB(cfg.Redis)
+	redisDB, err := database.NewRedisDB(cfg.Redis, cfg.ConnectRetry)
 	if err != nil {
 		logger.Fatal("Failed to connect to Redis : ", err)
 	}
@@ -79,12 +93,56 @@ func startServer() {
 
 	logger.Info(context.Background(), "Listening on "+cfg.Server.Port)
 
+	// Start background workers
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+
+	rideExpiryWorker := worker.NewRideExpiryWorker(apiServer.RideService(), cfg.Ride.RequestTimeout, cfg.Ride.ExpiryInterval)
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		rideExpiryWorker.Start(workerCtx)
+	}()
+
+	inactiveDriverWorker := worker.NewInactiveDriverWorker(postgres.NewOnlineStatusPostgresRepository(postgresDB), postgres.NewDriverPostgresRepository(postgresDB), postgres.NewDriverSessionPostgresRepository(postgresDB), postgresDB, cfg.Driver.OnlineCutoff, cfg.Driver.CleanupInterval)
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		inactiveDriverWorker.Start(workerCtx)
+	}()
+
+	locationPurgeWorker := worker.NewLocationPurgeWorker(mongodb.NewLocationMongoRepository(mongoDB.Database), cfg.Location.HistoryRetention, cfg.Location.PurgeInterval)
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		locationPurgeWorker.Start(workerCtx)
+	}()
+
+	dispatchWorker := worker.NewDispatchWorker(apiServer.DispatchService(), cfg.Ride.DispatchInterval)
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		dispatchWorker.Start(workerCtx)
+	}()
+
+	if cfg.Ride.AcceptanceTimeout > 0 {
+		releaseWorker := worker.NewAcceptedRideReleaseWorker(apiServer.RideService(), cfg.Ride.AcceptanceTimeout, cfg.Ride.ReleaseInterval)
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			releaseWorker.Start(workerCtx)
+		}()
+	}
+
 	// Wait for graceful shutdown
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	logger.Info(context.Background(), "Server is shutting down...")
 
+	stopWorkers()
+	workers.Wait()
+
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 	if err := e.Shutdown(ctx); err != nil {
@@ -97,23 +155,59 @@ func startServer() {
 func printRoutes(port string) {
 	fmt.Printf("\nRide Engine API Server (CLI)\n")
 	fmt.Println("============================")
+	fmt.Println("\nAuth Endpoints:")
+	fmt.Println("  POST   /api/v1/auth/logout")
 	fmt.Println("\nCustomer Endpoints:")
 	fmt.Println("  POST   /api/v1/customers/register")
 	fmt.Println("  POST   /api/v1/customers/login")
+	fmt.Println("  POST   /api/v1/customers/forgot-password")
+	fmt.Println("  POST   /api/v1/customers/reset-password")
+	fmt.Println("  GET    /api/v1/customers/profile")
+	fmt.Println("  PUT    /api/v1/customers/profile")
+	fmt.Println("  GET    /api/v1/customers/account")
+	fmt.Println("  DELETE /api/v1/customers/account")
+	fmt.Println("  POST   /api/v1/customers/change-password")
+	fmt.Println("  POST   /api/v1/customers/locations")
+	fmt.Println("  GET    /api/v1/customers/locations")
+	fmt.Println("  DELETE /api/v1/customers/locations/:id")
 	fmt.Println("\nDriver Endpoints:")
 	fmt.Println("  POST   /api/v1/drivers/register")
 	fmt.Println("  POST   /api/v1/drivers/login/request-otp")
 	fmt.Println("  POST   /api/v1/drivers/login/verify-otp")
 	fmt.Println("  POST   /api/v1/drivers/location")
+	fmt.Println("  POST   /api/v1/drivers/location/batch")
 	fmt.Println("  POST   /api/v1/drivers/status")
+	fmt.Println("  PUT    /api/v1/drivers/profile")
+	fmt.Println("  GET    /api/v1/drivers/earnings")
+	fmt.Println("  GET    /api/v1/drivers/sessions")
+	fmt.Println("  GET    /api/v1/drivers/{id}/trail")
 	fmt.Println("\nRide Endpoints:")
 	fmt.Println("  POST   /api/v1/rides")
+	fmt.Println("  POST   /api/v1/rides/estimate")
+	fmt.Println("  GET    /api/v1/rides/history")
+	fmt.Println("  GET    /api/v1/rides/track (WebSocket)")
+	fmt.Println("  GET    /api/v1/rides/status/stream (SSE)")
+	fmt.Println("  GET    /api/v1/rides/eta")
+	fmt.Println("  GET    /api/v1/rides/active")
+	fmt.Println("  GET    /api/v1/rides/route")
 	fmt.Println("  GET    /api/v1/rides/nearby")
 	fmt.Println("  POST   /api/v1/rides/accept")
+	fmt.Println("  POST   /api/v1/rides/decline")
+	fmt.Println("  POST   /api/v1/rides/arrived")
 	fmt.Println("  POST   /api/v1/rides/start")
 	fmt.Println("  POST   /api/v1/rides/complete")
 	fmt.Println("  POST   /api/v1/rides/cancel")
-	fmt.Println("\nHealth:")
+	fmt.Println("  POST   /api/v1/rides/customer-cancel")
+	fmt.Println("  POST   /api/v1/rides/rate")
+	fmt.Println("\nAdmin Endpoints:")
+	fmt.Println("  GET    /api/v1/admin/rides")
+	fmt.Println("  GET    /api/v1/admin/drivers/online")
+	fmt.Println("  GET    /api/v1/admin/drivers/online-count")
+	fmt.Println("  GET    /api/v1/admin/heatmap")
+	fmt.Println("  POST   /api/v1/admin/geofences")
+	fmt.Println("  GET    /api/v1/admin/otp-history")
+	fmt.Println("\nHealth & Metrics:")
 	fmt.Println("  GET    /health")
+	fmt.Println("  GET    /metrics")
 	fmt.Printf("\n✅ Server running on http://localhost:%s\n\n", port)
 }
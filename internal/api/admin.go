@@ -0,0 +1,20 @@
+package api
+
+import (
+	"github.com/labstack/echo/v4"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/handler"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/middleware"
+)
+
+// registerAdminRoutes registers the operator routes, all of them require the admin role
+func (s *ApiServer) registerAdminRoutes(e *echo.Group, authMiddleware *middleware.AuthMiddleware, adminHandler *handler.AdminHandler) {
+	admin := e.Group("/admin", authMiddleware.AuthEcho, authMiddleware.RequireRoleEcho("admin"))
+
+	admin.GET("/rides", adminHandler.ListRides)
+	admin.GET("/drivers/online", adminHandler.GetOnlineDrivers)
+	admin.GET("/drivers/online-count", adminHandler.GetOnlineDriversCount)
+	admin.GET("/heatmap", adminHandler.GetHeatmap)
+	admin.POST("/geofences", adminHandler.CreateGeofence)
+	admin.GET("/otp-history", adminHandler.GetOTPHistory)
+	admin.GET("/revenue", adminHandler.GetRevenue)
+}
@@ -0,0 +1,19 @@
+package api
+
+import (
+	"github.com/labstack/echo/v4"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/handler"
+	appMiddleware "vcs.technonext.com/carrybee/ride_engine/pkg/middleware"
+)
+
+// registerAuthRoutes registers routes shared by customers and drivers
+func (s *ApiServer) registerAuthRoutes(e *echo.Group, authMiddleware *appMiddleware.AuthMiddleware, authHandler *handler.AuthHandler, deviceTokenHandler *handler.DeviceTokenHandler) {
+	auth := e.Group("/auth")
+	auth.POST("/logout", authHandler.Logout, authMiddleware.AuthEcho)
+
+	e.GET("/me", authHandler.Me, authMiddleware.AuthEcho)
+
+	deviceTokens := e.Group("/device-tokens", authMiddleware.AuthEcho)
+	deviceTokens.POST("", deviceTokenHandler.Register)
+	deviceTokens.DELETE("", deviceTokenHandler.Unregister)
+}
@@ -3,11 +3,27 @@ package api
 import (
 	"github.com/labstack/echo/v4"
 	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/handler"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/middleware"
 )
 
 // registerCustomerRoutes registers all customer-related routes
-func (s *ApiServer) registerCustomerRoutes(e *echo.Group, customerHandler *handler.CustomerHandler) {
+func (s *ApiServer) registerCustomerRoutes(e *echo.Group, authMiddleware *middleware.AuthMiddleware, customerHandler *handler.CustomerHandler, savedLocationHandler *handler.SavedLocationHandler) {
 	customers := e.Group("/customers")
+	// Public routes, the credential checks share a per IP budget against brute forcing
+	loginLimit := s.rateLimit("login", s.config.RateLimit.Login)
 	customers.POST("/register", customerHandler.Register)
-	customers.POST("/login", customerHandler.Login)
+	customers.POST("/login", customerHandler.Login, loginLimit)
+	customers.POST("/forgot-password", customerHandler.ForgotPassword, loginLimit)
+	customers.POST("/reset-password", customerHandler.ResetPassword, loginLimit)
+
+	// Protected routes
+	customerOnly := authMiddleware.RequireRoleEcho("customer")
+	customers.GET("/profile", customerHandler.GetProfile, authMiddleware.AuthEcho, customerOnly)
+	customers.PUT("/profile", customerHandler.UpdateProfile, authMiddleware.AuthEcho, customerOnly)
+	customers.GET("/account", customerHandler.GetProfile, authMiddleware.AuthEcho, customerOnly)
+	customers.DELETE("/account", customerHandler.DeleteAccount, authMiddleware.AuthEcho, customerOnly)
+	customers.POST("/change-password", customerHandler.ChangePassword, authMiddleware.AuthEcho, customerOnly)
+	customers.POST("/locations", savedLocationHandler.Create, authMiddleware.AuthEcho, customerOnly)
+	customers.GET("/locations", savedLocationHandler.List, authMiddleware.AuthEcho, customerOnly)
+	customers.DELETE("/locations/:id", savedLocationHandler.Delete, authMiddleware.AuthEcho, customerOnly)
 }
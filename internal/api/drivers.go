@@ -9,12 +9,20 @@ import (
 // registerDriverRoutes registers all driver-related routes
 func (s *ApiServer) registerDriverRoutes(e *echo.Group, authMiddleware *appMiddleware.AuthMiddleware, driverHandler *handler.DriverHandler) {
 	drivers := e.Group("/drivers")
-	// Public routes
+	// Public routes, the OTP login shares the per IP login budget
+	loginLimit := s.rateLimit("login", s.config.RateLimit.Login)
 	drivers.POST("/register", driverHandler.Register)
-	drivers.POST("/login/request-otp", driverHandler.RequestOTP)
-	drivers.POST("/login/verify-otp", driverHandler.VerifyOTP)
+	drivers.POST("/login/request-otp", driverHandler.RequestOTP, loginLimit)
+	drivers.POST("/login/verify-otp", driverHandler.VerifyOTP, loginLimit)
 
 	// Protected routes
-	drivers.POST("/location", driverHandler.UpdateLocation, authMiddleware.AuthEcho)
-	drivers.POST("/nearby", driverHandler.FindNearestDrivers, authMiddleware.AuthEcho)
+	driverOnly := authMiddleware.RequireRoleEcho("driver")
+	drivers.POST("/location", driverHandler.UpdateLocation, authMiddleware.AuthEcho, driverOnly)
+	drivers.POST("/location/batch", driverHandler.UpdateLocationBatch, authMiddleware.AuthEcho, driverOnly)
+	drivers.POST("/status", driverHandler.SetOnlineStatus, authMiddleware.AuthEcho, driverOnly)
+	drivers.PUT("/profile", driverHandler.UpdateProfile, authMiddleware.AuthEcho, driverOnly)
+	drivers.GET("/earnings", driverHandler.GetEarnings, authMiddleware.AuthEcho, driverOnly)
+	drivers.GET("/sessions", driverHandler.GetSessions, authMiddleware.AuthEcho, driverOnly)
+	drivers.GET("/:id/trail", driverHandler.GetLocationTrail, authMiddleware.AuthEcho)
+	drivers.POST("/nearby", driverHandler.FindNearestDrivers, authMiddleware.AuthEcho, s.rateLimit("nearby_drivers", s.config.RateLimit.Nearby))
 }
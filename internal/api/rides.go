@@ -7,15 +7,29 @@ import (
 )
 
 // registerRideRoutes registers all ride-related routes
-func (s *ApiServer) registerRideRoutes(e *echo.Group, authMiddleware *middleware.AuthMiddleware, rideHandler *handler.RideHandler) {
+func (s *ApiServer) registerRideRoutes(e *echo.Group, authMiddleware *middleware.AuthMiddleware, rideHandler *handler.RideHandler, ratingHandler *handler.RatingHandler) {
 	rides := e.Group("/rides")
-	rides.POST("/", rideHandler.RequestRide, authMiddleware.AuthEcho)
-	rides.GET("/status", rideHandler.GetRideStatus, authMiddleware.AuthEcho)
-	rides.GET("/details", rideHandler.GetRideDetails, authMiddleware.AuthEcho)
-	rides.POST("/nearby", rideHandler.GetNearbyRides, authMiddleware.AuthEcho)
-	rides.POST("/accept", rideHandler.AcceptRide, authMiddleware.AuthEcho)
-	rides.POST("/start", rideHandler.StartRide, authMiddleware.AuthEcho)
-	rides.POST("/complete", rideHandler.CompleteRide, authMiddleware.AuthEcho)
-	rides.POST("/cancel", rideHandler.CancelRide, authMiddleware.AuthEcho)
+	customerOnly := authMiddleware.RequireRoleEcho("customer")
+	driverOnly := authMiddleware.RequireRoleEcho("driver")
 
+	rides.POST("/", rideHandler.RequestRide, authMiddleware.AuthEcho, customerOnly)
+	rides.POST("/estimate", rideHandler.EstimateFare, authMiddleware.AuthEcho, customerOnly)
+	rides.GET("/status", rideHandler.GetRideStatus, authMiddleware.AuthEcho, customerOnly)
+	rides.GET("/status/stream", rideHandler.StreamRideStatus, authMiddleware.AuthEcho, customerOnly)
+	rides.GET("/eta", rideHandler.GetRideETA, authMiddleware.AuthEcho, customerOnly)
+	rides.GET("/active", rideHandler.GetActiveRide, authMiddleware.AuthEcho)
+	rides.GET("/details", rideHandler.GetRideDetails, authMiddleware.AuthEcho)
+	rides.GET("/history", rideHandler.GetRideHistory, authMiddleware.AuthEcho)
+	rides.GET("/track", rideHandler.TrackRide, authMiddleware.AuthEcho, customerOnly)
+	rides.GET("/route", rideHandler.GetRideRoute, authMiddleware.AuthEcho)
+	rides.GET("/events", rideHandler.GetRideEvents, authMiddleware.AuthEcho)
+	rides.POST("/nearby", rideHandler.GetNearbyRides, authMiddleware.AuthEcho, driverOnly, s.rateLimit("nearby_rides", s.config.RateLimit.Nearby))
+	rides.POST("/accept", rideHandler.AcceptRide, authMiddleware.AuthEcho, driverOnly)
+	rides.POST("/decline", rideHandler.DeclineRide, authMiddleware.AuthEcho, driverOnly)
+	rides.POST("/arrived", rideHandler.MarkArrived, authMiddleware.AuthEcho, driverOnly)
+	rides.POST("/start", rideHandler.StartRide, authMiddleware.AuthEcho, driverOnly)
+	rides.POST("/complete", rideHandler.CompleteRide, authMiddleware.AuthEcho, driverOnly)
+	rides.POST("/cancel", rideHandler.CancelRide, authMiddleware.AuthEcho, driverOnly)
+	rides.POST("/customer-cancel", rideHandler.CustomerCancelRide, authMiddleware.AuthEcho, customerOnly)
+	rides.POST("/rate", ratingHandler.RateRide, authMiddleware.AuthEcho)
 }
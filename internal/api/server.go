@@ -2,7 +2,8 @@ package api
 
 import (
 	"github.com/labstack/echo/v4"
-	"github.com/labstack/echo/v4/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	echoSwagger "github.com/swaggo/echo-swagger"
 	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/handler"
 	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/repository/mongodb"
@@ -10,6 +11,7 @@ import (
 	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/service"
 	"vcs.technonext.com/carrybee/ride_engine/pkg/config"
 	"vcs.technonext.com/carrybee/ride_engine/pkg/database"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/metrics"
 	appMiddleware "vcs.technonext.com/carrybee/ride_engine/pkg/middleware"
 
 	_ "vcs.technonext.com/carrybee/ride_engine/docs"
@@ -21,6 +23,10 @@ type ApiServer struct {
 	postgres *database.PostgresDB
 	mongo    *database.MongoDB
 	redis    *database.RedisDB
+
+	dispatchService *service.DispatchService
+	rideService     *service.RideService
+	rateLimiter     *appMiddleware.RateLimiter
 }
 
 // NewServer creates a new API server with the provided dependencies
@@ -40,49 +46,128 @@ func (s *ApiServer) SetupRoutes() *echo.Echo {
 	driverRepo := postgres.NewDriverPostgresRepository(s.postgres)
 	rideRepoMongo := mongodb.NewRideMongoRepository(s.mongo.Database)
 	otpRepo := postgres.NewOTPPostgresRepository(s.postgres)
-	onlineStatusRepo := postgres.NewOnlineStatusPostgresRepository(s.postgres.DB)
+	onlineStatusRepo := postgres.NewOnlineStatusPostgresRepository(s.postgres)
+	driverSessionRepo := postgres.NewDriverSessionPostgresRepository(s.postgres)
 	locationRepo := mongodb.NewLocationMongoRepository(s.mongo.Database)
+	ratingRepo := mongodb.NewRatingMongoRepository(s.mongo.Database)
+	rideEventRepo := mongodb.NewRideEventMongoRepository(s.mongo.Database)
+	savedLocationRepo := postgres.NewSavedLocationPostgresRepository(s.postgres)
+	geofenceRepo := mongodb.NewGeofenceMongoRepository(s.mongo.Database)
+	promoRepo := postgres.NewPromoPostgresRepository(s.postgres)
+	deviceTokenRepo := postgres.NewDeviceTokenPostgresRepository(s.postgres)
 
 	// Initialize services
-	otpService := service.NewOTPService(s.redis.Client, otpRepo)
-	locationService := service.NewLocationService(locationRepo)
-	customerService := service.NewCustomerService(customerRepo, s.config.JWT.Secret, s.config.JWT.Expiration, s.redis.Client)
-	driverService := service.NewDriverService(driverRepo, onlineStatusRepo, otpService, locationService, s.config.JWT.Secret, s.config.JWT.Expiration, s.redis.Client)
-	rideService := service.NewRideService(rideRepoMongo, locationService, driverService, customerRepo)
+	otpService := service.NewOTPService(s.redis.Client, otpRepo, s.config.OTP.ResendCooldown, service.NewHTTPSMSSender(s.config.SMS), s.config.Environment == "development")
+	locationService := service.NewLocationService(locationRepo, s.config.Location)
+	trackingService := service.NewTrackingService(s.redis.Client, rideRepoMongo)
+	authService := service.NewAuthService(s.redis.Client)
+	customerService := service.NewCustomerService(customerRepo, otpService, s.config.JWT.Keys(), s.config.JWT.Expiration, s.redis.Client, savedLocationRepo, deviceTokenRepo, s.postgres)
+	driverService := service.NewDriverService(driverRepo, rideRepoMongo, ratingRepo, onlineStatusRepo, driverSessionRepo, otpService, locationService, trackingService, s.config.JWT.Keys(), s.config.JWT.Expiration, s.redis.Client, s.config.Driver.ProfileCacheTTL, metrics.NewCacheMetrics(prometheus.DefaultRegisterer), s.postgres)
+	fareService := service.NewFareService(s.config.Fare, locationService)
+	surgeService := service.NewSurgeService(s.config.Fare, rideRepoMongo, locationService)
+	savedLocationService := service.NewSavedLocationService(savedLocationRepo)
+	geofenceService := service.NewGeofenceService(geofenceRepo)
+	promoService := service.NewPromoService(promoRepo)
+	deviceTokenService := service.NewDeviceTokenService(deviceTokenRepo)
+	// Pickups are only checked against the geofences when the restriction is enabled
+	var pickupGeofenceService *service.GeofenceService
+	if s.config.Ride.GeofenceEnabled {
+		pickupGeofenceService = geofenceService
+	}
+	// Ride notifications are also pushed to registered devices when a push provider is configured
+	var notifier service.Notifier = service.NewRedisNotifier(s.redis.Client)
+	if s.config.Push.ProviderURL != "" {
+		notifier = service.MultiNotifier{notifier, service.NewPushNotifier(s.config.Push, deviceTokenRepo)}
+	}
+	s.dispatchService = service.NewDispatchService(rideRepoMongo, rideEventRepo, locationService, driverService, s.config.Ride.DispatchRadiusMeters, s.config.Ride.DispatchRadiusStepMeters, s.config.Ride.DispatchMaxRadiusMeters, s.config.Ride.OfferTimeout, notifier)
+	rideService := service.NewRideService(rideRepoMongo, rideEventRepo, locationService, driverService, fareService, surgeService, s.dispatchService, savedLocationService, pickupGeofenceService, promoService, customerRepo, s.config.Ride.AverageSpeedKmh, s.config.Ride.StatusStreamInterval, s.config.Ride.NearbyFreshness, metrics.NewRideMetrics(prometheus.DefaultRegisterer), s.redis.Client, s.config.Ride.IdempotencyKeyTTL, !s.config.Ride.AllowStartWithoutArrival, notifier, s.config.Ride.NearbySearchByGeohash)
+	s.rideService = rideService
+	ratingService := service.NewRatingService(rideRepoMongo, ratingRepo)
+	metrics.NewOnlineDriversGauge(prometheus.DefaultRegisterer, driverService.GetOnlineDriversCount)
 
 	// Initialize handlers
+	authHandler := handler.NewAuthHandler(authService, customerService, driverService)
 	customerHandler := handler.NewCustomerHandler(customerService)
 	driverHandler := handler.NewDriverHandler(driverService)
-	rideHandler := handler.NewRideHandler(rideService)
+	rideHandler := handler.NewRideHandler(rideService, trackingService)
+	ratingHandler := handler.NewRatingHandler(ratingService)
+	savedLocationHandler := handler.NewSavedLocationHandler(savedLocationService)
+	deviceTokenHandler := handler.NewDeviceTokenHandler(deviceTokenService)
+	adminHandler := handler.NewAdminHandler(rideService, driverService, locationService, geofenceService, otpService)
+	healthHandler := handler.NewHealthHandler(map[string]handler.HealthChecker{
+		"postgres": s.postgres,
+		"mongodb":  s.mongo,
+		"redis":    s.redis,
+	}, s.config.Server.HealthCheckTimeout)
 
 	// Setup Echo router
-	e := echo.New()
+	e := newEcho()
+
+	// Tag every request with a trace ID that is logged and returned in X-Request-ID
+	e.Use(appMiddleware.RequestIDEcho)
 
-	// Enable CORS to allow Swagger UI and other clients
-	e.Use(middleware.CORS())
+	// Cap request bodies before any handler reads them
+	e.Use(appMiddleware.BodyLimitEcho(int64(s.config.Server.MaxBodyBytes)))
 
-	authMiddleware := appMiddleware.NewAuthMiddleware(s.redis.Client, s.config.JWT.Secret)
+	// Only let the configured origins, such as the Swagger UI, call the API from a browser
+	e.Use(appMiddleware.CORSEcho(s.config.CORS))
+
+	authMiddleware := appMiddleware.NewAuthMiddleware(s.redis.Client, s.config.JWT.Keys())
+	s.rateLimiter = appMiddleware.NewRateLimiter(s.redis.Client)
 
 	// Register routes
-	s.registerRoutes(e, authMiddleware, customerHandler, driverHandler, rideHandler)
+	s.registerRoutes(e, authMiddleware, authHandler, deviceTokenHandler, customerHandler, savedLocationHandler, driverHandler, rideHandler, ratingHandler, adminHandler, healthHandler)
+
+	return e
+}
 
+// DispatchService returns the dispatch service created by SetupRoutes, for the dispatch worker
+func (s *ApiServer) DispatchService() *service.DispatchService {
+	return s.dispatchService
+}
+
+// RideService returns the ride service created by SetupRoutes, for the accepted ride release worker
+func (s *ApiServer) RideService() *service.RideService {
+	return s.rideService
+}
+
+// newEcho returns the Echo router the API is served on, before any middleware or route is added
+func newEcho() *echo.Echo {
+	e := echo.New()
+	e.Validator = handler.NewRequestValidator()
+	// Errors returned by handlers are answered with the status of their domain error kind
+	e.HTTPErrorHandler = handler.HTTPErrorHandler
+	// The client IP, used to rate limit anonymous requests, is the peer address, X-Forwarded-For and X-Real-IP are
+	// set by the client and ignored so changing them cannot get a new rate limit bucket
+	e.IPExtractor = echo.ExtractIPDirect()
 	return e
 }
 
+// rateLimit limits the route to the budget when rate limiting is enabled, it must come after AuthEcho on authenticated routes
+func (s *ApiServer) rateLimit(route string, limit config.RateLimit) echo.MiddlewareFunc {
+	if !s.config.RateLimit.Enabled {
+		return func(next echo.HandlerFunc) echo.HandlerFunc { return next }
+	}
+	return s.rateLimiter.Limit(route, limit)
+}
+
 // registerRoutes registers all the API routes using route groups
-func (s *ApiServer) registerRoutes(e *echo.Echo, authMiddleware *appMiddleware.AuthMiddleware, customerHandler *handler.CustomerHandler, driverHandler *handler.DriverHandler, rideHandler *handler.RideHandler) {
+func (s *ApiServer) registerRoutes(e *echo.Echo, authMiddleware *appMiddleware.AuthMiddleware, authHandler *handler.AuthHandler, deviceTokenHandler *handler.DeviceTokenHandler, customerHandler *handler.CustomerHandler, savedLocationHandler *handler.SavedLocationHandler, driverHandler *handler.DriverHandler, rideHandler *handler.RideHandler, ratingHandler *handler.RatingHandler, adminHandler *handler.AdminHandler, healthHandler *handler.HealthHandler) {
 	// Register route groups
 	api := e.Group("/api/v1")
 
-	s.registerCustomerRoutes(api, customerHandler)
+	s.registerAuthRoutes(api, authMiddleware, authHandler, deviceTokenHandler)
+	s.registerCustomerRoutes(api, authMiddleware, customerHandler, savedLocationHandler)
 	s.registerDriverRoutes(api, authMiddleware, driverHandler)
-	s.registerRideRoutes(api, authMiddleware, rideHandler)
+	s.registerRideRoutes(api, authMiddleware, rideHandler, ratingHandler)
+	s.registerAdminRoutes(api, authMiddleware, adminHandler)
 
 	// Swagger UI
 	e.GET("/swagger/*", echoSwagger.WrapHandler)
 
+	// Prometheus metrics
+	e.GET("/metrics", echo.WrapHandler(promhttp.Handler()))
+
 	// Health check
-	e.GET("/health", func(c echo.Context) error {
-		return c.String(200, "OK")
-	})
+	e.GET("/health", healthHandler.Health)
 }
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/config"
+	appMiddleware "vcs.technonext.com/carrybee/ride_engine/pkg/middleware"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/testutil"
+)
+
+func TestNewEcho_SpoofedForwardedForDoesNotResetRateLimit(t *testing.T) {
+	redisClient, _ := testutil.NewFakeRedis()
+	limiter := appMiddleware.NewRateLimiter(redisClient)
+
+	e := newEcho()
+	e.POST("/login", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	}, limiter.Limit("login", config.RateLimit{Requests: 2, Window: time.Minute}))
+
+	login := func(forwardedFor string) int {
+		req := httptest.NewRequest(http.MethodPost, "/login", nil)
+		req.RemoteAddr = "203.0.113.7:52000"
+		if forwardedFor != "" {
+			req.Header.Set(echo.HeaderXForwardedFor, forwardedFor)
+			req.Header.Set(echo.HeaderXRealIP, forwardedFor)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, login(""))
+	assert.Equal(t, http.StatusOK, login("198.51.100.1"))
+	assert.Equal(t, http.StatusTooManyRequests, login("198.51.100.2"), "A new forwarded address is still the same client")
+	assert.Equal(t, http.StatusTooManyRequests, login(""))
+}
@@ -0,0 +1,33 @@
+package domain
+
+import "errors"
+
+// Error kinds that say how a failure is reported to the caller, match them with errors.Is
+var (
+	ErrNotFound        = errors.New("not found")            // the resource does not exist
+	ErrForbidden       = errors.New("forbidden")            // the resource belongs to someone else
+	ErrConflict        = errors.New("conflict")             // the resource changed or is already taken
+	ErrValidation      = errors.New("invalid request data") // the input was rejected before anything changed
+	ErrUnprocessable   = errors.New("unprocessable")        // the input is well formed but cannot be served
+	ErrTooManyRequests = errors.New("too many requests")    // the caller has to wait before trying again
+)
+
+// Error is a sentinel error of one of the error kinds
+type Error struct {
+	kind    error
+	message string
+}
+
+// NewError returns a sentinel error with the message that errors.Is matches against kind
+func NewError(kind error, message string) error {
+	return &Error{kind: kind, message: message}
+}
+
+func (e *Error) Error() string {
+	return e.message
+}
+
+// Unwrap returns the error kind
+func (e *Error) Unwrap() error {
+	return e.kind
+}
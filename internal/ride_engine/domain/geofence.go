@@ -0,0 +1,54 @@
+package domain
+
+import (
+	"strings"
+	"time"
+)
+
+// MinGeofencePoints is the fewest vertices a geofence boundary can have
+const MinGeofencePoints = 3
+
+// Geofence validation errors
+var (
+	ErrGeofenceNameRequired    = NewError(ErrValidation, "geofence name is required")
+	ErrInvalidGeofenceBoundary = NewError(ErrValidation, "geofence boundary needs at least 3 points")
+)
+
+// Geofence is a service area, rides can only be requested with a pickup inside an active geofence
+type Geofence struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Boundary  []Location `json:"boundary"` // polygon vertices in order, the first vertex is not repeated at the end
+	Active    bool       `json:"active"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// ValidateGeofence checks that the geofence has a name and a boundary of valid points
+func ValidateGeofence(geofence *Geofence) error {
+	if strings.TrimSpace(geofence.Name) == "" {
+		return ErrGeofenceNameRequired
+	}
+	if len(geofence.Boundary) < MinGeofencePoints {
+		return ErrInvalidGeofenceBoundary
+	}
+	for _, point := range geofence.Boundary {
+		if err := point.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Contains reports whether the point lies inside the boundary using ray casting
+// Longitude and latitude are treated as planar coordinates, which holds for city sized areas
+func (g *Geofence) Contains(lat, lng float64) bool {
+	inside := false
+	for i, j := 0, len(g.Boundary)-1; i < len(g.Boundary); j, i = i, i+1 {
+		a, b := g.Boundary[i], g.Boundary[j]
+		if (a.Latitude > lat) != (b.Latitude > lat) &&
+			lng < (b.Longitude-a.Longitude)*(lat-a.Latitude)/(b.Latitude-a.Latitude)+a.Longitude {
+			inside = !inside
+		}
+	}
+	return inside
+}
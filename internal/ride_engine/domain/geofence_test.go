@@ -0,0 +1,69 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// gulshan is a simple square around Gulshan, Dhaka
+var gulshan = Geofence{
+	Name: "Gulshan",
+	Boundary: []Location{
+		{Latitude: 23.77, Longitude: 90.40},
+		{Latitude: 23.77, Longitude: 90.43},
+		{Latitude: 23.81, Longitude: 90.43},
+		{Latitude: 23.81, Longitude: 90.40},
+	},
+	Active: true,
+}
+
+func TestGeofence_Contains(t *testing.T) {
+	// An L shape, the north east quarter of the square is cut out
+	lShape := Geofence{
+		Name: "L",
+		Boundary: []Location{
+			{Latitude: 0, Longitude: 0},
+			{Latitude: 0, Longitude: 2},
+			{Latitude: 1, Longitude: 2},
+			{Latitude: 1, Longitude: 1},
+			{Latitude: 2, Longitude: 1},
+			{Latitude: 2, Longitude: 0},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		geofence Geofence
+		lat, lng float64
+		expected bool
+	}{
+		{name: "inside square", geofence: gulshan, lat: 23.7925, lng: 90.4078, expected: true},
+		{name: "north of square", geofence: gulshan, lat: 23.8500, lng: 90.4078, expected: false},
+		{name: "west of square", geofence: gulshan, lat: 23.7925, lng: 90.3700, expected: false},
+		{name: "inside L", geofence: lShape, lat: 0.5, lng: 1.5, expected: true},
+		{name: "in the cut out corner of L", geofence: lShape, lat: 1.5, lng: 1.5, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.geofence.Contains(tt.lat, tt.lng))
+		})
+	}
+}
+
+func TestValidateGeofence(t *testing.T) {
+	assert.NoError(t, ValidateGeofence(&gulshan))
+
+	unnamed := gulshan
+	unnamed.Name = " "
+	assert.ErrorIs(t, ValidateGeofence(&unnamed), ErrGeofenceNameRequired)
+
+	line := gulshan
+	line.Boundary = gulshan.Boundary[:2]
+	assert.ErrorIs(t, ValidateGeofence(&line), ErrInvalidGeofenceBoundary)
+
+	outOfRange := gulshan
+	outOfRange.Boundary = []Location{{Latitude: 23.77, Longitude: 90.40}, {Latitude: 23.77, Longitude: 190}, {Latitude: 23.81, Longitude: 90.43}}
+	assert.ErrorIs(t, ValidateGeofence(&outOfRange), ErrInvalidLongitude)
+}
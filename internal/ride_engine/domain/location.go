@@ -1,9 +1,12 @@
 package domain
 
 import (
-	"errors"
+	"math"
 )
 
+// earthRadiusMeters is the mean Earth radius used for great-circle distances
+const earthRadiusMeters = 6371000.0
+
 // Location represents a geographical location
 type Location struct {
 	Latitude  float64 `json:"latitude"`
@@ -12,6 +15,36 @@ type Location struct {
 
 // Validation errors
 var (
-	ErrInvalidLatitude  = errors.New("invalid latitude")
-	ErrInvalidLongitude = errors.New("invalid longitude")
+	ErrInvalidLatitude  = NewError(ErrValidation, "invalid latitude")
+	ErrInvalidLongitude = NewError(ErrValidation, "invalid longitude")
 )
+
+// ValidateCoordinates checks that latitude is in [-90, 90] and longitude in [-180, 180]
+func ValidateCoordinates(lat, lng float64) error {
+	if math.IsNaN(lat) || lat < -90 || lat > 90 {
+		return ErrInvalidLatitude
+	}
+	if math.IsNaN(lng) || lng < -180 || lng > 180 {
+		return ErrInvalidLongitude
+	}
+	return nil
+}
+
+// Validate checks that the coordinates are within valid ranges
+func (l Location) Validate() error {
+	return ValidateCoordinates(l.Latitude, l.Longitude)
+}
+
+// DistanceTo returns the great-circle distance to other in meters using the Haversine formula
+func (l Location) DistanceTo(other Location) float64 {
+	lat1 := l.Latitude * math.Pi / 180
+	lat2 := other.Latitude * math.Pi / 180
+	dLat := (other.Latitude - l.Latitude) * math.Pi / 180
+	dLng := (other.Longitude - l.Longitude) * math.Pi / 180
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	c := 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+
+	return earthRadiusMeters * c
+}
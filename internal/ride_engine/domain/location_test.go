@@ -0,0 +1,90 @@
+package domain
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocation_DistanceTo_CityPairs(t *testing.T) {
+	tests := []struct {
+		name     string
+		from     Location
+		to       Location
+		expected float64 // in meters
+	}{
+		{
+			name:     "London to Paris",
+			from:     Location{Latitude: 51.5074, Longitude: -0.1278},
+			to:       Location{Latitude: 48.8566, Longitude: 2.3522},
+			expected: 343556,
+		},
+		{
+			name:     "New York to Los Angeles",
+			from:     Location{Latitude: 40.7128, Longitude: -74.0060},
+			to:       Location{Latitude: 34.0522, Longitude: -118.2437},
+			expected: 3935746,
+		},
+		{
+			name:     "Dhaka to Chattogram",
+			from:     Location{Latitude: 23.8103, Longitude: 90.4125},
+			to:       Location{Latitude: 22.3569, Longitude: 91.7832},
+			expected: 213952,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.InDelta(t, tt.expected, tt.from.DistanceTo(tt.to), 100)
+			assert.InDelta(t, tt.expected, tt.to.DistanceTo(tt.from), 100, "distance should be symmetric")
+		})
+	}
+}
+
+func TestLocation_DistanceTo_SamePoint(t *testing.T) {
+	loc := Location{Latitude: 23.8103, Longitude: 90.4125}
+
+	assert.Equal(t, 0.0, loc.DistanceTo(loc))
+}
+
+func TestLocation_DistanceTo_Poles(t *testing.T) {
+	northPole := Location{Latitude: 90, Longitude: 0}
+	southPole := Location{Latitude: -90, Longitude: 0}
+
+	// Half the Earth's circumference
+	assert.InDelta(t, 20015087, northPole.DistanceTo(southPole), 1)
+
+	// Longitude is meaningless at the pole itself
+	assert.InDelta(t, 0, northPole.DistanceTo(Location{Latitude: 90, Longitude: 120}), 0.001)
+}
+
+func TestLocation_DistanceTo_AcrossAntimeridian(t *testing.T) {
+	east := Location{Latitude: 0, Longitude: 179.5}
+	west := Location{Latitude: 0, Longitude: -179.5}
+
+	// One degree of longitude at the equator, not 359 degrees the long way round
+	assert.InDelta(t, 111195, east.DistanceTo(west), 1)
+}
+
+func TestLocation_Validate(t *testing.T) {
+	assert.NoError(t, Location{Latitude: 23.8103, Longitude: 90.4125}.Validate())
+	assert.NoError(t, Location{Latitude: -90, Longitude: 180}.Validate())
+	assert.ErrorIs(t, Location{Latitude: 90.1, Longitude: 0}.Validate(), ErrInvalidLatitude)
+	assert.ErrorIs(t, Location{Latitude: 0, Longitude: -180.1}.Validate(), ErrInvalidLongitude)
+}
+
+func TestValidateCoordinates(t *testing.T) {
+	assert.NoError(t, ValidateCoordinates(23.8103, 90.4125))
+	assert.NoError(t, ValidateCoordinates(90, -180))
+	assert.ErrorIs(t, ValidateCoordinates(-90.1, 90.4125), ErrInvalidLatitude)
+	assert.ErrorIs(t, ValidateCoordinates(23.8103, 180.1), ErrInvalidLongitude)
+	assert.ErrorIs(t, ValidateCoordinates(math.NaN(), 90.4125), ErrInvalidLatitude)
+}
+
+func TestValidateWaypoints(t *testing.T) {
+	assert.NoError(t, ValidateWaypoints(nil))
+	assert.NoError(t, ValidateWaypoints([]Location{{Latitude: 23.7806, Longitude: 90.4193}}))
+	assert.ErrorIs(t, ValidateWaypoints([]Location{{Latitude: 23.7806, Longitude: 90.4193}, {Latitude: 123, Longitude: 90}}), ErrInvalidLatitude)
+	assert.ErrorIs(t, ValidateWaypoints(make([]Location, MaxWaypoints+1)), ErrTooManyWaypoints)
+}
@@ -2,7 +2,10 @@ package domain
 
 import (
 	"errors"
+	"regexp"
+	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 // UserType represents the type of user
@@ -24,11 +27,12 @@ type User struct {
 
 // Customer represents a customer/rider
 type Customer struct {
-	ID        int64     `json:"id"`
-	Name      string    `json:"name"`
-	Email     string    `json:"email"`
-	Phone     string    `json:"phone"`
-	CreatedAt time.Time `json:"created_at"`
+	ID        int64      `json:"id"`
+	Name      string     `json:"name"`
+	Email     string     `json:"email"`
+	Phone     string     `json:"phone"`
+	CreatedAt time.Time  `json:"created_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"` // set when the customer deleted their account
 }
 
 // Driver represents a driver
@@ -37,6 +41,7 @@ type Driver struct {
 	Name          string     `json:"name"`
 	Phone         string     `json:"phone"`
 	VehicleNo     string     `json:"vehicle_no"`
+	VehicleType   RideType   `json:"vehicle_type"`
 	IsOnline      bool       `json:"is_online"`
 	CurrentLat    *float64   `json:"current_lat,omitempty"`
 	CurrentLng    *float64   `json:"current_lng,omitempty"`
@@ -50,41 +55,252 @@ type RideStatus string
 
 const (
 	RideStatusRequested RideStatus = "requested"
-	RideStatusPending   RideStatus = "pending" // Alternative status for requested rides
+	RideStatusPending   RideStatus = "pending" // requested and currently offered to a driver
 	RideStatusAccepted  RideStatus = "accepted"
+	RideStatusArrived   RideStatus = "arrived" // the driver is at the pickup waiting for the customer
 	RideStatusStarted   RideStatus = "started"
 	RideStatusCompleted RideStatus = "completed"
 	RideStatusCancelled RideStatus = "cancelled"
 )
 
+// IsValid reports whether s is one of the known ride statuses
+func (s RideStatus) IsValid() bool {
+	switch s {
+	case RideStatusRequested, RideStatusPending, RideStatusAccepted, RideStatusArrived, RideStatusStarted, RideStatusCompleted, RideStatusCancelled:
+		return true
+	}
+	return false
+}
+
+// IsAwaitingDriver reports whether a ride in status s is still waiting for a driver to accept it
+func (s RideStatus) IsAwaitingDriver() bool {
+	return s == RideStatusRequested || s == RideStatusPending
+}
+
+// IsAwaitingPickup reports whether a ride in status s has a driver who has not yet picked the customer up
+func (s RideStatus) IsAwaitingPickup() bool {
+	return s == RideStatusAccepted || s == RideStatusArrived
+}
+
+// IsTerminal reports whether no further transitions are possible from s
+func (s RideStatus) IsTerminal() bool {
+	return s == RideStatusCompleted || s == RideStatusCancelled
+}
+
+// RideType is the class of vehicle a ride is requested for
+// Drivers only see requests matching their vehicle type
+type RideType string
+
+const (
+	RideTypeEconomy RideType = "economy"
+	RideTypePremium RideType = "premium"
+	RideTypeBike    RideType = "bike"
+)
+
+// RideTypes lists every known ride type
+var RideTypes = []RideType{RideTypeEconomy, RideTypePremium, RideTypeBike}
+
+// IsValid reports whether t is one of the known ride types
+func (t RideType) IsValid() bool {
+	switch t {
+	case RideTypeEconomy, RideTypePremium, RideTypeBike:
+		return true
+	}
+	return false
+}
+
+// Who cancelled a ride
+const (
+	CancelledByCustomer = "customer"
+	CancelledByDriver   = "driver"
+	CancelledBySystem   = "system" // e.g. ride request expired without a driver
+)
+
+// MaxWaypoints is the most intermediate stops a ride can have
+const MaxWaypoints = 5
+
+// MaxCancellationReasonLength is the longest cancellation reason accepted
+const MaxCancellationReasonLength = 255
+
 // Ride represents a ride request
 type Ride struct {
-	ID              int64      `json:"id"`
-	CustomerID      int64      `json:"customer_id"`
-	DriverID        *int64     `json:"driver_id,omitempty"`
-	PickupLat       float64    `json:"pickup_lat"`
-	PickupLng       float64    `json:"pickup_lng"`
-	DropoffLat      float64    `json:"dropoff_lat"`
-	DropoffLng      float64    `json:"dropoff_lng"`
-	Status          RideStatus `json:"status"`
-	Fare            *float64   `json:"fare,omitempty"`
-	RequestedAt     time.Time  `json:"requested_at"`
-	AcceptedAt      *time.Time `json:"accepted_at,omitempty"`
-	StartedAt       *time.Time `json:"started_at,omitempty"`
-	CompletedAt     *time.Time `json:"completed_at,omitempty"`
-	CancelledAt     *time.Time `json:"cancelled_at,omitempty"`
-	PickupLocation  Location   `json:"-"`
-	DropoffLocation Location   `json:"-"`
+	ID                 int64             `json:"id"`
+	CustomerID         int64             `json:"customer_id"`
+	DriverID           *int64            `json:"driver_id,omitempty"`
+	PickupLat          float64           `json:"pickup_lat"`
+	PickupLng          float64           `json:"pickup_lng"`
+	DropoffLat         float64           `json:"dropoff_lat"`
+	DropoffLng         float64           `json:"dropoff_lng"`
+	Waypoints          []Location        `json:"waypoints,omitempty"` // ordered stops between pickup and dropoff
+	Status             RideStatus        `json:"status"`
+	RideType           RideType          `json:"ride_type"`
+	Fare               *float64          `json:"fare,omitempty"`
+	SurgeMultiplier    float64           `json:"surge_multiplier,omitempty"` // applied to the fare, set when the ride is requested
+	PromoCode          string            `json:"promo_code,omitempty"`       // applied to the fare when the ride completes
+	Discount           *float64          `json:"discount,omitempty"`         // taken off the fare by the promo code, set on completion
+	CommissionRate     *float64          `json:"commission_rate,omitempty"`  // share of the fare kept by the platform, the rate in effect on completion
+	RequestedAt        time.Time         `json:"requested_at"`
+	RequeuedAt         *time.Time        `json:"requeued_at,omitempty"` // when the ride last went back to requested after a driver abandoned it, restarts the request timeout
+	AcceptedAt         *time.Time        `json:"accepted_at,omitempty"`
+	ArrivedAt          *time.Time        `json:"arrived_at,omitempty"`
+	StartedAt          *time.Time        `json:"started_at,omitempty"`
+	CompletedAt        *time.Time        `json:"completed_at,omitempty"`
+	CancelledAt        *time.Time        `json:"cancelled_at,omitempty"`
+	CancelledBy        string            `json:"cancelled_by,omitempty"`
+	CancellationReason string            `json:"cancellation_reason,omitempty"`
+	OfferedDriverID    *int64            `json:"offered_driver_id,omitempty"` // the only driver who may accept the ride until the offer expires
+	OfferExpiresAt     *time.Time        `json:"offer_expires_at,omitempty"`  // when the ride is offered to the next driver
+	DeclinedBy         []int64           `json:"-"`                           // drivers who declined the ride or let their offer expire
+	Abandonments       []RideAbandonment `json:"abandonments,omitempty"`      // drivers who cancelled after accepting, oldest first
+	PickupLocation     Location          `json:"-"`
+	DropoffLocation    Location          `json:"-"`
+	DistanceFromDriver float64           `json:"distance_from_driver,omitempty"` // in meters, only set for nearby ride listings
+}
+
+// RideAbandonment records a driver cancelling a ride they had accepted, the ride went back to requested
+type RideAbandonment struct {
+	DriverID    int64     `json:"driver_id"`
+	Reason      string    `json:"reason,omitempty"`
+	AbandonedAt time.Time `json:"abandoned_at"`
+}
+
+// Rating bounds
+const (
+	MinRatingStars         = 1
+	MaxRatingStars         = 5
+	MaxRatingCommentLength = 500
+)
+
+// Rating is the feedback one participant of a completed ride gives the other
+type Rating struct {
+	ID        string    `json:"id"`
+	RideID    int64     `json:"ride_id"`
+	RaterID   int64     `json:"rater_id"`
+	RaterRole string    `json:"rater_role"`
+	RateeID   int64     `json:"ratee_id"`
+	RateeRole string    `json:"ratee_role"`
+	Stars     int       `json:"stars"`
+	Comment   string    `json:"comment,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RideEvent records one status change of a ride, a ride's events in order are its timeline
+type RideEvent struct {
+	ID         string     `json:"id"`
+	RideID     int64      `json:"ride_id"`
+	FromStatus RideStatus `json:"from_status,omitempty"` // empty for the request that created the ride
+	ToStatus   RideStatus `json:"to_status"`
+	ActorRole  string     `json:"actor_role"`         // customer, driver or system
+	ActorID    int64      `json:"actor_id,omitempty"` // 0 for the system
+	Reason     string     `json:"reason,omitempty"`   // why the ride was cancelled or abandoned
+	OccurredAt time.Time  `json:"occurred_at"`
+}
+
+// SavedLocation is a place a customer stored under a label such as "Home" or "Work"
+type SavedLocation struct {
+	ID         int64     `json:"id"`
+	CustomerID int64     `json:"customer_id"`
+	Label      string    `json:"label"`
+	Lat        float64   `json:"lat"`
+	Lng        float64   `json:"lng"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// DriverSession is one stretch of time a driver was online, from going online until going offline or timing out
+type DriverSession struct {
+	ID              int64     `json:"id"`
+	DriverID        int64     `json:"driver_id"`
+	StartedAt       time.Time `json:"started_at"`
+	EndedAt         time.Time `json:"ended_at"`
+	DurationSeconds int64     `json:"duration_seconds"`
+}
+
+// Duration is how long the driver was online
+func (s *DriverSession) Duration() time.Duration {
+	return s.EndedAt.Sub(s.StartedAt)
+}
+
+// DeviceToken is a push notification token registered by a customer's or driver's device
+type DeviceToken struct {
+	ID        int64     `json:"id"`
+	UserRole  string    `json:"user_role"` // customer or driver
+	UserID    int64     `json:"user_id"`
+	Token     string    `json:"token"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 // Validation errors
 var (
-	ErrInvalidPhone      = errors.New("invalid phone number")
-	ErrInvalidEmail      = errors.New("invalid email")
+	ErrInvalidPhone      = NewError(ErrValidation, "invalid phone number")
+	ErrInvalidEmail      = NewError(ErrValidation, "invalid email")
 	ErrInvalidUserType   = errors.New("invalid user type")
-	ErrInvalidRideStatus = errors.New("invalid ride status")
+	ErrInvalidRideStatus = NewError(ErrValidation, "invalid ride status")
+	ErrInvalidRideType   = NewError(ErrValidation, "ride type must be economy, premium or bike")
+	ErrTooManyWaypoints  = NewError(ErrValidation, "too many waypoints")
+	ErrSamePickupDropoff = NewError(ErrValidation, "pickup and dropoff must be different locations")
+
+	ErrInvalidCancelledBy        = NewError(ErrValidation, "cancelled by must be customer, driver or system")
+	ErrInvalidCancellationReason = NewError(ErrValidation, "cancellation reason is too long")
+
+	ErrRideNotAwaitingDriver = NewError(ErrConflict, "ride is not in requested or pending status")
+	ErrRideNotDriverAccepted = NewError(ErrConflict, "ride is not accepted by this driver")
+	ErrRideNotArrivable      = NewError(ErrConflict, "only the driver of an accepted ride can mark arrived")
+	ErrRideNotArrived        = NewError(ErrValidation, "driver must mark arrived before starting the ride")
+	ErrRideNotStartable      = NewError(ErrConflict, "ride must be accepted before starting")
+	ErrRideNotCompletable    = NewError(ErrConflict, "ride must be started before completing")
+	ErrRideCompleted         = NewError(ErrConflict, "cannot cancel completed ride")
+
+	ErrInvalidRatingStars   = NewError(ErrValidation, "stars must be between 1 and 5")
+	ErrInvalidRatingComment = NewError(ErrValidation, "rating comment is too long")
+
+	ErrInvalidDriverName = NewError(ErrValidation, "driver name must be between 1 and 100 characters")
+	ErrInvalidVehicleNo  = NewError(ErrValidation, "vehicle number must be 3 to 32 letters, digits, spaces or hyphens")
+
+	ErrInvalidSavedLocationLabel = NewError(ErrValidation, "saved location label must be between 1 and 50 characters")
+
+	ErrInvalidDeviceToken = NewError(ErrValidation, "device token must be between 1 and 512 characters")
 )
 
+// MaxDeviceTokenLength is the longest push notification token accepted
+const MaxDeviceTokenLength = 512
+
+// MaxSavedLocationLabelLength is the longest saved location label accepted
+const MaxSavedLocationLabelLength = 50
+
+// MaxDriverNameLength is the longest driver name accepted
+const MaxDriverNameLength = 100
+
+// vehicleNoPattern matches plate numbers like "DHAKA METRO-GA-12-3456" after NormalizeVehicleNo
+var vehicleNoPattern = regexp.MustCompile(`^[A-Z0-9][A-Z0-9 -]{1,30}[A-Z0-9]$`)
+
+// NormalizeVehicleNo trims and upper-cases a vehicle number before validation and storage
+func NormalizeVehicleNo(vehicleNo string) string {
+	return strings.ToUpper(strings.TrimSpace(vehicleNo))
+}
+
+// ValidateDriverProfile validates the fields a driver can edit on their profile
+// vehicleNo is expected to be normalized with NormalizeVehicleNo
+func ValidateDriverProfile(name, vehicleNo string) error {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > MaxDriverNameLength {
+		return ErrInvalidDriverName
+	}
+	if !vehicleNoPattern.MatchString(vehicleNo) {
+		return ErrInvalidVehicleNo
+	}
+	return nil
+}
+
+// ValidateSavedLocation validates a saved location's label and coordinates
+func ValidateSavedLocation(l *SavedLocation) error {
+	label := strings.TrimSpace(l.Label)
+	if label == "" || utf8.RuneCountInString(label) > MaxSavedLocationLabelLength {
+		return ErrInvalidSavedLocationLabel
+	}
+	return Location{Latitude: l.Lat, Longitude: l.Lng}.Validate()
+}
+
 // ValidateCustomer validates customer data
 func ValidateCustomer(c *Customer) error {
 	if c.Phone == "" {
@@ -101,16 +317,57 @@ func ValidateDriver(d *Driver) error {
 	if d.Phone == "" {
 		return ErrInvalidPhone
 	}
+	if d.VehicleType != "" && !d.VehicleType.IsValid() {
+		return ErrInvalidRideType
+	}
 	//if d.Name == "" {
 	//	return errors.New("driver name is required")
 	//}
 	return nil
 }
 
+// ValidateRating validates rating data
+func ValidateRating(r *Rating) error {
+	if r.Stars < MinRatingStars || r.Stars > MaxRatingStars {
+		return ErrInvalidRatingStars
+	}
+	if len(r.Comment) > MaxRatingCommentLength {
+		return ErrInvalidRatingComment
+	}
+	return nil
+}
+
+// IsTerminal reports whether the ride has finished, either completed or cancelled
+func (r *Ride) IsTerminal() bool {
+	return r.Status.IsTerminal()
+}
+
+// Route returns the planned stops of the ride in order: pickup, waypoints, dropoff
+func (r *Ride) Route() []Location {
+	route := make([]Location, 0, len(r.Waypoints)+2)
+	route = append(route, Location{Latitude: r.PickupLat, Longitude: r.PickupLng})
+	route = append(route, r.Waypoints...)
+	route = append(route, Location{Latitude: r.DropoffLat, Longitude: r.DropoffLng})
+	return route
+}
+
+// ValidateWaypoints checks the waypoint count and that every waypoint has valid coordinates
+func ValidateWaypoints(waypoints []Location) error {
+	if len(waypoints) > MaxWaypoints {
+		return ErrTooManyWaypoints
+	}
+	for _, waypoint := range waypoints {
+		if err := waypoint.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Accept marks the ride as accepted by a driver
 func (r *Ride) Accept(driverID int64) error {
-	if r.Status != RideStatusRequested && r.Status != RideStatusPending {
-		return errors.New("ride is not in requested or pending status")
+	if !r.Status.IsAwaitingDriver() {
+		return ErrRideNotAwaitingDriver
 	}
 	now := time.Now()
 	r.DriverID = &driverID
@@ -119,10 +376,79 @@ func (r *Ride) Accept(driverID int64) error {
 	return nil
 }
 
+// OfferTo offers the ride to a single driver until expiresAt, the ride is pending while the offer stands
+func (r *Ride) OfferTo(driverID int64, expiresAt time.Time) {
+	r.Status = RideStatusPending
+	r.OfferedDriverID = &driverID
+	r.OfferExpiresAt = &expiresAt
+}
+
+// ClearOffer withdraws the current offer and returns the ride to requested, it is dispatched again after retryAt
+func (r *Ride) ClearOffer(retryAt time.Time) {
+	r.Status = RideStatusRequested
+	r.OfferedDriverID = nil
+	r.OfferExpiresAt = &retryAt
+}
+
+// IsOfferedTo reports whether driverID holds an offer for the ride that has not expired at now
+func (r *Ride) IsOfferedTo(driverID int64, now time.Time) bool {
+	return r.OfferedDriverID != nil && *r.OfferedDriverID == driverID &&
+		r.OfferExpiresAt != nil && now.Before(*r.OfferExpiresAt)
+}
+
+// HasDeclined reports whether driverID declined the ride or let an offer for it expire
+func (r *Ride) HasDeclined(driverID int64) bool {
+	for _, id := range r.DeclinedBy {
+		if id == driverID {
+			return true
+		}
+	}
+	return false
+}
+
+// Abandon returns a ride accepted by driverID but not started to requested so it can be dispatched again
+// The driver is added to DeclinedBy so the ride is not offered to them again, and RequeuedAt restarts the request timeout
+func (r *Ride) Abandon(driverID int64, reason string, now time.Time) error {
+	if !r.Status.IsAwaitingPickup() || r.DriverID == nil || *r.DriverID != driverID {
+		return ErrRideNotDriverAccepted
+	}
+	reason = strings.TrimSpace(reason)
+	if len(reason) > MaxCancellationReasonLength {
+		return ErrInvalidCancellationReason
+	}
+	r.Status = RideStatusRequested
+	r.RequeuedAt = &now
+	r.DriverID = nil
+	r.AcceptedAt = nil
+	r.ArrivedAt = nil
+	r.OfferedDriverID = nil
+	r.OfferExpiresAt = nil
+	if !r.HasDeclined(driverID) {
+		r.DeclinedBy = append(r.DeclinedBy, driverID)
+	}
+	r.Abandonments = append(r.Abandonments, RideAbandonment{DriverID: driverID, Reason: reason, AbandonedAt: now})
+	return nil
+}
+
+// MarkArrived records that driverID reached the pickup and is waiting for the customer
+func (r *Ride) MarkArrived(driverID int64, now time.Time) error {
+	if r.Status != RideStatusAccepted || r.DriverID == nil || *r.DriverID != driverID {
+		return ErrRideNotArrivable
+	}
+	r.Status = RideStatusArrived
+	r.ArrivedAt = &now
+	return nil
+}
+
 // Start marks the ride as started
-func (r *Ride) Start() error {
-	if r.Status != RideStatusAccepted {
-		return errors.New("ride must be accepted before starting")
+// With requireArrival the driver must have marked arrived first, otherwise an accepted ride can be started too
+func (r *Ride) Start(requireArrival bool) error {
+	switch {
+	case r.Status == RideStatusArrived:
+	case r.Status == RideStatusAccepted && requireArrival:
+		return ErrRideNotArrived
+	case r.Status != RideStatusAccepted:
+		return ErrRideNotStartable
 	}
 	now := time.Now()
 	r.Status = RideStatusStarted
@@ -133,7 +459,7 @@ func (r *Ride) Start() error {
 // Complete marks the ride as completed
 func (r *Ride) Complete() error {
 	if r.Status != RideStatusStarted {
-		return errors.New("ride must be started before completing")
+		return ErrRideNotCompletable
 	}
 	now := time.Now()
 	r.Status = RideStatusCompleted
@@ -141,13 +467,22 @@ func (r *Ride) Complete() error {
 	return nil
 }
 
-// Cancel marks the ride as cancelled
-func (r *Ride) Cancel() error {
+// Cancel marks the ride as cancelled, recording who cancelled it and why
+func (r *Ride) Cancel(cancelledBy, reason string) error {
 	if r.Status == RideStatusCompleted {
-		return errors.New("cannot cancel completed ride")
+		return ErrRideCompleted
+	}
+	if cancelledBy != CancelledByCustomer && cancelledBy != CancelledByDriver && cancelledBy != CancelledBySystem {
+		return ErrInvalidCancelledBy
+	}
+	reason = strings.TrimSpace(reason)
+	if len(reason) > MaxCancellationReasonLength {
+		return ErrInvalidCancellationReason
 	}
 	now := time.Now()
 	r.Status = RideStatusCancelled
 	r.CancelledAt = &now
+	r.CancelledBy = cancelledBy
+	r.CancellationReason = reason
 	return nil
 }
@@ -0,0 +1,59 @@
+package domain
+
+import (
+	"math"
+	"strings"
+	"time"
+)
+
+// PromoDiscountType is how a promo code takes money off the fare
+type PromoDiscountType string
+
+const (
+	PromoDiscountPercentage PromoDiscountType = "percentage" // a percentage of the fare
+	PromoDiscountFlat       PromoDiscountType = "flat"       // a fixed amount
+)
+
+// PromoCode is a discount customers can apply to a ride, it is redeemed when the ride completes
+type PromoCode struct {
+	ID                 int64             `json:"id"`
+	Code               string            `json:"code"`
+	DiscountType       PromoDiscountType `json:"discount_type"`
+	DiscountValue      float64           `json:"discount_value"`        // percent off for percentage codes, amount off for flat ones
+	MaxUsesPerCustomer int               `json:"max_uses_per_customer"` // 0 for no limit
+	Active             bool              `json:"active"`
+	ExpiresAt          *time.Time        `json:"expires_at,omitempty"` // nil for codes that never expire
+	CreatedAt          time.Time         `json:"created_at"`
+}
+
+// PromoRedemption records a customer using a promo code on a ride
+type PromoRedemption struct {
+	ID          int64     `json:"id"`
+	PromoCodeID int64     `json:"promo_code_id"`
+	CustomerID  int64     `json:"customer_id"`
+	RideID      int64     `json:"ride_id"`
+	Discount    float64   `json:"discount"` // amount taken off the fare
+	RedeemedAt  time.Time `json:"redeemed_at"`
+}
+
+// NormalizePromoCode returns code in the form it is stored in, promo codes are case insensitive
+func NormalizePromoCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// IsUsable reports whether the code is active and has not expired at now
+func (p *PromoCode) IsUsable(now time.Time) bool {
+	return p.Active && (p.ExpiresAt == nil || now.Before(*p.ExpiresAt))
+}
+
+// Apply returns fare after the code's discount, the discounted fare is never negative
+func (p *PromoCode) Apply(fare float64) float64 {
+	var discount float64
+	switch p.DiscountType {
+	case PromoDiscountPercentage:
+		discount = fare * math.Min(p.DiscountValue, 100) / 100
+	case PromoDiscountFlat:
+		discount = p.DiscountValue
+	}
+	return math.Max(fare-math.Max(discount, 0), 0)
+}
@@ -0,0 +1,46 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPromoCode_Apply(t *testing.T) {
+	tests := []struct {
+		name     string
+		promo    PromoCode
+		fare     float64
+		expected float64
+	}{
+		{"percentage", PromoCode{DiscountType: PromoDiscountPercentage, DiscountValue: 20}, 250, 200},
+		{"percentage above 100 is free", PromoCode{DiscountType: PromoDiscountPercentage, DiscountValue: 150}, 250, 0},
+		{"flat", PromoCode{DiscountType: PromoDiscountFlat, DiscountValue: 50}, 250, 200},
+		{"flat above the fare is free", PromoCode{DiscountType: PromoDiscountFlat, DiscountValue: 300}, 250, 0},
+		{"negative discount is ignored", PromoCode{DiscountType: PromoDiscountFlat, DiscountValue: -50}, 250, 250},
+		{"unknown type is ignored", PromoCode{DiscountType: "bogo", DiscountValue: 50}, 250, 250},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.InDelta(t, tt.expected, tt.promo.Apply(tt.fare), 1e-9)
+		})
+	}
+}
+
+func TestPromoCode_IsUsable(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+
+	assert.True(t, (&PromoCode{Active: true}).IsUsable(now))
+	assert.True(t, (&PromoCode{Active: true, ExpiresAt: &future}).IsUsable(now))
+	assert.False(t, (&PromoCode{Active: true, ExpiresAt: &past}).IsUsable(now))
+	assert.False(t, (&PromoCode{Active: true, ExpiresAt: &now}).IsUsable(now))
+	assert.False(t, (&PromoCode{Active: false}).IsUsable(now))
+}
+
+func TestNormalizePromoCode(t *testing.T) {
+	assert.Equal(t, "SAVE20", NormalizePromoCode("  save20 "))
+}
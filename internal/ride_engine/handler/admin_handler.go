@@ -0,0 +1,443 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/repository"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/service"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/logger"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/pagination"
+)
+
+// OnlineDriversCountResponse is the number of drivers currently online
+type OnlineDriversCountResponse struct {
+	Count int64 `json:"count"`
+}
+
+type CreateGeofenceRequest struct {
+	Name     string            `json:"name" validate:"required"`
+	Boundary []domain.Location `json:"boundary" validate:"required"` // polygon vertices in order, at least 3
+	Active   bool              `json:"active"`
+}
+
+type AdminHandler struct {
+	rideService     *service.RideService
+	driverService   *service.DriverService
+	locationService *service.LocationService
+	geofenceService *service.GeofenceService
+	otpService      *service.OTPService
+}
+
+func NewAdminHandler(rideService *service.RideService, driverService *service.DriverService, locationService *service.LocationService, geofenceService *service.GeofenceService, otpService *service.OTPService) *AdminHandler {
+	return &AdminHandler{
+		rideService:     rideService,
+		driverService:   driverService,
+		locationService: locationService,
+		geofenceService: geofenceService,
+		otpService:      otpService,
+	}
+}
+
+// ListRides handles admins listing all rides
+// @Summary List rides
+// @Description Get a page of all rides, newest first, optionally filtered by status, requested date range, driver and customer
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param status query string false "Only return rides in this status" Enums(requested, pending, accepted, arrived, started, completed, cancelled)
+// @Param from query string false "Requested on or after this date (YYYY-MM-DD)"
+// @Param to query string false "Requested on or before this date (YYYY-MM-DD), inclusive"
+// @Param driver_id query integer false "Only return rides of this driver"
+// @Param customer_id query integer false "Only return rides of this customer"
+// @Param limit query integer false "Page size, default 20, max 100"
+// @Param offset query integer false "Number of rides to skip, default 0, ignored with cursor"
+// @Param cursor query string false "next_cursor of the previous page, pages stay in place when new rides are requested"
+// @Success 200 {object} service.RideHistoryPage "Page of rides with total count and the cursor of the next page"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - admin role required"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /admin/rides [get]
+func (h *AdminHandler) ListRides(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	filter := repository.RideFilter{Status: domain.RideStatus(c.QueryParam("status"))}
+
+	if fromStr := c.QueryParam("from"); fromStr != "" {
+		parsed, err := time.Parse(dateLayout, fromStr)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid from date, expected YYYY-MM-DD"})
+		}
+		filter.From = parsed
+	}
+	if toStr := c.QueryParam("to"); toStr != "" {
+		parsed, err := time.Parse(dateLayout, toStr)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid to date, expected YYYY-MM-DD"})
+		}
+		// to is inclusive, so the range ends at the start of the following day
+		filter.To = parsed.AddDate(0, 0, 1)
+	}
+
+	if driverIDStr := c.QueryParam("driver_id"); driverIDStr != "" {
+		parsed, err := strconv.ParseInt(driverIDStr, 10, 64)
+		if err != nil || parsed < 1 {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid driver_id"})
+		}
+		filter.DriverID = parsed
+	}
+	if customerIDStr := c.QueryParam("customer_id"); customerIDStr != "" {
+		parsed, err := strconv.ParseInt(customerIDStr, 10, 64)
+		if err != nil || parsed < 1 {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid customer_id"})
+		}
+		filter.CustomerID = parsed
+	}
+
+	limit := 20 // default 20 rides
+	if limitStr := c.QueryParam("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed < 1 {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid limit"})
+		}
+		limit = parsed
+	}
+	if limit > 100 {
+		limit = 100 // cap at 100 rides
+	}
+
+	offset := 0
+	if offsetStr := c.QueryParam("offset"); offsetStr != "" {
+		parsed, err := strconv.Atoi(offsetStr)
+		if err != nil || parsed < 0 {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid offset"})
+		}
+		offset = parsed
+	}
+
+	cursor, err := pagination.Decode(c.QueryParam("cursor"), service.RideListSort)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+	}
+
+	page, err := h.rideService.ListRides(ctx, filter, repository.Page{Limit: limit, Offset: offset, Cursor: cursor})
+	if err != nil {
+		logger.Error(ctx, err)
+		if errors.Is(err, domain.ErrInvalidRideStatus) || errors.Is(err, service.ErrInvalidDateRange) {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, page)
+}
+
+// GetOnlineDrivers handles admins searching the online drivers in an area
+// @Summary List online drivers in an area
+// @Description Get the online drivers with a location ping in the last 2 minutes within radius meters of a point, nearest first, with their profile, distance and last ping time
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param lat query number true "Latitude of the search center"
+// @Param lng query number true "Longitude of the search center"
+// @Param radius query number false "Search radius in meters, default 3000, max 50000"
+// @Success 200 {array} service.OnlineDriverLocation "Online drivers, nearest first"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - admin role required"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /admin/drivers/online [get]
+func (h *AdminHandler) GetOnlineDrivers(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	lat, err := strconv.ParseFloat(c.QueryParam("lat"), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid lat"})
+	}
+	lng, err := strconv.ParseFloat(c.QueryParam("lng"), 64)
+	if err != nil || lng < -180 || lng > 180 {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid lng"})
+	}
+
+	radius := service.DefaultOnlineDriverSearchRadius
+	if radiusStr := c.QueryParam("radius"); radiusStr != "" {
+		parsed, err := strconv.ParseFloat(radiusStr, 64)
+		if err != nil || parsed <= 0 || parsed > service.MaxOnlineDriverSearchRadius {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "radius must be greater than 0 and at most 50000 meters"})
+		}
+		radius = parsed
+	}
+
+	drivers, err := h.driverService.GetOnlineDriversNear(ctx, lat, lng, radius)
+	if err != nil {
+		logger.Error(ctx, err)
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, drivers)
+}
+
+// GetOnlineDriversCount handles admins getting the number of online drivers
+// @Summary Count online drivers
+// @Description Get the number of drivers online with a ping in the last 2 minutes, the count may be up to 5 seconds old
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} OnlineDriversCountResponse "Number of online drivers"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - admin role required"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /admin/drivers/online-count [get]
+func (h *AdminHandler) GetOnlineDriversCount(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	count, err := h.driverService.GetCachedOnlineDriversCount(ctx)
+	if err != nil {
+		logger.Error(ctx, err)
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, OnlineDriversCountResponse{Count: count})
+}
+
+// GetHeatmap handles admins getting the density of online drivers in an area
+// @Summary Get online driver heatmap
+// @Description Count the drivers with a location ping in the last 2 minutes per grid cell of the bounding box. Cells are identified by their south west corner and only cells with drivers are returned.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param min_lat query number true "Southern latitude of the bounding box"
+// @Param min_lng query number true "Western longitude of the bounding box"
+// @Param max_lat query number true "Northern latitude of the bounding box"
+// @Param max_lng query number true "Eastern longitude of the bounding box"
+// @Param grid_size query number false "Cell size in degrees, default 0.01, at most 10000 cells"
+// @Success 200 {array} repository.DensityCell "Driver count per cell"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - admin role required"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /admin/heatmap [get]
+func (h *AdminHandler) GetHeatmap(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var bounds repository.GeoBounds
+	for _, param := range []struct {
+		name  string
+		value *float64
+	}{
+		{"min_lat", &bounds.MinLat},
+		{"min_lng", &bounds.MinLng},
+		{"max_lat", &bounds.MaxLat},
+		{"max_lng", &bounds.MaxLng},
+	} {
+		parsed, err := strconv.ParseFloat(c.QueryParam(param.name), 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid " + param.name})
+		}
+		*param.value = parsed
+	}
+
+	gridSize := service.DefaultHeatmapGridSize
+	if gridSizeStr := c.QueryParam("grid_size"); gridSizeStr != "" {
+		parsed, err := strconv.ParseFloat(gridSizeStr, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid grid_size"})
+		}
+		gridSize = parsed
+	}
+
+	cells, err := h.locationService.GetDriverDensity(ctx, bounds, gridSize)
+	if err != nil {
+		logger.Error(ctx, err)
+		if errors.Is(err, service.ErrInvalidHeatmapBounds) || errors.Is(err, service.ErrInvalidHeatmapGrid) {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, cells)
+}
+
+// CreateGeofence handles admins adding a service area
+// @Summary Create a geofence
+// @Description Add a service area polygon. When RIDE_GEOFENCE_ENABLED is set, rides can only be requested with a pickup inside an active geofence
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body CreateGeofenceRequest true "Geofence name, boundary and whether it is active"
+// @Success 201 {object} domain.Geofence "Created geofence"
+// @Failure 400 {object} ValidationErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - admin role required"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /admin/geofences [post]
+func (h *AdminHandler) CreateGeofence(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var req CreateGeofenceRequest
+	if err := c.Bind(&req); err != nil {
+		logger.Error(ctx, err)
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid request body"})
+	}
+
+	if err := c.Validate(&req); err != nil {
+		logger.Error(ctx, err)
+		return c.JSON(http.StatusBadRequest, NewValidationErrorResponse(err))
+	}
+
+	geofence, err := h.geofenceService.Create(ctx, req.Name, req.Boundary, req.Active)
+	if err != nil {
+		logger.Error(ctx, err)
+		if errors.Is(err, domain.ErrGeofenceNameRequired) ||
+			errors.Is(err, domain.ErrInvalidGeofenceBoundary) ||
+			errors.Is(err, domain.ErrInvalidLatitude) ||
+			errors.Is(err, domain.ErrInvalidLongitude) {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+
+	return c.JSON(http.StatusCreated, geofence)
+}
+
+// GetOTPHistory handles admins looking up the OTPs sent to a phone
+// @Summary OTP history of a phone
+// @Description Get a page of the OTPs sent to a phone, newest first, for support debugging. Only metadata is returned, never the OTP itself
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param phone query string true "Phone the OTPs were sent to"
+// @Param purpose query string false "Only return OTPs sent for this purpose, e.g. driver_login or customer_password_reset"
+// @Param from query string false "Sent on or after this date (YYYY-MM-DD)"
+// @Param to query string false "Sent on or before this date (YYYY-MM-DD), inclusive"
+// @Param limit query integer false "Page size, default 20, max 100"
+// @Param offset query integer false "Number of OTPs to skip, default 0, ignored with cursor"
+// @Param cursor query string false "next_cursor of the previous page, pages stay in place when new OTPs are sent"
+// @Success 200 {object} service.OTPHistoryPage "Page of OTPs with total count and the cursor of the next page"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - admin role required"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /admin/otp-history [get]
+func (h *AdminHandler) GetOTPHistory(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	filter := repository.OTPFilter{
+		Phone:   c.QueryParam("phone"),
+		Purpose: c.QueryParam("purpose"),
+	}
+
+	if fromStr := c.QueryParam("from"); fromStr != "" {
+		parsed, err := time.Parse(dateLayout, fromStr)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid from date, expected YYYY-MM-DD"})
+		}
+		filter.From = parsed
+	}
+	if toStr := c.QueryParam("to"); toStr != "" {
+		parsed, err := time.Parse(dateLayout, toStr)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid to date, expected YYYY-MM-DD"})
+		}
+		// to is inclusive, so the range ends at the start of the following day
+		filter.To = parsed.AddDate(0, 0, 1)
+	}
+
+	limit := 20 // default 20 OTPs
+	if limitStr := c.QueryParam("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed < 1 {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid limit"})
+		}
+		limit = parsed
+	}
+	if limit > 100 {
+		limit = 100 // cap at 100 OTPs
+	}
+
+	offset := 0
+	if offsetStr := c.QueryParam("offset"); offsetStr != "" {
+		parsed, err := strconv.Atoi(offsetStr)
+		if err != nil || parsed < 0 {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid offset"})
+		}
+		offset = parsed
+	}
+
+	cursor, err := pagination.Decode(c.QueryParam("cursor"), service.OTPHistorySort)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+	}
+
+	page, err := h.otpService.GetOTPHistory(ctx, filter, repository.Page{Limit: limit, Offset: offset, Cursor: cursor})
+	if err != nil {
+		logger.Error(ctx, err)
+		if errors.Is(err, service.ErrPhoneRequired) || errors.Is(err, service.ErrInvalidDateRange) {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, page)
+}
+
+// GetRevenue handles the platform revenue report
+// @Summary Get platform revenue
+// @Description Sum the platform commission on rides completed in a date range. Each ride is charged the commission rate in effect when it completed. Dates are inclusive; the range defaults to the last 30 days
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param from query string false "Start date (YYYY-MM-DD)"
+// @Param to query string false "End date (YYYY-MM-DD), inclusive"
+// @Success 200 {object} service.RevenueReport "Revenue report"
+// @Failure 400 {object} ErrorResponse "Invalid date range"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - admin role required"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /admin/revenue [get]
+func (h *AdminHandler) GetRevenue(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	// to is inclusive, so the range ends at the start of the following day
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	to := today.AddDate(0, 0, 1)
+	if toStr := c.QueryParam("to"); toStr != "" {
+		parsed, err := time.Parse(dateLayout, toStr)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid to date, expected YYYY-MM-DD"})
+		}
+		to = parsed.AddDate(0, 0, 1)
+	}
+
+	from := to.AddDate(0, 0, -30)
+	if fromStr := c.QueryParam("from"); fromStr != "" {
+		parsed, err := time.Parse(dateLayout, fromStr)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid from date, expected YYYY-MM-DD"})
+		}
+		from = parsed
+	}
+
+	report, err := h.rideService.GetRevenueReport(ctx, from, to)
+	if err != nil {
+		logger.Error(ctx, err)
+		if errors.Is(err, service.ErrInvalidDateRange) {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, report)
+}
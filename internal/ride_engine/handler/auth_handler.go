@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/logger"
+
+	"github.com/labstack/echo/v4"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/service"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/middleware"
+)
+
+type AuthHandler struct {
+	service         *service.AuthService
+	customerService *service.CustomerService
+	driverService   *service.DriverService
+}
+
+func NewAuthHandler(service *service.AuthService, customerService *service.CustomerService, driverService *service.DriverService) *AuthHandler {
+	return &AuthHandler{
+		service:         service,
+		customerService: customerService,
+		driverService:   driverService,
+	}
+}
+
+// MeResponse is the profile of the authenticated user, only the field matching the role is set
+type MeResponse struct {
+	Role     string           `json:"role" example:"driver"`
+	Customer *domain.Customer `json:"customer,omitempty"`
+	Driver   *domain.Driver   `json:"driver,omitempty"`
+}
+
+// Logout handles logging out the authenticated customer or driver
+// @Summary Logout
+// @Description Revoke the current access token. Subsequent requests with the same token are rejected, sessions on other devices stay logged in
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} MessageResponse "Logged out successfully"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /auth/logout [post]
+func (h *AuthHandler) Logout(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	userID, ok := middleware.GetUserIDFromEcho(c)
+	if !ok {
+		logger.Error(ctx, errors.New("missing user ID in context"))
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing user ID in context"})
+	}
+
+	role, ok := middleware.GetUserRoleFromEcho(c)
+	if !ok {
+		logger.Error(ctx, errors.New("missing role in context"))
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing role in context"})
+	}
+
+	sessionID, ok := middleware.GetSessionIDFromEcho(c)
+	if !ok {
+		logger.Error(ctx, errors.New("missing session ID in context"))
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing session ID in context"})
+	}
+
+	if err := h.service.Logout(ctx, role, userID, sessionID); err != nil {
+		logger.Error(ctx, err)
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "failed to logout"})
+	}
+
+	return c.JSON(http.StatusOK, MessageResponse{Message: "Logged out successfully"})
+}
+
+// Me handles fetching the profile of the authenticated customer or driver
+// @Summary Get my profile
+// @Description Get the profile of the authenticated user based on the token role. Drivers also get their online status and last known location
+// @Tags Auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} MeResponse "Profile of the authenticated user"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 404 {object} ErrorResponse "User not found"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /me [get]
+func (h *AuthHandler) Me(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	userID, ok := middleware.GetUserIDFromEcho(c)
+	if !ok {
+		logger.Error(ctx, errors.New("missing user ID in context"))
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing user ID in context"})
+	}
+
+	role, ok := middleware.GetUserRoleFromEcho(c)
+	if !ok {
+		logger.Error(ctx, errors.New("missing role in context"))
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing role in context"})
+	}
+
+	switch role {
+	case "customer":
+		customer, err := h.customerService.GetProfile(ctx, userID)
+		if err != nil {
+			logger.Error(ctx, err)
+			if errors.Is(err, service.ErrCustomerNotFound) {
+				return c.JSON(http.StatusNotFound, ErrorResponse{Error: err.Error()})
+			}
+			return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		}
+		return c.JSON(http.StatusOK, MeResponse{Role: role, Customer: customer})
+	case "driver":
+		driver, err := h.driverService.GetProfile(ctx, userID)
+		if err != nil {
+			logger.Error(ctx, err)
+			if errors.Is(err, service.ErrDriverNotFound) {
+				return c.JSON(http.StatusNotFound, ErrorResponse{Error: err.Error()})
+			}
+			return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		}
+		return c.JSON(http.StatusOK, MeResponse{Role: role, Driver: driver})
+	default:
+		// Admins have no account, their token is issued by the admin-token command
+		logger.Error(ctx, fmt.Sprintf("no profile for role %s", role))
+		return c.JSON(http.StatusNotFound, ErrorResponse{Error: fmt.Sprintf("no profile for role %s", role)})
+	}
+}
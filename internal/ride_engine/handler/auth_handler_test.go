@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/repository"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/repository/postgres"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/service"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/config"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/utils"
+)
+
+// The fakes embed the repository interfaces and only implement what GET /me uses
+
+type fakeCustomerRepository struct {
+	repository.CustomerRepository
+	customers map[int64]*domain.Customer
+}
+
+func (f fakeCustomerRepository) GetByID(ctx context.Context, id int64) (*domain.Customer, error) {
+	customer, ok := f.customers[id]
+	if !ok {
+		return nil, postgres.ErrCustomerNotFound
+	}
+	return customer, nil
+}
+
+type fakeDriverRepository struct {
+	repository.DriverRepository
+	drivers map[int64]*domain.Driver
+}
+
+func (f fakeDriverRepository) GetByID(ctx context.Context, id int64) (*domain.Driver, error) {
+	driver, ok := f.drivers[id]
+	if !ok {
+		return nil, postgres.ErrDriverNotFound
+	}
+	return driver, nil
+}
+
+type fakeOnlineStatusRepository struct {
+	repository.OnlineStatusRepository
+	online map[int64]bool
+}
+
+func (f fakeOnlineStatusRepository) IsDriverOnline(ctx context.Context, driverID int64) (bool, error) {
+	return f.online[driverID], nil
+}
+
+type fakeLocationRepository struct {
+	repository.LocationRepository
+	locations map[int64]repository.DriverLocation
+}
+
+func (f fakeLocationRepository) GetDriverLocation(ctx context.Context, driverID int64) (float64, float64, *time.Time, error) {
+	location, ok := f.locations[driverID]
+	if !ok {
+		return 0, 0, nil, errors.New("driver location not found")
+	}
+	return location.Location.Coordinates[1], location.Location.Coordinates[0], &location.UpdatedAt, nil
+}
+
+func newTestAuthHandler(customers map[int64]*domain.Customer, drivers map[int64]*domain.Driver, online map[int64]bool, locations map[int64]repository.DriverLocation) *AuthHandler {
+	customerService := service.NewCustomerService(fakeCustomerRepository{customers: customers}, nil, utils.JWTKeys{Secret: "secret"}, 24, nil, nil, nil, nil)
+	locationService := service.NewLocationService(fakeLocationRepository{locations: locations}, config.LocationConfig{MaxSearchRadiusMeters: 50000})
+	driverService := service.NewDriverService(fakeDriverRepository{drivers: drivers}, nil, nil, fakeOnlineStatusRepository{online: online}, nil, nil, locationService, nil, utils.JWTKeys{Secret: "secret"}, 24, nil, 0, nil, nil)
+	return NewAuthHandler(nil, customerService, driverService)
+}
+
+func serveMe(t *testing.T, h *AuthHandler, userID int64, role string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/me", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.Set("user_id", userID)
+	c.Set("user_role", role)
+
+	require.NoError(t, h.Me(c))
+	return rec
+}
+
+func TestAuthHandler_Me_Customer(t *testing.T) {
+	customer := &domain.Customer{ID: 123, Name: "Rahim", Email: "rahim@example.com", Phone: "+8801711000000"}
+	h := newTestAuthHandler(map[int64]*domain.Customer{123: customer}, nil, nil, nil)
+
+	rec := serveMe(t, h, 123, "customer")
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resp MeResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, "customer", resp.Role)
+	require.NotNil(t, resp.Customer)
+	assert.Equal(t, customer.Email, resp.Customer.Email)
+	assert.Nil(t, resp.Driver)
+}
+
+func TestAuthHandler_Me_Driver(t *testing.T) {
+	driver := &domain.Driver{ID: 456, Name: "Karim", Phone: "+8801811000000", VehicleNo: "ABC-123"}
+	pingedAt := time.Now().Add(-30 * time.Second).UTC().Truncate(time.Second)
+	h := newTestAuthHandler(nil, map[int64]*domain.Driver{456: driver}, map[int64]bool{456: true}, map[int64]repository.DriverLocation{
+		456: {DriverID: 456, Location: repository.GeoJSON{Type: "Point", Coordinates: []float64{90.4125, 23.8103}}, UpdatedAt: pingedAt},
+	})
+
+	rec := serveMe(t, h, 456, "driver")
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resp MeResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, "driver", resp.Role)
+	assert.Nil(t, resp.Customer)
+	require.NotNil(t, resp.Driver)
+	assert.Equal(t, "ABC-123", resp.Driver.VehicleNo)
+	assert.True(t, resp.Driver.IsOnline)
+	require.NotNil(t, resp.Driver.CurrentLat)
+	require.NotNil(t, resp.Driver.CurrentLng)
+	assert.Equal(t, 23.8103, *resp.Driver.CurrentLat)
+	assert.Equal(t, 90.4125, *resp.Driver.CurrentLng)
+	require.NotNil(t, resp.Driver.LastPingAt)
+	assert.True(t, pingedAt.Equal(*resp.Driver.LastPingAt))
+}
+
+func TestAuthHandler_Me_UserNoLongerExists(t *testing.T) {
+	h := newTestAuthHandler(nil, nil, nil, nil)
+
+	assert.Equal(t, http.StatusNotFound, serveMe(t, h, 123, "customer").Code)
+	assert.Equal(t, http.StatusNotFound, serveMe(t, h, 456, "driver").Code)
+}
+
+func TestAuthHandler_Me_Admin(t *testing.T) {
+	h := newTestAuthHandler(nil, nil, nil, nil)
+
+	assert.Equal(t, http.StatusNotFound, serveMe(t, h, 1, "admin").Code, "Admins have no profile")
+}
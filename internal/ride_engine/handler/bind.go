@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// bindStrict decodes the JSON request body into req and rejects fields req does not have
+// It is used on the auth endpoints so a misspelt credential field fails loudly instead of being dropped
+// The returned error explains what is wrong with the body and is meant to be sent back with 400
+func bindStrict(c echo.Context, req interface{}) error {
+	decoder := json.NewDecoder(c.Request().Body)
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(req); err != nil {
+		return strictBindError(err)
+	}
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return errors.New("request body must contain a single JSON object")
+	}
+	return nil
+}
+
+// strictBindError turns a JSON decoding error into a message for the client
+func strictBindError(err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var maxBytesErr *http.MaxBytesError
+
+	switch {
+	case errors.Is(err, io.EOF):
+		return errors.New("request body is empty")
+	case errors.Is(err, io.ErrUnexpectedEOF), errors.As(err, &syntaxErr):
+		return errors.New("request body is not valid JSON")
+	case errors.As(err, &typeErr):
+		if typeErr.Field == "" {
+			return errors.New("request body must be a JSON object")
+		}
+		return fmt.Errorf("field %q must be a %s", typeErr.Field, typeErr.Type)
+	case errors.As(err, &maxBytesErr):
+		return fmt.Errorf("request body must not be larger than %d bytes", maxBytesErr.Limit)
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json has no error type for unknown fields
+		return fmt.Errorf("unknown field %s in request body", strings.TrimPrefix(err.Error(), "json: unknown field "))
+	}
+	return err
+}
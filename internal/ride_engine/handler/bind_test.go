@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appMiddleware "vcs.technonext.com/carrybee/ride_engine/pkg/middleware"
+)
+
+func newBindContext(body string) echo.Context {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	return echo.New().NewContext(req, httptest.NewRecorder())
+}
+
+func TestBindStrict(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantErr string
+	}{
+		{"known fields", `{"email":"rahim@example.com","password":"secret"}`, ""},
+		{"unknown field", `{"email":"rahim@example.com","pasword":"secret"}`, `unknown field "pasword" in request body`},
+		{"wrong type", `{"email":42}`, `field "email" must be a string`},
+		{"not an object", `["rahim@example.com"]`, "request body must be a JSON object"},
+		{"invalid JSON", `{"email":`, "request body is not valid JSON"},
+		{"empty", ``, "request body is empty"},
+		{"trailing data", `{"email":"rahim@example.com"} {}`, "request body must contain a single JSON object"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req LoginCustomerRequest
+			err := bindStrict(newBindContext(tt.body), &req)
+
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				assert.Equal(t, "rahim@example.com", req.Email)
+				return
+			}
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestCustomerHandler_Login_RejectsUnknownField(t *testing.T) {
+	h := NewCustomerHandler(nil)
+	c := newBindContext(`{"email":"rahim@example.com","password":"secret","is_admin":true}`)
+	rec := c.Response().Writer.(*httptest.ResponseRecorder)
+
+	require.NoError(t, h.Login(c))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	var resp ErrorResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, `unknown field "is_admin" in request body`, resp.Error)
+}
+
+func TestCustomerHandler_Login_RejectsOversizedBody(t *testing.T) {
+	h := NewCustomerHandler(nil)
+	body := `{"email":"rahim@example.com","password":"` + strings.Repeat("a", 2048) + `"}`
+
+	e := echo.New()
+	e.POST("/customers/login", h.Login, appMiddleware.BodyLimitEcho(1024))
+	req := httptest.NewRequest(http.MethodPost, "/customers/login", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	// Sent chunked, so the limit is only hit while the body is decoded
+	req.ContentLength = -1
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	var resp ErrorResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, "request body must not be larger than 1024 bytes", resp.Error)
+}
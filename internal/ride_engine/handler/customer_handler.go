@@ -1,11 +1,13 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 	"vcs.technonext.com/carrybee/ride_engine/pkg/logger"
 
 	"github.com/labstack/echo/v4"
 	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/service"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/middleware"
 )
 
 type CustomerHandler struct {
@@ -28,6 +30,27 @@ type LoginCustomerRequest struct {
 	Password string `json:"password"`
 }
 
+type UpdateCustomerProfileRequest struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Phone string `json:"phone"`
+}
+
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password"`
+	NewPassword string `json:"new_password"`
+}
+
+type ForgotPasswordRequest struct {
+	Phone string `json:"phone"`
+}
+
+type ResetPasswordRequest struct {
+	Phone       string `json:"phone"`
+	OTP         string `json:"otp"`
+	NewPassword string `json:"new_password"`
+}
+
 type AuthResponse struct {
 	Customer interface{} `json:"customer"`
 	Token    string      `json:"token"`
@@ -42,11 +65,12 @@ type AuthResponse struct {
 // @Param request body RegisterCustomerRequest true "Customer registration details"
 // @Success 201 {object} AuthResponse "Customer registered successfully"
 // @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 409 {object} ErrorResponse "Email or phone already used by another customer"
 // @Router /customers/register [post]
 func (h *CustomerHandler) Register(c echo.Context) error {
 	ctx := c.Request().Context()
 	var req RegisterCustomerRequest
-	if err := c.Bind(&req); err != nil {
+	if err := bindStrict(c, &req); err != nil {
 		logger.Error(ctx, err)
 		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
 	}
@@ -54,6 +78,9 @@ func (h *CustomerHandler) Register(c echo.Context) error {
 	customer, token, err := h.service.Register(ctx, req.Name, req.Email, req.Phone, req.Password)
 	if err != nil {
 		logger.Error(ctx, err)
+		if errors.Is(err, service.ErrCustomerEmailTaken) || errors.Is(err, service.ErrCustomerTaken) {
+			return c.JSON(http.StatusConflict, ErrorResponse{Error: err.Error()})
+		}
 		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
 	}
 
@@ -77,7 +104,7 @@ func (h *CustomerHandler) Register(c echo.Context) error {
 func (h *CustomerHandler) Login(c echo.Context) error {
 	ctx := c.Request().Context()
 	var req LoginCustomerRequest
-	if err := c.Bind(&req); err != nil {
+	if err := bindStrict(c, &req); err != nil {
 		logger.Error(ctx, err)
 		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
 	}
@@ -93,3 +120,228 @@ func (h *CustomerHandler) Login(c echo.Context) error {
 		Token:    token,
 	})
 }
+
+// GetProfile handles returning the authenticated customer's profile
+// @Summary Get customer profile
+// @Description Get the profile of the authenticated customer
+// @Tags Customers
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} domain.Customer "Customer profile"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - customer role required"
+// @Failure 404 {object} ErrorResponse "Customer not found"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /customers/profile [get]
+// @Router /customers/account [get]
+func (h *CustomerHandler) GetProfile(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	customerID, ok := middleware.GetUserIDFromEcho(c)
+	if !ok {
+		logger.Error(ctx, errors.New("missing customer ID in context"))
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing customer ID in context"})
+	}
+
+	customer, err := h.service.GetProfile(ctx, customerID)
+	if err != nil {
+		logger.Error(ctx, err)
+		if errors.Is(err, service.ErrCustomerNotFound) {
+			return c.JSON(http.StatusNotFound, ErrorResponse{Error: err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, customer)
+}
+
+// UpdateProfile handles editing the authenticated customer's profile
+// @Summary Update customer profile
+// @Description Change the name, email or phone of the authenticated customer. Empty fields keep their current value
+// @Tags Customers
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body UpdateCustomerProfileRequest true "Fields to change"
+// @Success 200 {object} domain.Customer "Updated profile"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - customer role required"
+// @Failure 404 {object} ErrorResponse "Customer not found"
+// @Failure 409 {object} ErrorResponse "Email or phone already used by another customer"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /customers/profile [put]
+func (h *CustomerHandler) UpdateProfile(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	customerID, ok := middleware.GetUserIDFromEcho(c)
+	if !ok {
+		logger.Error(ctx, errors.New("missing customer ID in context"))
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing customer ID in context"})
+	}
+
+	var req UpdateCustomerProfileRequest
+	if err := c.Bind(&req); err != nil {
+		logger.Error(ctx, err)
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+	}
+
+	customer, err := h.service.UpdateProfile(ctx, customerID, req.Name, req.Email, req.Phone)
+	if err != nil {
+		logger.Error(ctx, err)
+		if errors.Is(err, service.ErrCustomerNotFound) {
+			return c.JSON(http.StatusNotFound, ErrorResponse{Error: err.Error()})
+		}
+		if errors.Is(err, service.ErrCustomerEmailTaken) || errors.Is(err, service.ErrCustomerPhoneTaken) || errors.Is(err, service.ErrCustomerTaken) {
+			return c.JSON(http.StatusConflict, ErrorResponse{Error: err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, customer)
+}
+
+// ChangePassword handles changing the authenticated customer's password
+// @Summary Change customer password
+// @Description Replace the password of the authenticated customer after verifying the current one
+// @Tags Customers
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body ChangePasswordRequest true "Current and new password"
+// @Success 200 {object} MessageResponse "Password changed"
+// @Failure 400 {object} ErrorResponse "Invalid request or incorrect current password"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - customer role required"
+// @Failure 404 {object} ErrorResponse "Customer not found"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /customers/change-password [post]
+func (h *CustomerHandler) ChangePassword(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	customerID, ok := middleware.GetUserIDFromEcho(c)
+	if !ok {
+		logger.Error(ctx, errors.New("missing customer ID in context"))
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing customer ID in context"})
+	}
+
+	var req ChangePasswordRequest
+	if err := bindStrict(c, &req); err != nil {
+		logger.Error(ctx, err)
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+	}
+
+	if err := h.service.ChangePassword(ctx, customerID, req.OldPassword, req.NewPassword); err != nil {
+		logger.Error(ctx, err)
+		if errors.Is(err, service.ErrPasswordRequired) || errors.Is(err, service.ErrPasswordUnchanged) || errors.Is(err, service.ErrInvalidCurrentPassword) {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		}
+		if errors.Is(err, service.ErrCustomerNotFound) {
+			return c.JSON(http.StatusNotFound, ErrorResponse{Error: err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, MessageResponse{Message: "Password changed successfully"})
+}
+
+// ForgotPassword handles requesting a password reset OTP
+// @Summary Request password reset OTP
+// @Description Send a password reset OTP to the customer's phone. The response is the same whether or not the phone is registered
+// @Tags Customers
+// @Accept json
+// @Produce json
+// @Param request body ForgotPasswordRequest true "Registered phone number"
+// @Success 200 {object} MessageResponse "OTP sent if the phone is registered"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 429 {object} OTPCooldownResponse "OTP requested again too soon"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Failure 502 {object} ErrorResponse "OTP could not be sent"
+// @Router /customers/forgot-password [post]
+func (h *CustomerHandler) ForgotPassword(c echo.Context) error {
+	ctx := c.Request().Context()
+	var req ForgotPasswordRequest
+	if err := bindStrict(c, &req); err != nil {
+		logger.Error(ctx, err)
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+	}
+
+	if req.Phone == "" {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "phone is required"})
+	}
+
+	if err := h.service.ForgotPassword(ctx, req.Phone); err != nil {
+		logger.Error(ctx, err)
+		var cooldownErr *service.OTPCooldownError
+		if errors.As(err, &cooldownErr) {
+			return sendOTPCooldown(c, cooldownErr)
+		}
+		if errors.Is(err, service.ErrOTPDeliveryFailed) {
+			return c.JSON(http.StatusBadGateway, ErrorResponse{Error: service.ErrOTPDeliveryFailed.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, MessageResponse{Message: "If the phone is registered, an OTP has been sent"})
+}
+
+// ResetPassword handles setting a new password with a reset OTP
+// @Summary Reset customer password
+// @Description Verify the password reset OTP and set a new password. Existing sessions are logged out
+// @Tags Customers
+// @Accept json
+// @Produce json
+// @Param request body ResetPasswordRequest true "Phone, OTP and new password"
+// @Success 200 {object} MessageResponse "Password reset"
+// @Failure 400 {object} ErrorResponse "Invalid request or invalid OTP"
+// @Failure 429 {object} ErrorResponse "Too many failed attempts"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /customers/reset-password [post]
+func (h *CustomerHandler) ResetPassword(c echo.Context) error {
+	ctx := c.Request().Context()
+	var req ResetPasswordRequest
+	if err := bindStrict(c, &req); err != nil {
+		logger.Error(ctx, err)
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+	}
+
+	if err := h.service.ResetPassword(ctx, req.Phone, req.OTP, req.NewPassword); err != nil {
+		logger.Error(ctx, err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, MessageResponse{Message: "Password reset successfully"})
+}
+
+// DeleteAccount handles the authenticated customer deleting their account
+// @Summary Delete customer account
+// @Description Delete the authenticated customer's account with their saved locations and device tokens and log them out. Past rides are kept
+// @Tags Customers
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} MessageResponse "Account deleted"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - customer role required"
+// @Failure 404 {object} ErrorResponse "Customer not found"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /customers/account [delete]
+func (h *CustomerHandler) DeleteAccount(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	customerID, ok := middleware.GetUserIDFromEcho(c)
+	if !ok {
+		logger.Error(ctx, errors.New("missing customer ID in context"))
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing customer ID in context"})
+	}
+
+	if err := h.service.DeleteAccount(ctx, customerID); err != nil {
+		logger.Error(ctx, err)
+		if errors.Is(err, service.ErrCustomerNotFound) {
+			return c.JSON(http.StatusNotFound, ErrorResponse{Error: err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, MessageResponse{Message: "Account deleted successfully"})
+}
@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/service"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/logger"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/middleware"
+)
+
+type DeviceTokenHandler struct {
+	service *service.DeviceTokenService
+}
+
+func NewDeviceTokenHandler(service *service.DeviceTokenService) *DeviceTokenHandler {
+	return &DeviceTokenHandler{service: service}
+}
+
+type DeviceTokenRequest struct {
+	Token string `json:"token" example:"fcm-registration-token"`
+}
+
+// Register handles a customer or driver registering the push notification token of their device
+// @Summary Register a device token
+// @Description Register the push notification token of the authenticated customer's or driver's device to receive ride notifications. Registering a token again moves it to the authenticated user
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body DeviceTokenRequest true "Device token"
+// @Success 201 {object} domain.DeviceToken "Device token registered"
+// @Failure 400 {object} ErrorResponse "Invalid device token"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - customer or driver role required"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /device-tokens [post]
+func (h *DeviceTokenHandler) Register(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	userID, ok := middleware.GetUserIDFromEcho(c)
+	if !ok {
+		logger.Error(ctx, errors.New("missing user ID in context"))
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing user ID in context"})
+	}
+
+	role, ok := middleware.GetUserRoleFromEcho(c)
+	if !ok {
+		logger.Error(ctx, errors.New("missing role in context"))
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing role in context"})
+	}
+
+	var req DeviceTokenRequest
+	if err := c.Bind(&req); err != nil {
+		logger.Error(ctx, err)
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+	}
+
+	deviceToken, err := h.service.Register(ctx, role, userID, req.Token)
+	if err != nil {
+		logger.Error(ctx, err)
+		if errors.Is(err, domain.ErrInvalidDeviceToken) {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		}
+		if errors.Is(err, domain.ErrInvalidUserType) {
+			return c.JSON(http.StatusForbidden, ErrorResponse{Error: err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+
+	return c.JSON(http.StatusCreated, deviceToken)
+}
+
+// Unregister handles a customer or driver removing the push notification token of their device
+// @Summary Unregister a device token
+// @Description Stop push notifications to a device of the authenticated customer or driver, e.g. when logging out of it
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body DeviceTokenRequest true "Device token"
+// @Success 200 {object} MessageResponse "Device token unregistered"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 404 {object} ErrorResponse "Device token not found"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /device-tokens [delete]
+func (h *DeviceTokenHandler) Unregister(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	userID, ok := middleware.GetUserIDFromEcho(c)
+	if !ok {
+		logger.Error(ctx, errors.New("missing user ID in context"))
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing user ID in context"})
+	}
+
+	role, ok := middleware.GetUserRoleFromEcho(c)
+	if !ok {
+		logger.Error(ctx, errors.New("missing role in context"))
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing role in context"})
+	}
+
+	var req DeviceTokenRequest
+	if err := c.Bind(&req); err != nil {
+		logger.Error(ctx, err)
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+	}
+
+	if err := h.service.Unregister(ctx, role, userID, req.Token); err != nil {
+		logger.Error(ctx, err)
+		if errors.Is(err, service.ErrDeviceTokenNotFound) {
+			return c.JSON(http.StatusNotFound, ErrorResponse{Error: err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, MessageResponse{Message: "Device token unregistered successfully"})
+}
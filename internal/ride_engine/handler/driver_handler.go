@@ -2,14 +2,21 @@ package handler
 
 import (
 	"errors"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 	"vcs.technonext.com/carrybee/ride_engine/pkg/logger"
 
 	"github.com/labstack/echo/v4"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
 	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/service"
 	"vcs.technonext.com/carrybee/ride_engine/pkg/middleware"
 )
 
+// dateLayout is the format of date query parameters
+const dateLayout = "2006-01-02"
+
 type DriverHandler struct {
 	service *service.DriverService
 }
@@ -19,9 +26,10 @@ func NewDriverHandler(service *service.DriverService) *DriverHandler {
 }
 
 type RegisterDriverRequest struct {
-	Name      string `json:"name"`
-	Phone     string `json:"phone"`
-	VehicleNo string `json:"vehicle_no"`
+	Name        string `json:"name"`
+	Phone       string `json:"phone"`
+	VehicleNo   string `json:"vehicle_no"`
+	VehicleType string `json:"vehicle_type" enums:"economy,premium,bike"` // defaults to economy
 }
 
 type RequestOTPRequest struct {
@@ -38,38 +46,66 @@ type UpdateLocationRequest struct {
 	Longitude float64 `json:"longitude"`
 }
 
+// LocationBatchPoint is one buffered location in a batch update
+type LocationBatchPoint struct {
+	Latitude  float64   `json:"lat"`
+	Longitude float64   `json:"lng"`
+	Timestamp time.Time `json:"timestamp" example:"2025-01-01T10:00:00Z"`
+}
+
+// UpdateDriverProfileRequest holds the editable profile fields
+// Phone is only declared to reject attempts to change it
+type UpdateDriverProfileRequest struct {
+	Name      string  `json:"name"`
+	VehicleNo string  `json:"vehicle_no" example:"DHAKA METRO-GA-12-3456"`
+	Phone     *string `json:"phone,omitempty" swaggerignore:"true"`
+}
+
 type SetOnlineStatusRequest struct {
 	IsOnline bool `json:"is_online"`
 }
 
 type FindNearestDriversRequest struct {
-	Latitude  float64 `json:"latitude" validate:"required"`
-	Longitude float64 `json:"longitude" validate:"required"`
-	Radius    float64 `json:"radius"`
-	Limit     int     `json:"limit"`
+	Latitude  float64 `json:"latitude" validate:"required,min=-90,max=90"`
+	Longitude float64 `json:"longitude" validate:"required,min=-180,max=180"`
+	Radius    float64 `json:"radius" validate:"min=0"` // in meters, default LOCATION_DRIVER_SEARCH_RADIUS_METERS, capped at LOCATION_MAX_SEARCH_RADIUS_METERS
+	Limit     int     `json:"limit" validate:"min=0"`  // default LOCATION_DRIVER_SEARCH_LIMIT
+	// WithDistance returns each driver's distance and last location update instead of only their IDs
+	WithDistance bool `json:"with_distance"`
+}
+
+// NearestDriverResponse is a nearby driver with their distance from the searched point
+type NearestDriverResponse struct {
+	DriverID       int64     `json:"driver_id"`
+	DistanceMeters float64   `json:"distance_meters"`
+	UpdatedAt      time.Time `json:"updated_at"` // when the driver's location was last updated
 }
 
 // Register handles driver registration
 // @Summary Register a new driver
-// @Description Register a new driver with name, phone, and vehicle number
+// @Description Register a new driver with name, phone, vehicle number and vehicle type
 // @Tags Drivers
 // @Accept json
 // @Produce json
 // @Param request body RegisterDriverRequest true "Driver registration details"
 // @Success 201 {object} map[string]interface{} "Driver registered successfully"
 // @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 409 {object} ErrorResponse "A driver with this phone already exists"
 // @Router /drivers/register [post]
 func (h *DriverHandler) Register(c echo.Context) error {
 	ctx := c.Request().Context()
 	var req RegisterDriverRequest
-	if err := c.Bind(&req); err != nil {
+	if err := bindStrict(c, &req); err != nil {
 		logger.Error(ctx, err)
 		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
 	}
 
-	driver, err := h.service.Register(ctx, req.Name, req.Phone, req.VehicleNo)
+	driver, err := h.service.Register(ctx, req.Name, req.Phone, req.VehicleNo, domain.RideType(req.VehicleType))
 	if err != nil {
 		logger.Error(ctx, err)
+		if errors.Is(err, service.ErrDriverAlreadyExists) {
+			return c.JSON(http.StatusConflict, ErrorResponse{Error: err.Error()})
+		}
 		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
 	}
 
@@ -85,11 +121,13 @@ func (h *DriverHandler) Register(c echo.Context) error {
 // @Param request body RequestOTPRequest true "Phone number to send OTP"
 // @Success 200 {object} MessageResponse "OTP sent successfully"
 // @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 429 {object} OTPCooldownResponse "OTP requested again too soon"
+// @Failure 502 {object} ErrorResponse "OTP could not be sent"
 // @Router /drivers/login/request-otp [post]
 func (h *DriverHandler) RequestOTP(c echo.Context) error {
 	ctx := c.Request().Context()
 	var req RequestOTPRequest
-	if err := c.Bind(&req); err != nil {
+	if err := bindStrict(c, &req); err != nil {
 		logger.Error(ctx, err)
 		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
 	}
@@ -97,6 +135,13 @@ func (h *DriverHandler) RequestOTP(c echo.Context) error {
 	err := h.service.RequestOTP(ctx, req.Phone)
 	if err != nil {
 		logger.Error(ctx, err)
+		var cooldownErr *service.OTPCooldownError
+		if errors.As(err, &cooldownErr) {
+			return sendOTPCooldown(c, cooldownErr)
+		}
+		if errors.Is(err, service.ErrOTPDeliveryFailed) {
+			return c.JSON(http.StatusBadGateway, ErrorResponse{Error: service.ErrOTPDeliveryFailed.Error()})
+		}
 		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
 	}
 
@@ -113,11 +158,12 @@ func (h *DriverHandler) RequestOTP(c echo.Context) error {
 // @Success 200 {object} AuthResponse "Login successful"
 // @Failure 400 {object} ErrorResponse "Invalid request"
 // @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 429 {object} ErrorResponse "Too many failed attempts"
 // @Router /drivers/login/verify-otp [post]
 func (h *DriverHandler) VerifyOTP(c echo.Context) error {
 	ctx := c.Request().Context()
 	var req VerifyOTPRequest
-	if err := c.Bind(&req); err != nil {
+	if err := bindStrict(c, &req); err != nil {
 		logger.Error(ctx, err)
 		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
 	}
@@ -125,6 +171,9 @@ func (h *DriverHandler) VerifyOTP(c echo.Context) error {
 	driver, token, err := h.service.VerifyOTP(ctx, req.Phone, req.OTP)
 	if err != nil {
 		logger.Error(ctx, err)
+		if errors.Is(err, service.ErrTooManyOTPAttempts) {
+			return c.JSON(http.StatusTooManyRequests, ErrorResponse{Error: err.Error()})
+		}
 		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: err.Error()})
 	}
 
@@ -145,6 +194,7 @@ func (h *DriverHandler) VerifyOTP(c echo.Context) error {
 // @Success 200 {object} MessageResponse "Location updated successfully"
 // @Failure 400 {object} ErrorResponse "Invalid request"
 // @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - driver role required"
 // @Failure 500 {object} ErrorResponse "Internal server error"
 // @Router /drivers/location [post]
 func (h *DriverHandler) UpdateLocation(c echo.Context) error {
@@ -155,93 +205,330 @@ func (h *DriverHandler) UpdateLocation(c echo.Context) error {
 		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing driver ID in context"})
 	}
 
+	var req UpdateLocationRequest
+	if err := c.Bind(&req); err != nil {
+		logger.Error(ctx, err)
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+	}
+
+	err := h.service.UpdateLocation(ctx, driverID, req.Latitude, req.Longitude)
+	if err != nil {
+		logger.Error(ctx, err)
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, MessageResponse{Message: "Location updated successfully"})
+}
+
+// UpdateLocationBatch handles flushing locations buffered by the driver's device while offline
+// @Summary Update driver location in batch
+// @Description Store up to 500 buffered locations of the authenticated driver at once. Points with invalid coordinates, a 0,0 fix or a timestamp more than a minute in the future or over a day old are dropped. The newest point becomes the current location unless a newer one is already stored
+// @Tags Drivers
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body []LocationBatchPoint true "Buffered locations in any order"
+// @Success 200 {object} service.LocationBatchResult "Number of points stored and dropped"
+// @Failure 400 {object} ErrorResponse "Invalid request, empty or too large batch, or no valid points"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - driver role required"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /drivers/location/batch [post]
+func (h *DriverHandler) UpdateLocationBatch(c echo.Context) error {
+	ctx := c.Request().Context()
+	driverID, ok := middleware.GetUserIDFromEcho(c)
+	if !ok {
+		logger.Error(ctx, errors.New("missing user id"))
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing driver ID in context"})
+	}
+
+	var req []LocationBatchPoint
+	if err := c.Bind(&req); err != nil {
+		logger.Error(ctx, err)
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+	}
+
+	points := make([]service.LocationPoint, len(req))
+	for i, point := range req {
+		points[i] = service.LocationPoint{
+			Lat:       point.Latitude,
+			Lng:       point.Longitude,
+			Timestamp: point.Timestamp,
+		}
+	}
+
+	result, err := h.service.UpdateLocationBatch(ctx, driverID, points)
+	if err != nil {
+		logger.Error(ctx, err)
+		if errors.Is(err, service.ErrEmptyLocationBatch) || errors.Is(err, service.ErrLocationBatchTooLarge) || errors.Is(err, service.ErrNoValidLocations) {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
+
+// UpdateProfile handles editing the authenticated driver's profile
+// @Summary Update driver profile
+// @Description Change the driver's name and vehicle number. The vehicle number is upper-cased and must be 3 to 32 letters, digits, spaces or hyphens. The phone number cannot be changed here
+// @Tags Drivers
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body UpdateDriverProfileRequest true "New profile"
+// @Success 200 {object} domain.Driver "Updated driver"
+// @Failure 400 {object} ErrorResponse "Invalid name or vehicle number, or phone change attempted"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - driver role required"
+// @Failure 404 {object} ErrorResponse "Driver not found"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /drivers/profile [put]
+func (h *DriverHandler) UpdateProfile(c echo.Context) error {
+	ctx := c.Request().Context()
+	driverID, ok := middleware.GetUserIDFromEcho(c)
+	if !ok {
+		logger.Error(ctx, errors.New("missing user id"))
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing driver ID in context"})
+	}
+
+	var req UpdateDriverProfileRequest
+	if err := c.Bind(&req); err != nil {
+		logger.Error(ctx, err)
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+	}
+	if req.Phone != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "phone cannot be changed"})
+	}
+
+	driver, err := h.service.UpdateProfile(ctx, driverID, req.Name, req.VehicleNo)
+	if err != nil {
+		logger.Error(ctx, err)
+		if errors.Is(err, domain.ErrInvalidDriverName) || errors.Is(err, domain.ErrInvalidVehicleNo) {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		}
+		if errors.Is(err, service.ErrDriverNotFound) {
+			return c.JSON(http.StatusNotFound, ErrorResponse{Error: err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, driver)
+}
+
+// GetLocationTrail handles returning a driver's recent path
+// @Summary Get driver location trail
+// @Description Return the driver's recorded locations over the last N minutes, oldest first. Drivers can view their own trail, customers the trail of the driver assigned to their accepted or started ride
+// @Tags Drivers
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path integer true "Driver ID"
+// @Param minutes query integer false "Window in minutes, default 30, max 1440"
+// @Success 200 {array} service.TrailPoint "Recorded locations"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - not allowed to view this trail"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /drivers/{id}/trail [get]
+func (h *DriverHandler) GetLocationTrail(c echo.Context) error {
+	ctx := c.Request().Context()
+	userID, ok := middleware.GetUserIDFromEcho(c)
+	if !ok {
+		logger.Error(ctx, errors.New("missing user id"))
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing user ID in context"})
+	}
+
 	role, ok := middleware.GetUserRoleFromEcho(c)
 	if !ok {
 		logger.Error(ctx, errors.New("missing user role"))
 		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing role in context"})
 	}
-	if role != "driver" {
-		logger.Error(ctx, errors.New("invalid role"))
-		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "invalid role in context"})
+
+	driverID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		logger.Error(ctx, err)
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid driver id"})
 	}
 
-	var req UpdateLocationRequest
+	minutes := service.DefaultTrailMinutes
+	if minutesStr := c.QueryParam("minutes"); minutesStr != "" {
+		minutes, err = strconv.Atoi(minutesStr)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid minutes"})
+		}
+	}
+
+	trail, err := h.service.GetLocationTrail(ctx, driverID, userID, role, minutes)
+	if err != nil {
+		logger.Error(ctx, err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, trail)
+}
+
+// SetOnlineStatus handles driver online/offline status
+// @Summary Set driver online/offline status
+// @Description Update whether the driver is available to accept rides. Going online requires a recent location ping.
+// @Tags Drivers
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body SetOnlineStatusRequest true "Driver's online status"
+// @Success 200 {object} MessageResponse "Status updated successfully"
+// @Failure 400 {object} ErrorResponse "Invalid request or no recent location ping"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - driver role required"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /drivers/status [post]
+func (h *DriverHandler) SetOnlineStatus(c echo.Context) error {
+	ctx := c.Request().Context()
+	driverID, ok := middleware.GetUserIDFromEcho(c)
+	if !ok {
+		logger.Error(ctx, errors.New("missing user id"))
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing driver ID in context"})
+	}
+
+	var req SetOnlineStatusRequest
 	if err := c.Bind(&req); err != nil {
 		logger.Error(ctx, err)
 		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
 	}
 
-	err := h.service.UpdateLocation(ctx, driverID, req.Latitude, req.Longitude)
+	err := h.service.SetOnlineStatus(ctx, driverID, req.IsOnline)
 	if err != nil {
 		logger.Error(ctx, err)
+		if errors.Is(err, service.ErrLocationPingRequired) {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		}
 		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
 	}
 
-	return c.JSON(http.StatusOK, MessageResponse{Message: "Location updated successfully"})
+	status := "offline"
+	if req.IsOnline {
+		status = "online"
+	}
+	return c.JSON(http.StatusOK, MessageResponse{Message: "Driver is now " + status})
+}
+
+// GetEarnings handles the driver's earnings summary
+// @Summary Get driver earnings
+// @Description Sum the fares of the driver's completed rides in a date range, with a per-ride breakdown. Dates are inclusive; the range defaults to the last 30 days
+// @Tags Drivers
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param from query string false "Start date (YYYY-MM-DD)"
+// @Param to query string false "End date (YYYY-MM-DD), inclusive"
+// @Success 200 {object} service.DriverEarnings "Earnings summary"
+// @Failure 400 {object} ErrorResponse "Invalid date range"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - driver role required"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /drivers/earnings [get]
+func (h *DriverHandler) GetEarnings(c echo.Context) error {
+	ctx := c.Request().Context()
+	driverID, ok := middleware.GetUserIDFromEcho(c)
+	if !ok {
+		logger.Error(ctx, errors.New("missing user id"))
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing driver ID in context"})
+	}
+
+	// to is inclusive, so the range ends at the start of the following day
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	to := today.AddDate(0, 0, 1)
+	if toStr := c.QueryParam("to"); toStr != "" {
+		parsed, err := time.Parse(dateLayout, toStr)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid to date, expected YYYY-MM-DD"})
+		}
+		to = parsed.AddDate(0, 0, 1)
+	}
+
+	from := to.AddDate(0, 0, -30)
+	if fromStr := c.QueryParam("from"); fromStr != "" {
+		parsed, err := time.Parse(dateLayout, fromStr)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid from date, expected YYYY-MM-DD"})
+		}
+		from = parsed
+	}
+
+	earnings, err := h.service.GetEarnings(ctx, driverID, from, to)
+	if err != nil {
+		logger.Error(ctx, err)
+		if errors.Is(err, service.ErrInvalidDateRange) {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, earnings)
 }
 
-//
-//// SetOnlineStatus handles driver online/offline status
-//// @Summary Set driver online/offline status
-//// @Description Update whether the driver is available to accept rides
-//// @Tags Drivers
-//// @Accept json
-//// @Produce json
-//// @Security BearerAuth
-//// @Param request body SetOnlineStatusRequest true "Driver's online status"
-//// @Success 200 {object} MessageResponse "Status updated successfully"
-//// @Failure 400 {object} ErrorResponse "Invalid request"
-//// @Failure 401 {object} ErrorResponse "Unauthorized"
-//// @Failure 500 {object} ErrorResponse "Internal server error"
-//// @Router /drivers/status [post]
-//func (h *DriverHandler) SetOnlineStatus(c echo.Context) error {
-//	ctx := c.Request().Context()
-//	driverID, ok := middleware.GetUserIDFromEcho(c)
-//	if !ok {
-//		logger.Error(ctx, errors.New("missing user id"))
-//		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing driver ID in context"})
-//	}
-//	fmt.Println("Driver ID from context:", driverID)
-//
-//	role, ok := middleware.GetUserRoleFromEcho(c)
-//	if !ok {
-//		logger.Error(ctx, errors.New("missing user role"))
-//		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing role in context"})
-//	}
-//	if role != "driver" {
-//		logger.Error(ctx, errors.New("invalid role"))
-//		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "invalid role in context"})
-//	}
-//
-//	var req SetOnlineStatusRequest
-//	if err := c.Bind(&req); err != nil {
-//		logger.Error(ctx, err)
-//		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
-//	}
-//
-//	err := h.service.SetOnlineStatus(ctx, driverID, req.IsOnline)
-//	if err != nil {
-//		logger.Error(ctx, err)
-//		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
-//	}
-//
-//	status := "offline"
-//	if req.IsOnline {
-//		status = "online"
-//	}
-//	return c.JSON(http.StatusOK, MessageResponse{Message: "Driver is now " + status})
-//}
+// GetSessions handles the driver's shift summary
+// @Summary Get driver online sessions
+// @Description List the driver's completed online sessions in a date range with the total time online and the time online on each UTC day. Dates are inclusive; the range defaults to the last 30 days
+// @Tags Drivers
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param from query string false "Start date (YYYY-MM-DD)"
+// @Param to query string false "End date (YYYY-MM-DD), inclusive"
+// @Success 200 {object} service.DriverShiftSummary "Shift summary"
+// @Failure 400 {object} ErrorResponse "Invalid date range"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - driver role required"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /drivers/sessions [get]
+func (h *DriverHandler) GetSessions(c echo.Context) error {
+	ctx := c.Request().Context()
+	driverID, ok := middleware.GetUserIDFromEcho(c)
+	if !ok {
+		logger.Error(ctx, errors.New("missing user id"))
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing driver ID in context"})
+	}
+
+	// to is inclusive, so the range ends at the start of the following day
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	to := today.AddDate(0, 0, 1)
+	if toStr := c.QueryParam("to"); toStr != "" {
+		parsed, err := time.Parse(dateLayout, toStr)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid to date, expected YYYY-MM-DD"})
+		}
+		to = parsed.AddDate(0, 0, 1)
+	}
+
+	from := to.AddDate(0, 0, -30)
+	if fromStr := c.QueryParam("from"); fromStr != "" {
+		parsed, err := time.Parse(dateLayout, fromStr)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid from date, expected YYYY-MM-DD"})
+		}
+		from = parsed
+	}
+
+	summary, err := h.service.GetShiftSummary(ctx, driverID, from, to)
+	if err != nil {
+		logger.Error(ctx, err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, summary)
+}
 
 // FindNearestDrivers finds nearest available drivers
 // @Summary Find nearest drivers
-// @Description Find nearest available drivers within a specified radius
+// @Description Find nearest available drivers within a specified radius, nearest first, drivers on an accepted or started ride are left out, a radius above the configured maximum search radius (default 50 km) is reduced to it. With with_distance set each driver is returned with their distance in meters and last location update instead of only their ID
 // @Tags Drivers
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param request body FindNearestDriversRequest true "Search parameters for nearest drivers"
 // @Success 200 {object} map[string]interface{} "List of nearest drivers"
-// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 400 {object} ValidationErrorResponse "Invalid request"
 // @Failure 500 {object} ErrorResponse "Internal server error"
 // @Router /drivers/nearby [post]
 func (h *DriverHandler) FindNearestDrivers(c echo.Context) error {
@@ -252,35 +539,34 @@ func (h *DriverHandler) FindNearestDrivers(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid request body"})
 	}
 
-	// Validate required fields
-	if req.Latitude == 0 && req.Longitude == 0 {
-		logger.Error(ctx, errors.New("missing latitude and longitude"))
-		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "latitude and longitude are required"})
-	}
-
-	// Validate coordinate ranges
-	if req.Latitude < -90 || req.Latitude > 90 {
-		logger.Error(ctx, errors.New("invalid latitude"))
-		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "latitude must be between -90 and 90"})
-	}
-
-	if req.Longitude < -180 || req.Longitude > 180 {
-		logger.Error(ctx, errors.New("invalid longitude"))
-		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "longitude must be between -180 and 180"})
-	}
-
-	// Set default values
-	radius := 3000.0
-	if req.Radius > 0 {
-		radius = req.Radius
+	if err := c.Validate(&req); err != nil {
+		logger.Error(ctx, err)
+		return c.JSON(http.StatusBadRequest, NewValidationErrorResponse(err))
 	}
 
-	limit := 5
-	if req.Limit > 0 {
-		limit = req.Limit
+	if req.WithDistance {
+		nearest, err := h.service.GetNearestDriversWithDistance(ctx, req.Latitude, req.Longitude, req.Radius, req.Limit)
+		if err != nil {
+			logger.Error(ctx, err)
+			return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		}
+
+		drivers := make([]NearestDriverResponse, 0, len(nearest))
+		for _, driver := range nearest {
+			drivers = append(drivers, NearestDriverResponse{
+				DriverID:       driver.DriverID,
+				DistanceMeters: math.Round(driver.DistanceMeters),
+				UpdatedAt:      driver.UpdatedAt,
+			})
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"drivers": drivers,
+			"count":   len(drivers),
+		})
 	}
 
-	driverIDs, err := h.service.GetNearestDrivers(ctx, req.Latitude, req.Longitude, radius, limit)
+	driverIDs, err := h.service.GetNearestDrivers(ctx, req.Latitude, req.Longitude, req.Radius, req.Limit)
 	if err != nil {
 		logger.Error(ctx, err)
 		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/logger"
+)
+
+// errorKindStatuses maps the domain error kinds to the status they are answered with
+var errorKindStatuses = []struct {
+	kind   error
+	status int
+}{
+	{domain.ErrNotFound, http.StatusNotFound},
+	{domain.ErrForbidden, http.StatusForbidden},
+	{domain.ErrConflict, http.StatusConflict},
+	{domain.ErrValidation, http.StatusBadRequest},
+	{domain.ErrUnprocessable, http.StatusUnprocessableEntity},
+	{domain.ErrTooManyRequests, http.StatusTooManyRequests},
+}
+
+// ErrorStatus returns the HTTP status for an error returned by a service
+// Errors of a domain error kind get the kind's status, echo errors keep theirs and any other error is a 500
+func ErrorStatus(err error) int {
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Code
+	}
+	for _, m := range errorKindStatuses {
+		if errors.Is(err, m.kind) {
+			return m.status
+		}
+	}
+	return http.StatusInternalServerError
+}
+
+// HTTPErrorHandler is the echo error handler, it answers errors returned by handlers with
+// an ErrorResponse at the status ErrorStatus picks for them
+// Handlers return service errors as they are and leave the status to it
+func HTTPErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	status := ErrorStatus(err)
+	message := err.Error()
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		message = fmt.Sprint(httpErr.Message)
+	}
+
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(status)
+	} else {
+		err = c.JSON(status, ErrorResponse{Error: message})
+	}
+	if err != nil {
+		logger.Error(c.Request().Context(), fmt.Sprintf("Failed to send error response: %v", err))
+	}
+}
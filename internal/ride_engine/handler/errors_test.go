@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/service"
+)
+
+func TestErrorStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"not found", service.ErrRideNotFound, http.StatusNotFound},
+		{"forbidden", service.ErrRideForbidden, http.StatusForbidden},
+		{"conflict", service.ErrRideAlreadyAccepted, http.StatusConflict},
+		{"validation", domain.ErrInvalidRideType, http.StatusBadRequest},
+		{"unprocessable", service.ErrOutsideServiceArea, http.StatusUnprocessableEntity},
+		{"too many requests", service.ErrTooManyOTPAttempts, http.StatusTooManyRequests},
+		{"otp cooldown", &service.OTPCooldownError{Remaining: time.Minute}, http.StatusTooManyRequests},
+		{"wrapped kind", fmt.Errorf("get ride 7: %w", service.ErrRideNotFound), http.StatusNotFound},
+		{"echo error", echo.NewHTTPError(http.StatusMethodNotAllowed, "method not allowed"), http.StatusMethodNotAllowed},
+		{"untyped", errors.New("connection refused"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ErrorStatus(tt.err))
+		})
+	}
+}
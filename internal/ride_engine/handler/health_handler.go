@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/logger"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	healthStatusOK   = "ok"
+	healthStatusDown = "down"
+)
+
+// HealthChecker is a dependency that can report whether it is reachable
+type HealthChecker interface {
+	HealthCheck(ctx context.Context) error
+}
+
+// DependencyHealth is the result of checking a single dependency
+type DependencyHealth struct {
+	Status string `json:"status" example:"ok"`
+	Error  string `json:"error,omitempty" example:"context deadline exceeded"`
+}
+
+// HealthResponse represents the health of the server and its dependencies
+type HealthResponse struct {
+	Status       string                      `json:"status" example:"ok"`
+	Dependencies map[string]DependencyHealth `json:"dependencies"`
+}
+
+type HealthHandler struct {
+	checkers map[string]HealthChecker
+	timeout  time.Duration
+}
+
+// NewHealthHandler checks every dependency in checkers, keyed by the name reported in the response
+// Each check gets its own timeout so a hung dependency cannot block the endpoint
+func NewHealthHandler(checkers map[string]HealthChecker, timeout time.Duration) *HealthHandler {
+	return &HealthHandler{checkers: checkers, timeout: timeout}
+}
+
+// Health handles the deep health check
+// @Summary Health check
+// @Description Check connectivity to PostgreSQL, MongoDB and Redis
+// @Tags Health
+// @Produce json
+// @Success 200 {object} HealthResponse "All dependencies are healthy"
+// @Failure 503 {object} HealthResponse "At least one dependency is unhealthy"
+// @Router /health [get]
+func (h *HealthHandler) Health(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	resp := HealthResponse{Status: healthStatusOK, Dependencies: make(map[string]DependencyHealth, len(h.checkers))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, checker := range h.checkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := h.check(ctx, checker)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				logger.Error(ctx, fmt.Sprintf("health check failed for %s: %v", name, err))
+				resp.Status = healthStatusDown
+				resp.Dependencies[name] = DependencyHealth{Status: healthStatusDown, Error: err.Error()}
+				return
+			}
+			resp.Dependencies[name] = DependencyHealth{Status: healthStatusOK}
+		}()
+	}
+	wg.Wait()
+
+	if resp.Status != healthStatusOK {
+		return c.JSON(http.StatusServiceUnavailable, resp)
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// check runs one health check, giving up once the timeout expires even if the checker ignores ctx
+func (h *HealthHandler) check(ctx context.Context, checker HealthChecker) error {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	result := make(chan error, 1)
+	go func() {
+		result <- checker.HealthCheck(ctx)
+	}()
+
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
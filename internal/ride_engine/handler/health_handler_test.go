@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeHealthChecker struct {
+	err   error
+	block bool
+}
+
+func (f fakeHealthChecker) HealthCheck(ctx context.Context) error {
+	if f.block {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return f.err
+}
+
+func serveHealth(t *testing.T, h *HealthHandler) (int, HealthResponse) {
+	t.Helper()
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	rec := httptest.NewRecorder()
+	require.NoError(t, h.Health(e.NewContext(req, rec)))
+
+	var resp HealthResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	return rec.Code, resp
+}
+
+func TestHealthHandler_AllHealthy(t *testing.T) {
+	h := NewHealthHandler(map[string]HealthChecker{
+		"postgres": fakeHealthChecker{},
+		"mongodb":  fakeHealthChecker{},
+		"redis":    fakeHealthChecker{},
+	}, time.Second)
+
+	code, resp := serveHealth(t, h)
+
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ok", resp.Status)
+	assert.Len(t, resp.Dependencies, 3)
+	for name, dep := range resp.Dependencies {
+		assert.Equal(t, "ok", dep.Status, name)
+		assert.Empty(t, dep.Error, name)
+	}
+}
+
+func TestHealthHandler_FailingDependency(t *testing.T) {
+	h := NewHealthHandler(map[string]HealthChecker{
+		"postgres": fakeHealthChecker{},
+		"mongodb":  fakeHealthChecker{err: errors.New("connection refused")},
+		"redis":    fakeHealthChecker{},
+	}, time.Second)
+
+	code, resp := serveHealth(t, h)
+
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "down", resp.Status)
+	assert.Equal(t, DependencyHealth{Status: "down", Error: "connection refused"}, resp.Dependencies["mongodb"])
+	assert.Equal(t, "ok", resp.Dependencies["postgres"].Status)
+	assert.Equal(t, "ok", resp.Dependencies["redis"].Status)
+}
+
+func TestHealthHandler_HungDependencyTimesOut(t *testing.T) {
+	h := NewHealthHandler(map[string]HealthChecker{
+		"postgres": fakeHealthChecker{},
+		"redis":    fakeHealthChecker{block: true},
+	}, 50*time.Millisecond)
+
+	start := time.Now()
+	code, resp := serveHealth(t, h)
+
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "down", resp.Dependencies["redis"].Status)
+	assert.Equal(t, context.DeadlineExceeded.Error(), resp.Dependencies["redis"].Error)
+	assert.Equal(t, "ok", resp.Dependencies["postgres"].Status)
+}
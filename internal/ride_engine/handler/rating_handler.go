@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/logger"
+
+	"github.com/labstack/echo/v4"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/service"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/middleware"
+)
+
+type RatingHandler struct {
+	service *service.RatingService
+}
+
+func NewRatingHandler(service *service.RatingService) *RatingHandler {
+	return &RatingHandler{service: service}
+}
+
+type RateRideRequest struct {
+	RideID  int64  `json:"ride_id"`
+	Stars   int    `json:"stars"`
+	Comment string `json:"comment"`
+}
+
+// RateRide handles a customer or driver rating a completed ride
+// @Summary Rate a ride
+// @Description Rate the other participant of a completed ride with 1 to 5 stars. Customers rate the driver, drivers rate the customer
+// @Tags Rides
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body RateRideRequest true "Rating details"
+// @Success 201 {object} domain.Rating "Rating saved"
+// @Failure 400 {object} ErrorResponse "Invalid stars or comment, or ride not completed"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - you did not take part in this ride"
+// @Failure 404 {object} ErrorResponse "Ride not found"
+// @Failure 409 {object} ErrorResponse "Ride already rated"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /rides/rate [post]
+func (h *RatingHandler) RateRide(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	userID, ok := middleware.GetUserIDFromEcho(c)
+	if !ok {
+		logger.Error(ctx, errors.New("missing user ID in context"))
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing user ID in context"})
+	}
+
+	role, ok := middleware.GetUserRoleFromEcho(c)
+	if !ok {
+		logger.Error(ctx, errors.New("missing role in context"))
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing role in context"})
+	}
+
+	var req RateRideRequest
+	if err := c.Bind(&req); err != nil {
+		logger.Error(ctx, err)
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid request body"})
+	}
+	if req.RideID == 0 {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "ride_id is required"})
+	}
+
+	rating, err := h.service.RateRide(ctx, req.RideID, userID, role, req.Stars, req.Comment)
+	if err != nil {
+		logger.Error(ctx, err)
+		switch {
+		case errors.Is(err, domain.ErrInvalidRatingStars),
+			errors.Is(err, domain.ErrInvalidRatingComment),
+			errors.Is(err, service.ErrRideNotCompleted):
+			return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		case errors.Is(err, service.ErrRideNotFound):
+			return c.JSON(http.StatusNotFound, ErrorResponse{Error: err.Error()})
+		case errors.Is(err, service.ErrNotRideParticipant):
+			return c.JSON(http.StatusForbidden, ErrorResponse{Error: err.Error()})
+		case errors.Is(err, service.ErrRideAlreadyRated):
+			return c.JSON(http.StatusConflict, ErrorResponse{Error: err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+
+	return c.JSON(http.StatusCreated, rating)
+}
@@ -3,6 +3,10 @@ package handler
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/service"
 )
 
 // ErrorResponse represents an error response
@@ -15,6 +19,21 @@ type MessageResponse struct {
 	Message string `json:"message" example:"Operation completed successfully"`
 }
 
+// OTPCooldownResponse represents an OTP request made before the resend cooldown is over
+type OTPCooldownResponse struct {
+	Error             string `json:"error" example:"please wait before requesting another OTP, try again in 30 seconds"`
+	RetryAfterSeconds int    `json:"retry_after_seconds" example:"30"`
+}
+
+// sendOTPCooldown replies 429 with the remaining wait in the body and the Retry-After header
+func sendOTPCooldown(c echo.Context, err *service.OTPCooldownError) error {
+	c.Response().Header().Set("Retry-After", strconv.Itoa(err.RemainingSeconds()))
+	return c.JSON(http.StatusTooManyRequests, OTPCooldownResponse{
+		Error:             err.Error(),
+		RetryAfterSeconds: err.RemainingSeconds(),
+	})
+}
+
 func SendJSON(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -1,43 +1,66 @@
 package handler
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 	"vcs.technonext.com/carrybee/ride_engine/pkg/logger"
 
 	"github.com/labstack/echo/v4"
+	"golang.org/x/net/websocket"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
 	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/service"
 	"vcs.technonext.com/carrybee/ride_engine/pkg/middleware"
 )
 
 type RideHandler struct {
-	service *service.RideService
+	service         *service.RideService
+	trackingService *service.TrackingService
 }
 
-func NewRideHandler(service *service.RideService) *RideHandler {
-	return &RideHandler{service: service}
+func NewRideHandler(service *service.RideService, trackingService *service.TrackingService) *RideHandler {
+	return &RideHandler{
+		service:         service,
+		trackingService: trackingService,
+	}
 }
 
+// IdempotencyKeyHeader lets clients retry POST /rides without creating duplicate rides
+const IdempotencyKeyHeader = "Idempotency-Key"
+
 type RequestRideRequest struct {
-	PickupLat  float64 `json:"pickup_lat"`
-	PickupLng  float64 `json:"pickup_lng"`
-	DropoffLat float64 `json:"dropoff_lat"`
-	DropoffLng float64 `json:"dropoff_lng"`
+	PickupLat  float64           `json:"pickup_lat"`
+	PickupLng  float64           `json:"pickup_lng"`
+	DropoffLat float64           `json:"dropoff_lat"`
+	DropoffLng float64           `json:"dropoff_lng"`
+	RideType   string            `json:"ride_type" enums:"economy,premium,bike"` // defaults to economy
+	Waypoints  []domain.Location `json:"waypoints"`                              // optional stops between pickup and dropoff, in order
+	// PickupSavedLocationID picks the customer up at one of their saved locations instead of pickup_lat/pickup_lng
+	PickupSavedLocationID *int64 `json:"pickup_saved_location_id,omitempty"`
+	// PromoCode is discounted from the final fare when the ride completes
+	PromoCode string `json:"promo_code,omitempty"`
 }
 
 // RequestRide handles customer ride requests
 // @Summary Request a new ride
-// @Description Create a new ride request with pickup and dropoff locations
+// @Description Create a new ride request with pickup and dropoff locations and optional waypoints visited in order. Set pickup_saved_location_id to pick up at a saved location and promo_code to discount the final fare
 // @Tags Rides
 // @Accept json
 // @Produce json
 // @Security BearerAuth
+// @Param Idempotency-Key header string false "Retries with the same key return the ride created by the first request"
 // @Param request body RequestRideRequest true "Ride request details"
 // @Success 201 {object} map[string]interface{} "Ride created successfully"
-// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 400 {object} ErrorResponse "Invalid ride type, coordinates out of range, pickup equal to dropoff, invalid waypoints, unknown promo code or idempotency key too long"
 // @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - customer role required"
+// @Failure 404 {object} ErrorResponse "Saved location not found"
+// @Failure 409 {object} ErrorResponse "Customer already has an active ride, or a request with the same idempotency key is still in progress"
+// @Failure 422 {object} ErrorResponse "Idempotency key was used by another customer, pickup outside the service area, or promo code expired or used up"
 // @Failure 500 {object} ErrorResponse "Internal server error"
 // @Router /rides [post]
 func (h *RideHandler) RequestRide(c echo.Context) error {
@@ -47,52 +70,97 @@ func (h *RideHandler) RequestRide(c echo.Context) error {
 		logger.Error(ctx, errors.New("no user id from context"))
 		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing customer ID in context"})
 	}
-	fmt.Println("customer ID from context:", customerID)
+	logger.DebugWithContext(ctx, fmt.Sprintf("customer ID from context: %d", customerID))
 
-	role, ok := middleware.GetUserRoleFromEcho(c)
-	if !ok {
-		logger.Error(ctx, errors.New("no user role from context"))
-		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing role in context"})
+	var req RequestRideRequest
+	if err := c.Bind(&req); err != nil {
+		logger.Error(ctx, err)
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
 	}
 
-	if role != "customer" {
-		logger.Error(ctx, errors.New("invalid role"))
-		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "invalid role"})
+	idempotencyKey := c.Request().Header.Get(IdempotencyKeyHeader)
+
+	var ride *domain.Ride
+	var err error
+	if req.PickupSavedLocationID != nil {
+		ride, err = h.service.RequestRideFromSavedLocation(ctx, customerID, *req.PickupSavedLocationID, domain.RideType(req.RideType), req.DropoffLat, req.DropoffLng, req.Waypoints, req.PromoCode, idempotencyKey)
+	} else {
+		ride, err = h.service.RequestRide(ctx, customerID, domain.RideType(req.RideType), req.PickupLat, req.PickupLng, req.DropoffLat, req.DropoffLng, req.Waypoints, req.PromoCode, idempotencyKey)
+	}
+	if err != nil {
+		logger.Error(ctx, err)
+		return err
 	}
 
-	var req RequestRideRequest
+	return c.JSON(http.StatusCreated, ride)
+}
+
+type EstimateFareRequest struct {
+	PickupLat  float64 `json:"pickup_lat"`
+	PickupLng  float64 `json:"pickup_lng"`
+	DropoffLat float64 `json:"dropoff_lat"`
+	DropoffLng float64 `json:"dropoff_lng"`
+	RideType   string  `json:"ride_type" enums:"economy,premium,bike"` // defaults to economy
+}
+
+// EstimateFare handles fare quotes before a ride is requested
+// @Summary Estimate the fare of a ride
+// @Description Quote the distance, duration and fare range from pickup to dropoff at the current surge without creating a ride
+// @Tags Rides
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body EstimateFareRequest true "Route to quote"
+// @Success 200 {object} service.FareEstimate "Fare estimate"
+// @Failure 400 {object} ErrorResponse "Invalid ride type, coordinates out of range or pickup equal to dropoff"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - customer role required"
+// @Failure 422 {object} ErrorResponse "Pickup is outside the service area"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /rides/estimate [post]
+func (h *RideHandler) EstimateFare(c echo.Context) error {
+	ctx := c.Request().Context()
+	customerID, ok := middleware.GetUserIDFromEcho(c)
+	if !ok {
+		logger.Error(ctx, errors.New("no user id from context"))
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing customer ID in context"})
+	}
+
+	var req EstimateFareRequest
 	if err := c.Bind(&req); err != nil {
 		logger.Error(ctx, err)
 		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
 	}
 
-	ride, err := h.service.RequestRide(ctx, customerID, req.PickupLat, req.PickupLng, req.DropoffLat, req.DropoffLng)
+	estimate, err := h.service.EstimateFare(ctx, customerID, domain.RideType(req.RideType), req.PickupLat, req.PickupLng, req.DropoffLat, req.DropoffLng)
 	if err != nil {
 		logger.Error(ctx, err)
-		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		return err
 	}
 
-	return c.JSON(http.StatusCreated, ride)
+	return c.JSON(http.StatusOK, estimate)
 }
 
 type GetNearbyRidesRequest struct {
-	Lat         float64 `json:"lat" validate:"required"`
-	Lng         float64 `json:"lng" validate:"required"`
-	MaxDistance float64 `json:"max_distance"` // in meters, default 10000
-	Limit       int     `json:"limit"`        // max number of rides to return, default 50
+	Lat              float64 `json:"lat" validate:"required,min=-90,max=90"`
+	Lng              float64 `json:"lng" validate:"required,min=-180,max=180"`
+	MaxDistance      float64 `json:"max_distance" validate:"min=0"`               // in meters, default LOCATION_RIDE_SEARCH_RADIUS_METERS, capped at LOCATION_MAX_SEARCH_RADIUS_METERS
+	Limit            int     `json:"limit" validate:"min=0"`                      // max number of rides to return, default LOCATION_RIDE_SEARCH_LIMIT, at most 100
+	FreshnessSeconds int     `json:"freshness_seconds" validate:"min=0,max=1800"` // max age of the ride's last update, default RIDE_NEARBY_FRESHNESS
 }
 
 // GetNearbyRides handles getting nearby rides for drivers (Short Polling Endpoint)
 // @Summary Get nearby available rides for driver
-// @Description Driver polls this endpoint to get available rides within a radius. Returns rides with status "requested" or "pending" updated within last 5 minutes.
+// @Description Driver polls this endpoint to get available rides within a radius. Returns rides with status "requested" or "pending" updated within freshness_seconds (default 5 minutes, at most 30 minutes). A max_distance above the configured maximum search radius (default 50 km) is reduced to it.
 // @Tags Rides
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param request body GetNearbyRidesRequest true "Driver location and search parameters"
 // @Success 200 {array} domain.Ride "List of nearby available rides"
-// @Failure 400 {object} ErrorResponse "Invalid request parameters"
+// @Failure 400 {object} ValidationErrorResponse "Invalid request parameters"
 // @Failure 401 {object} ErrorResponse "Unauthorized - driver must be logged in"
+// @Failure 403 {object} ErrorResponse "Forbidden - driver role required"
 // @Failure 500 {object} ErrorResponse "Internal server error"
 // @Router /rides/nearby [post]
 func (h *RideHandler) GetNearbyRides(c echo.Context) error {
@@ -102,17 +170,7 @@ func (h *RideHandler) GetNearbyRides(c echo.Context) error {
 		logger.Error(ctx, errors.New("missing driver ID in context"))
 		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing driver ID in context"})
 	}
-	fmt.Println("Driver ID from context:", driverID)
-
-	role, ok := middleware.GetUserRoleFromEcho(c)
-	if !ok {
-		logger.Error(ctx, errors.New("missing role in context"))
-		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing role in context"})
-	}
-	if role != "driver" {
-		logger.Error(ctx, errors.New("role is not driver"))
-		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "invalid role in context"})
-	}
+	logger.DebugWithContext(ctx, fmt.Sprintf("driver ID from context: %d", driverID))
 
 	var req GetNearbyRidesRequest
 	if err := c.Bind(&req); err != nil {
@@ -120,28 +178,16 @@ func (h *RideHandler) GetNearbyRides(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid request body"})
 	}
 
-	// Validate required fields
-	if req.Lat == 0 || req.Lng == 0 {
-		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "lat and lng are required"})
-	}
-
-	// Set defaults
-	if req.MaxDistance == 0 {
-		req.MaxDistance = 10000 // default 10km in meters
-	}
-	if req.Limit == 0 {
-		req.Limit = 50 // default 50 rides
+	if err := c.Validate(&req); err != nil {
+		logger.Error(ctx, err)
+		return c.JSON(http.StatusBadRequest, NewValidationErrorResponse(err))
 	}
 
-	// Validate limits
-	if req.Limit > 100 {
-		req.Limit = 100 // cap at 100 rides
-	}
-	if req.Limit < 1 {
-		req.Limit = 1 // minimum 1 ride
+	freshness := time.Duration(req.FreshnessSeconds) * time.Second
+	rides, err := h.service.GetNearbyRides(ctx, driverID, req.Lat, req.Lng, req.MaxDistance, freshness, req.Limit)
+	if errors.Is(err, service.ErrInvalidNearbyFreshness) {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
 	}
-
-	rides, err := h.service.GetNearbyRides(ctx, driverID, req.Lat, req.Lng, req.MaxDistance, req.Limit)
 	if err != nil {
 		logger.Error(ctx, err)
 		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
@@ -161,6 +207,10 @@ func (h *RideHandler) GetNearbyRides(c echo.Context) error {
 // @Success 200 {object} MessageResponse "Ride accepted successfully"
 // @Failure 400 {object} ErrorResponse "Invalid request"
 // @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - driver role required"
+// @Failure 404 {object} ErrorResponse "Ride not found"
+// @Failure 409 {object} ErrorResponse "Driver is offline, or the ride is not offered to this driver or was already accepted"
+// @Failure 500 {object} ErrorResponse "Internal server error"
 // @Router /rides/accept [post]
 func (h *RideHandler) AcceptRide(c echo.Context) error {
 	ctx := c.Request().Context()
@@ -176,37 +226,112 @@ func (h *RideHandler) AcceptRide(c echo.Context) error {
 		logger.Error(ctx, errors.New("missing customer ID in context"))
 		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing driver ID in context"})
 	}
-	fmt.Println("Driver ID from context:", driverID)
+	logger.DebugWithContext(ctx, fmt.Sprintf("driver ID from context: %d", driverID))
 
-	role, ok := middleware.GetUserRoleFromEcho(c)
+	err = h.service.AcceptRide(ctx, rideID, driverID)
+	if err != nil {
+		logger.Error(ctx, err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, MessageResponse{Message: "Ride accepted successfully"})
+}
+
+// DeclineRide handles driver declining a ride
+// @Summary Decline a ride request
+// @Description Driver declines a ride request so it is no longer offered to them
+// @Tags Rides
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param ride_id query integer true "Ride ID to decline"
+// @Success 200 {object} MessageResponse "Ride declined successfully"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - driver role required"
+// @Failure 404 {object} ErrorResponse "Ride not found"
+// @Failure 409 {object} ErrorResponse "Ride is no longer waiting for a driver"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /rides/decline [post]
+func (h *RideHandler) DeclineRide(c echo.Context) error {
+	ctx := c.Request().Context()
+	rideIDStr := c.QueryParam("ride_id")
+	rideID, err := strconv.ParseInt(rideIDStr, 10, 64)
+	if err != nil {
+		logger.Error(ctx, err)
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+	}
+
+	driverID, ok := middleware.GetUserIDFromEcho(c)
 	if !ok {
-		logger.Error(ctx, errors.New("missing role in context"))
-		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing role in context"})
+		logger.Error(ctx, errors.New("missing driver ID in context"))
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing driver ID in context"})
 	}
-	if role != "driver" {
-		logger.Error(ctx, errors.New("role is not driver"))
-		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "invalid role in context"})
+
+	err = h.service.DeclineRide(ctx, rideID, driverID)
+	if err != nil {
+		logger.Error(ctx, err)
+		return err
 	}
 
-	err = h.service.AcceptRide(ctx, rideID, driverID)
+	return c.JSON(http.StatusOK, MessageResponse{Message: "Ride declined successfully"})
+}
+
+// MarkArrived handles the driver reaching the pickup
+// @Summary Mark arrived at pickup
+// @Description Driver marks an accepted ride as arrived, they are waiting for the customer at the pickup
+// @Tags Rides
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param ride_id query integer true "Ride ID the driver arrived for"
+// @Success 200 {object} MessageResponse "Arrival recorded successfully"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - driver role required"
+// @Failure 404 {object} ErrorResponse "Ride not found"
+// @Failure 409 {object} ErrorResponse "Ride is not accepted by this driver"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /rides/arrived [post]
+func (h *RideHandler) MarkArrived(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	driverID, ok := middleware.GetUserIDFromEcho(c)
+	if !ok {
+		logger.Error(ctx, errors.New("missing driver ID in context"))
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing driver ID in context"})
+	}
+
+	rideIDStr := c.QueryParam("ride_id")
+	rideID, err := strconv.ParseInt(rideIDStr, 10, 64)
 	if err != nil {
 		logger.Error(ctx, err)
 		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
 	}
 
-	return c.JSON(http.StatusOK, MessageResponse{Message: "Ride accepted successfully"})
+	err = h.service.MarkArrived(ctx, rideID, driverID)
+	if err != nil {
+		logger.Error(ctx, err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, MessageResponse{Message: "Arrival recorded successfully"})
 }
 
 // StartRide handles starting a ride
 // @Summary Start a ride
-// @Description Mark a ride as started
+// @Description Mark a ride as started. The driver must have marked arrived first unless the server allows starting from accepted
 // @Tags Rides
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param ride_id query integer true "Ride ID to start"
 // @Success 200 {object} MessageResponse "Ride started successfully"
-// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 400 {object} ErrorResponse "Invalid request or driver has not marked arrived"
+// @Failure 403 {object} ErrorResponse "Forbidden - driver role required or ride assigned to another driver"
+// @Failure 404 {object} ErrorResponse "Ride not found"
+// @Failure 409 {object} ErrorResponse "Ride is not waiting for pickup"
+// @Failure 500 {object} ErrorResponse "Internal server error"
 // @Router /rides/start [post]
 func (h *RideHandler) StartRide(c echo.Context) error {
 	ctx := c.Request().Context()
@@ -216,17 +341,7 @@ func (h *RideHandler) StartRide(c echo.Context) error {
 		logger.Error(ctx, errors.New("missing customer ID in context"))
 		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing driver ID in context"})
 	}
-	fmt.Println("Driver ID from context:", driverID)
-
-	role, ok := middleware.GetUserRoleFromEcho(c)
-	if !ok {
-		logger.Error(ctx, errors.New("missing role in context"))
-		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing role in context"})
-	}
-	if role != "driver" {
-		logger.Error(ctx, errors.New("role is not driver"))
-		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "invalid role in context"})
-	}
+	logger.DebugWithContext(ctx, fmt.Sprintf("driver ID from context: %d", driverID))
 
 	rideIDStr := c.QueryParam("ride_id")
 	rideID, err := strconv.ParseInt(rideIDStr, 10, 64)
@@ -235,10 +350,10 @@ func (h *RideHandler) StartRide(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
 	}
 
-	err = h.service.StartRide(c.Request().Context(), rideID)
+	err = h.service.StartRide(ctx, rideID, driverID)
 	if err != nil {
 		logger.Error(ctx, err)
-		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return err
 	}
 
 	return c.JSON(http.StatusOK, MessageResponse{Message: "Ride started successfully"})
@@ -254,6 +369,10 @@ func (h *RideHandler) StartRide(c echo.Context) error {
 // @Param ride_id query integer true "Ride ID to complete"
 // @Success 200 {object} MessageResponse "Ride completed successfully"
 // @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 403 {object} ErrorResponse "Forbidden - driver role required or ride assigned to another driver"
+// @Failure 404 {object} ErrorResponse "Ride not found"
+// @Failure 409 {object} ErrorResponse "Ride has not started"
+// @Failure 500 {object} ErrorResponse "Internal server error"
 // @Router /rides/complete [post]
 func (h *RideHandler) CompleteRide(c echo.Context) error {
 	ctx := c.Request().Context()
@@ -263,17 +382,7 @@ func (h *RideHandler) CompleteRide(c echo.Context) error {
 		logger.Error(ctx, errors.New("missing customer ID in context"))
 		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing driver ID in context"})
 	}
-	fmt.Println("Driver ID from context:", driverID)
-
-	role, ok := middleware.GetUserRoleFromEcho(c)
-	if !ok {
-		logger.Error(ctx, errors.New("missing role in context"))
-		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing role in context"})
-	}
-	if role != "driver" {
-		logger.Error(ctx, errors.New("role is not driver"))
-		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "invalid role in context"})
-	}
+	logger.DebugWithContext(ctx, fmt.Sprintf("driver ID from context: %d", driverID))
 
 	rideIDStr := c.QueryParam("ride_id")
 	rideID, err := strconv.ParseInt(rideIDStr, 10, 64)
@@ -282,65 +391,106 @@ func (h *RideHandler) CompleteRide(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
 	}
 
-	err = h.service.CompleteRide(ctx, rideID)
+	err = h.service.CompleteRide(ctx, rideID, driverID)
 	if err != nil {
 		logger.Error(ctx, err)
-		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return err
 	}
 
 	return c.JSON(http.StatusOK, MessageResponse{Message: "Ride completed successfully"})
 }
 
-// CancelRide handles cancelling a ride
+// CancelRide handles driver cancelling a ride
 // @Summary Cancel a ride
-// @Description Cancel an active or pending ride
+// @Description Driver cancels an active or pending ride. An accepted ride that has not started is released back to dispatch and offered to another driver
 // @Tags Rides
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param ride_id query integer true "Ride ID to cancel"
+// @Param reason query string false "Cancellation reason"
 // @Success 200 {object} MessageResponse "Ride cancelled successfully"
 // @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - driver role required or ride assigned to another driver"
+// @Failure 404 {object} ErrorResponse "Ride not found"
+// @Failure 409 {object} ErrorResponse "Ride is not accepted by this driver"
+// @Failure 500 {object} ErrorResponse "Internal server error"
 // @Router /rides/cancel [post]
 func (h *RideHandler) CancelRide(c echo.Context) error {
 	ctx := c.Request().Context()
 
 	driverID, ok := middleware.GetUserIDFromEcho(c)
 	if !ok {
-		logger.Error(ctx, errors.New("missing customer ID in context"))
+		logger.Error(ctx, errors.New("missing driver ID in context"))
 		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing driver ID in context"})
 	}
-	fmt.Println("Driver ID from context:", driverID)
+	logger.DebugWithContext(ctx, fmt.Sprintf("driver ID from context: %d", driverID))
 
-	role, ok := middleware.GetUserRoleFromEcho(c)
-	if !ok {
-		logger.Error(ctx, errors.New("missing role in context"))
-		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing role in context"})
+	rideIDStr := c.QueryParam("ride_id")
+	rideID, err := strconv.ParseInt(rideIDStr, 10, 64)
+	if err != nil {
+		logger.Error(ctx, err)
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
 	}
-	if role != "driver" {
-		logger.Error(ctx, errors.New("role is not driver"))
-		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "invalid role in context"})
+
+	err = h.service.CancelRide(ctx, rideID, driverID, c.QueryParam("reason"))
+	if err != nil {
+		logger.Error(ctx, err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, MessageResponse{Message: "Ride cancelled successfully"})
+}
+
+// CustomerCancelRide handles customer cancelling their own ride
+// @Summary Cancel a ride as customer
+// @Description Customer cancels a ride they requested. Completed or already cancelled rides cannot be cancelled. Cancelling is free shortly after requesting while no driver has accepted, otherwise a cancellation fee is charged
+// @Tags Rides
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param ride_id query integer true "Ride ID to cancel"
+// @Param reason query string false "Cancellation reason"
+// @Success 200 {object} service.RideCancellation "Ride cancelled with the fee charged"
+// @Failure 400 {object} ErrorResponse "Invalid request or ride cannot be cancelled"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - not your ride"
+// @Failure 404 {object} ErrorResponse "Ride not found"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /rides/customer-cancel [post]
+func (h *RideHandler) CustomerCancelRide(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	customerID, ok := middleware.GetUserIDFromEcho(c)
+	if !ok {
+		logger.Error(ctx, errors.New("missing customer ID in context"))
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing customer ID in context"})
 	}
 
 	rideIDStr := c.QueryParam("ride_id")
+	if rideIDStr == "" {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "ride_id is required"})
+	}
+
 	rideID, err := strconv.ParseInt(rideIDStr, 10, 64)
 	if err != nil {
 		logger.Error(ctx, err)
-		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid ride_id"})
 	}
 
-	err = h.service.CancelRide(c.Request().Context(), rideID)
+	cancellation, err := h.service.CancelRideForCustomer(ctx, rideID, customerID, c.QueryParam("reason"))
 	if err != nil {
 		logger.Error(ctx, err)
-		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return err
 	}
 
-	return c.JSON(http.StatusOK, MessageResponse{Message: "Ride cancelled successfully"})
+	return c.JSON(http.StatusOK, cancellation)
 }
 
 // GetRideDetails handles getting ride details by ride_id
 // @Summary Get ride details
-// @Description Get detailed information about a specific ride including customer info
+// @Description Get detailed information about a specific ride including customer info. Available to the assigned or offered driver, the customer who requested the ride and admins. The offered driver sees the customer's phone masked until they accept
 // @Tags Rides
 // @Accept json
 // @Produce json
@@ -349,28 +499,25 @@ func (h *RideHandler) CancelRide(c echo.Context) error {
 // @Success 200 {object} service.RideWithCustomerInfo "Ride details with customer information"
 // @Failure 400 {object} ErrorResponse "Invalid request"
 // @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - not allowed to view this ride"
 // @Failure 404 {object} ErrorResponse "Ride not found"
 // @Failure 500 {object} ErrorResponse "Internal server error"
 // @Router /rides/details [get]
 func (h *RideHandler) GetRideDetails(c echo.Context) error {
 	ctx := c.Request().Context()
 
-	driverID, ok := middleware.GetUserIDFromEcho(c)
+	userID, ok := middleware.GetUserIDFromEcho(c)
 	if !ok {
-		logger.Error(ctx, errors.New("missing customer ID in context"))
-		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing driver ID in context"})
+		logger.Error(ctx, errors.New("missing user ID in context"))
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing user ID in context"})
 	}
-	fmt.Println("Driver ID from context:", driverID)
 
 	role, ok := middleware.GetUserRoleFromEcho(c)
 	if !ok {
 		logger.Error(ctx, errors.New("missing role in context"))
 		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing role in context"})
 	}
-	if role != "driver" {
-		logger.Error(ctx, errors.New("role is not driver"))
-		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "invalid role in context"})
-	}
+	logger.DebugWithContext(ctx, fmt.Sprintf("%s ID from context: %d", role, userID))
 
 	// Parse ride_id from query parameter
 	rideIDStr := c.QueryParam("ride_id")
@@ -385,29 +532,35 @@ func (h *RideHandler) GetRideDetails(c echo.Context) error {
 	}
 
 	// Get ride details with customer info
-	rideDetails, err := h.service.GetRideDetailsWithCustomer(ctx, rideID)
+	rideDetails, err := h.service.GetRideDetailsWithCustomer(ctx, rideID, userID, role)
 	if err != nil {
 		logger.Error(ctx, err)
-		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		return err
 	}
 
 	return c.JSON(http.StatusOK, rideDetails)
 }
 
 type RideStatusResponse struct {
-	RideID      int64    `json:"ride_id"`
-	CustomerID  int64    `json:"customer_id"`
-	PickupLat   float64  `json:"pickup_lat"`
-	PickupLng   float64  `json:"pickup_lng"`
-	DropoffLat  float64  `json:"dropoff_lat"`
-	DropoffLng  float64  `json:"dropoff_lng"`
-	Status      string   `json:"status"`
-	Fare        *float64 `json:"fare,omitempty"`
-	RequestedAt string   `json:"requested_at"`
-	AcceptedAt  *string  `json:"accepted_at,omitempty"`
-	StartedAt   *string  `json:"started_at,omitempty"`
-	CompletedAt *string  `json:"completed_at,omitempty"`
-	CancelledAt *string  `json:"cancelled_at,omitempty"`
+	RideID             int64             `json:"ride_id"`
+	CustomerID         int64             `json:"customer_id"`
+	PickupLat          float64           `json:"pickup_lat"`
+	PickupLng          float64           `json:"pickup_lng"`
+	DropoffLat         float64           `json:"dropoff_lat"`
+	DropoffLng         float64           `json:"dropoff_lng"`
+	Waypoints          []domain.Location `json:"waypoints,omitempty"`
+	Status             string            `json:"status"`
+	Fare               *float64          `json:"fare,omitempty"`
+	FareFormatted      *string           `json:"fare_formatted,omitempty"` // fare with its currency code, e.g. "BDT 170.00"
+	Currency           string            `json:"currency"`
+	RequestedAt        string            `json:"requested_at"`
+	AcceptedAt         *string           `json:"accepted_at,omitempty"`
+	ArrivedAt          *string           `json:"arrived_at,omitempty"`
+	StartedAt          *string           `json:"started_at,omitempty"`
+	CompletedAt        *string           `json:"completed_at,omitempty"`
+	CancelledAt        *string           `json:"cancelled_at,omitempty"`
+	CancelledBy        string            `json:"cancelled_by,omitempty"`
+	CancellationReason string            `json:"cancellation_reason,omitempty"`
 
 	// Driver information (only if ride is accepted/started/completed)
 	Driver *DriverInfo `json:"driver,omitempty"`
@@ -421,6 +574,7 @@ type DriverInfo struct {
 	CurrentLat *float64 `json:"current_lat,omitempty"`  // Driver's current location
 	CurrentLng *float64 `json:"current_lng,omitempty"`  // Driver's current location
 	LastPingAt *string  `json:"last_ping_at,omitempty"` // Last location update time
+	EtaMinutes *int     `json:"eta_minutes,omitempty"`  // Minutes until the driver reaches the pickup, only while the ride is accepted
 }
 
 type SendRideRequestToDriverRequest struct {
@@ -509,19 +663,92 @@ func (h *RideHandler) GetRideStatus(c echo.Context) error {
 		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing customer ID in context"})
 	}
 
+	// Parse ride_id from query parameter
+	rideIDStr := c.QueryParam("ride_id")
+	if rideIDStr == "" {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "ride_id is required"})
+	}
+
+	rideID, err := strconv.ParseInt(rideIDStr, 10, 64)
+	if err != nil {
+		logger.Error(ctx, err)
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid ride_id"})
+	}
+
+	// Get ride status with driver information
+	rideStatus, err := h.service.GetRideStatusForCustomer(ctx, rideID, customerID)
+	if err != nil {
+		logger.Error(ctx, err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, rideStatus)
+}
+
+// GetActiveRide handles looking up the ride the authenticated user is currently taking part in
+// @Summary Get active ride
+// @Description Get the customer's ride that is still waiting for a driver or in progress, or the ride the driver has accepted, arrived at or started. Apps call it on open to resume the ride
+// @Tags Rides
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} domain.Ride "Active ride"
+// @Success 204 "No active ride"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Only customers and drivers have rides"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /rides/active [get]
+func (h *RideHandler) GetActiveRide(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	userID, ok := middleware.GetUserIDFromEcho(c)
+	if !ok {
+		logger.Error(ctx, errors.New("missing user ID in context"))
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing user ID in context"})
+	}
+
 	role, ok := middleware.GetUserRoleFromEcho(c)
 	if !ok {
 		logger.Error(ctx, errors.New("missing role in context"))
 		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing role in context"})
 	}
 
-	fmt.Println("Ride ID from context:", customerID, "role:", role)
-	if role != "customer" {
-		logger.Error(ctx, errors.New("invalid role"))
-		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "only customers can check ride status"})
+	ride, err := h.service.GetActiveRide(ctx, userID, role)
+	if err != nil {
+		logger.Error(ctx, err)
+		return err
+	}
+	if ride == nil {
+		return c.NoContent(http.StatusNoContent)
+	}
+
+	return c.JSON(http.StatusOK, ride)
+}
+
+// GetRideETA handles recomputing the ETA of the ride's driver
+// @Summary Get ride ETA
+// @Description Estimate from the driver's latest location how long they need to reach the pickup, or the dropoff once the ride has started
+// @Tags Rides
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param ride_id query integer true "Ride ID"
+// @Success 200 {object} service.RideETA "Fresh ETA"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - not your ride"
+// @Failure 404 {object} ErrorResponse "Ride or driver location not found"
+// @Failure 409 {object} ErrorResponse "Ride has no assigned driver or is not in progress"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /rides/eta [get]
+func (h *RideHandler) GetRideETA(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	customerID, ok := middleware.GetUserIDFromEcho(c)
+	if !ok {
+		logger.Error(ctx, errors.New("missing customer ID in context"))
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing customer ID in context"})
 	}
 
-	// Parse ride_id from query parameter
 	rideIDStr := c.QueryParam("ride_id")
 	if rideIDStr == "" {
 		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "ride_id is required"})
@@ -533,18 +760,303 @@ func (h *RideHandler) GetRideStatus(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid ride_id"})
 	}
 
-	// Get ride status with driver information
-	rideStatus, err := h.service.GetRideStatusForCustomer(ctx, rideID, customerID)
+	eta, err := h.service.GetRideETA(ctx, rideID, customerID)
+	if err != nil {
+		logger.Error(ctx, err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, eta)
+}
+
+// GetRideRoute handles returning the path travelled during a ride
+// @Summary Get ride route
+// @Description Return the driver locations recorded while the ride was started, oldest first. Only the ride's customer and assigned driver can view it
+// @Tags Rides
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param ride_id query integer true "Ride ID"
+// @Success 200 {array} service.TrailPoint "Recorded path"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - not part of this ride"
+// @Failure 404 {object} ErrorResponse "Ride not found"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /rides/route [get]
+func (h *RideHandler) GetRideRoute(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	userID, ok := middleware.GetUserIDFromEcho(c)
+	if !ok {
+		logger.Error(ctx, errors.New("missing user ID in context"))
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing user ID in context"})
+	}
+
+	role, ok := middleware.GetUserRoleFromEcho(c)
+	if !ok {
+		logger.Error(ctx, errors.New("missing role in context"))
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing role in context"})
+	}
+
+	rideID, err := strconv.ParseInt(c.QueryParam("ride_id"), 10, 64)
+	if err != nil {
+		logger.Error(ctx, err)
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid ride_id"})
+	}
+
+	route, err := h.service.GetRideRoute(ctx, rideID, userID, role)
+	if err != nil {
+		logger.Error(ctx, err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, route)
+}
+
+// GetRideEvents handles returning a ride's status change timeline
+// @Summary Get ride events
+// @Description Return every status change of the ride with who made it and when, oldest first. Only the ride's customer and assigned driver can view it
+// @Tags Rides
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param ride_id query integer true "Ride ID"
+// @Success 200 {array} domain.RideEvent "Status changes"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - not part of this ride"
+// @Failure 404 {object} ErrorResponse "Ride not found"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /rides/events [get]
+func (h *RideHandler) GetRideEvents(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	userID, ok := middleware.GetUserIDFromEcho(c)
+	if !ok {
+		logger.Error(ctx, errors.New("missing user ID in context"))
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing user ID in context"})
+	}
+
+	role, ok := middleware.GetUserRoleFromEcho(c)
+	if !ok {
+		logger.Error(ctx, errors.New("missing role in context"))
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing role in context"})
+	}
+
+	rideID, err := strconv.ParseInt(c.QueryParam("ride_id"), 10, 64)
+	if err != nil {
+		logger.Error(ctx, err)
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid ride_id"})
+	}
+
+	events, err := h.service.GetRideEvents(ctx, rideID, userID, role)
+	if err != nil {
+		logger.Error(ctx, err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, events)
+}
+
+// StreamRideStatus handles streaming ride status snapshots to the customer as Server-Sent Events
+// @Summary Stream ride status
+// @Description Server-Sent Events fallback for clients without WebSocket support. Each "data:" event carries a ride status snapshot, sent right away and then every few seconds. The stream closes after the snapshot showing the ride completed or cancelled
+// @Tags Rides
+// @Produce text/event-stream
+// @Security BearerAuth
+// @Param ride_id query integer true "Ride ID"
+// @Success 200 {object} RideStatusResponse "Stream of ride status snapshots"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - not your ride"
+// @Failure 404 {object} ErrorResponse "Ride not found"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /rides/status/stream [get]
+func (h *RideHandler) StreamRideStatus(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	customerID, ok := middleware.GetUserIDFromEcho(c)
+	if !ok {
+		logger.Error(ctx, errors.New("missing customer ID in context"))
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing customer ID in context"})
+	}
+
+	rideID, err := strconv.ParseInt(c.QueryParam("ride_id"), 10, 64)
 	if err != nil {
 		logger.Error(ctx, err)
-		if err.Error() == "ride not found" {
-			return c.JSON(http.StatusNotFound, ErrorResponse{Error: err.Error()})
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid ride_id"})
+	}
+
+	// Headers are written with the first snapshot, so lookup and ownership errors can still be sent as JSON
+	res := c.Response()
+	started := false
+	err = h.service.StreamRideStatusForCustomer(ctx, rideID, customerID, func(status *service.RideStatusResponse) error {
+		if !started {
+			res.Header().Set(echo.HeaderContentType, "text/event-stream")
+			res.Header().Set(echo.HeaderCacheControl, "no-cache")
+			res.Header().Set(echo.HeaderConnection, "keep-alive")
+			res.WriteHeader(http.StatusOK)
+			started = true
+		}
+
+		payload, err := json.Marshal(status)
+		if err != nil {
+			return err
 		}
-		if err.Error() == "forbidden: this ride belongs to another customer" {
-			return c.JSON(http.StatusForbidden, ErrorResponse{Error: err.Error()})
+		if _, err := fmt.Fprintf(res, "data: %s\n\n", payload); err != nil {
+			return err
+		}
+		res.Flush()
+		return nil
+	})
+	if err != nil {
+		logger.Error(ctx, err)
+		if started {
+			return nil
+		}
+		return err
+	}
+
+	return nil
+}
+
+// GetRideHistory handles listing the authenticated user's past rides
+// @Summary Get ride history
+// @Description Get a page of the authenticated customer's or driver's rides, newest first, including status, fare and timestamps
+// @Tags Rides
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param status query string false "Only return rides in this status, customers only" Enums(requested, pending, accepted, arrived, started, completed, cancelled)
+// @Param limit query integer false "Page size, default 20, max 100"
+// @Param offset query integer false "Number of rides to skip, default 0"
+// @Success 200 {object} service.RideHistoryPage "Page of rides with total count"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /rides/history [get]
+func (h *RideHandler) GetRideHistory(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	userID, ok := middleware.GetUserIDFromEcho(c)
+	if !ok {
+		logger.Error(ctx, errors.New("missing user ID in context"))
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing user ID in context"})
+	}
+
+	role, ok := middleware.GetUserRoleFromEcho(c)
+	if !ok {
+		logger.Error(ctx, errors.New("missing role in context"))
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing role in context"})
+	}
+
+	limit := 20 // default 20 rides
+	if limitStr := c.QueryParam("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed < 1 {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid limit"})
+		}
+		limit = parsed
+	}
+	if limit > 100 {
+		limit = 100 // cap at 100 rides
+	}
+
+	offset := 0
+	if offsetStr := c.QueryParam("offset"); offsetStr != "" {
+		parsed, err := strconv.Atoi(offsetStr)
+		if err != nil || parsed < 0 {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid offset"})
+		}
+		offset = parsed
+	}
+
+	var (
+		history *service.RideHistoryPage
+		err     error
+	)
+	switch role {
+	case "customer":
+		status := domain.RideStatus(c.QueryParam("status"))
+		history, err = h.service.GetCustomerRideHistory(ctx, userID, status, limit, offset)
+	case "driver":
+		history, err = h.service.GetDriverRideHistory(ctx, userID, limit, offset)
+	default:
+		logger.Error(ctx, errors.New("invalid role"))
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "invalid role in context"})
+	}
+	if err != nil {
+		logger.Error(ctx, err)
+		if errors.Is(err, domain.ErrInvalidRideStatus) {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
 		}
 		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
 	}
 
-	return c.JSON(http.StatusOK, rideStatus)
+	return c.JSON(http.StatusOK, history)
+}
+
+// TrackRide handles streaming the driver's live location to the customer over a WebSocket
+// @Summary Track ride over WebSocket
+// @Description Upgrade to a WebSocket that receives the assigned driver's location as JSON events of type "location" whenever the driver sends a ping. A final "status" event is sent and the socket closed when the ride is completed or cancelled
+// @Tags Rides
+// @Security BearerAuth
+// @Param ride_id query integer true "Ride ID"
+// @Success 101 {object} service.RideTrackingEvent "Switching protocols, events follow"
+// @Failure 400 {object} ErrorResponse "Invalid request or ride already finished"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - not your ride"
+// @Failure 404 {object} ErrorResponse "Ride not found"
+// @Router /rides/track [get]
+func (h *RideHandler) TrackRide(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	customerID, ok := middleware.GetUserIDFromEcho(c)
+	if !ok {
+		logger.Error(ctx, errors.New("missing customer ID in context"))
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing customer ID in context"})
+	}
+
+	rideID, err := strconv.ParseInt(c.QueryParam("ride_id"), 10, 64)
+	if err != nil {
+		logger.Error(ctx, err)
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid ride_id"})
+	}
+
+	ride, err := h.service.GetRideForCustomer(ctx, rideID, customerID)
+	if err != nil {
+		logger.Error(ctx, err)
+		return err
+	}
+	if ride.IsTerminal() {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "ride has already finished"})
+	}
+
+	websocket.Handler(func(ws *websocket.Conn) {
+		defer ws.Close()
+
+		streamCtx, cancel := context.WithCancel(ws.Request().Context())
+		defer cancel()
+
+		// The client never sends anything, reading only detects when it goes away
+		go func() {
+			var discard string
+			for {
+				if err := websocket.Message.Receive(ws, &discard); err != nil {
+					cancel()
+					return
+				}
+			}
+		}()
+
+		err := h.trackingService.StreamRideLocation(streamCtx, rideID, func(payload []byte) error {
+			return websocket.Message.Send(ws, string(payload))
+		})
+		if err != nil {
+			logger.Error(streamCtx, err)
+		}
+	}).ServeHTTP(c.Response(), c.Request())
+
+	return nil
 }
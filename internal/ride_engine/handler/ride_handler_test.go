@@ -0,0 +1,199 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/repository"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/service"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/logger"
+)
+
+// captureStdout returns everything written to os.Stdout while fn runs, with the logger at debug level
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+
+	level := logger.DefaultLogger().Level
+	logger.SetLogLevel(logrus.DebugLevel)
+	defer logger.SetLogLevel(level)
+
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	fn()
+
+	require.NoError(t, w.Close())
+	out, err := io.ReadAll(r)
+	require.NoError(t, err)
+	return string(out)
+}
+
+func TestRideHandler_RequestRide_DoesNotWriteToStdout(t *testing.T) {
+	h := NewRideHandler(service.NewRideService(nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, 0, 0, 0, nil, nil, 0, false, nil, false), nil)
+
+	e := echo.New()
+	e.Validator = NewRequestValidator()
+	body := `{"pickup_lat":23.81,"pickup_lng":90.41,"dropoff_lat":23.75,"dropoff_lng":90.37,"ride_type":"spaceship"}`
+	req := httptest.NewRequest(http.MethodPost, "/rides", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.Set("user_id", int64(123))
+
+	out := captureStdout(t, func() {
+		err := h.RequestRide(c)
+		require.ErrorIs(t, err, domain.ErrInvalidRideType)
+		HTTPErrorHandler(err, c)
+	})
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Empty(t, out)
+}
+
+// fakeRideRepository serves GetByID from a map, the other RideRepository methods are not implemented
+type fakeRideRepository struct {
+	repository.RideRepository
+	rides map[int64]*domain.Ride
+}
+
+func (r fakeRideRepository) GetByID(ctx context.Context, id int64) (*domain.Ride, error) {
+	ride, ok := r.rides[id]
+	if !ok {
+		return nil, repository.ErrRideNotFound
+	}
+	return ride, nil
+}
+
+func (r fakeRideRepository) GetActiveRideByCustomerID(ctx context.Context, customerID int64) (*domain.Ride, error) {
+	for _, ride := range r.rides {
+		if ride.CustomerID == customerID && ride.Status != domain.RideStatusCompleted && ride.Status != domain.RideStatusCancelled {
+			return ride, nil
+		}
+	}
+	return nil, repository.ErrRideNotFound
+}
+
+func (r fakeRideRepository) GetActiveRideByDriverID(ctx context.Context, driverID int64) (*domain.Ride, error) {
+	for _, ride := range r.rides {
+		if ride.DriverID != nil && *ride.DriverID == driverID && ride.Status != domain.RideStatusCompleted && ride.Status != domain.RideStatusCancelled {
+			return ride, nil
+		}
+	}
+	return nil, repository.ErrRideNotFound
+}
+
+// getRideStatus requests the ride's status as customerID through echo's error handler
+func getRideStatus(t *testing.T, rides map[int64]*domain.Ride, customerID int64, rideID string) (*httptest.ResponseRecorder, ErrorResponse) {
+	t.Helper()
+
+	rideRepo := fakeRideRepository{rides: rides}
+	h := NewRideHandler(service.NewRideService(rideRepo, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, 0, 0, 0, nil, nil, 0, false, nil, false), nil)
+
+	e := echo.New()
+	e.HTTPErrorHandler = HTTPErrorHandler
+	e.GET("/rides/status", h.GetRideStatus, func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set("user_id", customerID)
+			return next(c)
+		}
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/rides/status?ride_id="+rideID, nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	var body ErrorResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	return rec, body
+}
+
+func TestRideHandler_GetRideStatus_OtherCustomersRideIsForbidden(t *testing.T) {
+	rides := map[int64]*domain.Ride{1: {ID: 1, CustomerID: 123, Status: domain.RideStatusRequested}}
+
+	rec, body := getRideStatus(t, rides, 456, "1")
+
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Equal(t, service.ErrRideForbidden.Error(), body.Error)
+}
+
+func TestRideHandler_GetRideStatus_UnknownRideIsNotFound(t *testing.T) {
+	rec, body := getRideStatus(t, map[int64]*domain.Ride{}, 123, "99")
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, service.ErrRideNotFound.Error(), body.Error)
+}
+
+// getActiveRide requests the active ride of the user through echo's error handler
+func getActiveRide(t *testing.T, rides map[int64]*domain.Ride, userID int64, role string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	rideRepo := fakeRideRepository{rides: rides}
+	h := NewRideHandler(service.NewRideService(rideRepo, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, 0, 0, 0, nil, nil, 0, false, nil, false), nil)
+
+	e := echo.New()
+	e.HTTPErrorHandler = HTTPErrorHandler
+	e.GET("/rides/active", h.GetActiveRide, func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set("user_id", userID)
+			c.Set("user_role", role)
+			return next(c)
+		}
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/rides/active", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRideHandler_GetActiveRide(t *testing.T) {
+	driverID := int64(456)
+	rides := map[int64]*domain.Ride{
+		1: {ID: 1, CustomerID: 123, DriverID: &driverID, Status: domain.RideStatusCompleted},
+		2: {ID: 2, CustomerID: 123, DriverID: &driverID, Status: domain.RideStatusArrived},
+	}
+
+	tests := []struct {
+		name     string
+		userID   int64
+		role     string
+		expected int
+		rideID   int64
+	}{
+		{name: "customer", userID: 123, role: "customer", expected: http.StatusOK, rideID: 2},
+		{name: "driver", userID: driverID, role: "driver", expected: http.StatusOK, rideID: 2},
+		{name: "customer without active ride", userID: 789, role: "customer", expected: http.StatusNoContent},
+		{name: "driver without active ride", userID: 999, role: "driver", expected: http.StatusNoContent},
+		{name: "admin", userID: 1, role: "admin", expected: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := getActiveRide(t, rides, tt.userID, tt.role)
+
+			assert.Equal(t, tt.expected, rec.Code)
+			if tt.expected == http.StatusOK {
+				var ride domain.Ride
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &ride))
+				assert.Equal(t, tt.rideID, ride.ID)
+			}
+			if tt.expected == http.StatusNoContent {
+				assert.Empty(t, rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/logger"
+
+	"github.com/labstack/echo/v4"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/service"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/middleware"
+)
+
+type SavedLocationHandler struct {
+	service *service.SavedLocationService
+}
+
+func NewSavedLocationHandler(service *service.SavedLocationService) *SavedLocationHandler {
+	return &SavedLocationHandler{service: service}
+}
+
+type CreateSavedLocationRequest struct {
+	Label string  `json:"label" example:"Home"`
+	Lat   float64 `json:"lat"`
+	Lng   float64 `json:"lng"`
+}
+
+// Create handles a customer saving a labelled location
+// @Summary Save a location
+// @Description Save a labelled place such as "Home" or "Work" to reuse as a ride pickup
+// @Tags Customers
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body CreateSavedLocationRequest true "Label and coordinates"
+// @Success 201 {object} domain.SavedLocation "Location saved"
+// @Failure 400 {object} ErrorResponse "Invalid label or coordinates, or limit reached"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - customer role required"
+// @Failure 409 {object} ErrorResponse "Label already used"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /customers/locations [post]
+func (h *SavedLocationHandler) Create(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	customerID, ok := middleware.GetUserIDFromEcho(c)
+	if !ok {
+		logger.Error(ctx, errors.New("missing customer ID in context"))
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing customer ID in context"})
+	}
+
+	var req CreateSavedLocationRequest
+	if err := c.Bind(&req); err != nil {
+		logger.Error(ctx, err)
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+	}
+
+	location, err := h.service.Create(ctx, customerID, req.Label, req.Lat, req.Lng)
+	if err != nil {
+		logger.Error(ctx, err)
+		if errors.Is(err, domain.ErrInvalidSavedLocationLabel) ||
+			errors.Is(err, domain.ErrInvalidLatitude) ||
+			errors.Is(err, domain.ErrInvalidLongitude) ||
+			errors.Is(err, service.ErrTooManySavedLocations) {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		}
+		if errors.Is(err, service.ErrSavedLocationLabelTaken) {
+			return c.JSON(http.StatusConflict, ErrorResponse{Error: err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+
+	return c.JSON(http.StatusCreated, location)
+}
+
+// List handles listing the customer's saved locations
+// @Summary List saved locations
+// @Description List the authenticated customer's saved locations
+// @Tags Customers
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} domain.SavedLocation "Saved locations"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - customer role required"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /customers/locations [get]
+func (h *SavedLocationHandler) List(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	customerID, ok := middleware.GetUserIDFromEcho(c)
+	if !ok {
+		logger.Error(ctx, errors.New("missing customer ID in context"))
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing customer ID in context"})
+	}
+
+	locations, err := h.service.List(ctx, customerID)
+	if err != nil {
+		logger.Error(ctx, err)
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, locations)
+}
+
+// Delete handles removing one of the customer's saved locations
+// @Summary Delete a saved location
+// @Description Delete one of the authenticated customer's saved locations
+// @Tags Customers
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Saved location ID"
+// @Success 200 {object} MessageResponse "Location deleted"
+// @Failure 400 {object} ErrorResponse "Invalid ID"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - customer role required"
+// @Failure 404 {object} ErrorResponse "Saved location not found"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /customers/locations/{id} [delete]
+func (h *SavedLocationHandler) Delete(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	customerID, ok := middleware.GetUserIDFromEcho(c)
+	if !ok {
+		logger.Error(ctx, errors.New("missing customer ID in context"))
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "missing customer ID in context"})
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		logger.Error(ctx, err)
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid saved location id"})
+	}
+
+	if err := h.service.Delete(ctx, customerID, id); err != nil {
+		logger.Error(ctx, err)
+		if errors.Is(err, service.ErrSavedLocationNotFound) {
+			return c.JSON(http.StatusNotFound, ErrorResponse{Error: err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, MessageResponse{Message: "Saved location deleted successfully"})
+}
@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// RequestValidator runs the `validate` struct tags of request bodies, it is registered as the Echo validator
+type RequestValidator struct {
+	validate *validator.Validate
+}
+
+func NewRequestValidator() *RequestValidator {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	// Report fields by their JSON name so clients see the keys they sent
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+	return &RequestValidator{validate: v}
+}
+
+// Validate implements echo.Validator
+func (v *RequestValidator) Validate(i interface{}) error {
+	return v.validate.Struct(i)
+}
+
+// FieldError describes one request field that failed validation
+type FieldError struct {
+	Field string `json:"field" example:"lat"`
+	Rule  string `json:"rule" example:"required"`
+	Param string `json:"param,omitempty" example:"90"`
+}
+
+// ValidationErrorResponse represents a request that failed struct tag validation
+type ValidationErrorResponse struct {
+	Error  string       `json:"error" example:"validation failed"`
+	Fields []FieldError `json:"fields"`
+}
+
+// NewValidationErrorResponse lists the fields that failed validation
+// Errors that do not come from the validator are reported without fields
+func NewValidationErrorResponse(err error) ValidationErrorResponse {
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return ValidationErrorResponse{Error: err.Error(), Fields: []FieldError{}}
+	}
+
+	fields := make([]FieldError, 0, len(validationErrs))
+	for _, fe := range validationErrs {
+		fields = append(fields, FieldError{
+			Field: fe.Field(),
+			Rule:  fe.Tag(),
+			Param: fe.Param(),
+		})
+	}
+	return ValidationErrorResponse{Error: "validation failed", Fields: fields}
+}
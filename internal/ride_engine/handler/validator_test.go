@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func fieldNames(resp ValidationErrorResponse) []string {
+	names := make([]string, 0, len(resp.Fields))
+	for _, f := range resp.Fields {
+		names = append(names, f.Field)
+	}
+	return names
+}
+
+func TestRequestValidator_GetNearbyRidesMissingLatLng(t *testing.T) {
+	v := NewRequestValidator()
+
+	err := v.Validate(&GetNearbyRidesRequest{MaxDistance: 5000})
+	require.Error(t, err)
+
+	resp := NewValidationErrorResponse(err)
+	assert.Equal(t, "validation failed", resp.Error)
+	assert.ElementsMatch(t, []string{"lat", "lng"}, fieldNames(resp))
+	for _, f := range resp.Fields {
+		assert.Equal(t, "required", f.Rule)
+	}
+}
+
+func TestRequestValidator_FindNearestDriversOutOfRange(t *testing.T) {
+	v := NewRequestValidator()
+
+	err := v.Validate(&FindNearestDriversRequest{Latitude: 91, Longitude: 90.4125})
+	require.Error(t, err)
+
+	resp := NewValidationErrorResponse(err)
+	require.Len(t, resp.Fields, 1)
+	assert.Equal(t, FieldError{Field: "latitude", Rule: "max", Param: "90"}, resp.Fields[0])
+}
+
+func TestRequestValidator_ValidRequest(t *testing.T) {
+	v := NewRequestValidator()
+
+	assert.NoError(t, v.Validate(&GetNearbyRidesRequest{Lat: 23.8103, Lng: 90.4125}))
+	assert.NoError(t, v.Validate(&FindNearestDriversRequest{Latitude: 23.8103, Longitude: 90.4125, Radius: 3000}))
+}
+
+func TestNewValidationErrorResponse_NonValidationError(t *testing.T) {
+	resp := NewValidationErrorResponse(errors.New("boom"))
+
+	assert.Equal(t, "boom", resp.Error)
+	assert.Empty(t, resp.Fields)
+}
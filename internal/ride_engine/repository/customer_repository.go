@@ -9,8 +9,10 @@ import (
 type CustomerRepository interface {
 	Create(ctx context.Context, customer *domain.Customer, password string) error
 	GetByID(ctx context.Context, id int64) (*domain.Customer, error)
+	GetByIDWithDeleted(ctx context.Context, id int64) (*domain.Customer, error)     // also finds deleted customers, for ride history
 	GetByEmail(ctx context.Context, email string) (*domain.Customer, string, error) // returns customer and hashed password
 	GetByPhone(ctx context.Context, phone string) (*domain.Customer, error)
 	Update(ctx context.Context, customer *domain.Customer) error
-	Delete(ctx context.Context, id int64) error
+	UpdatePassword(ctx context.Context, id int64, hashedPassword string) error
+	Delete(ctx context.Context, id int64) error // soft-deletes, the customer's rides are kept
 }
@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"context"
+
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+)
+
+// ErrDeviceTokenNotFound is returned when the token is not registered to the user
+var ErrDeviceTokenNotFound = domain.NewError(domain.ErrNotFound, "device token not found")
+
+type DeviceTokenRepository interface {
+	// Upsert registers the token to the user, a token already registered to another user moves to this one
+	Upsert(ctx context.Context, token *domain.DeviceToken) error
+	Delete(ctx context.Context, userRole string, userID int64, token string) error
+	// DeleteByUser removes every token registered to the user, a user without any is not an error
+	DeleteByUser(ctx context.Context, userRole string, userID int64) error
+	ListTokens(ctx context.Context, userRole string, userID int64) ([]string, error)
+}
@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+)
+
+type DriverRepository interface {
+	Create(ctx context.Context, driver *domain.Driver) error
+	GetByID(ctx context.Context, id int64) (*domain.Driver, error)
+	GetByPhone(ctx context.Context, phone string) (*domain.Driver, error)
+	Update(ctx context.Context, driver *domain.Driver) error
+	UpdatePing(ctx context.Context, driverID int64, lat, lng float64, pingTime time.Time) error
+	SetOnlineStatus(ctx context.Context, driverID int64, isOnline bool) error
+	GetOnlineDrivers(ctx context.Context) ([]*domain.Driver, error)
+	MarkOfflineIfInactive(ctx context.Context, cutoff time.Time) error
+}
@@ -0,0 +1,14 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+)
+
+// DriverSessionRepository archives the online sessions drivers completed
+type DriverSessionRepository interface {
+	Create(ctx context.Context, session *domain.DriverSession) error
+	GetByDriverID(ctx context.Context, driverID int64, from, to time.Time) ([]*domain.DriverSession, error) // sessions overlapping [from, to), oldest first
+}
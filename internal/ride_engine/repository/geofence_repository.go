@@ -0,0 +1,12 @@
+package repository
+
+import (
+	"context"
+
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+)
+
+type GeofenceRepository interface {
+	Create(ctx context.Context, geofence *domain.Geofence) error
+	GetActive(ctx context.Context) ([]*domain.Geofence, error)
+}
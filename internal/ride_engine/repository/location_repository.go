@@ -18,8 +18,61 @@ type GeoJSON struct {
 	Coordinates []float64 `bson:"coordinates"` // [longitude, latitude]
 }
 
+// NearbyDriverLocation is a driver's current location with its distance from the searched point
+type NearbyDriverLocation struct {
+	DriverLocation `bson:",inline"`
+	DistanceMeters float64 `bson:"distance"`
+}
+
+// NearestDriver is a recently located driver's distance from the searched point and when their location was last updated
+type NearestDriver struct {
+	DriverID       int64     `bson:"driver_id"`
+	DistanceMeters float64   `bson:"distance"`
+	UpdatedAt      time.Time `bson:"updated_at"`
+}
+
+// GeoBounds is a latitude/longitude bounding box
+type GeoBounds struct {
+	MinLat float64 `json:"min_lat"`
+	MinLng float64 `json:"min_lng"`
+	MaxLat float64 `json:"max_lat"`
+	MaxLng float64 `json:"max_lng"`
+}
+
+// DensityCell is the number of drivers located in one grid cell, identified by its south west corner
+type DensityCell struct {
+	Lat   float64 `json:"lat"`
+	Lng   float64 `json:"lng"`
+	Count int64   `json:"count"`
+}
+
+// DriverLocationPoint is one location reported by a driver, stored in the driver's location history
+type DriverLocationPoint struct {
+	DriverID   int64     `bson:"driver_id"`
+	Location   GeoJSON   `bson:"location"`
+	RecordedAt time.Time `bson:"recorded_at"`
+}
+
+// RideLocation represents a single point of the path travelled during a ride
+type RideLocation struct {
+	RideID     int64     `bson:"ride_id"`
+	DriverID   int64     `bson:"driver_id"`
+	Location   GeoJSON   `bson:"location"`
+	RecordedAt time.Time `bson:"recorded_at"`
+}
+
+// LocationRepository stores driver and ride locations, search radii and distances are in meters
 type LocationRepository interface {
 	UpdateDriverLocation(ctx context.Context, driverID int64, lat, lng float64) error
-	FindNearestDrivers(ctx context.Context, lat, lng float64, maxDistance float64, limit int) ([]int64, error)
+	UpdateDriverLocationBatch(ctx context.Context, driverID int64, points []DriverLocationPoint) error
+	FindNearestDrivers(ctx context.Context, lat, lng float64, maxDistanceMeters float64, limit int) ([]int64, error)
+	FindNearestDriversWithDistance(ctx context.Context, lat, lng float64, maxDistanceMeters float64, limit int) ([]NearestDriver, error)
+	FindNearbyDrivers(ctx context.Context, lat, lng float64, maxDistanceMeters float64, since time.Time, limit int) ([]NearbyDriverLocation, error)
+	GetDriverDensity(ctx context.Context, bounds GeoBounds, gridSize float64, since time.Time) ([]DensityCell, error)
 	GetDriverLocation(ctx context.Context, driverID int64) (lat, lng float64, updatedAt *time.Time, err error)
+	GetDriverLocationHistory(ctx context.Context, driverID int64, since time.Time) ([]DriverLocationPoint, error)
+	SaveRideLocation(ctx context.Context, point RideLocation) error
+	GetRideLocationHistory(ctx context.Context, rideID int64) ([]RideLocation, error)
+	DeleteOldDriverLocations(ctx context.Context, before time.Time) (int64, error)
+	DeleteOldRideLocations(ctx context.Context, before time.Time) (int64, error)
 }
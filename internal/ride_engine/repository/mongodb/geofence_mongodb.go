@@ -0,0 +1,122 @@
+package mongodb
+
+import (
+	"context"
+	"time"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/logger"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+)
+
+// GeofenceDocument represents a geofence in MongoDB
+type GeofenceDocument struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	Name      string             `bson:"name"`
+	Boundary  GeoJSONPolygon     `bson:"boundary"`
+	Active    bool               `bson:"active"`
+	CreatedAt time.Time          `bson:"created_at"`
+}
+
+// GeoJSONPolygon represents a GeoJSON Polygon with a single closed ring of [longitude, latitude] pairs
+type GeoJSONPolygon struct {
+	Type        string        `bson:"type"`
+	Coordinates [][][]float64 `bson:"coordinates"`
+}
+
+type GeofenceMongoRepository struct {
+	collection *mongo.Collection
+}
+
+// NewGeofenceMongoRepository creates a new MongoDB geofence repository
+func NewGeofenceMongoRepository(db *mongo.Database) *GeofenceMongoRepository {
+	collection := db.Collection("geofences")
+
+	activeIndexModel := mongo.IndexModel{
+		Keys: bson.D{{Key: "active", Value: 1}}, // Create index on active for the pickup check
+	}
+	collection.Indexes().CreateOne(context.Background(), activeIndexModel)
+
+	return &GeofenceMongoRepository{
+		collection: collection,
+	}
+}
+
+// Create stores a geofence
+func (r *GeofenceMongoRepository) Create(ctx context.Context, geofence *domain.Geofence) error {
+	if geofence.CreatedAt.IsZero() {
+		geofence.CreatedAt = time.Now()
+	}
+
+	// GeoJSON rings are closed, so the first vertex is repeated at the end
+	ring := make([][]float64, 0, len(geofence.Boundary)+1)
+	for _, point := range geofence.Boundary {
+		ring = append(ring, []float64{point.Longitude, point.Latitude})
+	}
+	ring = append(ring, ring[0])
+
+	doc := &GeofenceDocument{
+		Name:      geofence.Name,
+		Boundary:  GeoJSONPolygon{Type: "Polygon", Coordinates: [][][]float64{ring}},
+		Active:    geofence.Active,
+		CreatedAt: geofence.CreatedAt,
+	}
+
+	result, err := r.collection.InsertOne(ctx, doc)
+	if err != nil {
+		logger.Error(ctx, "Failed to insert geofence", err)
+		return err
+	}
+
+	if id, ok := result.InsertedID.(primitive.ObjectID); ok {
+		geofence.ID = id.Hex()
+	}
+
+	return nil
+}
+
+// GetActive returns every active geofence
+func (r *GeofenceMongoRepository) GetActive(ctx context.Context) ([]*domain.Geofence, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"active": true})
+	if err != nil {
+		logger.Error(ctx, "Failed to find active geofences", err)
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var geofences []*domain.Geofence
+	for cursor.Next(ctx) {
+		var doc GeofenceDocument
+		if err := cursor.Decode(&doc); err != nil {
+			logger.Error(ctx, err)
+			continue
+		}
+		geofences = append(geofences, toGeofenceDomain(&doc))
+	}
+
+	return geofences, nil
+}
+
+// toGeofenceDomain converts a GeofenceDocument to a domain Geofence, dropping the closing vertex of the ring
+func toGeofenceDomain(doc *GeofenceDocument) *domain.Geofence {
+	var boundary []domain.Location
+	if len(doc.Boundary.Coordinates) > 0 {
+		ring := doc.Boundary.Coordinates[0]
+		if len(ring) > 1 {
+			ring = ring[:len(ring)-1]
+		}
+		for _, point := range ring {
+			boundary = append(boundary, domain.Location{Latitude: point[1], Longitude: point[0]})
+		}
+	}
+
+	return &domain.Geofence{
+		ID:        doc.ID.Hex(),
+		Name:      doc.Name,
+		Boundary:  boundary,
+		Active:    doc.Active,
+		CreatedAt: doc.CreatedAt,
+	}
+}
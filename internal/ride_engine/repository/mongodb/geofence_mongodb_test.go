@@ -0,0 +1,40 @@
+package mongodb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+)
+
+func TestGeofenceMongoRepository_CreateAndGetActive(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewGeofenceMongoRepository(db)
+	ctx := context.Background()
+
+	boundary := []domain.Location{
+		{Latitude: 23.77, Longitude: 90.40},
+		{Latitude: 23.77, Longitude: 90.43},
+		{Latitude: 23.81, Longitude: 90.43},
+		{Latitude: 23.81, Longitude: 90.40},
+	}
+	active := &domain.Geofence{Name: "Gulshan", Boundary: boundary, Active: true}
+	require.NoError(t, repo.Create(ctx, active))
+	assert.NotEmpty(t, active.ID)
+	assert.False(t, active.CreatedAt.IsZero())
+
+	inactive := &domain.Geofence{Name: "Uttara", Boundary: boundary, Active: false}
+	require.NoError(t, repo.Create(ctx, inactive))
+
+	geofences, err := repo.GetActive(ctx)
+	require.NoError(t, err)
+	require.Len(t, geofences, 1)
+	assert.Equal(t, active.ID, geofences[0].ID)
+	assert.Equal(t, "Gulshan", geofences[0].Name)
+	assert.Equal(t, boundary, geofences[0].Boundary, "The closing vertex of the ring should be dropped")
+	assert.True(t, geofences[0].Contains(23.7925, 90.4078))
+}
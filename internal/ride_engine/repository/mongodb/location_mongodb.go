@@ -14,7 +14,9 @@ import (
 
 // LocationMongoRepository implements LocationRepository using MongoDB
 type LocationMongoRepository struct {
-	collection *mongo.Collection
+	collection    *mongo.Collection
+	history       *mongo.Collection
+	rideLocations *mongo.Collection
 }
 
 // NewLocationMongoRepository creates a new MongoDB location repository
@@ -26,9 +28,34 @@ func NewLocationMongoRepository(db *mongo.Database) repository.LocationRepositor
 	}
 	collection.Indexes().CreateOne(context.Background(), indexModel)
 
-	return &LocationMongoRepository{collection: collection}
+	history := db.Collection("driver_location_history")
+
+	historyIndexModel := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "driver_id", Value: 1},
+			{Key: "recorded_at", Value: 1}, // Create compound index on driver_id and recorded_at for ordered trail retrieval
+		},
+	}
+	history.Indexes().CreateOne(context.Background(), historyIndexModel)
+
+	rideLocations := db.Collection("ride_locations")
+
+	rideLocationIndexModel := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "ride_id", Value: 1},
+			{Key: "recorded_at", Value: 1}, // Create compound index on ride_id and recorded_at for ordered path retrieval
+		},
+	}
+	rideLocations.Indexes().CreateOne(context.Background(), rideLocationIndexModel)
+
+	return &LocationMongoRepository{
+		collection:    collection,
+		history:       history,
+		rideLocations: rideLocations,
+	}
 }
 
+// UpdateDriverLocation sets the driver's current location and appends it to the location history
 func (r *LocationMongoRepository) UpdateDriverLocation(ctx context.Context, driverID int64, lat, lng float64) error {
 	location := repository.DriverLocation{
 		DriverID: driverID,
@@ -39,6 +66,16 @@ func (r *LocationMongoRepository) UpdateDriverLocation(ctx context.Context, driv
 		UpdatedAt: time.Now(),
 	}
 
+	point := repository.DriverLocationPoint{
+		DriverID:   driverID,
+		Location:   location.Location,
+		RecordedAt: location.UpdatedAt,
+	}
+	if _, err := r.history.InsertOne(ctx, point); err != nil {
+		logger.Error(ctx, err)
+		return err
+	}
+
 	filter := bson.M{"driver_id": driverID}
 	update := bson.M{"$set": location}
 	opts := options.Update().SetUpsert(true)
@@ -52,43 +89,200 @@ func (r *LocationMongoRepository) UpdateDriverLocation(ctx context.Context, driv
 	return nil
 }
 
-func (r *LocationMongoRepository) FindNearestDrivers(ctx context.Context, lat, lng float64, maxDistance float64, limit int) ([]int64, error) {
+// UpdateDriverLocationBatch stores points, ordered oldest first, in the driver's location history
+// The newest point becomes the current location unless a more recent location is already stored
+func (r *LocationMongoRepository) UpdateDriverLocationBatch(ctx context.Context, driverID int64, points []repository.DriverLocationPoint) error {
+	if len(points) == 0 {
+		return nil
+	}
+
+	docs := make([]interface{}, len(points))
+	for i, point := range points {
+		docs[i] = point
+	}
+	if _, err := r.history.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false)); err != nil {
+		logger.Error(ctx, err)
+		return err
+	}
+
+	latest := points[len(points)-1]
+	location := repository.DriverLocation{
+		DriverID:  driverID,
+		Location:  latest.Location,
+		UpdatedAt: latest.RecordedAt,
+	}
+
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"driver_id": driverID, "updated_at": bson.M{"$lt": latest.RecordedAt}},
+		bson.M{"$set": location},
+	)
+	if err != nil {
+		logger.Error(ctx, err)
+		return err
+	}
+	if result.MatchedCount > 0 {
+		return nil
+	}
+
+	// Either the stored location is newer, which is kept, or the driver has none yet
+	_, err = r.collection.UpdateOne(ctx,
+		bson.M{"driver_id": driverID},
+		bson.M{"$setOnInsert": location},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		logger.Error(ctx, err)
+		return err
+	}
+
+	return nil
+}
+
+// FindNearestDrivers returns the IDs of drivers located within maxDistanceMeters in the last 2 minutes, nearest first
+func (r *LocationMongoRepository) FindNearestDrivers(ctx context.Context, lat, lng float64, maxDistanceMeters float64, limit int) ([]int64, error) {
+	nearest, err := r.FindNearestDriversWithDistance(ctx, lat, lng, maxDistanceMeters, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	driverIDs := make([]int64, 0, len(nearest))
+	for _, driver := range nearest {
+		driverIDs = append(driverIDs, driver.DriverID)
+	}
+
+	return driverIDs, nil
+}
+
+// FindNearestDriversWithDistance returns the drivers located within maxDistanceMeters in the last 2 minutes,
+// nearest first, with their distance from the searched point
+func (r *LocationMongoRepository) FindNearestDriversWithDistance(ctx context.Context, lat, lng float64, maxDistanceMeters float64, limit int) ([]repository.NearestDriver, error) {
 	cutoffTime := time.Now().Add(-2 * time.Minute) // Only consider drivers whose location was updated within the last 2 minutes
 
-	filter := bson.M{
-		"location": bson.M{
-			"$nearSphere": bson.M{
-				"$geometry": bson.M{
-					"type":        "Point",
-					"coordinates": []float64{lng, lat},
-				},
-				"$maxDistance": maxDistance, // in meters
+	pipeline := mongo.Pipeline{
+		{{Key: "$geoNear", Value: bson.M{
+			"near": bson.M{
+				"type":        "Point",
+				"coordinates": []float64{lng, lat},
 			},
-		},
+			"distanceField": "distance",        // in meters
+			"maxDistance":   maxDistanceMeters, // in meters
+			"spherical":     true,
+			"query":         bson.M{"updated_at": bson.M{"$gte": cutoffTime}},
+		}}},
+		{{Key: "$limit", Value: limit}},
+	}
 
-		"updated_at": bson.M{
-			"$gte": cutoffTime, // Filter: only include drivers who updated their location within last 2 minutes
-		},
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		logger.Error(ctx, err)
+		return nil, err
 	}
+	defer cursor.Close(ctx)
 
-	cursor, err := r.collection.Find(ctx, filter, options.Find().SetLimit(int64(limit)))
+	var drivers []repository.NearestDriver
+	for cursor.Next(ctx) {
+		var driver repository.NearestDriver
+		if err := cursor.Decode(&driver); err != nil {
+			logger.Error(ctx, err)
+			continue
+		}
+		drivers = append(drivers, driver)
+	}
+
+	return drivers, nil
+}
+
+// FindNearbyDrivers returns the current locations within maxDistanceMeters updated since the given time, nearest first
+func (r *LocationMongoRepository) FindNearbyDrivers(ctx context.Context, lat, lng float64, maxDistanceMeters float64, since time.Time, limit int) ([]repository.NearbyDriverLocation, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$geoNear", Value: bson.M{
+			"near": bson.M{
+				"type":        "Point",
+				"coordinates": []float64{lng, lat},
+			},
+			"distanceField": "distance",        // in meters
+			"maxDistance":   maxDistanceMeters, // in meters
+			"spherical":     true,
+			"query":         bson.M{"updated_at": bson.M{"$gte": since}},
+		}}},
+		{{Key: "$limit", Value: limit}},
+	}
+
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
 	if err != nil {
 		logger.Error(ctx, err)
 		return nil, err
 	}
 	defer cursor.Close(ctx)
 
-	var driverIDs []int64
+	var locations []repository.NearbyDriverLocation
 	for cursor.Next(ctx) {
-		var location repository.DriverLocation
+		var location repository.NearbyDriverLocation
 		if err := cursor.Decode(&location); err != nil {
 			logger.Error(ctx, err)
 			continue
 		}
-		driverIDs = append(driverIDs, location.DriverID)
+		locations = append(locations, location)
 	}
 
-	return driverIDs, nil
+	return locations, nil
+}
+
+// GetDriverDensity counts the drivers located within bounds since the given time per grid cell of gridSize degrees
+// Cells are aligned on the south west corner of bounds and only cells with drivers are returned
+func (r *LocationMongoRepository) GetDriverDensity(ctx context.Context, bounds repository.GeoBounds, gridSize float64, since time.Time) ([]repository.DensityCell, error) {
+	cellIndex := func(field string, min float64) bson.M {
+		return bson.M{"$floor": bson.M{"$divide": bson.A{bson.M{"$subtract": bson.A{field, min}}, gridSize}}}
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"updated_at": bson.M{"$gte": since}}}},
+		{{Key: "$project", Value: bson.M{
+			"lng": bson.M{"$arrayElemAt": bson.A{"$location.coordinates", 0}},
+			"lat": bson.M{"$arrayElemAt": bson.A{"$location.coordinates", 1}},
+		}}},
+		{{Key: "$match", Value: bson.M{
+			"lat": bson.M{"$gte": bounds.MinLat, "$lte": bounds.MaxLat},
+			"lng": bson.M{"$gte": bounds.MinLng, "$lte": bounds.MaxLng},
+		}}},
+		{{Key: "$group", Value: bson.M{
+			"_id": bson.M{
+				"row": cellIndex("$lat", bounds.MinLat),
+				"col": cellIndex("$lng", bounds.MinLng),
+			},
+			"count": bson.M{"$sum": 1},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "_id.row", Value: 1}, {Key: "_id.col", Value: 1}}}},
+	}
+
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		logger.Error(ctx, err)
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var cells []repository.DensityCell
+	for cursor.Next(ctx) {
+		var bucket struct {
+			ID struct {
+				Row float64 `bson:"row"`
+				Col float64 `bson:"col"`
+			} `bson:"_id"`
+			Count int64 `bson:"count"`
+		}
+		if err := cursor.Decode(&bucket); err != nil {
+			logger.Error(ctx, err)
+			continue
+		}
+		cells = append(cells, repository.DensityCell{
+			Lat:   bounds.MinLat + bucket.ID.Row*gridSize,
+			Lng:   bounds.MinLng + bucket.ID.Col*gridSize,
+			Count: bucket.Count,
+		})
+	}
+
+	return cells, nil
 }
 
 func (r *LocationMongoRepository) GetDriverLocation(ctx context.Context, driverID int64) (lat, lng float64, updatedAt *time.Time, err error) {
@@ -112,3 +306,86 @@ func (r *LocationMongoRepository) GetDriverLocation(ctx context.Context, driverI
 
 	return lat, lng, &location.UpdatedAt, nil
 }
+
+// GetDriverLocationHistory returns the driver's locations recorded since the given time, oldest first
+func (r *LocationMongoRepository) GetDriverLocationHistory(ctx context.Context, driverID int64, since time.Time) ([]repository.DriverLocationPoint, error) {
+	filter := bson.M{
+		"driver_id":   driverID,
+		"recorded_at": bson.M{"$gte": since},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "recorded_at", Value: 1}})
+
+	cursor, err := r.history.Find(ctx, filter, opts)
+	if err != nil {
+		logger.Error(ctx, err)
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var points []repository.DriverLocationPoint
+	for cursor.Next(ctx) {
+		var point repository.DriverLocationPoint
+		if err := cursor.Decode(&point); err != nil {
+			logger.Error(ctx, err)
+			continue
+		}
+		points = append(points, point)
+	}
+
+	return points, nil
+}
+
+// SaveRideLocation appends a point to the recorded path of a ride
+func (r *LocationMongoRepository) SaveRideLocation(ctx context.Context, point repository.RideLocation) error {
+	if _, err := r.rideLocations.InsertOne(ctx, point); err != nil {
+		logger.Error(ctx, err)
+		return err
+	}
+	return nil
+}
+
+// GetRideLocationHistory returns the recorded path of a ride ordered by time
+func (r *LocationMongoRepository) GetRideLocationHistory(ctx context.Context, rideID int64) ([]repository.RideLocation, error) {
+	filter := bson.M{"ride_id": rideID}
+	opts := options.Find().SetSort(bson.D{{Key: "recorded_at", Value: 1}})
+
+	cursor, err := r.rideLocations.Find(ctx, filter, opts)
+	if err != nil {
+		logger.Error(ctx, err)
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var points []repository.RideLocation
+	for cursor.Next(ctx) {
+		var point repository.RideLocation
+		if err := cursor.Decode(&point); err != nil {
+			logger.Error(ctx, err)
+			continue
+		}
+		points = append(points, point)
+	}
+
+	return points, nil
+}
+
+// DeleteOldDriverLocations removes driver location history points recorded before the given time
+// The current location of each driver is kept
+func (r *LocationMongoRepository) DeleteOldDriverLocations(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.history.DeleteMany(ctx, bson.M{"recorded_at": bson.M{"$lt": before}})
+	if err != nil {
+		logger.Error(ctx, err)
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+// DeleteOldRideLocations removes ride path points recorded before the given time
+func (r *LocationMongoRepository) DeleteOldRideLocations(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.rideLocations.DeleteMany(ctx, bson.M{"recorded_at": bson.M{"$lt": before}})
+	if err != nil {
+		logger.Error(ctx, err)
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
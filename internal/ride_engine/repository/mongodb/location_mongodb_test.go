@@ -0,0 +1,332 @@
+package mongodb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/repository"
+)
+
+func newLocationPoint(driverID int64, lat, lng float64, recordedAt time.Time) repository.DriverLocationPoint {
+	return repository.DriverLocationPoint{
+		DriverID: driverID,
+		Location: repository.GeoJSON{
+			Type:        "Point",
+			Coordinates: []float64{lng, lat},
+		},
+		RecordedAt: recordedAt,
+	}
+}
+
+func TestLocationMongoRepository_UpdateDriverLocationBatch(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewLocationMongoRepository(db)
+	ctx := context.Background()
+
+	driverID := int64(456)
+	base := time.Now().Add(-10 * time.Minute).Truncate(time.Millisecond)
+	points := []repository.DriverLocationPoint{
+		newLocationPoint(driverID, 23.8100, 90.4120, base),
+		newLocationPoint(driverID, 23.8105, 90.4125, base.Add(time.Minute)),
+		newLocationPoint(driverID, 23.8110, 90.4130, base.Add(2*time.Minute)),
+	}
+
+	err := repo.UpdateDriverLocationBatch(ctx, driverID, points)
+	require.NoError(t, err)
+
+	// Newest point becomes the current location
+	lat, lng, updatedAt, err := repo.GetDriverLocation(ctx, driverID)
+	require.NoError(t, err)
+	assert.Equal(t, 23.8110, lat)
+	assert.Equal(t, 90.4130, lng)
+	assert.True(t, base.Add(2*time.Minute).Equal(*updatedAt))
+
+	// Every point is kept in the history in time order
+	history, err := repo.GetDriverLocationHistory(ctx, driverID, base)
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+	for i := range points {
+		assert.True(t, points[i].RecordedAt.Equal(history[i].RecordedAt))
+		assert.Equal(t, points[i].Location.Coordinates, history[i].Location.Coordinates)
+	}
+}
+
+func TestLocationMongoRepository_UpdateDriverLocationBatch_KeepsNewerCurrentLocation(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewLocationMongoRepository(db)
+	ctx := context.Background()
+
+	driverID := int64(456)
+	err := repo.UpdateDriverLocation(ctx, driverID, 23.8000, 90.4000)
+	require.NoError(t, err)
+
+	// Points buffered before the live ping must not replace it
+	old := time.Now().Add(-time.Hour)
+	err = repo.UpdateDriverLocationBatch(ctx, driverID, []repository.DriverLocationPoint{
+		newLocationPoint(driverID, 23.8100, 90.4120, old),
+	})
+	require.NoError(t, err)
+
+	lat, lng, _, err := repo.GetDriverLocation(ctx, driverID)
+	require.NoError(t, err)
+	assert.Equal(t, 23.8000, lat)
+	assert.Equal(t, 90.4000, lng)
+
+	count, err := db.Collection("driver_locations").CountDocuments(ctx, bson.M{"driver_id": driverID})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count, "Current location should not be duplicated")
+}
+
+func TestLocationMongoRepository_UpdateDriverLocation_RecordsHistory(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewLocationMongoRepository(db)
+	ctx := context.Background()
+
+	driverID := int64(456)
+	otherDriverID := int64(789)
+	since := time.Now().Add(-time.Second)
+
+	const updates = 5
+	for i := 0; i < updates; i++ {
+		err := repo.UpdateDriverLocation(ctx, driverID, 23.8100+float64(i)*0.001, 90.4120)
+		require.NoError(t, err)
+	}
+	err := repo.UpdateDriverLocation(ctx, otherDriverID, 23.7500, 90.3700)
+	require.NoError(t, err)
+
+	history, err := repo.GetDriverLocationHistory(ctx, driverID, since)
+	require.NoError(t, err)
+	require.Len(t, history, updates, "Each update should add one history point")
+	for i, point := range history {
+		assert.Equal(t, driverID, point.DriverID)
+		assert.InDelta(t, 23.8100+float64(i)*0.001, point.Location.Coordinates[1], 1e-9, "Points should be in update order")
+		if i > 0 {
+			assert.False(t, point.RecordedAt.Before(history[i-1].RecordedAt))
+		}
+	}
+
+	// Points before the window are left out
+	history, err = repo.GetDriverLocationHistory(ctx, driverID, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Empty(t, history)
+}
+
+func TestLocationMongoRepository_FindNearbyDrivers(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewLocationMongoRepository(db)
+	ctx := context.Background()
+
+	// 456 is about 60m away, 789 about 1.2km away, 111 is out of range and 222 has not pinged in 10 minutes
+	require.NoError(t, repo.UpdateDriverLocation(ctx, 789, 23.8200, 90.4150))
+	require.NoError(t, repo.UpdateDriverLocation(ctx, 456, 23.8105, 90.4123))
+	require.NoError(t, repo.UpdateDriverLocation(ctx, 111, 23.9000, 90.5000))
+	err := repo.UpdateDriverLocationBatch(ctx, 222, []repository.DriverLocationPoint{
+		newLocationPoint(222, 23.8101, 90.4121, time.Now().Add(-10*time.Minute)),
+	})
+	require.NoError(t, err)
+
+	since := time.Now().Add(-2 * time.Minute)
+	drivers, err := repo.FindNearbyDrivers(ctx, 23.8100, 90.4120, 3000, since, 10)
+	require.NoError(t, err)
+	require.Len(t, drivers, 2)
+
+	assert.Equal(t, int64(456), drivers[0].DriverID, "Nearest driver should come first")
+	assert.InDelta(t, 63, drivers[0].DistanceMeters, 10)
+	assert.Equal(t, []float64{90.4123, 23.8105}, drivers[0].Location.Coordinates)
+	assert.False(t, drivers[0].UpdatedAt.Before(since))
+	assert.Equal(t, int64(789), drivers[1].DriverID)
+	assert.InDelta(t, 1150, drivers[1].DistanceMeters, 50)
+
+	drivers, err = repo.FindNearbyDrivers(ctx, 23.8100, 90.4120, 3000, since, 1)
+	require.NoError(t, err)
+	require.Len(t, drivers, 1)
+	assert.Equal(t, int64(456), drivers[0].DriverID)
+}
+
+func TestLocationMongoRepository_FindNearestDriversWithDistance(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewLocationMongoRepository(db)
+	ctx := context.Background()
+
+	// 789 is about 1.2km away, 456 about 60m, 333 about 500m, 111 is out of range and 222 has not pinged in 10 minutes
+	require.NoError(t, repo.UpdateDriverLocation(ctx, 789, 23.8200, 90.4150))
+	require.NoError(t, repo.UpdateDriverLocation(ctx, 456, 23.8105, 90.4123))
+	require.NoError(t, repo.UpdateDriverLocation(ctx, 333, 23.8145, 90.4120))
+	require.NoError(t, repo.UpdateDriverLocation(ctx, 111, 23.9000, 90.5000))
+	err := repo.UpdateDriverLocationBatch(ctx, 222, []repository.DriverLocationPoint{
+		newLocationPoint(222, 23.8101, 90.4121, time.Now().Add(-10*time.Minute)),
+	})
+	require.NoError(t, err)
+
+	drivers, err := repo.FindNearestDriversWithDistance(ctx, 23.8100, 90.4120, 3000, 10)
+	require.NoError(t, err)
+	require.Len(t, drivers, 3)
+
+	assert.Equal(t, int64(456), drivers[0].DriverID)
+	assert.Equal(t, int64(333), drivers[1].DriverID)
+	assert.Equal(t, int64(789), drivers[2].DriverID)
+	for i := 1; i < len(drivers); i++ {
+		assert.Less(t, drivers[i-1].DistanceMeters, drivers[i].DistanceMeters, "Drivers should be in ascending distance order")
+	}
+	assert.InDelta(t, 63, drivers[0].DistanceMeters, 10)
+	assert.InDelta(t, 500, drivers[1].DistanceMeters, 20)
+	assert.WithinDuration(t, time.Now(), drivers[0].UpdatedAt, time.Minute)
+
+	// The ID-only lookup keeps the same order
+	driverIDs, err := repo.FindNearestDrivers(ctx, 23.8100, 90.4120, 3000, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{456, 333}, driverIDs)
+}
+
+func TestLocationMongoRepository_GetDriverDensity(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewLocationMongoRepository(db)
+	ctx := context.Background()
+
+	// 456 and 789 share the cell at (23.81, 90.41), 111 is in the cell to the east
+	require.NoError(t, repo.UpdateDriverLocation(ctx, 456, 23.8105, 90.4123))
+	require.NoError(t, repo.UpdateDriverLocation(ctx, 789, 23.8190, 90.4101))
+	require.NoError(t, repo.UpdateDriverLocation(ctx, 111, 23.8150, 90.4250))
+	// 222 is outside the bounds and 333 has not pinged in 10 minutes
+	require.NoError(t, repo.UpdateDriverLocation(ctx, 222, 24.5000, 90.4123))
+	err := repo.UpdateDriverLocationBatch(ctx, 333, []repository.DriverLocationPoint{
+		newLocationPoint(333, 23.8101, 90.4121, time.Now().Add(-10*time.Minute)),
+	})
+	require.NoError(t, err)
+
+	bounds := repository.GeoBounds{MinLat: 23.70, MinLng: 90.30, MaxLat: 23.90, MaxLng: 90.50}
+	cells, err := repo.GetDriverDensity(ctx, bounds, 0.01, time.Now().Add(-2*time.Minute))
+	require.NoError(t, err)
+	require.Len(t, cells, 2)
+
+	assert.InDelta(t, 23.81, cells[0].Lat, 1e-9)
+	assert.InDelta(t, 90.41, cells[0].Lng, 1e-9)
+	assert.Equal(t, int64(2), cells[0].Count)
+	assert.InDelta(t, 23.81, cells[1].Lat, 1e-9)
+	assert.InDelta(t, 90.42, cells[1].Lng, 1e-9)
+	assert.Equal(t, int64(1), cells[1].Count)
+
+	// A coarser grid puts every fresh driver in the bounds in one cell
+	cells, err = repo.GetDriverDensity(ctx, bounds, 0.1, time.Now().Add(-2*time.Minute))
+	require.NoError(t, err)
+	require.Len(t, cells, 1)
+	assert.Equal(t, int64(3), cells[0].Count)
+}
+
+func TestLocationMongoRepository_SaveRideLocation(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewLocationMongoRepository(db)
+	ctx := context.Background()
+
+	rideID := int64(1)
+	driverID := int64(456)
+	base := time.Now().Truncate(time.Millisecond)
+
+	// Saved out of order to check the path is returned by time
+	for _, offset := range []time.Duration{2 * time.Minute, 0, time.Minute} {
+		err := repo.SaveRideLocation(ctx, repository.RideLocation{
+			RideID:     rideID,
+			DriverID:   driverID,
+			Location:   repository.GeoJSON{Type: "Point", Coordinates: []float64{90.4120, 23.8100}},
+			RecordedAt: base.Add(offset),
+		})
+		require.NoError(t, err)
+	}
+	err := repo.SaveRideLocation(ctx, repository.RideLocation{
+		RideID:     int64(2),
+		DriverID:   driverID,
+		Location:   repository.GeoJSON{Type: "Point", Coordinates: []float64{90.4120, 23.8100}},
+		RecordedAt: base,
+	})
+	require.NoError(t, err)
+
+	path, err := repo.GetRideLocationHistory(ctx, rideID)
+	require.NoError(t, err)
+	require.Len(t, path, 3)
+	assert.True(t, base.Equal(path[0].RecordedAt))
+	assert.True(t, base.Add(time.Minute).Equal(path[1].RecordedAt))
+	assert.True(t, base.Add(2*time.Minute).Equal(path[2].RecordedAt))
+}
+
+func TestLocationMongoRepository_DeleteOldDriverLocations(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewLocationMongoRepository(db)
+	ctx := context.Background()
+
+	driverID := int64(456)
+	now := time.Now().Truncate(time.Millisecond)
+	cutoff := now.Add(-30 * 24 * time.Hour)
+	points := []repository.DriverLocationPoint{
+		newLocationPoint(driverID, 23.8100, 90.4120, cutoff.Add(-time.Hour)),
+		newLocationPoint(driverID, 23.8105, 90.4125, cutoff.Add(-time.Minute)),
+		newLocationPoint(driverID, 23.8110, 90.4130, now.Add(-time.Hour)),
+	}
+	require.NoError(t, repo.UpdateDriverLocationBatch(ctx, driverID, points))
+
+	deleted, err := repo.DeleteOldDriverLocations(ctx, cutoff)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), deleted)
+
+	// Only the point inside the retention period is left
+	history, err := repo.GetDriverLocationHistory(ctx, driverID, time.Time{})
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.True(t, now.Add(-time.Hour).Equal(history[0].RecordedAt))
+
+	// The current location is not history and survives the purge
+	lat, _, _, err := repo.GetDriverLocation(ctx, driverID)
+	require.NoError(t, err)
+	assert.Equal(t, 23.8110, lat)
+}
+
+func TestLocationMongoRepository_DeleteOldRideLocations(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewLocationMongoRepository(db)
+	ctx := context.Background()
+
+	now := time.Now().Truncate(time.Millisecond)
+	cutoff := now.Add(-30 * 24 * time.Hour)
+	for rideID, recordedAt := range map[int64]time.Time{1: cutoff.Add(-time.Hour), 2: now} {
+		err := repo.SaveRideLocation(ctx, repository.RideLocation{
+			RideID:     rideID,
+			DriverID:   456,
+			Location:   repository.GeoJSON{Type: "Point", Coordinates: []float64{90.4120, 23.8100}},
+			RecordedAt: recordedAt,
+		})
+		require.NoError(t, err)
+	}
+
+	deleted, err := repo.DeleteOldRideLocations(ctx, cutoff)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+
+	oldPath, err := repo.GetRideLocationHistory(ctx, 1)
+	require.NoError(t, err)
+	assert.Empty(t, oldPath)
+
+	freshPath, err := repo.GetRideLocationHistory(ctx, 2)
+	require.NoError(t, err)
+	assert.Len(t, freshPath, 1)
+}
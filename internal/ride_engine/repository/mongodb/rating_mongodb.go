@@ -0,0 +1,126 @@
+package mongodb
+
+import (
+	"context"
+	"time"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/logger"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/repository"
+)
+
+// RatingDocument represents a rating in MongoDB
+type RatingDocument struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	RideID    int64              `bson:"ride_id"`
+	RaterID   int64              `bson:"rater_id"`
+	RaterRole string             `bson:"rater_role"`
+	RateeID   int64              `bson:"ratee_id"`
+	RateeRole string             `bson:"ratee_role"`
+	Stars     int                `bson:"stars"`
+	Comment   string             `bson:"comment,omitempty"`
+	CreatedAt time.Time          `bson:"created_at"`
+}
+
+type RatingMongoRepository struct {
+	collection *mongo.Collection
+}
+
+// NewRatingMongoRepository creates a new MongoDB rating repository
+func NewRatingMongoRepository(db *mongo.Database) *RatingMongoRepository {
+	collection := db.Collection("ratings")
+
+	rideRaterIndexModel := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "ride_id", Value: 1},
+			{Key: "rater_role", Value: 1},
+		},
+		Options: options.Index().SetUnique(true), // Each side of a ride can rate it once
+	}
+
+	rateeIndexModel := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "ratee_id", Value: 1},
+			{Key: "ratee_role", Value: 1}, // Create compound index for average rating lookups
+		},
+	}
+
+	ctx := context.Background()
+	collection.Indexes().CreateOne(ctx, rideRaterIndexModel)
+	collection.Indexes().CreateOne(ctx, rateeIndexModel)
+
+	return &RatingMongoRepository{
+		collection: collection,
+	}
+}
+
+// Create stores a rating, returns ErrRatingAlreadyExists if the rater's side already rated the ride
+func (r *RatingMongoRepository) Create(ctx context.Context, rating *domain.Rating) error {
+	if rating.CreatedAt.IsZero() {
+		rating.CreatedAt = time.Now()
+	}
+
+	doc := &RatingDocument{
+		RideID:    rating.RideID,
+		RaterID:   rating.RaterID,
+		RaterRole: rating.RaterRole,
+		RateeID:   rating.RateeID,
+		RateeRole: rating.RateeRole,
+		Stars:     rating.Stars,
+		Comment:   rating.Comment,
+		CreatedAt: rating.CreatedAt,
+	}
+
+	result, err := r.collection.InsertOne(ctx, doc)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return repository.ErrRatingAlreadyExists
+		}
+		logger.Error(ctx, "Failed to insert rating", err)
+		return err
+	}
+
+	if id, ok := result.InsertedID.(primitive.ObjectID); ok {
+		rating.ID = id.Hex()
+	}
+
+	return nil
+}
+
+// GetAverageForRatee returns the average stars and number of ratings a user has received
+// Returns 0, 0 when the user has not been rated yet
+func (r *RatingMongoRepository) GetAverageForRatee(ctx context.Context, rateeID int64, rateeRole string) (float64, int64, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"ratee_id": rateeID, "ratee_role": rateeRole}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":     nil,
+			"average": bson.M{"$avg": "$stars"},
+			"count":   bson.M{"$sum": 1},
+		}}},
+	}
+
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		logger.Error(ctx, "Failed to aggregate ratings", err)
+		return 0, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var result struct {
+		Average float64 `bson:"average"`
+		Count   int64   `bson:"count"`
+	}
+	if !cursor.Next(ctx) {
+		return 0, 0, cursor.Err()
+	}
+	if err := cursor.Decode(&result); err != nil {
+		logger.Error(ctx, "Failed to decode rating average", err)
+		return 0, 0, err
+	}
+
+	return result.Average, result.Count, nil
+}
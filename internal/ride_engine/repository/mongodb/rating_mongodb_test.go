@@ -0,0 +1,78 @@
+package mongodb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/repository"
+)
+
+func TestRatingMongoRepository_Create(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewRatingMongoRepository(db)
+	ctx := context.Background()
+
+	rating := &domain.Rating{
+		RideID:    1,
+		RaterID:   123,
+		RaterRole: "customer",
+		RateeID:   456,
+		RateeRole: "driver",
+		Stars:     5,
+		Comment:   "Great ride",
+	}
+
+	err := repo.Create(ctx, rating)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, rating.ID, "Rating ID should be set")
+	assert.False(t, rating.CreatedAt.IsZero())
+
+	// Same side cannot rate the ride twice
+	err = repo.Create(ctx, &domain.Rating{RideID: 1, RaterID: 123, RaterRole: "customer", RateeID: 456, RateeRole: "driver", Stars: 1})
+	assert.ErrorIs(t, err, repository.ErrRatingAlreadyExists)
+
+	// The other side can still rate it
+	err = repo.Create(ctx, &domain.Rating{RideID: 1, RaterID: 456, RaterRole: "driver", RateeID: 123, RateeRole: "customer", Stars: 4})
+	assert.NoError(t, err)
+}
+
+func TestRatingMongoRepository_GetAverageForRatee(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewRatingMongoRepository(db)
+	ctx := context.Background()
+
+	driverID := int64(456)
+	for i, stars := range []int{5, 4, 3} {
+		err := repo.Create(ctx, &domain.Rating{
+			RideID:    int64(i + 1),
+			RaterID:   123,
+			RaterRole: "customer",
+			RateeID:   driverID,
+			RateeRole: "driver",
+			Stars:     stars,
+		})
+		require.NoError(t, err)
+	}
+
+	// Rating given by the driver must not count towards their own average
+	err := repo.Create(ctx, &domain.Rating{RideID: 1, RaterID: driverID, RaterRole: "driver", RateeID: 123, RateeRole: "customer", Stars: 1})
+	require.NoError(t, err)
+
+	average, count, err := repo.GetAverageForRatee(ctx, driverID, "driver")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+	assert.InDelta(t, 4.0, average, 0.001)
+
+	// Driver with no ratings
+	average, count, err = repo.GetAverageForRatee(ctx, int64(999), "driver")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+	assert.Equal(t, 0.0, average)
+}
@@ -0,0 +1,111 @@
+package mongodb
+
+import (
+	"context"
+	"time"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/logger"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+)
+
+// RideEventDocument represents a ride status change in MongoDB
+type RideEventDocument struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty"`
+	RideID     int64              `bson:"ride_id"`
+	FromStatus string             `bson:"from_status,omitempty"`
+	ToStatus   string             `bson:"to_status"`
+	ActorRole  string             `bson:"actor_role"`
+	ActorID    int64              `bson:"actor_id,omitempty"`
+	Reason     string             `bson:"reason,omitempty"`
+	OccurredAt time.Time          `bson:"occurred_at"`
+}
+
+type RideEventMongoRepository struct {
+	collection *mongo.Collection
+}
+
+// NewRideEventMongoRepository creates a new MongoDB ride event repository
+func NewRideEventMongoRepository(db *mongo.Database) *RideEventMongoRepository {
+	collection := db.Collection("ride_events")
+
+	rideIndexModel := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "ride_id", Value: 1},
+			{Key: "occurred_at", Value: 1}, // Create compound index for reading a ride's timeline in order
+		},
+	}
+
+	ctx := context.Background()
+	collection.Indexes().CreateOne(ctx, rideIndexModel)
+
+	return &RideEventMongoRepository{
+		collection: collection,
+	}
+}
+
+// Create stores a ride event, inside the ride repository's transaction when ctx carries one
+func (r *RideEventMongoRepository) Create(ctx context.Context, event *domain.RideEvent) error {
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+
+	doc := &RideEventDocument{
+		RideID:     event.RideID,
+		FromStatus: string(event.FromStatus),
+		ToStatus:   string(event.ToStatus),
+		ActorRole:  event.ActorRole,
+		ActorID:    event.ActorID,
+		Reason:     event.Reason,
+		OccurredAt: event.OccurredAt,
+	}
+
+	result, err := r.collection.InsertOne(ctx, doc)
+	if err != nil {
+		logger.Error(ctx, "Failed to insert ride event", err)
+		return err
+	}
+
+	if id, ok := result.InsertedID.(primitive.ObjectID); ok {
+		event.ID = id.Hex()
+	}
+
+	return nil
+}
+
+// GetByRideID retrieves a ride's events, oldest first
+// Events in the same millisecond keep the order they were stored in
+func (r *RideEventMongoRepository) GetByRideID(ctx context.Context, rideID int64) ([]*domain.RideEvent, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "occurred_at", Value: 1}, {Key: "_id", Value: 1}})
+
+	cursor, err := r.collection.Find(ctx, bson.M{"ride_id": rideID}, opts)
+	if err != nil {
+		logger.Error(ctx, "Failed to get ride events", err)
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	events := []*domain.RideEvent{}
+	for cursor.Next(ctx) {
+		var doc RideEventDocument
+		if err := cursor.Decode(&doc); err != nil {
+			logger.Error(ctx, "Failed to decode ride event", err)
+			continue
+		}
+		events = append(events, &domain.RideEvent{
+			ID:         doc.ID.Hex(),
+			RideID:     doc.RideID,
+			FromStatus: domain.RideStatus(doc.FromStatus),
+			ToStatus:   domain.RideStatus(doc.ToStatus),
+			ActorRole:  doc.ActorRole,
+			ActorID:    doc.ActorID,
+			Reason:     doc.Reason,
+			OccurredAt: doc.OccurredAt,
+		})
+	}
+
+	return events, nil
+}
@@ -0,0 +1,45 @@
+package mongodb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+)
+
+func TestRideEventMongoRepository_GetByRideID(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewRideEventMongoRepository(db)
+	ctx := context.Background()
+
+	// Events in the same millisecond stay in the order they were stored
+	at := time.Now().Truncate(time.Millisecond)
+	events := []*domain.RideEvent{
+		{RideID: 1, ToStatus: domain.RideStatusRequested, ActorRole: "customer", ActorID: 123, OccurredAt: at},
+		{RideID: 1, FromStatus: domain.RideStatusRequested, ToStatus: domain.RideStatusAccepted, ActorRole: "driver", ActorID: 456, OccurredAt: at},
+		{RideID: 2, ToStatus: domain.RideStatusRequested, ActorRole: "customer", ActorID: 789, OccurredAt: at},
+		{RideID: 1, FromStatus: domain.RideStatusAccepted, ToStatus: domain.RideStatusCancelled, ActorRole: "customer", ActorID: 123, Reason: "changed my mind", OccurredAt: at.Add(time.Minute)},
+	}
+	for _, event := range events {
+		require.NoError(t, repo.Create(ctx, event))
+		assert.NotEmpty(t, event.ID)
+	}
+
+	found, err := repo.GetByRideID(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, found, 3)
+	assert.Equal(t, []domain.RideStatus{domain.RideStatusRequested, domain.RideStatusAccepted, domain.RideStatusCancelled},
+		[]domain.RideStatus{found[0].ToStatus, found[1].ToStatus, found[2].ToStatus})
+	assert.Equal(t, domain.RideStatus(""), found[0].FromStatus)
+	assert.Equal(t, "changed my mind", found[2].Reason)
+	assert.True(t, at.Equal(found[0].OccurredAt))
+
+	found, err = repo.GetByRideID(ctx, 3)
+	require.NoError(t, err)
+	assert.Empty(t, found)
+}
@@ -2,7 +2,8 @@ package mongodb
 
 import (
 	"context"
-	"errors"
+	"sort"
+	"strings"
 	"time"
 	"vcs.technonext.com/carrybee/ride_engine/pkg/logger"
 
@@ -11,11 +12,15 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/repository"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/pagination"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/utils"
 )
 
-var (
-	ErrRideNotFound = errors.New("ride not found")
-)
+// ErrRideNotFound is kept for callers matching the Mongo error, it is repository.ErrRideNotFound
+var ErrRideNotFound = repository.ErrRideNotFound
+
+var _ repository.RideRepository = (*RideMongoRepository)(nil)
 
 // GeoJSONPoint represents a GeoJSON point for MongoDB geospatial queries
 type GeoJSONPoint struct {
@@ -25,30 +30,56 @@ type GeoJSONPoint struct {
 
 // RideDocument represents a ride in MongoDB
 type RideDocument struct {
-	ID              primitive.ObjectID `bson:"_id,omitempty"`
-	RideID          int64              `bson:"ride_id"`
-	CustomerID      int64              `bson:"customer_id"`
-	DriverID        *int64             `bson:"driver_id,omitempty"`
-	PickupLocation  GeoJSONPoint       `bson:"pickup_location"`
-	DropoffLocation GeoJSONPoint       `bson:"dropoff_location"`
-	PickupLat       float64            `bson:"pickup_lat"`
-	PickupLng       float64            `bson:"pickup_lng"`
-	DropoffLat      float64            `bson:"dropoff_lat"`
-	DropoffLng      float64            `bson:"dropoff_lng"`
-	Status          string             `bson:"status"`
-	Fare            *float64           `bson:"fare,omitempty"`
-	RequestedAt     time.Time          `bson:"requested_at"`
-	AcceptedAt      *time.Time         `bson:"accepted_at,omitempty"`
-	StartedAt       *time.Time         `bson:"started_at,omitempty"`
-	CompletedAt     *time.Time         `bson:"completed_at,omitempty"`
-	CancelledAt     *time.Time         `bson:"cancelled_at,omitempty"`
-	CreatedAt       time.Time          `bson:"created_at"`
-	UpdatedAt       time.Time          `bson:"updated_at"`
+	ID                 primitive.ObjectID    `bson:"_id,omitempty"`
+	RideID             int64                 `bson:"ride_id"`
+	CustomerID         int64                 `bson:"customer_id"`
+	DriverID           *int64                `bson:"driver_id,omitempty"`
+	PickupLocation     GeoJSONPoint          `bson:"pickup_location"`
+	DropoffLocation    GeoJSONPoint          `bson:"dropoff_location"`
+	PickupLat          float64               `bson:"pickup_lat"`
+	PickupLng          float64               `bson:"pickup_lng"`
+	PickupGeohash      string                `bson:"pickup_geohash,omitempty"` // at utils.MaxGeohashPrecision
+	DropoffLat         float64               `bson:"dropoff_lat"`
+	DropoffLng         float64               `bson:"dropoff_lng"`
+	Waypoints          []GeoJSONPoint        `bson:"waypoints,omitempty"`
+	Status             string                `bson:"status"`
+	RideType           string                `bson:"ride_type,omitempty"`
+	Fare               *float64              `bson:"fare,omitempty"`
+	SurgeMultiplier    float64               `bson:"surge_multiplier,omitempty"`
+	PromoCode          string                `bson:"promo_code,omitempty"`
+	Discount           *float64              `bson:"discount,omitempty"`
+	CommissionRate     *float64              `bson:"commission_rate,omitempty"`
+	RequestedAt        time.Time             `bson:"requested_at"`
+	RequeuedAt         *time.Time            `bson:"requeued_at,omitempty"`
+	AcceptedAt         *time.Time            `bson:"accepted_at,omitempty"`
+	ArrivedAt          *time.Time            `bson:"arrived_at,omitempty"`
+	StartedAt          *time.Time            `bson:"started_at,omitempty"`
+	CompletedAt        *time.Time            `bson:"completed_at,omitempty"`
+	CancelledAt        *time.Time            `bson:"cancelled_at,omitempty"`
+	CancelledBy        string                `bson:"cancelled_by,omitempty"`
+	CancellationReason string                `bson:"cancellation_reason,omitempty"`
+	DeclinedBy         []int64               `bson:"declined_by,omitempty"`
+	OfferedDriverID    *int64                `bson:"offered_driver_id,omitempty"`
+	OfferExpiresAt     *time.Time            `bson:"offer_expires_at,omitempty"`
+	Abandonments       []AbandonmentDocument `bson:"abandonments,omitempty"`
+	CreatedAt          time.Time             `bson:"created_at"`
+	UpdatedAt          time.Time             `bson:"updated_at"`
+}
+
+// AbandonmentDocument records a driver cancelling a ride they had accepted
+type AbandonmentDocument struct {
+	DriverID    int64     `bson:"driver_id"`
+	Reason      string    `bson:"reason,omitempty"`
+	AbandonedAt time.Time `bson:"abandoned_at"`
 }
 
+// activeCustomerIndexName is the unique index that allows each customer one ride with a status in activeCustomerRideStatuses
+const activeCustomerIndexName = "customer_id_active_unique"
+
 type RideMongoRepository struct {
-	collection *mongo.Collection
-	db         *mongo.Database
+	collection   *mongo.Collection
+	db           *mongo.Database
+	transactions bool // whether the deployment supports multi-document transactions
 }
 
 // NewRideMongoRepository creates a new MongoDB ride repository
@@ -71,6 +102,14 @@ func NewRideMongoRepository(db *mongo.Database) *RideMongoRepository {
 		Keys: bson.D{{Key: "customer_id", Value: 1}}, // Create index on customer_id
 	}
 
+	activeCustomerIndexModel := mongo.IndexModel{
+		Keys: bson.D{{Key: "customer_id", Value: 1}},
+		Options: options.Index().
+			SetName(activeCustomerIndexName).
+			SetUnique(true).
+			SetPartialFilterExpression(bson.M{"status": bson.M{"$in": activeCustomerRideStatuses}}), // Create unique index allowing each customer one active ride
+	}
+
 	driverIndexModel := mongo.IndexModel{
 		Keys: bson.D{{Key: "driver_id", Value: 1}}, // Create index on driver_id
 	}
@@ -82,6 +121,21 @@ func NewRideMongoRepository(db *mongo.Database) *RideMongoRepository {
 		},
 	}
 
+	nearbyIndexModel := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "pickup_location", Value: "2dsphere"},
+			{Key: "status", Value: 1},
+			{Key: "driver_id", Value: 1}, // Create compound index covering the nearby polling filter on unassigned waiting rides
+		},
+	}
+
+	geohashIndexModel := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "pickup_geohash", Value: 1},
+			{Key: "status", Value: 1}, // Create index for the geohash prefix ranges of nearby polling
+		},
+	}
+
 	rideIDIndexModel := mongo.IndexModel{
 		Keys:    bson.D{{Key: "ride_id", Value: 1}},
 		Options: options.Index().SetUnique(true), // Create unique index on ride_id for auto-increment simulation
@@ -95,12 +149,57 @@ func NewRideMongoRepository(db *mongo.Database) *RideMongoRepository {
 	collection.Indexes().CreateOne(ctx, customerIndexModel)
 	collection.Indexes().CreateOne(ctx, driverIndexModel)
 	collection.Indexes().CreateOne(ctx, compoundIndexModel)
+	collection.Indexes().CreateOne(ctx, nearbyIndexModel)
+	collection.Indexes().CreateOne(ctx, geohashIndexModel)
 	collection.Indexes().CreateOne(ctx, rideIDIndexModel)
+	if _, err := collection.Indexes().CreateOne(ctx, activeCustomerIndexModel); err != nil {
+		logger.Warn("Failed to create the one active ride per customer index, concurrent ride requests of a customer may both succeed: ", err)
+	}
+
+	transactions := supportsTransactions(ctx, db)
+	if !transactions {
+		logger.Warn("MongoDB is not a replica set or sharded cluster, ride writes will run without transactions")
+	}
 
 	return &RideMongoRepository{
-		collection: collection,
-		db:         db,
+		collection:   collection,
+		db:           db,
+		transactions: transactions,
+	}
+}
+
+// supportsTransactions reports whether the server is a replica set member or a mongos, standalone servers have no transactions
+func supportsTransactions(ctx context.Context, db *mongo.Database) bool {
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
 	}
+	if err := db.RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err != nil {
+		logger.Error(ctx, "Failed to check MongoDB transaction support", err)
+		return false
+	}
+	return hello.SetName != "" || hello.Msg == "isdbgrid"
+}
+
+// WithTransaction runs fn in a transaction on a session of the repository's client
+// fn must use the ctx it is given for its writes to be part of the transaction, it may be retried on transient errors
+// On a standalone server, which has no transactions, fn runs on its own and its writes are not rolled back
+func (r *RideMongoRepository) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if !r.transactions {
+		return fn(ctx)
+	}
+
+	session, err := r.db.Client().StartSession()
+	if err != nil {
+		logger.Error(ctx, "Failed to start session", err)
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessionCtx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessionCtx)
+	})
+	return err
 }
 
 // getNextRideID generates next sequence ID for ride_id
@@ -139,18 +238,33 @@ func toRideDocument(ride *domain.Ride) *RideDocument {
 			Type:        "Point",
 			Coordinates: []float64{ride.DropoffLng, ride.DropoffLat},
 		},
-		PickupLat:   ride.PickupLat,
-		PickupLng:   ride.PickupLng,
-		DropoffLat:  ride.DropoffLat,
-		DropoffLng:  ride.DropoffLng,
-		Status:      string(ride.Status),
-		Fare:        ride.Fare,
-		RequestedAt: ride.RequestedAt,
-		AcceptedAt:  ride.AcceptedAt,
-		StartedAt:   ride.StartedAt,
-		CompletedAt: ride.CompletedAt,
-		CancelledAt: ride.CancelledAt,
-		UpdatedAt:   now,
+		PickupLat:          ride.PickupLat,
+		PickupLng:          ride.PickupLng,
+		PickupGeohash:      utils.Geohash(ride.PickupLat, ride.PickupLng, utils.MaxGeohashPrecision),
+		DropoffLat:         ride.DropoffLat,
+		DropoffLng:         ride.DropoffLng,
+		Waypoints:          toWaypointPoints(ride.Waypoints),
+		Status:             string(ride.Status),
+		RideType:           string(ride.RideType),
+		Fare:               ride.Fare,
+		SurgeMultiplier:    ride.SurgeMultiplier,
+		PromoCode:          ride.PromoCode,
+		Discount:           ride.Discount,
+		CommissionRate:     ride.CommissionRate,
+		RequestedAt:        ride.RequestedAt,
+		RequeuedAt:         ride.RequeuedAt,
+		AcceptedAt:         ride.AcceptedAt,
+		ArrivedAt:          ride.ArrivedAt,
+		StartedAt:          ride.StartedAt,
+		CompletedAt:        ride.CompletedAt,
+		CancelledAt:        ride.CancelledAt,
+		CancelledBy:        ride.CancelledBy,
+		CancellationReason: ride.CancellationReason,
+		DeclinedBy:         ride.DeclinedBy,
+		OfferedDriverID:    ride.OfferedDriverID,
+		OfferExpiresAt:     ride.OfferExpiresAt,
+		Abandonments:       toAbandonmentDocuments(ride.Abandonments),
+		UpdatedAt:          now,
 	}
 
 	if doc.RideID == 0 {
@@ -161,26 +275,109 @@ func toRideDocument(ride *domain.Ride) *RideDocument {
 }
 
 // toRideDomain converts RideDocument to domain.Ride
+// Rides stored before ride types existed are read as economy
 func toRideDomain(doc *RideDocument) *domain.Ride {
+	rideType := domain.RideType(doc.RideType)
+	if rideType == "" {
+		rideType = domain.RideTypeEconomy
+	}
+
 	return &domain.Ride{
-		ID:          doc.RideID,
-		CustomerID:  doc.CustomerID,
-		DriverID:    doc.DriverID,
-		PickupLat:   doc.PickupLat,
-		PickupLng:   doc.PickupLng,
-		DropoffLat:  doc.DropoffLat,
-		DropoffLng:  doc.DropoffLng,
-		Status:      domain.RideStatus(doc.Status),
-		Fare:        doc.Fare,
-		RequestedAt: doc.RequestedAt,
-		AcceptedAt:  doc.AcceptedAt,
-		StartedAt:   doc.StartedAt,
-		CompletedAt: doc.CompletedAt,
-		CancelledAt: doc.CancelledAt,
+		ID:                 doc.RideID,
+		CustomerID:         doc.CustomerID,
+		DriverID:           doc.DriverID,
+		PickupLat:          doc.PickupLat,
+		PickupLng:          doc.PickupLng,
+		DropoffLat:         doc.DropoffLat,
+		DropoffLng:         doc.DropoffLng,
+		Waypoints:          toWaypointLocations(doc.Waypoints),
+		Status:             domain.RideStatus(doc.Status),
+		RideType:           rideType,
+		Fare:               doc.Fare,
+		SurgeMultiplier:    doc.SurgeMultiplier,
+		PromoCode:          doc.PromoCode,
+		Discount:           doc.Discount,
+		CommissionRate:     doc.CommissionRate,
+		RequestedAt:        doc.RequestedAt,
+		RequeuedAt:         doc.RequeuedAt,
+		AcceptedAt:         doc.AcceptedAt,
+		ArrivedAt:          doc.ArrivedAt,
+		StartedAt:          doc.StartedAt,
+		CompletedAt:        doc.CompletedAt,
+		CancelledAt:        doc.CancelledAt,
+		CancelledBy:        doc.CancelledBy,
+		CancellationReason: doc.CancellationReason,
+		OfferedDriverID:    doc.OfferedDriverID,
+		OfferExpiresAt:     doc.OfferExpiresAt,
+		DeclinedBy:         doc.DeclinedBy,
+		Abandonments:       toRideAbandonments(doc.Abandonments),
+	}
+}
+
+func toAbandonmentDocuments(abandonments []domain.RideAbandonment) []AbandonmentDocument {
+	if len(abandonments) == 0 {
+		return nil
+	}
+	docs := make([]AbandonmentDocument, 0, len(abandonments))
+	for _, abandonment := range abandonments {
+		docs = append(docs, toAbandonmentDocument(abandonment))
+	}
+	return docs
+}
+
+func toAbandonmentDocument(abandonment domain.RideAbandonment) AbandonmentDocument {
+	return AbandonmentDocument{
+		DriverID:    abandonment.DriverID,
+		Reason:      abandonment.Reason,
+		AbandonedAt: abandonment.AbandonedAt,
+	}
+}
+
+func toRideAbandonments(docs []AbandonmentDocument) []domain.RideAbandonment {
+	if len(docs) == 0 {
+		return nil
+	}
+	abandonments := make([]domain.RideAbandonment, 0, len(docs))
+	for _, doc := range docs {
+		abandonments = append(abandonments, domain.RideAbandonment{
+			DriverID:    doc.DriverID,
+			Reason:      doc.Reason,
+			AbandonedAt: doc.AbandonedAt,
+		})
 	}
+	return abandonments
+}
+
+func toWaypointPoints(waypoints []domain.Location) []GeoJSONPoint {
+	if len(waypoints) == 0 {
+		return nil
+	}
+	points := make([]GeoJSONPoint, 0, len(waypoints))
+	for _, waypoint := range waypoints {
+		points = append(points, GeoJSONPoint{
+			Type:        "Point",
+			Coordinates: []float64{waypoint.Longitude, waypoint.Latitude},
+		})
+	}
+	return points
+}
+
+func toWaypointLocations(points []GeoJSONPoint) []domain.Location {
+	if len(points) == 0 {
+		return nil
+	}
+	waypoints := make([]domain.Location, 0, len(points))
+	for _, point := range points {
+		if len(point.Coordinates) < 2 {
+			continue
+		}
+		waypoints = append(waypoints, domain.Location{Latitude: point.Coordinates[1], Longitude: point.Coordinates[0]})
+	}
+	return waypoints
 }
 
 // Create creates a new ride in MongoDB
+// Returns ErrCustomerHasActiveRide when the ride is active and the customer already has an active ride
 func (r *RideMongoRepository) Create(ctx context.Context, ride *domain.Ride) error {
 	rideID, err := r.getNextRideID(ctx)
 	if err != nil {
@@ -193,6 +390,9 @@ func (r *RideMongoRepository) Create(ctx context.Context, ride *domain.Ride) err
 
 	_, err = r.collection.InsertOne(ctx, doc)
 	if err != nil {
+		if mongo.IsDuplicateKeyError(err) && strings.Contains(err.Error(), activeCustomerIndexName) {
+			return repository.ErrCustomerHasActiveRide
+		}
 		logger.Error(ctx, "Failed to insert ride", err)
 		return err
 	}
@@ -217,21 +417,83 @@ func (r *RideMongoRepository) GetByID(ctx context.Context, id int64) (*domain.Ri
 	return toRideDomain(&doc), nil
 }
 
-// Update updates an existing ride
-func (r *RideMongoRepository) Update(ctx context.Context, ride *domain.Ride) error {
+// activeDriverRideStatuses are the statuses of a ride the driver is busy with
+var activeDriverRideStatuses = []string{string(domain.RideStatusAccepted), string(domain.RideStatusArrived), string(domain.RideStatusStarted)}
+
+// activeCustomerRideStatuses are the statuses of a ride the customer is still waiting for or riding in
+var activeCustomerRideStatuses = []string{
+	string(domain.RideStatusRequested), string(domain.RideStatusPending),
+	string(domain.RideStatusAccepted), string(domain.RideStatusArrived), string(domain.RideStatusStarted),
+}
+
+// GetActiveRideByCustomerID retrieves the customer's latest ride that has not completed or been cancelled
+// Returns ErrRideNotFound when the customer has no active ride
+func (r *RideMongoRepository) GetActiveRideByCustomerID(ctx context.Context, customerID int64) (*domain.Ride, error) {
+	var doc RideDocument
+
+	filter := bson.M{
+		"customer_id": customerID,
+		"status":      bson.M{"$in": activeCustomerRideStatuses},
+	}
+	opts := options.FindOne().SetSort(bson.D{{Key: "requested_at", Value: -1}})
+
+	err := r.collection.FindOne(ctx, filter, opts).Decode(&doc)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrRideNotFound
+		}
+		logger.Error(ctx, "Failed to get active ride for customer", err)
+		return nil, err
+	}
+
+	return toRideDomain(&doc), nil
+}
+
+// GetActiveRideByDriverID retrieves the ride the driver has accepted, arrived at or started
+// Returns ErrRideNotFound when the driver has no active ride
+func (r *RideMongoRepository) GetActiveRideByDriverID(ctx context.Context, driverID int64) (*domain.Ride, error) {
+	var doc RideDocument
+
+	filter := bson.M{
+		"driver_id": driverID,
+		"status":    bson.M{"$in": activeDriverRideStatuses},
+	}
+	opts := options.FindOne().SetSort(bson.D{{Key: "accepted_at", Value: -1}})
+
+	err := r.collection.FindOne(ctx, filter, opts).Decode(&doc)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrRideNotFound
+		}
+		logger.Error(ctx, "Failed to get active ride for driver", err)
+		return nil, err
+	}
+
+	return toRideDomain(&doc), nil
+}
+
+// Update writes the ride's status change from status from, the status it had when it was read
+// Returns repository.ErrRideStatusChanged when the ride no longer has that status, e.g. because a driver accepted it meanwhile,
+// so a stale ride never overwrites a newer change
+func (r *RideMongoRepository) Update(ctx context.Context, ride *domain.Ride, from domain.RideStatus) error {
 	doc := toRideDocument(ride)
 
-	filter := bson.M{"ride_id": ride.ID}
+	filter := bson.M{"ride_id": ride.ID, "status": string(from)}
 	update := bson.M{
 		"$set": bson.M{
-			"driver_id":    doc.DriverID,
-			"status":       doc.Status,
-			"fare":         doc.Fare,
-			"accepted_at":  doc.AcceptedAt,
-			"started_at":   doc.StartedAt,
-			"completed_at": doc.CompletedAt,
-			"cancelled_at": doc.CancelledAt,
-			"updated_at":   time.Now(),
+			"driver_id":           doc.DriverID,
+			"status":              doc.Status,
+			"fare":                doc.Fare,
+			"discount":            doc.Discount,
+			"commission_rate":     doc.CommissionRate,
+			"accepted_at":         doc.AcceptedAt,
+			"arrived_at":          doc.ArrivedAt,
+			"started_at":          doc.StartedAt,
+			"completed_at":        doc.CompletedAt,
+			"cancelled_at":        doc.CancelledAt,
+			"cancelled_by":        doc.CancelledBy,
+			"cancellation_reason": doc.CancellationReason,
+			"updated_at":          time.Now(),
 		},
 	}
 
@@ -242,7 +504,7 @@ func (r *RideMongoRepository) Update(ctx context.Context, ride *domain.Ride) err
 	}
 
 	if result.MatchedCount == 0 {
-		return ErrRideNotFound
+		return repository.ErrRideStatusChanged
 	}
 
 	return nil
@@ -275,35 +537,364 @@ func (r *RideMongoRepository) GetRequestedRides(ctx context.Context) ([]*domain.
 
 // GetNearbyRequestedRides retrieves rides within a certain radius using geospatial query
 // This is the key method for driver polling - finds available rides near driver's location
-// Filters: status in ["requested", "pending"], updated within last 5 minutes, within radius
-// Params: lat, lng (driver location), maxDistanceMeters (search radius), limit (max results)
-func (r *RideMongoRepository) GetNearbyRequestedRides(ctx context.Context, lat, lng, maxDistanceMeters float64, limit int) ([]*domain.Ride, error) {
+// Filters: status in ["requested", "pending"], no driver assigned, updated within freshness, within radius, not declined by the driver, matching the driver's vehicle type,
+// currently offered to the driver unless driverID is 0
+// Params: driverID (polling driver), rideType (driver's vehicle type), lat, lng (driver location), maxDistanceMeters (search radius), freshness (max age of the last update), limit (max results)
+func (r *RideMongoRepository) GetNearbyRequestedRides(ctx context.Context, driverID int64, rideType domain.RideType, lat, lng, maxDistanceMeters float64, freshness time.Duration, limit int) ([]*domain.Ride, error) {
+	filter := nearbyRequestedRidesFilter(driverID, rideType, freshness)
+	filter["pickup_location"] = bson.M{
+		"$nearSphere": bson.M{
+			"$geometry": bson.M{
+				"type":        "Point",
+				"coordinates": []float64{lng, lat},
+			},
+			"$maxDistance": maxDistanceMeters, // in meters
+		},
+	}
+
+	opts := options.Find().SetLimit(int64(limit))
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		logger.Error(ctx, "Failed to get nearby requested rides", err)
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var rides []*domain.Ride
+	for cursor.Next(ctx) {
+		var doc RideDocument
+		if err := cursor.Decode(&doc); err != nil {
+			logger.Error(ctx, "Failed to decode ride", err)
+			continue
+		}
+		rides = append(rides, toRideDomain(&doc))
+	}
+
+	return rides, nil
+}
 
-	cutoffTime := time.Now().Add(-5 * time.Minute) // Calculate cutoff time (5 minutes ago)
+// GetNearbyRequestedRidesByGeohash finds the same rides as GetNearbyRequestedRides without a geospatial query
+// Rides are prefiltered to the pickup geohash cells around the driver, using cells at least maxDistanceMeters across,
+// then the distance to each is computed here to drop the ones out of range and order the rest nearest first
+// Rides stored before pickup geohashes were recorded are not found, they fall out of the freshness window soon after
+func (r *RideMongoRepository) GetNearbyRequestedRidesByGeohash(ctx context.Context, driverID int64, rideType domain.RideType, lat, lng, maxDistanceMeters float64, freshness time.Duration, limit int) ([]*domain.Ride, error) {
+	cells := utils.GeohashNeighborhood(lat, lng, utils.GeohashPrecisionForRadius(lat, maxDistanceMeters))
+	cellRanges := make(bson.A, 0, len(cells))
+	for _, cell := range cells {
+		// Geohashes inside a cell all start with it, "{" sorts right after the last base32 character "z"
+		cellRanges = append(cellRanges, bson.M{"pickup_geohash": bson.M{"$gte": cell, "$lt": cell + "{"}})
+	}
+
+	filter := nearbyRequestedRidesFilter(driverID, rideType, freshness)
+	filter["$or"] = cellRanges
+
+	cursor, err := r.collection.Find(ctx, filter)
+	if err != nil {
+		logger.Error(ctx, "Failed to get nearby requested rides by geohash", err)
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	origin := domain.Location{Latitude: lat, Longitude: lng}
+	var rides []*domain.Ride
+	distances := make(map[int64]float64)
+	for cursor.Next(ctx) {
+		var doc RideDocument
+		if err := cursor.Decode(&doc); err != nil {
+			logger.Error(ctx, "Failed to decode ride", err)
+			continue
+		}
+		distance := origin.DistanceTo(domain.Location{Latitude: doc.PickupLat, Longitude: doc.PickupLng})
+		if distance > maxDistanceMeters {
+			continue
+		}
+		distances[doc.RideID] = distance
+		rides = append(rides, toRideDomain(&doc))
+	}
+
+	sort.SliceStable(rides, func(i, j int) bool {
+		return distances[rides[i].ID] < distances[rides[j].ID]
+	})
+	if limit > 0 && len(rides) > limit {
+		rides = rides[:limit]
+	}
+
+	return rides, nil
+}
+
+// nearbyRequestedRidesFilter matches the rides currently offered to a polling driver, before the distance filter
+// A driverID of 0 matches every waiting ride whoever it is offered to, e.g. to measure demand
+func nearbyRequestedRidesFilter(driverID int64, rideType domain.RideType, freshness time.Duration) bson.M {
+	now := time.Now()
+	cutoffTime := now.Add(-freshness)
+
+	var rideTypeFilter interface{} = string(rideType)
+	if rideType == domain.RideTypeEconomy {
+		rideTypeFilter = bson.M{"$in": bson.A{string(rideType), nil}} // Rides without a ride_type predate ride types and are economy
+	}
 
 	filter := bson.M{
 		"status": bson.M{
 			"$in": []string{"requested", "pending"}, // Support both requested and pending status
 		},
+		"driver_id": nil, // Skip rides that already have a driver, e.g. re-requested after an accept
 		"updated_at": bson.M{
 			"$gte": cutoffTime,
 		},
-		"pickup_location": bson.M{
-			"$nearSphere": bson.M{
-				"$geometry": bson.M{
-					"type":        "Point",
-					"coordinates": []float64{lng, lat},
-				},
-				"$maxDistance": maxDistanceMeters, // in meters
-			},
+		"declined_by": bson.M{
+			"$ne": driverID, // Skip rides this driver already declined
 		},
+		"ride_type": rideTypeFilter,
+	}
+	if driverID != 0 {
+		// Filtered here rather than after the limit so other drivers' offers do not crowd out this driver's
+		filter["offered_driver_id"] = driverID
+		filter["offer_expires_at"] = bson.M{"$gt": now}
 	}
+	return filter
+}
 
-	opts := options.Find().SetLimit(int64(limit))
+// AddDeclinedDriver records that a driver declined the ride so it is no longer offered to them
+func (r *RideMongoRepository) AddDeclinedDriver(ctx context.Context, rideID, driverID int64) error {
+	filter := bson.M{"ride_id": rideID}
+	update := bson.M{
+		"$addToSet": bson.M{"declined_by": driverID},
+	}
+
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		logger.Error(ctx, "Failed to add declined driver", err)
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return ErrRideNotFound
+	}
+
+	return nil
+}
+
+// AcceptRide assigns the driver to a ride that is still waiting for one
+// The status is checked in the same update, so of several drivers accepting at once only the first succeeds
+// and the others get repository.ErrRideNotAcceptable
+func (r *RideMongoRepository) AcceptRide(ctx context.Context, rideID, driverID int64, acceptedAt time.Time) error {
+	filter := bson.M{
+		"ride_id": rideID,
+		"status": bson.M{
+			"$in": []string{string(domain.RideStatusRequested), string(domain.RideStatusPending)},
+		},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"driver_id":   driverID,
+			"status":      string(domain.RideStatusAccepted),
+			"accepted_at": acceptedAt,
+			"updated_at":  time.Now(),
+		},
+	}
+
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		logger.Error(ctx, "Failed to accept ride", err)
+		return err
+	}
+
+	if result.ModifiedCount == 0 {
+		return repository.ErrRideNotAcceptable
+	}
+
+	return nil
+}
+
+// AbandonRide returns a ride accepted by abandonment.DriverID to requested so it is dispatched again
+// The driver is unassigned, recorded in the ride's abandonments and added to declined_by so the ride is not offered to them again
+// Returns repository.ErrRideNotAbandonable when the ride is no longer accepted by that driver, e.g. because it was started
+func (r *RideMongoRepository) AbandonRide(ctx context.Context, rideID int64, abandonment domain.RideAbandonment) error {
+	filter := bson.M{
+		"ride_id": rideID,
+		"status": bson.M{
+			"$in": []string{string(domain.RideStatusAccepted), string(domain.RideStatusArrived)},
+		},
+		"driver_id": abandonment.DriverID,
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"status":      string(domain.RideStatusRequested),
+			"requeued_at": abandonment.AbandonedAt, // the request timeout starts over
+			"updated_at":  time.Now(),
+		},
+		"$unset": bson.M{
+			"driver_id":         "",
+			"accepted_at":       "",
+			"arrived_at":        "",
+			"offered_driver_id": "",
+			"offer_expires_at":  "", // dispatched again right away
+		},
+		"$push":     bson.M{"abandonments": toAbandonmentDocument(abandonment)},
+		"$addToSet": bson.M{"declined_by": abandonment.DriverID},
+	}
+
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		logger.Error(ctx, "Failed to abandon ride", err)
+		return err
+	}
+
+	if result.ModifiedCount == 0 {
+		return repository.ErrRideNotAbandonable
+	}
+
+	return nil
+}
+
+// SetRideOffer offers a ride still waiting for a driver to driverID until expiresAt
+// A nil driverID withdraws the offer and leaves the ride to be dispatched again after expiresAt
+// Returns ErrRideNotFound when the ride no longer exists or was accepted or cancelled in the meantime
+func (r *RideMongoRepository) SetRideOffer(ctx context.Context, rideID int64, driverID *int64, expiresAt time.Time) error {
+	filter := bson.M{
+		"ride_id": rideID,
+		"status": bson.M{
+			"$in": []string{string(domain.RideStatusRequested), string(domain.RideStatusPending)},
+		},
+	}
+	// The ride is pending while it is offered to a driver and requested again once the offer is withdrawn
+	status := domain.RideStatusRequested
+	if driverID != nil {
+		status = domain.RideStatusPending
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"status":            string(status),
+			"offered_driver_id": driverID,
+			"offer_expires_at":  expiresAt,
+			"updated_at":        time.Now(),
+		},
+	}
+
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		logger.Error(ctx, "Failed to set ride offer", err)
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return ErrRideNotFound
+	}
+
+	return nil
+}
+
+// GetRidesAwaitingDispatch retrieves rides waiting for a driver whose offer expired at now or that were never offered
+func (r *RideMongoRepository) GetRidesAwaitingDispatch(ctx context.Context, now time.Time) ([]*domain.Ride, error) {
+	filter := bson.M{
+		"status": bson.M{
+			"$in": []string{string(domain.RideStatusRequested), string(domain.RideStatusPending)},
+		},
+		"$or": bson.A{
+			bson.M{"offer_expires_at": bson.M{"$lte": now}},
+			bson.M{"offer_expires_at": nil}, // matches rides requested before dispatch existed too
+		},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "requested_at", Value: 1}})
 
 	cursor, err := r.collection.Find(ctx, filter, opts)
 	if err != nil {
-		logger.Error(ctx, "Failed to get nearby requested rides", err)
+		logger.Error(ctx, "Failed to get rides awaiting dispatch", err)
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var rides []*domain.Ride
+	for cursor.Next(ctx) {
+		var doc RideDocument
+		if err := cursor.Decode(&doc); err != nil {
+			logger.Error(ctx, "Failed to decode ride", err)
+			continue
+		}
+		rides = append(rides, toRideDomain(&doc))
+	}
+
+	return rides, nil
+}
+
+// staleRequestedRidesFilter matches rides still in "requested" or "pending" status that were requested, or last requeued, before cutoff
+func staleRequestedRidesFilter(cutoff time.Time) bson.M {
+	return bson.M{
+		"status": bson.M{
+			"$in": []string{string(domain.RideStatusRequested), string(domain.RideStatusPending)},
+		},
+		// A ride a driver abandoned waits from when it went back to requested, not from the original request
+		"$or": []bson.M{
+			{"requeued_at": bson.M{"$lt": cutoff}},
+			{"requeued_at": nil, "requested_at": bson.M{"$lt": cutoff}},
+		},
+	}
+}
+
+// GetStaleRequestedRides retrieves rides still waiting for a driver that were requested, or last requeued, before cutoff, oldest first
+func (r *RideMongoRepository) GetStaleRequestedRides(ctx context.Context, cutoff time.Time) ([]*domain.Ride, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "requested_at", Value: 1}})
+
+	cursor, err := r.collection.Find(ctx, staleRequestedRidesFilter(cutoff), opts)
+	if err != nil {
+		logger.Error(ctx, "Failed to get stale requested rides", err)
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var rides []*domain.Ride
+	for cursor.Next(ctx) {
+		var doc RideDocument
+		if err := cursor.Decode(&doc); err != nil {
+			logger.Error(ctx, "Failed to decode ride", err)
+			continue
+		}
+		rides = append(rides, toRideDomain(&doc))
+	}
+
+	return rides, nil
+}
+
+// ExpireRide saves the cancellation of a ride no driver accepted in time, its cancelled fields are taken from ride
+// Returns ErrRideNotExpirable when the ride was accepted, cancelled or requeued since it was found stale at cutoff
+func (r *RideMongoRepository) ExpireRide(ctx context.Context, ride *domain.Ride, cutoff time.Time) error {
+	filter := staleRequestedRidesFilter(cutoff)
+	filter["ride_id"] = ride.ID
+	update := bson.M{
+		"$set": bson.M{
+			"status":              string(ride.Status),
+			"cancelled_by":        ride.CancelledBy,
+			"cancellation_reason": ride.CancellationReason,
+			"cancelled_at":        ride.CancelledAt,
+			"updated_at":          time.Now(),
+		},
+	}
+
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		logger.Error(ctx, "Failed to expire ride", err)
+		return err
+	}
+
+	if result.ModifiedCount == 0 {
+		return repository.ErrRideNotExpirable
+	}
+
+	return nil
+}
+
+// GetStaleAcceptedRides retrieves rides accepted before cutoff that the driver has neither arrived at nor started, oldest first
+func (r *RideMongoRepository) GetStaleAcceptedRides(ctx context.Context, cutoff time.Time) ([]*domain.Ride, error) {
+	filter := bson.M{
+		"status":      string(domain.RideStatusAccepted),
+		"accepted_at": bson.M{"$lt": cutoff},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "accepted_at", Value: 1}})
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		logger.Error(ctx, "Failed to get stale accepted rides", err)
 		return nil, err
 	}
 	defer cursor.Close(ctx)
@@ -321,14 +912,93 @@ func (r *RideMongoRepository) GetNearbyRequestedRides(ctx context.Context, lat,
 	return rides, nil
 }
 
-// GetByCustomerID retrieves all rides for a customer
-func (r *RideMongoRepository) GetByCustomerID(ctx context.Context, customerID int64) ([]*domain.Ride, error) {
+// GetByCustomerID retrieves a page of a customer's rides, newest first, along with the total count
+// An empty status matches rides in any status
+func (r *RideMongoRepository) GetByCustomerID(ctx context.Context, customerID int64, status domain.RideStatus, limit, offset int) ([]*domain.Ride, int64, error) {
 	filter := bson.M{"customer_id": customerID}
-	opts := options.Find().SetSort(bson.D{{Key: "requested_at", Value: -1}})
+	if status != "" {
+		filter["status"] = string(status)
+	}
+	return r.findRidesPage(ctx, filter, repository.Page{Limit: limit, Offset: offset})
+}
+
+// GetBusyDriverIDs returns which of driverIDs have accepted, arrived at or started a ride
+// It is a single query on the driver_id index however many drivers are checked
+func (r *RideMongoRepository) GetBusyDriverIDs(ctx context.Context, driverIDs []int64) ([]int64, error) {
+	if len(driverIDs) == 0 {
+		return []int64{}, nil
+	}
+
+	filter := bson.M{
+		"driver_id": bson.M{"$in": driverIDs},
+		"status":    bson.M{"$in": activeDriverRideStatuses},
+	}
+
+	values, err := r.collection.Distinct(ctx, "driver_id", filter)
+	if err != nil {
+		logger.Error(ctx, "Failed to get busy drivers", err)
+		return nil, err
+	}
+
+	busy := make([]int64, 0, len(values))
+	for _, value := range values {
+		switch id := value.(type) {
+		case int64:
+			busy = append(busy, id)
+		case int32:
+			busy = append(busy, int64(id))
+		}
+	}
+	return busy, nil
+}
+
+// GetByDriverID retrieves a page of a driver's rides, newest first, along with the total count
+func (r *RideMongoRepository) GetByDriverID(ctx context.Context, driverID int64, limit, offset int) ([]*domain.Ride, int64, error) {
+	return r.findRidesPage(ctx, bson.M{"driver_id": driverID}, repository.Page{Limit: limit, Offset: offset})
+}
+
+// ListRides retrieves a page of all rides matching filter, newest first, along with the total count
+func (r *RideMongoRepository) ListRides(ctx context.Context, filter repository.RideFilter, page repository.Page) ([]*domain.Ride, int64, error) {
+	query := bson.M{}
+	if filter.Status != "" {
+		query["status"] = string(filter.Status)
+	}
+	if filter.DriverID != 0 {
+		query["driver_id"] = filter.DriverID
+	}
+	if filter.CustomerID != 0 {
+		query["customer_id"] = filter.CustomerID
+	}
+
+	requestedAt := bson.M{}
+	if !filter.From.IsZero() {
+		requestedAt["$gte"] = filter.From
+	}
+	if !filter.To.IsZero() {
+		requestedAt["$lt"] = filter.To
+	}
+	if len(requestedAt) > 0 {
+		query["requested_at"] = requestedAt
+	}
+
+	return r.findRidesPage(ctx, query, page)
+}
+
+// GetCompletedByDriverID retrieves a driver's rides completed in [from, to), most recently completed first
+func (r *RideMongoRepository) GetCompletedByDriverID(ctx context.Context, driverID int64, from, to time.Time) ([]*domain.Ride, error) {
+	filter := bson.M{
+		"driver_id": driverID,
+		"status":    string(domain.RideStatusCompleted),
+		"completed_at": bson.M{
+			"$gte": from,
+			"$lt":  to,
+		},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "completed_at", Value: -1}})
 
 	cursor, err := r.collection.Find(ctx, filter, opts)
 	if err != nil {
-		logger.Error(ctx, "Failed to get rides by customer ID", err)
+		logger.Error(ctx, "Failed to get completed rides", err)
 		return nil, err
 	}
 	defer cursor.Close(ctx)
@@ -346,14 +1016,20 @@ func (r *RideMongoRepository) GetByCustomerID(ctx context.Context, customerID in
 	return rides, nil
 }
 
-// GetByDriverID retrieves all rides for a driver
-func (r *RideMongoRepository) GetByDriverID(ctx context.Context, driverID int64) ([]*domain.Ride, error) {
-	filter := bson.M{"driver_id": driverID}
-	opts := options.Find().SetSort(bson.D{{Key: "requested_at", Value: -1}})
+// GetCompletedRides retrieves all rides completed in [from, to), most recently completed first
+func (r *RideMongoRepository) GetCompletedRides(ctx context.Context, from, to time.Time) ([]*domain.Ride, error) {
+	filter := bson.M{
+		"status": string(domain.RideStatusCompleted),
+		"completed_at": bson.M{
+			"$gte": from,
+			"$lt":  to,
+		},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "completed_at", Value: -1}})
 
 	cursor, err := r.collection.Find(ctx, filter, opts)
 	if err != nil {
-		logger.Error(ctx, "Failed to get rides by driver ID", err)
+		logger.Error(ctx, "Failed to get completed rides", err)
 		return nil, err
 	}
 	defer cursor.Close(ctx)
@@ -370,3 +1046,40 @@ func (r *RideMongoRepository) GetByDriverID(ctx context.Context, driverID int64)
 
 	return rides, nil
 }
+
+// findRidesPage returns rides matching filter sorted by requested_at then ride_id descending
+// The total counts every ride matching filter, not just those after the page's cursor
+func (r *RideMongoRepository) findRidesPage(ctx context.Context, filter bson.M, page repository.Page) ([]*domain.Ride, int64, error) {
+	total, err := r.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		logger.Error(ctx, "Failed to count rides", err)
+		return nil, 0, err
+	}
+
+	opts := options.Find().SetSort(pagination.MongoSort("requested_at", "ride_id"))
+	if page.Cursor == nil {
+		opts.SetSkip(int64(page.Offset))
+	}
+	if page.Limit > 0 {
+		opts.SetLimit(int64(page.Limit))
+	}
+
+	cursor, err := r.collection.Find(ctx, pagination.MongoFilter(filter, page.Cursor, "requested_at", "ride_id"), opts)
+	if err != nil {
+		logger.Error(ctx, "Failed to get rides", err)
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var rides []*domain.Ride
+	for cursor.Next(ctx) {
+		var doc RideDocument
+		if err := cursor.Decode(&doc); err != nil {
+			logger.Error(ctx, "Failed to decode ride", err)
+			continue
+		}
+		rides = append(rides, toRideDomain(&doc))
+	}
+
+	return rides, total, nil
+}
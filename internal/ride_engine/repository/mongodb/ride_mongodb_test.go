@@ -2,18 +2,35 @@ package mongodb
 
 import (
 	"context"
+	"errors"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/repository"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/pagination"
 )
 
+// anyDriver searches nearby rides as no particular driver, matching waiting rides whoever they are offered to
+const anyDriver = int64(0)
+
+// lastCustomerID is the customer ID nextCustomerID handed out last
+var lastCustomerID int64
+
+// nextCustomerID returns a customer ID not used by any other test ride, a customer can only have one active ride
+func nextCustomerID() int64 {
+	lastCustomerID++
+	return lastCustomerID
+}
+
 // setupTestDB creates a test MongoDB connection
-func setupTestDB(t *testing.T) (*mongo.Database, func()) {
+func setupTestDB(t testing.TB) (*mongo.Database, func()) {
 	ctx := context.Background()
 
 	// Connect to test MongoDB instance
@@ -56,6 +73,35 @@ func TestRideMongoRepository_Create(t *testing.T) {
 	assert.NotZero(t, ride.ID, "Ride ID should be generated")
 }
 
+func TestRideMongoRepository_Create_WithWaypoints(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewRideMongoRepository(db)
+	ctx := context.Background()
+
+	waypoints := []domain.Location{
+		{Latitude: 23.7806, Longitude: 90.4193},
+		{Latitude: 23.7461, Longitude: 90.3742},
+	}
+	ride := &domain.Ride{
+		CustomerID:  123,
+		PickupLat:   23.8100,
+		PickupLng:   90.4120,
+		DropoffLat:  23.7509,
+		DropoffLng:  90.3761,
+		Waypoints:   waypoints,
+		Status:      domain.RideStatusRequested,
+		RequestedAt: time.Now(),
+	}
+	err := repo.Create(ctx, ride)
+	require.NoError(t, err)
+
+	retrieved, err := repo.GetByID(ctx, ride.ID)
+	require.NoError(t, err)
+	assert.Equal(t, waypoints, retrieved.Waypoints, "Waypoints should keep their order")
+}
+
 func TestRideMongoRepository_GetByID(t *testing.T) {
 	db, cleanup := setupTestDB(t)
 	defer cleanup()
@@ -127,7 +173,7 @@ func TestRideMongoRepository_Update(t *testing.T) {
 	require.NoError(t, err)
 
 	// Update the ride
-	err = repo.Update(ctx, ride)
+	err = repo.Update(ctx, ride, domain.RideStatusRequested)
 	assert.NoError(t, err)
 
 	// Verify update
@@ -137,6 +183,111 @@ func TestRideMongoRepository_Update(t *testing.T) {
 	assert.NotNil(t, updated.DriverID)
 	assert.Equal(t, driverID, *updated.DriverID)
 	assert.NotNil(t, updated.AcceptedAt)
+
+	// Arriving at the pickup is recorded with its time
+	arrivedAt := time.Now().Truncate(time.Millisecond)
+	require.NoError(t, ride.MarkArrived(driverID, arrivedAt))
+	require.NoError(t, repo.Update(ctx, ride, domain.RideStatusAccepted))
+
+	arrived, err := repo.GetByID(ctx, ride.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.RideStatusArrived, arrived.Status)
+	require.NotNil(t, arrived.ArrivedAt)
+	assert.True(t, arrivedAt.Equal(*arrived.ArrivedAt))
+}
+
+func TestRideMongoRepository_Update_StaleStatus(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewRideMongoRepository(db)
+	ctx := context.Background()
+
+	ride := &domain.Ride{
+		CustomerID:  nextCustomerID(),
+		PickupLat:   23.8100,
+		PickupLng:   90.4120,
+		DropoffLat:  23.7509,
+		DropoffLng:  90.3761,
+		Status:      domain.RideStatusRequested,
+		RequestedAt: time.Now(),
+	}
+	require.NoError(t, repo.Create(ctx, ride))
+
+	// The customer read the ride before a driver accepted it
+	stale, err := repo.GetByID(ctx, ride.ID)
+	require.NoError(t, err)
+
+	driverID := int64(456)
+	require.NoError(t, repo.AcceptRide(ctx, ride.ID, driverID, time.Now()))
+
+	require.NoError(t, stale.Cancel(domain.CancelledByCustomer, ""))
+	err = repo.Update(ctx, stale, domain.RideStatusRequested)
+	assert.ErrorIs(t, err, repository.ErrRideStatusChanged)
+
+	retrieved, err := repo.GetByID(ctx, ride.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.RideStatusAccepted, retrieved.Status, "The stale cancel should not overwrite the accept")
+	require.NotNil(t, retrieved.DriverID)
+	assert.Equal(t, driverID, *retrieved.DriverID)
+}
+
+func TestRideMongoRepository_AcceptAndCancelConcurrently(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewRideMongoRepository(db)
+	ctx := context.Background()
+	driverID := int64(456)
+
+	for i := 0; i < 10; i++ {
+		ride := &domain.Ride{
+			CustomerID:      nextCustomerID(),
+			PickupLat:       23.8100,
+			PickupLng:       90.4120,
+			DropoffLat:      23.7509,
+			DropoffLng:      90.3761,
+			Status:          domain.RideStatusPending,
+			OfferedDriverID: &driverID,
+			RequestedAt:     time.Now(),
+		}
+		require.NoError(t, repo.Create(ctx, ride))
+
+		// The customer cancels the ride they read while it was offered, the driver accepts the offer at the same moment
+		var acceptErr, cancelErr error
+		var wg sync.WaitGroup
+		start := make(chan struct{})
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			<-start
+			acceptErr = repo.AcceptRide(ctx, ride.ID, driverID, time.Now())
+		}()
+		go func() {
+			defer wg.Done()
+			<-start
+			if cancelErr = ride.Cancel(domain.CancelledByCustomer, ""); cancelErr == nil {
+				cancelErr = repo.Update(ctx, ride, domain.RideStatusPending)
+			}
+		}()
+		close(start)
+		wg.Wait()
+
+		retrieved, err := repo.GetByID(ctx, ride.ID)
+		require.NoError(t, err)
+
+		if acceptErr == nil {
+			assert.ErrorIs(t, cancelErr, repository.ErrRideStatusChanged, "only one of the accept and the cancel should succeed")
+			assert.Equal(t, domain.RideStatusAccepted, retrieved.Status)
+			require.NotNil(t, retrieved.DriverID)
+			assert.Equal(t, driverID, *retrieved.DriverID)
+		} else {
+			assert.ErrorIs(t, acceptErr, repository.ErrRideNotAcceptable)
+			require.NoError(t, cancelErr)
+			assert.Equal(t, domain.RideStatusCancelled, retrieved.Status)
+			assert.Nil(t, retrieved.DriverID)
+		}
+	}
 }
 
 func TestRideMongoRepository_GetRequestedRides(t *testing.T) {
@@ -230,7 +381,7 @@ func TestRideMongoRepository_GetNearbyRequestedRides(t *testing.T) {
 	maxDistance := 5000.0 // 5km
 
 	// Get nearby rides
-	nearby, err := repo.GetNearbyRequestedRides(ctx, driverLat, driverLng, maxDistance, 10)
+	nearby, err := repo.GetNearbyRequestedRides(ctx, anyDriver, domain.RideTypeEconomy, driverLat, driverLng, maxDistance, 5*time.Minute, 10)
 	assert.NoError(t, err)
 	assert.NotEmpty(t, nearby, "Should find at least one nearby ride")
 
@@ -271,52 +422,144 @@ func TestRideMongoRepository_GetNearbyRequestedRides_TimeFilter(t *testing.T) {
 	maxDistance := 10000.0
 
 	// Get nearby rides
-	nearby, err := repo.GetNearbyRequestedRides(ctx, driverLat, driverLng, maxDistance, 10)
+	nearby, err := repo.GetNearbyRequestedRides(ctx, anyDriver, domain.RideTypeEconomy, driverLat, driverLng, maxDistance, 5*time.Minute, 10)
 	assert.NoError(t, err)
 	assert.NotEmpty(t, nearby, "Should find fresh ride")
 }
 
-func TestRideMongoRepository_GetNearbyRequestedRides_WithLimit(t *testing.T) {
+func TestRideMongoRepository_GetNearbyRequestedRides_FreshnessWindow(t *testing.T) {
 	db, cleanup := setupTestDB(t)
 	defer cleanup()
 
 	repo := NewRideMongoRepository(db)
 	ctx := context.Background()
 
-	// Create 10 rides at same location
-	for i := 0; i < 10; i++ {
+	createRideUpdatedAt := func(updatedAt time.Time) *domain.Ride {
 		ride := &domain.Ride{
-			CustomerID:  int64(i + 1),
+			CustomerID:  nextCustomerID(),
 			PickupLat:   23.8100,
 			PickupLng:   90.4120,
 			DropoffLat:  23.7509,
 			DropoffLng:  90.3761,
 			Status:      domain.RideStatusRequested,
-			RequestedAt: time.Now(),
+			RequestedAt: updatedAt,
 		}
-		err := repo.Create(ctx, ride)
+		require.NoError(t, repo.Create(ctx, ride))
+		_, err := repo.collection.UpdateOne(ctx, bson.M{"ride_id": ride.ID}, bson.M{"$set": bson.M{"updated_at": updatedAt}})
 		require.NoError(t, err)
+		return ride
 	}
 
-	// Get nearby rides with limit of 5
-	nearby, err := repo.GetNearbyRequestedRides(ctx, 23.8103, 90.4125, 10000.0, 5)
+	freshness := time.Minute
+	justInside := createRideUpdatedAt(time.Now().Add(-freshness + 5*time.Second))
+	createRideUpdatedAt(time.Now().Add(-freshness - 5*time.Second)) // just outside
+
+	nearby, err := repo.GetNearbyRequestedRides(ctx, anyDriver, domain.RideTypeEconomy, 23.8103, 90.4125, 10000.0, freshness, 10)
 	assert.NoError(t, err)
-	assert.LessOrEqual(t, len(nearby), 5, "Should respect limit")
+	require.Len(t, nearby, 1)
+	assert.Equal(t, justInside.ID, nearby[0].ID)
+
+	// A wider window includes the older ride too
+	nearby, err = repo.GetNearbyRequestedRides(ctx, anyDriver, domain.RideTypeEconomy, 23.8103, 90.4125, 10000.0, 2*freshness, 10)
+	assert.NoError(t, err)
+	assert.Len(t, nearby, 2)
 }
 
-func TestRideMongoRepository_GetByCustomerID(t *testing.T) {
+func TestRideMongoRepository_GetNearbyRequestedRides_SkipsAssignedRides(t *testing.T) {
 	db, cleanup := setupTestDB(t)
 	defer cleanup()
 
 	repo := NewRideMongoRepository(db)
 	ctx := context.Background()
 
-	customerID := int64(123)
+	createRide := func() *domain.Ride {
+		ride := &domain.Ride{
+			CustomerID:  nextCustomerID(),
+			PickupLat:   23.8100,
+			PickupLng:   90.4120,
+			DropoffLat:  23.7509,
+			DropoffLng:  90.3761,
+			Status:      domain.RideStatusRequested,
+			RequestedAt: time.Now(),
+		}
+		require.NoError(t, repo.Create(ctx, ride))
+		return ride
+	}
+
+	driverID := int64(456)
+	open := createRide()
 
-	// Create rides for different customers
+	accepted := createRide()
+	require.NoError(t, repo.AcceptRide(ctx, accepted.ID, driverID, time.Now()))
+
+	// A waiting ride that still carries a driver, e.g. re-requested after an accept
+	reRequested := createRide()
+	_, err := repo.collection.UpdateOne(ctx, bson.M{"ride_id": reRequested.ID}, bson.M{"$set": bson.M{"driver_id": driverID}})
+	require.NoError(t, err)
+
+	nearby, err := repo.GetNearbyRequestedRides(ctx, anyDriver, domain.RideTypeEconomy, 23.8103, 90.4125, 10000.0, 5*time.Minute, 10)
+	assert.NoError(t, err)
+	require.Len(t, nearby, 1, "only the unassigned ride should be returned")
+	assert.Equal(t, open.ID, nearby[0].ID)
+}
+
+func TestRideMongoRepository_GetNearbyRequestedRides_OnlyOfferedToDriver(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewRideMongoRepository(db)
+	ctx := context.Background()
+
+	driverID, otherDriverID := int64(456), int64(789)
+	createRide := func(offeredTo *int64, expiresAt time.Time) *domain.Ride {
+		ride := &domain.Ride{
+			CustomerID:  nextCustomerID(),
+			PickupLat:   23.8100,
+			PickupLng:   90.4120,
+			DropoffLat:  23.7509,
+			DropoffLng:  90.3761,
+			Status:      domain.RideStatusRequested,
+			RequestedAt: time.Now(),
+		}
+		require.NoError(t, repo.Create(ctx, ride))
+		require.NoError(t, repo.SetRideOffer(ctx, ride.ID, offeredTo, expiresAt))
+		return ride
+	}
+
+	offered := createRide(&driverID, time.Now().Add(time.Minute))
+	createRide(&driverID, time.Now().Add(-time.Second)) // offer expired
+	// Offers to other drivers come first so a limit applied before the offer filter would leave nothing for the driver
 	for i := 0; i < 3; i++ {
+		createRide(&otherDriverID, time.Now().Add(time.Minute))
+	}
+	createRide(nil, time.Now().Add(time.Minute)) // not offered to anyone
+
+	for name, find := range map[string]func(context.Context, int64, domain.RideType, float64, float64, float64, time.Duration, int) ([]*domain.Ride, error){
+		"geo":     repo.GetNearbyRequestedRides,
+		"geohash": repo.GetNearbyRequestedRidesByGeohash,
+	} {
+		nearby, err := find(ctx, driverID, domain.RideTypeEconomy, 23.8103, 90.4125, 10000.0, 5*time.Minute, 2)
+		require.NoError(t, err, name)
+		require.Len(t, nearby, 1, name)
+		assert.Equal(t, offered.ID, nearby[0].ID, name)
+
+		nearby, err = find(ctx, anyDriver, domain.RideTypeEconomy, 23.8103, 90.4125, 10000.0, 5*time.Minute, 10)
+		require.NoError(t, err, name)
+		assert.Len(t, nearby, 6, "%s: every waiting ride is found without a driver", name)
+	}
+}
+
+func TestRideMongoRepository_GetNearbyRequestedRides_WithLimit(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewRideMongoRepository(db)
+	ctx := context.Background()
+
+	// Create 10 rides at same location
+	for i := 0; i < 10; i++ {
 		ride := &domain.Ride{
-			CustomerID:  customerID,
+			CustomerID:  int64(i + 1),
 			PickupLat:   23.8100,
 			PickupLng:   90.4120,
 			DropoffLat:  23.7509,
@@ -328,9 +571,118 @@ func TestRideMongoRepository_GetByCustomerID(t *testing.T) {
 		require.NoError(t, err)
 	}
 
-	// Create ride for different customer
-	otherRide := &domain.Ride{
-		CustomerID:  456,
+	// Get nearby rides with limit of 5
+	nearby, err := repo.GetNearbyRequestedRides(ctx, anyDriver, domain.RideTypeEconomy, 23.8103, 90.4125, 10000.0, 5*time.Minute, 5)
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, len(nearby), 5, "Should respect limit")
+}
+
+// createGeohashSampleRides creates requested rides on a grid of pickups around the driver's location, spaced about 550m apart
+func createGeohashSampleRides(t testing.TB, repo *RideMongoRepository, lat, lng float64, steps int) {
+	ctx := context.Background()
+	for i := -steps; i <= steps; i++ {
+		for j := -steps; j <= steps; j++ {
+			ride := &domain.Ride{
+				CustomerID:  nextCustomerID(),
+				PickupLat:   lat + float64(i)*0.005,
+				PickupLng:   lng + float64(j)*0.005,
+				DropoffLat:  23.7509,
+				DropoffLng:  90.3761,
+				Status:      domain.RideStatusRequested,
+				RideType:    domain.RideTypeEconomy,
+				RequestedAt: time.Now(),
+			}
+			require.NoError(t, repo.Create(ctx, ride))
+		}
+	}
+}
+
+func TestRideMongoRepository_GetNearbyRequestedRidesByGeohash_MatchesGeoQuery(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewRideMongoRepository(db)
+	ctx := context.Background()
+
+	driverLat, driverLng := 23.8103, 90.4125
+	createGeohashSampleRides(t, repo, driverLat+0.0011, driverLng-0.0007, 8)
+
+	for _, radius := range []float64{1000, 1500, 3000} {
+		geo, err := repo.GetNearbyRequestedRides(ctx, anyDriver, domain.RideTypeEconomy, driverLat, driverLng, radius, 5*time.Minute, 500)
+		require.NoError(t, err)
+		byGeohash, err := repo.GetNearbyRequestedRidesByGeohash(ctx, anyDriver, domain.RideTypeEconomy, driverLat, driverLng, radius, 5*time.Minute, 500)
+		require.NoError(t, err)
+
+		require.NotEmpty(t, geo)
+		assert.ElementsMatch(t, rideIDs(geo), rideIDs(byGeohash), "Radius %.0fm finds the same rides", radius)
+	}
+}
+
+func TestRideMongoRepository_GetNearbyRequestedRidesByGeohash_NearestFirst(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewRideMongoRepository(db)
+	ctx := context.Background()
+
+	driverLat, driverLng := 23.8103, 90.4125
+	createGeohashSampleRides(t, repo, driverLat, driverLng, 3)
+
+	nearby, err := repo.GetNearbyRequestedRidesByGeohash(ctx, anyDriver, domain.RideTypeEconomy, driverLat, driverLng, 2000, 5*time.Minute, 5)
+	require.NoError(t, err)
+	require.Len(t, nearby, 5)
+
+	driver := domain.Location{Latitude: driverLat, Longitude: driverLng}
+	for i := 1; i < len(nearby); i++ {
+		prev := driver.DistanceTo(domain.Location{Latitude: nearby[i-1].PickupLat, Longitude: nearby[i-1].PickupLng})
+		curr := driver.DistanceTo(domain.Location{Latitude: nearby[i].PickupLat, Longitude: nearby[i].PickupLng})
+		assert.LessOrEqual(t, prev, curr)
+	}
+}
+
+func rideIDs(rides []*domain.Ride) []int64 {
+	ids := make([]int64, 0, len(rides))
+	for _, ride := range rides {
+		ids = append(ids, ride.ID)
+	}
+	return ids
+}
+
+// BenchmarkRideMongoRepository_GetNearbyRequestedRides and its geohash counterpart compare the two nearby polling queries,
+// pick one with RIDE_NEARBY_SEARCH_BY_GEOHASH
+func BenchmarkRideMongoRepository_GetNearbyRequestedRides(b *testing.B) {
+	benchmarkNearbyRequestedRides(b, (*RideMongoRepository).GetNearbyRequestedRides)
+}
+
+func BenchmarkRideMongoRepository_GetNearbyRequestedRidesByGeohash(b *testing.B) {
+	benchmarkNearbyRequestedRides(b, (*RideMongoRepository).GetNearbyRequestedRidesByGeohash)
+}
+
+func benchmarkNearbyRequestedRides(b *testing.B, find func(*RideMongoRepository, context.Context, int64, domain.RideType, float64, float64, float64, time.Duration, int) ([]*domain.Ride, error)) {
+	db, cleanup := setupTestDB(b)
+	defer cleanup()
+
+	repo := NewRideMongoRepository(db)
+	ctx := context.Background()
+	createGeohashSampleRides(b, repo, 23.8103, 90.4125, 20)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := find(repo, ctx, anyDriver, domain.RideTypeEconomy, 23.8103, 90.4125, 3000, 5*time.Minute, 50); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestRideMongoRepository_GetNearbyRequestedRides_SkipsDeclined(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewRideMongoRepository(db)
+	ctx := context.Background()
+
+	ride := &domain.Ride{
+		CustomerID:  1,
 		PickupLat:   23.8100,
 		PickupLng:   90.4120,
 		DropoffLat:  23.7509,
@@ -338,47 +690,1175 @@ func TestRideMongoRepository_GetByCustomerID(t *testing.T) {
 		Status:      domain.RideStatusRequested,
 		RequestedAt: time.Now(),
 	}
-	err := repo.Create(ctx, otherRide)
+	err := repo.Create(ctx, ride)
 	require.NoError(t, err)
 
-	// Get rides by customer ID
-	rides, err := repo.GetByCustomerID(ctx, customerID)
+	decliningDriverID := int64(456)
+	otherDriverID := int64(789)
+
+	require.NoError(t, repo.SetRideOffer(ctx, ride.ID, &decliningDriverID, time.Now().Add(time.Minute)))
+	err = repo.AddDeclinedDriver(ctx, ride.ID, decliningDriverID)
+	require.NoError(t, err)
+
+	// Declining twice should not duplicate the driver
+	err = repo.AddDeclinedDriver(ctx, ride.ID, decliningDriverID)
+	require.NoError(t, err)
+
+	nearby, err := repo.GetNearbyRequestedRides(ctx, decliningDriverID, domain.RideTypeEconomy, 23.8103, 90.4125, 10000.0, 5*time.Minute, 10)
 	assert.NoError(t, err)
-	assert.Len(t, rides, 3, "Should return only customer's rides")
+	assert.Empty(t, nearby, "Declined ride should not be offered to the declining driver")
+
+	require.NoError(t, repo.SetRideOffer(ctx, ride.ID, &otherDriverID, time.Now().Add(time.Minute)))
+	nearby, err = repo.GetNearbyRequestedRides(ctx, otherDriverID, domain.RideTypeEconomy, 23.8103, 90.4125, 10000.0, 5*time.Minute, 10)
+	assert.NoError(t, err)
+	require.Len(t, nearby, 1, "Declined ride should still be offered to other drivers")
+	assert.Equal(t, ride.ID, nearby[0].ID)
 }
 
-func TestRideMongoRepository_GetByDriverID(t *testing.T) {
+func TestRideMongoRepository_GetNearbyRequestedRides_MatchesRideType(t *testing.T) {
 	db, cleanup := setupTestDB(t)
 	defer cleanup()
 
 	repo := NewRideMongoRepository(db)
 	ctx := context.Background()
 
-	driverID := int64(456)
-
-	// Create and accept rides
-	for i := 0; i < 2; i++ {
+	createRide := func(rideType domain.RideType) *domain.Ride {
 		ride := &domain.Ride{
-			CustomerID:  int64(i + 1),
+			CustomerID:  nextCustomerID(),
 			PickupLat:   23.8100,
 			PickupLng:   90.4120,
 			DropoffLat:  23.7509,
 			DropoffLng:  90.3761,
 			Status:      domain.RideStatusRequested,
+			RideType:    rideType,
 			RequestedAt: time.Now(),
 		}
 		err := repo.Create(ctx, ride)
 		require.NoError(t, err)
-
-		// Accept ride
-		err = ride.Accept(driverID)
-		require.NoError(t, err)
-		err = repo.Update(ctx, ride)
-		require.NoError(t, err)
+		return ride
 	}
 
-	// Get rides by driver ID
-	rides, err := repo.GetByDriverID(ctx, driverID)
+	premiumRide := createRide(domain.RideTypePremium)
+	bikeRide := createRide(domain.RideTypeBike)
+	legacyRide := createRide("") // stored without a ride type
+
+	// Bike driver must not see premium car requests
+	nearby, err := repo.GetNearbyRequestedRides(ctx, anyDriver, domain.RideTypeBike, 23.8103, 90.4125, 10000.0, 5*time.Minute, 10)
 	assert.NoError(t, err)
-	assert.Len(t, rides, 2, "Should return driver's rides")
+	require.Len(t, nearby, 1)
+	assert.Equal(t, bikeRide.ID, nearby[0].ID)
+	assert.Equal(t, domain.RideTypeBike, nearby[0].RideType)
+
+	nearby, err = repo.GetNearbyRequestedRides(ctx, anyDriver, domain.RideTypePremium, 23.8103, 90.4125, 10000.0, 5*time.Minute, 10)
+	assert.NoError(t, err)
+	require.Len(t, nearby, 1)
+	assert.Equal(t, premiumRide.ID, nearby[0].ID)
+
+	// Rides without a ride type are economy
+	nearby, err = repo.GetNearbyRequestedRides(ctx, anyDriver, domain.RideTypeEconomy, 23.8103, 90.4125, 10000.0, 5*time.Minute, 10)
+	assert.NoError(t, err)
+	require.Len(t, nearby, 1)
+	assert.Equal(t, legacyRide.ID, nearby[0].ID)
+	assert.Equal(t, domain.RideTypeEconomy, nearby[0].RideType)
+}
+
+func TestRideMongoRepository_AddDeclinedDriver_NotFound(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewRideMongoRepository(db)
+	ctx := context.Background()
+
+	err := repo.AddDeclinedDriver(ctx, 99999, 456)
+	assert.ErrorIs(t, err, ErrRideNotFound)
+}
+
+func TestRideMongoRepository_AcceptRide(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewRideMongoRepository(db)
+	ctx := context.Background()
+
+	ride := &domain.Ride{
+		CustomerID:  1,
+		PickupLat:   23.8100,
+		PickupLng:   90.4120,
+		DropoffLat:  23.7509,
+		DropoffLng:  90.3761,
+		Status:      domain.RideStatusRequested,
+		RequestedAt: time.Now(),
+	}
+	require.NoError(t, repo.Create(ctx, ride))
+
+	driverID := int64(456)
+	require.NoError(t, repo.AcceptRide(ctx, ride.ID, driverID, time.Now()))
+
+	accepted, err := repo.GetByID(ctx, ride.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.RideStatusAccepted, accepted.Status)
+	require.NotNil(t, accepted.DriverID)
+	assert.Equal(t, driverID, *accepted.DriverID)
+	assert.NotNil(t, accepted.AcceptedAt)
+
+	// A second accept must not reassign the ride
+	err = repo.AcceptRide(ctx, ride.ID, 789, time.Now())
+	assert.ErrorIs(t, err, repository.ErrRideNotAcceptable)
+
+	err = repo.AcceptRide(ctx, 99999, driverID, time.Now())
+	assert.ErrorIs(t, err, repository.ErrRideNotAcceptable)
+}
+
+func TestRideMongoRepository_AcceptRide_Concurrent(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewRideMongoRepository(db)
+	ctx := context.Background()
+
+	ride := &domain.Ride{
+		CustomerID:  1,
+		PickupLat:   23.8100,
+		PickupLng:   90.4120,
+		DropoffLat:  23.7509,
+		DropoffLng:  90.3761,
+		Status:      domain.RideStatusRequested,
+		RequestedAt: time.Now(),
+	}
+	require.NoError(t, repo.Create(ctx, ride))
+
+	driverIDs := []int64{456, 789}
+	errs := make([]error, len(driverIDs))
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i, driverID := range driverIDs {
+		wg.Add(1)
+		go func(i int, driverID int64) {
+			defer wg.Done()
+			<-start
+			errs[i] = repo.AcceptRide(ctx, ride.ID, driverID, time.Now())
+		}(i, driverID)
+	}
+	close(start)
+	wg.Wait()
+
+	var winner int64
+	succeeded := 0
+	for i, err := range errs {
+		if err == nil {
+			succeeded++
+			winner = driverIDs[i]
+			continue
+		}
+		assert.ErrorIs(t, err, repository.ErrRideNotAcceptable)
+	}
+	require.Equal(t, 1, succeeded, "exactly one driver should get the ride")
+
+	accepted, err := repo.GetByID(ctx, ride.ID)
+	require.NoError(t, err)
+	require.NotNil(t, accepted.DriverID)
+	assert.Equal(t, winner, *accepted.DriverID)
+}
+
+func TestRideMongoRepository_Create_OneActiveRidePerCustomer(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewRideMongoRepository(db)
+	ctx := context.Background()
+
+	customerID := int64(123)
+	newRide := func(status domain.RideStatus) *domain.Ride {
+		return &domain.Ride{
+			CustomerID:  customerID,
+			PickupLat:   23.8100,
+			PickupLng:   90.4120,
+			DropoffLat:  23.7509,
+			DropoffLng:  90.3761,
+			Status:      status,
+			RequestedAt: time.Now(),
+		}
+	}
+
+	// Finished rides do not count
+	require.NoError(t, repo.Create(ctx, newRide(domain.RideStatusCompleted)))
+	require.NoError(t, repo.Create(ctx, newRide(domain.RideStatusCancelled)))
+
+	const requests = 10
+	errs := make([]error, requests)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			errs[i] = repo.Create(ctx, newRide(domain.RideStatusRequested))
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	succeeded := 0
+	for _, err := range errs {
+		if err == nil {
+			succeeded++
+			continue
+		}
+		assert.ErrorIs(t, err, repository.ErrCustomerHasActiveRide)
+	}
+	require.Equal(t, 1, succeeded, "exactly one of the customer's concurrent requests should create a ride")
+
+	active, err := repo.GetActiveRideByCustomerID(ctx, customerID)
+	require.NoError(t, err)
+
+	// Once the active ride is cancelled the customer can request another
+	require.NoError(t, active.Cancel(domain.CancelledByCustomer, ""))
+	require.NoError(t, repo.Update(ctx, active, domain.RideStatusRequested))
+	require.NoError(t, repo.Create(ctx, newRide(domain.RideStatusRequested)))
+
+	// Other customers are not affected
+	other := newRide(domain.RideStatusRequested)
+	other.CustomerID = 456
+	require.NoError(t, repo.Create(ctx, other))
+}
+
+func TestRideMongoRepository_WithTransaction_RollsBackOnError(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewRideMongoRepository(db)
+	if !repo.transactions {
+		t.Skip("MongoDB test server is not a replica set, transactions are not supported")
+	}
+	ctx := context.Background()
+
+	ride := &domain.Ride{
+		CustomerID:  1,
+		PickupLat:   23.8100,
+		PickupLng:   90.4120,
+		DropoffLat:  23.7509,
+		DropoffLng:  90.3761,
+		Status:      domain.RideStatusRequested,
+		RequestedAt: time.Now(),
+	}
+	require.NoError(t, repo.Create(ctx, ride))
+
+	errMidTransaction := errors.New("driver could not be reserved")
+	err := repo.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := repo.AcceptRide(ctx, ride.ID, 456, time.Now()); err != nil {
+			return err
+		}
+		return errMidTransaction
+	})
+	assert.ErrorIs(t, err, errMidTransaction)
+
+	stored, err := repo.GetByID(ctx, ride.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.RideStatusRequested, stored.Status)
+	assert.Nil(t, stored.DriverID)
+	assert.Nil(t, stored.AcceptedAt)
+
+	// The ride can still be accepted once the failed transaction is rolled back
+	err = repo.WithTransaction(ctx, func(ctx context.Context) error {
+		return repo.AcceptRide(ctx, ride.ID, 789, time.Now())
+	})
+	require.NoError(t, err)
+
+	accepted, err := repo.GetByID(ctx, ride.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.RideStatusAccepted, accepted.Status)
+}
+
+func TestRideMongoRepository_SetRideOffer(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewRideMongoRepository(db)
+	ctx := context.Background()
+
+	ride := &domain.Ride{
+		CustomerID:  1,
+		PickupLat:   23.8100,
+		PickupLng:   90.4120,
+		DropoffLat:  23.7509,
+		DropoffLng:  90.3761,
+		Status:      domain.RideStatusRequested,
+		RequestedAt: time.Now(),
+	}
+	require.NoError(t, repo.Create(ctx, ride))
+
+	driverID := int64(456)
+	expiresAt := time.Now().Add(30 * time.Second).Truncate(time.Millisecond)
+	require.NoError(t, repo.SetRideOffer(ctx, ride.ID, &driverID, expiresAt))
+
+	offered, err := repo.GetByID(ctx, ride.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.RideStatusPending, offered.Status, "An offered ride should be pending")
+	require.NotNil(t, offered.OfferedDriverID)
+	assert.Equal(t, driverID, *offered.OfferedDriverID)
+	require.NotNil(t, offered.OfferExpiresAt)
+	assert.True(t, expiresAt.Equal(*offered.OfferExpiresAt))
+
+	// Withdrawing the offer clears the offeree
+	require.NoError(t, repo.SetRideOffer(ctx, ride.ID, nil, expiresAt))
+	withdrawn, err := repo.GetByID(ctx, ride.ID)
+	require.NoError(t, err)
+	assert.Nil(t, withdrawn.OfferedDriverID)
+	assert.Equal(t, domain.RideStatusRequested, withdrawn.Status)
+
+	// Accepted rides are no longer offered
+	require.NoError(t, withdrawn.Accept(driverID))
+	require.NoError(t, repo.Update(ctx, withdrawn, domain.RideStatusRequested))
+	err = repo.SetRideOffer(ctx, ride.ID, &driverID, expiresAt)
+	assert.ErrorIs(t, err, ErrRideNotFound)
+}
+
+func TestRideMongoRepository_AbandonRide(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewRideMongoRepository(db)
+	ctx := context.Background()
+
+	driverID := int64(456)
+	acceptedAt := time.Now()
+	ride := &domain.Ride{
+		CustomerID:  1,
+		DriverID:    &driverID,
+		PickupLat:   23.8100,
+		PickupLng:   90.4120,
+		DropoffLat:  23.7509,
+		DropoffLng:  90.3761,
+		Status:      domain.RideStatusAccepted,
+		RequestedAt: time.Now(),
+		AcceptedAt:  &acceptedAt,
+	}
+	require.NoError(t, repo.Create(ctx, ride))
+
+	// Only the accepted driver can abandon the ride
+	abandonment := domain.RideAbandonment{DriverID: 789, AbandonedAt: time.Now().Truncate(time.Millisecond)}
+	assert.ErrorIs(t, repo.AbandonRide(ctx, ride.ID, abandonment), repository.ErrRideNotAbandonable)
+
+	abandonment.DriverID = driverID
+	abandonment.Reason = "flat tyre"
+	require.NoError(t, repo.AbandonRide(ctx, ride.ID, abandonment))
+
+	abandoned, err := repo.GetByID(ctx, ride.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.RideStatusRequested, abandoned.Status)
+	assert.Nil(t, abandoned.DriverID)
+	assert.Nil(t, abandoned.AcceptedAt)
+	assert.Equal(t, []int64{driverID}, abandoned.DeclinedBy)
+	require.Len(t, abandoned.Abandonments, 1)
+	assert.Equal(t, driverID, abandoned.Abandonments[0].DriverID)
+	assert.Equal(t, "flat tyre", abandoned.Abandonments[0].Reason)
+	assert.True(t, abandonment.AbandonedAt.Equal(abandoned.Abandonments[0].AbandonedAt))
+
+	// A ride that is no longer accepted cannot be abandoned again
+	assert.ErrorIs(t, repo.AbandonRide(ctx, ride.ID, abandonment), repository.ErrRideNotAbandonable)
+}
+
+func TestRideMongoRepository_GetRidesAwaitingDispatch(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewRideMongoRepository(db)
+	ctx := context.Background()
+	now := time.Now()
+
+	createRide := func() *domain.Ride {
+		ride := &domain.Ride{
+			CustomerID:  nextCustomerID(),
+			PickupLat:   23.8100,
+			PickupLng:   90.4120,
+			DropoffLat:  23.7509,
+			DropoffLng:  90.3761,
+			Status:      domain.RideStatusRequested,
+			RequestedAt: now,
+		}
+		require.NoError(t, repo.Create(ctx, ride))
+		return ride
+	}
+
+	driverID := int64(456)
+	neverOffered := createRide()
+	expired := createRide()
+	require.NoError(t, repo.SetRideOffer(ctx, expired.ID, &driverID, now.Add(-time.Second)))
+	pending := createRide()
+	require.NoError(t, repo.SetRideOffer(ctx, pending.ID, &driverID, now.Add(30*time.Second)))
+
+	rides, err := repo.GetRidesAwaitingDispatch(ctx, now)
+	require.NoError(t, err)
+
+	var ids []int64
+	for _, ride := range rides {
+		ids = append(ids, ride.ID)
+	}
+	assert.ElementsMatch(t, []int64{neverOffered.ID, expired.ID}, ids)
+}
+
+func TestRideMongoRepository_GetStaleAcceptedRides(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewRideMongoRepository(db)
+	ctx := context.Background()
+
+	now := time.Now()
+	cutoff := now.Add(-20 * time.Minute)
+	createRide := func(status domain.RideStatus, acceptedAt time.Time) *domain.Ride {
+		driverID := int64(456)
+		ride := &domain.Ride{
+			CustomerID:  nextCustomerID(),
+			DriverID:    &driverID,
+			PickupLat:   23.8100,
+			PickupLng:   90.4120,
+			DropoffLat:  23.7509,
+			DropoffLng:  90.3761,
+			Status:      status,
+			RequestedAt: acceptedAt.Add(-time.Minute),
+			AcceptedAt:  &acceptedAt,
+		}
+		require.NoError(t, repo.Create(ctx, ride))
+		return ride
+	}
+
+	stale := createRide(domain.RideStatusAccepted, now.Add(-30*time.Minute))
+	createRide(domain.RideStatusAccepted, now.Add(-5*time.Minute))
+	createRide(domain.RideStatusStarted, now.Add(-30*time.Minute))
+	createRide(domain.RideStatusArrived, now.Add(-30*time.Minute))
+
+	rides, err := repo.GetStaleAcceptedRides(ctx, cutoff)
+
+	require.NoError(t, err)
+	require.Len(t, rides, 1, "Fresh, started and arrived rides are not stale")
+	assert.Equal(t, stale.ID, rides[0].ID)
+}
+
+func TestRideMongoRepository_GetStaleRequestedRides_ExpireRide(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewRideMongoRepository(db)
+	ctx := context.Background()
+
+	staleRide := &domain.Ride{
+		CustomerID:  1,
+		PickupLat:   23.8100,
+		PickupLng:   90.4120,
+		DropoffLat:  23.7509,
+		DropoffLng:  90.3761,
+		Status:      domain.RideStatusRequested,
+		RequestedAt: time.Now().Add(-30 * time.Minute),
+	}
+	err := repo.Create(ctx, staleRide)
+	require.NoError(t, err)
+
+	freshRide := &domain.Ride{
+		CustomerID:  2,
+		PickupLat:   23.8100,
+		PickupLng:   90.4120,
+		DropoffLat:  23.7509,
+		DropoffLng:  90.3761,
+		Status:      domain.RideStatusRequested,
+		RequestedAt: time.Now(),
+	}
+	err = repo.Create(ctx, freshRide)
+	require.NoError(t, err)
+
+	driverID := int64(456)
+	acceptedAt := time.Now().Add(-25 * time.Minute)
+	acceptedRide := &domain.Ride{
+		CustomerID:  3,
+		DriverID:    &driverID,
+		PickupLat:   23.8100,
+		PickupLng:   90.4120,
+		DropoffLat:  23.7509,
+		DropoffLng:  90.3761,
+		Status:      domain.RideStatusAccepted,
+		RequestedAt: time.Now().Add(-30 * time.Minute),
+		AcceptedAt:  &acceptedAt,
+	}
+	err = repo.Create(ctx, acceptedRide)
+	require.NoError(t, err)
+
+	cutoff := time.Now().Add(-10 * time.Minute)
+	stale, err := repo.GetStaleRequestedRides(ctx, cutoff)
+	require.NoError(t, err)
+	require.Len(t, stale, 1, "Only the stale requested ride should be expired")
+	assert.Equal(t, staleRide.ID, stale[0].ID)
+
+	require.NoError(t, stale[0].Cancel(domain.CancelledBySystem, "no driver found"))
+	require.NoError(t, repo.ExpireRide(ctx, stale[0], cutoff))
+
+	retrieved, err := repo.GetByID(ctx, staleRide.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.RideStatusCancelled, retrieved.Status)
+	assert.NotNil(t, retrieved.CancelledAt)
+	assert.Equal(t, domain.CancelledBySystem, retrieved.CancelledBy)
+	assert.Equal(t, "no driver found", retrieved.CancellationReason)
+
+	retrieved, err = repo.GetByID(ctx, freshRide.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.RideStatusRequested, retrieved.Status)
+
+	retrieved, err = repo.GetByID(ctx, acceptedRide.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.RideStatusAccepted, retrieved.Status)
+}
+
+func TestRideMongoRepository_ExpireRide_Pending(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewRideMongoRepository(db)
+	ctx := context.Background()
+
+	ride := &domain.Ride{
+		CustomerID:  1,
+		PickupLat:   23.8100,
+		PickupLng:   90.4120,
+		DropoffLat:  23.7509,
+		DropoffLng:  90.3761,
+		Status:      domain.RideStatusRequested,
+		RequestedAt: time.Now().Add(-30 * time.Minute),
+	}
+	require.NoError(t, repo.Create(ctx, ride))
+
+	// A ride offered to a driver who never answers still expires
+	driverID := int64(456)
+	require.NoError(t, repo.SetRideOffer(ctx, ride.ID, &driverID, time.Now().Add(30*time.Second)))
+
+	cutoff := time.Now().Add(-10 * time.Minute)
+	stale, err := repo.GetStaleRequestedRides(ctx, cutoff)
+	require.NoError(t, err)
+	require.Len(t, stale, 1)
+
+	require.NoError(t, stale[0].Cancel(domain.CancelledBySystem, "no driver found"))
+	require.NoError(t, repo.ExpireRide(ctx, stale[0], cutoff))
+
+	retrieved, err := repo.GetByID(ctx, ride.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.RideStatusCancelled, retrieved.Status)
+}
+
+func TestRideMongoRepository_ExpireRide_AcceptedSinceFound(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewRideMongoRepository(db)
+	ctx := context.Background()
+
+	ride := &domain.Ride{
+		CustomerID:  1,
+		PickupLat:   23.8100,
+		PickupLng:   90.4120,
+		DropoffLat:  23.7509,
+		DropoffLng:  90.3761,
+		Status:      domain.RideStatusRequested,
+		RequestedAt: time.Now().Add(-30 * time.Minute),
+	}
+	require.NoError(t, repo.Create(ctx, ride))
+
+	cutoff := time.Now().Add(-10 * time.Minute)
+	stale, err := repo.GetStaleRequestedRides(ctx, cutoff)
+	require.NoError(t, err)
+	require.Len(t, stale, 1)
+
+	// A driver accepts the ride between the lookup and the expiry
+	require.NoError(t, repo.AcceptRide(ctx, ride.ID, 456, time.Now()))
+
+	require.NoError(t, stale[0].Cancel(domain.CancelledBySystem, "no driver found"))
+	err = repo.ExpireRide(ctx, stale[0], cutoff)
+	assert.ErrorIs(t, err, repository.ErrRideNotExpirable)
+
+	retrieved, err := repo.GetByID(ctx, ride.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.RideStatusAccepted, retrieved.Status)
+}
+
+func TestRideMongoRepository_GetByCustomerID(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewRideMongoRepository(db)
+	ctx := context.Background()
+
+	customerID := int64(123)
+
+	// Create the customer's past rides
+	for i := 0; i < 3; i++ {
+		ride := &domain.Ride{
+			CustomerID:  customerID,
+			PickupLat:   23.8100,
+			PickupLng:   90.4120,
+			DropoffLat:  23.7509,
+			DropoffLng:  90.3761,
+			Status:      domain.RideStatusCompleted,
+			RequestedAt: time.Now(),
+		}
+		err := repo.Create(ctx, ride)
+		require.NoError(t, err)
+	}
+
+	// Create ride for different customer
+	otherRide := &domain.Ride{
+		CustomerID:  456,
+		PickupLat:   23.8100,
+		PickupLng:   90.4120,
+		DropoffLat:  23.7509,
+		DropoffLng:  90.3761,
+		Status:      domain.RideStatusRequested,
+		RequestedAt: time.Now(),
+	}
+	err := repo.Create(ctx, otherRide)
+	require.NoError(t, err)
+
+	// Get rides by customer ID
+	rides, total, err := repo.GetByCustomerID(ctx, customerID, "", 0, 0)
+	assert.NoError(t, err)
+	assert.Len(t, rides, 3, "Should return only customer's rides")
+	assert.Equal(t, int64(3), total)
+}
+
+func TestRideMongoRepository_GetByCustomerID_Pagination(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewRideMongoRepository(db)
+	ctx := context.Background()
+
+	customerID := int64(123)
+	base := time.Now().Add(-time.Hour)
+
+	// Create 5 rides, one minute apart
+	var rideIDs []int64
+	for i := 0; i < 5; i++ {
+		ride := &domain.Ride{
+			CustomerID:  customerID,
+			PickupLat:   23.8100,
+			PickupLng:   90.4120,
+			DropoffLat:  23.7509,
+			DropoffLng:  90.3761,
+			Status:      domain.RideStatusCompleted,
+			RequestedAt: base.Add(time.Duration(i) * time.Minute),
+		}
+		err := repo.Create(ctx, ride)
+		require.NoError(t, err)
+		rideIDs = append(rideIDs, ride.ID)
+	}
+
+	// First page holds the newest rides
+	page, total, err := repo.GetByCustomerID(ctx, customerID, "", 2, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), total)
+	require.Len(t, page, 2)
+	assert.Equal(t, rideIDs[4], page[0].ID)
+	assert.Equal(t, rideIDs[3], page[1].ID)
+
+	// Last page is partial
+	page, total, err = repo.GetByCustomerID(ctx, customerID, "", 2, 4)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), total)
+	require.Len(t, page, 1)
+	assert.Equal(t, rideIDs[0], page[0].ID)
+
+	// Past the end is empty but still reports the total
+	page, total, err = repo.GetByCustomerID(ctx, customerID, "", 2, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), total)
+	assert.Empty(t, page)
+}
+
+func TestRideMongoRepository_ListRides_Filters(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewRideMongoRepository(db)
+	ctx := context.Background()
+
+	base := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	createRide := func(customerID int64, status domain.RideStatus, requestedAt time.Time) *domain.Ride {
+		ride := &domain.Ride{
+			CustomerID:  customerID,
+			PickupLat:   23.8100,
+			PickupLng:   90.4120,
+			DropoffLat:  23.7509,
+			DropoffLng:  90.3761,
+			Status:      status,
+			RequestedAt: requestedAt,
+		}
+		require.NoError(t, repo.Create(ctx, ride))
+		return ride
+	}
+
+	driverID := int64(456)
+	requested := createRide(1, domain.RideStatusRequested, base)
+	accepted := createRide(2, domain.RideStatusRequested, base.Add(-24*time.Hour))
+	require.NoError(t, repo.AcceptRide(ctx, accepted.ID, driverID, base))
+	older := createRide(1, domain.RideStatusCompleted, base.Add(-48*time.Hour))
+
+	ids := func(rides []*domain.Ride) []int64 {
+		var ids []int64
+		for _, ride := range rides {
+			ids = append(ids, ride.ID)
+		}
+		return ids
+	}
+
+	rides, total, err := repo.ListRides(ctx, repository.RideFilter{}, repository.Page{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), total)
+	assert.Equal(t, []int64{requested.ID, accepted.ID, older.ID}, ids(rides), "newest first")
+
+	rides, total, err = repo.ListRides(ctx, repository.RideFilter{Status: domain.RideStatusAccepted}, repository.Page{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.Equal(t, []int64{accepted.ID}, ids(rides))
+
+	rides, _, err = repo.ListRides(ctx, repository.RideFilter{CustomerID: 1}, repository.Page{})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{requested.ID, older.ID}, ids(rides))
+
+	rides, _, err = repo.ListRides(ctx, repository.RideFilter{Status: domain.RideStatusCompleted, CustomerID: 1}, repository.Page{})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{older.ID}, ids(rides))
+
+	rides, _, err = repo.ListRides(ctx, repository.RideFilter{DriverID: driverID}, repository.Page{})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{accepted.ID}, ids(rides))
+
+	// From is inclusive and To exclusive
+	rides, _, err = repo.ListRides(ctx, repository.RideFilter{From: base.Add(-24 * time.Hour), To: base}, repository.Page{})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{accepted.ID}, ids(rides))
+
+	rides, total, err = repo.ListRides(ctx, repository.RideFilter{Status: domain.RideStatusCancelled}, repository.Page{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), total)
+	assert.Empty(t, rides)
+}
+
+func TestRideMongoRepository_ListRides_Pagination(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewRideMongoRepository(db)
+	ctx := context.Background()
+
+	base := time.Now().Add(-time.Hour)
+	var rideIDs []int64
+	for i := 0; i < 5; i++ {
+		ride := &domain.Ride{
+			CustomerID:  int64(i + 1),
+			PickupLat:   23.8100,
+			PickupLng:   90.4120,
+			DropoffLat:  23.7509,
+			DropoffLng:  90.3761,
+			Status:      domain.RideStatusRequested,
+			RequestedAt: base.Add(time.Duration(i) * time.Minute),
+		}
+		require.NoError(t, repo.Create(ctx, ride))
+		rideIDs = append(rideIDs, ride.ID)
+	}
+
+	// A page ending exactly at the last ride
+	page, total, err := repo.ListRides(ctx, repository.RideFilter{}, repository.Page{Limit: 5, Offset: 0})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), total)
+	assert.Len(t, page, 5)
+
+	// The last ride alone
+	page, total, err = repo.ListRides(ctx, repository.RideFilter{}, repository.Page{Limit: 2, Offset: 4})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), total)
+	require.Len(t, page, 1)
+	assert.Equal(t, rideIDs[0], page[0].ID)
+
+	// Offset equal to the total is empty but still reports the total
+	page, total, err = repo.ListRides(ctx, repository.RideFilter{}, repository.Page{Limit: 2, Offset: 5})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), total)
+	assert.Empty(t, page)
+}
+
+func TestRideMongoRepository_ListRides_Cursor(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewRideMongoRepository(db)
+	ctx := context.Background()
+
+	// Rides requested in the same millisecond are ordered by ride ID
+	requestedAt := time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+	var rideIDs []int64
+	for i := 0; i < 4; i++ {
+		ride := &domain.Ride{
+			CustomerID:  int64(i + 1),
+			PickupLat:   23.8100,
+			PickupLng:   90.4120,
+			DropoffLat:  23.7509,
+			DropoffLng:  90.3761,
+			Status:      domain.RideStatusRequested,
+			RequestedAt: requestedAt,
+		}
+		require.NoError(t, repo.Create(ctx, ride))
+		rideIDs = append(rideIDs, ride.ID)
+	}
+
+	page, total, err := repo.ListRides(ctx, repository.RideFilter{}, repository.Page{Limit: 2})
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), total)
+	require.Len(t, page, 2)
+	assert.Equal(t, []int64{rideIDs[3], rideIDs[2]}, []int64{page[0].ID, page[1].ID})
+
+	cursor := &pagination.Cursor{Sort: "requested_at", Value: page[1].RequestedAt, ID: page[1].ID}
+	page, total, err = repo.ListRides(ctx, repository.RideFilter{}, repository.Page{Limit: 2, Offset: 3, Cursor: cursor})
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), total, "The total counts the rides before the cursor too")
+	require.Len(t, page, 2, "The offset is ignored with a cursor")
+	assert.Equal(t, []int64{rideIDs[1], rideIDs[0]}, []int64{page[0].ID, page[1].ID})
+}
+
+func TestRideMongoRepository_GetByCustomerID_StatusFilter(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewRideMongoRepository(db)
+	ctx := context.Background()
+
+	customerID := int64(123)
+
+	statuses := []domain.RideStatus{
+		domain.RideStatusCompleted,
+		domain.RideStatusCompleted,
+		domain.RideStatusCancelled,
+		domain.RideStatusRequested,
+	}
+	for _, status := range statuses {
+		ride := &domain.Ride{
+			CustomerID:  customerID,
+			PickupLat:   23.8100,
+			PickupLng:   90.4120,
+			DropoffLat:  23.7509,
+			DropoffLng:  90.3761,
+			Status:      status,
+			RequestedAt: time.Now(),
+		}
+		err := repo.Create(ctx, ride)
+		require.NoError(t, err)
+	}
+
+	// Completed ride for another customer must not leak into the results
+	otherRide := &domain.Ride{
+		CustomerID:  456,
+		PickupLat:   23.8100,
+		PickupLng:   90.4120,
+		DropoffLat:  23.7509,
+		DropoffLng:  90.3761,
+		Status:      domain.RideStatusCompleted,
+		RequestedAt: time.Now(),
+	}
+	err := repo.Create(ctx, otherRide)
+	require.NoError(t, err)
+
+	rides, total, err := repo.GetByCustomerID(ctx, customerID, domain.RideStatusCompleted, 0, 0)
+	assert.NoError(t, err)
+	assert.Len(t, rides, 2)
+	assert.Equal(t, int64(2), total, "Total should count only rides matching the status")
+	for _, ride := range rides {
+		assert.Equal(t, customerID, ride.CustomerID)
+		assert.Equal(t, domain.RideStatusCompleted, ride.Status)
+	}
+
+	// No rides in this status
+	rides, total, err = repo.GetByCustomerID(ctx, customerID, domain.RideStatusStarted, 0, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, rides)
+	assert.Equal(t, int64(0), total)
+}
+
+func TestRideMongoRepository_GetCompletedByDriverID(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewRideMongoRepository(db)
+	ctx := context.Background()
+
+	driverID := int64(456)
+	otherDriverID := int64(789)
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 1, 8, 0, 0, 0, 0, time.UTC)
+
+	createRide := func(driverID int64, status domain.RideStatus, completedAt time.Time) *domain.Ride {
+		fare := 100.0
+		ride := &domain.Ride{
+			CustomerID:  123,
+			DriverID:    &driverID,
+			PickupLat:   23.8100,
+			PickupLng:   90.4120,
+			DropoffLat:  23.7509,
+			DropoffLng:  90.3761,
+			Status:      status,
+			Fare:        &fare,
+			RequestedAt: completedAt.Add(-30 * time.Minute),
+			CompletedAt: &completedAt,
+		}
+		err := repo.Create(ctx, ride)
+		require.NoError(t, err)
+		return ride
+	}
+
+	inRange := createRide(driverID, domain.RideStatusCompleted, from.Add(2*24*time.Hour))
+	lastInRange := createRide(driverID, domain.RideStatusCompleted, to.Add(-time.Minute))
+	createRide(driverID, domain.RideStatusCompleted, from.Add(-time.Minute)) // before range
+	createRide(driverID, domain.RideStatusCompleted, to)                     // end is exclusive
+	createRide(driverID, domain.RideStatusStarted, from.Add(24*time.Hour))   // not completed
+	createRide(otherDriverID, domain.RideStatusCompleted, from.Add(24*time.Hour))
+
+	rides, err := repo.GetCompletedByDriverID(ctx, driverID, from, to)
+	assert.NoError(t, err)
+	require.Len(t, rides, 2)
+	assert.Equal(t, lastInRange.ID, rides[0].ID, "Most recently completed ride should come first")
+	assert.Equal(t, inRange.ID, rides[1].ID)
+
+	// Driver with no completed rides
+	rides, err = repo.GetCompletedByDriverID(ctx, int64(999), from, to)
+	assert.NoError(t, err)
+	assert.Empty(t, rides)
+}
+
+func TestRideMongoRepository_GetCompletedRides(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewRideMongoRepository(db)
+	ctx := context.Background()
+
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 1, 8, 0, 0, 0, 0, time.UTC)
+
+	createRide := func(driverID int64, status domain.RideStatus, completedAt time.Time, commissionRate *float64) *domain.Ride {
+		fare := 100.0
+		ride := &domain.Ride{
+			CustomerID:     123,
+			DriverID:       &driverID,
+			PickupLat:      23.8100,
+			PickupLng:      90.4120,
+			DropoffLat:     23.7509,
+			DropoffLng:     90.3761,
+			Status:         status,
+			Fare:           &fare,
+			CommissionRate: commissionRate,
+			RequestedAt:    completedAt.Add(-30 * time.Minute),
+			CompletedAt:    &completedAt,
+		}
+		err := repo.Create(ctx, ride)
+		require.NoError(t, err)
+		return ride
+	}
+
+	rate := 0.15
+	inRange := createRide(456, domain.RideStatusCompleted, from.Add(2*24*time.Hour), &rate)
+	otherDriver := createRide(789, domain.RideStatusCompleted, to.Add(-time.Minute), nil)
+	createRide(456, domain.RideStatusCompleted, from.Add(-time.Minute), &rate) // before range
+	createRide(456, domain.RideStatusCompleted, to, &rate)                     // end is exclusive
+	createRide(456, domain.RideStatusStarted, from.Add(24*time.Hour), nil)     // not completed
+
+	rides, err := repo.GetCompletedRides(ctx, from, to)
+	assert.NoError(t, err)
+	require.Len(t, rides, 2)
+	assert.Equal(t, otherDriver.ID, rides[0].ID, "Most recently completed ride should come first")
+	assert.Nil(t, rides[0].CommissionRate)
+	assert.Equal(t, inRange.ID, rides[1].ID)
+	require.NotNil(t, rides[1].CommissionRate)
+	assert.Equal(t, rate, *rides[1].CommissionRate)
+}
+
+func TestRideMongoRepository_GetByDriverID(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewRideMongoRepository(db)
+	ctx := context.Background()
+
+	driverID := int64(456)
+
+	// Create and accept rides
+	for i := 0; i < 2; i++ {
+		ride := &domain.Ride{
+			CustomerID:  int64(i + 1),
+			PickupLat:   23.8100,
+			PickupLng:   90.4120,
+			DropoffLat:  23.7509,
+			DropoffLng:  90.3761,
+			Status:      domain.RideStatusRequested,
+			RequestedAt: time.Now(),
+		}
+		err := repo.Create(ctx, ride)
+		require.NoError(t, err)
+
+		// Accept ride
+		err = ride.Accept(driverID)
+		require.NoError(t, err)
+		err = repo.Update(ctx, ride, domain.RideStatusRequested)
+		require.NoError(t, err)
+	}
+
+	// Get rides by driver ID
+	rides, total, err := repo.GetByDriverID(ctx, driverID, 0, 0)
+	assert.NoError(t, err)
+	assert.Len(t, rides, 2, "Should return driver's rides")
+	assert.Equal(t, int64(2), total)
+
+	// Page size smaller than total
+	rides, total, err = repo.GetByDriverID(ctx, driverID, 1, 1)
+	assert.NoError(t, err)
+	assert.Len(t, rides, 1)
+	assert.Equal(t, int64(2), total)
+}
+
+func TestRideMongoRepository_GetActiveRideByDriverID(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewRideMongoRepository(db)
+	ctx := context.Background()
+
+	driverID := int64(456)
+	createRide := func(status domain.RideStatus) *domain.Ride {
+		acceptedAt := time.Now()
+		ride := &domain.Ride{
+			CustomerID:  123,
+			DriverID:    &driverID,
+			PickupLat:   23.8100,
+			PickupLng:   90.4120,
+			DropoffLat:  23.7509,
+			DropoffLng:  90.3761,
+			Status:      status,
+			RequestedAt: acceptedAt.Add(-5 * time.Minute),
+			AcceptedAt:  &acceptedAt,
+		}
+		err := repo.Create(ctx, ride)
+		require.NoError(t, err)
+		return ride
+	}
+
+	createRide(domain.RideStatusCompleted)
+	createRide(domain.RideStatusCancelled)
+
+	_, err := repo.GetActiveRideByDriverID(ctx, driverID)
+	assert.ErrorIs(t, err, ErrRideNotFound, "Finished rides are not active")
+
+	active := createRide(domain.RideStatusStarted)
+
+	ride, err := repo.GetActiveRideByDriverID(ctx, driverID)
+	assert.NoError(t, err)
+	require.NotNil(t, ride)
+	assert.Equal(t, active.ID, ride.ID)
+}
+
+func TestRideMongoRepository_GetActiveRideByCustomerID(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewRideMongoRepository(db)
+	ctx := context.Background()
+
+	customerID := int64(123)
+	createRide := func(customerID int64, status domain.RideStatus) *domain.Ride {
+		ride := &domain.Ride{
+			CustomerID:  customerID,
+			PickupLat:   23.8100,
+			PickupLng:   90.4120,
+			DropoffLat:  23.7509,
+			DropoffLng:  90.3761,
+			Status:      status,
+			RequestedAt: time.Now(),
+		}
+		err := repo.Create(ctx, ride)
+		require.NoError(t, err)
+		return ride
+	}
+
+	createRide(customerID, domain.RideStatusCompleted)
+	createRide(customerID, domain.RideStatusCancelled)
+	createRide(789, domain.RideStatusStarted)
+
+	_, err := repo.GetActiveRideByCustomerID(ctx, customerID)
+	assert.ErrorIs(t, err, ErrRideNotFound, "Finished rides and other customers' rides are not active")
+
+	active := createRide(customerID, domain.RideStatusRequested)
+
+	ride, err := repo.GetActiveRideByCustomerID(ctx, customerID)
+	assert.NoError(t, err)
+	require.NotNil(t, ride)
+	assert.Equal(t, active.ID, ride.ID, "A ride waiting for a driver is active")
+}
+
+func TestRideMongoRepository_GetBusyDriverIDs(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewRideMongoRepository(db)
+	ctx := context.Background()
+
+	createRide := func(driverID int64, status domain.RideStatus) {
+		acceptedAt := time.Now()
+		ride := &domain.Ride{
+			CustomerID:  nextCustomerID(),
+			DriverID:    &driverID,
+			PickupLat:   23.8100,
+			PickupLng:   90.4120,
+			DropoffLat:  23.7509,
+			DropoffLng:  90.3761,
+			Status:      status,
+			RequestedAt: acceptedAt.Add(-5 * time.Minute),
+			AcceptedAt:  &acceptedAt,
+		}
+		require.NoError(t, repo.Create(ctx, ride))
+	}
+
+	createRide(1, domain.RideStatusStarted)
+	createRide(2, domain.RideStatusAccepted)
+	createRide(2, domain.RideStatusCompleted)
+	createRide(3, domain.RideStatusCompleted)
+	createRide(4, domain.RideStatusArrived)
+
+	busy, err := repo.GetBusyDriverIDs(ctx, []int64{1, 2, 3, 5})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []int64{1, 2}, busy, "Only drivers with an active ride among the checked ones are busy")
+
+	busy, err = repo.GetBusyDriverIDs(ctx, nil)
+	require.NoError(t, err)
+	assert.Empty(t, busy)
+}
+
+func TestRideMongoRepository_GetActiveRideByDriverID_Arrived(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewRideMongoRepository(db)
+	ctx := context.Background()
+
+	driverID := int64(456)
+	acceptedAt := time.Now()
+	ride := &domain.Ride{
+		CustomerID:  123,
+		DriverID:    &driverID,
+		PickupLat:   23.8100,
+		PickupLng:   90.4120,
+		DropoffLat:  23.7509,
+		DropoffLng:  90.3761,
+		Status:      domain.RideStatusArrived,
+		RequestedAt: acceptedAt.Add(-5 * time.Minute),
+		AcceptedAt:  &acceptedAt,
+		ArrivedAt:   &acceptedAt,
+	}
+	require.NoError(t, repo.Create(ctx, ride))
+
+	active, err := repo.GetActiveRideByDriverID(ctx, driverID)
+	require.NoError(t, err)
+	assert.Equal(t, ride.ID, active.ID, "A driver waiting at the pickup has an active ride")
 }
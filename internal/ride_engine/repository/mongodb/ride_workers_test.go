@@ -0,0 +1,161 @@
+package mongodb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/worker"
+)
+
+// repoRideReleaser releases stale accepted rides the way RideService does, without the dispatch that follows
+type repoRideReleaser struct {
+	repo *RideMongoRepository
+}
+
+func (r repoRideReleaser) ReleaseStaleAcceptedRides(ctx context.Context, cutoff time.Time) (int, error) {
+	rides, err := r.repo.GetStaleAcceptedRides(ctx, cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	released := 0
+	for _, ride := range rides {
+		driverID := *ride.DriverID
+		if err := ride.Abandon(driverID, "not started in time", time.Now()); err != nil {
+			return released, err
+		}
+		if err := r.repo.AbandonRide(ctx, ride.ID, ride.Abandonments[len(ride.Abandonments)-1]); err != nil {
+			return released, err
+		}
+		released++
+	}
+	return released, nil
+}
+
+// repoRideExpirer expires stale requested rides the way RideService does, without the events and notifications
+type repoRideExpirer struct {
+	repo *RideMongoRepository
+}
+
+func (r repoRideExpirer) ExpireStaleRequestedRides(ctx context.Context, cutoff time.Time) (int, error) {
+	rides, err := r.repo.GetStaleRequestedRides(ctx, cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, ride := range rides {
+		if err := ride.Cancel(domain.CancelledBySystem, "no driver found"); err != nil {
+			return expired, err
+		}
+		if err := r.repo.ExpireRide(ctx, ride, cutoff); err != nil {
+			return expired, err
+		}
+		expired++
+	}
+	return expired, nil
+}
+
+// runUntilTicked starts a worker with a millisecond interval, lets it tick a few times and stops it
+func runUntilTicked(t *testing.T, start func(ctx context.Context)) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		start(ctx)
+		close(done)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("worker did not stop after the context was cancelled")
+	}
+}
+
+func TestRideExpiryWorker_KeepsAbandonedRideWaiting(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewRideMongoRepository(db)
+	ctx := context.Background()
+
+	// Requested long before the request timeout, then accepted and abandoned just now
+	driverID := int64(456)
+	acceptedAt := time.Now().Add(-15 * time.Minute)
+	ride := &domain.Ride{
+		CustomerID:  1,
+		DriverID:    &driverID,
+		PickupLat:   23.8100,
+		PickupLng:   90.4120,
+		DropoffLat:  23.7509,
+		DropoffLng:  90.3761,
+		Status:      domain.RideStatusAccepted,
+		RequestedAt: time.Now().Add(-30 * time.Minute),
+		AcceptedAt:  &acceptedAt,
+	}
+	require.NoError(t, repo.Create(ctx, ride))
+	require.NoError(t, ride.Abandon(driverID, "flat tyre", time.Now()))
+	require.NoError(t, repo.AbandonRide(ctx, ride.ID, ride.Abandonments[0]))
+
+	expiryWorker := worker.NewRideExpiryWorker(repoRideExpirer{repo: repo}, 10*time.Minute, time.Millisecond)
+	runUntilTicked(t, expiryWorker.Start)
+
+	retrieved, err := repo.GetByID(ctx, ride.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.RideStatusRequested, retrieved.Status, "The request timeout starts over when the driver abandons the ride")
+	require.NotNil(t, retrieved.RequeuedAt)
+
+	// Once the ride has waited the full timeout since it was requeued it expires
+	expired, err := repoRideExpirer{repo: repo}.ExpireStaleRequestedRides(ctx, retrieved.RequeuedAt.Add(time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, 1, expired)
+}
+
+func TestAcceptedRideReleaseWorker_ReleasedRideIsNotExpired(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewRideMongoRepository(db)
+	ctx := context.Background()
+
+	// With the acceptance timeout longer than the request timeout, a released ride is always past the request timeout
+	driverID := int64(456)
+	acceptedAt := time.Now().Add(-25 * time.Minute)
+	ride := &domain.Ride{
+		CustomerID:  1,
+		DriverID:    &driverID,
+		PickupLat:   23.8100,
+		PickupLng:   90.4120,
+		DropoffLat:  23.7509,
+		DropoffLng:  90.3761,
+		Status:      domain.RideStatusAccepted,
+		RequestedAt: acceptedAt.Add(-time.Minute),
+		AcceptedAt:  &acceptedAt,
+	}
+	require.NoError(t, repo.Create(ctx, ride))
+
+	releaseWorker := worker.NewAcceptedRideReleaseWorker(repoRideReleaser{repo: repo}, 20*time.Minute, time.Millisecond)
+	runUntilTicked(t, releaseWorker.Start)
+
+	released, err := repo.GetByID(ctx, ride.ID)
+	require.NoError(t, err)
+	require.Equal(t, domain.RideStatusRequested, released.Status)
+	require.NotNil(t, released.RequeuedAt)
+
+	expiryWorker := worker.NewRideExpiryWorker(repoRideExpirer{repo: repo}, 10*time.Minute, time.Millisecond)
+	runUntilTicked(t, expiryWorker.Start)
+
+	retrieved, err := repo.GetByID(ctx, ride.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.RideStatusRequested, retrieved.Status, "A released ride gets the full request timeout to find another driver")
+	assert.Nil(t, retrieved.CancelledAt)
+}
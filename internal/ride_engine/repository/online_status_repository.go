@@ -18,9 +18,10 @@ type OnlineDriver struct {
 
 type OnlineStatusRepository interface {
 	UpsertOnlineDriver(ctx context.Context, driverID int64, lat, lng float64) error
-	SetDriverOffline(ctx context.Context, driverID int64) error
+	SetDriverOffline(ctx context.Context, driverID int64) (*OnlineDriver, error) // the removed record, nil if the driver was not online
 	IsDriverOnline(ctx context.Context, driverID int64) (bool, error)
 	GetOnlineDrivers(ctx context.Context) ([]int64, error)
-	RemoveInactiveDrivers(ctx context.Context, cutoffTime time.Time) error
+	GetOnlineDriversCount(ctx context.Context) (int64, error)
+	RemoveInactiveDrivers(ctx context.Context, cutoffTime time.Time) ([]OnlineDriver, error) // the removed records
 	GetOnlineDriversByIDs(ctx context.Context, driverIDs []int64) ([]int64, error)
 }
@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// OTPFilter narrows the OTP history of a phone, zero fields do not filter
+type OTPFilter struct {
+	Phone   string
+	Purpose string
+	From    time.Time // created at or after, zero for no lower bound
+	To      time.Time // created before, zero for no upper bound
+}
+
+// OTPRecord is the audit metadata of an OTP that was sent, the code itself is never exposed
+type OTPRecord struct {
+	ID         int64      `json:"id"`
+	Phone      string     `json:"phone"`
+	Purpose    string     `json:"purpose"`
+	IsVerified bool       `json:"is_verified"`
+	IsExpired  bool       `json:"is_expired"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	VerifiedAt *time.Time `json:"verified_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type OTPRepository interface {
+	SaveOTP(ctx context.Context, phone, otp, purpose string, expiresAt time.Time) error
+	VerifyOTP(ctx context.Context, phone, otp, purpose string) (bool, error)
+	MarkExpired(ctx context.Context, phone string) error
+	GetOTPHistory(ctx context.Context, filter OTPFilter, page Page) ([]OTPRecord, int64, error) // newest first, with the total matching filter
+	CleanupExpiredOTPs(ctx context.Context, olderThan time.Time) error
+}
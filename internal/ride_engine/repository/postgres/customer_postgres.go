@@ -10,8 +10,8 @@ import (
 )
 
 var (
-	ErrCustomerNotFound      = errors.New("customer not found")
-	ErrCustomerAlreadyExists = errors.New("customer already exists")
+	ErrCustomerNotFound      = domain.NewError(domain.ErrNotFound, "customer not found")
+	ErrCustomerAlreadyExists = domain.NewError(domain.ErrConflict, "customer already exists")
 )
 
 type CustomerPostgresRepository struct {
@@ -34,19 +34,23 @@ func toCustomerModel(customer *domain.Customer, password string) *CustomerModel
 }
 
 func toCustomerDomain(model *CustomerModel) *domain.Customer {
-	return &domain.Customer{
+	customer := &domain.Customer{
 		ID:        model.ID,
 		Name:      model.Name,
 		Email:     model.Email,
 		Phone:     model.Phone,
 		CreatedAt: model.CreatedAt,
 	}
+	if model.DeletedAt.Valid {
+		customer.DeletedAt = &model.DeletedAt.Time
+	}
+	return customer
 }
 
 func (r *CustomerPostgresRepository) Create(ctx context.Context, customer *domain.Customer, password string) error {
 	model := toCustomerModel(customer, password)
 
-	result := r.db.WithContext(ctx).Create(model)
+	result := r.db.Conn(ctx).Create(model)
 	if result.Error != nil {
 		logger.Error(ctx, "error creating customer", result.Error)
 		if errors.Is(result.Error, gorm.ErrDuplicatedKey) {
@@ -62,7 +66,23 @@ func (r *CustomerPostgresRepository) Create(ctx context.Context, customer *domai
 func (r *CustomerPostgresRepository) GetByID(ctx context.Context, id int64) (*domain.Customer, error) {
 	var model CustomerModel
 
-	result := r.db.WithContext(ctx).Where("id = ?", id).First(&model)
+	result := r.db.Conn(ctx).Where("id = ?", id).First(&model)
+	if result.Error != nil {
+		logger.Error(ctx, "error getting customer", result.Error)
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, ErrCustomerNotFound
+		}
+		return nil, result.Error
+	}
+
+	return toCustomerDomain(&model), nil
+}
+
+// GetByIDWithDeleted is GetByID including soft-deleted customers, so past rides still show who took them
+func (r *CustomerPostgresRepository) GetByIDWithDeleted(ctx context.Context, id int64) (*domain.Customer, error) {
+	var model CustomerModel
+
+	result := r.db.Conn(ctx).Unscoped().Where("id = ?", id).First(&model)
 	if result.Error != nil {
 		logger.Error(ctx, "error getting customer", result.Error)
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
@@ -77,7 +97,7 @@ func (r *CustomerPostgresRepository) GetByID(ctx context.Context, id int64) (*do
 func (r *CustomerPostgresRepository) GetByEmail(ctx context.Context, email string) (*domain.Customer, string, error) {
 	var model CustomerModel
 
-	result := r.db.WithContext(ctx).Where("email = ?", email).First(&model)
+	result := r.db.Conn(ctx).Where("email = ?", email).First(&model)
 	if result.Error != nil {
 		logger.Error(ctx, "error getting customer", result.Error)
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
@@ -92,7 +112,7 @@ func (r *CustomerPostgresRepository) GetByEmail(ctx context.Context, email strin
 func (r *CustomerPostgresRepository) GetByPhone(ctx context.Context, phone string) (*domain.Customer, error) {
 	var model CustomerModel
 
-	result := r.db.WithContext(ctx).Where("phone = ?", phone).First(&model)
+	result := r.db.Conn(ctx).Where("phone = ?", phone).First(&model)
 	if result.Error != nil {
 		logger.Error(ctx, "error getting customer", result.Error)
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
@@ -105,7 +125,7 @@ func (r *CustomerPostgresRepository) GetByPhone(ctx context.Context, phone strin
 }
 
 func (r *CustomerPostgresRepository) Update(ctx context.Context, customer *domain.Customer) error {
-	result := r.db.WithContext(ctx).Model(&CustomerModel{}).
+	result := r.db.Conn(ctx).Model(&CustomerModel{}).
 		Where("id = ?", customer.ID).
 		Updates(map[string]interface{}{
 			"name":  customer.Name,
@@ -115,6 +135,9 @@ func (r *CustomerPostgresRepository) Update(ctx context.Context, customer *domai
 
 	if result.Error != nil {
 		logger.Error(ctx, "error updating customer", result.Error)
+		if errors.Is(result.Error, gorm.ErrDuplicatedKey) {
+			return ErrCustomerAlreadyExists
+		}
 		return result.Error
 	}
 
@@ -126,8 +149,27 @@ func (r *CustomerPostgresRepository) Update(ctx context.Context, customer *domai
 	return nil
 }
 
+func (r *CustomerPostgresRepository) UpdatePassword(ctx context.Context, id int64, hashedPassword string) error {
+	result := r.db.Conn(ctx).Model(&CustomerModel{}).
+		Where("id = ?", id).
+		Update("password", hashedPassword)
+
+	if result.Error != nil {
+		logger.Error(ctx, "error updating customer password", result.Error)
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		logger.Error(ctx, "error updating customer password", ErrCustomerNotFound)
+		return ErrCustomerNotFound
+	}
+
+	return nil
+}
+
+// Delete soft-deletes the customer, they can no longer log in or be looked up but their rides keep referencing them
 func (r *CustomerPostgresRepository) Delete(ctx context.Context, id int64) error {
-	result := r.db.WithContext(ctx).Where("id = ?", id).Delete(&CustomerModel{})
+	result := r.db.Conn(ctx).Where("id = ?", id).Delete(&CustomerModel{})
 
 	if result.Error != nil {
 		logger.Error(ctx, "error deleting customer", result.Error)
@@ -0,0 +1,25 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+)
+
+func TestCustomerPostgresRepository_Create_AlreadyExists(t *testing.T) {
+	db := newUniqueViolationTestDB(t, "idx_customers_phone_active")
+	repo := NewCustomerPostgresRepository(db)
+
+	err := repo.Create(context.Background(), &domain.Customer{
+		Name:      "Rahim",
+		Email:     "rahim@example.com",
+		Phone:     "+8801711000000",
+		CreatedAt: time.Now(),
+	}, "hash")
+
+	assert.ErrorIs(t, err, ErrCustomerAlreadyExists)
+	assert.NotContains(t, err.Error(), "idx_customers_phone_active", "The constraint name should not reach the client")
+}
@@ -0,0 +1,107 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm/clause"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/repository"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/database"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/logger"
+)
+
+// DeviceTokenModel represents the device_tokens table
+type DeviceTokenModel struct {
+	ID        int64     `gorm:"primaryKey;autoIncrement"`
+	UserRole  string    `gorm:"type:varchar(20);not null;index:idx_device_tokens_user"`
+	UserID    int64     `gorm:"not null;index:idx_device_tokens_user"`
+	Token     string    `gorm:"type:varchar(512);not null;uniqueIndex"`
+	CreatedAt time.Time `gorm:"not null;default:CURRENT_TIMESTAMP"`
+	UpdatedAt time.Time `gorm:"not null;default:CURRENT_TIMESTAMP"`
+}
+
+func (DeviceTokenModel) TableName() string {
+	return "device_tokens"
+}
+
+type DeviceTokenPostgresRepository struct {
+	db *database.PostgresDB
+}
+
+func NewDeviceTokenPostgresRepository(db *database.PostgresDB) *DeviceTokenPostgresRepository {
+	return &DeviceTokenPostgresRepository{db: db}
+}
+
+// Upsert registers the token to the user, a token already registered to another user moves to this one
+// since a device only belongs to whoever is logged in on it
+func (r *DeviceTokenPostgresRepository) Upsert(ctx context.Context, token *domain.DeviceToken) error {
+	model := &DeviceTokenModel{
+		UserRole:  token.UserRole,
+		UserID:    token.UserID,
+		Token:     token.Token,
+		CreatedAt: token.CreatedAt,
+		UpdatedAt: token.UpdatedAt,
+	}
+
+	result := r.db.Conn(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "token"}},
+		DoUpdates: clause.AssignmentColumns([]string{"user_role", "user_id", "updated_at"}),
+	}).Create(model)
+	if result.Error != nil {
+		logger.Error(ctx, "error registering device token", result.Error)
+		return result.Error
+	}
+
+	token.ID = model.ID
+	return nil
+}
+
+// Delete removes the token only when it is registered to the user
+func (r *DeviceTokenPostgresRepository) Delete(ctx context.Context, userRole string, userID int64, token string) error {
+	result := r.db.Conn(ctx).
+		Where("user_role = ? AND user_id = ? AND token = ?", userRole, userID, token).
+		Delete(&DeviceTokenModel{})
+
+	if result.Error != nil {
+		logger.Error(ctx, "error deleting device token", result.Error)
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		logger.Error(ctx, "error deleting device token", repository.ErrDeviceTokenNotFound)
+		return repository.ErrDeviceTokenNotFound
+	}
+
+	return nil
+}
+
+// DeleteByUser removes every token registered to the user
+func (r *DeviceTokenPostgresRepository) DeleteByUser(ctx context.Context, userRole string, userID int64) error {
+	result := r.db.Conn(ctx).
+		Where("user_role = ? AND user_id = ?", userRole, userID).
+		Delete(&DeviceTokenModel{})
+
+	if result.Error != nil {
+		logger.Error(ctx, "error deleting device tokens of user", result.Error)
+		return result.Error
+	}
+
+	return nil
+}
+
+// ListTokens returns the tokens registered to the user, most recently registered first
+func (r *DeviceTokenPostgresRepository) ListTokens(ctx context.Context, userRole string, userID int64) ([]string, error) {
+	var tokens []string
+
+	result := r.db.Conn(ctx).Model(&DeviceTokenModel{}).
+		Where("user_role = ? AND user_id = ?", userRole, userID).
+		Order("updated_at DESC").
+		Pluck("token", &tokens)
+	if result.Error != nil {
+		logger.Error(ctx, "error listing device tokens", result.Error)
+		return nil, result.Error
+	}
+
+	return tokens, nil
+}
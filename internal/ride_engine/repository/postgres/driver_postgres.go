@@ -12,8 +12,8 @@ import (
 )
 
 var (
-	ErrDriverNotFound      = errors.New("driver not found")
-	ErrDriverAlreadyExists = errors.New("driver already exists")
+	ErrDriverNotFound      = domain.NewError(domain.ErrNotFound, "driver not found")
+	ErrDriverAlreadyExists = domain.NewError(domain.ErrConflict, "driver already exists")
 )
 
 type DriverPostgresRepository struct {
@@ -30,6 +30,7 @@ func toDriverModel(driver *domain.Driver) *DriverModel {
 		Name:          driver.Name,
 		Phone:         driver.Phone,
 		VehicleNo:     driver.VehicleNo,
+		VehicleType:   string(driver.VehicleType),
 		IsOnline:      driver.IsOnline,
 		CurrentLat:    driver.CurrentLat,
 		CurrentLng:    driver.CurrentLng,
@@ -45,6 +46,7 @@ func toDriverDomain(model *DriverModel) *domain.Driver {
 		Name:          model.Name,
 		Phone:         model.Phone,
 		VehicleNo:     model.VehicleNo,
+		VehicleType:   domain.RideType(model.VehicleType),
 		IsOnline:      model.IsOnline,
 		CurrentLat:    model.CurrentLat,
 		CurrentLng:    model.CurrentLng,
@@ -57,7 +59,7 @@ func toDriverDomain(model *DriverModel) *domain.Driver {
 func (r *DriverPostgresRepository) Create(ctx context.Context, driver *domain.Driver) error {
 	model := toDriverModel(driver)
 
-	result := r.db.WithContext(ctx).Create(model)
+	result := r.db.Conn(ctx).Create(model)
 	if result.Error != nil {
 		logger.Error(ctx, "Failed to create driver model", result.Error)
 		if errors.Is(result.Error, gorm.ErrDuplicatedKey) {
@@ -73,7 +75,7 @@ func (r *DriverPostgresRepository) Create(ctx context.Context, driver *domain.Dr
 func (r *DriverPostgresRepository) GetByID(ctx context.Context, id int64) (*domain.Driver, error) {
 	var model DriverModel
 
-	result := r.db.WithContext(ctx).Where("id = ?", id).First(&model)
+	result := r.db.Conn(ctx).Where("id = ?", id).First(&model)
 	if result.Error != nil {
 		logger.Error(ctx, "Failed to get driver model", result.Error)
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
@@ -88,20 +90,44 @@ func (r *DriverPostgresRepository) GetByID(ctx context.Context, id int64) (*doma
 func (r *DriverPostgresRepository) GetByPhone(ctx context.Context, phone string) (*domain.Driver, error) {
 	var model DriverModel
 
-	result := r.db.WithContext(ctx).Where("phone = ?", phone).First(&model)
+	result := r.db.Conn(ctx).Where("phone = ?", phone).First(&model)
 	if result.Error != nil {
-		logger.Error(ctx, "Failed to get driver model", result.Error)
+		// Not found is expected, registration looks the phone up to check it is free
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
 			return nil, ErrDriverNotFound
 		}
+		logger.Error(ctx, "Failed to get driver model", result.Error)
 		return nil, result.Error
 	}
 
 	return toDriverDomain(&model), nil
 }
 
+// Update saves the driver's editable profile fields, name and vehicle number
+// Phone, vehicle type and online state are left untouched
+func (r *DriverPostgresRepository) Update(ctx context.Context, driver *domain.Driver) error {
+	now := time.Now()
+	result := r.db.Conn(ctx).Model(&DriverModel{}).
+		Where("id = ?", driver.ID).
+		Updates(map[string]interface{}{
+			"name":            driver.Name,
+			"vehicle_no":      driver.VehicleNo,
+			"last_updated_at": now,
+		})
+	if result.Error != nil {
+		logger.Error(ctx, "Failed to update driver model", result.Error)
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrDriverNotFound
+	}
+
+	driver.LastUpdatedAt = &now
+	return nil
+}
+
 func (r *DriverPostgresRepository) UpdatePing(ctx context.Context, driverID int64, lat, lng float64, pingTime time.Time) error {
-	return r.db.WithContext(ctx).Model(&DriverModel{}).
+	return r.db.Conn(ctx).Model(&DriverModel{}).
 		Where("id = ?", driverID).
 		Updates(map[string]interface{}{
 			"current_lat":     lat,
@@ -113,7 +139,7 @@ func (r *DriverPostgresRepository) UpdatePing(ctx context.Context, driverID int6
 }
 
 func (r *DriverPostgresRepository) SetOnlineStatus(ctx context.Context, driverID int64, isOnline bool) error {
-	return r.db.WithContext(ctx).Model(&DriverModel{}).
+	return r.db.Conn(ctx).Model(&DriverModel{}).
 		Where("id = ?", driverID).
 		Update("is_online", isOnline).Error
 }
@@ -121,7 +147,7 @@ func (r *DriverPostgresRepository) SetOnlineStatus(ctx context.Context, driverID
 func (r *DriverPostgresRepository) GetOnlineDrivers(ctx context.Context) ([]*domain.Driver, error) {
 	var models []DriverModel
 
-	result := r.db.WithContext(ctx).Where("is_online = ?", true).Find(&models)
+	result := r.db.Conn(ctx).Where("is_online = ?", true).Find(&models)
 	if result.Error != nil {
 		logger.Error(ctx, "Failed to get online drivers", result.Error)
 		return nil, result.Error
@@ -136,7 +162,7 @@ func (r *DriverPostgresRepository) GetOnlineDrivers(ctx context.Context) ([]*dom
 }
 
 func (r *DriverPostgresRepository) MarkOfflineIfInactive(ctx context.Context, cutoff time.Time) error {
-	return r.db.WithContext(ctx).Model(&DriverModel{}).
+	return r.db.Conn(ctx).Model(&DriverModel{}).
 		Where("last_ping_at < ? AND is_online = ?", cutoff, true).
 		Update("is_online", false).Error
 }
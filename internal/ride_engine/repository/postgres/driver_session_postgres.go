@@ -0,0 +1,79 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/database"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/logger"
+)
+
+// DriverSessionModel represents the driver_sessions table
+type DriverSessionModel struct {
+	ID        int64     `gorm:"primaryKey;autoIncrement"`
+	DriverID  int64     `gorm:"not null;index:idx_driver_sessions_driver_started"`
+	StartedAt time.Time `gorm:"not null;index:idx_driver_sessions_driver_started"`
+	EndedAt   time.Time `gorm:"not null"`
+	CreatedAt time.Time `gorm:"not null;default:CURRENT_TIMESTAMP"`
+}
+
+func (DriverSessionModel) TableName() string {
+	return "driver_sessions"
+}
+
+type DriverSessionPostgresRepository struct {
+	db *database.PostgresDB
+}
+
+func NewDriverSessionPostgresRepository(db *database.PostgresDB) *DriverSessionPostgresRepository {
+	return &DriverSessionPostgresRepository{db: db}
+}
+
+// Create archives a completed online session
+func (r *DriverSessionPostgresRepository) Create(ctx context.Context, session *domain.DriverSession) error {
+	model := &DriverSessionModel{
+		DriverID:  session.DriverID,
+		StartedAt: session.StartedAt,
+		EndedAt:   session.EndedAt,
+	}
+
+	if err := r.db.Conn(ctx).Create(model).Error; err != nil {
+		logger.Error(ctx, "error archiving driver session", err)
+		return err
+	}
+
+	session.ID = model.ID
+	return nil
+}
+
+// GetByDriverID retrieves the driver's sessions that overlap [from, to), oldest first
+// Sessions crossing either end are returned whole
+func (r *DriverSessionPostgresRepository) GetByDriverID(ctx context.Context, driverID int64, from, to time.Time) ([]*domain.DriverSession, error) {
+	var models []DriverSessionModel
+	err := r.db.Conn(ctx).
+		Where("driver_id = ? AND ended_at > ? AND started_at < ?", driverID, from, to).
+		Order("started_at ASC").
+		Find(&models).Error
+	if err != nil {
+		logger.Error(ctx, "error getting driver sessions", err)
+		return nil, err
+	}
+
+	sessions := make([]*domain.DriverSession, 0, len(models))
+	for i := range models {
+		sessions = append(sessions, toDriverSessionDomain(&models[i]))
+	}
+	return sessions, nil
+}
+
+func toDriverSessionDomain(model *DriverSessionModel) *domain.DriverSession {
+	session := &domain.DriverSession{
+		ID:        model.ID,
+		DriverID:  model.DriverID,
+		StartedAt: model.StartedAt,
+		EndedAt:   model.EndedAt,
+	}
+	session.DurationSeconds = int64(session.Duration().Seconds())
+	return session
+}
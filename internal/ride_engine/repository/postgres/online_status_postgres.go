@@ -5,7 +5,9 @@ import (
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/repository"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/database"
 )
 
 // OnlineDriverModel represents the online_drivers table
@@ -24,10 +26,10 @@ func (OnlineDriverModel) TableName() string {
 }
 
 type OnlineStatusPostgresRepository struct {
-	db *gorm.DB
+	db *database.PostgresDB
 }
 
-func NewOnlineStatusPostgresRepository(db *gorm.DB) repository.OnlineStatusRepository {
+func NewOnlineStatusPostgresRepository(db *database.PostgresDB) repository.OnlineStatusRepository {
 	return &OnlineStatusPostgresRepository{db: db}
 }
 
@@ -36,7 +38,7 @@ func (r *OnlineStatusPostgresRepository) UpsertOnlineDriver(ctx context.Context,
 	now := time.Now()
 
 	var existing OnlineDriverModel
-	err := r.db.WithContext(ctx).Where("driver_id = ?", driverID).First(&existing).Error
+	err := r.db.Conn(ctx).Where("driver_id = ?", driverID).First(&existing).Error
 
 	if err == gorm.ErrRecordNotFound {
 		newDriver := OnlineDriverModel{
@@ -48,7 +50,7 @@ func (r *OnlineStatusPostgresRepository) UpsertOnlineDriver(ctx context.Context,
 			CurrentLng:   &lng,
 			UpdatedAt:    now,
 		}
-		return r.db.WithContext(ctx).Create(&newDriver).Error
+		return r.db.Conn(ctx).Create(&newDriver).Error
 	} else if err != nil {
 		return err
 	}
@@ -61,17 +63,29 @@ func (r *OnlineStatusPostgresRepository) UpsertOnlineDriver(ctx context.Context,
 		"updated_at":   now,
 	}
 
-	return r.db.WithContext(ctx).
+	return r.db.Conn(ctx).
 		Model(&OnlineDriverModel{}).
 		Where("driver_id = ?", driverID).
 		Updates(updates).Error
 }
 
-// SetDriverOffline removes driver from online drivers table
-func (r *OnlineStatusPostgresRepository) SetDriverOffline(ctx context.Context, driverID int64) error {
-	return r.db.WithContext(ctx).
+// SetDriverOffline removes driver from online drivers table and returns the removed record
+// It returns nil when the driver was not online
+func (r *OnlineStatusPostgresRepository) SetDriverOffline(ctx context.Context, driverID int64) (*repository.OnlineDriver, error) {
+	var removed []OnlineDriverModel
+	err := r.db.Conn(ctx).
+		Clauses(clause.Returning{}).
 		Where("driver_id = ?", driverID).
-		Delete(&OnlineDriverModel{}).Error
+		Delete(&removed).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(removed) == 0 {
+		return nil, nil
+	}
+
+	driver := toOnlineDriver(&removed[0])
+	return &driver, nil
 }
 
 // IsDriverOnline A driver is considered online if they exist in online_drivers table AND last ping was within 2 minutes
@@ -80,7 +94,7 @@ func (r *OnlineStatusPostgresRepository) IsDriverOnline(ctx context.Context, dri
 	cutoffTime := time.Now().Add(-2 * time.Minute)
 
 	var count int64
-	err := r.db.WithContext(ctx).
+	err := r.db.Conn(ctx).
 		Model(&OnlineDriverModel{}).
 		Where("driver_id = ? AND is_online = ? AND last_ping_at > ?", driverID, true, cutoffTime).
 		Count(&count).Error
@@ -98,7 +112,7 @@ func (r *OnlineStatusPostgresRepository) GetOnlineDrivers(ctx context.Context) (
 	cutoffTime := time.Now().Add(-2 * time.Minute) // Calculate cutoff time (2 minutes ago)
 
 	var driverIDs []int64
-	err := r.db.WithContext(ctx).
+	err := r.db.Conn(ctx).
 		Model(&OnlineDriverModel{}).
 		Where("is_online = ? AND last_ping_at > ?", true, cutoffTime).
 		Pluck("driver_id", &driverIDs).Error
@@ -110,11 +124,39 @@ func (r *OnlineStatusPostgresRepository) GetOnlineDrivers(ctx context.Context) (
 	return driverIDs, nil
 }
 
-// RemoveInactiveDrivers removes drivers who haven't pinged since cutoffTime
-func (r *OnlineStatusPostgresRepository) RemoveInactiveDrivers(ctx context.Context, cutoffTime time.Time) error {
-	return r.db.WithContext(ctx).
+// GetOnlineDriversCount returns the number of drivers currently online
+func (r *OnlineStatusPostgresRepository) GetOnlineDriversCount(ctx context.Context) (int64, error) {
+	cutoffTime := time.Now().Add(-2 * time.Minute) // Calculate cutoff time (2 minutes ago)
+
+	var count int64
+	err := r.db.Conn(ctx).
+		Model(&OnlineDriverModel{}).
+		Where("is_online = ? AND last_ping_at > ?", true, cutoffTime).
+		Count(&count).Error
+
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// RemoveInactiveDrivers removes drivers who haven't pinged since cutoffTime and returns the removed records
+func (r *OnlineStatusPostgresRepository) RemoveInactiveDrivers(ctx context.Context, cutoffTime time.Time) ([]repository.OnlineDriver, error) {
+	var removed []OnlineDriverModel
+	err := r.db.Conn(ctx).
+		Clauses(clause.Returning{}).
 		Where("last_ping_at < ?", cutoffTime).
-		Delete(&OnlineDriverModel{}).Error
+		Delete(&removed).Error
+	if err != nil {
+		return nil, err
+	}
+
+	drivers := make([]repository.OnlineDriver, 0, len(removed))
+	for i := range removed {
+		drivers = append(drivers, toOnlineDriver(&removed[i]))
+	}
+	return drivers, nil
 }
 
 // GetOnlineDriversByIDs filters a list of driver IDs to only those currently online
@@ -126,7 +168,7 @@ func (r *OnlineStatusPostgresRepository) GetOnlineDriversByIDs(ctx context.Conte
 	cutoffTime := time.Now().Add(-2 * time.Minute) // Calculate cutoff time (2 minutes ago)
 
 	var onlineDriverIDs []int64
-	err := r.db.WithContext(ctx).
+	err := r.db.Conn(ctx).
 		Model(&OnlineDriverModel{}).
 		Where("driver_id IN ? AND is_online = ? AND last_ping_at > ?", driverIDs, true, cutoffTime).
 		Pluck("driver_id", &onlineDriverIDs).Error
@@ -137,3 +179,15 @@ func (r *OnlineStatusPostgresRepository) GetOnlineDriversByIDs(ctx context.Conte
 
 	return onlineDriverIDs, nil
 }
+
+func toOnlineDriver(model *OnlineDriverModel) repository.OnlineDriver {
+	return repository.OnlineDriver{
+		DriverID:     model.DriverID,
+		IsOnline:     model.IsOnline,
+		LastPingAt:   model.LastPingAt,
+		WentOnlineAt: model.WentOnlineAt,
+		CurrentLat:   model.CurrentLat,
+		CurrentLng:   model.CurrentLng,
+		UpdatedAt:    model.UpdatedAt,
+	}
+}
@@ -5,7 +5,9 @@ import (
 	"time"
 	"vcs.technonext.com/carrybee/ride_engine/pkg/logger"
 
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/repository"
 	"vcs.technonext.com/carrybee/ride_engine/pkg/database"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/pagination"
 )
 
 type OTPPostgresRepository struct {
@@ -28,17 +30,18 @@ func (r *OTPPostgresRepository) SaveOTP(ctx context.Context, phone, otp, purpose
 		CreatedAt:  time.Now(),
 	}
 
-	return r.db.WithContext(ctx).Create(model).Error
+	return r.db.Conn(ctx).Create(model).Error
 }
 
 // VerifyOTP marks OTP as verified and returns true if valid
-func (r *OTPPostgresRepository) VerifyOTP(ctx context.Context, phone, otp string) (bool, error) {
+// Only OTPs sent for the purpose match
+func (r *OTPPostgresRepository) VerifyOTP(ctx context.Context, phone, otp, purpose string) (bool, error) {
 	var model OTPModel
 
-	// Find the most recent non-expired, non-verified OTP for this phone
-	err := r.db.WithContext(ctx).
-		Where("phone = ? AND otp = ? AND is_verified = ? AND is_expired = ? AND expires_at > ?",
-			phone, otp, false, false, time.Now()).
+	// Find the most recent non-expired, non-verified OTP for this phone and purpose
+	err := r.db.Conn(ctx).
+		Where("phone = ? AND otp = ? AND purpose = ? AND is_verified = ? AND is_expired = ? AND expires_at > ?",
+			phone, otp, purpose, false, false, time.Now()).
 		Order("created_at DESC").
 		First(&model).Error
 
@@ -51,7 +54,7 @@ func (r *OTPPostgresRepository) VerifyOTP(ctx context.Context, phone, otp string
 	model.IsVerified = true
 	model.VerifiedAt = &now
 
-	if err := r.db.WithContext(ctx).Save(&model).Error; err != nil {
+	if err := r.db.Conn(ctx).Save(&model).Error; err != nil {
 		logger.Error(ctx, err)
 		return false, err
 	}
@@ -61,28 +64,70 @@ func (r *OTPPostgresRepository) VerifyOTP(ctx context.Context, phone, otp string
 
 // MarkExpired marks all non-verified OTPs for a phone as expired
 func (r *OTPPostgresRepository) MarkExpired(ctx context.Context, phone string) error {
-	return r.db.WithContext(ctx).
+	return r.db.Conn(ctx).
 		Model(&OTPModel{}).
 		Where("phone = ? AND is_verified = ? AND is_expired = ?", phone, false, false).
 		Update("is_expired", true).Error
 }
 
-// GetOTPHistory retrieves OTP history for a phone number
-func (r *OTPPostgresRepository) GetOTPHistory(ctx context.Context, phone string, limit int) ([]OTPModel, error) {
-	var otps []OTPModel
+// GetOTPHistory retrieves a page of the OTPs sent matching filter, newest first, along with the total count
+// Only metadata is returned, the OTP codes stay in the database
+func (r *OTPPostgresRepository) GetOTPHistory(ctx context.Context, filter repository.OTPFilter, page repository.Page) ([]repository.OTPRecord, int64, error) {
+	query := r.db.Conn(ctx).Model(&OTPModel{}).Where("phone = ?", filter.Phone)
+	if filter.Purpose != "" {
+		query = query.Where("purpose = ?", filter.Purpose)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("created_at < ?", filter.To)
+	}
 
-	err := r.db.WithContext(ctx).
-		Where("phone = ?", phone).
-		Order("created_at DESC").
-		Limit(limit).
-		Find(&otps).Error
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		logger.Error(ctx, "Failed to count otp history", err)
+		return nil, 0, err
+	}
+
+	query = pagination.ApplyGorm(query, page.Cursor, "created_at", "id")
+	if page.Cursor == nil {
+		query = query.Offset(page.Offset)
+	}
+	if page.Limit > 0 {
+		query = query.Limit(page.Limit)
+	}
+
+	var models []OTPModel
+	if err := query.Find(&models).Error; err != nil {
+		logger.Error(ctx, "Failed to get otp history", err)
+		return nil, 0, err
+	}
 
-	return otps, err
+	records := make([]repository.OTPRecord, 0, len(models))
+	for i := range models {
+		records = append(records, toOTPRecord(&models[i]))
+	}
+	return records, total, nil
+}
+
+// toOTPRecord converts OTPModel to its audit metadata, leaving out the OTP code
+func toOTPRecord(model *OTPModel) repository.OTPRecord {
+	return repository.OTPRecord{
+		ID:         model.ID,
+		Phone:      model.Phone,
+		Purpose:    model.Purpose,
+		IsVerified: model.IsVerified,
+		IsExpired:  model.IsExpired,
+		ExpiresAt:  model.ExpiresAt,
+		VerifiedAt: model.VerifiedAt,
+		CreatedAt:  model.CreatedAt,
+	}
 }
 
 // CleanupExpiredOTPs removes expired OTPs older than specified duration (for maintenance)
 func (r *OTPPostgresRepository) CleanupExpiredOTPs(ctx context.Context, olderThan time.Time) error {
-	return r.db.WithContext(ctx).
+	return r.db.Conn(ctx).
 		Where("expires_at < ?", olderThan).
 		Delete(&OTPModel{}).Error
 }
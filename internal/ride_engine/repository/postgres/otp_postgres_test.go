@@ -0,0 +1,34 @@
+package postgres
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToOTPRecord_RedactsOTP(t *testing.T) {
+	now := time.Now()
+	model := &OTPModel{
+		ID:         7,
+		Phone:      "+8801700000000",
+		OTP:        "493817",
+		Purpose:    "driver_login",
+		IsVerified: true,
+		ExpiresAt:  now.Add(2 * time.Minute),
+		VerifiedAt: &now,
+		CreatedAt:  now,
+	}
+
+	record := toOTPRecord(model)
+	assert.Equal(t, int64(7), record.ID)
+	assert.Equal(t, "driver_login", record.Purpose)
+	assert.True(t, record.IsVerified)
+
+	body, err := json.Marshal(record)
+	require.NoError(t, err)
+	assert.NotContains(t, string(body), "493817")
+	assert.NotContains(t, string(body), `"otp"`)
+}
@@ -0,0 +1,117 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/repository"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/database"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/logger"
+)
+
+// PromoCodeModel represents the promo_codes table
+type PromoCodeModel struct {
+	ID                 int64      `gorm:"primaryKey;autoIncrement"`
+	Code               string     `gorm:"type:varchar(32);not null;uniqueIndex"`
+	DiscountType       string     `gorm:"type:varchar(20);not null"`
+	DiscountValue      float64    `gorm:"type:double precision;not null"`
+	MaxUsesPerCustomer int        `gorm:"not null;default:1"`
+	Active             bool       `gorm:"not null;default:true"`
+	ExpiresAt          *time.Time `gorm:"type:timestamp"`
+	CreatedAt          time.Time  `gorm:"not null;default:CURRENT_TIMESTAMP"`
+}
+
+func (PromoCodeModel) TableName() string {
+	return "promo_codes"
+}
+
+// PromoRedemptionModel represents the promo_code_redemptions table
+type PromoRedemptionModel struct {
+	ID          int64     `gorm:"primaryKey;autoIncrement"`
+	PromoCodeID int64     `gorm:"not null;uniqueIndex:idx_promo_code_redemptions_ride"`
+	CustomerID  int64     `gorm:"not null"`
+	RideID      int64     `gorm:"not null;uniqueIndex:idx_promo_code_redemptions_ride"`
+	Discount    float64   `gorm:"type:double precision;not null"`
+	RedeemedAt  time.Time `gorm:"not null;default:CURRENT_TIMESTAMP"`
+}
+
+func (PromoRedemptionModel) TableName() string {
+	return "promo_code_redemptions"
+}
+
+type PromoPostgresRepository struct {
+	db *database.PostgresDB
+}
+
+func NewPromoPostgresRepository(db *database.PostgresDB) *PromoPostgresRepository {
+	return &PromoPostgresRepository{db: db}
+}
+
+func toPromoCodeDomain(model *PromoCodeModel) *domain.PromoCode {
+	return &domain.PromoCode{
+		ID:                 model.ID,
+		Code:               model.Code,
+		DiscountType:       domain.PromoDiscountType(model.DiscountType),
+		DiscountValue:      model.DiscountValue,
+		MaxUsesPerCustomer: model.MaxUsesPerCustomer,
+		Active:             model.Active,
+		ExpiresAt:          model.ExpiresAt,
+		CreatedAt:          model.CreatedAt,
+	}
+}
+
+// GetByCode looks the promo code up by its normalized code
+func (r *PromoPostgresRepository) GetByCode(ctx context.Context, code string) (*domain.PromoCode, error) {
+	var model PromoCodeModel
+
+	result := r.db.Conn(ctx).Where("code = ?", code).First(&model)
+	if result.Error != nil {
+		logger.Error(ctx, "error getting promo code", result.Error)
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, repository.ErrPromoCodeNotFound
+		}
+		return nil, result.Error
+	}
+
+	return toPromoCodeDomain(&model), nil
+}
+
+// CountRedemptions returns how many times the customer redeemed the promo code
+func (r *PromoPostgresRepository) CountRedemptions(ctx context.Context, promoCodeID, customerID int64) (int64, error) {
+	var count int64
+
+	result := r.db.Conn(ctx).Model(&PromoRedemptionModel{}).
+		Where("promo_code_id = ? AND customer_id = ?", promoCodeID, customerID).
+		Count(&count)
+	if result.Error != nil {
+		logger.Error(ctx, "error counting promo code redemptions", result.Error)
+		return 0, result.Error
+	}
+
+	return count, nil
+}
+
+func (r *PromoPostgresRepository) CreateRedemption(ctx context.Context, redemption *domain.PromoRedemption) error {
+	model := &PromoRedemptionModel{
+		PromoCodeID: redemption.PromoCodeID,
+		CustomerID:  redemption.CustomerID,
+		RideID:      redemption.RideID,
+		Discount:    redemption.Discount,
+		RedeemedAt:  redemption.RedeemedAt,
+	}
+
+	result := r.db.Conn(ctx).Create(model)
+	if result.Error != nil {
+		logger.Error(ctx, "error creating promo code redemption", result.Error)
+		if errors.Is(result.Error, gorm.ErrDuplicatedKey) {
+			return repository.ErrPromoCodeAlreadyRedeemed
+		}
+		return result.Error
+	}
+
+	redemption.ID = model.ID
+	return nil
+}
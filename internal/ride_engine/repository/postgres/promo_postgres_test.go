@@ -0,0 +1,84 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/repository"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/database"
+)
+
+// uniqueViolationConnector opens connections that fail every statement
+// with the error PostgreSQL returns when constraint is violated
+type uniqueViolationConnector struct {
+	constraint string
+}
+
+func (c uniqueViolationConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return uniqueViolationConn(c), nil
+}
+
+func (c uniqueViolationConnector) Driver() driver.Driver {
+	return nil
+}
+
+type uniqueViolationConn struct {
+	constraint string
+}
+
+func (c uniqueViolationConn) err() error {
+	return &pgconn.PgError{
+		Severity:       "ERROR",
+		Code:           "23505",
+		Message:        `duplicate key value violates unique constraint "` + c.constraint + `"`,
+		ConstraintName: c.constraint,
+	}
+}
+
+func (c uniqueViolationConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, c.err()
+}
+
+func (c uniqueViolationConn) Close() error {
+	return nil
+}
+
+func (c uniqueViolationConn) Begin() (driver.Tx, error) {
+	return nil, c.err()
+}
+
+// newUniqueViolationTestDB opens the service's gorm setup on connections where every statement violates constraint
+func newUniqueViolationTestDB(t *testing.T, constraint string) *database.PostgresDB {
+	conn := sql.OpenDB(uniqueViolationConnector{constraint: constraint})
+	t.Cleanup(func() { conn.Close() })
+
+	db, err := database.OpenPostgres(postgres.New(postgres.Config{Conn: conn}), time.Second)
+	require.NoError(t, err)
+	return db
+}
+
+func TestPromoPostgresRepository_CreateRedemption_AlreadyRedeemed(t *testing.T) {
+	db := newUniqueViolationTestDB(t, "promo_code_redemptions_promo_code_id_ride_id_key")
+	repo := NewPromoPostgresRepository(db)
+
+	err := repo.CreateRedemption(context.Background(), &domain.PromoRedemption{
+		PromoCodeID: 1,
+		CustomerID:  7,
+		RideID:      42,
+		Discount:    50,
+		RedeemedAt:  time.Now(),
+	})
+
+	assert.ErrorIs(t, err, repository.ErrPromoCodeAlreadyRedeemed)
+	assert.ErrorIs(t, err, domain.ErrConflict)
+	assert.NotErrorIs(t, err, gorm.ErrDuplicatedKey, "The constraint violation should not reach the caller")
+}
@@ -0,0 +1,130 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/repository"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/database"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/logger"
+)
+
+// SavedLocationModel represents the saved_locations table
+type SavedLocationModel struct {
+	ID         int64     `gorm:"primaryKey;autoIncrement"`
+	CustomerID int64     `gorm:"not null;uniqueIndex:idx_saved_locations_customer_label"`
+	Label      string    `gorm:"type:varchar(50);not null;uniqueIndex:idx_saved_locations_customer_label"`
+	Lat        float64   `gorm:"type:double precision;not null"`
+	Lng        float64   `gorm:"type:double precision;not null"`
+	CreatedAt  time.Time `gorm:"not null;default:CURRENT_TIMESTAMP"`
+}
+
+func (SavedLocationModel) TableName() string {
+	return "saved_locations"
+}
+
+type SavedLocationPostgresRepository struct {
+	db *database.PostgresDB
+}
+
+func NewSavedLocationPostgresRepository(db *database.PostgresDB) *SavedLocationPostgresRepository {
+	return &SavedLocationPostgresRepository{db: db}
+}
+
+func toSavedLocationDomain(model *SavedLocationModel) *domain.SavedLocation {
+	return &domain.SavedLocation{
+		ID:         model.ID,
+		CustomerID: model.CustomerID,
+		Label:      model.Label,
+		Lat:        model.Lat,
+		Lng:        model.Lng,
+		CreatedAt:  model.CreatedAt,
+	}
+}
+
+func (r *SavedLocationPostgresRepository) Create(ctx context.Context, location *domain.SavedLocation) error {
+	model := &SavedLocationModel{
+		CustomerID: location.CustomerID,
+		Label:      location.Label,
+		Lat:        location.Lat,
+		Lng:        location.Lng,
+		CreatedAt:  location.CreatedAt,
+	}
+
+	result := r.db.Conn(ctx).Create(model)
+	if result.Error != nil {
+		logger.Error(ctx, "error creating saved location", result.Error)
+		if errors.Is(result.Error, gorm.ErrDuplicatedKey) {
+			return repository.ErrSavedLocationLabelExists
+		}
+		return result.Error
+	}
+
+	location.ID = model.ID
+	return nil
+}
+
+// GetByID returns the saved location only when it belongs to the customer
+func (r *SavedLocationPostgresRepository) GetByID(ctx context.Context, id, customerID int64) (*domain.SavedLocation, error) {
+	var model SavedLocationModel
+
+	result := r.db.Conn(ctx).Where("id = ? AND customer_id = ?", id, customerID).First(&model)
+	if result.Error != nil {
+		logger.Error(ctx, "error getting saved location", result.Error)
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, repository.ErrSavedLocationNotFound
+		}
+		return nil, result.Error
+	}
+
+	return toSavedLocationDomain(&model), nil
+}
+
+func (r *SavedLocationPostgresRepository) ListByCustomer(ctx context.Context, customerID int64) ([]*domain.SavedLocation, error) {
+	var models []SavedLocationModel
+
+	result := r.db.Conn(ctx).Where("customer_id = ?", customerID).Order("label ASC").Find(&models)
+	if result.Error != nil {
+		logger.Error(ctx, "error listing saved locations", result.Error)
+		return nil, result.Error
+	}
+
+	locations := make([]*domain.SavedLocation, 0, len(models))
+	for i := range models {
+		locations = append(locations, toSavedLocationDomain(&models[i]))
+	}
+
+	return locations, nil
+}
+
+// Delete removes the saved location only when it belongs to the customer
+func (r *SavedLocationPostgresRepository) Delete(ctx context.Context, id, customerID int64) error {
+	result := r.db.Conn(ctx).Where("id = ? AND customer_id = ?", id, customerID).Delete(&SavedLocationModel{})
+
+	if result.Error != nil {
+		logger.Error(ctx, "error deleting saved location", result.Error)
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		logger.Error(ctx, "error deleting saved location", repository.ErrSavedLocationNotFound)
+		return repository.ErrSavedLocationNotFound
+	}
+
+	return nil
+}
+
+// DeleteByCustomer removes all of the customer's saved locations
+func (r *SavedLocationPostgresRepository) DeleteByCustomer(ctx context.Context, customerID int64) error {
+	result := r.db.Conn(ctx).Where("customer_id = ?", customerID).Delete(&SavedLocationModel{})
+
+	if result.Error != nil {
+		logger.Error(ctx, "error deleting saved locations of customer", result.Error)
+		return result.Error
+	}
+
+	return nil
+}
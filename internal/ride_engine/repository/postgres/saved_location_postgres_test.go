@@ -0,0 +1,27 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/repository"
+)
+
+func TestSavedLocationPostgresRepository_Create_LabelExists(t *testing.T) {
+	db := newUniqueViolationTestDB(t, "saved_locations_customer_id_label_key")
+	repo := NewSavedLocationPostgresRepository(db)
+
+	err := repo.Create(context.Background(), &domain.SavedLocation{
+		CustomerID: 123,
+		Label:      "Home",
+		Lat:        23.8103,
+		Lng:        90.4125,
+		CreatedAt:  time.Now(),
+	})
+
+	assert.ErrorIs(t, err, repository.ErrSavedLocationLabelExists)
+	assert.ErrorIs(t, err, domain.ErrConflict)
+}
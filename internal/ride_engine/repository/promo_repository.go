@@ -0,0 +1,20 @@
+package repository
+
+import (
+	"context"
+
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+)
+
+var (
+	// ErrPromoCodeNotFound is returned when no promo code matches
+	ErrPromoCodeNotFound = domain.NewError(domain.ErrNotFound, "promo code not found")
+	// ErrPromoCodeAlreadyRedeemed is returned by CreateRedemption when the code was already redeemed on the ride
+	ErrPromoCodeAlreadyRedeemed = domain.NewError(domain.ErrConflict, "promo code already redeemed on this ride")
+)
+
+type PromoRepository interface {
+	GetByCode(ctx context.Context, code string) (*domain.PromoCode, error)
+	CountRedemptions(ctx context.Context, promoCodeID, customerID int64) (int64, error)
+	CreateRedemption(ctx context.Context, redemption *domain.PromoRedemption) error
+}
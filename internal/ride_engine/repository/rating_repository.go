@@ -0,0 +1,15 @@
+package repository
+
+import (
+	"context"
+
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+)
+
+// ErrRatingAlreadyExists is returned by Create when the rater's side has already rated the ride
+var ErrRatingAlreadyExists = domain.NewError(domain.ErrConflict, "ride already rated")
+
+type RatingRepository interface {
+	Create(ctx context.Context, rating *domain.Rating) error
+	GetAverageForRatee(ctx context.Context, rateeID int64, rateeRole string) (float64, int64, error)
+}
@@ -0,0 +1,13 @@
+package repository
+
+import (
+	"context"
+
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+)
+
+// RideEventRepository stores the audit log of ride status changes
+type RideEventRepository interface {
+	Create(ctx context.Context, event *domain.RideEvent) error
+	GetByRideID(ctx context.Context, rideID int64) ([]*domain.RideEvent, error) // oldest first
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/pagination"
+)
+
+// ErrRideNotFound is returned when no ride matches the lookup
+var ErrRideNotFound = domain.NewError(domain.ErrNotFound, "ride not found")
+
+// ErrCustomerHasActiveRide is returned by Create when the customer already has a ride that has not completed or been cancelled
+var ErrCustomerHasActiveRide = domain.NewError(domain.ErrConflict, "customer already has an active ride")
+
+// ErrRideStatusChanged is returned by Update when another request changed the ride's status since it was read
+var ErrRideStatusChanged = domain.NewError(domain.ErrConflict, "ride was changed by another request")
+
+// ErrRideNotAcceptable is returned by AcceptRide when the ride is no longer waiting for a driver
+var ErrRideNotAcceptable = domain.NewError(domain.ErrConflict, "ride is no longer waiting for a driver")
+
+// ErrRideNotAbandonable is returned by AbandonRide when the ride is no longer accepted by the driver
+var ErrRideNotAbandonable = domain.NewError(domain.ErrConflict, "ride is no longer accepted by this driver")
+
+// ErrRideNotExpirable is returned by ExpireRide when the ride is no longer waiting for a driver past its request timeout
+var ErrRideNotExpirable = domain.NewError(domain.ErrConflict, "ride is no longer waiting past its request timeout")
+
+// RideFilter selects rides for ListRides, zero fields match any ride
+type RideFilter struct {
+	Status     domain.RideStatus
+	DriverID   int64
+	CustomerID int64
+	From       time.Time // requested at or after, zero for no lower bound
+	To         time.Time // requested before, zero for no upper bound
+}
+
+// Page is a window of a list sorted newest first, a Limit of 0 returns all items from Offset
+// When Cursor is set the page starts after it instead of at Offset
+type Page struct {
+	Limit  int
+	Offset int
+	Cursor *pagination.Cursor
+}
+
+type RideRepository interface {
+	// WithTransaction runs fn in a transaction, the writes fn makes with the ctx it is given are all kept or all rolled back
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+	Create(ctx context.Context, ride *domain.Ride) error
+	GetByID(ctx context.Context, id int64) (*domain.Ride, error)
+	Update(ctx context.Context, ride *domain.Ride, from domain.RideStatus) error // only while the ride still has status from
+	AcceptRide(ctx context.Context, rideID, driverID int64, acceptedAt time.Time) error
+	AbandonRide(ctx context.Context, rideID int64, abandonment domain.RideAbandonment) error
+	GetRequestedRides(ctx context.Context) ([]*domain.Ride, error)
+	GetNearbyRequestedRides(ctx context.Context, driverID int64, rideType domain.RideType, lat, lng, maxDistanceMeters float64, freshness time.Duration, limit int) ([]*domain.Ride, error)
+	GetNearbyRequestedRidesByGeohash(ctx context.Context, driverID int64, rideType domain.RideType, lat, lng, maxDistanceMeters float64, freshness time.Duration, limit int) ([]*domain.Ride, error)
+	AddDeclinedDriver(ctx context.Context, rideID, driverID int64) error
+	SetRideOffer(ctx context.Context, rideID int64, driverID *int64, expiresAt time.Time) error
+	GetRidesAwaitingDispatch(ctx context.Context, now time.Time) ([]*domain.Ride, error)
+	GetStaleRequestedRides(ctx context.Context, cutoff time.Time) ([]*domain.Ride, error) // requested, pending, waiting since before cutoff
+	ExpireRide(ctx context.Context, ride *domain.Ride, cutoff time.Time) error
+	GetStaleAcceptedRides(ctx context.Context, cutoff time.Time) ([]*domain.Ride, error) // accepted before cutoff, not yet arrived at or started
+	GetByCustomerID(ctx context.Context, customerID int64, status domain.RideStatus, limit, offset int) ([]*domain.Ride, int64, error)
+	GetActiveRideByCustomerID(ctx context.Context, customerID int64) (*domain.Ride, error) // the latest ride that is requested, pending, accepted, arrived or started
+	GetActiveRideByDriverID(ctx context.Context, driverID int64) (*domain.Ride, error)
+	GetBusyDriverIDs(ctx context.Context, driverIDs []int64) ([]int64, error) // the drivers among driverIDs with an accepted, arrived or started ride
+	GetByDriverID(ctx context.Context, driverID int64, limit, offset int) ([]*domain.Ride, int64, error)
+	ListRides(ctx context.Context, filter RideFilter, page Page) ([]*domain.Ride, int64, error)
+	GetCompletedByDriverID(ctx context.Context, driverID int64, from, to time.Time) ([]*domain.Ride, error)
+	GetCompletedRides(ctx context.Context, from, to time.Time) ([]*domain.Ride, error)
+}
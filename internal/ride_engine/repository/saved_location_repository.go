@@ -0,0 +1,23 @@
+package repository
+
+import (
+	"context"
+
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+)
+
+var (
+	// ErrSavedLocationNotFound is returned when the location does not exist or belongs to another customer
+	ErrSavedLocationNotFound = domain.NewError(domain.ErrNotFound, "saved location not found")
+	// ErrSavedLocationLabelExists is returned by Create when the customer already uses the label
+	ErrSavedLocationLabelExists = domain.NewError(domain.ErrConflict, "saved location label already exists")
+)
+
+type SavedLocationRepository interface {
+	Create(ctx context.Context, location *domain.SavedLocation) error
+	GetByID(ctx context.Context, id, customerID int64) (*domain.SavedLocation, error)
+	ListByCustomer(ctx context.Context, customerID int64) ([]*domain.SavedLocation, error)
+	Delete(ctx context.Context, id, customerID int64) error
+	// DeleteByCustomer removes all of the customer's saved locations, a customer without any is not an error
+	DeleteByCustomer(ctx context.Context, customerID int64) error
+}
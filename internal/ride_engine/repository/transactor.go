@@ -0,0 +1,8 @@
+package repository
+
+import "context"
+
+// Transactor runs fn in a transaction, the writes repositories make with the ctx fn is given are all kept or all rolled back
+type Transactor interface {
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
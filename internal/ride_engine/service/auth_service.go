@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/logger"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/utils"
+)
+
+type AuthService struct {
+	redis *redis.Client
+}
+
+func NewAuthService(redis *redis.Client) *AuthService {
+	return &AuthService{redis: redis}
+}
+
+// IssueAdminToken creates a token with the admin role and stores it as a new session of the admin
+// Admins have no account, the token is issued by the admin-token command
+func (s *AuthService) IssueAdminToken(ctx context.Context, adminID int64, jwtKeys utils.JWTKeys, jwtExpiry int) (string, error) {
+	token, err := issueSessionToken(ctx, s.redis, adminID, "admin", jwtKeys, jwtExpiry)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("error issuing token for admin %d: %v", adminID, err))
+		return "", err
+	}
+
+	return token, nil
+}
+
+// Logout revokes the token of one session of the user so it is rejected by the auth middleware
+// The user's sessions on other devices stay logged in
+func (s *AuthService) Logout(ctx context.Context, role string, userID int64, sessionID string) error {
+	key := utils.JWTRedisKey(role, userID)
+	if err := s.redis.HDel(ctx, key, sessionID).Err(); err != nil {
+		logger.Error(ctx, fmt.Sprintf("error revoking session %s for %s %d: %v", sessionID, role, userID, err))
+		return err
+	}
+
+	return nil
+}
+
+// maxSessionsPerUser is how many devices a user can be logged in on at once
+// Logging in on one more device logs out the session closest to expiring, which is the oldest login
+const maxSessionsPerUser = 5
+
+// issueSessionToken starts a new login session of the user and returns its token
+// The token is stored in the user's session hash next to the sessions on their other devices,
+// every login extends the hash's lifetime so it outlives all the tokens in it
+// Since the hash never expires while the user keeps logging in, the sessions whose token expired
+// are removed on login, as are the oldest ones over maxSessionsPerUser
+func issueSessionToken(ctx context.Context, rdb *redis.Client, userID int64, role string, jwtKeys utils.JWTKeys, jwtExpiry int) (string, error) {
+	sessionID := utils.GenerateID()
+	token, err := utils.GenerateJWT(userID, role, sessionID, jwtKeys, jwtExpiry)
+	if err != nil {
+		return "", err
+	}
+
+	key := utils.JWTRedisKey(role, userID)
+	stale, err := staleSessions(ctx, rdb, key, time.Now())
+	if err != nil {
+		return "", fmt.Errorf("failed to read sessions from Redis: %w", err)
+	}
+
+	_, err = rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if len(stale) > 0 {
+			pipe.HDel(ctx, key, stale...)
+		}
+		pipe.HSet(ctx, key, sessionID, token)
+		pipe.Expire(ctx, key, utils.JWTExpiry(jwtExpiry))
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to store JWT in Redis: %w", err)
+	}
+
+	return token, nil
+}
+
+// staleSessions returns the sessions in the user's session hash to remove before a new one is added:
+// the ones whose token expired or cannot be read, and the ones closest to expiring
+// that leave no room for the new session within maxSessionsPerUser
+func staleSessions(ctx context.Context, rdb *redis.Client, key string, now time.Time) ([]string, error) {
+	tokens, err := rdb.HGetAll(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	type session struct {
+		id        string
+		expiresAt time.Time
+	}
+
+	var stale []string
+	var live []session
+	for sessionID, token := range tokens {
+		expiresAt, err := utils.JWTExpiresAt(token)
+		if err != nil || !expiresAt.After(now) {
+			stale = append(stale, sessionID)
+			continue
+		}
+		live = append(live, session{id: sessionID, expiresAt: expiresAt})
+	}
+
+	sort.Slice(live, func(i, j int) bool {
+		return live[i].expiresAt.Before(live[j].expiresAt)
+	})
+	for len(live) >= maxSessionsPerUser {
+		stale = append(stale, live[0].id)
+		live = live[1:]
+	}
+
+	return stale, nil
+}
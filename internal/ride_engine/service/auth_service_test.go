@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/middleware"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/testutil"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/utils"
+)
+
+var testJWTKeys = utils.JWTKeys{Secret: "test-secret"}
+
+// authenticate runs a request with the token through the Echo auth middleware and returns the status code
+func authenticate(t *testing.T, authMiddleware *middleware.AuthMiddleware, token string) int {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	handler := authMiddleware.AuthEcho(func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+	require.NoError(t, handler(c))
+
+	return rec.Code
+}
+
+// sessionIDOf returns the login session the token belongs to
+func sessionIDOf(t *testing.T, token string) string {
+	claims, err := utils.ValidateJWT(token, testJWTKeys)
+	require.NoError(t, err)
+	return claims.SessionID
+}
+
+func TestAuthService_Logout_RevokesToken(t *testing.T) {
+	redisClient, _ := testutil.NewFakeRedis()
+	service := NewAuthService(redisClient)
+	authMiddleware := middleware.NewAuthMiddleware(redisClient, testJWTKeys)
+
+	ctx := context.Background()
+	customerID := int64(123)
+
+	token, err := issueSessionToken(ctx, redisClient, customerID, "customer", testJWTKeys, 24)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, authenticate(t, authMiddleware, token))
+
+	err = service.Logout(ctx, "customer", customerID, sessionIDOf(t, token))
+	assert.NoError(t, err)
+
+	assert.Equal(t, http.StatusUnauthorized, authenticate(t, authMiddleware, token), "Logged out token should be rejected")
+}
+
+func TestAuthService_Logout_OnlyRevokesOwnToken(t *testing.T) {
+	redisClient, _ := testutil.NewFakeRedis()
+	service := NewAuthService(redisClient)
+	authMiddleware := middleware.NewAuthMiddleware(redisClient, testJWTKeys)
+
+	ctx := context.Background()
+	userID := int64(7)
+
+	customerToken, err := issueSessionToken(ctx, redisClient, userID, "customer", testJWTKeys, 24)
+	require.NoError(t, err)
+
+	driverToken, err := issueSessionToken(ctx, redisClient, userID, "driver", testJWTKeys, 24)
+	require.NoError(t, err)
+
+	err = service.Logout(ctx, "driver", userID, sessionIDOf(t, driverToken))
+	assert.NoError(t, err)
+
+	assert.Equal(t, http.StatusUnauthorized, authenticate(t, authMiddleware, driverToken))
+	assert.Equal(t, http.StatusOK, authenticate(t, authMiddleware, customerToken), "A customer with the same ID should stay logged in")
+}
+
+func TestAuthService_Logout_KeepsOtherDevicesLoggedIn(t *testing.T) {
+	redisClient, _ := testutil.NewFakeRedis()
+	service := NewAuthService(redisClient)
+	authMiddleware := middleware.NewAuthMiddleware(redisClient, testJWTKeys)
+
+	ctx := context.Background()
+	driverID := int64(42)
+
+	phoneToken, err := issueSessionToken(ctx, redisClient, driverID, "driver", testJWTKeys, 24)
+	require.NoError(t, err)
+	tabletToken, err := issueSessionToken(ctx, redisClient, driverID, "driver", testJWTKeys, 24)
+	require.NoError(t, err)
+	require.NotEqual(t, sessionIDOf(t, phoneToken), sessionIDOf(t, tabletToken))
+
+	assert.Equal(t, http.StatusOK, authenticate(t, authMiddleware, phoneToken), "Logging in on a second device should not log out the first")
+	assert.Equal(t, http.StatusOK, authenticate(t, authMiddleware, tabletToken))
+
+	require.NoError(t, service.Logout(ctx, "driver", driverID, sessionIDOf(t, phoneToken)))
+
+	assert.Equal(t, http.StatusUnauthorized, authenticate(t, authMiddleware, phoneToken))
+	assert.Equal(t, http.StatusOK, authenticate(t, authMiddleware, tabletToken), "Only the logged out session should be revoked")
+
+	require.NoError(t, service.Logout(ctx, "driver", driverID, sessionIDOf(t, tabletToken)))
+
+	assert.Equal(t, http.StatusUnauthorized, authenticate(t, authMiddleware, tabletToken))
+}
+
+func TestAuthService_IssueAdminToken(t *testing.T) {
+	redisClient, _ := testutil.NewFakeRedis()
+	service := NewAuthService(redisClient)
+	authMiddleware := middleware.NewAuthMiddleware(redisClient, testJWTKeys)
+
+	ctx := context.Background()
+
+	token, err := service.IssueAdminToken(ctx, 1, testJWTKeys, 24)
+	require.NoError(t, err)
+
+	claims, err := utils.ValidateJWT(token, testJWTKeys)
+	require.NoError(t, err)
+	assert.Equal(t, "admin", claims.Role)
+	assert.Equal(t, int64(1), claims.UserID)
+	assert.Equal(t, http.StatusOK, authenticate(t, authMiddleware, token))
+}
+
+func TestIssueSessionToken_PrunesExpiredSessions(t *testing.T) {
+	redisClient, _ := testutil.NewFakeRedis()
+	ctx := context.Background()
+	driverID := int64(42)
+	key := utils.JWTRedisKey("driver", driverID)
+
+	// A session whose token expired while the hash was kept alive by later logins
+	expired, err := utils.GenerateJWT(driverID, "driver", "expired-session", testJWTKeys, -1)
+	require.NoError(t, err)
+	require.NoError(t, redisClient.HSet(ctx, key, "expired-session", expired).Err())
+
+	token, err := issueSessionToken(ctx, redisClient, driverID, "driver", testJWTKeys, 24)
+	require.NoError(t, err)
+
+	sessions, err := redisClient.HGetAll(ctx, key).Result()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{sessionIDOf(t, token): token}, sessions, "The expired session should be removed on login")
+}
+
+func TestIssueSessionToken_CapsSessionsPerUser(t *testing.T) {
+	redisClient, _ := testutil.NewFakeRedis()
+	authMiddleware := middleware.NewAuthMiddleware(redisClient, testJWTKeys)
+	ctx := context.Background()
+	customerID := int64(123)
+	key := utils.JWTRedisKey("customer", customerID)
+
+	// Sessions expiring an hour apart, the first one is closest to expiring
+	var tokens []string
+	for i := 0; i < maxSessionsPerUser; i++ {
+		sessionID := fmt.Sprintf("session-%d", i)
+		token, err := utils.GenerateJWT(customerID, "customer", sessionID, testJWTKeys, i+1)
+		require.NoError(t, err)
+		require.NoError(t, redisClient.HSet(ctx, key, sessionID, token).Err())
+		tokens = append(tokens, token)
+	}
+
+	token, err := issueSessionToken(ctx, redisClient, customerID, "customer", testJWTKeys, 24)
+	require.NoError(t, err)
+
+	sessions, err := redisClient.HGetAll(ctx, key).Result()
+	require.NoError(t, err)
+	assert.Len(t, sessions, maxSessionsPerUser)
+
+	assert.Equal(t, http.StatusOK, authenticate(t, authMiddleware, token))
+	assert.Equal(t, http.StatusUnauthorized, authenticate(t, authMiddleware, tokens[0]), "The oldest session should be logged out")
+	for _, other := range tokens[1:] {
+		assert.Equal(t, http.StatusOK, authenticate(t, authMiddleware, other))
+	}
+}
@@ -5,27 +5,51 @@ import (
 	"errors"
 	"fmt"
 	"github.com/redis/go-redis/v9"
+	"strings"
 	"time"
 	"vcs.technonext.com/carrybee/ride_engine/pkg/logger"
 
 	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
 	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/repository"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/repository/postgres"
 	"vcs.technonext.com/carrybee/ride_engine/pkg/utils"
 )
 
+var (
+	ErrCustomerNotFound   = domain.NewError(domain.ErrNotFound, "customer not found")
+	ErrCustomerEmailTaken = domain.NewError(domain.ErrConflict, "email is already used by another customer")
+	ErrCustomerPhoneTaken = domain.NewError(domain.ErrConflict, "phone is already used by another customer")
+	ErrCustomerTaken      = domain.NewError(domain.ErrConflict, "email or phone is already used by another customer")
+
+	ErrPasswordRequired       = domain.NewError(domain.ErrValidation, "current and new password are required")
+	ErrInvalidCurrentPassword = domain.NewError(domain.ErrValidation, "current password is incorrect")
+	ErrPasswordUnchanged      = domain.NewError(domain.ErrValidation, "new password must differ from the current password")
+	ErrResetFieldsRequired    = domain.NewError(domain.ErrValidation, "phone, OTP and new password are required")
+)
+
+const passwordResetOTPPurpose = "customer_password_reset"
+
 type CustomerService struct {
-	repo      repository.CustomerRepository
-	jwtSecret string
-	jwtExpiry int
-	redis     *redis.Client
+	repo              repository.CustomerRepository
+	otpService        *OTPService
+	jwtKeys           utils.JWTKeys
+	jwtExpiry         int
+	redis             *redis.Client
+	savedLocationRepo repository.SavedLocationRepository
+	deviceTokenRepo   repository.DeviceTokenRepository
+	tx                repository.Transactor
 }
 
-func NewCustomerService(repo repository.CustomerRepository, jwtSecret string, jwtExpiry int, redis *redis.Client) *CustomerService {
+func NewCustomerService(repo repository.CustomerRepository, otpService *OTPService, jwtKeys utils.JWTKeys, jwtExpiry int, redis *redis.Client, savedLocationRepo repository.SavedLocationRepository, deviceTokenRepo repository.DeviceTokenRepository, tx repository.Transactor) *CustomerService {
 	return &CustomerService{
-		repo:      repo,
-		jwtSecret: jwtSecret,
-		jwtExpiry: jwtExpiry,
-		redis:     redis,
+		repo:              repo,
+		otpService:        otpService,
+		jwtKeys:           jwtKeys,
+		jwtExpiry:         jwtExpiry,
+		redis:             redis,
+		savedLocationRepo: savedLocationRepo,
+		deviceTokenRepo:   deviceTokenRepo,
+		tx:                tx,
 	}
 }
 
@@ -39,7 +63,7 @@ func (s *CustomerService) Register(ctx context.Context, name, email, phone, pass
 	existingCustomer, _, err := s.repo.GetByEmail(ctx, email)
 	if err == nil && existingCustomer != nil {
 		logger.Error(ctx, "Customer with email already exists")
-		return nil, "", errors.New("customer with this email already exists")
+		return nil, "", ErrCustomerEmailTaken
 	}
 
 	hashedPassword, err := utils.HashPassword(password)
@@ -62,24 +86,20 @@ func (s *CustomerService) Register(ctx context.Context, name, email, phone, pass
 
 	if err := s.repo.Create(ctx, customer, hashedPassword); err != nil {
 		logger.Error(ctx, err)
+		if errors.Is(err, postgres.ErrCustomerAlreadyExists) {
+			// The phone is taken, or another registration took the email after the check above
+			return nil, "", ErrCustomerTaken
+		}
 		return nil, "", err
 	}
 
-	token, err := utils.GenerateJWT(customer.ID, "customer", s.jwtSecret, s.jwtExpiry)
-	if err != nil {
-		logger.Error(ctx, err)
-		return nil, "", err
-	}
-
-	key := fmt.Sprintf("jwt:customer:%d", customer.ID)
-	expiration := time.Duration(s.jwtExpiry) * time.Hour
-	err = s.redis.Set(ctx, key, token, expiration).Err()
+	token, err := issueSessionToken(ctx, s.redis, customer.ID, "customer", s.jwtKeys, s.jwtExpiry)
 	if err != nil {
 		logger.Error(ctx, err)
 		return nil, "", err
 	}
 
-	fmt.Printf("✅ [REGISTER] Stored token for customer %d in Redis (expiry: %d hours). Token: %s...\n", customer.ID, s.jwtExpiry, token[:30])
+	logger.DebugWithContext(ctx, fmt.Sprintf("Stored token for customer %d in Redis (expiry: %d hours)", customer.ID, s.jwtExpiry))
 	return customer, token, nil
 }
 
@@ -101,21 +121,13 @@ func (s *CustomerService) Login(ctx context.Context, email, password string) (*d
 		return nil, "", errors.New("invalid email or password")
 	}
 
-	token, err := utils.GenerateJWT(customer.ID, "customer", s.jwtSecret, s.jwtExpiry)
+	token, err := issueSessionToken(ctx, s.redis, customer.ID, "customer", s.jwtKeys, s.jwtExpiry)
 	if err != nil {
 		logger.Error(ctx, err)
 		return nil, "", err
 	}
 
-	key := fmt.Sprintf("jwt:customer:%d", customer.ID)
-	expiration := time.Duration(s.jwtExpiry) * time.Hour
-	err = s.redis.Set(ctx, key, token, expiration).Err()
-	if err != nil {
-		logger.Error(ctx, err)
-		return nil, "", err
-	}
-
-	fmt.Printf("✅ [LOGIN] Stored token for customer %d in Redis (expiry: %d hours). Token: %s...\n", customer.ID, s.jwtExpiry, token[:30])
+	logger.DebugWithContext(ctx, fmt.Sprintf("Stored token for customer %d in Redis (expiry: %d hours)", customer.ID, s.jwtExpiry))
 	return customer, token, nil
 }
 
@@ -123,3 +135,216 @@ func (s *CustomerService) Login(ctx context.Context, email, password string) (*d
 func (s *CustomerService) GetByID(ctx context.Context, id int64) (*domain.Customer, error) {
 	return s.repo.GetByID(ctx, id)
 }
+
+// GetProfile retrieves the customer's own profile
+func (s *CustomerService) GetProfile(ctx context.Context, customerID int64) (*domain.Customer, error) {
+	customer, err := s.repo.GetByID(ctx, customerID)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("error getting customer %d: %v", customerID, err))
+		if errors.Is(err, postgres.ErrCustomerNotFound) {
+			return nil, ErrCustomerNotFound
+		}
+		return nil, err
+	}
+
+	return customer, nil
+}
+
+// UpdateProfile changes the customer's name, email and phone
+// Empty fields keep their current value. An email or phone already used by another customer is rejected
+func (s *CustomerService) UpdateProfile(ctx context.Context, customerID int64, name, email, phone string) (*domain.Customer, error) {
+	customer, err := s.GetProfile(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+
+	if name = strings.TrimSpace(name); name != "" {
+		customer.Name = name
+	}
+
+	if email = strings.TrimSpace(email); email != "" && email != customer.Email {
+		existing, _, err := s.repo.GetByEmail(ctx, email)
+		if err == nil && existing != nil && existing.ID != customerID {
+			logger.Error(ctx, fmt.Sprintf("customer %d tried to take the email of customer %d", customerID, existing.ID))
+			return nil, ErrCustomerEmailTaken
+		}
+		if err != nil && !errors.Is(err, postgres.ErrCustomerNotFound) {
+			logger.Error(ctx, err)
+			return nil, err
+		}
+		customer.Email = email
+	}
+
+	if phone = strings.TrimSpace(phone); phone != "" && phone != customer.Phone {
+		existing, err := s.repo.GetByPhone(ctx, phone)
+		if err == nil && existing != nil && existing.ID != customerID {
+			logger.Error(ctx, fmt.Sprintf("customer %d tried to take the phone of customer %d", customerID, existing.ID))
+			return nil, ErrCustomerPhoneTaken
+		}
+		if err != nil && !errors.Is(err, postgres.ErrCustomerNotFound) {
+			logger.Error(ctx, err)
+			return nil, err
+		}
+		customer.Phone = phone
+	}
+
+	if err := domain.ValidateCustomer(customer); err != nil {
+		logger.Error(ctx, err)
+		return nil, err
+	}
+
+	if err := s.repo.Update(ctx, customer); err != nil {
+		logger.Error(ctx, fmt.Sprintf("error updating customer %d: %v", customerID, err))
+		if errors.Is(err, postgres.ErrCustomerAlreadyExists) {
+			// Lost a race with another customer taking the same email or phone
+			return nil, ErrCustomerTaken
+		}
+		if errors.Is(err, postgres.ErrCustomerNotFound) {
+			return nil, ErrCustomerNotFound
+		}
+		return nil, err
+	}
+
+	return customer, nil
+}
+
+// ChangePassword replaces the customer's password after verifying the current one
+func (s *CustomerService) ChangePassword(ctx context.Context, customerID int64, oldPassword, newPassword string) error {
+	if oldPassword == "" || newPassword == "" {
+		logger.Error(ctx, "current and new password are required")
+		return ErrPasswordRequired
+	}
+	if oldPassword == newPassword {
+		logger.Error(ctx, fmt.Sprintf("customer %d tried to reuse the current password", customerID))
+		return ErrPasswordUnchanged
+	}
+
+	customer, err := s.GetProfile(ctx, customerID)
+	if err != nil {
+		return err
+	}
+
+	_, hashedPassword, err := s.repo.GetByEmail(ctx, customer.Email)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("error getting password of customer %d: %v", customerID, err))
+		return err
+	}
+
+	if !utils.CheckPassword(oldPassword, hashedPassword) {
+		logger.Error(ctx, fmt.Sprintf("customer %d gave an incorrect current password", customerID))
+		return ErrInvalidCurrentPassword
+	}
+
+	newHash, err := utils.HashPassword(newPassword)
+	if err != nil {
+		logger.Error(ctx, err)
+		return err
+	}
+
+	if err := s.repo.UpdatePassword(ctx, customerID, newHash); err != nil {
+		logger.Error(ctx, fmt.Sprintf("error updating password of customer %d: %v", customerID, err))
+		if errors.Is(err, postgres.ErrCustomerNotFound) {
+			return ErrCustomerNotFound
+		}
+		return err
+	}
+
+	return nil
+}
+
+// ForgotPassword sends a password reset OTP to the customer's phone
+// Unknown phones are ignored so the endpoint does not reveal which numbers are registered
+func (s *CustomerService) ForgotPassword(ctx context.Context, phone string) error {
+	if phone == "" {
+		logger.Error(ctx, "phone is required")
+		return errors.New("phone is required")
+	}
+
+	// The cooldown applies to unknown phones too, so it does not reveal which numbers are registered
+	if err := s.otpService.StartResendCooldown(ctx, phone); err != nil {
+		return err
+	}
+
+	if _, err := s.repo.GetByPhone(ctx, phone); err != nil {
+		logger.Error(ctx, fmt.Sprintf("password reset requested for unknown phone %s: %v", utils.MaskPhone(phone), err))
+		if errors.Is(err, postgres.ErrCustomerNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	return s.otpService.SendOTP(ctx, phone, passwordResetOTPPurpose)
+}
+
+// ResetPassword sets a new password once the reset OTP sent to the phone is verified
+// Every session issued before the reset is revoked, on all devices
+func (s *CustomerService) ResetPassword(ctx context.Context, phone, otp, newPassword string) error {
+	if phone == "" || otp == "" || newPassword == "" {
+		logger.Error(ctx, "phone, OTP and new password are required")
+		return ErrResetFieldsRequired
+	}
+
+	valid, err := s.otpService.VerifyOTP(ctx, phone, otp, passwordResetOTPPurpose)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("error verifying otp: %v", err))
+		return err
+	}
+	if !valid {
+		logger.Error(ctx, fmt.Sprintf("invalid password reset otp for phone %s", utils.MaskPhone(phone)))
+		return ErrInvalidOTP
+	}
+
+	customer, err := s.repo.GetByPhone(ctx, phone)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("customer with phone %s not found: %v", utils.MaskPhone(phone), err))
+		if errors.Is(err, postgres.ErrCustomerNotFound) {
+			return ErrInvalidOTP
+		}
+		return err
+	}
+
+	hashedPassword, err := utils.HashPassword(newPassword)
+	if err != nil {
+		logger.Error(ctx, err)
+		return err
+	}
+
+	if err := s.repo.UpdatePassword(ctx, customer.ID, hashedPassword); err != nil {
+		logger.Error(ctx, fmt.Sprintf("error updating password of customer %d: %v", customer.ID, err))
+		return err
+	}
+
+	if err := s.redis.Del(ctx, utils.JWTRedisKey("customer", customer.ID)).Err(); err != nil {
+		logger.Error(ctx, fmt.Sprintf("error revoking sessions of customer %d: %v", customer.ID, err))
+	}
+
+	return nil
+}
+
+// DeleteAccount soft-deletes the customer, removes their saved locations and device tokens and revokes their sessions
+// The customer can no longer log in, their rides are kept and still show their name
+// The account is only deleted when all of its data is removed with it
+func (s *CustomerService) DeleteAccount(ctx context.Context, customerID int64) error {
+	err := s.tx.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := s.repo.Delete(ctx, customerID); err != nil {
+			return err
+		}
+		if err := s.savedLocationRepo.DeleteByCustomer(ctx, customerID); err != nil {
+			return err
+		}
+		return s.deviceTokenRepo.DeleteByUser(ctx, string(domain.UserTypeCustomer), customerID)
+	})
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("error deleting customer %d: %v", customerID, err))
+		if errors.Is(err, postgres.ErrCustomerNotFound) {
+			return ErrCustomerNotFound
+		}
+		return err
+	}
+
+	if err := s.redis.Del(ctx, utils.JWTRedisKey("customer", customerID)).Err(); err != nil {
+		logger.Error(ctx, fmt.Sprintf("error revoking sessions of customer %d: %v", customerID, err))
+	}
+
+	return nil
+}
@@ -0,0 +1,438 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/repository/postgres"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/testutil"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/utils"
+)
+
+// MockCustomerRepository is a mock implementation of the customer repository
+type MockCustomerRepository struct {
+	mock.Mock
+}
+
+func (m *MockCustomerRepository) Create(ctx context.Context, customer *domain.Customer, password string) error {
+	args := m.Called(ctx, customer, password)
+	return args.Error(0)
+}
+
+func (m *MockCustomerRepository) GetByID(ctx context.Context, id int64) (*domain.Customer, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Customer), args.Error(1)
+}
+
+func (m *MockCustomerRepository) GetByIDWithDeleted(ctx context.Context, id int64) (*domain.Customer, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Customer), args.Error(1)
+}
+
+func (m *MockCustomerRepository) GetByEmail(ctx context.Context, email string) (*domain.Customer, string, error) {
+	args := m.Called(ctx, email)
+	if args.Get(0) == nil {
+		return nil, args.String(1), args.Error(2)
+	}
+	return args.Get(0).(*domain.Customer), args.String(1), args.Error(2)
+}
+
+func (m *MockCustomerRepository) GetByPhone(ctx context.Context, phone string) (*domain.Customer, error) {
+	args := m.Called(ctx, phone)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Customer), args.Error(1)
+}
+
+func (m *MockCustomerRepository) Update(ctx context.Context, customer *domain.Customer) error {
+	args := m.Called(ctx, customer)
+	return args.Error(0)
+}
+
+func (m *MockCustomerRepository) UpdatePassword(ctx context.Context, id int64, hashedPassword string) error {
+	args := m.Called(ctx, id, hashedPassword)
+	return args.Error(0)
+}
+
+func (m *MockCustomerRepository) Delete(ctx context.Context, id int64) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func newTestCustomer() *domain.Customer {
+	return &domain.Customer{
+		ID:    123,
+		Name:  "Rahim",
+		Email: "rahim@example.com",
+		Phone: "+8801711000000",
+	}
+}
+
+func TestCustomerService_UpdateProfile_DuplicateEmail(t *testing.T) {
+	customerRepo := new(MockCustomerRepository)
+	service := NewCustomerService(customerRepo, nil, utils.JWTKeys{Secret: "secret"}, 24, nil, nil, nil, nil)
+
+	ctx := context.Background()
+
+	customerRepo.On("GetByID", ctx, int64(123)).Return(newTestCustomer(), nil)
+	customerRepo.On("GetByEmail", ctx, "karim@example.com").Return(&domain.Customer{ID: 456, Email: "karim@example.com"}, "hash", nil)
+
+	customer, err := service.UpdateProfile(ctx, 123, "", "karim@example.com", "")
+
+	assert.ErrorIs(t, err, ErrCustomerEmailTaken)
+	assert.Nil(t, customer)
+	customerRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+}
+
+func TestCustomerService_UpdateProfile_NameAndPhone(t *testing.T) {
+	customerRepo := new(MockCustomerRepository)
+	service := NewCustomerService(customerRepo, nil, utils.JWTKeys{Secret: "secret"}, 24, nil, nil, nil, nil)
+
+	ctx := context.Background()
+
+	customerRepo.On("GetByID", ctx, int64(123)).Return(newTestCustomer(), nil)
+	customerRepo.On("GetByPhone", ctx, "+8801811000000").Return(nil, postgres.ErrCustomerNotFound)
+	customerRepo.On("Update", ctx, mock.MatchedBy(func(c *domain.Customer) bool {
+		return c.ID == 123 && c.Name == "Rahim Uddin" && c.Phone == "+8801811000000" && c.Email == "rahim@example.com"
+	})).Return(nil)
+
+	customer, err := service.UpdateProfile(ctx, 123, " Rahim Uddin ", "", "+8801811000000")
+
+	require.NoError(t, err)
+	assert.Equal(t, "Rahim Uddin", customer.Name)
+	assert.Equal(t, "+8801811000000", customer.Phone)
+	assert.Equal(t, "rahim@example.com", customer.Email)
+	customerRepo.AssertExpectations(t)
+	customerRepo.AssertNotCalled(t, "GetByEmail", mock.Anything, mock.Anything)
+}
+
+func TestCustomerService_UpdateProfile_DuplicatePhone(t *testing.T) {
+	customerRepo := new(MockCustomerRepository)
+	service := NewCustomerService(customerRepo, nil, utils.JWTKeys{Secret: "secret"}, 24, nil, nil, nil, nil)
+
+	ctx := context.Background()
+
+	customerRepo.On("GetByID", ctx, int64(123)).Return(newTestCustomer(), nil)
+	customerRepo.On("GetByPhone", ctx, "+8801811000000").Return(&domain.Customer{ID: 456}, nil)
+
+	_, err := service.UpdateProfile(ctx, 123, "", "", "+8801811000000")
+
+	assert.ErrorIs(t, err, ErrCustomerPhoneTaken)
+	customerRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+}
+
+func TestCustomerService_GetProfile_NotFound(t *testing.T) {
+	customerRepo := new(MockCustomerRepository)
+	service := NewCustomerService(customerRepo, nil, utils.JWTKeys{Secret: "secret"}, 24, nil, nil, nil, nil)
+
+	ctx := context.Background()
+
+	customerRepo.On("GetByID", ctx, int64(999)).Return(nil, postgres.ErrCustomerNotFound)
+
+	customer, err := service.GetProfile(ctx, 999)
+
+	assert.ErrorIs(t, err, ErrCustomerNotFound)
+	assert.Nil(t, customer)
+}
+
+func TestCustomerService_ChangePassword_WrongOldPassword(t *testing.T) {
+	customerRepo := new(MockCustomerRepository)
+	service := NewCustomerService(customerRepo, nil, utils.JWTKeys{Secret: "secret"}, 24, nil, nil, nil, nil)
+
+	ctx := context.Background()
+
+	hash, err := utils.HashPassword("old-secret")
+	require.NoError(t, err)
+
+	customerRepo.On("GetByID", ctx, int64(123)).Return(newTestCustomer(), nil)
+	customerRepo.On("GetByEmail", ctx, "rahim@example.com").Return(newTestCustomer(), hash, nil)
+
+	err = service.ChangePassword(ctx, 123, "not-my-password", "new-secret")
+
+	assert.ErrorIs(t, err, ErrInvalidCurrentPassword)
+	customerRepo.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCustomerService_ChangePassword_Success(t *testing.T) {
+	customerRepo := new(MockCustomerRepository)
+	service := NewCustomerService(customerRepo, nil, utils.JWTKeys{Secret: "secret"}, 24, nil, nil, nil, nil)
+
+	ctx := context.Background()
+
+	hash, err := utils.HashPassword("old-secret")
+	require.NoError(t, err)
+
+	customerRepo.On("GetByID", ctx, int64(123)).Return(newTestCustomer(), nil)
+	customerRepo.On("GetByEmail", ctx, "rahim@example.com").Return(newTestCustomer(), hash, nil).Once()
+	customerRepo.On("UpdatePassword", ctx, int64(123), mock.AnythingOfType("string")).
+		Run(func(args mock.Arguments) { hash = args.String(2) }).
+		Return(nil)
+
+	err = service.ChangePassword(ctx, 123, "old-secret", "new-secret")
+
+	require.NoError(t, err)
+	assert.True(t, utils.CheckPassword("new-secret", hash))
+	assert.False(t, utils.CheckPassword("old-secret", hash))
+
+	// The old password no longer logs the customer in
+	customerRepo.On("GetByEmail", ctx, "rahim@example.com").Return(newTestCustomer(), hash, nil)
+	_, _, err = service.Login(ctx, "rahim@example.com", "old-secret")
+	assert.Error(t, err)
+	customerRepo.AssertExpectations(t)
+}
+
+func TestCustomerService_ChangePassword_Unchanged(t *testing.T) {
+	customerRepo := new(MockCustomerRepository)
+	service := NewCustomerService(customerRepo, nil, utils.JWTKeys{Secret: "secret"}, 24, nil, nil, nil, nil)
+
+	err := service.ChangePassword(context.Background(), 123, "same-secret", "same-secret")
+
+	assert.ErrorIs(t, err, ErrPasswordUnchanged)
+	customerRepo.AssertNotCalled(t, "GetByID", mock.Anything, mock.Anything)
+}
+
+func TestCustomerService_ResetPassword_InvalidOTP(t *testing.T) {
+	redisClient, _ := testutil.NewFakeRedis()
+	customerRepo := new(MockCustomerRepository)
+	otpRepo := new(MockOTPRepository)
+	service := NewCustomerService(customerRepo, NewOTPService(redisClient, otpRepo, 0, nil, false), utils.JWTKeys{Secret: "secret"}, 24, redisClient, nil, nil, nil)
+
+	ctx := context.Background()
+	phone := "+8801711000000"
+
+	require.NoError(t, redisClient.Set(ctx, otpKey(passwordResetOTPPurpose, phone), "123456", 2*time.Minute).Err())
+
+	err := service.ResetPassword(ctx, phone, "000000", "new-secret")
+
+	assert.ErrorIs(t, err, ErrInvalidOTP)
+	customerRepo.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCustomerService_ResetPassword_RejectsOTPOfAnotherPurpose(t *testing.T) {
+	redisClient, _ := testutil.NewFakeRedis()
+	customerRepo := new(MockCustomerRepository)
+	otpRepo := new(MockOTPRepository)
+	sender := new(MockSMSSender)
+	otpService := NewOTPService(redisClient, otpRepo, 0, sender, false)
+	service := NewCustomerService(customerRepo, otpService, utils.JWTKeys{Secret: "secret"}, 24, redisClient, nil, nil, nil)
+
+	ctx := context.Background()
+	phone := "+8801711000000"
+
+	// The same phone is registered as a driver and is sent a login code
+	var loginOTP string
+	otpRepo.On("SaveOTP", ctx, phone, mock.AnythingOfType("string"), driverLoginOTPPurpose, mock.Anything).
+		Run(func(args mock.Arguments) { loginOTP = args.String(2) }).
+		Return(nil)
+	sender.On("Send", ctx, phone, mock.AnythingOfType("string")).Return(nil)
+	require.NoError(t, otpService.SendOTP(ctx, phone, driverLoginOTPPurpose))
+
+	// The history table only holds a login OTP, so the lookup by reset purpose finds nothing
+	otpRepo.On("VerifyOTP", ctx, phone, loginOTP, passwordResetOTPPurpose).Return(false, nil)
+
+	err := service.ResetPassword(ctx, phone, loginOTP, "new-secret")
+
+	assert.ErrorIs(t, err, ErrInvalidOTP)
+	customerRepo.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything, mock.Anything)
+
+	// The login code is still pending for the driver
+	storedOTP, err := redisClient.Get(ctx, otpKey(driverLoginOTPPurpose, phone)).Result()
+	require.NoError(t, err)
+	assert.Equal(t, loginOTP, storedOTP)
+}
+
+func TestCustomerService_ResetPassword_NewPasswordLogsIn(t *testing.T) {
+	redisClient, _ := testutil.NewFakeRedis()
+	customerRepo := new(MockCustomerRepository)
+	otpRepo := new(MockOTPRepository)
+	sender := new(MockSMSSender)
+	service := NewCustomerService(customerRepo, NewOTPService(redisClient, otpRepo, 0, sender, false), utils.JWTKeys{Secret: "secret"}, 24, redisClient, nil, nil, nil)
+
+	ctx := context.Background()
+	customer := newTestCustomer()
+
+	hash, err := utils.HashPassword("forgotten-secret")
+	require.NoError(t, err)
+
+	var sentOTP string
+	customerRepo.On("GetByPhone", ctx, customer.Phone).Return(customer, nil)
+	otpRepo.On("SaveOTP", ctx, customer.Phone, mock.AnythingOfType("string"), "customer_password_reset", mock.Anything).
+		Run(func(args mock.Arguments) { sentOTP = args.String(2) }).
+		Return(nil)
+	sender.On("Send", ctx, customer.Phone, mock.AnythingOfType("string")).Return(nil)
+	otpRepo.On("VerifyOTP", ctx, customer.Phone, mock.AnythingOfType("string"), passwordResetOTPPurpose).Return(true, nil)
+	customerRepo.On("UpdatePassword", ctx, customer.ID, mock.AnythingOfType("string")).
+		Run(func(args mock.Arguments) { hash = args.String(2) }).
+		Return(nil)
+
+	require.NoError(t, service.ForgotPassword(ctx, customer.Phone))
+	require.Len(t, sentOTP, 6)
+
+	require.NoError(t, service.ResetPassword(ctx, customer.Phone, sentOTP, "new-secret"))
+
+	customerRepo.On("GetByEmail", ctx, customer.Email).Return(customer, hash, nil)
+
+	loggedIn, token, err := service.Login(ctx, customer.Email, "new-secret")
+	require.NoError(t, err)
+	assert.Equal(t, customer.ID, loggedIn.ID)
+	assert.NotEmpty(t, token)
+
+	_, _, err = service.Login(ctx, customer.Email, "forgotten-secret")
+	assert.Error(t, err)
+}
+
+// fakeTransactor runs fn directly and counts whether each transaction would be committed or rolled back
+type fakeTransactor struct {
+	committed  int
+	rolledBack int
+}
+
+func (f *fakeTransactor) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if err := fn(ctx); err != nil {
+		f.rolledBack++
+		return err
+	}
+	f.committed++
+	return nil
+}
+
+func TestCustomerService_DeleteAccount_CannotLogIn(t *testing.T) {
+	redisClient, _ := testutil.NewFakeRedis()
+	customerRepo := new(MockCustomerRepository)
+	savedLocationRepo := new(MockSavedLocationRepository)
+	deviceTokenRepo := new(MockDeviceTokenRepository)
+	tx := &fakeTransactor{}
+	service := NewCustomerService(customerRepo, nil, utils.JWTKeys{Secret: "secret"}, 24, redisClient, savedLocationRepo, deviceTokenRepo, tx)
+
+	ctx := context.Background()
+	customer := newTestCustomer()
+
+	hash, err := utils.HashPassword("secret-password")
+	require.NoError(t, err)
+
+	customerRepo.On("GetByEmail", ctx, customer.Email).Return(customer, hash, nil).Once()
+	customerRepo.On("Delete", ctx, customer.ID).Return(nil)
+	savedLocationRepo.On("DeleteByCustomer", ctx, customer.ID).Return(nil)
+	deviceTokenRepo.On("DeleteByUser", ctx, "customer", customer.ID).Return(nil)
+	// Soft-deleted customers are left out of lookups
+	customerRepo.On("GetByEmail", ctx, customer.Email).Return(nil, "", postgres.ErrCustomerNotFound)
+
+	_, _, err = service.Login(ctx, customer.Email, "secret-password")
+	require.NoError(t, err)
+
+	require.NoError(t, service.DeleteAccount(ctx, customer.ID))
+
+	exists, err := redisClient.Exists(ctx, utils.JWTRedisKey("customer", customer.ID)).Result()
+	require.NoError(t, err)
+	assert.Zero(t, exists, "The session is revoked")
+
+	_, _, err = service.Login(ctx, customer.Email, "secret-password")
+	assert.EqualError(t, err, "invalid email or password")
+	assert.Equal(t, 1, tx.committed)
+	customerRepo.AssertExpectations(t)
+	savedLocationRepo.AssertExpectations(t)
+	deviceTokenRepo.AssertExpectations(t)
+}
+
+func TestCustomerService_DeleteAccount_NotFound(t *testing.T) {
+	customerRepo := new(MockCustomerRepository)
+	savedLocationRepo := new(MockSavedLocationRepository)
+	tx := &fakeTransactor{}
+	service := NewCustomerService(customerRepo, nil, utils.JWTKeys{Secret: "secret"}, 24, nil, savedLocationRepo, new(MockDeviceTokenRepository), tx)
+
+	ctx := context.Background()
+	customerRepo.On("Delete", ctx, int64(999)).Return(postgres.ErrCustomerNotFound)
+
+	err := service.DeleteAccount(ctx, 999)
+
+	assert.ErrorIs(t, err, ErrCustomerNotFound)
+	assert.Equal(t, 1, tx.rolledBack)
+	savedLocationRepo.AssertNotCalled(t, "DeleteByCustomer", mock.Anything, mock.Anything)
+}
+
+func TestCustomerService_DeleteAccount_FailedCleanupRollsBack(t *testing.T) {
+	redisClient, _ := testutil.NewFakeRedis()
+	customerRepo := new(MockCustomerRepository)
+	savedLocationRepo := new(MockSavedLocationRepository)
+	deviceTokenRepo := new(MockDeviceTokenRepository)
+	tx := &fakeTransactor{}
+	service := NewCustomerService(customerRepo, nil, utils.JWTKeys{Secret: "secret"}, 24, redisClient, savedLocationRepo, deviceTokenRepo, tx)
+
+	ctx := context.Background()
+	customerID := int64(123)
+	require.NoError(t, redisClient.Set(ctx, utils.JWTRedisKey("customer", customerID), "token", time.Hour).Err())
+
+	customerRepo.On("Delete", ctx, customerID).Return(nil)
+	savedLocationRepo.On("DeleteByCustomer", ctx, customerID).Return(errors.New("connection reset"))
+
+	err := service.DeleteAccount(ctx, customerID)
+
+	assert.Error(t, err)
+	assert.Equal(t, 1, tx.rolledBack, "The customer delete is rolled back with the failed cleanup")
+	assert.Zero(t, tx.committed)
+	deviceTokenRepo.AssertNotCalled(t, "DeleteByUser", mock.Anything, mock.Anything, mock.Anything)
+
+	exists, err := redisClient.Exists(ctx, utils.JWTRedisKey("customer", customerID)).Result()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), exists, "The session is kept while the account still exists")
+}
+
+func TestCustomerService_Register_AgainAfterDeletingAccount(t *testing.T) {
+	redisClient, _ := testutil.NewFakeRedis()
+	customerRepo := new(MockCustomerRepository)
+	savedLocationRepo := new(MockSavedLocationRepository)
+	deviceTokenRepo := new(MockDeviceTokenRepository)
+	service := NewCustomerService(customerRepo, nil, utils.JWTKeys{Secret: "secret"}, 24, redisClient, savedLocationRepo, deviceTokenRepo, &fakeTransactor{})
+
+	ctx := context.Background()
+	deleted := newTestCustomer()
+
+	customerRepo.On("Delete", ctx, deleted.ID).Return(nil)
+	savedLocationRepo.On("DeleteByCustomer", ctx, deleted.ID).Return(nil)
+	deviceTokenRepo.On("DeleteByUser", ctx, "customer", deleted.ID).Return(nil)
+	// The deleted customer is left out of lookups and no longer holds the email and phone
+	customerRepo.On("GetByEmail", ctx, deleted.Email).Return(nil, "", postgres.ErrCustomerNotFound)
+	customerRepo.On("Create", ctx, mock.MatchedBy(func(c *domain.Customer) bool {
+		return c.Email == deleted.Email && c.Phone == deleted.Phone
+	}), mock.Anything).Run(func(args mock.Arguments) {
+		args.Get(1).(*domain.Customer).ID = 456
+	}).Return(nil)
+
+	require.NoError(t, service.DeleteAccount(ctx, deleted.ID))
+
+	customer, token, err := service.Register(ctx, deleted.Name, deleted.Email, deleted.Phone, "secret-password")
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(456), customer.ID, "The new account is a different customer")
+	assert.NotEmpty(t, token)
+	customerRepo.AssertExpectations(t)
+}
+
+func TestCustomerService_Register_PhoneTaken(t *testing.T) {
+	customerRepo := new(MockCustomerRepository)
+	service := NewCustomerService(customerRepo, nil, utils.JWTKeys{Secret: "secret"}, 24, nil, nil, nil, nil)
+
+	ctx := context.Background()
+	customer := newTestCustomer()
+
+	customerRepo.On("GetByEmail", ctx, customer.Email).Return(nil, "", postgres.ErrCustomerNotFound)
+	customerRepo.On("Create", ctx, mock.Anything, mock.Anything).Return(postgres.ErrCustomerAlreadyExists)
+
+	_, _, err := service.Register(ctx, customer.Name, customer.Email, customer.Phone, "secret-password")
+
+	assert.ErrorIs(t, err, ErrCustomerTaken)
+	assert.ErrorIs(t, err, domain.ErrConflict)
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/repository"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/logger"
+)
+
+var ErrDeviceTokenNotFound = domain.NewError(domain.ErrNotFound, "device token not found")
+
+type DeviceTokenService struct {
+	repo repository.DeviceTokenRepository
+}
+
+func NewDeviceTokenService(repo repository.DeviceTokenRepository) *DeviceTokenService {
+	return &DeviceTokenService{repo: repo}
+}
+
+// Register stores the push notification token of the user's device
+// Only customers and drivers receive push notifications
+func (s *DeviceTokenService) Register(ctx context.Context, userRole string, userID int64, token string) (*domain.DeviceToken, error) {
+	if userRole != "customer" && userRole != "driver" {
+		logger.Error(ctx, fmt.Sprintf("%s %d cannot register a device token", userRole, userID))
+		return nil, domain.ErrInvalidUserType
+	}
+
+	token = strings.TrimSpace(token)
+	if token == "" || utf8.RuneCountInString(token) > domain.MaxDeviceTokenLength {
+		logger.Error(ctx, fmt.Sprintf("invalid device token from %s %d", userRole, userID))
+		return nil, domain.ErrInvalidDeviceToken
+	}
+
+	now := time.Now()
+	deviceToken := &domain.DeviceToken{
+		UserRole:  userRole,
+		UserID:    userID,
+		Token:     token,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.repo.Upsert(ctx, deviceToken); err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to register device token for %s %d: %v", userRole, userID, err))
+		return nil, err
+	}
+
+	return deviceToken, nil
+}
+
+// Unregister removes a token the user registered, e.g. when they log out of the device
+func (s *DeviceTokenService) Unregister(ctx context.Context, userRole string, userID int64, token string) error {
+	err := s.repo.Delete(ctx, userRole, userID, strings.TrimSpace(token))
+	if errors.Is(err, repository.ErrDeviceTokenNotFound) {
+		return ErrDeviceTokenNotFound
+	}
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to unregister device token for %s %d: %v", userRole, userID, err))
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/repository"
+)
+
+// MockDeviceTokenRepository is a mock implementation of DeviceTokenRepository
+type MockDeviceTokenRepository struct {
+	mock.Mock
+}
+
+func (m *MockDeviceTokenRepository) Upsert(ctx context.Context, token *domain.DeviceToken) error {
+	args := m.Called(ctx, token)
+	return args.Error(0)
+}
+
+func (m *MockDeviceTokenRepository) Delete(ctx context.Context, userRole string, userID int64, token string) error {
+	args := m.Called(ctx, userRole, userID, token)
+	return args.Error(0)
+}
+
+func (m *MockDeviceTokenRepository) DeleteByUser(ctx context.Context, userRole string, userID int64) error {
+	args := m.Called(ctx, userRole, userID)
+	return args.Error(0)
+}
+
+func (m *MockDeviceTokenRepository) ListTokens(ctx context.Context, userRole string, userID int64) ([]string, error) {
+	args := m.Called(ctx, userRole, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func TestDeviceTokenService_Register_Success(t *testing.T) {
+	repo := new(MockDeviceTokenRepository)
+	service := NewDeviceTokenService(repo)
+
+	ctx := context.Background()
+	repo.On("Upsert", ctx, mock.MatchedBy(func(token *domain.DeviceToken) bool {
+		return token.UserRole == "driver" && token.UserID == 456 && token.Token == "token-1"
+	})).Return(nil)
+
+	deviceToken, err := service.Register(ctx, "driver", 456, "  token-1  ")
+
+	require.NoError(t, err)
+	assert.Equal(t, "token-1", deviceToken.Token)
+	repo.AssertExpectations(t)
+}
+
+func TestDeviceTokenService_Register_InvalidToken(t *testing.T) {
+	repo := new(MockDeviceTokenRepository)
+	service := NewDeviceTokenService(repo)
+
+	_, err := service.Register(context.Background(), "customer", 123, "   ")
+
+	assert.ErrorIs(t, err, domain.ErrInvalidDeviceToken)
+	repo.AssertNotCalled(t, "Upsert", mock.Anything, mock.Anything)
+}
+
+func TestDeviceTokenService_Register_AdminRejected(t *testing.T) {
+	repo := new(MockDeviceTokenRepository)
+	service := NewDeviceTokenService(repo)
+
+	_, err := service.Register(context.Background(), "admin", 1, "token-1")
+
+	assert.ErrorIs(t, err, domain.ErrInvalidUserType)
+	repo.AssertNotCalled(t, "Upsert", mock.Anything, mock.Anything)
+}
+
+func TestDeviceTokenService_Unregister_NotFound(t *testing.T) {
+	repo := new(MockDeviceTokenRepository)
+	service := NewDeviceTokenService(repo)
+
+	ctx := context.Background()
+	repo.On("Delete", ctx, "customer", int64(123), "token-1").Return(repository.ErrDeviceTokenNotFound)
+
+	err := service.Unregister(ctx, "customer", 123, "token-1")
+
+	assert.ErrorIs(t, err, ErrDeviceTokenNotFound)
+}
@@ -0,0 +1,256 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/repository"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/logger"
+)
+
+// dispatchCandidateLimit is how many of the nearest drivers are considered for each offer
+const dispatchCandidateLimit = 10
+
+// DispatchService offers ride requests to the nearest online drivers one at a time
+// Each offer lasts offerTimeout, a declined or expired offer moves on to the next nearest driver
+// Drivers are searched within radiusMeters of the pickup first, widening by radiusStepMeters up to maxRadiusMeters while none is found
+type DispatchService struct {
+	rideRepo         repository.RideRepository
+	eventRepo        repository.RideEventRepository
+	locationService  *LocationService
+	driverService    *DriverService
+	radiusMeters     float64
+	radiusStepMeters float64
+	maxRadiusMeters  float64
+	offerTimeout     time.Duration
+	notifier         Notifier
+	now              func() time.Time
+}
+
+func NewDispatchService(rideRepo repository.RideRepository, eventRepo repository.RideEventRepository, locationService *LocationService, driverService *DriverService, radiusMeters, radiusStepMeters, maxRadiusMeters float64, offerTimeout time.Duration, notifier Notifier) *DispatchService {
+	if notifier == nil {
+		notifier = NoopNotifier{}
+	}
+	return &DispatchService{
+		rideRepo:         rideRepo,
+		eventRepo:        eventRepo,
+		locationService:  locationService,
+		driverService:    driverService,
+		radiusMeters:     radiusMeters,
+		radiusStepMeters: radiusStepMeters,
+		maxRadiusMeters:  maxRadiusMeters,
+		offerTimeout:     offerTimeout,
+		notifier:         notifier,
+		now:              time.Now,
+	}
+}
+
+// Dispatch offers the ride to the nearest online driver of its ride type who has not declined it
+// When no driver is available the offer is withdrawn and the ride is dispatched again after offerTimeout
+// A change between requested and pending is recorded on the ride's timeline as made by the system
+func (s *DispatchService) Dispatch(ctx context.Context, ride *domain.Ride) error {
+	driverID, err := s.nextDriver(ctx, ride)
+	if err != nil {
+		return err
+	}
+
+	from := ride.Status
+	expiresAt := s.now().Add(s.offerTimeout)
+	if driverID == 0 {
+		logger.Info(ctx, fmt.Sprintf("No driver available for ride %d, retrying at %s", ride.ID, expiresAt.Format(time.RFC3339)))
+		ride.ClearOffer(expiresAt)
+	} else {
+		logger.Info(ctx, fmt.Sprintf("Offering ride %d to driver %d until %s", ride.ID, driverID, expiresAt.Format(time.RFC3339)))
+		ride.OfferTo(driverID, expiresAt)
+	}
+
+	err = s.rideRepo.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := s.rideRepo.SetRideOffer(ctx, ride.ID, ride.OfferedDriverID, expiresAt); err != nil {
+			return err
+		}
+		if ride.Status == from {
+			return nil
+		}
+		return recordRideEvent(ctx, s.eventRepo, ride, from, domain.CancelledBySystem, 0, "")
+	})
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to offer ride %d: %v", ride.ID, err))
+		return err
+	}
+
+	if driverID != 0 {
+		if err := s.notifier.NotifyDriverRideRequest(ctx, ride, driverID); err != nil {
+			logger.Error(ctx, fmt.Sprintf("Failed to notify driver %d of ride %d: %v", driverID, ride.ID, err))
+		}
+	}
+
+	return nil
+}
+
+// Decline records that driverID declined the ride and moves the offer on if it was theirs
+func (s *DispatchService) Decline(ctx context.Context, ride *domain.Ride, driverID int64) error {
+	if err := s.rideRepo.AddDeclinedDriver(ctx, ride.ID, driverID); err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to decline ride %d for driver %d: %v", ride.ID, driverID, err))
+		return err
+	}
+	ride.DeclinedBy = append(ride.DeclinedBy, driverID)
+
+	if ride.OfferedDriverID == nil || *ride.OfferedDriverID != driverID {
+		return nil
+	}
+
+	return s.Dispatch(ctx, ride)
+}
+
+// AdvanceExpiredOffers re-dispatches rides whose offer expired, the driver who let it expire is treated as declining
+// Returns the number of rides dispatched
+func (s *DispatchService) AdvanceExpiredOffers(ctx context.Context) (int, error) {
+	rides, err := s.rideRepo.GetRidesAwaitingDispatch(ctx, s.now())
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to get rides awaiting dispatch: %v", err))
+		return 0, err
+	}
+
+	dispatched := 0
+	for _, ride := range rides {
+		if ride.OfferedDriverID != nil {
+			if err := s.rideRepo.AddDeclinedDriver(ctx, ride.ID, *ride.OfferedDriverID); err != nil {
+				logger.Error(ctx, fmt.Sprintf("Failed to record expired offer of ride %d for driver %d: %v", ride.ID, *ride.OfferedDriverID, err))
+				continue
+			}
+			ride.DeclinedBy = append(ride.DeclinedBy, *ride.OfferedDriverID)
+		}
+
+		if err := s.Dispatch(ctx, ride); err != nil {
+			continue
+		}
+		dispatched++
+	}
+
+	return dispatched, nil
+}
+
+// SearchRadii returns the radii drivers are searched within, from the dispatch radius widening by the step up to the max
+func (s *DispatchService) SearchRadii() []float64 {
+	radii := []float64{s.radiusMeters}
+	if s.radiusStepMeters <= 0 {
+		return radii
+	}
+	for radius := s.radiusMeters + s.radiusStepMeters; radius < s.maxRadiusMeters; radius += s.radiusStepMeters {
+		radii = append(radii, radius)
+	}
+	if s.maxRadiusMeters > radii[len(radii)-1] {
+		radii = append(radii, s.maxRadiusMeters)
+	}
+	return radii
+}
+
+// FindNearestDriversExpanding finds the drivers nearest to lat/lng, searching each of SearchRadii in turn
+// until filter keeps any of the drivers found within it. It returns the kept drivers and the radius they were found within
+// filter gets the drivers of each radius nearest first, a nil filter keeps them all
+// When no radius has any, the drivers are empty and the radius is the widest searched
+func (s *DispatchService) FindNearestDriversExpanding(ctx context.Context, lat, lng float64, filter func(ctx context.Context, driverIDs []int64) ([]int64, error)) ([]int64, float64, error) {
+	radii := s.SearchRadii()
+	for _, radius := range radii {
+		drivers, err := s.locationService.FindNearestDrivers(ctx, lat, lng, radius, dispatchCandidateLimit)
+		if err != nil {
+			return nil, radius, err
+		}
+
+		if filter != nil && len(drivers) > 0 {
+			if drivers, err = filter(ctx, drivers); err != nil {
+				return nil, radius, err
+			}
+		}
+		if len(drivers) > 0 {
+			return drivers, radius, nil
+		}
+	}
+
+	return nil, radii[len(radii)-1], nil
+}
+
+// nextDriver returns the nearest eligible driver for the ride, or 0 when there is none
+// A radius whose drivers are all ineligible widens the search
+func (s *DispatchService) nextDriver(ctx context.Context, ride *domain.Ride) (int64, error) {
+	eligible, radius, err := s.FindNearestDriversExpanding(ctx, ride.PickupLat, ride.PickupLng, func(ctx context.Context, driverIDs []int64) ([]int64, error) {
+		driverID, err := s.firstEligibleDriver(ctx, ride, driverIDs)
+		if err != nil || driverID == 0 {
+			return nil, err
+		}
+		return []int64{driverID}, nil
+	})
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to find drivers for ride %d: %v", ride.ID, err))
+		return 0, err
+	}
+	if len(eligible) == 0 {
+		return 0, nil
+	}
+	if radius > s.radiusMeters {
+		logger.Info(ctx, fmt.Sprintf("Widened the driver search for ride %d to %.0fm", ride.ID, radius))
+	}
+
+	return eligible[0], nil
+}
+
+// firstEligibleDriver returns the first of driverIDs, ordered nearest first, that can be offered the ride, or 0 when none can
+// A driver is eligible when they have not declined the ride, are not the current offer, are online, not on a ride
+// and drive the ride's vehicle type
+func (s *DispatchService) firstEligibleDriver(ctx context.Context, ride *domain.Ride, driverIDs []int64) (int64, error) {
+	var candidates []int64
+	for _, driverID := range driverIDs {
+		if ride.HasDeclined(driverID) || (ride.OfferedDriverID != nil && *ride.OfferedDriverID == driverID) {
+			continue
+		}
+		candidates = append(candidates, driverID)
+	}
+	if len(candidates) == 0 {
+		return 0, nil
+	}
+
+	online, err := s.driverService.GetOnlineDriversByIDs(ctx, candidates)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to check online drivers for ride %d: %v", ride.ID, err))
+		return 0, err
+	}
+	// Drivers already on a ride keep pinging, so they are online but cannot take another one
+	available, err := s.driverService.ExcludeBusyDrivers(ctx, online)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to check busy drivers for ride %d: %v", ride.ID, err))
+		return 0, err
+	}
+	isAvailable := make(map[int64]bool, len(available))
+	for _, driverID := range available {
+		isAvailable[driverID] = true
+	}
+
+	rideType := ride.RideType
+	if rideType == "" {
+		rideType = domain.RideTypeEconomy
+	}
+
+	// candidates are ordered nearest first
+	for _, driverID := range candidates {
+		if !isAvailable[driverID] {
+			continue
+		}
+
+		driver, err := s.driverService.GetByID(ctx, driverID)
+		if err != nil {
+			logger.Error(ctx, fmt.Sprintf("Failed to get driver %d: %v", driverID, err))
+			continue
+		}
+		vehicleType := driver.VehicleType
+		if vehicleType == "" {
+			vehicleType = domain.RideTypeEconomy
+		}
+		if vehicleType == rideType {
+			return driverID, nil
+		}
+	}
+
+	return 0, nil
+}
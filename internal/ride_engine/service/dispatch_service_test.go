@@ -0,0 +1,336 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+)
+
+const (
+	testDispatchRadius = 5000.0
+	testOfferTimeout   = 30 * time.Second
+)
+
+type dispatchMocks struct {
+	rideRepo     *MockRideRepository
+	locationRepo *MockLocationRepository
+	onlineRepo   *MockOnlineStatusRepository
+	driverRepo   *MockDriverRepository
+}
+
+// newTestDispatchService builds a DispatchService on fresh mocks with the clock fixed at now
+func newTestDispatchService(now time.Time) (*DispatchService, *dispatchMocks) {
+	m := &dispatchMocks{
+		rideRepo:     new(MockRideRepository),
+		locationRepo: new(MockLocationRepository),
+		onlineRepo:   new(MockOnlineStatusRepository),
+		driverRepo:   new(MockDriverRepository),
+	}
+	locationService := &LocationService{repo: m.locationRepo}
+	driverService := &DriverService{driverRepo: m.driverRepo, rideRepo: m.rideRepo, onlineStatusRepo: m.onlineRepo, locationService: locationService}
+
+	s := NewDispatchService(m.rideRepo, &fakeRideEventRepository{}, locationService, driverService, testDispatchRadius, 0, 0, testOfferTimeout, nil)
+	s.now = func() time.Time { return now }
+	return s, m
+}
+
+func offeredTo(driverID int64) interface{} {
+	return mock.MatchedBy(func(id *int64) bool { return id != nil && *id == driverID })
+}
+
+func TestDispatchService_Dispatch_OffersNearestEligibleDriver(t *testing.T) {
+	now := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	s, m := newTestDispatchService(now)
+
+	ctx := context.Background()
+	ride := &domain.Ride{ID: 1, PickupLat: 23.8100, PickupLng: 90.4120, Status: domain.RideStatusRequested, RideType: domain.RideTypeEconomy, DeclinedBy: []int64{11}}
+
+	// 11 declined, 12 is offline, 13 drives a bike, 14 is the nearest eligible driver
+	m.locationRepo.On("FindNearestDrivers", ctx, 23.8100, 90.4120, testDispatchRadius, dispatchCandidateLimit).Return([]int64{11, 12, 13, 14, 15}, nil)
+	m.onlineRepo.On("GetOnlineDriversByIDs", ctx, []int64{12, 13, 14, 15}).Return([]int64{15, 14, 13}, nil)
+	m.rideRepo.On("GetBusyDriverIDs", ctx, []int64{15, 14, 13}).Return([]int64{}, nil)
+	m.driverRepo.On("GetByID", ctx, int64(13)).Return(&domain.Driver{ID: 13, VehicleType: domain.RideTypeBike}, nil)
+	m.driverRepo.On("GetByID", ctx, int64(14)).Return(&domain.Driver{ID: 14}, nil)
+	m.rideRepo.On("SetRideOffer", ctx, int64(1), offeredTo(14), now.Add(testOfferTimeout)).Return(nil)
+
+	err := s.Dispatch(ctx, ride)
+
+	require.NoError(t, err)
+	assert.True(t, ride.IsOfferedTo(14, now))
+	m.rideRepo.AssertExpectations(t)
+	m.driverRepo.AssertNotCalled(t, "GetByID", ctx, int64(15))
+}
+
+func TestDispatchService_Dispatch_SkipsDriverOnRide(t *testing.T) {
+	now := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	s, m := newTestDispatchService(now)
+
+	ctx := context.Background()
+	ride := &domain.Ride{ID: 1, PickupLat: 23.8100, PickupLng: 90.4120, Status: domain.RideStatusRequested, RideType: domain.RideTypeEconomy}
+
+	// 11 is nearest and online but has started another ride
+	m.locationRepo.On("FindNearestDrivers", ctx, 23.8100, 90.4120, testDispatchRadius, dispatchCandidateLimit).Return([]int64{11, 12}, nil)
+	m.onlineRepo.On("GetOnlineDriversByIDs", ctx, []int64{11, 12}).Return([]int64{11, 12}, nil)
+	m.rideRepo.On("GetBusyDriverIDs", ctx, []int64{11, 12}).Return([]int64{11}, nil)
+	m.driverRepo.On("GetByID", ctx, int64(12)).Return(&domain.Driver{ID: 12}, nil)
+	m.rideRepo.On("SetRideOffer", ctx, int64(1), offeredTo(12), now.Add(testOfferTimeout)).Return(nil)
+
+	err := s.Dispatch(ctx, ride)
+
+	require.NoError(t, err)
+	assert.True(t, ride.IsOfferedTo(12, now))
+	m.driverRepo.AssertNotCalled(t, "GetByID", ctx, int64(11))
+}
+
+func TestDispatchService_Dispatch_NoDriverAvailable(t *testing.T) {
+	now := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	s, m := newTestDispatchService(now)
+
+	ctx := context.Background()
+	driverID := int64(11)
+	ride := &domain.Ride{ID: 1, PickupLat: 23.8100, PickupLng: 90.4120, Status: domain.RideStatusRequested, RideType: domain.RideTypeEconomy}
+	ride.OfferTo(driverID, now)
+
+	m.locationRepo.On("FindNearestDrivers", ctx, 23.8100, 90.4120, testDispatchRadius, dispatchCandidateLimit).Return([]int64{driverID}, nil)
+	m.rideRepo.On("SetRideOffer", ctx, int64(1), (*int64)(nil), now.Add(testOfferTimeout)).Return(nil)
+
+	err := s.Dispatch(ctx, ride)
+
+	require.NoError(t, err)
+	assert.Nil(t, ride.OfferedDriverID)
+	m.rideRepo.AssertExpectations(t)
+	m.onlineRepo.AssertNotCalled(t, "GetOnlineDriversByIDs", mock.Anything, mock.Anything)
+}
+
+func TestDispatchService_AdvanceExpiredOffers_MovesToNextDriver(t *testing.T) {
+	now := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	s, m := newTestDispatchService(now)
+
+	ctx := context.Background()
+	ride := &domain.Ride{ID: 1, PickupLat: 23.8100, PickupLng: 90.4120, Status: domain.RideStatusRequested, RideType: domain.RideTypeEconomy}
+	ride.OfferTo(11, now.Add(-time.Second))
+
+	m.rideRepo.On("GetRidesAwaitingDispatch", ctx, now).Return([]*domain.Ride{ride}, nil)
+	m.rideRepo.On("AddDeclinedDriver", ctx, int64(1), int64(11)).Return(nil)
+	m.locationRepo.On("FindNearestDrivers", ctx, 23.8100, 90.4120, testDispatchRadius, dispatchCandidateLimit).Return([]int64{11, 12}, nil)
+	m.onlineRepo.On("GetOnlineDriversByIDs", ctx, []int64{12}).Return([]int64{12}, nil)
+	m.rideRepo.On("GetBusyDriverIDs", ctx, []int64{12}).Return([]int64{}, nil)
+	m.driverRepo.On("GetByID", ctx, int64(12)).Return(&domain.Driver{ID: 12, VehicleType: domain.RideTypeEconomy}, nil)
+	m.rideRepo.On("SetRideOffer", ctx, int64(1), offeredTo(12), now.Add(testOfferTimeout)).Return(nil)
+
+	dispatched, err := s.AdvanceExpiredOffers(ctx)
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, dispatched)
+	assert.True(t, ride.HasDeclined(11))
+	assert.False(t, ride.IsOfferedTo(11, now))
+	assert.True(t, ride.IsOfferedTo(12, now))
+	m.rideRepo.AssertExpectations(t)
+}
+
+func TestDispatchService_Decline_CurrentOfferMovesOn(t *testing.T) {
+	now := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	s, m := newTestDispatchService(now)
+
+	ctx := context.Background()
+	ride := &domain.Ride{ID: 1, PickupLat: 23.8100, PickupLng: 90.4120, Status: domain.RideStatusRequested, RideType: domain.RideTypeEconomy}
+	ride.OfferTo(11, now.Add(testOfferTimeout))
+
+	m.rideRepo.On("AddDeclinedDriver", ctx, int64(1), int64(11)).Return(nil)
+	m.locationRepo.On("FindNearestDrivers", ctx, 23.8100, 90.4120, testDispatchRadius, dispatchCandidateLimit).Return([]int64{11, 12}, nil)
+	m.onlineRepo.On("GetOnlineDriversByIDs", ctx, []int64{12}).Return([]int64{12}, nil)
+	m.rideRepo.On("GetBusyDriverIDs", ctx, []int64{12}).Return([]int64{}, nil)
+	m.driverRepo.On("GetByID", ctx, int64(12)).Return(&domain.Driver{ID: 12}, nil)
+	m.rideRepo.On("SetRideOffer", ctx, int64(1), offeredTo(12), now.Add(testOfferTimeout)).Return(nil)
+
+	err := s.Decline(ctx, ride, 11)
+
+	require.NoError(t, err)
+	assert.True(t, ride.IsOfferedTo(12, now))
+	m.rideRepo.AssertExpectations(t)
+}
+
+func TestDispatchService_Decline_NotOfferedKeepsOffer(t *testing.T) {
+	now := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	s, m := newTestDispatchService(now)
+
+	ctx := context.Background()
+	ride := &domain.Ride{ID: 1, Status: domain.RideStatusRequested}
+	ride.OfferTo(11, now.Add(testOfferTimeout))
+
+	m.rideRepo.On("AddDeclinedDriver", ctx, int64(1), int64(12)).Return(nil)
+
+	err := s.Decline(ctx, ride, 12)
+
+	require.NoError(t, err)
+	assert.True(t, ride.IsOfferedTo(11, now))
+	m.rideRepo.AssertNotCalled(t, "SetRideOffer", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRideService_AcceptRide_NotOfferedDriver(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	onlineRepo := new(MockOnlineStatusRepository)
+	service := newTestRideService(rideRepo, onlineRepo, new(MockLocationRepository))
+
+	ctx := context.Background()
+	driverID := int64(456)
+	ride := &domain.Ride{ID: 1, CustomerID: 123, Status: domain.RideStatusRequested, RequestedAt: time.Now()}
+	ride.OfferTo(789, time.Now().Add(time.Minute))
+
+	onlineRepo.On("IsDriverOnline", ctx, driverID).Return(true, nil)
+	rideRepo.On("GetByID", ctx, int64(1)).Return(ride, nil)
+
+	err := service.AcceptRide(ctx, 1, driverID)
+
+	assert.ErrorIs(t, err, ErrRideNotOffered)
+	assert.Equal(t, domain.RideStatusPending, ride.Status)
+	rideRepo.AssertNotCalled(t, "AcceptRide", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRideService_AcceptRide_OfferExpired(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	onlineRepo := new(MockOnlineStatusRepository)
+	service := newTestRideService(rideRepo, onlineRepo, new(MockLocationRepository))
+
+	ctx := context.Background()
+	driverID := int64(456)
+	ride := &domain.Ride{ID: 1, CustomerID: 123, Status: domain.RideStatusRequested, RequestedAt: time.Now()}
+	ride.OfferTo(driverID, time.Now().Add(-time.Second))
+
+	onlineRepo.On("IsDriverOnline", ctx, driverID).Return(true, nil)
+	rideRepo.On("GetByID", ctx, int64(1)).Return(ride, nil)
+
+	err := service.AcceptRide(ctx, 1, driverID)
+
+	assert.ErrorIs(t, err, ErrRideNotOffered)
+	rideRepo.AssertNotCalled(t, "AcceptRide", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDispatchService_SearchRadii(t *testing.T) {
+	s, _ := newTestDispatchService(time.Now())
+
+	s.radiusStepMeters, s.maxRadiusMeters = 2500, 10000
+	assert.Equal(t, []float64{5000, 7500, 10000}, s.SearchRadii())
+
+	// A max between steps is searched last
+	s.radiusStepMeters, s.maxRadiusMeters = 2500, 9000
+	assert.Equal(t, []float64{5000, 7500, 9000}, s.SearchRadii())
+
+	s.radiusStepMeters, s.maxRadiusMeters = 0, 10000
+	assert.Equal(t, []float64{5000}, s.SearchRadii(), "A zero step disables widening")
+
+	s.radiusStepMeters, s.maxRadiusMeters = 2500, 3000
+	assert.Equal(t, []float64{5000}, s.SearchRadii(), "A max below the dispatch radius disables widening")
+}
+
+func TestDispatchService_FindNearestDriversExpanding_WidensUntilFound(t *testing.T) {
+	s, m := newTestDispatchService(time.Now())
+	s.radiusStepMeters, s.maxRadiusMeters = 2500, 10000
+
+	ctx := context.Background()
+	m.locationRepo.On("FindNearestDrivers", ctx, 23.8100, 90.4120, 5000.0, dispatchCandidateLimit).Return([]int64{}, nil)
+	m.locationRepo.On("FindNearestDrivers", ctx, 23.8100, 90.4120, 7500.0, dispatchCandidateLimit).Return([]int64{21, 22}, nil)
+
+	drivers, radius, err := s.FindNearestDriversExpanding(ctx, 23.8100, 90.4120, nil)
+
+	require.NoError(t, err)
+	assert.Equal(t, []int64{21, 22}, drivers)
+	assert.Equal(t, 7500.0, radius)
+	m.locationRepo.AssertNotCalled(t, "FindNearestDrivers", ctx, 23.8100, 90.4120, 10000.0, dispatchCandidateLimit)
+}
+
+func TestDispatchService_FindNearestDriversExpanding_NoneWithinMax(t *testing.T) {
+	s, m := newTestDispatchService(time.Now())
+	s.radiusStepMeters, s.maxRadiusMeters = 2500, 10000
+
+	ctx := context.Background()
+	m.locationRepo.On("FindNearestDrivers", ctx, 23.8100, 90.4120, mock.AnythingOfType("float64"), dispatchCandidateLimit).Return([]int64{}, nil)
+
+	drivers, radius, err := s.FindNearestDriversExpanding(ctx, 23.8100, 90.4120, nil)
+
+	require.NoError(t, err)
+	assert.Empty(t, drivers)
+	assert.Equal(t, 10000.0, radius)
+	m.locationRepo.AssertNumberOfCalls(t, "FindNearestDrivers", 3)
+}
+
+func TestDispatchService_Dispatch_WidensPastDeclinedDrivers(t *testing.T) {
+	now := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	s, m := newTestDispatchService(now)
+	s.radiusStepMeters, s.maxRadiusMeters = 2500, 10000
+
+	ctx := context.Background()
+	ride := &domain.Ride{ID: 1, PickupLat: 23.8100, PickupLng: 90.4120, Status: domain.RideStatusRequested, RideType: domain.RideTypeEconomy, DeclinedBy: []int64{11}}
+
+	// The only driver within the dispatch radius declined, the wider search finds 21
+	m.locationRepo.On("FindNearestDrivers", ctx, 23.8100, 90.4120, 5000.0, dispatchCandidateLimit).Return([]int64{11}, nil)
+	m.locationRepo.On("FindNearestDrivers", ctx, 23.8100, 90.4120, 7500.0, dispatchCandidateLimit).Return([]int64{11, 21}, nil)
+	m.onlineRepo.On("GetOnlineDriversByIDs", ctx, []int64{21}).Return([]int64{21}, nil)
+	m.rideRepo.On("GetBusyDriverIDs", ctx, []int64{21}).Return([]int64{}, nil)
+	m.driverRepo.On("GetByID", ctx, int64(21)).Return(&domain.Driver{ID: 21}, nil)
+	m.rideRepo.On("SetRideOffer", ctx, int64(1), offeredTo(21), now.Add(testOfferTimeout)).Return(nil)
+
+	err := s.Dispatch(ctx, ride)
+
+	require.NoError(t, err)
+	assert.True(t, ride.IsOfferedTo(21, now))
+	m.rideRepo.AssertExpectations(t)
+}
+
+func TestDispatchService_Dispatch_WidensPastBusyDrivers(t *testing.T) {
+	now := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	s, m := newTestDispatchService(now)
+	s.radiusStepMeters, s.maxRadiusMeters = 2500, 10000
+
+	ctx := context.Background()
+	ride := &domain.Ride{ID: 1, PickupLat: 23.8100, PickupLng: 90.4120, Status: domain.RideStatusRequested, RideType: domain.RideTypeEconomy}
+
+	// The only driver within the dispatch radius is on another ride, the wider search finds 21 available
+	m.locationRepo.On("FindNearestDrivers", ctx, 23.8100, 90.4120, 5000.0, dispatchCandidateLimit).Return([]int64{11}, nil)
+	m.onlineRepo.On("GetOnlineDriversByIDs", ctx, []int64{11}).Return([]int64{11}, nil)
+	m.rideRepo.On("GetBusyDriverIDs", ctx, []int64{11}).Return([]int64{11}, nil)
+	m.locationRepo.On("FindNearestDrivers", ctx, 23.8100, 90.4120, 7500.0, dispatchCandidateLimit).Return([]int64{11, 21}, nil)
+	m.onlineRepo.On("GetOnlineDriversByIDs", ctx, []int64{11, 21}).Return([]int64{11, 21}, nil)
+	m.rideRepo.On("GetBusyDriverIDs", ctx, []int64{11, 21}).Return([]int64{11}, nil)
+	m.driverRepo.On("GetByID", ctx, int64(21)).Return(&domain.Driver{ID: 21}, nil)
+	m.rideRepo.On("SetRideOffer", ctx, int64(1), offeredTo(21), now.Add(testOfferTimeout)).Return(nil)
+
+	err := s.Dispatch(ctx, ride)
+
+	require.NoError(t, err)
+	assert.True(t, ride.IsOfferedTo(21, now))
+	m.rideRepo.AssertExpectations(t)
+	m.driverRepo.AssertNotCalled(t, "GetByID", ctx, int64(11))
+	m.locationRepo.AssertNotCalled(t, "FindNearestDrivers", ctx, 23.8100, 90.4120, 10000.0, dispatchCandidateLimit)
+}
+
+func TestDispatchService_Dispatch_WidensPastOtherVehicleTypes(t *testing.T) {
+	now := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	s, m := newTestDispatchService(now)
+	s.radiusStepMeters, s.maxRadiusMeters = 2500, 10000
+
+	ctx := context.Background()
+	ride := &domain.Ride{ID: 1, PickupLat: 23.8100, PickupLng: 90.4120, Status: domain.RideStatusRequested, RideType: domain.RideTypeEconomy}
+
+	// 11 is offline and 12 drives a bike, the wider search finds 21
+	m.locationRepo.On("FindNearestDrivers", ctx, 23.8100, 90.4120, 5000.0, dispatchCandidateLimit).Return([]int64{11, 12}, nil)
+	m.onlineRepo.On("GetOnlineDriversByIDs", ctx, []int64{11, 12}).Return([]int64{12}, nil)
+	m.rideRepo.On("GetBusyDriverIDs", ctx, []int64{12}).Return([]int64{}, nil)
+	m.driverRepo.On("GetByID", ctx, int64(12)).Return(&domain.Driver{ID: 12, VehicleType: domain.RideTypeBike}, nil)
+	m.locationRepo.On("FindNearestDrivers", ctx, 23.8100, 90.4120, 7500.0, dispatchCandidateLimit).Return([]int64{11, 12, 21}, nil)
+	m.onlineRepo.On("GetOnlineDriversByIDs", ctx, []int64{11, 12, 21}).Return([]int64{12, 21}, nil)
+	m.rideRepo.On("GetBusyDriverIDs", ctx, []int64{12, 21}).Return([]int64{}, nil)
+	m.driverRepo.On("GetByID", ctx, int64(21)).Return(&domain.Driver{ID: 21}, nil)
+	m.rideRepo.On("SetRideOffer", ctx, int64(1), offeredTo(21), now.Add(testOfferTimeout)).Return(nil)
+
+	err := s.Dispatch(ctx, ride)
+
+	require.NoError(t, err)
+	assert.True(t, ride.IsOfferedTo(21, now))
+	m.rideRepo.AssertExpectations(t)
+}
@@ -2,63 +2,140 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/redis/go-redis/v9"
+	"strings"
 	"time"
 	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
 	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/repository"
 	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/repository/postgres"
-	"vcs.technonext.com/carrybee/ride_engine/pkg/config"
 	"vcs.technonext.com/carrybee/ride_engine/pkg/logger"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/metrics"
 	"vcs.technonext.com/carrybee/ride_engine/pkg/utils"
 )
 
+// driverLocationFreshness is how recent a location ping must be for a driver to go online
+const driverLocationFreshness = 2 * time.Minute
+
+// driverProfileCache names the driver profile cache in the cache metrics
+const driverProfileCache = "driver_profile"
+
+// driverLoginOTPPurpose is the purpose of the OTPs drivers log in with
+const driverLoginOTPPurpose = "driver_login"
+
+// onlineDriversCountTTL is how long the online driver count is served from Redis before it is recounted
+const onlineDriversCountTTL = 5 * time.Second
+
+// Trail window limits in minutes, the default is used when none is given
+const (
+	DefaultTrailMinutes = 30
+	MaxTrailMinutes     = 24 * 60
+)
+
+// Admin online driver search limits, the radius is in meters
+const (
+	DefaultOnlineDriverSearchRadius = 3000.0
+	MaxOnlineDriverSearchRadius     = 50000.0
+	onlineDriverSearchLimit         = 100
+)
+
+var (
+	ErrLocationPingRequired = domain.NewError(domain.ErrValidation, "no recent location found, please send a location ping before going online")
+	ErrInvalidDateRange     = domain.NewError(domain.ErrValidation, "from must be before to")
+	ErrInvalidTrailWindow   = domain.NewError(domain.ErrValidation, "minutes must be between 1 and 1440")
+	ErrTrailForbidden       = domain.NewError(domain.ErrForbidden, "forbidden: you cannot view this driver's trail")
+	ErrDriverNotFound       = domain.NewError(domain.ErrNotFound, "driver not found")
+	ErrDriverAlreadyExists  = postgres.ErrDriverAlreadyExists
+)
+
+// OnlineDriverLocation is an online driver with their current location and distance from a searched point
+type OnlineDriverLocation struct {
+	Driver         *domain.Driver `json:"driver"`
+	Lat            float64        `json:"lat"`
+	Lng            float64        `json:"lng"`
+	DistanceMeters float64        `json:"distance_meters"`
+	LastPingAt     time.Time      `json:"last_ping_at"`
+}
+
 type DriverService struct {
-	driverRepo       *postgres.DriverPostgresRepository
+	driverRepo       repository.DriverRepository
+	rideRepo         repository.RideRepository
+	ratingRepo       repository.RatingRepository
 	onlineStatusRepo repository.OnlineStatusRepository
+	sessionRepo      repository.DriverSessionRepository
 	otpService       *OTPService
 	locationService  *LocationService
-	jwtSecret        string
+	trackingService  *TrackingService
+	jwtKeys          utils.JWTKeys
 	jwtExpiry        int
 	redis            *redis.Client
+	profileCacheTTL  time.Duration // how long GetByID results are cached, 0 disables the cache
+	cacheMetrics     *metrics.CacheMetrics
+	tx               repository.Transactor
+	now              func() time.Time
 }
 
 func NewDriverService(
-	driverRepo *postgres.DriverPostgresRepository,
+	driverRepo repository.DriverRepository,
+	rideRepo repository.RideRepository,
+	ratingRepo repository.RatingRepository,
 	onlineStatusRepo repository.OnlineStatusRepository,
+	sessionRepo repository.DriverSessionRepository,
 	otpService *OTPService,
 	locationService *LocationService,
-	jwtSecret string,
+	trackingService *TrackingService,
+	jwtKeys utils.JWTKeys,
 	jwtExpiry int,
 	redis *redis.Client,
+	profileCacheTTL time.Duration,
+	cacheMetrics *metrics.CacheMetrics,
+	tx repository.Transactor,
 ) *DriverService {
 	return &DriverService{
 		driverRepo:       driverRepo,
+		rideRepo:         rideRepo,
+		ratingRepo:       ratingRepo,
 		onlineStatusRepo: onlineStatusRepo,
+		sessionRepo:      sessionRepo,
 		otpService:       otpService,
 		locationService:  locationService,
-		jwtSecret:        jwtSecret,
+		trackingService:  trackingService,
+		jwtKeys:          jwtKeys,
 		jwtExpiry:        jwtExpiry,
 		redis:            redis,
+		profileCacheTTL:  profileCacheTTL,
+		cacheMetrics:     cacheMetrics,
+		tx:               tx,
+		now:              time.Now,
 	}
 }
 
 // Register creates a new driver account
-func (s *DriverService) Register(ctx context.Context, name, phone, vehicleNo string) (*domain.Driver, error) {
+// An empty vehicle type defaults to economy
+func (s *DriverService) Register(ctx context.Context, name, phone, vehicleNo string, vehicleType domain.RideType) (*domain.Driver, error) {
+	if vehicleType == "" {
+		vehicleType = domain.RideTypeEconomy
+	}
 
-	existingDriver, err := s.driverRepo.GetByPhone(ctx, phone)
-	if err == nil && existingDriver != nil {
-		logger.Error(ctx, fmt.Sprintf("driver with phone %s already exists", phone))
-		return nil, errors.New("driver with this phone already exists")
+	_, err := s.driverRepo.GetByPhone(ctx, phone)
+	if err == nil {
+		logger.Error(ctx, fmt.Sprintf("driver with phone %s already exists", utils.MaskPhone(phone)))
+		return nil, ErrDriverAlreadyExists
+	}
+	if !errors.Is(err, postgres.ErrDriverNotFound) {
+		logger.Error(ctx, fmt.Sprintf("Failed to look up driver with phone %s: %v", utils.MaskPhone(phone), err))
+		return nil, err
 	}
 
 	driver := &domain.Driver{
-		Name:      name,
-		Phone:     phone,
-		VehicleNo: vehicleNo,
-		IsOnline:  false,
-		CreatedAt: time.Now(),
+		Name:        name,
+		Phone:       phone,
+		VehicleNo:   vehicleNo,
+		VehicleType: vehicleType,
+		IsOnline:    false,
+		CreatedAt:   time.Now(),
 	}
 
 	if err := domain.ValidateDriver(driver); err != nil {
@@ -83,23 +160,15 @@ func (s *DriverService) RequestOTP(ctx context.Context, phone string) error {
 
 	_, err := s.driverRepo.GetByPhone(ctx, phone)
 	if err != nil {
-		logger.Error(ctx, fmt.Sprintf("driver with phone %s not found", phone))
+		logger.Error(ctx, fmt.Sprintf("driver with phone %s not found", utils.MaskPhone(phone)))
 		return errors.New("driver not found")
 	}
 
-	otp := s.otpService.GenerateOTP()
-	if config.GetConfig().Environment == "development" {
-		otp = "123456"
-	}
-
-	if err := s.otpService.SaveOTP(ctx, phone, otp, "driver_login"); err != nil {
-		logger.Error(ctx, fmt.Sprintf("error saving otp: %v", err))
+	if err := s.otpService.StartResendCooldown(ctx, phone); err != nil {
 		return err
 	}
 
-	fmt.Printf("OTP for driver %s: %s\n", phone, otp)
-
-	return nil
+	return s.otpService.SendOTP(ctx, phone, driverLoginOTPPurpose)
 }
 
 // VerifyOTP verifies OTP and logs in the driver
@@ -109,7 +178,7 @@ func (s *DriverService) VerifyOTP(ctx context.Context, phone, otp string) (*doma
 		return nil, "", errors.New("phone and OTP are required")
 	}
 
-	valid, err := s.otpService.VerifyOTP(ctx, phone, otp)
+	valid, err := s.otpService.VerifyOTP(ctx, phone, otp, driverLoginOTPPurpose)
 	if err != nil {
 		logger.Error(ctx, fmt.Sprintf("error verifying otp: %v", err))
 		return nil, "", err
@@ -122,27 +191,22 @@ func (s *DriverService) VerifyOTP(ctx context.Context, phone, otp string) (*doma
 
 	driver, err := s.driverRepo.GetByPhone(ctx, phone)
 	if err != nil {
-		logger.Error(ctx, fmt.Sprintf("driver with phone %s not found", phone))
+		logger.Error(ctx, fmt.Sprintf("driver with phone %s not found", utils.MaskPhone(phone)))
 		return nil, "", err
 	}
 
-	token, err := utils.GenerateJWT(driver.ID, "driver", s.jwtSecret, s.jwtExpiry)
+	token, err := issueSessionToken(ctx, s.redis, driver.ID, "driver", s.jwtKeys, s.jwtExpiry)
 	if err != nil {
-		logger.Error(ctx, fmt.Sprintf("error generating token: %v", err))
+		logger.Error(ctx, fmt.Sprintf("error issuing token: %v", err))
 		return nil, "", err
 	}
 
-	key := fmt.Sprintf("jwt:driver:%d", driver.ID)
-	err = s.redis.Set(ctx, key, token, time.Duration(s.jwtExpiry)*time.Hour).Err()
-	if err != nil {
-		logger.Error(ctx, fmt.Sprintf("error saving token: %v", err))
-		return nil, "", fmt.Errorf("failed to store JWT in Redis: %v", err)
-	}
-
 	return driver, token, nil
 }
 
 // UpdateLocation updates driver's location in both PostgreSQL and MongoDB
+// While the driver has an active ride the location is also pushed to the customer tracking it,
+// and once the ride has started it is recorded on the ride's route
 func (s *DriverService) UpdateLocation(ctx context.Context, driverID int64, lat, lng float64) error {
 
 	if err := s.locationService.UpdateDriverLocation(ctx, driverID, lat, lng); err != nil {
@@ -150,26 +214,561 @@ func (s *DriverService) UpdateLocation(ctx context.Context, driverID int64, lat,
 		return err
 	}
 
+	if err := s.refreshPing(ctx, driverID, lat, lng); err != nil {
+		return err
+	}
+
+	// Route recording and live tracking are best effort, the location is already stored
+	ride, err := s.rideRepo.GetActiveRideByDriverID(ctx, driverID)
+	if err != nil {
+		if !errors.Is(err, repository.ErrRideNotFound) {
+			logger.Error(ctx, fmt.Sprintf("error getting active ride for driver %d: %v", driverID, err))
+		}
+		return nil
+	}
+
+	if ride.Status == domain.RideStatusStarted {
+		if err := s.locationService.SaveRideLocation(ctx, ride.ID, driverID, lat, lng); err != nil {
+			logger.Error(ctx, fmt.Sprintf("error recording route of ride %d: %v", ride.ID, err))
+		}
+	}
+
+	if err := s.trackingService.PublishRideLocation(ctx, ride, driverID, lat, lng); err != nil {
+		logger.Error(ctx, fmt.Sprintf("error publishing location of driver %d: %v", driverID, err))
+	}
+
+	return nil
+}
+
+// UpdateLocationBatch stores locations the driver's device buffered while offline
+func (s *DriverService) UpdateLocationBatch(ctx context.Context, driverID int64, points []LocationPoint) (*LocationBatchResult, error) {
+	result, err := s.locationService.UpdateDriverLocationBatch(ctx, driverID, points)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("error updating driver %d location batch: %v", driverID, err))
+		return nil, err
+	}
+
+	if err := s.refreshPing(ctx, driverID, result.Latest.Lat, result.Latest.Lng); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// refreshPing records a location ping of an online driver, so the inactive driver worker keeps them online
+// and their session runs until their last ping. Pings of offline drivers do not bring them online
+func (s *DriverService) refreshPing(ctx context.Context, driverID int64, lat, lng float64) error {
+	online, err := s.onlineStatusRepo.IsDriverOnline(ctx, driverID)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("error checking online status of driver %d: %v", driverID, err))
+		return err
+	}
+	if !online {
+		return nil
+	}
+
+	if err := s.onlineStatusRepo.UpsertOnlineDriver(ctx, driverID, lat, lng); err != nil {
+		logger.Error(ctx, fmt.Sprintf("error refreshing online status of driver %d: %v", driverID, err))
+		return err
+	}
+	if err := s.driverRepo.UpdatePing(ctx, driverID, lat, lng, s.now()); err != nil {
+		logger.Error(ctx, fmt.Sprintf("error recording ping of driver %d: %v", driverID, err))
+		return err
+	}
+
+	return nil
+}
+
+// SetOnlineStatus toggles the driver's availability to accept rides
+// Going online requires a location ping within the last 2 minutes
+func (s *DriverService) SetOnlineStatus(ctx context.Context, driverID int64, isOnline bool) error {
+	if !isOnline {
+		// The session is archived with the same transaction, so it is neither lost nor recorded twice
+		err := s.tx.WithTransaction(ctx, func(ctx context.Context) error {
+			removed, err := s.onlineStatusRepo.SetDriverOffline(ctx, driverID)
+			if err != nil || removed == nil {
+				return err
+			}
+			return s.sessionRepo.Create(ctx, &domain.DriverSession{
+				DriverID:  driverID,
+				StartedAt: removed.WentOnlineAt,
+				EndedAt:   s.now(),
+			})
+		})
+		if err != nil {
+			logger.Error(ctx, fmt.Sprintf("error setting driver %d offline: %v", driverID, err))
+			return err
+		}
+		return nil
+	}
+
+	lat, lng, updatedAt, err := s.locationService.GetDriverLocation(ctx, driverID)
+	if err != nil || updatedAt == nil || time.Since(*updatedAt) > driverLocationFreshness {
+		logger.Error(ctx, fmt.Sprintf("driver %d has no recent location", driverID))
+		return ErrLocationPingRequired
+	}
+
+	if err := s.onlineStatusRepo.UpsertOnlineDriver(ctx, driverID, lat, lng); err != nil {
+		logger.Error(ctx, fmt.Sprintf("error setting driver %d online: %v", driverID, err))
+		return err
+	}
+
 	return nil
 }
 
+// IsDriverOnline reports whether the driver is currently available to accept rides
+func (s *DriverService) IsDriverOnline(ctx context.Context, driverID int64) (bool, error) {
+	return s.onlineStatusRepo.IsDriverOnline(ctx, driverID)
+}
+
+// GetOnlineDriversByIDs filters driverIDs to the drivers currently online
+func (s *DriverService) GetOnlineDriversByIDs(ctx context.Context, driverIDs []int64) ([]int64, error) {
+	return s.onlineStatusRepo.GetOnlineDriversByIDs(ctx, driverIDs)
+}
+
+// GetOnlineDriversCount returns how many drivers are currently online
+func (s *DriverService) GetOnlineDriversCount(ctx context.Context) (int64, error) {
+	return s.onlineStatusRepo.GetOnlineDriversCount(ctx)
+}
+
+// GetCachedOnlineDriversCount returns the number of online drivers, recounting it at most every onlineDriversCountTTL
+func (s *DriverService) GetCachedOnlineDriversCount(ctx context.Context) (int64, error) {
+	count, err := s.redis.Get(ctx, utils.OnlineDriversCountKey).Int64()
+	if err == nil {
+		return count, nil
+	}
+	if !errors.Is(err, redis.Nil) {
+		logger.Error(ctx, fmt.Sprintf("Failed to read cached online driver count: %v", err))
+	}
+
+	count, err = s.onlineStatusRepo.GetOnlineDriversCount(ctx)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to count online drivers: %v", err))
+		return 0, err
+	}
+
+	if err := s.redis.Set(ctx, utils.OnlineDriversCountKey, count, onlineDriversCountTTL).Err(); err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to cache online driver count: %v", err))
+	}
+
+	return count, nil
+}
+
+// GetOnlineDriversNear returns the online drivers with a fresh location within radius meters, nearest first
+func (s *DriverService) GetOnlineDriversNear(ctx context.Context, lat, lng, radius float64) ([]OnlineDriverLocation, error) {
+	since := time.Now().Add(-driverLocationFreshness)
+	locations, err := s.locationService.FindNearbyDrivers(ctx, lat, lng, radius, since, onlineDriverSearchLimit)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to find drivers near (%f, %f): %v", lat, lng, err))
+		return nil, err
+	}
+
+	drivers := []OnlineDriverLocation{}
+	if len(locations) == 0 {
+		return drivers, nil
+	}
+
+	driverIDs := make([]int64, len(locations))
+	for i, location := range locations {
+		driverIDs[i] = location.DriverID
+	}
+	onlineIDs, err := s.onlineStatusRepo.GetOnlineDriversByIDs(ctx, driverIDs)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to get online status of drivers near (%f, %f): %v", lat, lng, err))
+		return nil, err
+	}
+	online := make(map[int64]bool, len(onlineIDs))
+	for _, id := range onlineIDs {
+		online[id] = true
+	}
+
+	for _, location := range locations {
+		if !online[location.DriverID] {
+			continue
+		}
+		driver, err := s.driverRepo.GetByID(ctx, location.DriverID)
+		if err != nil {
+			logger.Error(ctx, fmt.Sprintf("Failed to get driver %d: %v", location.DriverID, err))
+			return nil, err
+		}
+		drivers = append(drivers, OnlineDriverLocation{
+			Driver:         driver,
+			Lat:            location.Location.Coordinates[1],
+			Lng:            location.Location.Coordinates[0],
+			DistanceMeters: location.DistanceMeters,
+			LastPingAt:     location.UpdatedAt,
+		})
+	}
+
+	return drivers, nil
+}
+
+// GetRideHistory retrieves the driver's rides completed in [from, to)
+func (s *DriverService) GetRideHistory(ctx context.Context, driverID int64, from, to time.Time) ([]*domain.Ride, error) {
+	if !from.Before(to) {
+		logger.Error(ctx, fmt.Sprintf("invalid date range %s - %s", from, to))
+		return nil, ErrInvalidDateRange
+	}
+
+	rides, err := s.rideRepo.GetCompletedByDriverID(ctx, driverID, from, to)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("error getting completed rides for driver %d: %v", driverID, err))
+		return nil, err
+	}
+
+	return rides, nil
+}
+
+// GetEarnings sums the fares of the driver's rides completed in [from, to)
+func (s *DriverService) GetEarnings(ctx context.Context, driverID int64, from, to time.Time) (*DriverEarnings, error) {
+	rides, err := s.GetRideHistory(ctx, driverID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	earnings := &DriverEarnings{
+		DriverID: driverID,
+		From:     from,
+		To:       to,
+		Rides:    make([]RideEarning, 0, len(rides)),
+	}
+	for _, ride := range rides {
+		item := RideEarning{RideID: ride.ID, CompletedAt: ride.CompletedAt}
+		if ride.Fare != nil {
+			item.Fare = *ride.Fare
+		}
+		earnings.Rides = append(earnings.Rides, item)
+		earnings.GrossFare += item.Fare
+	}
+	earnings.RideCount = len(earnings.Rides)
+
+	return earnings, nil
+}
+
+// GetShiftSummary returns the driver's online sessions overlapping [from, to) and how long they were online on each UTC day
+// Sessions crossing the range ends only count the time inside the range
+func (s *DriverService) GetShiftSummary(ctx context.Context, driverID int64, from, to time.Time) (*DriverShiftSummary, error) {
+	if !from.Before(to) {
+		logger.Error(ctx, fmt.Sprintf("invalid date range %s - %s", from, to))
+		return nil, ErrInvalidDateRange
+	}
+
+	sessions, err := s.sessionRepo.GetByDriverID(ctx, driverID, from, to)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("error getting sessions for driver %d: %v", driverID, err))
+		return nil, err
+	}
+
+	summary := &DriverShiftSummary{
+		DriverID: driverID,
+		From:     from,
+		To:       to,
+		Days:     []DailyOnlineTime{},
+		Sessions: sessions,
+	}
+
+	dayIndex := map[string]int{}
+	for _, session := range sessions {
+		start, end := session.StartedAt.UTC(), session.EndedAt.UTC()
+		if start.Before(from) {
+			start = from.UTC()
+		}
+		if end.After(to) {
+			end = to.UTC()
+		}
+
+		// Split the session at midnight so each day gets the time spent online on it
+		for start.Before(end) {
+			dayEnd := start.Truncate(24*time.Hour).AddDate(0, 0, 1)
+			if dayEnd.After(end) {
+				dayEnd = end
+			}
+			seconds := int64(dayEnd.Sub(start).Seconds())
+
+			date := start.Format("2006-01-02")
+			i, ok := dayIndex[date]
+			if !ok {
+				i = len(summary.Days)
+				dayIndex[date] = i
+				summary.Days = append(summary.Days, DailyOnlineTime{Date: date})
+			}
+			summary.Days[i].OnlineSeconds += seconds
+			summary.TotalOnlineSeconds += seconds
+
+			start = dayEnd
+		}
+	}
+
+	return summary, nil
+}
+
+// GetAverageRating returns the driver's average stars from customers and how many ratings it is based on
+func (s *DriverService) GetAverageRating(ctx context.Context, driverID int64) (float64, int64, error) {
+	average, count, err := s.ratingRepo.GetAverageForRatee(ctx, driverID, "driver")
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("error getting average rating for driver %d: %v", driverID, err))
+		return 0, 0, err
+	}
+
+	return average, count, nil
+}
+
+// UpdateProfile changes the driver's name and vehicle number
+// The phone number is not editable here since it proves account ownership through OTP login
+func (s *DriverService) UpdateProfile(ctx context.Context, driverID int64, name, vehicleNo string) (*domain.Driver, error) {
+	name = strings.TrimSpace(name)
+	vehicleNo = domain.NormalizeVehicleNo(vehicleNo)
+	if err := domain.ValidateDriverProfile(name, vehicleNo); err != nil {
+		logger.Error(ctx, fmt.Sprintf("invalid profile for driver %d: %v", driverID, err))
+		return nil, err
+	}
+
+	driver, err := s.driverRepo.GetByID(ctx, driverID)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("error getting driver %d: %v", driverID, err))
+		if errors.Is(err, postgres.ErrDriverNotFound) {
+			return nil, ErrDriverNotFound
+		}
+		return nil, err
+	}
+
+	driver.Name = name
+	driver.VehicleNo = vehicleNo
+	if err := s.driverRepo.Update(ctx, driver); err != nil {
+		logger.Error(ctx, fmt.Sprintf("error updating driver %d: %v", driverID, err))
+		if errors.Is(err, postgres.ErrDriverNotFound) {
+			return nil, ErrDriverNotFound
+		}
+		return nil, err
+	}
+	s.invalidateCachedDriver(ctx, driverID)
+
+	return driver, nil
+}
+
+// GetLocationTrail returns the driver's path over the last minutes, oldest point first
+// Drivers can see their own trail and customers the trail of the driver on their active ride
+func (s *DriverService) GetLocationTrail(ctx context.Context, driverID, viewerID int64, viewerRole string, minutes int) ([]TrailPoint, error) {
+	if minutes < 1 || minutes > MaxTrailMinutes {
+		return nil, ErrInvalidTrailWindow
+	}
+
+	switch viewerRole {
+	case "driver":
+		if viewerID != driverID {
+			return nil, ErrTrailForbidden
+		}
+	case "customer":
+		ride, err := s.rideRepo.GetActiveRideByDriverID(ctx, driverID)
+		if err != nil {
+			if errors.Is(err, repository.ErrRideNotFound) {
+				return nil, ErrTrailForbidden
+			}
+			logger.Error(ctx, fmt.Sprintf("error getting active ride for driver %d: %v", driverID, err))
+			return nil, err
+		}
+		if ride.CustomerID != viewerID {
+			return nil, ErrTrailForbidden
+		}
+	default:
+		return nil, ErrTrailForbidden
+	}
+
+	since := time.Now().Add(-time.Duration(minutes) * time.Minute)
+	trail, err := s.locationService.GetDriverTrail(ctx, driverID, since)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("error getting location trail for driver %d: %v", driverID, err))
+		return nil, err
+	}
+
+	return trail, nil
+}
+
+// GetProfile retrieves the driver's own profile with their online status and last known location
+// A driver who never sent a location ping is returned without one
+func (s *DriverService) GetProfile(ctx context.Context, driverID int64) (*domain.Driver, error) {
+	driver, err := s.driverRepo.GetByID(ctx, driverID)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("error getting driver %d: %v", driverID, err))
+		if errors.Is(err, postgres.ErrDriverNotFound) {
+			return nil, ErrDriverNotFound
+		}
+		return nil, err
+	}
+
+	isOnline, err := s.onlineStatusRepo.IsDriverOnline(ctx, driverID)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("error getting online status of driver %d: %v", driverID, err))
+		return nil, err
+	}
+	driver.IsOnline = isOnline
+
+	lat, lng, lastPingAt, err := s.locationService.GetDriverLocation(ctx, driverID)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("no location found for driver %d: %v", driverID, err))
+		return driver, nil
+	}
+	driver.CurrentLat = &lat
+	driver.CurrentLng = &lng
+	driver.LastPingAt = lastPingAt
+
+	return driver, nil
+}
+
 // GetByID retrieves a driver by ID
+// Drivers are served from Redis for profileCacheTTL after being read, UpdateProfile drops the cached copy
+// The online status and location of a cached driver may be stale, use the online status repository and location service for those
 func (s *DriverService) GetByID(ctx context.Context, id int64) (*domain.Driver, error) {
-	return s.driverRepo.GetByID(ctx, id)
+	if s.profileCacheTTL <= 0 {
+		return s.driverRepo.GetByID(ctx, id)
+	}
+
+	key := utils.DriverProfileKey(id)
+	cached, err := s.redis.Get(ctx, key).Bytes()
+	if err == nil {
+		var driver domain.Driver
+		if err := json.Unmarshal(cached, &driver); err == nil {
+			s.cacheMetrics.Hits.WithLabelValues(driverProfileCache).Inc()
+			return &driver, nil
+		}
+		logger.Error(ctx, fmt.Sprintf("Failed to decode cached driver %d: %v", id, err))
+	} else if !errors.Is(err, redis.Nil) {
+		logger.Error(ctx, fmt.Sprintf("Failed to read cached driver %d: %v", id, err))
+	}
+	s.cacheMetrics.Misses.WithLabelValues(driverProfileCache).Inc()
+
+	driver, err := s.driverRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := json.Marshal(driver)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to encode driver %d for the cache: %v", id, err))
+		return driver, nil
+	}
+	if err := s.redis.Set(ctx, key, payload, s.profileCacheTTL).Err(); err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to cache driver %d: %v", id, err))
+	}
+
+	return driver, nil
 }
 
-func (s *DriverService) GetNearestDrivers(ctx context.Context, lat, lng, radius float64, limit int) ([]int64, error) {
-	if radius <= 0 {
-		radius = 3000 // default 3 km
+// invalidateCachedDriver drops the cached copy of the driver so the next GetByID reads the database
+func (s *DriverService) invalidateCachedDriver(ctx context.Context, driverID int64) {
+	if s.profileCacheTTL <= 0 {
+		return
 	}
-	if limit <= 0 {
-		limit = 5
+	if err := s.redis.Del(ctx, utils.DriverProfileKey(driverID)).Err(); err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to drop cached driver %d: %v", driverID, err))
 	}
+}
+
+func (s *DriverService) GetNearestDrivers(ctx context.Context, lat, lng, radius float64, limit int) ([]int64, error) {
+	radius, limit = s.locationService.DriverSearch(radius, limit)
 
 	nearestDrivers, err := s.locationService.FindNearestDrivers(ctx, lat, lng, radius, limit)
 	if err != nil {
 		return nil, err
 	}
 
-	return nearestDrivers, nil
+	return s.ExcludeBusyDrivers(ctx, nearestDrivers)
+}
+
+// GetNearestDriversWithDistance is GetNearestDrivers with each driver's distance and last location update
+func (s *DriverService) GetNearestDriversWithDistance(ctx context.Context, lat, lng, radius float64, limit int) ([]repository.NearestDriver, error) {
+	radius, limit = s.locationService.DriverSearch(radius, limit)
+
+	nearestDrivers, err := s.locationService.FindNearestDriversWithDistance(ctx, lat, lng, radius, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	driverIDs := make([]int64, len(nearestDrivers))
+	for i, driver := range nearestDrivers {
+		driverIDs[i] = driver.DriverID
+	}
+	available, err := s.ExcludeBusyDrivers(ctx, driverIDs)
+	if err != nil {
+		return nil, err
+	}
+	if len(available) == len(nearestDrivers) {
+		return nearestDrivers, nil
+	}
+
+	isAvailable := make(map[int64]bool, len(available))
+	for _, driverID := range available {
+		isAvailable[driverID] = true
+	}
+	filtered := make([]repository.NearestDriver, 0, len(available))
+	for _, driver := range nearestDrivers {
+		if isAvailable[driver.DriverID] {
+			filtered = append(filtered, driver)
+		}
+	}
+
+	return filtered, nil
+}
+
+// ExcludeBusyDrivers drops the drivers who have accepted, arrived at or started a ride from driverIDs, keeping their order
+// The busy drivers are looked up with a single query
+func (s *DriverService) ExcludeBusyDrivers(ctx context.Context, driverIDs []int64) ([]int64, error) {
+	if len(driverIDs) == 0 {
+		return driverIDs, nil
+	}
+
+	busy, err := s.rideRepo.GetBusyDriverIDs(ctx, driverIDs)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to check busy drivers: %v", err))
+		return nil, err
+	}
+	if len(busy) == 0 {
+		return driverIDs, nil
+	}
+
+	isBusy := make(map[int64]bool, len(busy))
+	for _, driverID := range busy {
+		isBusy[driverID] = true
+	}
+	available := make([]int64, 0, len(driverIDs))
+	for _, driverID := range driverIDs {
+		if !isBusy[driverID] {
+			available = append(available, driverID)
+		}
+	}
+
+	return available, nil
+}
+
+// DriverEarnings summarises a driver's completed rides over a date range
+type DriverEarnings struct {
+	DriverID  int64         `json:"driver_id"`
+	From      time.Time     `json:"from"`
+	To        time.Time     `json:"to"`
+	RideCount int           `json:"ride_count"`
+	GrossFare float64       `json:"gross_fare"`
+	Rides     []RideEarning `json:"rides"`
+}
+
+// DriverShiftSummary is how long a driver was online over a date range, in total and per day
+type DriverShiftSummary struct {
+	DriverID           int64                   `json:"driver_id"`
+	From               time.Time               `json:"from"`
+	To                 time.Time               `json:"to"`
+	TotalOnlineSeconds int64                   `json:"total_online_seconds"`
+	Days               []DailyOnlineTime       `json:"days"`
+	Sessions           []*domain.DriverSession `json:"sessions"`
+}
+
+// DailyOnlineTime is how long a driver was online on one UTC day
+type DailyOnlineTime struct {
+	Date          string `json:"date"` // YYYY-MM-DD
+	OnlineSeconds int64  `json:"online_seconds"`
+}
+
+// RideEarning is the fare earned from a single completed ride
+type RideEarning struct {
+	RideID      int64      `json:"ride_id"`
+	Fare        float64    `json:"fare"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
 }
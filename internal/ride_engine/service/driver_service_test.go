@@ -0,0 +1,1249 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/repository"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/repository/postgres"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/config"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/logger"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/metrics"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/middleware"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/testutil"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/utils"
+)
+
+// MockDriverRepository is a mock implementation of the driver repository
+type MockDriverRepository struct {
+	mock.Mock
+}
+
+func (m *MockDriverRepository) Create(ctx context.Context, driver *domain.Driver) error {
+	args := m.Called(ctx, driver)
+	return args.Error(0)
+}
+
+func (m *MockDriverRepository) GetByID(ctx context.Context, id int64) (*domain.Driver, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Driver), args.Error(1)
+}
+
+func (m *MockDriverRepository) GetByPhone(ctx context.Context, phone string) (*domain.Driver, error) {
+	args := m.Called(ctx, phone)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Driver), args.Error(1)
+}
+
+func (m *MockDriverRepository) Update(ctx context.Context, driver *domain.Driver) error {
+	args := m.Called(ctx, driver)
+	return args.Error(0)
+}
+
+func (m *MockDriverRepository) UpdatePing(ctx context.Context, driverID int64, lat, lng float64, pingTime time.Time) error {
+	args := m.Called(ctx, driverID, lat, lng, pingTime)
+	return args.Error(0)
+}
+
+func (m *MockDriverRepository) SetOnlineStatus(ctx context.Context, driverID int64, isOnline bool) error {
+	args := m.Called(ctx, driverID, isOnline)
+	return args.Error(0)
+}
+
+func (m *MockDriverRepository) GetOnlineDrivers(ctx context.Context) ([]*domain.Driver, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Driver), args.Error(1)
+}
+
+func (m *MockDriverRepository) MarkOfflineIfInactive(ctx context.Context, cutoff time.Time) error {
+	args := m.Called(ctx, cutoff)
+	return args.Error(0)
+}
+
+// MockOTPRepository is a mock implementation of the OTP repository
+type MockOTPRepository struct {
+	mock.Mock
+}
+
+func (m *MockOTPRepository) SaveOTP(ctx context.Context, phone, otp, purpose string, expiresAt time.Time) error {
+	args := m.Called(ctx, phone, otp, purpose, expiresAt)
+	return args.Error(0)
+}
+
+func (m *MockOTPRepository) VerifyOTP(ctx context.Context, phone, otp, purpose string) (bool, error) {
+	args := m.Called(ctx, phone, otp, purpose)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockOTPRepository) MarkExpired(ctx context.Context, phone string) error {
+	args := m.Called(ctx, phone)
+	return args.Error(0)
+}
+
+func (m *MockOTPRepository) GetOTPHistory(ctx context.Context, filter repository.OTPFilter, page repository.Page) ([]repository.OTPRecord, int64, error) {
+	args := m.Called(ctx, filter, page)
+	if args.Get(0) == nil {
+		return nil, args.Get(1).(int64), args.Error(2)
+	}
+	return args.Get(0).([]repository.OTPRecord), args.Get(1).(int64), args.Error(2)
+}
+
+func (m *MockOTPRepository) CleanupExpiredOTPs(ctx context.Context, olderThan time.Time) error {
+	args := m.Called(ctx, olderThan)
+	return args.Error(0)
+}
+
+// MockOnlineStatusRepository is a mock implementation of the online status repository
+type MockOnlineStatusRepository struct {
+	mock.Mock
+}
+
+func (m *MockOnlineStatusRepository) UpsertOnlineDriver(ctx context.Context, driverID int64, lat, lng float64) error {
+	args := m.Called(ctx, driverID, lat, lng)
+	return args.Error(0)
+}
+
+func (m *MockOnlineStatusRepository) SetDriverOffline(ctx context.Context, driverID int64) (*repository.OnlineDriver, error) {
+	args := m.Called(ctx, driverID)
+	removed, _ := args.Get(0).(*repository.OnlineDriver)
+	return removed, args.Error(1)
+}
+
+func (m *MockOnlineStatusRepository) IsDriverOnline(ctx context.Context, driverID int64) (bool, error) {
+	args := m.Called(ctx, driverID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockOnlineStatusRepository) GetOnlineDrivers(ctx context.Context) ([]int64, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]int64), args.Error(1)
+}
+
+func (m *MockOnlineStatusRepository) GetOnlineDriversCount(ctx context.Context) (int64, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockOnlineStatusRepository) RemoveInactiveDrivers(ctx context.Context, cutoffTime time.Time) ([]repository.OnlineDriver, error) {
+	args := m.Called(ctx, cutoffTime)
+	removed, _ := args.Get(0).([]repository.OnlineDriver)
+	return removed, args.Error(1)
+}
+
+func (m *MockOnlineStatusRepository) GetOnlineDriversByIDs(ctx context.Context, driverIDs []int64) ([]int64, error) {
+	args := m.Called(ctx, driverIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]int64), args.Error(1)
+}
+
+// fakeOnlineStatusRepository keeps the last ping of each online driver in memory and, like the postgres repository,
+// counts a driver as online while their last ping is within 2 minutes of its clock
+type fakeOnlineStatusRepository struct {
+	repository.OnlineStatusRepository
+	lastPing map[int64]time.Time
+	now      func() time.Time
+}
+
+func newFakeOnlineStatusRepository(now func() time.Time) *fakeOnlineStatusRepository {
+	return &fakeOnlineStatusRepository{lastPing: make(map[int64]time.Time), now: now}
+}
+
+func (f *fakeOnlineStatusRepository) UpsertOnlineDriver(ctx context.Context, driverID int64, lat, lng float64) error {
+	f.lastPing[driverID] = f.now()
+	return nil
+}
+
+func (f *fakeOnlineStatusRepository) IsDriverOnline(ctx context.Context, driverID int64) (bool, error) {
+	lastPing, ok := f.lastPing[driverID]
+	return ok && f.now().Sub(lastPing) < 2*time.Minute, nil
+}
+
+func (f *fakeOnlineStatusRepository) GetOnlineDriversByIDs(ctx context.Context, driverIDs []int64) ([]int64, error) {
+	online := []int64{}
+	for _, driverID := range driverIDs {
+		if ok, _ := f.IsDriverOnline(ctx, driverID); ok {
+			online = append(online, driverID)
+		}
+	}
+	return online, nil
+}
+
+// fakeDriverSessionRepository keeps archived sessions in memory
+type fakeDriverSessionRepository struct {
+	sessions []*domain.DriverSession
+	err      error
+}
+
+func (f *fakeDriverSessionRepository) Create(ctx context.Context, session *domain.DriverSession) error {
+	if f.err != nil {
+		return f.err
+	}
+	session.ID = int64(len(f.sessions) + 1)
+	f.sessions = append(f.sessions, session)
+	return nil
+}
+
+func (f *fakeDriverSessionRepository) GetByDriverID(ctx context.Context, driverID int64, from, to time.Time) ([]*domain.DriverSession, error) {
+	var sessions []*domain.DriverSession
+	for _, session := range f.sessions {
+		if session.DriverID == driverID && session.EndedAt.After(from) && session.StartedAt.Before(to) {
+			sessions = append(sessions, session)
+		}
+	}
+	return sessions, nil
+}
+
+func newTestDriverService(onlineRepo *MockOnlineStatusRepository, locationRepo *MockLocationRepository) *DriverService {
+	return &DriverService{
+		onlineStatusRepo: onlineRepo,
+		sessionRepo:      &fakeDriverSessionRepository{},
+		locationService:  &LocationService{repo: locationRepo},
+		tx:               &fakeTransactor{},
+		now:              time.Now,
+	}
+}
+
+func TestDriverService_SetOnlineStatus_Online(t *testing.T) {
+	onlineRepo := new(MockOnlineStatusRepository)
+	locationRepo := new(MockLocationRepository)
+	service := newTestDriverService(onlineRepo, locationRepo)
+
+	ctx := context.Background()
+	driverID := int64(456)
+	lat := 23.8100
+	lng := 90.4120
+	recent := time.Now().Add(-30 * time.Second)
+
+	locationRepo.On("GetDriverLocation", ctx, driverID).Return(lat, lng, &recent, nil)
+	onlineRepo.On("UpsertOnlineDriver", ctx, driverID, lat, lng).Return(nil)
+
+	err := service.SetOnlineStatus(ctx, driverID, true)
+
+	assert.NoError(t, err)
+	onlineRepo.AssertExpectations(t)
+	locationRepo.AssertExpectations(t)
+}
+
+func TestDriverService_SetOnlineStatus_OnlineWithoutLocation(t *testing.T) {
+	onlineRepo := new(MockOnlineStatusRepository)
+	locationRepo := new(MockLocationRepository)
+	service := newTestDriverService(onlineRepo, locationRepo)
+
+	ctx := context.Background()
+	driverID := int64(456)
+
+	locationRepo.On("GetDriverLocation", ctx, driverID).Return(0.0, 0.0, (*time.Time)(nil), errors.New("driver location not found"))
+
+	err := service.SetOnlineStatus(ctx, driverID, true)
+
+	assert.ErrorIs(t, err, ErrLocationPingRequired)
+	onlineRepo.AssertNotCalled(t, "UpsertOnlineDriver", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDriverService_SetOnlineStatus_OnlineWithStaleLocation(t *testing.T) {
+	onlineRepo := new(MockOnlineStatusRepository)
+	locationRepo := new(MockLocationRepository)
+	service := newTestDriverService(onlineRepo, locationRepo)
+
+	ctx := context.Background()
+	driverID := int64(456)
+	stale := time.Now().Add(-10 * time.Minute)
+
+	locationRepo.On("GetDriverLocation", ctx, driverID).Return(23.8100, 90.4120, &stale, nil)
+
+	err := service.SetOnlineStatus(ctx, driverID, true)
+
+	assert.ErrorIs(t, err, ErrLocationPingRequired)
+	onlineRepo.AssertNotCalled(t, "UpsertOnlineDriver", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDriverService_SetOnlineStatus_Offline(t *testing.T) {
+	onlineRepo := new(MockOnlineStatusRepository)
+	locationRepo := new(MockLocationRepository)
+	service := newTestDriverService(onlineRepo, locationRepo)
+
+	ctx := context.Background()
+	driverID := int64(456)
+
+	onlineRepo.On("SetDriverOffline", ctx, driverID).Return(nil, nil)
+
+	err := service.SetOnlineStatus(ctx, driverID, false)
+
+	assert.NoError(t, err)
+	onlineRepo.AssertExpectations(t)
+	locationRepo.AssertNotCalled(t, "GetDriverLocation", mock.Anything, mock.Anything)
+}
+
+func TestDriverService_SetOnlineStatus_OfflineError(t *testing.T) {
+	onlineRepo := new(MockOnlineStatusRepository)
+	locationRepo := new(MockLocationRepository)
+	service := newTestDriverService(onlineRepo, locationRepo)
+
+	ctx := context.Background()
+	driverID := int64(456)
+
+	onlineRepo.On("SetDriverOffline", ctx, driverID).Return(nil, errors.New("database error"))
+
+	err := service.SetOnlineStatus(ctx, driverID, false)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "database error")
+	onlineRepo.AssertExpectations(t)
+}
+
+func TestDriverService_SetOnlineStatus_OnlineThenOfflineRecordsSession(t *testing.T) {
+	onlineRepo := new(MockOnlineStatusRepository)
+	locationRepo := new(MockLocationRepository)
+	sessionRepo := &fakeDriverSessionRepository{}
+	service := newTestDriverService(onlineRepo, locationRepo)
+	service.sessionRepo = sessionRepo
+
+	ctx := context.Background()
+	driverID := int64(456)
+	wentOnline := time.Date(2025, 3, 10, 8, 0, 0, 0, time.UTC)
+	recent := time.Now().Add(-30 * time.Second)
+
+	// The online row is kept like the repository does, so going offline returns when the driver went online
+	online := &repository.OnlineDriver{}
+	locationRepo.On("GetDriverLocation", ctx, driverID).Return(23.81, 90.41, &recent, nil)
+	onlineRepo.On("UpsertOnlineDriver", ctx, driverID, 23.81, 90.41).
+		Run(func(mock.Arguments) {
+			*online = repository.OnlineDriver{DriverID: driverID, IsOnline: true, WentOnlineAt: wentOnline, LastPingAt: wentOnline}
+		}).
+		Return(nil)
+	onlineRepo.On("SetDriverOffline", ctx, driverID).Return(online, nil)
+
+	assert.NoError(t, service.SetOnlineStatus(ctx, driverID, true))
+
+	service.now = func() time.Time { return wentOnline.Add(2*time.Hour + 30*time.Minute) }
+	assert.NoError(t, service.SetOnlineStatus(ctx, driverID, false))
+
+	if assert.Len(t, sessionRepo.sessions, 1) {
+		session := sessionRepo.sessions[0]
+		assert.Equal(t, driverID, session.DriverID)
+		assert.Equal(t, wentOnline, session.StartedAt)
+		assert.Equal(t, 2*time.Hour+30*time.Minute, session.Duration())
+	}
+}
+
+func TestDriverService_SetOnlineStatus_OfflineWhenNotOnlineRecordsNoSession(t *testing.T) {
+	onlineRepo := new(MockOnlineStatusRepository)
+	sessionRepo := &fakeDriverSessionRepository{}
+	service := newTestDriverService(onlineRepo, new(MockLocationRepository))
+	service.sessionRepo = sessionRepo
+
+	ctx := context.Background()
+	onlineRepo.On("SetDriverOffline", ctx, int64(456)).Return(nil, nil)
+
+	assert.NoError(t, service.SetOnlineStatus(ctx, 456, false))
+	assert.Empty(t, sessionRepo.sessions)
+}
+
+func TestDriverService_SetOnlineStatus_OfflineRollsBackWhenSessionFails(t *testing.T) {
+	onlineRepo := new(MockOnlineStatusRepository)
+	tx := &fakeTransactor{}
+	service := newTestDriverService(onlineRepo, new(MockLocationRepository))
+	service.sessionRepo = &fakeDriverSessionRepository{err: errors.New("insert failed")}
+	service.tx = tx
+
+	ctx := context.Background()
+	wentOnline := time.Now().Add(-time.Hour)
+	onlineRepo.On("SetDriverOffline", ctx, int64(456)).Return(&repository.OnlineDriver{DriverID: 456, WentOnlineAt: wentOnline}, nil)
+
+	err := service.SetOnlineStatus(ctx, 456, false)
+
+	assert.Error(t, err)
+	assert.Equal(t, 1, tx.rolledBack)
+	assert.Equal(t, 0, tx.committed)
+}
+
+func TestDriverService_GetShiftSummary_SplitsSessionsPerDay(t *testing.T) {
+	sessionRepo := &fakeDriverSessionRepository{}
+	service := newTestDriverService(new(MockOnlineStatusRepository), new(MockLocationRepository))
+	service.sessionRepo = sessionRepo
+
+	ctx := context.Background()
+	day := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+	sessionRepo.sessions = []*domain.DriverSession{
+		// Starts before the range, only the hour after midnight counts
+		{DriverID: 7, StartedAt: day.Add(-2 * time.Hour), EndedAt: day.Add(time.Hour)},
+		{DriverID: 7, StartedAt: day.Add(8 * time.Hour), EndedAt: day.Add(10 * time.Hour)},
+		// Crosses midnight, split over both days
+		{DriverID: 7, StartedAt: day.Add(23 * time.Hour), EndedAt: day.Add(25*time.Hour + 30*time.Minute)},
+		{DriverID: 8, StartedAt: day.Add(8 * time.Hour), EndedAt: day.Add(9 * time.Hour)},
+	}
+
+	summary, err := service.GetShiftSummary(ctx, 7, day, day.AddDate(0, 0, 2))
+
+	assert.NoError(t, err)
+	assert.Equal(t, []DailyOnlineTime{
+		{Date: "2025-03-10", OnlineSeconds: int64((4 * time.Hour).Seconds())},
+		{Date: "2025-03-11", OnlineSeconds: int64((90 * time.Minute).Seconds())},
+	}, summary.Days)
+	assert.Equal(t, int64((5*time.Hour + 30*time.Minute).Seconds()), summary.TotalOnlineSeconds)
+	assert.Len(t, summary.Sessions, 3)
+}
+
+func TestDriverService_GetShiftSummary_InvalidRange(t *testing.T) {
+	service := newTestDriverService(new(MockOnlineStatusRepository), new(MockLocationRepository))
+	now := time.Now()
+
+	_, err := service.GetShiftSummary(context.Background(), 7, now, now)
+
+	assert.ErrorIs(t, err, ErrInvalidDateRange)
+}
+
+func TestDriverService_VerifyOTP_TokenPassesAuthMiddleware(t *testing.T) {
+	redisClient, _ := testutil.NewFakeRedis()
+	driverRepo := new(MockDriverRepository)
+	otpRepo := new(MockOTPRepository)
+	service := &DriverService{
+		driverRepo: driverRepo,
+		otpService: NewOTPService(redisClient, otpRepo, 0, nil, false),
+		jwtKeys:    testJWTKeys,
+		jwtExpiry:  24,
+		redis:      redisClient,
+	}
+	authMiddleware := middleware.NewAuthMiddleware(redisClient, testJWTKeys)
+
+	ctx := context.Background()
+	phone := "+8801700000000"
+	driver := &domain.Driver{ID: 456, Name: "Test Driver", Phone: phone}
+
+	require.NoError(t, redisClient.Set(ctx, otpKey(driverLoginOTPPurpose, phone), "123456", 2*time.Minute).Err())
+	otpRepo.On("VerifyOTP", ctx, phone, "123456", driverLoginOTPPurpose).Return(true, nil)
+	driverRepo.On("GetByPhone", ctx, phone).Return(driver, nil)
+
+	loggedIn, token, err := service.VerifyOTP(ctx, phone, "123456")
+	require.NoError(t, err)
+	assert.Equal(t, driver.ID, loggedIn.ID)
+
+	assert.Equal(t, http.StatusOK, authenticate(t, authMiddleware, token), "Freshly issued driver token should be accepted")
+}
+
+func TestDriverService_VerifyOTP_TokenTTLMatchesExpiry(t *testing.T) {
+	redisClient, _ := testutil.NewFakeRedis()
+	driverRepo := new(MockDriverRepository)
+	otpRepo := new(MockOTPRepository)
+	service := &DriverService{
+		driverRepo: driverRepo,
+		otpService: NewOTPService(redisClient, otpRepo, 0, nil, false),
+		jwtKeys:    testJWTKeys,
+		jwtExpiry:  24,
+		redis:      redisClient,
+	}
+
+	ctx := context.Background()
+	phone := "+8801700000000"
+	driver := &domain.Driver{ID: 456, Name: "Test Driver", Phone: phone}
+
+	require.NoError(t, redisClient.Set(ctx, otpKey(driverLoginOTPPurpose, phone), "123456", 2*time.Minute).Err())
+	otpRepo.On("VerifyOTP", ctx, phone, "123456", driverLoginOTPPurpose).Return(true, nil)
+	driverRepo.On("GetByPhone", ctx, phone).Return(driver, nil)
+
+	_, token, err := service.VerifyOTP(ctx, phone, "123456")
+	require.NoError(t, err)
+
+	claims, err := utils.ValidateJWT(token, testJWTKeys)
+	require.NoError(t, err)
+
+	ttl, err := redisClient.TTL(ctx, utils.JWTRedisKey("driver", driver.ID)).Result()
+	require.NoError(t, err)
+
+	assert.InDelta(t, time.Until(claims.ExpiresAt.Time).Seconds(), ttl.Seconds(), 2, "Redis TTL should match the token expiry")
+	assert.InDelta(t, (24 * time.Hour).Seconds(), ttl.Seconds(), 2)
+}
+
+func TestDriverService_GetEarnings(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := &DriverService{rideRepo: rideRepo}
+
+	ctx := context.Background()
+	driverID := int64(456)
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 1, 8, 0, 0, 0, 0, time.UTC)
+	fare1, fare2 := 120.5, 80.0
+	completed1 := from.Add(24 * time.Hour)
+	completed2 := from.Add(48 * time.Hour)
+	rides := []*domain.Ride{
+		{ID: 2, DriverID: &driverID, Status: domain.RideStatusCompleted, Fare: &fare2, CompletedAt: &completed2},
+		{ID: 1, DriverID: &driverID, Status: domain.RideStatusCompleted, Fare: &fare1, CompletedAt: &completed1},
+	}
+
+	rideRepo.On("GetCompletedByDriverID", ctx, driverID, from, to).Return(rides, nil)
+
+	earnings, err := service.GetEarnings(ctx, driverID, from, to)
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, earnings.RideCount)
+	assert.InDelta(t, 200.5, earnings.GrossFare, 0.001)
+	require.Len(t, earnings.Rides, 2)
+	assert.Equal(t, int64(2), earnings.Rides[0].RideID)
+	assert.Equal(t, fare2, earnings.Rides[0].Fare)
+	assert.Equal(t, &completed2, earnings.Rides[0].CompletedAt)
+	rideRepo.AssertExpectations(t)
+}
+
+func TestDriverService_GetEarnings_NoCompletedRides(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := &DriverService{rideRepo: rideRepo}
+
+	ctx := context.Background()
+	driverID := int64(456)
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 1, 8, 0, 0, 0, 0, time.UTC)
+
+	rideRepo.On("GetCompletedByDriverID", ctx, driverID, from, to).Return(nil, nil)
+
+	earnings, err := service.GetEarnings(ctx, driverID, from, to)
+
+	require.NoError(t, err)
+	assert.Equal(t, 0, earnings.RideCount)
+	assert.Equal(t, 0.0, earnings.GrossFare)
+	assert.NotNil(t, earnings.Rides)
+	assert.Empty(t, earnings.Rides)
+}
+
+func TestDriverService_GetEarnings_InvalidRange(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := &DriverService{rideRepo: rideRepo}
+
+	ctx := context.Background()
+	from := time.Date(2025, 1, 8, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	earnings, err := service.GetEarnings(ctx, 456, from, to)
+
+	assert.ErrorIs(t, err, ErrInvalidDateRange)
+	assert.Nil(t, earnings)
+	rideRepo.AssertNotCalled(t, "GetCompletedByDriverID", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDriverService_GetAverageRating(t *testing.T) {
+	ratingRepo := new(MockRatingRepository)
+	service := &DriverService{ratingRepo: ratingRepo}
+
+	ctx := context.Background()
+
+	ratingRepo.On("GetAverageForRatee", ctx, int64(456), "driver").Return(4.5, int64(2), nil)
+
+	average, count, err := service.GetAverageRating(ctx, 456)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 4.5, average)
+	assert.Equal(t, int64(2), count)
+	ratingRepo.AssertExpectations(t)
+}
+
+func TestDriverService_Register_InvalidVehicleType(t *testing.T) {
+	driverRepo := new(MockDriverRepository)
+	service := &DriverService{driverRepo: driverRepo}
+
+	ctx := context.Background()
+	phone := "+8801700000000"
+
+	driverRepo.On("GetByPhone", ctx, phone).Return(nil, postgres.ErrDriverNotFound)
+
+	driver, err := service.Register(ctx, "Test Driver", phone, "DHA-1234", domain.RideType("truck"))
+
+	assert.ErrorIs(t, err, domain.ErrInvalidRideType)
+	assert.Nil(t, driver)
+	driverRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestDriverService_Register_DefaultsToEconomy(t *testing.T) {
+	driverRepo := new(MockDriverRepository)
+	service := &DriverService{driverRepo: driverRepo}
+
+	ctx := context.Background()
+	phone := "+8801700000000"
+
+	driverRepo.On("GetByPhone", ctx, phone).Return(nil, postgres.ErrDriverNotFound)
+	driverRepo.On("Create", ctx, mock.Anything).Return(nil)
+
+	driver, err := service.Register(ctx, "Test Driver", phone, "DHA-1234", "")
+
+	require.NoError(t, err)
+	assert.Equal(t, domain.RideTypeEconomy, driver.VehicleType)
+}
+
+// captureLogs sends the logger's output to a buffer for the duration of the test
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+
+	l := logger.DefaultLogger()
+	out := l.Out
+	t.Cleanup(func() { l.SetOutput(out) })
+
+	var buf bytes.Buffer
+	l.SetOutput(&buf)
+	return &buf
+}
+
+func TestDriverService_Register_FirstTime(t *testing.T) {
+	driverRepo := new(MockDriverRepository)
+	service := &DriverService{driverRepo: driverRepo}
+	logs := captureLogs(t)
+
+	ctx := context.Background()
+	phone := "+8801700000000"
+
+	driverRepo.On("GetByPhone", ctx, phone).Return(nil, postgres.ErrDriverNotFound)
+	driverRepo.On("Create", ctx, mock.AnythingOfType("*domain.Driver")).Return(nil)
+
+	driver, err := service.Register(ctx, "Test Driver", phone, "DHA-1234", domain.RideTypeBike)
+
+	require.NoError(t, err)
+	assert.Equal(t, phone, driver.Phone)
+	// The phone not being registered yet is the expected path, not an error
+	assert.NotContains(t, logs.String(), "level=error")
+	driverRepo.AssertExpectations(t)
+}
+
+func TestDriverService_Register_DuplicatePhone(t *testing.T) {
+	driverRepo := new(MockDriverRepository)
+	service := &DriverService{driverRepo: driverRepo}
+
+	ctx := context.Background()
+	phone := "+8801700000000"
+
+	driverRepo.On("GetByPhone", ctx, phone).Return(&domain.Driver{ID: 1, Phone: phone}, nil)
+
+	driver, err := service.Register(ctx, "Test Driver", phone, "DHA-1234", "")
+
+	assert.ErrorIs(t, err, ErrDriverAlreadyExists)
+	assert.Nil(t, driver)
+	driverRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestDriverService_Register_DuplicatePhoneOnCreate(t *testing.T) {
+	driverRepo := new(MockDriverRepository)
+	service := &DriverService{driverRepo: driverRepo}
+
+	ctx := context.Background()
+	phone := "+8801700000000"
+
+	// Another registration with the phone won the race after the lookup
+	driverRepo.On("GetByPhone", ctx, phone).Return(nil, postgres.ErrDriverNotFound)
+	driverRepo.On("Create", ctx, mock.AnythingOfType("*domain.Driver")).Return(postgres.ErrDriverAlreadyExists)
+
+	driver, err := service.Register(ctx, "Test Driver", phone, "DHA-1234", "")
+
+	assert.ErrorIs(t, err, ErrDriverAlreadyExists)
+	assert.Nil(t, driver)
+}
+
+func TestDriverService_Register_LookupFails(t *testing.T) {
+	driverRepo := new(MockDriverRepository)
+	service := &DriverService{driverRepo: driverRepo}
+
+	ctx := context.Background()
+	phone := "+8801700000000"
+
+	driverRepo.On("GetByPhone", ctx, phone).Return(nil, errors.New("database error"))
+
+	driver, err := service.Register(ctx, "Test Driver", phone, "DHA-1234", "")
+
+	assert.EqualError(t, err, "database error")
+	assert.Nil(t, driver)
+	driverRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestDriverService_GetLocationTrail_OwnTrail(t *testing.T) {
+	locationRepo := new(MockLocationRepository)
+	service := newTestDriverService(new(MockOnlineStatusRepository), locationRepo)
+
+	ctx := context.Background()
+	driverID := int64(456)
+	first := time.Now().Add(-10 * time.Minute)
+	points := []repository.DriverLocationPoint{
+		{DriverID: driverID, Location: repository.GeoJSON{Type: "Point", Coordinates: []float64{90.4120, 23.8100}}, RecordedAt: first},
+		{DriverID: driverID, Location: repository.GeoJSON{Type: "Point", Coordinates: []float64{90.4125, 23.8105}}, RecordedAt: first.Add(time.Minute)},
+	}
+	locationRepo.On("GetDriverLocationHistory", ctx, driverID, mock.AnythingOfType("time.Time")).Return(points, nil)
+
+	trail, err := service.GetLocationTrail(ctx, driverID, driverID, "driver", 15)
+
+	require.NoError(t, err)
+	require.Len(t, trail, 2)
+	assert.Equal(t, TrailPoint{Lat: 23.8100, Lng: 90.4120, RecordedAt: first}, trail[0])
+	assert.Equal(t, 23.8105, trail[1].Lat)
+
+	since := locationRepo.Calls[0].Arguments.Get(2).(time.Time)
+	assert.WithinDuration(t, time.Now().Add(-15*time.Minute), since, time.Second)
+}
+
+func TestDriverService_GetLocationTrail_CustomerOnActiveRide(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	locationRepo := new(MockLocationRepository)
+	service := newTestDriverService(new(MockOnlineStatusRepository), locationRepo)
+	service.rideRepo = rideRepo
+
+	ctx := context.Background()
+	driverID := int64(456)
+	rideRepo.On("GetActiveRideByDriverID", ctx, driverID).Return(&domain.Ride{ID: 1, CustomerID: 123, DriverID: &driverID}, nil)
+	locationRepo.On("GetDriverLocationHistory", ctx, driverID, mock.AnythingOfType("time.Time")).Return(nil, nil)
+
+	trail, err := service.GetLocationTrail(ctx, driverID, 123, "customer", DefaultTrailMinutes)
+
+	require.NoError(t, err)
+	assert.NotNil(t, trail)
+	assert.Empty(t, trail)
+
+	_, err = service.GetLocationTrail(ctx, driverID, 999, "customer", DefaultTrailMinutes)
+	assert.ErrorIs(t, err, ErrTrailForbidden, "Customer of another ride")
+}
+
+func TestDriverService_GetLocationTrail_Forbidden(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	locationRepo := new(MockLocationRepository)
+	service := newTestDriverService(new(MockOnlineStatusRepository), locationRepo)
+	service.rideRepo = rideRepo
+
+	ctx := context.Background()
+	driverID := int64(456)
+	rideRepo.On("GetActiveRideByDriverID", ctx, driverID).Return(nil, repository.ErrRideNotFound)
+
+	_, err := service.GetLocationTrail(ctx, driverID, 789, "driver", DefaultTrailMinutes)
+	assert.ErrorIs(t, err, ErrTrailForbidden, "Another driver")
+
+	_, err = service.GetLocationTrail(ctx, driverID, 123, "customer", DefaultTrailMinutes)
+	assert.ErrorIs(t, err, ErrTrailForbidden, "Driver has no active ride")
+
+	_, err = service.GetLocationTrail(ctx, driverID, driverID, "driver", 0)
+	assert.ErrorIs(t, err, ErrInvalidTrailWindow)
+
+	_, err = service.GetLocationTrail(ctx, driverID, driverID, "driver", MaxTrailMinutes+1)
+	assert.ErrorIs(t, err, ErrInvalidTrailWindow)
+
+	locationRepo.AssertNotCalled(t, "GetDriverLocationHistory", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func newTestLocationUpdateService(rideRepo *MockRideRepository, locationRepo *MockLocationRepository) *DriverService {
+	redisClient, _ := testutil.NewFakeRedis()
+	service := newTestDriverService(nil, locationRepo)
+	service.onlineStatusRepo = newFakeOnlineStatusRepository(time.Now)
+	service.rideRepo = rideRepo
+	service.trackingService = NewTrackingService(redisClient, rideRepo)
+	return service
+}
+
+func TestDriverService_UpdateLocation_RecordsRouteWhileStarted(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	locationRepo := new(MockLocationRepository)
+	service := newTestLocationUpdateService(rideRepo, locationRepo)
+
+	ctx := context.Background()
+	driverID := int64(456)
+	ride := &domain.Ride{ID: 1, CustomerID: 123, DriverID: &driverID, Status: domain.RideStatusStarted}
+
+	locationRepo.On("UpdateDriverLocation", ctx, driverID, 23.8100, 90.4120).Return(nil)
+	rideRepo.On("GetActiveRideByDriverID", ctx, driverID).Return(ride, nil)
+
+	var saved repository.RideLocation
+	locationRepo.On("SaveRideLocation", ctx, mock.Anything).
+		Run(func(args mock.Arguments) { saved = args.Get(1).(repository.RideLocation) }).
+		Return(nil)
+
+	err := service.UpdateLocation(ctx, driverID, 23.8100, 90.4120)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), saved.RideID)
+	assert.Equal(t, driverID, saved.DriverID)
+	assert.Equal(t, []float64{90.4120, 23.8100}, saved.Location.Coordinates)
+	assert.WithinDuration(t, time.Now(), saved.RecordedAt, time.Second)
+	locationRepo.AssertExpectations(t)
+}
+
+func TestDriverService_UpdateLocation_NoRouteBeforeStart(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	locationRepo := new(MockLocationRepository)
+	service := newTestLocationUpdateService(rideRepo, locationRepo)
+
+	ctx := context.Background()
+	driverID := int64(456)
+	ride := &domain.Ride{ID: 1, CustomerID: 123, DriverID: &driverID, Status: domain.RideStatusAccepted}
+
+	locationRepo.On("UpdateDriverLocation", ctx, driverID, 23.8100, 90.4120).Return(nil)
+	rideRepo.On("GetActiveRideByDriverID", ctx, driverID).Return(ride, nil)
+
+	err := service.UpdateLocation(ctx, driverID, 23.8100, 90.4120)
+
+	assert.NoError(t, err)
+	locationRepo.AssertNotCalled(t, "SaveRideLocation", mock.Anything, mock.Anything)
+}
+
+func TestDriverService_UpdateLocation_NoActiveRide(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	locationRepo := new(MockLocationRepository)
+	service := newTestLocationUpdateService(rideRepo, locationRepo)
+
+	ctx := context.Background()
+	driverID := int64(456)
+
+	locationRepo.On("UpdateDriverLocation", ctx, driverID, 23.8100, 90.4120).Return(nil)
+	rideRepo.On("GetActiveRideByDriverID", ctx, driverID).Return(nil, repository.ErrRideNotFound)
+
+	err := service.UpdateLocation(ctx, driverID, 23.8100, 90.4120)
+
+	assert.NoError(t, err)
+	locationRepo.AssertNotCalled(t, "SaveRideLocation", mock.Anything, mock.Anything)
+}
+
+func TestDriverService_UpdateLocation_KeepsDriverOnline(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	locationRepo := new(MockLocationRepository)
+	driverRepo := new(MockDriverRepository)
+	service := newTestLocationUpdateService(rideRepo, locationRepo)
+	service.driverRepo = driverRepo
+
+	clock := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	now := func() time.Time { return clock }
+	onlineRepo := newFakeOnlineStatusRepository(now)
+	service.onlineStatusRepo = onlineRepo
+	service.now = now
+
+	ctx := context.Background()
+	pinging, idle, offline := int64(456), int64(457), int64(458)
+	require.NoError(t, onlineRepo.UpsertOnlineDriver(ctx, pinging, 23.8100, 90.4120))
+	require.NoError(t, onlineRepo.UpsertOnlineDriver(ctx, idle, 23.8100, 90.4120))
+
+	locationRepo.On("UpdateDriverLocation", ctx, mock.Anything, 23.8100, 90.4120).Return(nil)
+	rideRepo.On("GetActiveRideByDriverID", ctx, mock.Anything).Return(nil, repository.ErrRideNotFound)
+	driverRepo.On("UpdatePing", ctx, pinging, 23.8100, 90.4120, mock.AnythingOfType("time.Time")).Return(nil)
+
+	for i := 0; i < 3; i++ {
+		clock = clock.Add(time.Minute)
+		require.NoError(t, service.UpdateLocation(ctx, pinging, 23.8100, 90.4120))
+		require.NoError(t, service.UpdateLocation(ctx, offline, 23.8100, 90.4120))
+	}
+
+	online, err := service.IsDriverOnline(ctx, pinging)
+	require.NoError(t, err)
+	assert.True(t, online, "A driver sending pings stays online past the cutoff")
+
+	online, err = service.IsDriverOnline(ctx, idle)
+	require.NoError(t, err)
+	assert.False(t, online, "A driver without pings goes offline after the cutoff")
+
+	online, err = service.IsDriverOnline(ctx, offline)
+	require.NoError(t, err)
+	assert.False(t, online, "Pings do not bring an offline driver online")
+
+	driverRepo.AssertNumberOfCalls(t, "UpdatePing", 3)
+	driverRepo.AssertNotCalled(t, "UpdatePing", ctx, offline, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDriverService_UpdateLocationBatch_KeepsDriverOnline(t *testing.T) {
+	locationRepo := new(MockLocationRepository)
+	driverRepo := new(MockDriverRepository)
+	service := newTestDriverService(nil, locationRepo)
+	service.driverRepo = driverRepo
+
+	clock := time.Now()
+	now := func() time.Time { return clock }
+	onlineRepo := newFakeOnlineStatusRepository(now)
+	service.onlineStatusRepo = onlineRepo
+	service.now = now
+
+	ctx := context.Background()
+	driverID := int64(456)
+	require.NoError(t, onlineRepo.UpsertOnlineDriver(ctx, driverID, 23.8100, 90.4120))
+
+	points := []LocationPoint{
+		{Lat: 23.8100, Lng: 90.4120, Timestamp: clock.Add(-time.Minute)},
+		{Lat: 23.8105, Lng: 90.4125, Timestamp: clock.Add(-30 * time.Second)},
+	}
+	locationRepo.On("UpdateDriverLocationBatch", ctx, driverID, mock.Anything).Return(nil)
+	driverRepo.On("UpdatePing", ctx, driverID, 23.8105, 90.4125, mock.AnythingOfType("time.Time")).Return(nil)
+
+	clock = clock.Add(90 * time.Second)
+	_, err := service.UpdateLocationBatch(ctx, driverID, points)
+	require.NoError(t, err)
+
+	clock = clock.Add(90 * time.Second)
+	online, err := service.IsDriverOnline(ctx, driverID)
+	require.NoError(t, err)
+	assert.True(t, online)
+	driverRepo.AssertExpectations(t)
+}
+
+func TestDriverService_UpdateProfile(t *testing.T) {
+	driverRepo := new(MockDriverRepository)
+	service := &DriverService{driverRepo: driverRepo}
+
+	ctx := context.Background()
+	driverID := int64(456)
+	driver := &domain.Driver{ID: driverID, Name: "Old Name", Phone: "01712345678", VehicleNo: "ABC-123", VehicleType: domain.RideTypeEconomy}
+
+	driverRepo.On("GetByID", ctx, driverID).Return(driver, nil)
+	driverRepo.On("Update", ctx, driver).Return(nil)
+
+	updated, err := service.UpdateProfile(ctx, driverID, "  New Name ", " dhaka metro-ga-12-3456 ")
+
+	require.NoError(t, err)
+	assert.Equal(t, "New Name", updated.Name)
+	assert.Equal(t, "DHAKA METRO-GA-12-3456", updated.VehicleNo)
+	assert.Equal(t, "01712345678", updated.Phone, "Phone should not change")
+	driverRepo.AssertExpectations(t)
+}
+
+func newTestCachingDriverService(driverRepo *MockDriverRepository) (*DriverService, *metrics.CacheMetrics) {
+	redisClient, _ := testutil.NewFakeRedis()
+	cacheMetrics := metrics.NewCacheMetrics(prometheus.NewRegistry())
+	return NewDriverService(driverRepo, nil, nil, nil, nil, nil, nil, nil, testJWTKeys, 24, redisClient, time.Minute, cacheMetrics, nil), cacheMetrics
+}
+
+func TestDriverService_GetByID_ServedFromCache(t *testing.T) {
+	driverRepo := new(MockDriverRepository)
+	service, cacheMetrics := newTestCachingDriverService(driverRepo)
+
+	ctx := context.Background()
+	driverID := int64(456)
+	driverRepo.On("GetByID", ctx, driverID).Return(&domain.Driver{ID: driverID, Name: "Test Driver", VehicleType: domain.RideTypeBike}, nil).Once()
+
+	first, err := service.GetByID(ctx, driverID)
+	require.NoError(t, err)
+	second, err := service.GetByID(ctx, driverID)
+	require.NoError(t, err)
+
+	assert.Equal(t, first, second)
+	driverRepo.AssertNumberOfCalls(t, "GetByID", 1)
+	assert.Equal(t, float64(1), promtestutil.ToFloat64(cacheMetrics.Misses.WithLabelValues(driverProfileCache)))
+	assert.Equal(t, float64(1), promtestutil.ToFloat64(cacheMetrics.Hits.WithLabelValues(driverProfileCache)))
+}
+
+func TestDriverService_GetByID_UpdateProfileInvalidatesCache(t *testing.T) {
+	driverRepo := new(MockDriverRepository)
+	service, _ := newTestCachingDriverService(driverRepo)
+
+	ctx := context.Background()
+	driverID := int64(456)
+	driverRepo.On("GetByID", ctx, driverID).Return(&domain.Driver{ID: driverID, Name: "Old Name", VehicleNo: "ABC-123"}, nil).Once()
+	driverRepo.On("GetByID", ctx, driverID).Return(&domain.Driver{ID: driverID, Name: "Old Name", VehicleNo: "ABC-123"}, nil).Once()
+	driverRepo.On("GetByID", ctx, driverID).Return(&domain.Driver{ID: driverID, Name: "New Name", VehicleNo: "ABC-123"}, nil).Once()
+	driverRepo.On("Update", ctx, mock.Anything).Return(nil)
+
+	_, err := service.GetByID(ctx, driverID)
+	require.NoError(t, err)
+
+	_, err = service.UpdateProfile(ctx, driverID, "New Name", "ABC-123")
+	require.NoError(t, err)
+
+	driver, err := service.GetByID(ctx, driverID)
+	require.NoError(t, err)
+	assert.Equal(t, "New Name", driver.Name)
+	driverRepo.AssertNumberOfCalls(t, "GetByID", 3)
+}
+
+func TestDriverService_UpdateProfile_Invalid(t *testing.T) {
+	driverRepo := new(MockDriverRepository)
+	service := &DriverService{driverRepo: driverRepo}
+
+	ctx := context.Background()
+
+	_, err := service.UpdateProfile(ctx, 456, "", "ABC-123")
+	assert.ErrorIs(t, err, domain.ErrInvalidDriverName)
+
+	_, err = service.UpdateProfile(ctx, 456, "   ", "ABC-123")
+	assert.ErrorIs(t, err, domain.ErrInvalidDriverName)
+
+	for _, vehicleNo := range []string{"", "AB", "ABC_123", "-ABC123", strings.Repeat("A", 33)} {
+		_, err = service.UpdateProfile(ctx, 456, "New Name", vehicleNo)
+		assert.ErrorIs(t, err, domain.ErrInvalidVehicleNo, vehicleNo)
+	}
+
+	driverRepo.AssertNotCalled(t, "GetByID", mock.Anything, mock.Anything)
+	driverRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+}
+
+func TestDriverService_UpdateProfile_NotFound(t *testing.T) {
+	driverRepo := new(MockDriverRepository)
+	service := &DriverService{driverRepo: driverRepo}
+
+	ctx := context.Background()
+	driverRepo.On("GetByID", ctx, int64(999)).Return(nil, postgres.ErrDriverNotFound)
+
+	_, err := service.UpdateProfile(ctx, 999, "New Name", "ABC-123")
+
+	assert.ErrorIs(t, err, ErrDriverNotFound)
+	driverRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+}
+
+func TestDriverService_GetProfile_WithoutLocation(t *testing.T) {
+	onlineRepo := new(MockOnlineStatusRepository)
+	locationRepo := new(MockLocationRepository)
+	driverRepo := new(MockDriverRepository)
+	service := newTestDriverService(onlineRepo, locationRepo)
+	service.driverRepo = driverRepo
+
+	ctx := context.Background()
+	driverID := int64(456)
+	driver := &domain.Driver{ID: driverID, Name: "Test Driver", Phone: "+8801700000000", VehicleNo: "ABC-123"}
+
+	driverRepo.On("GetByID", ctx, driverID).Return(driver, nil)
+	onlineRepo.On("IsDriverOnline", ctx, driverID).Return(false, nil)
+	locationRepo.On("GetDriverLocation", ctx, driverID).Return(0.0, 0.0, (*time.Time)(nil), errors.New("driver location not found"))
+
+	profile, err := service.GetProfile(ctx, driverID)
+
+	require.NoError(t, err, "A driver who never sent a location still has a profile")
+	assert.False(t, profile.IsOnline)
+	assert.Nil(t, profile.CurrentLat)
+	assert.Nil(t, profile.LastPingAt)
+}
+
+func TestDriverService_GetProfile_NotFound(t *testing.T) {
+	onlineRepo := new(MockOnlineStatusRepository)
+	driverRepo := new(MockDriverRepository)
+	service := newTestDriverService(onlineRepo, nil)
+	service.driverRepo = driverRepo
+
+	ctx := context.Background()
+	driverRepo.On("GetByID", ctx, int64(999)).Return(nil, postgres.ErrDriverNotFound)
+
+	_, err := service.GetProfile(ctx, 999)
+
+	assert.ErrorIs(t, err, ErrDriverNotFound)
+	onlineRepo.AssertNotCalled(t, "IsDriverOnline", mock.Anything, mock.Anything)
+}
+
+func TestDriverService_GetOnlineDriversNear(t *testing.T) {
+	onlineRepo := new(MockOnlineStatusRepository)
+	locationRepo := new(MockLocationRepository)
+	driverRepo := new(MockDriverRepository)
+	service := newTestDriverService(onlineRepo, locationRepo)
+	service.driverRepo = driverRepo
+
+	ctx := context.Background()
+	pingedAt := time.Now().Add(-30 * time.Second)
+	location := func(driverID int64, lat, lng, distance float64) repository.NearbyDriverLocation {
+		return repository.NearbyDriverLocation{
+			DriverLocation: repository.DriverLocation{
+				DriverID:  driverID,
+				Location:  repository.GeoJSON{Type: "Point", Coordinates: []float64{lng, lat}},
+				UpdatedAt: pingedAt,
+			},
+			DistanceMeters: distance,
+		}
+	}
+
+	// Locations older than the freshness window are left out by the repository
+	fresh := mock.MatchedBy(func(since time.Time) bool {
+		return time.Since(since) >= driverLocationFreshness && time.Since(since) < driverLocationFreshness+time.Minute
+	})
+	locationRepo.On("FindNearbyDrivers", ctx, 23.8100, 90.4120, 3000.0, fresh, onlineDriverSearchLimit).Return([]repository.NearbyDriverLocation{
+		location(456, 23.8105, 90.4123, 63),
+		location(789, 23.8110, 90.4130, 150),
+		location(111, 23.8200, 90.4150, 1150),
+	}, nil)
+	// 789 still sends pings but went offline
+	onlineRepo.On("GetOnlineDriversByIDs", ctx, []int64{456, 789, 111}).Return([]int64{111, 456}, nil)
+	driverRepo.On("GetByID", ctx, int64(456)).Return(&domain.Driver{ID: 456, Name: "Rahim"}, nil)
+	driverRepo.On("GetByID", ctx, int64(111)).Return(&domain.Driver{ID: 111, Name: "Karim"}, nil)
+
+	drivers, err := service.GetOnlineDriversNear(ctx, 23.8100, 90.4120, 3000)
+
+	require.NoError(t, err)
+	require.Len(t, drivers, 2)
+	assert.Equal(t, "Rahim", drivers[0].Driver.Name)
+	assert.Equal(t, 23.8105, drivers[0].Lat)
+	assert.Equal(t, 90.4123, drivers[0].Lng)
+	assert.Equal(t, 63.0, drivers[0].DistanceMeters)
+	assert.True(t, pingedAt.Equal(drivers[0].LastPingAt))
+	assert.Equal(t, int64(111), drivers[1].Driver.ID)
+	driverRepo.AssertNotCalled(t, "GetByID", ctx, int64(789))
+}
+
+func TestDriverService_GetOnlineDriversNear_NoDrivers(t *testing.T) {
+	onlineRepo := new(MockOnlineStatusRepository)
+	locationRepo := new(MockLocationRepository)
+	service := newTestDriverService(onlineRepo, locationRepo)
+
+	ctx := context.Background()
+	locationRepo.On("FindNearbyDrivers", ctx, 23.8100, 90.4120, 3000.0, mock.Anything, onlineDriverSearchLimit).Return(nil, nil)
+
+	drivers, err := service.GetOnlineDriversNear(ctx, 23.8100, 90.4120, 3000)
+
+	require.NoError(t, err)
+	assert.NotNil(t, drivers, "An empty list should be returned rather than null")
+	assert.Empty(t, drivers)
+	onlineRepo.AssertNotCalled(t, "GetOnlineDriversByIDs", mock.Anything, mock.Anything)
+}
+
+func TestDriverService_GetCachedOnlineDriversCount(t *testing.T) {
+	onlineRepo := new(MockOnlineStatusRepository)
+	redisClient, fakeRedis := testutil.NewFakeRedis()
+	service := newTestDriverService(onlineRepo, new(MockLocationRepository))
+	service.redis = redisClient
+
+	ctx := context.Background()
+	onlineRepo.On("GetOnlineDriversCount", ctx).Return(int64(3), nil).Once()
+
+	count, err := service.GetCachedOnlineDriversCount(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+
+	// Within the TTL the cached count is served even though drivers went online
+	onlineRepo.On("GetOnlineDriversCount", ctx).Return(int64(5), nil).Once()
+	count, err = service.GetCachedOnlineDriversCount(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+	onlineRepo.AssertNumberOfCalls(t, "GetOnlineDriversCount", 1)
+
+	// Once it expires the count is recomputed
+	fakeRedis.Expire(utils.OnlineDriversCountKey, 0)
+	count, err = service.GetCachedOnlineDriversCount(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), count)
+	onlineRepo.AssertNumberOfCalls(t, "GetOnlineDriversCount", 2)
+}
+
+func TestDriverService_GetCachedOnlineDriversCount_Error(t *testing.T) {
+	onlineRepo := new(MockOnlineStatusRepository)
+	redisClient, _ := testutil.NewFakeRedis()
+	service := newTestDriverService(onlineRepo, new(MockLocationRepository))
+	service.redis = redisClient
+
+	ctx := context.Background()
+	onlineRepo.On("GetOnlineDriversCount", ctx).Return(int64(0), errors.New("database error"))
+
+	_, err := service.GetCachedOnlineDriversCount(ctx)
+	assert.Error(t, err)
+
+	exists, err := redisClient.Exists(ctx, utils.OnlineDriversCountKey).Result()
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), exists, "A failed count must not be cached")
+}
+
+func TestDriverService_GetNearestDriversWithDistance_Defaults(t *testing.T) {
+	locationRepo := new(MockLocationRepository)
+	rideRepo := new(MockRideRepository)
+	service := newTestDriverService(new(MockOnlineStatusRepository), locationRepo)
+	service.rideRepo = rideRepo
+	service.locationService = NewLocationService(locationRepo, config.LocationConfig{})
+
+	ctx := context.Background()
+	now := time.Now()
+	nearest := []repository.NearestDriver{
+		{DriverID: 456, DistanceMeters: 63.2, UpdatedAt: now},
+		{DriverID: 333, DistanceMeters: 498.7, UpdatedAt: now.Add(-time.Minute)},
+		{DriverID: 789, DistanceMeters: 1152.4, UpdatedAt: now.Add(-30 * time.Second)},
+	}
+	locationRepo.On("FindNearestDriversWithDistance", ctx, 23.8100, 90.4120, 3000.0, 5).Return(nearest, nil)
+	rideRepo.On("GetBusyDriverIDs", ctx, []int64{456, 333, 789}).Return([]int64{}, nil)
+
+	drivers, err := service.GetNearestDriversWithDistance(ctx, 23.8100, 90.4120, 0, 0)
+
+	require.NoError(t, err)
+	require.Len(t, drivers, 3)
+	for i := 1; i < len(drivers); i++ {
+		assert.LessOrEqual(t, drivers[i-1].DistanceMeters, drivers[i].DistanceMeters, "Drivers should be in ascending distance order")
+	}
+	assert.Equal(t, nearest[1].UpdatedAt, drivers[1].UpdatedAt)
+	locationRepo.AssertExpectations(t)
+}
+
+func TestDriverService_GetNearestDrivers_ConfiguredDefaults(t *testing.T) {
+	locationRepo := new(MockLocationRepository)
+	rideRepo := new(MockRideRepository)
+	service := newTestDriverService(new(MockOnlineStatusRepository), locationRepo)
+	service.rideRepo = rideRepo
+	service.locationService = NewLocationService(locationRepo, config.LocationConfig{DriverSearchRadiusMeters: 2000, DriverSearchLimit: 8})
+
+	ctx := context.Background()
+	locationRepo.On("FindNearestDrivers", ctx, 23.8100, 90.4120, 2000.0, 8).Return([]int64{456}, nil)
+	locationRepo.On("FindNearestDrivers", ctx, 23.8100, 90.4120, 4500.0, 3).Return([]int64{456, 789}, nil)
+	rideRepo.On("GetBusyDriverIDs", ctx, mock.Anything).Return([]int64{}, nil)
+
+	// Zero radius and limit fall back to the configured defaults
+	drivers, err := service.GetNearestDrivers(ctx, 23.8100, 90.4120, 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{456}, drivers)
+
+	// Explicit values are used as is
+	drivers, err = service.GetNearestDrivers(ctx, 23.8100, 90.4120, 4500, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{456, 789}, drivers)
+
+	locationRepo.AssertExpectations(t)
+}
+
+func TestDriverService_GetNearestDrivers_ExcludesBusyDrivers(t *testing.T) {
+	locationRepo := new(MockLocationRepository)
+	rideRepo := new(MockRideRepository)
+	service := newTestDriverService(new(MockOnlineStatusRepository), locationRepo)
+	service.locationService = NewLocationService(locationRepo, config.LocationConfig{})
+	service.rideRepo = rideRepo
+
+	ctx := context.Background()
+	locationRepo.On("FindNearestDrivers", ctx, 23.8100, 90.4120, 3000.0, 5).Return([]int64{456, 333, 789}, nil)
+	rideRepo.On("GetBusyDriverIDs", ctx, []int64{456, 333, 789}).Return([]int64{333}, nil)
+
+	drivers, err := service.GetNearestDrivers(ctx, 23.8100, 90.4120, 0, 0)
+
+	require.NoError(t, err)
+	assert.Equal(t, []int64{456, 789}, drivers, "A driver on a ride is not offered, the rest keep their order")
+	rideRepo.AssertExpectations(t)
+}
+
+func TestDriverService_GetNearestDriversWithDistance_ExcludesBusyDrivers(t *testing.T) {
+	locationRepo := new(MockLocationRepository)
+	rideRepo := new(MockRideRepository)
+	service := newTestDriverService(new(MockOnlineStatusRepository), locationRepo)
+	service.locationService = NewLocationService(locationRepo, config.LocationConfig{})
+	service.rideRepo = rideRepo
+
+	ctx := context.Background()
+	nearest := []repository.NearestDriver{
+		{DriverID: 456, DistanceMeters: 63.2},
+		{DriverID: 333, DistanceMeters: 498.7},
+	}
+	locationRepo.On("FindNearestDriversWithDistance", ctx, 23.8100, 90.4120, 3000.0, 5).Return(nearest, nil)
+	rideRepo.On("GetBusyDriverIDs", ctx, []int64{456, 333}).Return([]int64{456}, nil)
+
+	drivers, err := service.GetNearestDriversWithDistance(ctx, 23.8100, 90.4120, 0, 0)
+
+	require.NoError(t, err)
+	assert.Equal(t, []repository.NearestDriver{nearest[1]}, drivers)
+}
+
+func TestDriverService_GetNearestDrivers_BusyCheckFails(t *testing.T) {
+	locationRepo := new(MockLocationRepository)
+	rideRepo := new(MockRideRepository)
+	service := newTestDriverService(new(MockOnlineStatusRepository), locationRepo)
+	service.locationService = NewLocationService(locationRepo, config.LocationConfig{})
+	service.rideRepo = rideRepo
+
+	ctx := context.Background()
+	locationRepo.On("FindNearestDrivers", ctx, 23.8100, 90.4120, 3000.0, 5).Return([]int64{456}, nil)
+	rideRepo.On("GetBusyDriverIDs", ctx, []int64{456}).Return(nil, errors.New("database error"))
+
+	_, err := service.GetNearestDrivers(ctx, 23.8100, 90.4120, 0, 0)
+
+	assert.Error(t, err)
+}
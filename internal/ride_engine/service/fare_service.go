@@ -0,0 +1,199 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"vcs.technonext.com/carrybee/ride_engine/pkg/logger"
+
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/repository"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/config"
+)
+
+// DefaultFareCurrency is the ISO 4217 code fares are charged in when none is configured
+const DefaultFareCurrency = "BDT"
+
+// fareEstimateRouteFactor is how much longer than the straight line the driven route is expected to be at most
+const fareEstimateRouteFactor = 1.3
+
+type FareService struct {
+	baseFare        float64
+	perKmRate       float64
+	perMinuteRate   float64
+	waitGracePeriod time.Duration
+	waitRate        float64 // per minute waited beyond the grace period
+	cancelWindow    time.Duration
+	commissionRate  float64 // share of the fare kept by the platform
+	currency        string
+	minorUnits      int // decimals fares are rounded to
+	locationService *LocationService
+}
+
+func NewFareService(cfg config.FareConfig, locationService *LocationService) *FareService {
+	currency := strings.ToUpper(strings.TrimSpace(cfg.Currency))
+	if currency == "" {
+		currency = DefaultFareCurrency
+	}
+	return &FareService{
+		baseFare:        cfg.BaseFare,
+		perKmRate:       cfg.PerKmRate,
+		perMinuteRate:   cfg.PerMinuteRate,
+		waitGracePeriod: cfg.WaitGracePeriod,
+		waitRate:        cfg.WaitPerMinuteRate,
+		cancelWindow:    cfg.CancellationWindow,
+		commissionRate:  cfg.CommissionRate,
+		currency:        currency,
+		minorUnits:      minorUnits(currency),
+		locationService: locationService,
+	}
+}
+
+// Currency returns the ISO 4217 code fares are charged in
+func (s *FareService) Currency() string {
+	return s.currency
+}
+
+// FormatFare renders a fare with its currency code at the currency's precision, e.g. "BDT 170.50"
+func (s *FareService) FormatFare(fare float64) string {
+	return fmt.Sprintf("%s %.*f", s.currency, s.minorUnits, s.roundFare(fare))
+}
+
+// CalculateFare computes the fare for a distance in meters and a duration in minutes
+func (s *FareService) CalculateFare(distanceMeters, durationMinutes float64) float64 {
+	fare := s.baseFare + (distanceMeters/1000)*s.perKmRate + durationMinutes*s.perMinuteRate
+	return s.roundFare(fare)
+}
+
+// EstimateFare computes the fare estimate from the straight-line distance of the planned route and the ride's surge
+func (s *FareService) EstimateFare(ride *domain.Ride) float64 {
+	return s.roundFare(s.CalculateFare(plannedDistance(ride), 0) * surgeFactor(ride))
+}
+
+// EstimateFareRange quotes the fare range for a straight-line distance in meters and a duration in minutes at the given surge
+// The low end prices the straight line, the high end allows for a driven route fareEstimateRouteFactor times longer
+func (s *FareService) EstimateFareRange(distanceMeters, durationMinutes, surge float64) (minFare, maxFare float64) {
+	if surge <= 0 {
+		surge = NoSurge
+	}
+	minFare = s.roundFare(s.CalculateFare(distanceMeters, durationMinutes) * surge)
+	maxFare = s.roundFare(s.CalculateFare(distanceMeters*fareEstimateRouteFactor, durationMinutes*fareEstimateRouteFactor) * surge)
+	return minFare, maxFare
+}
+
+// FinalizeFare computes the final fare using the distance travelled during the ride and the surge it was requested at
+// Falls back to the straight-line distance of the planned route when no path was recorded
+// The wait fee is added on top and is not surged
+func (s *FareService) FinalizeFare(ctx context.Context, ride *domain.Ride) float64 {
+	distance := plannedDistance(ride)
+
+	points, err := s.locationService.GetRideLocationHistory(ctx, ride.ID)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to get location history for ride %d: %v", ride.ID, err))
+	} else if len(points) >= 2 {
+		distance = travelledDistance(points)
+	}
+
+	var durationMinutes float64
+	if ride.StartedAt != nil && ride.CompletedAt != nil {
+		durationMinutes = ride.CompletedAt.Sub(*ride.StartedAt).Minutes()
+	}
+
+	return s.roundFare(s.CalculateFare(distance, durationMinutes)*surgeFactor(ride) + s.WaitFee(ride))
+}
+
+// WaitFee returns the charge for the time the driver waited at the pickup beyond the grace period
+// Rides started without the driver marking arrived have no wait fee
+func (s *FareService) WaitFee(ride *domain.Ride) float64 {
+	if ride.ArrivedAt == nil || ride.StartedAt == nil {
+		return 0
+	}
+	billable := ride.StartedAt.Sub(*ride.ArrivedAt) - s.waitGracePeriod
+	if billable <= 0 {
+		return 0
+	}
+	return s.roundFare(billable.Minutes() * s.waitRate)
+}
+
+// CancellationFee returns the fee charged when a ride is cancelled at the given time
+// Rides still waiting for a driver are free within the cancellation window after being requested,
+// otherwise the base fare is charged
+func (s *FareService) CancellationFee(ride *domain.Ride, at time.Time) *float64 {
+	if ride.Status.IsAwaitingDriver() && at.Sub(ride.RequestedAt) < s.cancelWindow {
+		return nil
+	}
+	fee := s.roundFare(s.baseFare)
+	return &fee
+}
+
+// CommissionRate returns the share of the fare the platform currently keeps, rides record it on completion
+func (s *FareService) CommissionRate() float64 {
+	return s.commissionRate
+}
+
+// Commission returns the platform's cut of a fare at the given rate
+func (s *FareService) Commission(fare, rate float64) float64 {
+	return s.roundFare(fare * rate)
+}
+
+// plannedDistance sums the straight-line legs from pickup through each waypoint to dropoff
+func plannedDistance(ride *domain.Ride) float64 {
+	route := ride.Route()
+	var total float64
+	for i := 1; i < len(route); i++ {
+		total += route[i-1].DistanceTo(route[i])
+	}
+	return total
+}
+
+// travelledDistance sums the leg distances between consecutive recorded points
+func travelledDistance(points []repository.RideLocation) float64 {
+	var total float64
+	for i := 1; i < len(points); i++ {
+		prev := points[i-1].Location.Coordinates
+		curr := points[i].Location.Coordinates
+		if len(prev) < 2 || len(curr) < 2 {
+			continue
+		}
+		from := domain.Location{Latitude: prev[1], Longitude: prev[0]}
+		to := domain.Location{Latitude: curr[1], Longitude: curr[0]}
+		total += from.DistanceTo(to)
+	}
+	return total
+}
+
+// surgeFactor returns the ride's surge multiplier, rides requested before surge pricing have none
+func surgeFactor(ride *domain.Ride) float64 {
+	if ride.SurgeMultiplier <= 0 {
+		return NoSurge
+	}
+	return ride.SurgeMultiplier
+}
+
+// roundFare rounds half up to the minor unit of the fare currency
+func (s *FareService) roundFare(fare float64) float64 {
+	scale := math.Pow10(s.minorUnits)
+	// Trim floating point noise first so e.g. 1.005 counts as exactly half a minor unit
+	scaled := math.Round(fare*scale*1e6) / 1e6
+	return math.Floor(scaled+0.5) / scale
+}
+
+// currencyMinorUnits lists the currencies whose minor unit is not a hundredth, by ISO 4217 code
+var currencyMinorUnits = map[string]int{
+	"JPY": 0,
+	"KRW": 0,
+	"BHD": 3,
+	"KWD": 3,
+	"OMR": 3,
+}
+
+// minorUnits returns the number of decimals amounts in currency are rounded to
+func minorUnits(currency string) int {
+	if n, ok := currencyMinorUnits[currency]; ok {
+		return n
+	}
+	return 2
+}
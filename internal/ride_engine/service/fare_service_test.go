@@ -0,0 +1,296 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/repository"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/config"
+)
+
+func newTestFareService(mockRepo *MockLocationRepository) *FareService {
+	return NewFareService(config.FareConfig{
+		BaseFare:           50,
+		PerKmRate:          20,
+		PerMinuteRate:      2,
+		WaitGracePeriod:    3 * time.Minute,
+		WaitPerMinuteRate:  3,
+		CancellationWindow: 2 * time.Minute,
+		CommissionRate:     0.2,
+	}, &LocationService{repo: mockRepo})
+}
+
+func rideLocationPoint(rideID int64, lat, lng float64, recordedAt time.Time) repository.RideLocation {
+	return repository.RideLocation{
+		RideID: rideID,
+		Location: repository.GeoJSON{
+			Type:        "Point",
+			Coordinates: []float64{lng, lat},
+		},
+		RecordedAt: recordedAt,
+	}
+}
+
+func TestFareService_CalculateFare(t *testing.T) {
+	service := newTestFareService(new(MockLocationRepository))
+
+	// 50 base + 5km * 20 + 10min * 2
+	assert.Equal(t, 170.0, service.CalculateFare(5000, 10))
+	assert.Equal(t, 50.0, service.CalculateFare(0, 0))
+}
+
+func TestFareService_EstimateFare(t *testing.T) {
+	service := newTestFareService(new(MockLocationRepository))
+
+	ride := &domain.Ride{
+		PickupLat:  23.8100,
+		PickupLng:  90.4120,
+		DropoffLat: 23.7509,
+		DropoffLng: 90.3761,
+	}
+
+	fare := service.EstimateFare(ride)
+
+	// ~7.5km straight-line distance
+	assert.InDelta(t, 50+7.5*20, fare, 5)
+}
+
+func TestFareService_EstimateFare_WithWaypoints(t *testing.T) {
+	service := newTestFareService(new(MockLocationRepository))
+
+	pickup := domain.Location{Latitude: 23.8100, Longitude: 90.4120}
+	first := domain.Location{Latitude: 23.7806, Longitude: 90.4193}
+	second := domain.Location{Latitude: 23.7461, Longitude: 90.3742}
+	dropoff := domain.Location{Latitude: 23.7509, Longitude: 90.3761}
+
+	ride := &domain.Ride{
+		PickupLat:  pickup.Latitude,
+		PickupLng:  pickup.Longitude,
+		DropoffLat: dropoff.Latitude,
+		DropoffLng: dropoff.Longitude,
+		Waypoints:  []domain.Location{first, second},
+	}
+
+	legs := pickup.DistanceTo(first) + first.DistanceTo(second) + second.DistanceTo(dropoff)
+
+	assert.InDelta(t, legs, plannedDistance(ride), 0.001, "Distance should be the sum of the legs")
+	assert.Greater(t, plannedDistance(ride), pickup.DistanceTo(dropoff))
+	assert.Equal(t, service.CalculateFare(legs, 0), service.EstimateFare(ride))
+}
+
+func TestFareService_FinalizeFare_UsesTravelledDistance(t *testing.T) {
+	mockRepo := new(MockLocationRepository)
+	service := newTestFareService(mockRepo)
+
+	ctx := context.Background()
+	startedAt := time.Now().Add(-20 * time.Minute)
+	completedAt := startedAt.Add(20 * time.Minute)
+	ride := &domain.Ride{
+		ID:          1,
+		PickupLat:   23.8100,
+		PickupLng:   90.4120,
+		DropoffLat:  23.8100,
+		DropoffLng:  90.4120,
+		StartedAt:   &startedAt,
+		CompletedAt: &completedAt,
+	}
+
+	points := []repository.RideLocation{
+		rideLocationPoint(1, 23.8100, 90.4120, startedAt),
+		rideLocationPoint(1, 23.8190, 90.4120, startedAt.Add(5*time.Minute)),
+		rideLocationPoint(1, 23.8100, 90.4120, startedAt.Add(10*time.Minute)),
+	}
+	mockRepo.On("GetRideLocationHistory", ctx, int64(1)).Return(points, nil)
+
+	fare := service.FinalizeFare(ctx, ride)
+
+	// ~2km round trip + 20 minutes, although pickup equals dropoff
+	assert.InDelta(t, 50+2*20+20*2, fare, 1)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestFareService_FinalizeFare_FallsBackToStraightLine(t *testing.T) {
+	mockRepo := new(MockLocationRepository)
+	service := newTestFareService(mockRepo)
+
+	ctx := context.Background()
+	ride := &domain.Ride{
+		ID:         2,
+		PickupLat:  23.8100,
+		PickupLng:  90.4120,
+		DropoffLat: 23.7509,
+		DropoffLng: 90.3761,
+	}
+
+	mockRepo.On("GetRideLocationHistory", ctx, int64(2)).Return(nil, errors.New("query error"))
+
+	assert.Equal(t, service.EstimateFare(ride), service.FinalizeFare(ctx, ride))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestFareService_FinalizeFare_AppliesSurge(t *testing.T) {
+	mockRepo := new(MockLocationRepository)
+	service := newTestFareService(mockRepo)
+
+	ctx := context.Background()
+	startedAt := time.Now().Add(-10 * time.Minute)
+	completedAt := startedAt.Add(10 * time.Minute)
+	ride := &domain.Ride{
+		ID:              3,
+		PickupLat:       23.8100,
+		PickupLng:       90.4120,
+		DropoffLat:      23.7509,
+		DropoffLng:      90.3761,
+		SurgeMultiplier: 1.5,
+		StartedAt:       &startedAt,
+		CompletedAt:     &completedAt,
+	}
+	mockRepo.On("GetRideLocationHistory", ctx, int64(3)).Return(nil, nil)
+
+	fare := service.FinalizeFare(ctx, ride)
+
+	flatFare := service.CalculateFare(plannedDistance(ride), 10)
+	assert.InDelta(t, flatFare*1.5, fare, 0.01)
+}
+
+func TestFareService_CancellationFee(t *testing.T) {
+	service := newTestFareService(new(MockLocationRepository))
+
+	requestedAt := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	requested := &domain.Ride{Status: domain.RideStatusRequested, RequestedAt: requestedAt}
+	assert.Nil(t, service.CancellationFee(requested, requestedAt.Add(30*time.Second)), "Free within the window")
+
+	pending := &domain.Ride{Status: domain.RideStatusPending, RequestedAt: requestedAt}
+	assert.Nil(t, service.CancellationFee(pending, requestedAt.Add(time.Minute)), "Free while offered to a driver too")
+
+	late := service.CancellationFee(requested, requestedAt.Add(2*time.Minute))
+	require.NotNil(t, late, "Charged once the window is over")
+	assert.Equal(t, 50.0, *late)
+
+	driverID := int64(456)
+	acceptedAt := requestedAt.Add(20 * time.Second)
+	accepted := &domain.Ride{
+		Status:      domain.RideStatusAccepted,
+		DriverID:    &driverID,
+		RequestedAt: requestedAt,
+		AcceptedAt:  &acceptedAt,
+	}
+	fee := service.CancellationFee(accepted, requestedAt.Add(30*time.Second))
+	require.NotNil(t, fee, "Charged after a driver accepted even within the window")
+	assert.Equal(t, 50.0, *fee)
+}
+
+func TestFareService_WaitFee(t *testing.T) {
+	service := newTestFareService(new(MockLocationRepository))
+
+	arrivedAt := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		waited time.Duration
+		want   float64
+	}{
+		{"under grace", 2 * time.Minute, 0},
+		{"exactly grace", 3 * time.Minute, 0},
+		{"over grace", 8 * time.Minute, 15},                     // 5 minutes beyond grace * 3
+		{"partial minute", 4*time.Minute + 30*time.Second, 4.5}, // 1.5 minutes beyond grace * 3
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			startedAt := arrivedAt.Add(tt.waited)
+			ride := &domain.Ride{ArrivedAt: &arrivedAt, StartedAt: &startedAt}
+
+			assert.Equal(t, tt.want, service.WaitFee(ride))
+		})
+	}
+}
+
+func TestFareService_WaitFee_NotArrived(t *testing.T) {
+	service := newTestFareService(new(MockLocationRepository))
+
+	startedAt := time.Now()
+	ride := &domain.Ride{StartedAt: &startedAt}
+
+	assert.Equal(t, 0.0, service.WaitFee(ride), "Rides started without arriving have no wait fee")
+}
+
+func TestFareService_FinalizeFare_AddsWaitFee(t *testing.T) {
+	mockRepo := new(MockLocationRepository)
+	service := newTestFareService(mockRepo)
+
+	ctx := context.Background()
+	arrivedAt := time.Now().Add(-30 * time.Minute)
+	startedAt := arrivedAt.Add(10 * time.Minute)
+	completedAt := startedAt.Add(10 * time.Minute)
+	ride := &domain.Ride{
+		ID:              4,
+		PickupLat:       23.8100,
+		PickupLng:       90.4120,
+		DropoffLat:      23.7509,
+		DropoffLng:      90.3761,
+		SurgeMultiplier: 2,
+		ArrivedAt:       &arrivedAt,
+		StartedAt:       &startedAt,
+		CompletedAt:     &completedAt,
+	}
+	mockRepo.On("GetRideLocationHistory", ctx, int64(4)).Return(nil, nil)
+
+	fare := service.FinalizeFare(ctx, ride)
+
+	// 7 minutes beyond grace * 3 is added after the surge
+	surged := service.CalculateFare(plannedDistance(ride), 10) * 2
+	assert.InDelta(t, surged+21, fare, 0.01)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestFareService_EstimateFareRange(t *testing.T) {
+	service := newTestFareService(new(MockLocationRepository))
+
+	minFare, maxFare := service.EstimateFareRange(5000, 10, NoSurge)
+
+	// 50 base + 5km * 20 + 10min * 2, the high end allows for a 30% longer route
+	assert.Equal(t, 170.0, minFare)
+	assert.Equal(t, 50+6.5*20+13*2, maxFare)
+
+	surgedMin, surgedMax := service.EstimateFareRange(5000, 10, 2)
+	assert.Equal(t, 2*minFare, surgedMin)
+	assert.Equal(t, 2*maxFare, surgedMax)
+}
+
+func TestFareService_RoundFare_HalfUp(t *testing.T) {
+	service := newTestFareService(new(MockLocationRepository))
+
+	assert.Equal(t, 1.01, service.roundFare(1.005))
+	assert.Equal(t, 2.68, service.roundFare(2.675))
+	assert.Equal(t, 0.13, service.roundFare(0.125))
+	assert.Equal(t, 170.12, service.roundFare(170.124))
+	assert.Equal(t, 170.0, service.roundFare(170))
+}
+
+func TestFareService_RoundFare_CurrencyPrecision(t *testing.T) {
+	yen := NewFareService(config.FareConfig{Currency: "JPY"}, nil)
+	assert.Equal(t, 3.0, yen.roundFare(2.5))
+	assert.Equal(t, 2.0, yen.roundFare(2.49))
+
+	dinar := NewFareService(config.FareConfig{Currency: "KWD"}, nil)
+	assert.Equal(t, 1.235, dinar.roundFare(1.2345))
+}
+
+func TestFareService_FormatFare(t *testing.T) {
+	service := newTestFareService(new(MockLocationRepository))
+	assert.Equal(t, DefaultFareCurrency, service.Currency(), "Fares default to the default currency")
+	assert.Equal(t, "BDT 170.00", service.FormatFare(170))
+	assert.Equal(t, "BDT 1.01", service.FormatFare(1.005))
+
+	usd := NewFareService(config.FareConfig{Currency: " usd "}, nil)
+	assert.Equal(t, "USD", usd.Currency())
+	assert.Equal(t, "USD 12.50", usd.FormatFare(12.5))
+
+	yen := NewFareService(config.FareConfig{Currency: "JPY"}, nil)
+	assert.Equal(t, "JPY 1235", yen.FormatFare(1234.5))
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/repository"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/logger"
+)
+
+type GeofenceService struct {
+	repo repository.GeofenceRepository
+}
+
+func NewGeofenceService(repo repository.GeofenceRepository) *GeofenceService {
+	return &GeofenceService{repo: repo}
+}
+
+// Create stores a new service area
+func (s *GeofenceService) Create(ctx context.Context, name string, boundary []domain.Location, active bool) (*domain.Geofence, error) {
+	geofence := &domain.Geofence{
+		Name:      name,
+		Boundary:  boundary,
+		Active:    active,
+		CreatedAt: time.Now(),
+	}
+	if err := domain.ValidateGeofence(geofence); err != nil {
+		logger.Error(ctx, fmt.Sprintf("invalid geofence %q: %v", name, err))
+		return nil, err
+	}
+
+	if err := s.repo.Create(ctx, geofence); err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to create geofence %q: %v", name, err))
+		return nil, err
+	}
+
+	return geofence, nil
+}
+
+// Contains reports whether the point lies inside any active geofence
+func (s *GeofenceService) Contains(ctx context.Context, lat, lng float64) (bool, error) {
+	geofences, err := s.repo.GetActive(ctx)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to get active geofences: %v", err))
+		return false, err
+	}
+
+	for _, geofence := range geofences {
+		if geofence.Contains(lat, lng) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+)
+
+type MockGeofenceRepository struct {
+	mock.Mock
+}
+
+func (m *MockGeofenceRepository) Create(ctx context.Context, geofence *domain.Geofence) error {
+	args := m.Called(ctx, geofence)
+	return args.Error(0)
+}
+
+func (m *MockGeofenceRepository) GetActive(ctx context.Context) ([]*domain.Geofence, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Geofence), args.Error(1)
+}
+
+// testGeofence is a square around Gulshan, Dhaka
+func testGeofence() *domain.Geofence {
+	return &domain.Geofence{
+		Name: "Gulshan",
+		Boundary: []domain.Location{
+			{Latitude: 23.77, Longitude: 90.40},
+			{Latitude: 23.77, Longitude: 90.43},
+			{Latitude: 23.81, Longitude: 90.43},
+			{Latitude: 23.81, Longitude: 90.40},
+		},
+		Active: true,
+	}
+}
+
+func TestGeofenceService_Contains(t *testing.T) {
+	repo := new(MockGeofenceRepository)
+	service := NewGeofenceService(repo)
+
+	ctx := context.Background()
+	repo.On("GetActive", ctx).Return([]*domain.Geofence{testGeofence()}, nil)
+
+	inside, err := service.Contains(ctx, 23.7925, 90.4078)
+	require.NoError(t, err)
+	assert.True(t, inside)
+
+	inside, err = service.Contains(ctx, 23.7509, 90.3761)
+	require.NoError(t, err)
+	assert.False(t, inside)
+}
+
+func TestGeofenceService_Contains_NoActiveGeofences(t *testing.T) {
+	repo := new(MockGeofenceRepository)
+	service := NewGeofenceService(repo)
+
+	ctx := context.Background()
+	repo.On("GetActive", ctx).Return(nil, nil)
+
+	inside, err := service.Contains(ctx, 23.7925, 90.4078)
+	require.NoError(t, err)
+	assert.False(t, inside)
+}
+
+func TestGeofenceService_Create_InvalidBoundary(t *testing.T) {
+	repo := new(MockGeofenceRepository)
+	service := NewGeofenceService(repo)
+
+	ctx := context.Background()
+	_, err := service.Create(ctx, "Gulshan", testGeofence().Boundary[:2], true)
+
+	assert.ErrorIs(t, err, domain.ErrInvalidGeofenceBoundary)
+	repo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestRideService_RequestRide_OutsideServiceArea(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	geofenceRepo := new(MockGeofenceRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+	service.geofenceService = NewGeofenceService(geofenceRepo)
+
+	ctx := context.Background()
+	geofenceRepo.On("GetActive", ctx).Return([]*domain.Geofence{testGeofence()}, nil)
+
+	ride, err := service.RequestRide(ctx, 123, domain.RideTypeEconomy, 23.7509, 90.3761, 23.7925, 90.4078, nil, "", "")
+
+	assert.ErrorIs(t, err, ErrOutsideServiceArea)
+	assert.Nil(t, ride)
+	rideRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestRideService_RequestRide_InsideServiceArea(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	locationRepo := new(MockLocationRepository)
+	geofenceRepo := new(MockGeofenceRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), locationRepo)
+	service.geofenceService = NewGeofenceService(geofenceRepo)
+	expectNoSurge(rideRepo, locationRepo)
+	expectNoActiveRide(rideRepo)
+
+	ctx := context.Background()
+	geofenceRepo.On("GetActive", ctx).Return([]*domain.Geofence{testGeofence()}, nil)
+	rideRepo.On("Create", ctx, mock.AnythingOfType("*domain.Ride")).Return(nil)
+
+	// Only the pickup has to be inside, the dropoff is outside the area
+	_, err := service.RequestRide(ctx, 123, domain.RideTypeEconomy, 23.7925, 90.4078, 23.7509, 90.3761, nil, "", "")
+
+	require.NoError(t, err)
+	rideRepo.AssertExpectations(t)
+}
+
+func TestRideService_RequestRide_GeofenceLookupFails(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	geofenceRepo := new(MockGeofenceRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+	service.geofenceService = NewGeofenceService(geofenceRepo)
+
+	ctx := context.Background()
+	geofenceRepo.On("GetActive", ctx).Return(nil, errors.New("database error"))
+
+	_, err := service.RequestRide(ctx, 123, domain.RideTypeEconomy, 23.7925, 90.4078, 23.7509, 90.3761, nil, "", "")
+
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrOutsideServiceArea)
+	rideRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
@@ -2,30 +2,361 @@ package service
 
 import (
 	"context"
+	"math"
+	"sort"
 	"time"
 
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
 	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/repository"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/config"
+)
+
+// Heatmap grid limits, the grid size is in degrees
+const (
+	DefaultHeatmapGridSize = 0.01 // about 1.1 km
+	MaxHeatmapCells        = 10000
+)
+
+const (
+	MaxLocationBatchSize = 500            // points accepted in a single batch
+	maxLocationClockSkew = time.Minute    // points timestamped further in the future are dropped
+	maxLocationAge       = 24 * time.Hour // older points are dropped
+)
+
+var (
+	ErrEmptyLocationBatch    = domain.NewError(domain.ErrValidation, "location batch is empty")
+	ErrLocationBatchTooLarge = domain.NewError(domain.ErrValidation, "location batch is too large")
+	ErrNoValidLocations      = domain.NewError(domain.ErrValidation, "location batch has no valid points")
+	ErrInvalidHeatmapBounds  = domain.NewError(domain.ErrValidation, "heatmap bounds must be valid coordinates with min below max")
+	ErrInvalidHeatmapGrid    = domain.NewError(domain.ErrValidation, "grid size must be positive and split the bounds into at most 10000 cells")
+	ErrInvalidSearchRadius   = domain.NewError(domain.ErrValidation, "search radius must be a positive number of meters")
+)
+
+// LocationPoint is a location reported by a driver at a given time
+type LocationPoint struct {
+	Lat       float64
+	Lng       float64
+	Timestamp time.Time
+}
+
+// TrailPoint is one recorded location on a driver's trail
+type TrailPoint struct {
+	Lat        float64   `json:"lat"`
+	Lng        float64   `json:"lng"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+// LocationBatchResult reports how many points of a batch were stored
+type LocationBatchResult struct {
+	Accepted int           `json:"accepted"`
+	Dropped  int           `json:"dropped"`
+	Latest   LocationPoint `json:"-"` // the newest point stored, now the driver's current location
+}
+
+// Search defaults used when none are configured, radii are in meters
+const (
+	DefaultDriverSearchRadius = 3000.0
+	DefaultDriverSearchLimit  = 5
+	DefaultRideSearchRadius   = 10000.0
+	DefaultRideSearchLimit    = 50
+	MaxRideSearchLimit        = 100
 )
 
 type LocationService struct {
-	repo repository.LocationRepository
+	repo               repository.LocationRepository
+	maxSearchRadius    float64       // in meters, 0 means unlimited
+	movementThreshold  float64       // in meters, pings closer than this to the stored location are not written, 0 writes every ping
+	refreshInterval    time.Duration // a stored location older than this is rewritten even if the driver has not moved
+	driverSearchRadius float64       // in meters, used when a nearest driver search has none
+	driverSearchLimit  int           // used when a nearest driver search has none
+	rideSearchRadius   float64       // in meters, used when a nearby ride search has none
+	rideSearchLimit    int           // used when a nearby ride search has none
 }
 
-func NewLocationService(repo repository.LocationRepository) *LocationService {
-	return &LocationService{repo: repo}
+func NewLocationService(repo repository.LocationRepository, cfg config.LocationConfig) *LocationService {
+	s := &LocationService{
+		repo:               repo,
+		maxSearchRadius:    cfg.MaxSearchRadiusMeters,
+		movementThreshold:  cfg.MovementThresholdMeters,
+		refreshInterval:    cfg.RefreshInterval,
+		driverSearchRadius: cfg.DriverSearchRadiusMeters,
+		driverSearchLimit:  cfg.DriverSearchLimit,
+		rideSearchRadius:   cfg.RideSearchRadiusMeters,
+		rideSearchLimit:    cfg.RideSearchLimit,
+	}
+	if s.driverSearchRadius <= 0 {
+		s.driverSearchRadius = DefaultDriverSearchRadius
+	}
+	if s.driverSearchLimit <= 0 {
+		s.driverSearchLimit = DefaultDriverSearchLimit
+	}
+	if s.rideSearchRadius <= 0 {
+		s.rideSearchRadius = DefaultRideSearchRadius
+	}
+	if s.rideSearchLimit <= 0 {
+		s.rideSearchLimit = DefaultRideSearchLimit
+	}
+	return s
+}
+
+// DriverSearch fills in the configured default radius and limit of a nearest driver search when they are zero
+func (s *LocationService) DriverSearch(radius float64, limit int) (float64, int) {
+	if radius <= 0 {
+		radius = s.driverSearchRadius
+	}
+	if limit <= 0 {
+		limit = s.driverSearchLimit
+	}
+	return radius, limit
+}
+
+// RideSearch fills in the configured default radius and limit of a nearby ride search when they are zero
+// The limit is capped at MaxRideSearchLimit
+func (s *LocationService) RideSearch(radius float64, limit int) (float64, int) {
+	if radius <= 0 {
+		radius = s.rideSearchRadius
+	}
+	if limit <= 0 {
+		limit = s.rideSearchLimit
+	}
+	if limit > MaxRideSearchLimit {
+		limit = MaxRideSearchLimit
+	}
+	return radius, limit
+}
+
+// ClampSearchRadius caps a search radius in meters at the configured maximum
+func (s *LocationService) ClampSearchRadius(radius float64) float64 {
+	if s.maxSearchRadius > 0 && radius > s.maxSearchRadius {
+		return s.maxSearchRadius
+	}
+	return radius
 }
 
 // UpdateDriverLocation updates driver's current location
+// Pings within the movement threshold of the stored location are skipped until it is older than the refresh interval,
+// so a parked driver does not write on every ping but still shows up in nearby searches
 func (s *LocationService) UpdateDriverLocation(ctx context.Context, driverID int64, lat, lng float64) error {
+	if s.isUnmoved(ctx, driverID, lat, lng) {
+		return nil
+	}
 	return s.repo.UpdateDriverLocation(ctx, driverID, lat, lng)
 }
 
-// FindNearestDrivers finds drivers within maxDistance (in meters)
-func (s *LocationService) FindNearestDrivers(ctx context.Context, lat, lng float64, maxDistance float64, limit int) ([]int64, error) {
-	return s.repo.FindNearestDrivers(ctx, lat, lng, maxDistance, limit)
+// isUnmoved reports whether the driver's stored location is recent and within the movement threshold of lat, lng
+// A driver without a stored location has always moved
+func (s *LocationService) isUnmoved(ctx context.Context, driverID int64, lat, lng float64) bool {
+	if s.movementThreshold <= 0 {
+		return false
+	}
+
+	lastLat, lastLng, updatedAt, err := s.repo.GetDriverLocation(ctx, driverID)
+	if err != nil || updatedAt == nil || time.Since(*updatedAt) >= s.refreshInterval {
+		return false
+	}
+
+	last := domain.Location{Latitude: lastLat, Longitude: lastLng}
+	return last.DistanceTo(domain.Location{Latitude: lat, Longitude: lng}) < s.movementThreshold
+}
+
+// UpdateDriverLocationBatch stores locations buffered by the driver's device while offline
+// Points with invalid coordinates or implausible timestamps are dropped, the rest are stored
+// oldest first and the newest becomes the current location
+func (s *LocationService) UpdateDriverLocationBatch(ctx context.Context, driverID int64, points []LocationPoint) (*LocationBatchResult, error) {
+	if len(points) == 0 {
+		return nil, ErrEmptyLocationBatch
+	}
+	if len(points) > MaxLocationBatchSize {
+		return nil, ErrLocationBatchTooLarge
+	}
+
+	now := time.Now()
+	valid := make([]LocationPoint, 0, len(points))
+	for _, point := range points {
+		if !isPlausibleLocation(point, now) {
+			continue
+		}
+		valid = append(valid, point)
+	}
+
+	sort.SliceStable(valid, func(i, j int) bool {
+		return valid[i].Timestamp.Before(valid[j].Timestamp)
+	})
+
+	// A device can only be in one place at a time, keep the last reported point for a timestamp
+	deduped := valid[:0]
+	for i, point := range valid {
+		if i+1 < len(valid) && valid[i+1].Timestamp.Equal(point.Timestamp) {
+			continue
+		}
+		deduped = append(deduped, point)
+	}
+
+	if len(deduped) == 0 {
+		return nil, ErrNoValidLocations
+	}
+
+	history := make([]repository.DriverLocationPoint, len(deduped))
+	for i, point := range deduped {
+		history[i] = repository.DriverLocationPoint{
+			DriverID: driverID,
+			Location: repository.GeoJSON{
+				Type:        "Point",
+				Coordinates: []float64{point.Lng, point.Lat}, // MongoDB uses [longitude, latitude]
+			},
+			RecordedAt: point.Timestamp,
+		}
+	}
+
+	if err := s.repo.UpdateDriverLocationBatch(ctx, driverID, history); err != nil {
+		return nil, err
+	}
+
+	return &LocationBatchResult{
+		Accepted: len(deduped),
+		Dropped:  len(points) - len(deduped),
+		Latest:   deduped[len(deduped)-1],
+	}, nil
+}
+
+// isPlausibleLocation rejects out of range coordinates, the 0,0 fix reported by devices without a
+// GPS lock and timestamps that are missing, too old or in the future
+func isPlausibleLocation(point LocationPoint, now time.Time) bool {
+	location := domain.Location{Latitude: point.Lat, Longitude: point.Lng}
+	if location.Validate() != nil {
+		return false
+	}
+	if point.Lat == 0 && point.Lng == 0 {
+		return false
+	}
+	if point.Timestamp.IsZero() || point.Timestamp.After(now.Add(maxLocationClockSkew)) || point.Timestamp.Before(now.Add(-maxLocationAge)) {
+		return false
+	}
+	return true
+}
+
+// FindNearestDrivers finds drivers within maxDistanceMeters, nearest first
+func (s *LocationService) FindNearestDrivers(ctx context.Context, lat, lng float64, maxDistanceMeters float64, limit int) ([]int64, error) {
+	maxDistanceMeters, err := s.searchRadius(maxDistanceMeters)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.FindNearestDrivers(ctx, lat, lng, maxDistanceMeters, limit)
+}
+
+// FindNearestDriversWithDistance finds drivers within maxDistanceMeters, nearest first, with their distance
+func (s *LocationService) FindNearestDriversWithDistance(ctx context.Context, lat, lng float64, maxDistanceMeters float64, limit int) ([]repository.NearestDriver, error) {
+	maxDistanceMeters, err := s.searchRadius(maxDistanceMeters)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.FindNearestDriversWithDistance(ctx, lat, lng, maxDistanceMeters, limit)
+}
+
+// FindNearbyDrivers returns the drivers located within maxDistanceMeters since the given time, nearest first
+func (s *LocationService) FindNearbyDrivers(ctx context.Context, lat, lng float64, maxDistanceMeters float64, since time.Time, limit int) ([]repository.NearbyDriverLocation, error) {
+	maxDistanceMeters, err := s.searchRadius(maxDistanceMeters)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.FindNearbyDrivers(ctx, lat, lng, maxDistanceMeters, since, limit)
+}
+
+// searchRadius validates a search radius in meters and caps it at the configured maximum
+// Fractions of a meter are kept as is
+func (s *LocationService) searchRadius(maxDistanceMeters float64) (float64, error) {
+	if math.IsNaN(maxDistanceMeters) || math.IsInf(maxDistanceMeters, 0) || maxDistanceMeters <= 0 {
+		return 0, ErrInvalidSearchRadius
+	}
+	return s.ClampSearchRadius(maxDistanceMeters), nil
+}
+
+// GetDriverDensity counts the drivers with a fresh location in each gridSize degree cell of bounds
+func (s *LocationService) GetDriverDensity(ctx context.Context, bounds repository.GeoBounds, gridSize float64) ([]repository.DensityCell, error) {
+	if bounds.MinLat < -90 || bounds.MaxLat > 90 || bounds.MinLng < -180 || bounds.MaxLng > 180 ||
+		bounds.MinLat >= bounds.MaxLat || bounds.MinLng >= bounds.MaxLng {
+		return nil, ErrInvalidHeatmapBounds
+	}
+	if gridSize <= 0 {
+		return nil, ErrInvalidHeatmapGrid
+	}
+	rows := math.Ceil((bounds.MaxLat - bounds.MinLat) / gridSize)
+	cols := math.Ceil((bounds.MaxLng - bounds.MinLng) / gridSize)
+	if rows*cols > MaxHeatmapCells {
+		return nil, ErrInvalidHeatmapGrid
+	}
+
+	cells, err := s.repo.GetDriverDensity(ctx, bounds, gridSize, time.Now().Add(-driverLocationFreshness))
+	if err != nil {
+		return nil, err
+	}
+	if cells == nil {
+		cells = []repository.DensityCell{}
+	}
+	return cells, nil
 }
 
 // GetDriverLocation retrieves driver's current location from MongoDB
 func (s *LocationService) GetDriverLocation(ctx context.Context, driverID int64) (lat, lng float64, updatedAt *time.Time, err error) {
 	return s.repo.GetDriverLocation(ctx, driverID)
 }
+
+// GetDriverTrail retrieves the driver's locations recorded since the given time, oldest first
+func (s *LocationService) GetDriverTrail(ctx context.Context, driverID int64, since time.Time) ([]TrailPoint, error) {
+	points, err := s.repo.GetDriverLocationHistory(ctx, driverID, since)
+	if err != nil {
+		return nil, err
+	}
+
+	trail := make([]TrailPoint, 0, len(points))
+	for _, point := range points {
+		if len(point.Location.Coordinates) < 2 {
+			continue
+		}
+		trail = append(trail, TrailPoint{
+			Lat:        point.Location.Coordinates[1],
+			Lng:        point.Location.Coordinates[0],
+			RecordedAt: point.RecordedAt,
+		})
+	}
+	return trail, nil
+}
+
+// SaveRideLocation records the driver's location on the path of a started ride
+func (s *LocationService) SaveRideLocation(ctx context.Context, rideID, driverID int64, lat, lng float64) error {
+	return s.repo.SaveRideLocation(ctx, repository.RideLocation{
+		RideID:   rideID,
+		DriverID: driverID,
+		Location: repository.GeoJSON{
+			Type:        "Point",
+			Coordinates: []float64{lng, lat}, // MongoDB uses [longitude, latitude]
+		},
+		RecordedAt: time.Now(),
+	})
+}
+
+// GetRideRoute retrieves the path recorded for a ride, oldest point first
+func (s *LocationService) GetRideRoute(ctx context.Context, rideID int64) ([]TrailPoint, error) {
+	points, err := s.repo.GetRideLocationHistory(ctx, rideID)
+	if err != nil {
+		return nil, err
+	}
+
+	route := make([]TrailPoint, 0, len(points))
+	for _, point := range points {
+		if len(point.Location.Coordinates) < 2 {
+			continue
+		}
+		route = append(route, TrailPoint{
+			Lat:        point.Location.Coordinates[1],
+			Lng:        point.Location.Coordinates[0],
+			RecordedAt: point.RecordedAt,
+		})
+	}
+	return route, nil
+}
+
+// GetRideLocationHistory retrieves the ordered path recorded for a ride
+func (s *LocationService) GetRideLocationHistory(ctx context.Context, rideID int64) ([]repository.RideLocation, error) {
+	return s.repo.GetRideLocationHistory(ctx, rideID)
+}
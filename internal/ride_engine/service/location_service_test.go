@@ -3,11 +3,15 @@ package service
 import (
 	"context"
 	"errors"
+	"math"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/repository"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/config"
 )
 
 // MockLocationRepository is a mock implementation of the location repository
@@ -20,6 +24,19 @@ func (m *MockLocationRepository) UpdateDriverLocation(ctx context.Context, drive
 	return args.Error(0)
 }
 
+func (m *MockLocationRepository) UpdateDriverLocationBatch(ctx context.Context, driverID int64, points []repository.DriverLocationPoint) error {
+	args := m.Called(ctx, driverID, points)
+	return args.Error(0)
+}
+
+func (m *MockLocationRepository) FindNearestDriversWithDistance(ctx context.Context, lat, lng float64, maxDistance float64, limit int) ([]repository.NearestDriver, error) {
+	args := m.Called(ctx, lat, lng, maxDistance, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]repository.NearestDriver), args.Error(1)
+}
+
 func (m *MockLocationRepository) FindNearestDrivers(ctx context.Context, lat, lng float64, maxDistance float64, limit int) ([]int64, error) {
 	args := m.Called(ctx, lat, lng, maxDistance, limit)
 	if args.Get(0) == nil {
@@ -28,11 +45,58 @@ func (m *MockLocationRepository) FindNearestDrivers(ctx context.Context, lat, ln
 	return args.Get(0).([]int64), args.Error(1)
 }
 
+func (m *MockLocationRepository) FindNearbyDrivers(ctx context.Context, lat, lng float64, maxDistance float64, since time.Time, limit int) ([]repository.NearbyDriverLocation, error) {
+	args := m.Called(ctx, lat, lng, maxDistance, since, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]repository.NearbyDriverLocation), args.Error(1)
+}
+
+func (m *MockLocationRepository) GetDriverDensity(ctx context.Context, bounds repository.GeoBounds, gridSize float64, since time.Time) ([]repository.DensityCell, error) {
+	args := m.Called(ctx, bounds, gridSize, since)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]repository.DensityCell), args.Error(1)
+}
+
 func (m *MockLocationRepository) GetDriverLocation(ctx context.Context, driverID int64) (lat, lng float64, updatedAt *time.Time, err error) {
 	args := m.Called(ctx, driverID)
 	return args.Get(0).(float64), args.Get(1).(float64), args.Get(2).(*time.Time), args.Error(3)
 }
 
+func (m *MockLocationRepository) GetDriverLocationHistory(ctx context.Context, driverID int64, since time.Time) ([]repository.DriverLocationPoint, error) {
+	args := m.Called(ctx, driverID, since)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]repository.DriverLocationPoint), args.Error(1)
+}
+
+func (m *MockLocationRepository) SaveRideLocation(ctx context.Context, point repository.RideLocation) error {
+	args := m.Called(ctx, point)
+	return args.Error(0)
+}
+
+func (m *MockLocationRepository) GetRideLocationHistory(ctx context.Context, rideID int64) ([]repository.RideLocation, error) {
+	args := m.Called(ctx, rideID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]repository.RideLocation), args.Error(1)
+}
+
+func (m *MockLocationRepository) DeleteOldDriverLocations(ctx context.Context, before time.Time) (int64, error) {
+	args := m.Called(ctx, before)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockLocationRepository) DeleteOldRideLocations(ctx context.Context, before time.Time) (int64, error) {
+	args := m.Called(ctx, before)
+	return args.Get(0).(int64), args.Error(1)
+}
+
 func TestLocationService_UpdateDriverLocation(t *testing.T) {
 	mockRepo := new(MockLocationRepository)
 	service := &LocationService{
@@ -72,6 +136,70 @@ func TestLocationService_UpdateDriverLocation_Error(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+func TestLocationService_UpdateDriverLocation_TinyMoveSkipped(t *testing.T) {
+	mockRepo := new(MockLocationRepository)
+	service := NewLocationService(mockRepo, config.LocationConfig{MaxSearchRadiusMeters: 50000, MovementThresholdMeters: 5, RefreshInterval: 30 * time.Second})
+
+	ctx := context.Background()
+	driverID := int64(456)
+	updatedAt := time.Now().Add(-10 * time.Second)
+	mockRepo.On("GetDriverLocation", ctx, driverID).Return(23.8100, 90.4120, &updatedAt, nil)
+
+	// About 2 meters north of the stored location
+	err := service.UpdateDriverLocation(ctx, driverID, 23.81002, 90.4120)
+
+	assert.NoError(t, err)
+	mockRepo.AssertNotCalled(t, "UpdateDriverLocation", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestLocationService_UpdateDriverLocation_LargerMoveWritten(t *testing.T) {
+	mockRepo := new(MockLocationRepository)
+	service := NewLocationService(mockRepo, config.LocationConfig{MaxSearchRadiusMeters: 50000, MovementThresholdMeters: 5, RefreshInterval: 30 * time.Second})
+
+	ctx := context.Background()
+	driverID := int64(456)
+	updatedAt := time.Now().Add(-10 * time.Second)
+	mockRepo.On("GetDriverLocation", ctx, driverID).Return(23.8100, 90.4120, &updatedAt, nil)
+	// About 11 meters north of the stored location
+	mockRepo.On("UpdateDriverLocation", ctx, driverID, 23.8101, 90.4120).Return(nil)
+
+	err := service.UpdateDriverLocation(ctx, driverID, 23.8101, 90.4120)
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestLocationService_UpdateDriverLocation_StaleLocationWritten(t *testing.T) {
+	mockRepo := new(MockLocationRepository)
+	service := NewLocationService(mockRepo, config.LocationConfig{MaxSearchRadiusMeters: 50000, MovementThresholdMeters: 5, RefreshInterval: 30 * time.Second})
+
+	ctx := context.Background()
+	driverID := int64(456)
+	updatedAt := time.Now().Add(-time.Minute)
+	mockRepo.On("GetDriverLocation", ctx, driverID).Return(23.8100, 90.4120, &updatedAt, nil)
+	mockRepo.On("UpdateDriverLocation", ctx, driverID, 23.8100, 90.4120).Return(nil)
+
+	err := service.UpdateDriverLocation(ctx, driverID, 23.8100, 90.4120)
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestLocationService_UpdateDriverLocation_FirstPingWritten(t *testing.T) {
+	mockRepo := new(MockLocationRepository)
+	service := NewLocationService(mockRepo, config.LocationConfig{MaxSearchRadiusMeters: 50000, MovementThresholdMeters: 5, RefreshInterval: 30 * time.Second})
+
+	ctx := context.Background()
+	driverID := int64(456)
+	mockRepo.On("GetDriverLocation", ctx, driverID).Return(0.0, 0.0, (*time.Time)(nil), errors.New("driver location not found"))
+	mockRepo.On("UpdateDriverLocation", ctx, driverID, 23.8100, 90.4120).Return(nil)
+
+	err := service.UpdateDriverLocation(ctx, driverID, 23.8100, 90.4120)
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
 func TestLocationService_FindNearestDrivers(t *testing.T) {
 	mockRepo := new(MockLocationRepository)
 	service := &LocationService{
@@ -239,3 +367,221 @@ func TestLocationService_FindNearestDrivers_WithDifferentLimits(t *testing.T) {
 
 	mockRepo.AssertExpectations(t)
 }
+
+func TestLocationService_UpdateDriverLocationBatch_SortsOldestFirst(t *testing.T) {
+	mockRepo := new(MockLocationRepository)
+	service := &LocationService{
+		repo: mockRepo,
+	}
+
+	ctx := context.Background()
+	driverID := int64(456)
+	now := time.Now()
+	points := []LocationPoint{
+		{Lat: 23.8110, Lng: 90.4130, Timestamp: now.Add(-1 * time.Minute)}, // newest
+		{Lat: 23.8100, Lng: 90.4120, Timestamp: now.Add(-3 * time.Minute)},
+		{Lat: 23.8105, Lng: 90.4125, Timestamp: now.Add(-2 * time.Minute)},
+	}
+
+	var stored []repository.DriverLocationPoint
+	mockRepo.On("UpdateDriverLocationBatch", ctx, driverID, mock.Anything).
+		Run(func(args mock.Arguments) { stored = args.Get(2).([]repository.DriverLocationPoint) }).
+		Return(nil)
+
+	result, err := service.UpdateDriverLocationBatch(ctx, driverID, points)
+
+	assert.NoError(t, err)
+	assert.Equal(t, &LocationBatchResult{Accepted: 3, Dropped: 0, Latest: points[0]}, result)
+	if assert.Len(t, stored, 3) {
+		assert.Equal(t, points[1].Timestamp, stored[0].RecordedAt)
+		assert.Equal(t, points[2].Timestamp, stored[1].RecordedAt)
+		assert.Equal(t, points[0].Timestamp, stored[2].RecordedAt, "Newest point should be last so it becomes the current location")
+		assert.Equal(t, []float64{90.4130, 23.8110}, stored[2].Location.Coordinates)
+		assert.Equal(t, driverID, stored[2].DriverID)
+	}
+	mockRepo.AssertExpectations(t)
+}
+
+func TestLocationService_UpdateDriverLocationBatch_DropsBadPoints(t *testing.T) {
+	mockRepo := new(MockLocationRepository)
+	service := &LocationService{
+		repo: mockRepo,
+	}
+
+	ctx := context.Background()
+	driverID := int64(456)
+	now := time.Now()
+	good := LocationPoint{Lat: 23.8100, Lng: 90.4120, Timestamp: now.Add(-time.Minute)}
+	points := []LocationPoint{
+		good,
+		{Lat: 91, Lng: 90.4120, Timestamp: now}, // latitude out of range
+		{Lat: 23.8100, Lng: -181, Timestamp: now},                          // longitude out of range
+		{Lat: 0, Lng: 0, Timestamp: now},                                   // no GPS fix
+		{Lat: 23.8100, Lng: 90.4120},                                       // missing timestamp
+		{Lat: 23.8100, Lng: 90.4120, Timestamp: now.Add(10 * time.Minute)}, // in the future
+		{Lat: 23.8100, Lng: 90.4120, Timestamp: now.Add(-48 * time.Hour)},  // too old
+		{Lat: 23.8200, Lng: 90.4220, Timestamp: good.Timestamp},            // same time as good, reported later
+	}
+
+	var stored []repository.DriverLocationPoint
+	mockRepo.On("UpdateDriverLocationBatch", ctx, driverID, mock.Anything).
+		Run(func(args mock.Arguments) { stored = args.Get(2).([]repository.DriverLocationPoint) }).
+		Return(nil)
+
+	result, err := service.UpdateDriverLocationBatch(ctx, driverID, points)
+
+	assert.NoError(t, err)
+	assert.Equal(t, &LocationBatchResult{Accepted: 1, Dropped: 7, Latest: points[7]}, result)
+	if assert.Len(t, stored, 1) {
+		assert.Equal(t, []float64{90.4220, 23.8200}, stored[0].Location.Coordinates, "Last reported point for a timestamp should win")
+	}
+	mockRepo.AssertExpectations(t)
+}
+
+func TestLocationService_UpdateDriverLocationBatch_Invalid(t *testing.T) {
+	mockRepo := new(MockLocationRepository)
+	service := &LocationService{
+		repo: mockRepo,
+	}
+
+	ctx := context.Background()
+
+	_, err := service.UpdateDriverLocationBatch(ctx, 456, nil)
+	assert.ErrorIs(t, err, ErrEmptyLocationBatch)
+
+	_, err = service.UpdateDriverLocationBatch(ctx, 456, make([]LocationPoint, MaxLocationBatchSize+1))
+	assert.ErrorIs(t, err, ErrLocationBatchTooLarge)
+
+	_, err = service.UpdateDriverLocationBatch(ctx, 456, []LocationPoint{{Lat: 0, Lng: 0, Timestamp: time.Now()}})
+	assert.ErrorIs(t, err, ErrNoValidLocations)
+
+	mockRepo.AssertNotCalled(t, "UpdateDriverLocationBatch", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestLocationService_GetDriverDensity(t *testing.T) {
+	mockRepo := new(MockLocationRepository)
+	service := &LocationService{repo: mockRepo}
+
+	ctx := context.Background()
+	bounds := repository.GeoBounds{MinLat: 23.70, MinLng: 90.30, MaxLat: 23.90, MaxLng: 90.50}
+	cells := []repository.DensityCell{{Lat: 23.81, Lng: 90.41, Count: 2}}
+	fresh := mock.MatchedBy(func(since time.Time) bool {
+		return time.Since(since) >= driverLocationFreshness && time.Since(since) < driverLocationFreshness+time.Minute
+	})
+	mockRepo.On("GetDriverDensity", ctx, bounds, 0.01, fresh).Return(cells, nil)
+
+	result, err := service.GetDriverDensity(ctx, bounds, 0.01)
+
+	require.NoError(t, err)
+	assert.Equal(t, cells, result)
+}
+
+func TestLocationService_GetDriverDensity_NoDrivers(t *testing.T) {
+	mockRepo := new(MockLocationRepository)
+	service := &LocationService{repo: mockRepo}
+
+	ctx := context.Background()
+	bounds := repository.GeoBounds{MinLat: 23.70, MinLng: 90.30, MaxLat: 23.90, MaxLng: 90.50}
+	mockRepo.On("GetDriverDensity", ctx, bounds, 0.01, mock.Anything).Return(nil, nil)
+
+	result, err := service.GetDriverDensity(ctx, bounds, 0.01)
+
+	require.NoError(t, err)
+	assert.NotNil(t, result, "An empty list should be returned rather than null")
+	assert.Empty(t, result)
+}
+
+func TestLocationService_GetDriverDensity_InvalidRequest(t *testing.T) {
+	mockRepo := new(MockLocationRepository)
+	service := &LocationService{repo: mockRepo}
+
+	ctx := context.Background()
+	valid := repository.GeoBounds{MinLat: 23.70, MinLng: 90.30, MaxLat: 23.90, MaxLng: 90.50}
+
+	tests := []struct {
+		name     string
+		bounds   repository.GeoBounds
+		gridSize float64
+		wantErr  error
+	}{
+		{"min above max", repository.GeoBounds{MinLat: 23.90, MinLng: 90.30, MaxLat: 23.70, MaxLng: 90.50}, 0.01, ErrInvalidHeatmapBounds},
+		{"empty box", repository.GeoBounds{MinLat: 23.70, MinLng: 90.30, MaxLat: 23.70, MaxLng: 90.50}, 0.01, ErrInvalidHeatmapBounds},
+		{"latitude out of range", repository.GeoBounds{MinLat: -91, MinLng: 90.30, MaxLat: 23.90, MaxLng: 90.50}, 0.01, ErrInvalidHeatmapBounds},
+		{"zero grid size", valid, 0, ErrInvalidHeatmapGrid},
+		{"too many cells", valid, 0.001, ErrInvalidHeatmapGrid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.GetDriverDensity(ctx, tt.bounds, tt.gridSize)
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+	mockRepo.AssertNotCalled(t, "GetDriverDensity", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestLocationService_FindNearestDrivers_ClampsRadius(t *testing.T) {
+	mockRepo := new(MockLocationRepository)
+	service := NewLocationService(mockRepo, config.LocationConfig{MaxSearchRadiusMeters: 50000})
+
+	ctx := context.Background()
+	mockRepo.On("FindNearestDrivers", ctx, 23.8100, 90.4120, 50000.0, 5).Return([]int64{456}, nil)
+	mockRepo.On("FindNearestDrivers", ctx, 23.8100, 90.4120, 3000.0, 5).Return([]int64{456}, nil)
+
+	// A 10,000 km radius is reduced to the maximum
+	_, err := service.FindNearestDrivers(ctx, 23.8100, 90.4120, 10000000, 5)
+	require.NoError(t, err)
+
+	// Radii within the maximum are used as is
+	_, err = service.FindNearestDrivers(ctx, 23.8100, 90.4120, 3000, 5)
+	require.NoError(t, err)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestLocationService_FindNearestDrivers_FractionalRadius(t *testing.T) {
+	mockRepo := new(MockLocationRepository)
+	service := NewLocationService(mockRepo, config.LocationConfig{MaxSearchRadiusMeters: 50000})
+
+	ctx := context.Background()
+	mockRepo.On("FindNearestDrivers", ctx, 23.8100, 90.4120, 2.5, 5).Return([]int64{456}, nil)
+	mockRepo.On("FindNearbyDrivers", ctx, 23.8100, 90.4120, 1500.75, mock.Anything, 5).Return([]repository.NearbyDriverLocation{}, nil)
+
+	// Fractions of a meter reach the repository unrounded
+	drivers, err := service.FindNearestDrivers(ctx, 23.8100, 90.4120, 2.5, 5)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{456}, drivers)
+
+	_, err = service.FindNearbyDrivers(ctx, 23.8100, 90.4120, 1500.75, time.Now(), 5)
+	require.NoError(t, err)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestLocationService_FindNearestDrivers_InvalidRadius(t *testing.T) {
+	mockRepo := new(MockLocationRepository)
+	service := NewLocationService(mockRepo, config.LocationConfig{MaxSearchRadiusMeters: 50000})
+
+	ctx := context.Background()
+	for _, radius := range []float64{0, -10, math.NaN(), math.Inf(1)} {
+		_, err := service.FindNearestDrivers(ctx, 23.8100, 90.4120, radius, 5)
+		assert.ErrorIs(t, err, ErrInvalidSearchRadius, "radius %v", radius)
+
+		_, err = service.FindNearestDriversWithDistance(ctx, 23.8100, 90.4120, radius, 5)
+		assert.ErrorIs(t, err, ErrInvalidSearchRadius, "radius %v", radius)
+
+		_, err = service.FindNearbyDrivers(ctx, 23.8100, 90.4120, radius, time.Now(), 5)
+		assert.ErrorIs(t, err, ErrInvalidSearchRadius, "radius %v", radius)
+	}
+	mockRepo.AssertNotCalled(t, "FindNearestDrivers", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestLocationService_ClampSearchRadius(t *testing.T) {
+	service := NewLocationService(new(MockLocationRepository), config.LocationConfig{MaxSearchRadiusMeters: 50000})
+	assert.Equal(t, 50000.0, service.ClampSearchRadius(10000000))
+	assert.Equal(t, 50000.0, service.ClampSearchRadius(50000))
+	assert.Equal(t, 10000.0, service.ClampSearchRadius(10000))
+
+	unlimited := NewLocationService(new(MockLocationRepository), config.LocationConfig{})
+	assert.Equal(t, 10000000.0, unlimited.ClampSearchRadius(10000000))
+}
@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/logger"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/utils"
+)
+
+// Ride notification types
+const (
+	NotificationRideRequest    = "ride_request"    // to the driver a ride is offered to
+	NotificationDriverAssigned = "driver_assigned" // to the customer once a driver accepts
+	NotificationRideStarted    = "ride_started"
+	NotificationRideCompleted  = "ride_completed"
+	NotificationRideExpired    = "ride_expired" // to the customer when no driver accepted in time
+)
+
+// Notifier tells drivers and customers about changes to their rides
+// Notifications are best effort, callers log a failed notification and carry on with the ride flow
+type Notifier interface {
+	NotifyDriverRideRequest(ctx context.Context, ride *domain.Ride, driverID int64) error
+	NotifyCustomerDriverAssigned(ctx context.Context, ride *domain.Ride) error
+	NotifyCustomerRideStarted(ctx context.Context, ride *domain.Ride) error
+	NotifyCustomerRideCompleted(ctx context.Context, ride *domain.Ride) error
+	NotifyCustomerRideExpired(ctx context.Context, ride *domain.Ride) error
+}
+
+// RideNotification is the message sent to a driver or customer about one of their rides
+type RideNotification struct {
+	Type     string    `json:"type"`
+	RideID   int64     `json:"ride_id"`
+	DriverID *int64    `json:"driver_id,omitempty"`
+	Status   string    `json:"status"`
+	Fare     *float64  `json:"fare,omitempty"` // only set once the ride completes
+	At       time.Time `json:"at"`
+}
+
+func newRideNotification(notificationType string, ride *domain.Ride) RideNotification {
+	notification := RideNotification{
+		Type:     notificationType,
+		RideID:   ride.ID,
+		DriverID: ride.DriverID,
+		Status:   string(ride.Status),
+		At:       time.Now(),
+	}
+	if ride.Status == domain.RideStatusCompleted {
+		notification.Fare = ride.Fare
+	}
+	return notification
+}
+
+// NoopNotifier drops every notification, it is used when no notifier is configured
+type NoopNotifier struct{}
+
+func (NoopNotifier) NotifyDriverRideRequest(ctx context.Context, ride *domain.Ride, driverID int64) error {
+	return nil
+}
+
+func (NoopNotifier) NotifyCustomerDriverAssigned(ctx context.Context, ride *domain.Ride) error {
+	return nil
+}
+
+func (NoopNotifier) NotifyCustomerRideStarted(ctx context.Context, ride *domain.Ride) error {
+	return nil
+}
+
+func (NoopNotifier) NotifyCustomerRideCompleted(ctx context.Context, ride *domain.Ride) error {
+	return nil
+}
+
+func (NoopNotifier) NotifyCustomerRideExpired(ctx context.Context, ride *domain.Ride) error {
+	return nil
+}
+
+// MultiNotifier sends every notification through each of its notifiers
+// A failing notifier does not stop the others, their errors are joined
+type MultiNotifier []Notifier
+
+func (m MultiNotifier) NotifyDriverRideRequest(ctx context.Context, ride *domain.Ride, driverID int64) error {
+	var errs []error
+	for _, notifier := range m {
+		errs = append(errs, notifier.NotifyDriverRideRequest(ctx, ride, driverID))
+	}
+	return errors.Join(errs...)
+}
+
+func (m MultiNotifier) NotifyCustomerDriverAssigned(ctx context.Context, ride *domain.Ride) error {
+	var errs []error
+	for _, notifier := range m {
+		errs = append(errs, notifier.NotifyCustomerDriverAssigned(ctx, ride))
+	}
+	return errors.Join(errs...)
+}
+
+func (m MultiNotifier) NotifyCustomerRideStarted(ctx context.Context, ride *domain.Ride) error {
+	var errs []error
+	for _, notifier := range m {
+		errs = append(errs, notifier.NotifyCustomerRideStarted(ctx, ride))
+	}
+	return errors.Join(errs...)
+}
+
+func (m MultiNotifier) NotifyCustomerRideCompleted(ctx context.Context, ride *domain.Ride) error {
+	var errs []error
+	for _, notifier := range m {
+		errs = append(errs, notifier.NotifyCustomerRideCompleted(ctx, ride))
+	}
+	return errors.Join(errs...)
+}
+
+func (m MultiNotifier) NotifyCustomerRideExpired(ctx context.Context, ride *domain.Ride) error {
+	var errs []error
+	for _, notifier := range m {
+		errs = append(errs, notifier.NotifyCustomerRideExpired(ctx, ride))
+	}
+	return errors.Join(errs...)
+}
+
+// RedisNotifier publishes notifications as JSON on a Redis pub/sub channel per user
+// for the WebSocket and SSE streams to forward, users without a subscriber miss them
+type RedisNotifier struct {
+	redis *redis.Client
+}
+
+func NewRedisNotifier(redis *redis.Client) *RedisNotifier {
+	return &RedisNotifier{redis: redis}
+}
+
+// NotifyDriverRideRequest tells the driver a ride is offered to them
+func (n *RedisNotifier) NotifyDriverRideRequest(ctx context.Context, ride *domain.Ride, driverID int64) error {
+	return n.publish(ctx, utils.DriverNotificationChannel(driverID), newRideNotification(NotificationRideRequest, ride))
+}
+
+// NotifyCustomerDriverAssigned tells the customer a driver accepted their ride
+func (n *RedisNotifier) NotifyCustomerDriverAssigned(ctx context.Context, ride *domain.Ride) error {
+	return n.publish(ctx, utils.CustomerNotificationChannel(ride.CustomerID), newRideNotification(NotificationDriverAssigned, ride))
+}
+
+// NotifyCustomerRideStarted tells the customer their ride started
+func (n *RedisNotifier) NotifyCustomerRideStarted(ctx context.Context, ride *domain.Ride) error {
+	return n.publish(ctx, utils.CustomerNotificationChannel(ride.CustomerID), newRideNotification(NotificationRideStarted, ride))
+}
+
+// NotifyCustomerRideCompleted tells the customer their ride completed and what it cost
+func (n *RedisNotifier) NotifyCustomerRideCompleted(ctx context.Context, ride *domain.Ride) error {
+	return n.publish(ctx, utils.CustomerNotificationChannel(ride.CustomerID), newRideNotification(NotificationRideCompleted, ride))
+}
+
+// NotifyCustomerRideExpired tells the customer no driver accepted their ride in time
+func (n *RedisNotifier) NotifyCustomerRideExpired(ctx context.Context, ride *domain.Ride) error {
+	return n.publish(ctx, utils.CustomerNotificationChannel(ride.CustomerID), newRideNotification(NotificationRideExpired, ride))
+}
+
+func (n *RedisNotifier) publish(ctx context.Context, channel string, notification RideNotification) error {
+	payload, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+
+	if err := n.redis.Publish(ctx, channel, payload).Err(); err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to publish %s notification for ride %d: %v", notification.Type, notification.RideID, err))
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/testutil"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/utils"
+)
+
+// MockNotifier is a mock implementation of Notifier
+type MockNotifier struct {
+	mock.Mock
+}
+
+func (m *MockNotifier) NotifyDriverRideRequest(ctx context.Context, ride *domain.Ride, driverID int64) error {
+	args := m.Called(ctx, ride, driverID)
+	return args.Error(0)
+}
+
+func (m *MockNotifier) NotifyCustomerDriverAssigned(ctx context.Context, ride *domain.Ride) error {
+	args := m.Called(ctx, ride)
+	return args.Error(0)
+}
+
+func (m *MockNotifier) NotifyCustomerRideStarted(ctx context.Context, ride *domain.Ride) error {
+	args := m.Called(ctx, ride)
+	return args.Error(0)
+}
+
+func (m *MockNotifier) NotifyCustomerRideCompleted(ctx context.Context, ride *domain.Ride) error {
+	args := m.Called(ctx, ride)
+	return args.Error(0)
+}
+
+func (m *MockNotifier) NotifyCustomerRideExpired(ctx context.Context, ride *domain.Ride) error {
+	args := m.Called(ctx, ride)
+	return args.Error(0)
+}
+
+func TestRedisNotifier_DriverAssignedReachesCustomer(t *testing.T) {
+	redisClient, _ := testutil.NewFakeRedis()
+	notifier := NewRedisNotifier(redisClient)
+
+	ctx := context.Background()
+	pubsub := redisClient.Subscribe(ctx, utils.CustomerNotificationChannel(123))
+	defer pubsub.Close()
+	_, err := pubsub.Receive(ctx)
+	require.NoError(t, err)
+
+	driverID := int64(456)
+	ride := &domain.Ride{ID: 1, CustomerID: 123, DriverID: &driverID, Status: domain.RideStatusAccepted}
+	require.NoError(t, notifier.NotifyCustomerDriverAssigned(ctx, ride))
+
+	select {
+	case m := <-pubsub.Channel():
+		var notification RideNotification
+		require.NoError(t, json.Unmarshal([]byte(m.Payload), &notification))
+		assert.Equal(t, NotificationDriverAssigned, notification.Type)
+		assert.Equal(t, int64(1), notification.RideID)
+		require.NotNil(t, notification.DriverID)
+		assert.Equal(t, driverID, *notification.DriverID)
+		assert.Equal(t, "accepted", notification.Status)
+		assert.Nil(t, notification.Fare)
+	case <-time.After(2 * time.Second):
+		t.Fatal("notification did not reach the customer channel")
+	}
+}
+
+func TestNoopNotifier(t *testing.T) {
+	var notifier Notifier = NoopNotifier{}
+	ride := &domain.Ride{ID: 1, CustomerID: 123}
+
+	assert.NoError(t, notifier.NotifyDriverRideRequest(context.Background(), ride, 456))
+	assert.NoError(t, notifier.NotifyCustomerDriverAssigned(context.Background(), ride))
+	assert.NoError(t, notifier.NotifyCustomerRideStarted(context.Background(), ride))
+	assert.NoError(t, notifier.NotifyCustomerRideCompleted(context.Background(), ride))
+	assert.NoError(t, notifier.NotifyCustomerRideExpired(context.Background(), ride))
+}
+
+func TestRideService_AcceptRide_NotifiesCustomer(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	onlineRepo := new(MockOnlineStatusRepository)
+	notifier := new(MockNotifier)
+	service := newTestRideService(rideRepo, onlineRepo, new(MockLocationRepository))
+	service.notifier = notifier
+
+	ctx := context.Background()
+	driverID := int64(456)
+	ride := &domain.Ride{ID: 1, CustomerID: 123, Status: domain.RideStatusRequested, RequestedAt: time.Now()}
+	ride.OfferTo(driverID, time.Now().Add(time.Minute))
+
+	onlineRepo.On("IsDriverOnline", ctx, driverID).Return(true, nil)
+	rideRepo.On("GetByID", ctx, int64(1)).Return(ride, nil)
+	rideRepo.On("AcceptRide", ctx, int64(1), driverID, mock.AnythingOfType("time.Time")).Return(nil)
+	notifier.On("NotifyCustomerDriverAssigned", ctx, mock.MatchedBy(func(r *domain.Ride) bool {
+		return r.ID == 1 && r.DriverID != nil && *r.DriverID == driverID
+	})).Return(nil)
+
+	err := service.AcceptRide(ctx, 1, driverID)
+
+	require.NoError(t, err)
+	notifier.AssertExpectations(t)
+}
+
+func TestRideService_AcceptRide_NotificationFailureDoesNotFailAccept(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	onlineRepo := new(MockOnlineStatusRepository)
+	notifier := new(MockNotifier)
+	service := newTestRideService(rideRepo, onlineRepo, new(MockLocationRepository))
+	service.notifier = notifier
+
+	ctx := context.Background()
+	driverID := int64(456)
+	ride := &domain.Ride{ID: 1, CustomerID: 123, Status: domain.RideStatusRequested, RequestedAt: time.Now()}
+	ride.OfferTo(driverID, time.Now().Add(time.Minute))
+
+	onlineRepo.On("IsDriverOnline", ctx, driverID).Return(true, nil)
+	rideRepo.On("GetByID", ctx, int64(1)).Return(ride, nil)
+	rideRepo.On("AcceptRide", ctx, int64(1), driverID, mock.AnythingOfType("time.Time")).Return(nil)
+	notifier.On("NotifyCustomerDriverAssigned", ctx, ride).Return(errors.New("redis unavailable"))
+
+	err := service.AcceptRide(ctx, 1, driverID)
+
+	assert.NoError(t, err)
+	assert.Equal(t, domain.RideStatusAccepted, ride.Status)
+}
+
+func TestRideService_CompleteRide_NotifiesCustomer(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	locationRepo := new(MockLocationRepository)
+	notifier := new(MockNotifier)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), locationRepo)
+	service.notifier = notifier
+
+	ctx := context.Background()
+	driverID := int64(456)
+	startedAt := time.Now().Add(-15 * time.Minute)
+	ride := &domain.Ride{ID: 1, CustomerID: 123, DriverID: &driverID, Status: domain.RideStatusStarted, RequestedAt: startedAt.Add(-5 * time.Minute), StartedAt: &startedAt}
+
+	rideRepo.On("GetByID", ctx, int64(1)).Return(ride, nil)
+	locationRepo.On("GetRideLocationHistory", ctx, int64(1)).Return(nil, nil)
+	rideRepo.On("Update", ctx, ride, mock.Anything).Return(nil)
+	notifier.On("NotifyCustomerRideCompleted", ctx, ride).Return(nil)
+
+	err := service.CompleteRide(ctx, 1, driverID)
+
+	require.NoError(t, err)
+	notifier.AssertExpectations(t)
+}
+
+func TestDispatchService_Dispatch_NotifiesOfferedDriver(t *testing.T) {
+	now := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	s, m := newTestDispatchService(now)
+	notifier := new(MockNotifier)
+	s.notifier = notifier
+
+	ctx := context.Background()
+	ride := &domain.Ride{ID: 1, PickupLat: 23.8100, PickupLng: 90.4120, Status: domain.RideStatusRequested, RideType: domain.RideTypeEconomy}
+
+	m.locationRepo.On("FindNearestDrivers", ctx, 23.8100, 90.4120, testDispatchRadius, dispatchCandidateLimit).Return([]int64{14}, nil)
+	m.onlineRepo.On("GetOnlineDriversByIDs", ctx, []int64{14}).Return([]int64{14}, nil)
+	m.rideRepo.On("GetBusyDriverIDs", ctx, []int64{14}).Return([]int64{}, nil)
+	m.driverRepo.On("GetByID", ctx, int64(14)).Return(&domain.Driver{ID: 14}, nil)
+	m.rideRepo.On("SetRideOffer", ctx, int64(1), offeredTo(14), now.Add(testOfferTimeout)).Return(nil)
+	notifier.On("NotifyDriverRideRequest", ctx, ride, int64(14)).Return(nil)
+
+	err := s.Dispatch(ctx, ride)
+
+	require.NoError(t, err)
+	notifier.AssertExpectations(t)
+}
@@ -2,37 +2,158 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
+	"errors"
 	"fmt"
-	"math/rand"
+	"math"
+	"math/big"
 	"time"
 	"vcs.technonext.com/carrybee/ride_engine/pkg/logger"
 
 	"github.com/redis/go-redis/v9"
-	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/repository/postgres"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/repository"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/pagination"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/utils"
 )
 
+const (
+	maxOTPAttempts     = 5
+	otpLockoutDuration = 15 * time.Minute
+	otpDigits          = 6
+	otpTTL             = 2 * time.Minute
+	// devOTP is the code every OTP is in development, where no SMS is sent
+	devOTP = "123456"
+)
+
+// otpRange is the number of distinct OTPs, 10^otpDigits
+var otpRange = new(big.Int).Exp(big.NewInt(10), big.NewInt(otpDigits), nil)
+
+var (
+	ErrTooManyOTPAttempts = domain.NewError(domain.ErrTooManyRequests, "too many failed attempts")
+	ErrInvalidOTP         = domain.NewError(domain.ErrValidation, "invalid or expired OTP")
+	ErrPhoneRequired      = domain.NewError(domain.ErrValidation, "phone is required")
+	ErrOTPCooldown        = domain.NewError(domain.ErrTooManyRequests, "please wait before requesting another OTP")
+	ErrOTPDeliveryFailed  = errors.New("failed to send OTP, please try again")
+)
+
+// OTPCooldownError is returned when an OTP is requested again for a phone before the resend cooldown is over
+// It wraps ErrOTPCooldown so errors.Is matches it and its error kind
+type OTPCooldownError struct {
+	Remaining time.Duration
+}
+
+func (e *OTPCooldownError) Error() string {
+	return fmt.Sprintf("%v, try again in %d seconds", ErrOTPCooldown, e.RemainingSeconds())
+}
+
+func (e *OTPCooldownError) Unwrap() error {
+	return ErrOTPCooldown
+}
+
+// RemainingSeconds is the wait rounded up to whole seconds
+func (e *OTPCooldownError) RemainingSeconds() int {
+	return int(math.Ceil(e.Remaining.Seconds()))
+}
+
+// OTPHistorySort is the sort of the OTP history, the cursors it hands out are only accepted by it
+const OTPHistorySort = "created_at"
+
+// OTPHistoryPage is one page of the OTPs sent to a phone along with the total across all pages
+// NextCursor fetches the page after it and is empty on the last page
+type OTPHistoryPage struct {
+	OTPs       []repository.OTPRecord `json:"otps"`
+	Total      int64                  `json:"total"`
+	Limit      int                    `json:"limit"`
+	Offset     int                    `json:"offset"`
+	NextCursor string                 `json:"next_cursor,omitempty"`
+}
+
+// otpMessages is the SMS text for each OTP purpose, the code is filled in for %s
+var otpMessages = map[string]string{
+	driverLoginOTPPurpose:   "Your Carrybee driver login code is %s. It expires in 2 minutes.",
+	passwordResetOTPPurpose: "Your Carrybee password reset code is %s. It expires in 2 minutes.",
+}
+
 type OTPService struct {
-	redis   *redis.Client
-	otpRepo *postgres.OTPPostgresRepository
+	redis          *redis.Client
+	otpRepo        repository.OTPRepository
+	resendCooldown time.Duration // minimum time between OTPs sent to the same phone, 0 to disable
+	sender         SMSSender
+	devMode        bool // OTPs are always devOTP and printed to the console instead of sent
 }
 
-func NewOTPService(redisClient *redis.Client, otpRepo *postgres.OTPPostgresRepository) *OTPService {
+func NewOTPService(redisClient *redis.Client, otpRepo repository.OTPRepository, resendCooldown time.Duration, sender SMSSender, devMode bool) *OTPService {
 	return &OTPService{
-		redis:   redisClient,
-		otpRepo: otpRepo,
+		redis:          redisClient,
+		otpRepo:        otpRepo,
+		resendCooldown: resendCooldown,
+		sender:         sender,
+		devMode:        devMode,
 	}
 }
 
+// SendOTP generates an OTP for the purpose, saves it and texts it to the phone
+// In dev mode the OTP is devOTP and is printed instead of sent
+// When the SMS cannot be sent ErrOTPDeliveryFailed is returned and the resend cooldown is lifted so the user can retry
+func (s *OTPService) SendOTP(ctx context.Context, phone, purpose string) error {
+	otp := devOTP
+	if !s.devMode {
+		otp = s.GenerateOTP()
+	}
+
+	if err := s.SaveOTP(ctx, phone, otp, purpose); err != nil {
+		logger.Error(ctx, fmt.Sprintf("error saving otp: %v", err))
+		return err
+	}
+
+	if s.devMode {
+		fmt.Printf("OTP (%s) for %s: %s\n", purpose, phone, otp)
+		return nil
+	}
+
+	if err := s.deliver(ctx, phone, otpMessage(purpose, otp)); err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to send %s OTP to %s: %v", purpose, utils.MaskPhone(phone), err))
+		s.redis.Del(ctx, otpLastSentKey(phone))
+		return fmt.Errorf("%w: %v", ErrOTPDeliveryFailed, err)
+	}
+
+	return nil
+}
+
+func (s *OTPService) deliver(ctx context.Context, phone, message string) error {
+	if s.sender == nil {
+		return errors.New("no SMS sender configured")
+	}
+	return s.sender.Send(ctx, phone, message)
+}
+
+func otpMessage(purpose, otp string) string {
+	format, ok := otpMessages[purpose]
+	if !ok {
+		format = "Your Carrybee verification code is %s. It expires in 2 minutes."
+	}
+	return fmt.Sprintf(format, otp)
+}
+
+// GenerateOTP returns a random zero-padded code of otpDigits digits
+// The code comes from crypto/rand so it cannot be predicted from earlier codes
 func (s *OTPService) GenerateOTP() string {
-	return fmt.Sprintf("%06d", rand.Intn(1000000))
+	n, err := rand.Int(rand.Reader, otpRange)
+	if err != nil {
+		// crypto/rand.Reader does not return errors, it crashes the program instead
+		panic(fmt.Sprintf("generating otp: %v", err))
+	}
+	return fmt.Sprintf("%0*d", otpDigits, n)
 }
 
 // SaveOTP saves OTP in both Redis (for fast validation) and PostgreSQL (for visualization)
+// Each purpose has its own OTP, sending one does not replace a pending OTP of another purpose
 func (s *OTPService) SaveOTP(ctx context.Context, phone, otp, purpose string) error {
-	expiresAt := time.Now().Add(2 * time.Minute)
+	expiresAt := time.Now().Add(otpTTL)
 
-	key := fmt.Sprintf("otp:%s", phone)
-	if err := s.redis.Set(ctx, key, otp, 2*time.Minute).Err(); err != nil {
+	key := otpKey(purpose, phone)
+	if err := s.redis.Set(ctx, key, otp, otpTTL).Err(); err != nil {
 		logger.Error(ctx, fmt.Sprintf("Failed to save OTP to Redis: %v", err))
 		return err
 	}
@@ -44,25 +165,103 @@ func (s *OTPService) SaveOTP(ctx context.Context, phone, otp, purpose string) er
 	return nil
 }
 
-// VerifyOTP verifies OTP from both Redis and PostgreSQL
-func (s *OTPService) VerifyOTP(ctx context.Context, phone, otp string) (bool, error) {
-	key := fmt.Sprintf("otp:%s", phone)
+// StartResendCooldown records that an OTP is being sent to the phone now
+// It returns an *OTPCooldownError with the remaining wait when the last OTP was sent less than resendCooldown ago
+func (s *OTPService) StartResendCooldown(ctx context.Context, phone string) error {
+	if s.resendCooldown <= 0 {
+		return nil
+	}
+
+	key := otpLastSentKey(phone)
+	now := time.Now()
+	started, err := s.redis.SetNX(ctx, key, now.UnixMilli(), s.resendCooldown).Result()
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to record OTP send time in Redis: %v", err))
+		return err
+	}
+	if started {
+		return nil
+	}
+
+	lastSentMillis, err := s.redis.Get(ctx, key).Int64()
+	if err != nil {
+		if err == redis.Nil {
+			// The cooldown ended between the two commands
+			return s.StartResendCooldown(ctx, phone)
+		}
+		logger.Error(ctx, fmt.Sprintf("Failed to get OTP send time from Redis: %v", err))
+		return err
+	}
+
+	remaining := time.UnixMilli(lastSentMillis).Add(s.resendCooldown).Sub(now)
+	if remaining <= 0 {
+		remaining = time.Second
+	}
+	logger.Error(ctx, fmt.Sprintf("OTP requested again for phone %s within the cooldown, %s remaining", utils.MaskPhone(phone), remaining))
+	return &OTPCooldownError{Remaining: remaining}
+}
+
+// VerifyOTP verifies an OTP sent to the phone for the purpose from both Redis and PostgreSQL
+// An OTP sent for another purpose is not valid, e.g. a driver login code cannot reset a password
+// Verification is locked for the phone after too many wrong guesses
+// Each attempt is counted before the OTP is checked, so concurrent guesses cannot all slip in under the limit
+func (s *OTPService) VerifyOTP(ctx context.Context, phone, otp, purpose string) (bool, error) {
+	attemptsKey := otpAttemptsKey(phone)
+	attempts, err := s.countAttempt(ctx, attemptsKey)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to record OTP attempt in Redis: %v", err))
+	}
+	if attempts > maxOTPAttempts {
+		logger.Error(ctx, fmt.Sprintf("OTP verification locked for phone %s", utils.MaskPhone(phone)))
+		return false, ErrTooManyOTPAttempts
+	}
+
+	valid, err := s.verifyOTP(ctx, phone, otp, purpose)
+	if err != nil {
+		return false, err
+	}
+
+	if valid {
+		s.redis.Del(ctx, attemptsKey)
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// countAttempt adds an attempt to the phone's counter and returns the new count
+// The lockout window starts with the first attempt and is not extended by later ones
+func (s *OTPService) countAttempt(ctx context.Context, attemptsKey string) (int64, error) {
+	var incr *redis.IntCmd
+	_, err := s.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		incr = pipe.Incr(ctx, attemptsKey)
+		pipe.ExpireNX(ctx, attemptsKey, otpLockoutDuration)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
+
+func (s *OTPService) verifyOTP(ctx context.Context, phone, otp, purpose string) (bool, error) {
+	key := otpKey(purpose, phone)
 	storedOTP, err := s.redis.Get(ctx, key).Result()
 
 	if err == redis.Nil {
-		valid, dbErr := s.otpRepo.VerifyOTP(ctx, phone, otp)
+		valid, dbErr := s.otpRepo.VerifyOTP(ctx, phone, otp, purpose)
 		return valid, dbErr
 	}
 
 	if err != nil {
 		// Redis error, fallback to database
-		return s.otpRepo.VerifyOTP(ctx, phone, otp)
+		return s.otpRepo.VerifyOTP(ctx, phone, otp, purpose)
 	}
 
 	if storedOTP == otp {
 		s.redis.Del(ctx, key)
 
-		if _, err := s.otpRepo.VerifyOTP(ctx, phone, otp); err != nil {
+		if _, err := s.otpRepo.VerifyOTP(ctx, phone, otp, purpose); err != nil {
 			logger.Error(ctx, fmt.Sprintf("verify otp error: %v", err))
 		}
 
@@ -72,10 +271,65 @@ func (s *OTPService) VerifyOTP(ctx context.Context, phone, otp string) (bool, er
 	return false, nil
 }
 
-// InvalidateOTP marks all pending OTPs for a phone as expired
+// InvalidateOTP marks all pending OTPs for a phone as expired, whatever their purpose
 func (s *OTPService) InvalidateOTP(ctx context.Context, phone string) error {
-	key := fmt.Sprintf("otp:%s", phone)
-	s.redis.Del(ctx, key)
+	keys := []string{otpAttemptsKey(phone)}
+	for purpose := range otpMessages {
+		keys = append(keys, otpKey(purpose, phone))
+	}
+	s.redis.Del(ctx, keys...)
 
 	return s.otpRepo.MarkExpired(ctx, phone)
 }
+
+// GetOTPHistory retrieves a page of the OTPs sent to filter.Phone, newest first, for support debugging
+// OTPs that were neither verified nor expired but are past their expiry are reported as expired
+func (s *OTPService) GetOTPHistory(ctx context.Context, filter repository.OTPFilter, page repository.Page) (*OTPHistoryPage, error) {
+	if filter.Phone == "" {
+		logger.Error(ctx, "phone is required for otp history")
+		return nil, ErrPhoneRequired
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		logger.Error(ctx, fmt.Sprintf("invalid otp history date range: %s to %s", filter.From, filter.To))
+		return nil, ErrInvalidDateRange
+	}
+
+	records, total, err := s.otpRepo.GetOTPHistory(ctx, filter, page)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to get otp history of phone %s: %v", utils.MaskPhone(filter.Phone), err))
+		return nil, err
+	}
+
+	now := time.Now()
+	for i := range records {
+		if !records[i].IsVerified && !now.Before(records[i].ExpiresAt) {
+			records[i].IsExpired = true
+		}
+	}
+	if records == nil {
+		records = []repository.OTPRecord{}
+	}
+
+	return &OTPHistoryPage{
+		OTPs:   records,
+		Total:  total,
+		Limit:  page.Limit,
+		Offset: page.Offset,
+		NextCursor: pagination.Next(records, page.Limit, OTPHistorySort, func(record repository.OTPRecord) (time.Time, int64) {
+			return record.CreatedAt, record.ID
+		}),
+	}, nil
+}
+
+// otpKey is where the pending OTP sent to the phone for the purpose is kept
+func otpKey(purpose, phone string) string {
+	return fmt.Sprintf("otp:%s:%s", purpose, phone)
+}
+
+func otpAttemptsKey(phone string) string {
+	return fmt.Sprintf("otp_attempts:%s", phone)
+}
+
+func otpLastSentKey(phone string) string {
+	return fmt.Sprintf("otp_last_sent:%s", phone)
+}
@@ -0,0 +1,352 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/repository"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/testutil"
+)
+
+// MockSMSSender is a mock implementation of SMSSender
+type MockSMSSender struct {
+	mock.Mock
+}
+
+func (m *MockSMSSender) Send(ctx context.Context, phone, message string) error {
+	args := m.Called(ctx, phone, message)
+	return args.Error(0)
+}
+
+func TestOTPService_VerifyOTP_Valid(t *testing.T) {
+	redisClient, _ := testutil.NewFakeRedis()
+	otpRepo := new(MockOTPRepository)
+	service := NewOTPService(redisClient, otpRepo, 0, nil, false)
+
+	ctx := context.Background()
+	phone := "+8801700000000"
+
+	require.NoError(t, redisClient.Set(ctx, otpKey(driverLoginOTPPurpose, phone), "123456", 2*time.Minute).Err())
+	otpRepo.On("VerifyOTP", ctx, phone, "123456", driverLoginOTPPurpose).Return(true, nil)
+
+	valid, err := service.VerifyOTP(ctx, phone, "123456", driverLoginOTPPurpose)
+
+	assert.NoError(t, err)
+	assert.True(t, valid)
+}
+
+func TestOTPService_VerifyOTP_LockoutAfterMaxAttempts(t *testing.T) {
+	redisClient, _ := testutil.NewFakeRedis()
+	otpRepo := new(MockOTPRepository)
+	service := NewOTPService(redisClient, otpRepo, 0, nil, false)
+
+	ctx := context.Background()
+	phone := "+8801700000000"
+
+	require.NoError(t, redisClient.Set(ctx, otpKey(driverLoginOTPPurpose, phone), "123456", 2*time.Minute).Err())
+
+	for i := 0; i < maxOTPAttempts; i++ {
+		valid, err := service.VerifyOTP(ctx, phone, "000000", driverLoginOTPPurpose)
+		assert.NoError(t, err)
+		assert.False(t, valid)
+	}
+
+	// Correct OTP is rejected while locked out
+	valid, err := service.VerifyOTP(ctx, phone, "123456", driverLoginOTPPurpose)
+
+	assert.ErrorIs(t, err, ErrTooManyOTPAttempts)
+	assert.False(t, valid)
+	otpRepo.AssertNotCalled(t, "VerifyOTP", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestOTPService_VerifyOTP_ConcurrentGuessesAreLimited(t *testing.T) {
+	redisClient, _ := testutil.NewFakeRedis()
+	otpRepo := new(MockOTPRepository)
+	service := NewOTPService(redisClient, otpRepo, 0, nil, false)
+
+	ctx := context.Background()
+	phone := "+8801700000000"
+
+	require.NoError(t, redisClient.Set(ctx, otpKey(driverLoginOTPPurpose, phone), "123456", 2*time.Minute).Err())
+
+	const guesses = 50
+	var (
+		wg      sync.WaitGroup
+		checked atomic.Int32
+		locked  atomic.Int32
+	)
+	for i := 0; i < guesses; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := service.VerifyOTP(ctx, phone, fmt.Sprintf("%06d", 900000+i), driverLoginOTPPurpose)
+			if errors.Is(err, ErrTooManyOTPAttempts) {
+				locked.Add(1)
+				return
+			}
+			assert.NoError(t, err)
+			checked.Add(1)
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(maxOTPAttempts), checked.Load(), "Only maxOTPAttempts guesses are checked however many arrive at once")
+	assert.Equal(t, int32(guesses-maxOTPAttempts), locked.Load())
+
+	valid, err := service.VerifyOTP(ctx, phone, "123456", driverLoginOTPPurpose)
+	assert.ErrorIs(t, err, ErrTooManyOTPAttempts)
+	assert.False(t, valid)
+}
+
+func TestOTPService_VerifyOTP_LockoutExpires(t *testing.T) {
+	redisClient, fakeRedis := testutil.NewFakeRedis()
+	otpRepo := new(MockOTPRepository)
+	service := NewOTPService(redisClient, otpRepo, 0, nil, false)
+
+	ctx := context.Background()
+	phone := "+8801700000000"
+
+	require.NoError(t, redisClient.Set(ctx, otpKey(driverLoginOTPPurpose, phone), "123456", 2*time.Minute).Err())
+	otpRepo.On("VerifyOTP", ctx, phone, "123456", driverLoginOTPPurpose).Return(true, nil)
+
+	for i := 0; i < maxOTPAttempts; i++ {
+		_, _ = service.VerifyOTP(ctx, phone, "000000", driverLoginOTPPurpose)
+	}
+
+	ttl, err := redisClient.TTL(ctx, otpAttemptsKey(phone)).Result()
+	require.NoError(t, err)
+	assert.InDelta(t, otpLockoutDuration.Seconds(), ttl.Seconds(), 2)
+
+	// Simulate the cooldown passing
+	fakeRedis.Expire(otpAttemptsKey(phone), 0)
+
+	valid, err := service.VerifyOTP(ctx, phone, "123456", driverLoginOTPPurpose)
+
+	assert.NoError(t, err)
+	assert.True(t, valid)
+}
+
+func TestOTPService_VerifyOTP_SuccessResetsAttempts(t *testing.T) {
+	redisClient, _ := testutil.NewFakeRedis()
+	otpRepo := new(MockOTPRepository)
+	service := NewOTPService(redisClient, otpRepo, 0, nil, false)
+
+	ctx := context.Background()
+	phone := "+8801700000000"
+
+	require.NoError(t, redisClient.Set(ctx, otpKey(driverLoginOTPPurpose, phone), "123456", 2*time.Minute).Err())
+	otpRepo.On("VerifyOTP", ctx, phone, "123456", driverLoginOTPPurpose).Return(true, nil)
+
+	for i := 0; i < maxOTPAttempts-1; i++ {
+		_, _ = service.VerifyOTP(ctx, phone, "000000", driverLoginOTPPurpose)
+	}
+
+	valid, err := service.VerifyOTP(ctx, phone, "123456", driverLoginOTPPurpose)
+	require.NoError(t, err)
+	assert.True(t, valid)
+
+	exists, err := redisClient.Exists(ctx, otpAttemptsKey(phone)).Result()
+	require.NoError(t, err)
+	assert.Zero(t, exists, "Attempt counter should be reset after a successful verification")
+}
+
+func TestOTPService_InvalidateOTP_ResetsAttempts(t *testing.T) {
+	redisClient, _ := testutil.NewFakeRedis()
+	otpRepo := new(MockOTPRepository)
+	service := NewOTPService(redisClient, otpRepo, 0, nil, false)
+
+	ctx := context.Background()
+	phone := "+8801700000000"
+
+	require.NoError(t, redisClient.Set(ctx, otpKey(driverLoginOTPPurpose, phone), "123456", 2*time.Minute).Err())
+	otpRepo.On("MarkExpired", ctx, phone).Return(nil)
+
+	for i := 0; i < maxOTPAttempts; i++ {
+		_, _ = service.VerifyOTP(ctx, phone, "000000", driverLoginOTPPurpose)
+	}
+
+	err := service.InvalidateOTP(ctx, phone)
+	require.NoError(t, err)
+
+	exists, err := redisClient.Exists(ctx, otpAttemptsKey(phone)).Result()
+	require.NoError(t, err)
+	assert.Zero(t, exists)
+}
+
+func TestOTPService_GetOTPHistory_PurposeFilter(t *testing.T) {
+	otpRepo := new(MockOTPRepository)
+	service := NewOTPService(nil, otpRepo, 0, nil, false)
+
+	ctx := context.Background()
+	phone := "+8801700000000"
+	now := time.Now()
+	verifiedAt := now.Add(-9 * time.Minute)
+	filter := repository.OTPFilter{Phone: phone, Purpose: "driver_login"}
+	records := []repository.OTPRecord{
+		{ID: 3, Phone: phone, Purpose: "driver_login", ExpiresAt: now.Add(time.Minute), CreatedAt: now.Add(-time.Minute)},
+		{ID: 2, Phone: phone, Purpose: "driver_login", ExpiresAt: now.Add(-5 * time.Minute), CreatedAt: now.Add(-7 * time.Minute)},
+		{ID: 1, Phone: phone, Purpose: "driver_login", IsVerified: true, VerifiedAt: &verifiedAt, ExpiresAt: now.Add(-8 * time.Minute), CreatedAt: now.Add(-10 * time.Minute)},
+	}
+	otpRepo.On("GetOTPHistory", ctx, filter, repository.Page{Limit: 20, Offset: 40}).Return(records, int64(43), nil)
+
+	page, err := service.GetOTPHistory(ctx, filter, repository.Page{Limit: 20, Offset: 40})
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(43), page.Total)
+	require.Len(t, page.OTPs, 3)
+	assert.False(t, page.OTPs[0].IsExpired, "Still pending")
+	assert.True(t, page.OTPs[1].IsExpired, "Past its expiry without being verified")
+	assert.False(t, page.OTPs[2].IsExpired, "Verified before it expired")
+	otpRepo.AssertExpectations(t)
+}
+
+func TestOTPService_GetOTPHistory_PhoneRequired(t *testing.T) {
+	otpRepo := new(MockOTPRepository)
+	service := NewOTPService(nil, otpRepo, 0, nil, false)
+
+	_, err := service.GetOTPHistory(context.Background(), repository.OTPFilter{Purpose: "driver_login"}, repository.Page{Limit: 20})
+
+	assert.ErrorIs(t, err, ErrPhoneRequired)
+	otpRepo.AssertNotCalled(t, "GetOTPHistory", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestOTPService_GetOTPHistory_InvalidDateRange(t *testing.T) {
+	otpRepo := new(MockOTPRepository)
+	service := NewOTPService(nil, otpRepo, 0, nil, false)
+
+	day := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+	_, err := service.GetOTPHistory(context.Background(), repository.OTPFilter{Phone: "+8801700000000", From: day, To: day}, repository.Page{Limit: 20})
+
+	assert.ErrorIs(t, err, ErrInvalidDateRange)
+}
+
+func TestOTPService_GenerateOTP(t *testing.T) {
+	service := NewOTPService(nil, nil, 0, nil, false)
+
+	const generated = 1000
+	seen := make(map[string]bool, generated)
+	for i := 0; i < generated; i++ {
+		otp := service.GenerateOTP()
+
+		require.Len(t, otp, otpDigits, "Codes are zero-padded to a fixed width")
+		n, err := strconv.Atoi(otp)
+		require.NoError(t, err, "Codes are numeric")
+		require.GreaterOrEqual(t, n, 0)
+		require.Less(t, n, 1000000)
+
+		seen[otp] = true
+	}
+
+	// 1000 draws from a million codes collide about once on average
+	assert.Greater(t, len(seen), generated-10, "Codes do not repeat in a pattern")
+}
+
+func TestOTPService_StartResendCooldown_RejectsWithinCooldown(t *testing.T) {
+	redisClient, _ := testutil.NewFakeRedis()
+	service := NewOTPService(redisClient, nil, 30*time.Second, nil, false)
+
+	ctx := context.Background()
+	phone := "+8801700000000"
+
+	require.NoError(t, service.StartResendCooldown(ctx, phone))
+
+	err := service.StartResendCooldown(ctx, phone)
+
+	assert.ErrorIs(t, err, ErrOTPCooldown)
+	var cooldownErr *OTPCooldownError
+	require.ErrorAs(t, err, &cooldownErr)
+	assert.InDelta(t, 30, cooldownErr.RemainingSeconds(), 1)
+	assert.Contains(t, err.Error(), "please wait before requesting another OTP")
+
+	// Other phones have their own cooldown
+	assert.NoError(t, service.StartResendCooldown(ctx, "+8801800000000"))
+}
+
+func TestOTPService_StartResendCooldown_AllowsAfterCooldown(t *testing.T) {
+	redisClient, fakeRedis := testutil.NewFakeRedis()
+	service := NewOTPService(redisClient, nil, 30*time.Second, nil, false)
+
+	ctx := context.Background()
+	phone := "+8801700000000"
+
+	require.NoError(t, service.StartResendCooldown(ctx, phone))
+
+	// Simulate the cooldown passing
+	fakeRedis.Expire(otpLastSentKey(phone), 0)
+
+	assert.NoError(t, service.StartResendCooldown(ctx, phone))
+	assert.ErrorIs(t, service.StartResendCooldown(ctx, phone), ErrOTPCooldown, "Sending again starts a new cooldown")
+}
+
+func TestOTPService_StartResendCooldown_Disabled(t *testing.T) {
+	service := NewOTPService(nil, nil, 0, nil, false)
+
+	assert.NoError(t, service.StartResendCooldown(context.Background(), "+8801700000000"))
+	assert.NoError(t, service.StartResendCooldown(context.Background(), "+8801700000000"))
+}
+
+func TestOTPService_SendOTP_TextsSavedCode(t *testing.T) {
+	redisClient, _ := testutil.NewFakeRedis()
+	otpRepo := new(MockOTPRepository)
+	sender := new(MockSMSSender)
+	service := NewOTPService(redisClient, otpRepo, 0, sender, false)
+
+	ctx := context.Background()
+	phone := "+8801700000000"
+
+	var savedOTP string
+	otpRepo.On("SaveOTP", ctx, phone, mock.AnythingOfType("string"), "driver_login", mock.Anything).
+		Run(func(args mock.Arguments) { savedOTP = args.String(2) }).
+		Return(nil)
+	sender.On("Send", ctx, phone, mock.AnythingOfType("string")).Return(nil)
+
+	require.NoError(t, service.SendOTP(ctx, phone, "driver_login"))
+
+	require.Len(t, savedOTP, otpDigits)
+	sender.AssertCalled(t, "Send", ctx, phone, "Your Carrybee driver login code is "+savedOTP+". It expires in 2 minutes.")
+}
+
+func TestOTPService_SendOTP_DevModeSkipsSending(t *testing.T) {
+	redisClient, _ := testutil.NewFakeRedis()
+	otpRepo := new(MockOTPRepository)
+	sender := new(MockSMSSender)
+	service := NewOTPService(redisClient, otpRepo, 0, sender, true)
+
+	ctx := context.Background()
+	phone := "+8801700000000"
+
+	otpRepo.On("SaveOTP", ctx, phone, devOTP, "driver_login", mock.Anything).Return(nil)
+
+	require.NoError(t, service.SendOTP(ctx, phone, "driver_login"))
+
+	otpRepo.AssertExpectations(t)
+	sender.AssertNotCalled(t, "Send", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestOTPService_SendOTP_DeliveryFailureLiftsCooldown(t *testing.T) {
+	redisClient, _ := testutil.NewFakeRedis()
+	otpRepo := new(MockOTPRepository)
+	sender := new(MockSMSSender)
+	service := NewOTPService(redisClient, otpRepo, 30*time.Second, sender, false)
+
+	ctx := context.Background()
+	phone := "+8801700000000"
+
+	otpRepo.On("SaveOTP", ctx, phone, mock.AnythingOfType("string"), "driver_login", mock.Anything).Return(nil)
+	sender.On("Send", ctx, phone, mock.AnythingOfType("string")).Return(errors.New("gateway down"))
+
+	require.NoError(t, service.StartResendCooldown(ctx, phone))
+	err := service.SendOTP(ctx, phone, "driver_login")
+
+	assert.ErrorIs(t, err, ErrOTPDeliveryFailed)
+	assert.NoError(t, service.StartResendCooldown(ctx, phone), "The user can retry right away")
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/repository"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/logger"
+)
+
+var (
+	ErrInvalidPromoCode    = domain.NewError(domain.ErrValidation, "invalid promo code")
+	ErrPromoCodeExpired    = domain.NewError(domain.ErrUnprocessable, "promo code has expired")
+	ErrPromoCodeUsageLimit = domain.NewError(domain.ErrUnprocessable, "promo code usage limit reached")
+)
+
+type PromoService struct {
+	repo repository.PromoRepository
+	now  func() time.Time
+}
+
+func NewPromoService(repo repository.PromoRepository) *PromoService {
+	return &PromoService{repo: repo, now: time.Now}
+}
+
+// Apply validates an active, non-expired code and returns fare after its discount
+func (s *PromoService) Apply(ctx context.Context, code string, fare float64) (float64, error) {
+	promo, err := s.getUsable(ctx, code)
+	if err != nil {
+		return 0, err
+	}
+	return promo.Apply(fare), nil
+}
+
+// Validate checks the code can be used by the customer, including their usage limit
+func (s *PromoService) Validate(ctx context.Context, code string, customerID int64) (*domain.PromoCode, error) {
+	promo, err := s.getUsable(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+
+	if promo.MaxUsesPerCustomer > 0 {
+		used, err := s.repo.CountRedemptions(ctx, promo.ID, customerID)
+		if err != nil {
+			logger.Error(ctx, fmt.Sprintf("Failed to count redemptions of promo code %s for customer %d: %v", promo.Code, customerID, err))
+			return nil, err
+		}
+		if used >= int64(promo.MaxUsesPerCustomer) {
+			return nil, ErrPromoCodeUsageLimit
+		}
+	}
+
+	return promo, nil
+}
+
+// Redeem applies the code to the ride's fare and records the customer's use of it
+// The code is validated again since it may have expired or been used up since the ride was requested
+func (s *PromoService) Redeem(ctx context.Context, code string, customerID, rideID int64, fare float64) (float64, error) {
+	promo, err := s.Validate(ctx, code, customerID)
+	if err != nil {
+		return 0, err
+	}
+
+	discounted := promo.Apply(fare)
+	redemption := &domain.PromoRedemption{
+		PromoCodeID: promo.ID,
+		CustomerID:  customerID,
+		RideID:      rideID,
+		Discount:    fare - discounted,
+		RedeemedAt:  s.now(),
+	}
+	if err := s.repo.CreateRedemption(ctx, redemption); err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to redeem promo code %s on ride %d: %v", promo.Code, rideID, err))
+		return 0, err
+	}
+
+	return discounted, nil
+}
+
+func (s *PromoService) getUsable(ctx context.Context, code string) (*domain.PromoCode, error) {
+	code = domain.NormalizePromoCode(code)
+	if code == "" {
+		return nil, ErrInvalidPromoCode
+	}
+
+	promo, err := s.repo.GetByCode(ctx, code)
+	if err != nil {
+		if errors.Is(err, repository.ErrPromoCodeNotFound) {
+			return nil, ErrInvalidPromoCode
+		}
+		logger.Error(ctx, fmt.Sprintf("Failed to get promo code %s: %v", code, err))
+		return nil, err
+	}
+
+	if !promo.Active {
+		return nil, ErrInvalidPromoCode
+	}
+	if !promo.IsUsable(s.now()) {
+		return nil, ErrPromoCodeExpired
+	}
+	return promo, nil
+}
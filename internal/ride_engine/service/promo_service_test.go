@@ -0,0 +1,288 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/repository"
+)
+
+// MockPromoRepository is a mock implementation of the promo repository
+type MockPromoRepository struct {
+	mock.Mock
+}
+
+func (m *MockPromoRepository) GetByCode(ctx context.Context, code string) (*domain.PromoCode, error) {
+	args := m.Called(ctx, code)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.PromoCode), args.Error(1)
+}
+
+func (m *MockPromoRepository) CountRedemptions(ctx context.Context, promoCodeID, customerID int64) (int64, error) {
+	args := m.Called(ctx, promoCodeID, customerID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockPromoRepository) CreateRedemption(ctx context.Context, redemption *domain.PromoRedemption) error {
+	args := m.Called(ctx, redemption)
+	return args.Error(0)
+}
+
+var testPromoNow = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func newTestPromoService(repo *MockPromoRepository) *PromoService {
+	service := NewPromoService(repo)
+	service.now = func() time.Time { return testPromoNow }
+	return service
+}
+
+// testPromoCode is a 20% code each customer can use twice
+func testPromoCode() *domain.PromoCode {
+	expiresAt := testPromoNow.Add(24 * time.Hour)
+	return &domain.PromoCode{
+		ID:                 1,
+		Code:               "SAVE20",
+		DiscountType:       domain.PromoDiscountPercentage,
+		DiscountValue:      20,
+		MaxUsesPerCustomer: 2,
+		Active:             true,
+		ExpiresAt:          &expiresAt,
+	}
+}
+
+func TestPromoService_Apply_Percentage(t *testing.T) {
+	repo := new(MockPromoRepository)
+	service := newTestPromoService(repo)
+
+	ctx := context.Background()
+	repo.On("GetByCode", ctx, "SAVE20").Return(testPromoCode(), nil)
+
+	// Codes are case insensitive
+	fare, err := service.Apply(ctx, " save20", 250)
+
+	require.NoError(t, err)
+	assert.Equal(t, 200.0, fare)
+}
+
+func TestPromoService_Apply_Flat(t *testing.T) {
+	repo := new(MockPromoRepository)
+	service := newTestPromoService(repo)
+
+	ctx := context.Background()
+	promo := testPromoCode()
+	promo.DiscountType = domain.PromoDiscountFlat
+	promo.DiscountValue = 75
+	repo.On("GetByCode", ctx, "SAVE20").Return(promo, nil)
+
+	fare, err := service.Apply(ctx, "SAVE20", 250)
+
+	require.NoError(t, err)
+	assert.Equal(t, 175.0, fare)
+}
+
+func TestPromoService_Apply_Expired(t *testing.T) {
+	repo := new(MockPromoRepository)
+	service := newTestPromoService(repo)
+
+	ctx := context.Background()
+	promo := testPromoCode()
+	expiredAt := testPromoNow.Add(-time.Minute)
+	promo.ExpiresAt = &expiredAt
+	repo.On("GetByCode", ctx, "SAVE20").Return(promo, nil)
+
+	_, err := service.Apply(ctx, "SAVE20", 250)
+
+	assert.ErrorIs(t, err, ErrPromoCodeExpired)
+}
+
+func TestPromoService_Apply_Inactive(t *testing.T) {
+	repo := new(MockPromoRepository)
+	service := newTestPromoService(repo)
+
+	ctx := context.Background()
+	promo := testPromoCode()
+	promo.Active = false
+	repo.On("GetByCode", ctx, "SAVE20").Return(promo, nil)
+
+	_, err := service.Apply(ctx, "SAVE20", 250)
+
+	assert.ErrorIs(t, err, ErrInvalidPromoCode)
+}
+
+func TestPromoService_Apply_Unknown(t *testing.T) {
+	repo := new(MockPromoRepository)
+	service := newTestPromoService(repo)
+
+	ctx := context.Background()
+	repo.On("GetByCode", ctx, "NOPE").Return(nil, repository.ErrPromoCodeNotFound)
+
+	_, err := service.Apply(ctx, "nope", 250)
+
+	assert.ErrorIs(t, err, ErrInvalidPromoCode)
+}
+
+func TestPromoService_Validate_UsageLimit(t *testing.T) {
+	repo := new(MockPromoRepository)
+	service := newTestPromoService(repo)
+
+	ctx := context.Background()
+	repo.On("GetByCode", ctx, "SAVE20").Return(testPromoCode(), nil)
+	repo.On("CountRedemptions", ctx, int64(1), int64(123)).Return(int64(1), nil).Once()
+	repo.On("CountRedemptions", ctx, int64(1), int64(123)).Return(int64(2), nil).Once()
+
+	promo, err := service.Validate(ctx, "SAVE20", 123)
+	require.NoError(t, err)
+	assert.Equal(t, "SAVE20", promo.Code)
+
+	_, err = service.Validate(ctx, "SAVE20", 123)
+	assert.ErrorIs(t, err, ErrPromoCodeUsageLimit)
+	repo.AssertExpectations(t)
+}
+
+func TestPromoService_Validate_Unlimited(t *testing.T) {
+	repo := new(MockPromoRepository)
+	service := newTestPromoService(repo)
+
+	ctx := context.Background()
+	promo := testPromoCode()
+	promo.MaxUsesPerCustomer = 0
+	repo.On("GetByCode", ctx, "SAVE20").Return(promo, nil)
+
+	_, err := service.Validate(ctx, "SAVE20", 123)
+
+	require.NoError(t, err)
+	repo.AssertNotCalled(t, "CountRedemptions", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestPromoService_Redeem(t *testing.T) {
+	repo := new(MockPromoRepository)
+	service := newTestPromoService(repo)
+
+	ctx := context.Background()
+	repo.On("GetByCode", ctx, "SAVE20").Return(testPromoCode(), nil)
+	repo.On("CountRedemptions", ctx, int64(1), int64(123)).Return(int64(0), nil)
+	repo.On("CreateRedemption", ctx, mock.MatchedBy(func(r *domain.PromoRedemption) bool {
+		return r.PromoCodeID == 1 && r.CustomerID == 123 && r.RideID == 9 && r.Discount == 50 && r.RedeemedAt.Equal(testPromoNow)
+	})).Return(nil)
+
+	fare, err := service.Redeem(ctx, "SAVE20", 123, 9, 250)
+
+	require.NoError(t, err)
+	assert.Equal(t, 200.0, fare)
+	repo.AssertExpectations(t)
+}
+
+func TestRideService_RequestRide_WithPromoCode(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	locationRepo := new(MockLocationRepository)
+	promoRepo := new(MockPromoRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), locationRepo)
+	service.promoService = newTestPromoService(promoRepo)
+	expectNoSurge(rideRepo, locationRepo)
+	expectNoActiveRide(rideRepo)
+
+	ctx := context.Background()
+	promoRepo.On("GetByCode", ctx, "SAVE20").Return(testPromoCode(), nil)
+	promoRepo.On("CountRedemptions", ctx, int64(1), int64(123)).Return(int64(0), nil)
+	rideRepo.On("Create", ctx, mock.AnythingOfType("*domain.Ride")).Return(nil)
+
+	ride, err := service.RequestRide(ctx, 123, domain.RideTypeEconomy, 23.8100, 90.4120, 23.7509, 90.3761, nil, "save20", "")
+
+	require.NoError(t, err)
+	assert.Equal(t, "SAVE20", ride.PromoCode)
+	assert.Nil(t, ride.Discount)
+}
+
+func TestRideService_RequestRide_PromoCodeUsedUp(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	promoRepo := new(MockPromoRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+	service.promoService = newTestPromoService(promoRepo)
+	expectNoActiveRide(rideRepo)
+
+	ctx := context.Background()
+	promoRepo.On("GetByCode", ctx, "SAVE20").Return(testPromoCode(), nil)
+	promoRepo.On("CountRedemptions", ctx, int64(1), int64(123)).Return(int64(2), nil)
+
+	ride, err := service.RequestRide(ctx, 123, domain.RideTypeEconomy, 23.8100, 90.4120, 23.7509, 90.3761, nil, "SAVE20", "")
+
+	assert.ErrorIs(t, err, ErrPromoCodeUsageLimit)
+	assert.Nil(t, ride)
+	rideRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func startedPromoRide() *domain.Ride {
+	driverID := int64(456)
+	startedAt := time.Now().Add(-15 * time.Minute)
+	return &domain.Ride{
+		ID:          9,
+		CustomerID:  123,
+		DriverID:    &driverID,
+		PickupLat:   23.8100,
+		PickupLng:   90.4120,
+		DropoffLat:  23.7509,
+		DropoffLng:  90.3761,
+		Status:      domain.RideStatusStarted,
+		PromoCode:   "SAVE20",
+		RequestedAt: startedAt.Add(-5 * time.Minute),
+		StartedAt:   &startedAt,
+	}
+}
+
+func TestRideService_CompleteRide_AppliesPromoCode(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	locationRepo := new(MockLocationRepository)
+	promoRepo := new(MockPromoRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), locationRepo)
+	service.promoService = newTestPromoService(promoRepo)
+
+	ctx := context.Background()
+	ride := startedPromoRide()
+	rideRepo.On("GetByID", ctx, int64(9)).Return(ride, nil)
+	locationRepo.On("GetRideLocationHistory", ctx, int64(9)).Return(nil, nil)
+	promoRepo.On("GetByCode", ctx, "SAVE20").Return(testPromoCode(), nil)
+	promoRepo.On("CountRedemptions", ctx, int64(1), int64(123)).Return(int64(1), nil)
+	promoRepo.On("CreateRedemption", ctx, mock.AnythingOfType("*domain.PromoRedemption")).Return(nil)
+	rideRepo.On("Update", ctx, ride, mock.Anything).Return(nil)
+
+	err := service.CompleteRide(ctx, 9, 456)
+
+	require.NoError(t, err)
+	require.NotNil(t, ride.Fare)
+	require.NotNil(t, ride.Discount)
+	// 20% off, so the discount is a quarter of what the customer pays
+	assert.InDelta(t, *ride.Fare/4, *ride.Discount, 0.01)
+	promoRepo.AssertExpectations(t)
+}
+
+func TestRideService_CompleteRide_PromoCodeUsedUp(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	locationRepo := new(MockLocationRepository)
+	promoRepo := new(MockPromoRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), locationRepo)
+	service.promoService = newTestPromoService(promoRepo)
+
+	ctx := context.Background()
+	ride := startedPromoRide()
+	rideRepo.On("GetByID", ctx, int64(9)).Return(ride, nil)
+	locationRepo.On("GetRideLocationHistory", ctx, int64(9)).Return(nil, nil)
+	promoRepo.On("GetByCode", ctx, "SAVE20").Return(testPromoCode(), nil)
+	promoRepo.On("CountRedemptions", ctx, int64(1), int64(123)).Return(int64(2), nil)
+	rideRepo.On("Update", ctx, ride, mock.Anything).Return(nil)
+
+	err := service.CompleteRide(ctx, 9, 456)
+
+	// The ride still completes, at the full fare
+	require.NoError(t, err)
+	assert.Equal(t, domain.RideStatusCompleted, ride.Status)
+	assert.NotNil(t, ride.Fare)
+	assert.Nil(t, ride.Discount)
+	promoRepo.AssertNotCalled(t, "CreateRedemption", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/repository"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/config"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/logger"
+)
+
+// pushContent is the title and body shown for each notification type
+var pushContent = map[string]PushContent{
+	NotificationRideRequest:    {Title: "New ride request", Body: "A customer near you requested a ride"},
+	NotificationDriverAssigned: {Title: "Driver on the way", Body: "A driver accepted your ride"},
+	NotificationRideStarted:    {Title: "Ride started", Body: "Your ride has started"},
+	NotificationRideCompleted:  {Title: "Ride completed", Body: "You have arrived, thanks for riding with us"},
+	NotificationRideExpired:    {Title: "No driver found", Body: "No driver accepted your ride, please request it again"},
+}
+
+// PushMessage is the FCM style request sent to the push provider
+type PushMessage struct {
+	RegistrationIDs []string         `json:"registration_ids"`
+	Notification    PushContent      `json:"notification"`
+	Data            RideNotification `json:"data"`
+}
+
+// PushContent is what the device displays for a push notification
+type PushContent struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+// PushNotifier sends notifications to every device the user registered a token for
+// through an FCM style HTTP provider, users without a device token are skipped
+type PushNotifier struct {
+	providerURL string
+	serverKey   string
+	tokens      repository.DeviceTokenRepository
+	client      *http.Client
+}
+
+func NewPushNotifier(cfg config.PushConfig, tokens repository.DeviceTokenRepository) *PushNotifier {
+	return &PushNotifier{
+		providerURL: cfg.ProviderURL,
+		serverKey:   cfg.ServerKey,
+		tokens:      tokens,
+		client:      &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+// NotifyDriverRideRequest pushes the ride offer to the driver's devices
+func (n *PushNotifier) NotifyDriverRideRequest(ctx context.Context, ride *domain.Ride, driverID int64) error {
+	return n.send(ctx, "driver", driverID, newRideNotification(NotificationRideRequest, ride))
+}
+
+// NotifyCustomerDriverAssigned pushes the accepted ride to the customer's devices
+func (n *PushNotifier) NotifyCustomerDriverAssigned(ctx context.Context, ride *domain.Ride) error {
+	return n.send(ctx, "customer", ride.CustomerID, newRideNotification(NotificationDriverAssigned, ride))
+}
+
+// NotifyCustomerRideStarted pushes the started ride to the customer's devices
+func (n *PushNotifier) NotifyCustomerRideStarted(ctx context.Context, ride *domain.Ride) error {
+	return n.send(ctx, "customer", ride.CustomerID, newRideNotification(NotificationRideStarted, ride))
+}
+
+// NotifyCustomerRideCompleted pushes the completed ride to the customer's devices
+func (n *PushNotifier) NotifyCustomerRideCompleted(ctx context.Context, ride *domain.Ride) error {
+	return n.send(ctx, "customer", ride.CustomerID, newRideNotification(NotificationRideCompleted, ride))
+}
+
+// NotifyCustomerRideExpired pushes the expired ride to the customer's devices
+func (n *PushNotifier) NotifyCustomerRideExpired(ctx context.Context, ride *domain.Ride) error {
+	return n.send(ctx, "customer", ride.CustomerID, newRideNotification(NotificationRideExpired, ride))
+}
+
+func (n *PushNotifier) send(ctx context.Context, userRole string, userID int64, notification RideNotification) error {
+	tokens, err := n.tokens.ListTokens(ctx, userRole, userID)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to get device tokens of %s %d: %v", userRole, userID, err))
+		return err
+	}
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(PushMessage{
+		RegistrationIDs: tokens,
+		Notification:    pushContent[notification.Type],
+		Data:            notification,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.providerURL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "key="+n.serverKey)
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to push %s notification for ride %d to %s %d: %v", notification.Type, notification.RideID, userRole, userID, err))
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		err := fmt.Errorf("push provider responded with status %d", resp.StatusCode)
+		logger.Error(ctx, fmt.Sprintf("Failed to push %s notification for ride %d to %s %d: %v", notification.Type, notification.RideID, userRole, userID, err))
+		return err
+	}
+
+	return nil
+}
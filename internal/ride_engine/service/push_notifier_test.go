@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/config"
+)
+
+func newTestPushNotifier(url string, tokens *MockDeviceTokenRepository) *PushNotifier {
+	return NewPushNotifier(config.PushConfig{ProviderURL: url, ServerKey: "test-key", Timeout: time.Second}, tokens)
+}
+
+func TestPushNotifier_RideRequestSentToDriverDevices(t *testing.T) {
+	var received PushMessage
+	var authorization string
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer provider.Close()
+
+	tokens := new(MockDeviceTokenRepository)
+	notifier := newTestPushNotifier(provider.URL, tokens)
+
+	ctx := context.Background()
+	tokens.On("ListTokens", ctx, "driver", int64(456)).Return([]string{"token-1", "token-2"}, nil)
+
+	ride := &domain.Ride{ID: 1, CustomerID: 123, Status: domain.RideStatusRequested}
+	err := notifier.NotifyDriverRideRequest(ctx, ride, 456)
+
+	require.NoError(t, err)
+	assert.Equal(t, "key=test-key", authorization)
+	assert.Equal(t, []string{"token-1", "token-2"}, received.RegistrationIDs)
+	assert.Equal(t, "New ride request", received.Notification.Title)
+	assert.Equal(t, NotificationRideRequest, received.Data.Type)
+	assert.Equal(t, int64(1), received.Data.RideID)
+}
+
+func TestPushNotifier_CompletedRideCarriesFare(t *testing.T) {
+	var received PushMessage
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+	}))
+	defer provider.Close()
+
+	tokens := new(MockDeviceTokenRepository)
+	notifier := newTestPushNotifier(provider.URL, tokens)
+
+	ctx := context.Background()
+	tokens.On("ListTokens", ctx, "customer", int64(123)).Return([]string{"token-1"}, nil)
+
+	fare := 170.5
+	ride := &domain.Ride{ID: 1, CustomerID: 123, Status: domain.RideStatusCompleted, Fare: &fare}
+	err := notifier.NotifyCustomerRideCompleted(ctx, ride)
+
+	require.NoError(t, err)
+	assert.Equal(t, NotificationRideCompleted, received.Data.Type)
+	require.NotNil(t, received.Data.Fare)
+	assert.Equal(t, 170.5, *received.Data.Fare)
+}
+
+func TestPushNotifier_ProviderErrorReturned(t *testing.T) {
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer provider.Close()
+
+	tokens := new(MockDeviceTokenRepository)
+	notifier := newTestPushNotifier(provider.URL, tokens)
+
+	ctx := context.Background()
+	tokens.On("ListTokens", ctx, "customer", int64(123)).Return([]string{"token-1"}, nil)
+
+	err := notifier.NotifyCustomerRideStarted(ctx, &domain.Ride{ID: 1, CustomerID: 123, Status: domain.RideStatusStarted})
+
+	assert.Error(t, err)
+}
+
+func TestPushNotifier_NoDeviceTokens(t *testing.T) {
+	called := false
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer provider.Close()
+
+	tokens := new(MockDeviceTokenRepository)
+	notifier := newTestPushNotifier(provider.URL, tokens)
+
+	ctx := context.Background()
+	tokens.On("ListTokens", ctx, "customer", int64(123)).Return([]string{}, nil)
+
+	err := notifier.NotifyCustomerDriverAssigned(ctx, &domain.Ride{ID: 1, CustomerID: 123, Status: domain.RideStatusAccepted})
+
+	require.NoError(t, err)
+	assert.False(t, called)
+}
+
+func TestMultiNotifier_FailingNotifierDoesNotStopOthers(t *testing.T) {
+	failing := new(MockNotifier)
+	working := new(MockNotifier)
+	notifier := MultiNotifier{failing, working}
+
+	ctx := context.Background()
+	ride := &domain.Ride{ID: 1, CustomerID: 123, Status: domain.RideStatusAccepted}
+	failing.On("NotifyCustomerDriverAssigned", ctx, ride).Return(assert.AnError)
+	working.On("NotifyCustomerDriverAssigned", ctx, ride).Return(nil)
+
+	err := notifier.NotifyCustomerDriverAssigned(ctx, ride)
+
+	assert.ErrorIs(t, err, assert.AnError)
+	working.AssertExpectations(t)
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/repository"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/logger"
+)
+
+var (
+	ErrRideNotCompleted   = domain.NewError(domain.ErrValidation, "only completed rides can be rated")
+	ErrNotRideParticipant = domain.NewError(domain.ErrForbidden, "forbidden: you did not take part in this ride")
+	ErrRideAlreadyRated   = domain.NewError(domain.ErrConflict, "you have already rated this ride")
+)
+
+type RatingService struct {
+	rideRepo   repository.RideRepository
+	ratingRepo repository.RatingRepository
+}
+
+func NewRatingService(rideRepo repository.RideRepository, ratingRepo repository.RatingRepository) *RatingService {
+	return &RatingService{
+		rideRepo:   rideRepo,
+		ratingRepo: ratingRepo,
+	}
+}
+
+// RateRide records the rater's feedback on the other participant of a completed ride
+// Customers rate the driver and drivers rate the customer, each side can rate a ride once
+func (s *RatingService) RateRide(ctx context.Context, rideID, raterID int64, raterRole string, stars int, comment string) (*domain.Rating, error) {
+	rating := &domain.Rating{
+		RideID:    rideID,
+		RaterID:   raterID,
+		RaterRole: raterRole,
+		Stars:     stars,
+		Comment:   comment,
+		CreatedAt: time.Now(),
+	}
+	if err := domain.ValidateRating(rating); err != nil {
+		logger.Error(ctx, fmt.Sprintf("invalid rating for ride %d: %v", rideID, err))
+		return nil, err
+	}
+
+	ride, err := s.rideRepo.GetByID(ctx, rideID)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to get ride %d: %v", rideID, err))
+		return nil, ErrRideNotFound
+	}
+
+	switch {
+	case raterRole == "customer" && ride.CustomerID == raterID && ride.DriverID != nil:
+		rating.RateeID = *ride.DriverID
+		rating.RateeRole = "driver"
+	case raterRole == "driver" && ride.DriverID != nil && *ride.DriverID == raterID:
+		rating.RateeID = ride.CustomerID
+		rating.RateeRole = "customer"
+	default:
+		logger.Error(ctx, fmt.Sprintf("%s %d tried to rate ride %d they did not take part in", raterRole, raterID, rideID))
+		return nil, ErrNotRideParticipant
+	}
+
+	if ride.Status != domain.RideStatusCompleted {
+		logger.Error(ctx, fmt.Sprintf("ride %d is %s, not completed", rideID, ride.Status))
+		return nil, ErrRideNotCompleted
+	}
+
+	if err := s.ratingRepo.Create(ctx, rating); err != nil {
+		if errors.Is(err, repository.ErrRatingAlreadyExists) {
+			return nil, ErrRideAlreadyRated
+		}
+		logger.Error(ctx, fmt.Sprintf("Failed to save rating for ride %d: %v", rideID, err))
+		return nil, err
+	}
+
+	return rating, nil
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/repository"
+)
+
+// MockRatingRepository is a mock implementation of the rating repository
+type MockRatingRepository struct {
+	mock.Mock
+}
+
+func (m *MockRatingRepository) Create(ctx context.Context, rating *domain.Rating) error {
+	args := m.Called(ctx, rating)
+	return args.Error(0)
+}
+
+func (m *MockRatingRepository) GetAverageForRatee(ctx context.Context, rateeID int64, rateeRole string) (float64, int64, error) {
+	args := m.Called(ctx, rateeID, rateeRole)
+	return args.Get(0).(float64), args.Get(1).(int64), args.Error(2)
+}
+
+func newCompletedRide(customerID, driverID int64) *domain.Ride {
+	return &domain.Ride{
+		ID:         1,
+		CustomerID: customerID,
+		DriverID:   &driverID,
+		Status:     domain.RideStatusCompleted,
+	}
+}
+
+func TestRatingService_RateRide_CustomerRatesDriver(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	ratingRepo := new(MockRatingRepository)
+	service := NewRatingService(rideRepo, ratingRepo)
+
+	ctx := context.Background()
+
+	rideRepo.On("GetByID", ctx, int64(1)).Return(newCompletedRide(123, 456), nil)
+	ratingRepo.On("Create", ctx, mock.MatchedBy(func(r *domain.Rating) bool {
+		return r.RideID == 1 && r.RaterID == 123 && r.RaterRole == "customer" &&
+			r.RateeID == 456 && r.RateeRole == "driver" && r.Stars == 5
+	})).Return(nil)
+
+	rating, err := service.RateRide(ctx, 1, 123, "customer", 5, "Great ride")
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(456), rating.RateeID)
+	assert.Equal(t, "Great ride", rating.Comment)
+	ratingRepo.AssertExpectations(t)
+}
+
+func TestRatingService_RateRide_DriverRatesCustomer(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	ratingRepo := new(MockRatingRepository)
+	service := NewRatingService(rideRepo, ratingRepo)
+
+	ctx := context.Background()
+
+	rideRepo.On("GetByID", ctx, int64(1)).Return(newCompletedRide(123, 456), nil)
+	ratingRepo.On("Create", ctx, mock.Anything).Return(nil)
+
+	rating, err := service.RateRide(ctx, 1, 456, "driver", 4, "")
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(123), rating.RateeID)
+	assert.Equal(t, "customer", rating.RateeRole)
+}
+
+func TestRatingService_RateRide_InvalidStars(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	ratingRepo := new(MockRatingRepository)
+	service := NewRatingService(rideRepo, ratingRepo)
+
+	ctx := context.Background()
+
+	for _, stars := range []int{0, -1, 6} {
+		rating, err := service.RateRide(ctx, 1, 123, "customer", stars, "")
+
+		assert.ErrorIs(t, err, domain.ErrInvalidRatingStars, "stars=%d", stars)
+		assert.Nil(t, rating)
+	}
+	rideRepo.AssertNotCalled(t, "GetByID", mock.Anything, mock.Anything)
+	ratingRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestRatingService_RateRide_CommentTooLong(t *testing.T) {
+	service := NewRatingService(new(MockRideRepository), new(MockRatingRepository))
+
+	_, err := service.RateRide(context.Background(), 1, 123, "customer", 5, strings.Repeat("a", domain.MaxRatingCommentLength+1))
+
+	assert.ErrorIs(t, err, domain.ErrInvalidRatingComment)
+}
+
+func TestRatingService_RateRide_NotCompleted(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	ratingRepo := new(MockRatingRepository)
+	service := NewRatingService(rideRepo, ratingRepo)
+
+	ctx := context.Background()
+	ride := newCompletedRide(123, 456)
+	ride.Status = domain.RideStatusStarted
+
+	rideRepo.On("GetByID", ctx, int64(1)).Return(ride, nil)
+
+	rating, err := service.RateRide(ctx, 1, 123, "customer", 5, "")
+
+	assert.ErrorIs(t, err, ErrRideNotCompleted)
+	assert.Nil(t, rating)
+	ratingRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestRatingService_RateRide_NotParticipant(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		raterID   int64
+		raterRole string
+	}{
+		{name: "other customer", raterID: 999, raterRole: "customer"},
+		{name: "other driver", raterID: 999, raterRole: "driver"},
+		{name: "customer id used as driver", raterID: 123, raterRole: "driver"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rideRepo := new(MockRideRepository)
+			ratingRepo := new(MockRatingRepository)
+			service := NewRatingService(rideRepo, ratingRepo)
+
+			rideRepo.On("GetByID", ctx, int64(1)).Return(newCompletedRide(123, 456), nil)
+
+			rating, err := service.RateRide(ctx, 1, tt.raterID, tt.raterRole, 5, "")
+
+			assert.ErrorIs(t, err, ErrNotRideParticipant)
+			assert.Nil(t, rating)
+			ratingRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestRatingService_RateRide_AlreadyRated(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	ratingRepo := new(MockRatingRepository)
+	service := NewRatingService(rideRepo, ratingRepo)
+
+	ctx := context.Background()
+
+	rideRepo.On("GetByID", ctx, int64(1)).Return(newCompletedRide(123, 456), nil)
+	ratingRepo.On("Create", ctx, mock.Anything).Return(repository.ErrRatingAlreadyExists)
+
+	_, err := service.RateRide(ctx, 1, 123, "customer", 5, "")
+
+	assert.ErrorIs(t, err, ErrRideAlreadyRated)
+}
+
+func TestRatingService_RateRide_RideNotFound(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := NewRatingService(rideRepo, new(MockRatingRepository))
+
+	ctx := context.Background()
+
+	rideRepo.On("GetByID", ctx, int64(1)).Return(nil, errors.New("ride not found"))
+
+	_, err := service.RateRide(ctx, 1, 123, "customer", 5, "")
+
+	assert.ErrorIs(t, err, ErrRideNotFound)
+}
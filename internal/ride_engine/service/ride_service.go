@@ -4,80 +4,440 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
+	"strconv"
+	"strings"
 	"time"
 	"vcs.technonext.com/carrybee/ride_engine/pkg/logger"
 
+	"github.com/redis/go-redis/v9"
 	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
-	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/repository/mongodb"
-	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/repository/postgres"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/repository"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/metrics"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/pagination"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/utils"
 )
 
 // RideWithCustomerInfo contains ride details along with customer information
 type RideWithCustomerInfo struct {
-	RideID             int64   `json:"ride_id"`
-	CustomerID         int64   `json:"customer_id"`
-	CustomerName       string  `json:"customer_name"`
-	CustomerPhone      string  `json:"customer_phone"`
-	CustomerCurrentLat float64 `json:"customer_current_lat"`
-	CustomerCurrentLng float64 `json:"customer_current_lng"`
-	PickupLat          float64 `json:"pickup_lat"`
-	PickupLng          float64 `json:"pickup_lng"`
-	DropoffLat         float64 `json:"dropoff_lat"`
-	DropoffLng         float64 `json:"dropoff_lng"`
-	RequestedAt        string  `json:"requested_at"`
-	Status             string  `json:"status"`
-	DistanceFromDriver float64 `json:"distance_from_driver,omitempty"`
+	RideID             int64             `json:"ride_id"`
+	CustomerID         int64             `json:"customer_id"`
+	CustomerName       string            `json:"customer_name"`
+	CustomerPhone      string            `json:"customer_phone"`
+	CustomerCurrentLat float64           `json:"customer_current_lat"`
+	CustomerCurrentLng float64           `json:"customer_current_lng"`
+	PickupLat          float64           `json:"pickup_lat"`
+	PickupLng          float64           `json:"pickup_lng"`
+	DropoffLat         float64           `json:"dropoff_lat"`
+	DropoffLng         float64           `json:"dropoff_lng"`
+	Waypoints          []domain.Location `json:"waypoints,omitempty"`
+	RequestedAt        string            `json:"requested_at"`
+	Status             string            `json:"status"`
+	DistanceFromDriver float64           `json:"distance_from_driver,omitempty"`
 }
 
+var (
+	ErrDriverNotOnline       = domain.NewError(domain.ErrConflict, "driver must be online to accept rides")
+	ErrRideNotFound          = repository.ErrRideNotFound
+	ErrRideForbidden         = domain.NewError(domain.ErrForbidden, "forbidden: this ride belongs to another customer")
+	ErrRideCannotBeCancelled = domain.NewError(domain.ErrValidation, "ride cannot be cancelled")
+	ErrRideNotOffered        = domain.NewError(domain.ErrConflict, "ride is not offered to this driver")
+	ErrRideAlreadyAccepted   = domain.NewError(domain.ErrConflict, "ride was already accepted by another driver")
+	ErrOutsideServiceArea    = domain.NewError(domain.ErrUnprocessable, "outside service area")
+	ErrRideCannotBeAccepted  = domain.NewError(domain.ErrConflict, "ride cannot be accepted")
+	ErrRideCannotBeStarted   = domain.NewError(domain.ErrConflict, "ride cannot be started")
+	ErrNotRideDriver         = domain.NewError(domain.ErrForbidden, "forbidden: this ride is not assigned to you")
+	ErrRideNotAbandonable    = domain.NewError(domain.ErrConflict, "only the driver of an accepted ride that has not started can abandon it")
+	ErrRideNoDriver          = domain.NewError(domain.ErrConflict, "ride has no assigned driver")
+	ErrRideNotInProgress     = domain.NewError(domain.ErrConflict, "ride is not in progress")
+	ErrDriverLocationMissing = domain.NewError(domain.ErrNotFound, "driver location not found")
+	ErrNoRidesForRole        = domain.NewError(domain.ErrForbidden, "forbidden: only customers and drivers have rides")
+	ErrActiveRideExists      = domain.NewError(domain.ErrConflict, "you already have an active ride")
+
+	ErrInvalidNearbyFreshness = domain.NewError(domain.ErrValidation, fmt.Sprintf("freshness must be between 0 and %s", MaxNearbyFreshness))
+
+	ErrInvalidIdempotencyKey       = domain.NewError(domain.ErrValidation, fmt.Sprintf("idempotency key must be at most %d characters", MaxIdempotencyKeyLength))
+	ErrIdempotencyKeyReused        = domain.NewError(domain.ErrUnprocessable, "idempotency key was already used by another customer")
+	ErrIdempotentRequestInProgress = domain.NewError(domain.ErrConflict, "a ride request with this idempotency key is still in progress")
+)
+
 type RideService struct {
-	rideRepoMongo   *mongodb.RideMongoRepository
-	locationService *LocationService
-	driverService   *DriverService
-	customerRepo    *postgres.CustomerPostgresRepository
+	rideRepo             repository.RideRepository
+	eventRepo            repository.RideEventRepository // status changes are recorded in the ride repository's transaction
+	locationService      *LocationService
+	driverService        *DriverService
+	fareService          *FareService
+	surgeService         *SurgeService
+	dispatchService      *DispatchService
+	savedLocationService *SavedLocationService
+	geofenceService      *GeofenceService // nil when pickups are not restricted to geofences
+	promoService         *PromoService
+	customerRepo         repository.CustomerRepository
+	averageSpeedKmh      float64
+	statusStreamInterval time.Duration
+	nearbyFreshness      time.Duration
+	metrics              *metrics.RideMetrics
+	redis                *redis.Client
+	idempotencyKeyTTL    time.Duration
+	requireArrival       bool // drivers must mark arrived before starting a ride
+	notifier             Notifier
+	nearbyByGeohash      bool // nearby polling prefilters rides by pickup geohash instead of a geospatial query
 }
 
+// MaxIdempotencyKeyLength is the longest Idempotency-Key accepted
+const MaxIdempotencyKeyLength = 255
+
+// MaxNearbyFreshness is the longest a ride request stays visible to polling drivers after its last update
+const MaxNearbyFreshness = 30 * time.Minute
+
 func NewRideService(
-	rideRepoMongo *mongodb.RideMongoRepository,
+	rideRepo repository.RideRepository,
+	eventRepo repository.RideEventRepository,
 	locationService *LocationService,
 	driverService *DriverService,
-	customerRepo *postgres.CustomerPostgresRepository,
+	fareService *FareService,
+	surgeService *SurgeService,
+	dispatchService *DispatchService,
+	savedLocationService *SavedLocationService,
+	geofenceService *GeofenceService,
+	promoService *PromoService,
+	customerRepo repository.CustomerRepository,
+	averageSpeedKmh float64,
+	statusStreamInterval time.Duration,
+	nearbyFreshness time.Duration,
+	rideMetrics *metrics.RideMetrics,
+	redisClient *redis.Client,
+	idempotencyKeyTTL time.Duration,
+	requireArrival bool,
+	notifier Notifier,
+	nearbyByGeohash bool,
 ) *RideService {
+	if notifier == nil {
+		notifier = NoopNotifier{}
+	}
 	return &RideService{
-		rideRepoMongo:   rideRepoMongo,
-		locationService: locationService,
-		driverService:   driverService,
-		customerRepo:    customerRepo,
+		rideRepo:             rideRepo,
+		eventRepo:            eventRepo,
+		locationService:      locationService,
+		driverService:        driverService,
+		fareService:          fareService,
+		surgeService:         surgeService,
+		dispatchService:      dispatchService,
+		savedLocationService: savedLocationService,
+		geofenceService:      geofenceService,
+		promoService:         promoService,
+		customerRepo:         customerRepo,
+		averageSpeedKmh:      averageSpeedKmh,
+		statusStreamInterval: statusStreamInterval,
+		nearbyFreshness:      nearbyFreshness,
+		metrics:              rideMetrics,
+		redis:                redisClient,
+		idempotencyKeyTTL:    idempotencyKeyTTL,
+		requireArrival:       requireArrival,
+		notifier:             notifier,
+		nearbyByGeohash:      nearbyByGeohash,
 	}
 }
 
 // RequestRide creates a new ride request
-func (s *RideService) RequestRide(ctx context.Context, customerID int64, pickupLat, pickupLng, dropoffLat, dropoffLng float64) (*domain.Ride, error) {
+// An empty ride type defaults to economy, waypoints are optional stops visited in order
+// An optional promoCode is validated now and its discount applied to the final fare when the ride completes
+// A non-empty idempotencyKey makes retries of the same request return the ride created the first time
+// Returns ErrActiveRideExists while the customer has a ride that has not completed or been cancelled
+func (s *RideService) RequestRide(ctx context.Context, customerID int64, rideType domain.RideType, pickupLat, pickupLng, dropoffLat, dropoffLng float64, waypoints []domain.Location, promoCode, idempotencyKey string) (*domain.Ride, error) {
+	return s.withIdempotencyKey(ctx, customerID, idempotencyKey, func() (*domain.Ride, error) {
+		return s.requestRide(ctx, customerID, rideType, pickupLat, pickupLng, dropoffLat, dropoffLng, waypoints, promoCode)
+	})
+}
+
+func (s *RideService) requestRide(ctx context.Context, customerID int64, rideType domain.RideType, pickupLat, pickupLng, dropoffLat, dropoffLng float64, waypoints []domain.Location, promoCode string) (*domain.Ride, error) {
+	rideType, err := s.validateRideRequest(ctx, customerID, rideType, pickupLat, pickupLng, dropoffLat, dropoffLng, waypoints)
+	if err != nil {
+		return nil, err
+	}
+
+	// A customer takes one ride at a time, the next can be requested once it completes or is cancelled
+	// Create enforces it too, for requests racing past this check
+	active, err := s.rideRepo.GetActiveRideByCustomerID(ctx, customerID)
+	if err == nil {
+		logger.Error(ctx, fmt.Sprintf("Customer %d requested a ride while ride %d is %s", customerID, active.ID, active.Status))
+		return nil, ErrActiveRideExists
+	}
+	if !errors.Is(err, repository.ErrRideNotFound) {
+		logger.Error(ctx, fmt.Sprintf("Failed to get active ride for customer %d: %v", customerID, err))
+		return nil, err
+	}
+
+	if promoCode != "" {
+		promo, err := s.promoService.Validate(ctx, promoCode, customerID)
+		if err != nil {
+			logger.Error(ctx, fmt.Sprintf("Promo code %q rejected for customer %d: %v", promoCode, customerID, err))
+			return nil, err
+		}
+		promoCode = promo.Code
+	}
+
 	ride := &domain.Ride{
 		CustomerID:  customerID,
 		PickupLat:   pickupLat,
 		PickupLng:   pickupLng,
 		DropoffLat:  dropoffLat,
 		DropoffLng:  dropoffLng,
+		Waypoints:   waypoints,
 		Status:      domain.RideStatusRequested,
+		RideType:    rideType,
+		PromoCode:   promoCode,
 		RequestedAt: time.Now(),
 	}
 
-	if err := s.rideRepoMongo.Create(ctx, ride); err != nil {
+	ride.SurgeMultiplier = s.surgeMultiplier(ctx, customerID, pickupLat, pickupLng)
+
+	estimatedFare := s.fareService.EstimateFare(ride)
+	ride.Fare = &estimatedFare
+
+	err = s.rideRepo.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := s.rideRepo.Create(ctx, ride); err != nil {
+			return err
+		}
+		return s.recordEvent(ctx, ride, "", "customer", customerID, "")
+	})
+	if err != nil {
 		logger.Error(ctx, fmt.Sprintf("Failed to create ride: %v", err))
+		if errors.Is(err, repository.ErrCustomerHasActiveRide) {
+			return nil, ErrActiveRideExists
+		}
+		return nil, err
+	}
+	s.metrics.RidesRequested.Inc()
+
+	if err := s.dispatchService.Dispatch(ctx, ride); err != nil {
+		// The dispatch worker offers the ride once its offer is found missing
+		logger.Error(ctx, fmt.Sprintf("Failed to dispatch ride %d: %v", ride.ID, err))
+	}
+
+	return ride, nil
+}
+
+// validateRideRequest checks the ride type and route of a ride request, an empty ride type defaults to economy
+// The pickup must be inside a service area when pickups are restricted to geofences
+func (s *RideService) validateRideRequest(ctx context.Context, customerID int64, rideType domain.RideType, pickupLat, pickupLng, dropoffLat, dropoffLng float64, waypoints []domain.Location) (domain.RideType, error) {
+	if rideType == "" {
+		rideType = domain.RideTypeEconomy
+	}
+	if !rideType.IsValid() {
+		logger.Error(ctx, fmt.Sprintf("invalid ride type: %s", rideType))
+		return "", domain.ErrInvalidRideType
+	}
+	if err := domain.ValidateCoordinates(pickupLat, pickupLng); err != nil {
+		logger.Error(ctx, fmt.Sprintf("invalid pickup location: %v", err))
+		return "", fmt.Errorf("pickup: %w", err)
+	}
+	if err := domain.ValidateCoordinates(dropoffLat, dropoffLng); err != nil {
+		logger.Error(ctx, fmt.Sprintf("invalid dropoff location: %v", err))
+		return "", fmt.Errorf("dropoff: %w", err)
+	}
+	if pickupLat == dropoffLat && pickupLng == dropoffLng {
+		logger.Error(ctx, fmt.Sprintf("pickup and dropoff are the same point for customer %d", customerID))
+		return "", domain.ErrSamePickupDropoff
+	}
+	if err := domain.ValidateWaypoints(waypoints); err != nil {
+		logger.Error(ctx, fmt.Sprintf("invalid waypoints: %v", err))
+		return "", err
+	}
+	if s.geofenceService != nil {
+		inside, err := s.geofenceService.Contains(ctx, pickupLat, pickupLng)
+		if err != nil {
+			return "", err
+		}
+		if !inside {
+			logger.Error(ctx, fmt.Sprintf("pickup (%f, %f) of customer %d is outside every service area", pickupLat, pickupLng, customerID))
+			return "", ErrOutsideServiceArea
+		}
+	}
+	return rideType, nil
+}
+
+// surgeMultiplier returns the current surge at the pickup
+// When demand cannot be measured the ride is priced without surge rather than failing the request
+func (s *RideService) surgeMultiplier(ctx context.Context, customerID int64, pickupLat, pickupLng float64) float64 {
+	surge, err := s.surgeService.GetMultiplier(ctx, pickupLat, pickupLng)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to get surge multiplier for customer %d: %v", customerID, err))
+		return NoSurge
+	}
+	return surge
+}
+
+// FareEstimate is a price quote for a route, no ride is created for it
+type FareEstimate struct {
+	RideType         domain.RideType `json:"ride_type"`
+	DistanceMeters   float64         `json:"distance_meters"`  // straight-line distance from pickup to dropoff
+	DurationMinutes  int             `json:"duration_minutes"` // driving time at the average speed, 0 when no speed is configured
+	SurgeMultiplier  float64         `json:"surge_multiplier"`
+	Currency         string          `json:"currency"`
+	MinFare          float64         `json:"min_fare"`
+	MaxFare          float64         `json:"max_fare"`
+	MinFareFormatted string          `json:"min_fare_formatted"` // e.g. "BDT 170.00"
+	MaxFareFormatted string          `json:"max_fare_formatted"`
+}
+
+// EstimateFare quotes the fare range for a ride from pickup to dropoff at the current surge without requesting it
+// An empty ride type defaults to economy
+func (s *RideService) EstimateFare(ctx context.Context, customerID int64, rideType domain.RideType, pickupLat, pickupLng, dropoffLat, dropoffLng float64) (*FareEstimate, error) {
+	rideType, err := s.validateRideRequest(ctx, customerID, rideType, pickupLat, pickupLng, dropoffLat, dropoffLng, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	pickup := domain.Location{Latitude: pickupLat, Longitude: pickupLng}
+	dropoff := domain.Location{Latitude: dropoffLat, Longitude: dropoffLng}
+	distance := pickup.DistanceTo(dropoff)
+
+	var durationMinutes float64
+	if s.averageSpeedKmh > 0 {
+		durationMinutes = (distance / 1000) / s.averageSpeedKmh * 60
+	}
+
+	surge := s.surgeMultiplier(ctx, customerID, pickupLat, pickupLng)
+	minFare, maxFare := s.fareService.EstimateFareRange(distance, durationMinutes, surge)
+
+	return &FareEstimate{
+		RideType:         rideType,
+		DistanceMeters:   math.Round(distance),
+		DurationMinutes:  int(math.Ceil(durationMinutes)),
+		SurgeMultiplier:  surge,
+		Currency:         s.fareService.Currency(),
+		MinFare:          minFare,
+		MaxFare:          maxFare,
+		MinFareFormatted: s.fareService.FormatFare(minFare),
+		MaxFareFormatted: s.fareService.FormatFare(maxFare),
+	}, nil
+}
+
+// RequestRideFromSavedLocation creates a ride request picking the customer up at one of their saved locations
+func (s *RideService) RequestRideFromSavedLocation(ctx context.Context, customerID, pickupSavedLocationID int64, rideType domain.RideType, dropoffLat, dropoffLng float64, waypoints []domain.Location, promoCode, idempotencyKey string) (*domain.Ride, error) {
+	return s.withIdempotencyKey(ctx, customerID, idempotencyKey, func() (*domain.Ride, error) {
+		pickup, err := s.savedLocationService.Get(ctx, customerID, pickupSavedLocationID)
+		if err != nil {
+			return nil, err
+		}
+
+		return s.requestRide(ctx, customerID, rideType, pickup.Lat, pickup.Lng, dropoffLat, dropoffLng, waypoints, promoCode)
+	})
+}
+
+// withIdempotencyKey runs create at most once per idempotency key within idempotencyKeyTTL
+// The key is reserved for the customer before the ride is created and then mapped to the ride ID,
+// a replay by the same customer returns that ride and a failed request releases the key so it can be retried
+func (s *RideService) withIdempotencyKey(ctx context.Context, customerID int64, idempotencyKey string, create func() (*domain.Ride, error)) (*domain.Ride, error) {
+	if idempotencyKey == "" {
+		return create()
+	}
+	if len(idempotencyKey) > MaxIdempotencyKeyLength {
+		logger.Error(ctx, fmt.Sprintf("idempotency key of %d characters from customer %d", len(idempotencyKey), customerID))
+		return nil, ErrInvalidIdempotencyKey
+	}
+
+	key := utils.RideIdempotencyKey(idempotencyKey)
+	owner := strconv.FormatInt(customerID, 10)
+
+	reserved, err := s.redis.SetNX(ctx, key, owner, s.idempotencyKeyTTL).Result()
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to reserve idempotency key for customer %d: %v", customerID, err))
 		return nil, err
 	}
+	if !reserved {
+		return s.replayIdempotentRequest(ctx, key, owner)
+	}
+
+	ride, err := create()
+	if err != nil {
+		if delErr := s.redis.Del(ctx, key).Err(); delErr != nil {
+			logger.Error(ctx, fmt.Sprintf("Failed to release idempotency key for customer %d: %v", customerID, delErr))
+		}
+		return nil, err
+	}
+
+	if err := s.redis.Set(ctx, key, fmt.Sprintf("%s:%d", owner, ride.ID), s.idempotencyKeyTTL).Err(); err != nil {
+		// The ride is created, replays report the request as in progress until the key expires
+		logger.Error(ctx, fmt.Sprintf("Failed to store ride %d for idempotency key: %v", ride.ID, err))
+	}
 
 	return ride, nil
 }
 
-// GetNearbyRides Returns rides within radius that were updated in the last 5 minutes with status "requested" or "pending"
-func (s *RideService) GetNearbyRides(ctx context.Context, driverID int64, driverLat, driverLng, maxDistance float64, limit int) ([]*domain.Ride, error) {
-	rides, err := s.rideRepoMongo.GetNearbyRequestedRides(ctx, driverLat, driverLng, maxDistance, limit)
+// replayIdempotentRequest returns the ride created for an idempotency key reserved by owner
+func (s *RideService) replayIdempotentRequest(ctx context.Context, key, owner string) (*domain.Ride, error) {
+	value, err := s.redis.Get(ctx, key).Result()
+	if err == redis.Nil {
+		// The key expired or was released between reserving and reading it
+		return nil, ErrIdempotentRequestInProgress
+	}
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to read idempotency key: %v", err))
+		return nil, err
+	}
+
+	keyOwner, rideIDStr, _ := strings.Cut(value, ":")
+	if keyOwner != owner {
+		logger.Error(ctx, fmt.Sprintf("customer %s replayed an idempotency key of customer %s", owner, keyOwner))
+		return nil, ErrIdempotencyKeyReused
+	}
+	if rideIDStr == "" {
+		return nil, ErrIdempotentRequestInProgress
+	}
+
+	rideID, err := strconv.ParseInt(rideIDStr, 10, 64)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("invalid ride ID %q stored for idempotency key: %v", rideIDStr, err))
+		return nil, err
+	}
+
+	return s.rideRepo.GetByID(ctx, rideID)
+}
+
+// GetNearbyRides Returns rides within radius that were updated within freshness with status "requested" or "pending"
+// A zero freshness uses the configured default, freshness above MaxNearbyFreshness is rejected
+// A zero maxDistance or limit uses the configured default, maxDistance is capped at the configured maximum search radius
+// Only rides requested for the driver's vehicle type and currently offered to the driver are returned
+func (s *RideService) GetNearbyRides(ctx context.Context, driverID int64, driverLat, driverLng, maxDistance float64, freshness time.Duration, limit int) ([]*domain.Ride, error) {
+	if freshness < 0 || freshness > MaxNearbyFreshness {
+		logger.Error(ctx, fmt.Sprintf("invalid nearby ride freshness %s from driver %d", freshness, driverID))
+		return nil, ErrInvalidNearbyFreshness
+	}
+	if freshness == 0 {
+		freshness = s.nearbyFreshness
+	}
+
+	driver, err := s.driverService.GetByID(ctx, driverID)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to get driver %d: %v", driverID, err))
+		return nil, err
+	}
+
+	vehicleType := driver.VehicleType
+	if vehicleType == "" {
+		vehicleType = domain.RideTypeEconomy
+	}
+
+	maxDistance, limit = s.locationService.RideSearch(maxDistance, limit)
+	maxDistance = s.locationService.ClampSearchRadius(maxDistance)
+	findNearby := s.rideRepo.GetNearbyRequestedRides
+	if s.nearbyByGeohash {
+		findNearby = s.rideRepo.GetNearbyRequestedRidesByGeohash
+	}
+	rides, err := findNearby(ctx, driverID, vehicleType, driverLat, driverLng, maxDistance, freshness, limit)
 	if err != nil {
 		logger.Error(ctx, fmt.Sprintf("Failed to get nearby requested rides: %v", err))
 		return nil, err
 	}
 
+	driverLocation := domain.Location{Latitude: driverLat, Longitude: driverLng}
+	for _, ride := range rides {
+		pickup := domain.Location{Latitude: ride.PickupLat, Longitude: ride.PickupLng}
+		ride.DistanceFromDriver = driverLocation.DistanceTo(pickup)
+	}
+
 	logger.Info(ctx, fmt.Sprintf("Found %d nearby rides for driver %d within %.2fm (limit: %d)", len(rides), driverID, maxDistance, limit))
 
 	return rides, nil
@@ -85,102 +445,499 @@ func (s *RideService) GetNearbyRides(ctx context.Context, driverID int64, driver
 
 // AcceptRide allows driver to accept a ride
 func (s *RideService) AcceptRide(ctx context.Context, rideID, driverID int64) error {
-	ride, err := s.rideRepoMongo.GetByID(ctx, rideID)
+	// Check if driver is online
+	isOnline, err := s.driverService.IsDriverOnline(ctx, driverID)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to check online status for driver %d: %v", driverID, err))
+		return err
+	}
+	if !isOnline {
+		logger.Error(ctx, fmt.Sprintf("Driver %d is not online", driverID))
+		return ErrDriverNotOnline
+	}
+
+	ride, err := s.rideRepo.GetByID(ctx, rideID)
 	if err != nil {
 		logger.Error(ctx, fmt.Sprintf("Failed to get ride: %v", err))
 		return err
 	}
 
-	if ride.Status == domain.RideStatusAccepted || ride.Status == domain.RideStatusStarted || ride.Status == domain.RideStatusCompleted {
+	if ride.Status.IsAwaitingPickup() || ride.Status == domain.RideStatusStarted || ride.Status == domain.RideStatusCompleted {
 		logger.Error(ctx, fmt.Sprintf("Ride with id %d cannot be accepted", rideID))
-		return errors.New("ride is cannot be accepted")
+		return ErrRideCannotBeAccepted
+	}
+
+	if !ride.IsOfferedTo(driverID, time.Now()) {
+		logger.Error(ctx, fmt.Sprintf("Ride %d is not offered to driver %d", rideID, driverID))
+		return ErrRideNotOffered
 	}
 
+	from := ride.Status
 	if err := ride.Accept(driverID); err != nil {
 		logger.Error(ctx, fmt.Sprintf("Failed to accept ride: %v", err))
 		return err
 	}
 
-	return s.rideRepoMongo.Update(ctx, ride)
+	// Writes that must succeed or fail together with the accept belong in this transaction
+	err = s.rideRepo.WithTransaction(ctx, func(ctx context.Context) error {
+		// Accept only if the ride is still waiting, another driver may have accepted it since it was read
+		if err := s.rideRepo.AcceptRide(ctx, rideID, driverID, *ride.AcceptedAt); err != nil {
+			return err
+		}
+		return s.recordEvent(ctx, ride, from, "driver", driverID, "")
+	})
+	if err != nil {
+		if errors.Is(err, repository.ErrRideNotAcceptable) {
+			logger.Error(ctx, fmt.Sprintf("Ride %d was accepted or cancelled before driver %d", rideID, driverID))
+			return ErrRideAlreadyAccepted
+		}
+		logger.Error(ctx, fmt.Sprintf("Failed to accept ride %d: %v", rideID, err))
+		return err
+	}
+	s.metrics.ObserveAccepted(ride.RequestedAt, *ride.AcceptedAt)
+
+	if err := s.notifier.NotifyCustomerDriverAssigned(ctx, ride); err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to notify customer %d that ride %d was accepted: %v", ride.CustomerID, rideID, err))
+	}
+
+	return nil
 }
 
-// StartRide starts the ride
-func (s *RideService) StartRide(ctx context.Context, rideID int64) error {
-	ride, err := s.rideRepoMongo.GetByID(ctx, rideID)
+// DeclineRide records that the driver declined the ride so it is no longer offered to them
+// Declining the current offer passes the ride on to the next nearest driver
+func (s *RideService) DeclineRide(ctx context.Context, rideID, driverID int64) error {
+	ride, err := s.rideRepo.GetByID(ctx, rideID)
 	if err != nil {
 		logger.Error(ctx, fmt.Sprintf("Failed to get ride: %v", err))
 		return err
 	}
 
-	if ride.Status != domain.RideStatusAccepted {
+	if !ride.Status.IsAwaitingDriver() {
+		logger.Error(ctx, fmt.Sprintf("Ride with id %d cannot be declined", rideID))
+		return domain.ErrRideNotAwaitingDriver
+	}
+
+	return s.dispatchService.Decline(ctx, ride, driverID)
+}
+
+// MarkArrived records that the driver reached the pickup and is waiting for the customer
+func (s *RideService) MarkArrived(ctx context.Context, rideID, driverID int64) error {
+	ride, err := s.rideRepo.GetByID(ctx, rideID)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to get ride: %v", err))
+		return err
+	}
+
+	from := ride.Status
+	if err := ride.MarkArrived(driverID, time.Now()); err != nil {
+		logger.Error(ctx, fmt.Sprintf("Driver %d cannot mark arrived for ride %d in status %s", driverID, rideID, ride.Status))
+		return err
+	}
+
+	if err := s.updateRide(ctx, ride, from, "driver", driverID, ""); err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to mark ride %d arrived: %v", rideID, err))
+		return err
+	}
+
+	return nil
+}
+
+// StartRide starts the ride on behalf of its assigned driver
+// The driver must have marked arrived unless starting straight from accepted is allowed
+func (s *RideService) StartRide(ctx context.Context, rideID, driverID int64) error {
+	ride, err := s.rideRepo.GetByID(ctx, rideID)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to get ride: %v", err))
+		return err
+	}
+
+	if !isRideDriver(ride, driverID) {
+		logger.Error(ctx, fmt.Sprintf("Driver %d tried to start ride %d assigned to another driver", driverID, rideID))
+		return ErrNotRideDriver
+	}
+
+	if !ride.Status.IsAwaitingPickup() {
 		logger.Error(ctx, fmt.Sprintf("Ride with id %d cannot be started", rideID))
-		return errors.New("ride is cannot be started")
+		return ErrRideCannotBeStarted
 	}
 
-	if err := ride.Start(); err != nil {
+	from := ride.Status
+	if err := ride.Start(s.requireArrival); err != nil {
 		logger.Error(ctx, fmt.Sprintf("Failed to start ride: %v", err))
 		return err
 	}
 
-	return s.rideRepoMongo.Update(ctx, ride)
+	if err := s.updateRide(ctx, ride, from, "driver", driverID, ""); err != nil {
+		return err
+	}
+	s.metrics.RidesStarted.Inc()
+
+	if err := s.notifier.NotifyCustomerRideStarted(ctx, ride); err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to notify customer %d that ride %d started: %v", ride.CustomerID, rideID, err))
+	}
+
+	return nil
 }
 
-// CompleteRide completes the ride
-func (s *RideService) CompleteRide(ctx context.Context, rideID int64) error {
-	ride, err := s.rideRepoMongo.GetByID(ctx, rideID)
+// CompleteRide completes the ride on behalf of its assigned driver
+func (s *RideService) CompleteRide(ctx context.Context, rideID, driverID int64) error {
+	ride, err := s.rideRepo.GetByID(ctx, rideID)
 	if err != nil {
 		logger.Error(ctx, fmt.Sprintf("Failed to get ride: %v", err))
 		return err
 	}
 
-	if ride.Status != domain.RideStatusCompleted {
+	if !isRideDriver(ride, driverID) {
+		logger.Error(ctx, fmt.Sprintf("Driver %d tried to complete ride %d assigned to another driver", driverID, rideID))
+		return ErrNotRideDriver
+	}
+
+	if ride.Status != domain.RideStatusStarted {
 		logger.Error(ctx, fmt.Sprintf("Ride with id %d cannot be completed", rideID))
-		return errors.New("ride is cannot be completed")
+		return domain.ErrRideNotCompletable
 	}
 
+	from := ride.Status
 	if err := ride.Complete(); err != nil {
 		logger.Error(ctx, fmt.Sprintf("Failed to complete ride: %v", err))
 		return err
 	}
 
-	return s.rideRepoMongo.Update(ctx, ride)
+	finalFare := s.fareService.FinalizeFare(ctx, ride)
+	if ride.PromoCode != "" {
+		finalFare = s.applyPromoCode(ctx, ride, finalFare)
+	}
+	ride.Fare = &finalFare
+	// Snapshot the rate so later changes to it do not alter this ride's commission
+	commissionRate := s.fareService.CommissionRate()
+	ride.CommissionRate = &commissionRate
+
+	if err := s.updateRide(ctx, ride, from, "driver", driverID, ""); err != nil {
+		return err
+	}
+	s.metrics.RidesCompleted.Inc()
+
+	if err := s.notifier.NotifyCustomerRideCompleted(ctx, ride); err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to notify customer %d that ride %d completed: %v", ride.CustomerID, rideID, err))
+	}
+
+	return nil
+}
+
+// applyPromoCode redeems the ride's promo code against its final fare
+// A code that can no longer be redeemed, e.g. it expired during the ride, leaves the fare undiscounted rather than failing completion
+func (s *RideService) applyPromoCode(ctx context.Context, ride *domain.Ride, fare float64) float64 {
+	discounted, err := s.promoService.Redeem(ctx, ride.PromoCode, ride.CustomerID, ride.ID, fare)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to redeem promo code %s on ride %d, charging the full fare: %v", ride.PromoCode, ride.ID, err))
+		return fare
+	}
+
+	discounted = s.fareService.roundFare(discounted)
+	discount := s.fareService.roundFare(fare - discounted)
+	ride.Discount = &discount
+	return discounted
+}
+
+// CancelRide cancels the ride on behalf of the driver
+// An accepted ride that has not started is abandoned instead, so the customer is matched with another driver
+func (s *RideService) CancelRide(ctx context.Context, rideID, driverID int64, reason string) error {
+	ride, err := s.rideRepo.GetByID(ctx, rideID)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to get ride: %v", err))
+		return err
+	}
+
+	if ride.Status.IsAwaitingPickup() {
+		return s.abandonRide(ctx, ride, driverID, reason, "driver", driverID)
+	}
+
+	if !isRideDriver(ride, driverID) {
+		logger.Error(ctx, fmt.Sprintf("Driver %d tried to cancel ride %d assigned to another driver", driverID, rideID))
+		return ErrNotRideDriver
+	}
+
+	return s.cancelRide(ctx, ride, domain.CancelledByDriver, reason)
+}
+
+// isRideDriver reports whether driverID is the driver assigned to the ride
+func isRideDriver(ride *domain.Ride, driverID int64) bool {
+	return ride.DriverID != nil && *ride.DriverID == driverID
 }
 
-// CancelRide cancels the ride
-func (s *RideService) CancelRide(ctx context.Context, rideID int64) error {
-	ride, err := s.rideRepoMongo.GetByID(ctx, rideID)
+// DriverAbandonRide releases a ride the driver accepted but has not started
+// The ride goes back to requested and is dispatched to the next nearest driver, the abandoning driver is not offered it again
+func (s *RideService) DriverAbandonRide(ctx context.Context, rideID, driverID int64, reason string) error {
+	ride, err := s.rideRepo.GetByID(ctx, rideID)
 	if err != nil {
 		logger.Error(ctx, fmt.Sprintf("Failed to get ride: %v", err))
 		return err
 	}
 
+	return s.abandonRide(ctx, ride, driverID, reason, "driver", driverID)
+}
+
+// acceptanceTimeoutReason is recorded on rides released because the driver did not start them in time
+const acceptanceTimeoutReason = "driver did not start the ride in time"
+
+// ReleaseStaleAcceptedRides releases the rides accepted before cutoff whose driver has not arrived at or started them
+// Each ride goes back to requested and is dispatched again like an abandoned one, its driver is not offered it again
+// Returns the number of rides released
+func (s *RideService) ReleaseStaleAcceptedRides(ctx context.Context, cutoff time.Time) (int, error) {
+	rides, err := s.rideRepo.GetStaleAcceptedRides(ctx, cutoff)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to get stale accepted rides: %v", err))
+		return 0, err
+	}
+
+	released := 0
+	for _, ride := range rides {
+		if ride.Status != domain.RideStatusAccepted || ride.DriverID == nil || ride.AcceptedAt == nil || !ride.AcceptedAt.Before(cutoff) {
+			continue
+		}
+
+		driverID, acceptedAt := *ride.DriverID, *ride.AcceptedAt
+		if err := s.abandonRide(ctx, ride, driverID, acceptanceTimeoutReason, domain.CancelledBySystem, 0); err != nil {
+			continue
+		}
+		logger.Info(ctx, fmt.Sprintf("Released ride %d, driver %d accepted it at %s and did not start it", ride.ID, driverID, acceptedAt.Format(time.RFC3339)))
+		released++
+	}
+
+	return released, nil
+}
+
+// abandonRide returns the ride driverID accepted to requested, the change is recorded as made by actorRole actorID
+func (s *RideService) abandonRide(ctx context.Context, ride *domain.Ride, driverID int64, reason, actorRole string, actorID int64) error {
+	if !ride.Status.IsAwaitingPickup() || !isRideDriver(ride, driverID) {
+		logger.Error(ctx, fmt.Sprintf("driver %d cannot abandon ride %d in status %s", driverID, ride.ID, ride.Status))
+		return ErrRideNotAbandonable
+	}
+
+	from := ride.Status
+	if err := ride.Abandon(driverID, reason, time.Now()); err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to abandon ride %d: %v", ride.ID, err))
+		return err
+	}
+
+	abandonment := ride.Abandonments[len(ride.Abandonments)-1]
+	err := s.rideRepo.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := s.rideRepo.AbandonRide(ctx, ride.ID, abandonment); err != nil {
+			return err
+		}
+		return s.recordEvent(ctx, ride, from, actorRole, actorID, reason)
+	})
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to abandon ride %d for driver %d: %v", ride.ID, driverID, err))
+		if errors.Is(err, repository.ErrRideNotAbandonable) {
+			// The ride was started or cancelled in the meantime
+			return ErrRideNotAbandonable
+		}
+		return err
+	}
+	s.metrics.RidesAbandoned.Inc()
+
+	if err := s.dispatchService.Dispatch(ctx, ride); err != nil {
+		// The dispatch worker offers the ride once its offer is found missing
+		logger.Error(ctx, fmt.Sprintf("Failed to dispatch abandoned ride %d: %v", ride.ID, err))
+	}
+
+	return nil
+}
+
+// rideExpiredReason is recorded on requested rides cancelled because no driver accepted them in time
+const rideExpiredReason = "no driver found"
+
+// ExpireStaleRequestedRides cancels the rides still waiting for a driver that were requested, or last requeued, before cutoff
+// Each cancellation is recorded as made by the system and the customer is notified
+// Returns the number of rides expired
+func (s *RideService) ExpireStaleRequestedRides(ctx context.Context, cutoff time.Time) (int, error) {
+	rides, err := s.rideRepo.GetStaleRequestedRides(ctx, cutoff)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to get stale requested rides: %v", err))
+		return 0, err
+	}
+
+	expired := 0
+	for _, ride := range rides {
+		if err := s.expireRide(ctx, ride, cutoff); err != nil {
+			continue
+		}
+		expired++
+	}
+
+	return expired, nil
+}
+
+// expireRide cancels a ride no driver accepted in time, it is left alone if a driver accepted it since it was found stale
+func (s *RideService) expireRide(ctx context.Context, ride *domain.Ride, cutoff time.Time) error {
+	from := ride.Status
+	if err := ride.Cancel(domain.CancelledBySystem, rideExpiredReason); err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to expire ride %d: %v", ride.ID, err))
+		return err
+	}
+
+	err := s.rideRepo.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := s.rideRepo.ExpireRide(ctx, ride, cutoff); err != nil {
+			return err
+		}
+		return s.recordEvent(ctx, ride, from, domain.CancelledBySystem, 0, rideExpiredReason)
+	})
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to expire ride %d: %v", ride.ID, err))
+		return err
+	}
+	s.metrics.RidesCancelled.WithLabelValues(domain.CancelledBySystem).Inc()
+
+	if err := s.notifier.NotifyCustomerRideExpired(ctx, ride); err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to notify customer %d of expired ride %d: %v", ride.CustomerID, ride.ID, err))
+	}
+
+	return nil
+}
+
+// RideCancellation is the fee charged to the customer for a cancelled ride
+type RideCancellation struct {
+	RideID                   int64   `json:"ride_id"`
+	Currency                 string  `json:"currency"`
+	CancellationFee          float64 `json:"cancellation_fee"` // 0 when the ride was cancelled for free
+	CancellationFeeFormatted string  `json:"cancellation_fee_formatted"`
+}
+
+// CancelRideForCustomer cancels the ride on behalf of the customer who requested it
+// Cancelling is free within the cancellation window while the ride waits for a driver, later a fee is charged
+func (s *RideService) CancelRideForCustomer(ctx context.Context, rideID, customerID int64, reason string) (*RideCancellation, error) {
+	ride, err := s.getCustomerRide(ctx, rideID, customerID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.cancelRide(ctx, ride, domain.CancelledByCustomer, reason); err != nil {
+		return nil, err
+	}
+
+	var fee float64
+	if ride.Fare != nil {
+		fee = *ride.Fare
+	}
+	return &RideCancellation{
+		RideID:                   ride.ID,
+		Currency:                 s.fareService.Currency(),
+		CancellationFee:          fee,
+		CancellationFeeFormatted: s.fareService.FormatFare(fee),
+	}, nil
+}
+
+func (s *RideService) cancelRide(ctx context.Context, ride *domain.Ride, cancelledBy, reason string) error {
 	if ride.Status == domain.RideStatusCompleted || ride.Status == domain.RideStatusCancelled {
-		logger.Error(ctx, fmt.Sprintf("Ride with id %d cannot be cancelled", rideID))
-		return errors.New("ride is cannot be cancelled")
+		logger.Error(ctx, fmt.Sprintf("Ride with id %d cannot be cancelled", ride.ID))
+		return ErrRideCannotBeCancelled
 	}
 
-	if err := ride.Cancel(); err != nil {
+	cancellationFee := s.fareService.CancellationFee(ride, time.Now())
+
+	from := ride.Status
+	if err := ride.Cancel(cancelledBy, reason); err != nil {
 		logger.Error(ctx, fmt.Sprintf("Failed to cancel ride: %v", err))
 		return err
 	}
 
-	return s.rideRepoMongo.Update(ctx, ride)
+	ride.Fare = cancellationFee
+
+	actorID := ride.CustomerID
+	if cancelledBy == domain.CancelledByDriver {
+		actorID = *ride.DriverID
+	}
+	if err := s.updateRide(ctx, ride, from, cancelledBy, actorID, reason); err != nil {
+		return err
+	}
+	s.metrics.RidesCancelled.WithLabelValues(cancelledBy).Inc()
+
+	return nil
+}
+
+// updateRide saves the ride after its status changed from from, recording the change in the same transaction
+func (s *RideService) updateRide(ctx context.Context, ride *domain.Ride, from domain.RideStatus, actorRole string, actorID int64, reason string) error {
+	return s.rideRepo.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := s.rideRepo.Update(ctx, ride, from); err != nil {
+			return err
+		}
+		return s.recordEvent(ctx, ride, from, actorRole, actorID, reason)
+	})
+}
+
+// recordEvent adds the ride's change from status from to its current status to the ride's timeline
+func (s *RideService) recordEvent(ctx context.Context, ride *domain.Ride, from domain.RideStatus, actorRole string, actorID int64, reason string) error {
+	return recordRideEvent(ctx, s.eventRepo, ride, from, actorRole, actorID, reason)
+}
+
+// recordRideEvent adds the ride's change from status from to its current status to eventRepo
+func recordRideEvent(ctx context.Context, eventRepo repository.RideEventRepository, ride *domain.Ride, from domain.RideStatus, actorRole string, actorID int64, reason string) error {
+	event := &domain.RideEvent{
+		RideID:     ride.ID,
+		FromStatus: from,
+		ToStatus:   ride.Status,
+		ActorRole:  actorRole,
+		ActorID:    actorID,
+		Reason:     reason,
+		OccurredAt: time.Now(),
+	}
+	if err := eventRepo.Create(ctx, event); err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to record ride %d change from %q to %q: %v", ride.ID, from, ride.Status, err))
+		return err
+	}
+	return nil
+}
+
+// GetRideEvents returns the ride's status changes, oldest first
+// Only the ride's customer and assigned driver can see them
+func (s *RideService) GetRideEvents(ctx context.Context, rideID, userID int64, role string) ([]*domain.RideEvent, error) {
+	ride, err := s.rideRepo.GetByID(ctx, rideID)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to get ride %d: %v", rideID, err))
+		return nil, ErrRideNotFound
+	}
+
+	switch {
+	case role == "customer" && ride.CustomerID == userID:
+	case role == "driver" && isRideDriver(ride, userID):
+	default:
+		logger.Error(ctx, fmt.Sprintf("%s %d tried to view the events of ride %d they did not take part in", role, userID, rideID))
+		return nil, ErrNotRideParticipant
+	}
+
+	events, err := s.eventRepo.GetByRideID(ctx, rideID)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to get events of ride %d: %v", rideID, err))
+		return nil, err
+	}
+
+	return events, nil
 }
 
 // GetRideByID retrieves a ride by ID
+// Returns ErrRideNotFound when no ride has the ID
 func (s *RideService) GetRideByID(ctx context.Context, rideID int64) (*domain.Ride, error) {
-	return s.rideRepoMongo.GetByID(ctx, rideID)
+	return s.rideRepo.GetByID(ctx, rideID)
 }
 
 // GetRideDetailsWithCustomer retrieves detailed ride information with customer details
-func (s *RideService) GetRideDetailsWithCustomer(ctx context.Context, rideID int64) (*RideWithCustomerInfo, error) {
-	ride, err := s.rideRepoMongo.GetByID(ctx, rideID)
+// Returns ErrNotRideParticipant unless canViewRideDetails allows the user,
+// the customer's phone is masked unless canSeeCustomerContact allows the user
+func (s *RideService) GetRideDetailsWithCustomer(ctx context.Context, rideID, userID int64, role string) (*RideWithCustomerInfo, error) {
+	ride, err := s.rideRepo.GetByID(ctx, rideID)
 	if err != nil {
 		logger.Error(ctx, fmt.Sprintf("Failed to get ride %d: %v", rideID, err))
-		return nil, err
+		return nil, ErrRideNotFound
+	}
+
+	if !canViewRideDetails(ride, userID, role, time.Now()) {
+		logger.Error(ctx, fmt.Sprintf("%s %d tried to view the details of ride %d", role, userID, rideID))
+		return nil, ErrNotRideParticipant
 	}
 
-	customer, err := s.customerRepo.GetByID(ctx, ride.CustomerID)
+	// Customers who deleted their account still appear on their past rides
+	customer, err := s.customerRepo.GetByIDWithDeleted(ctx, ride.CustomerID)
 	if err != nil {
 		logger.Error(ctx, fmt.Sprintf("Failed to get customer %d: %v", ride.CustomerID, err))
 		return nil, err
@@ -190,49 +947,88 @@ func (s *RideService) GetRideDetailsWithCustomer(ctx context.Context, rideID int
 		RideID:             ride.ID,
 		CustomerID:         ride.CustomerID,
 		CustomerName:       customer.Name,
-		CustomerPhone:      customer.Phone,
+		CustomerPhone:      utils.MaskPhone(customer.Phone),
 		CustomerCurrentLat: ride.PickupLat,
 		CustomerCurrentLng: ride.PickupLng,
 		PickupLat:          ride.PickupLat,
 		PickupLng:          ride.PickupLng,
 		DropoffLat:         ride.DropoffLat,
 		DropoffLng:         ride.DropoffLng,
+		Waypoints:          ride.Waypoints,
 		RequestedAt:        ride.RequestedAt.Format("2006-01-02 15:04:05"),
 		Status:             string(ride.Status),
 	}
+	if canSeeCustomerContact(ride, userID, role) {
+		rideDetails.CustomerPhone = customer.Phone
+	}
 
 	return rideDetails, nil
 }
 
+// canSeeCustomerContact reports whether the user may see the customer's full phone number
+// A driver only may once the ride is assigned to them, a driver who is just offered the ride sees it masked
+func canSeeCustomerContact(ride *domain.Ride, userID int64, role string) bool {
+	switch role {
+	case "admin":
+		return true
+	case "customer":
+		return ride.CustomerID == userID
+	case "driver":
+		return isRideDriver(ride, userID)
+	}
+	return false
+}
+
+// canViewRideDetails reports whether the user may see a ride's details including the customer's contact
+// Allowed are admins, the customer who requested the ride, and the driver assigned to it or currently offered it
+func canViewRideDetails(ride *domain.Ride, userID int64, role string, now time.Time) bool {
+	switch role {
+	case "admin":
+		return true
+	case "customer":
+		return ride.CustomerID == userID
+	case "driver":
+		return (ride.DriverID != nil && *ride.DriverID == userID) || ride.IsOfferedTo(userID, now)
+	}
+	return false
+}
+
 // GetRideStatusForCustomer retrieves ride status with driver information for customer
 func (s *RideService) GetRideStatusForCustomer(ctx context.Context, rideID, customerID int64) (*RideStatusResponse, error) {
-	ride, err := s.rideRepoMongo.GetByID(ctx, rideID)
+	ride, err := s.getCustomerRide(ctx, rideID, customerID)
 	if err != nil {
-		logger.Error(ctx, fmt.Sprintf("Failed to get ride %d: %v", rideID, err))
-		return nil, errors.New("ride not found")
+		return nil, err
 	}
 
-	if ride.CustomerID != customerID {
-		logger.Error(ctx, fmt.Sprintf("Customer %d tried to access ride %d belonging to customer %d", customerID, rideID, ride.CustomerID))
-		return nil, errors.New("forbidden: this ride belongs to another customer")
+	response := &RideStatusResponse{
+		RideID:             ride.ID,
+		CustomerID:         ride.CustomerID,
+		PickupLat:          ride.PickupLat,
+		PickupLng:          ride.PickupLng,
+		DropoffLat:         ride.DropoffLat,
+		DropoffLng:         ride.DropoffLng,
+		Waypoints:          ride.Waypoints,
+		Status:             string(ride.Status),
+		Fare:               ride.Fare,
+		Currency:           s.fareService.Currency(),
+		RequestedAt:        ride.RequestedAt.Format("2006-01-02 15:04:05"),
+		CancelledBy:        ride.CancelledBy,
+		CancellationReason: ride.CancellationReason,
 	}
 
-	response := &RideStatusResponse{
-		RideID:      ride.ID,
-		CustomerID:  ride.CustomerID,
-		PickupLat:   ride.PickupLat,
-		PickupLng:   ride.PickupLng,
-		DropoffLat:  ride.DropoffLat,
-		DropoffLng:  ride.DropoffLng,
-		Status:      string(ride.Status),
-		Fare:        ride.Fare,
-		RequestedAt: ride.RequestedAt.Format("2006-01-02 15:04:05"),
+	if ride.Fare != nil {
+		fareStr := s.fareService.FormatFare(*ride.Fare)
+		response.FareFormatted = &fareStr
 	}
 
 	if ride.AcceptedAt != nil {
 		acceptedStr := ride.AcceptedAt.Format("2006-01-02 15:04:05")
 		response.AcceptedAt = &acceptedStr
 	}
+	if ride.ArrivedAt != nil {
+		arrivedStr := ride.ArrivedAt.Format("2006-01-02 15:04:05")
+		response.ArrivedAt = &arrivedStr
+	}
 	if ride.StartedAt != nil {
 		startedStr := ride.StartedAt.Format("2006-01-02 15:04:05")
 		response.StartedAt = &startedStr
@@ -247,7 +1043,7 @@ func (s *RideService) GetRideStatusForCustomer(ctx context.Context, rideID, cust
 	}
 
 	if ride.DriverID != nil {
-		driverInfo, err := s.getDriverInfoWithLocation(ctx, *ride.DriverID)
+		driverInfo, err := s.getDriverInfoWithLocation(ctx, ride)
 		if err != nil {
 			logger.Error(ctx, fmt.Sprintf("Failed to get driver info for driver %d: %v", *ride.DriverID, err))
 		} else {
@@ -258,8 +1054,253 @@ func (s *RideService) GetRideStatusForCustomer(ctx context.Context, rideID, cust
 	return response, nil
 }
 
-// getDriverInfoWithLocation retrieves driver information including current location
-func (s *RideService) getDriverInfoWithLocation(ctx context.Context, driverID int64) (*DriverInfo, error) {
+// StreamRideStatusForCustomer calls send with a status snapshot right away and then once per
+// status stream interval, until the ride is completed or cancelled, send fails or ctx is done
+func (s *RideService) StreamRideStatusForCustomer(ctx context.Context, rideID, customerID int64, send func(*RideStatusResponse) error) error {
+	ticker := time.NewTicker(s.statusStreamInterval)
+	defer ticker.Stop()
+
+	for {
+		status, err := s.GetRideStatusForCustomer(ctx, rideID, customerID)
+		if err != nil {
+			return err
+		}
+		if err := send(status); err != nil {
+			return err
+		}
+		if domain.RideStatus(status.Status).IsTerminal() {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// GetCustomerRideHistory retrieves a page of the customer's rides, newest first
+// An empty status returns rides in any status
+func (s *RideService) GetCustomerRideHistory(ctx context.Context, customerID int64, status domain.RideStatus, limit, offset int) (*RideHistoryPage, error) {
+	if status != "" && !status.IsValid() {
+		logger.Error(ctx, fmt.Sprintf("invalid ride status filter: %s", status))
+		return nil, domain.ErrInvalidRideStatus
+	}
+
+	rides, total, err := s.rideRepo.GetByCustomerID(ctx, customerID, status, limit, offset)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to get ride history for customer %d: %v", customerID, err))
+		return nil, err
+	}
+
+	return newRideHistoryPage(rides, total, limit, offset), nil
+}
+
+// GetDriverRideHistory retrieves a page of the driver's rides, newest first
+func (s *RideService) GetDriverRideHistory(ctx context.Context, driverID int64, limit, offset int) (*RideHistoryPage, error) {
+	rides, total, err := s.rideRepo.GetByDriverID(ctx, driverID, limit, offset)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to get ride history for driver %d: %v", driverID, err))
+		return nil, err
+	}
+
+	return newRideHistoryPage(rides, total, limit, offset), nil
+}
+
+// ListRides retrieves a page of all rides matching filter, newest first, for admins
+// The page starts at page.Cursor when it is set, and its NextCursor fetches the page after it
+func (s *RideService) ListRides(ctx context.Context, filter repository.RideFilter, page repository.Page) (*RideHistoryPage, error) {
+	if filter.Status != "" && !filter.Status.IsValid() {
+		logger.Error(ctx, fmt.Sprintf("invalid ride status filter: %s", filter.Status))
+		return nil, domain.ErrInvalidRideStatus
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		logger.Error(ctx, fmt.Sprintf("invalid ride date range: %s to %s", filter.From, filter.To))
+		return nil, ErrInvalidDateRange
+	}
+
+	rides, total, err := s.rideRepo.ListRides(ctx, filter, page)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to list rides: %v", err))
+		return nil, err
+	}
+
+	history := newRideHistoryPage(rides, total, page.Limit, page.Offset)
+	history.NextCursor = pagination.Next(rides, page.Limit, RideListSort, func(ride *domain.Ride) (time.Time, int64) {
+		return ride.RequestedAt, ride.ID
+	})
+	return history, nil
+}
+
+// GetRevenueReport sums the platform commission on rides completed in [from, to), for admins
+// Each ride is charged the commission rate recorded when it completed, rides completed before rates were recorded earn none
+func (s *RideService) GetRevenueReport(ctx context.Context, from, to time.Time) (*RevenueReport, error) {
+	if !from.Before(to) {
+		logger.Error(ctx, fmt.Sprintf("invalid revenue date range: %s to %s", from, to))
+		return nil, ErrInvalidDateRange
+	}
+
+	rides, err := s.rideRepo.GetCompletedRides(ctx, from, to)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to get rides completed between %s and %s: %v", from, to, err))
+		return nil, err
+	}
+
+	report := &RevenueReport{
+		From:      from,
+		To:        to,
+		Currency:  s.fareService.Currency(),
+		RideCount: len(rides),
+	}
+	for _, ride := range rides {
+		if ride.Fare == nil {
+			continue
+		}
+		report.GrossFare += *ride.Fare
+		if ride.CommissionRate != nil {
+			report.Commission += s.fareService.Commission(*ride.Fare, *ride.CommissionRate)
+		}
+	}
+	report.GrossFare = s.fareService.roundFare(report.GrossFare)
+	report.Commission = s.fareService.roundFare(report.Commission)
+	report.CommissionFormatted = s.fareService.FormatFare(report.Commission)
+
+	return report, nil
+}
+
+func newRideHistoryPage(rides []*domain.Ride, total int64, limit, offset int) *RideHistoryPage {
+	if rides == nil {
+		rides = []*domain.Ride{}
+	}
+	return &RideHistoryPage{
+		Rides:  rides,
+		Total:  total,
+		Limit:  limit,
+		Offset: offset,
+	}
+}
+
+// GetRideRoute returns the path recorded while the ride was started, oldest point first
+// Only the ride's customer and assigned driver can see it
+func (s *RideService) GetRideRoute(ctx context.Context, rideID, userID int64, role string) ([]TrailPoint, error) {
+	ride, err := s.rideRepo.GetByID(ctx, rideID)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to get ride %d: %v", rideID, err))
+		return nil, ErrRideNotFound
+	}
+
+	switch {
+	case role == "customer" && ride.CustomerID == userID:
+	case role == "driver" && ride.DriverID != nil && *ride.DriverID == userID:
+	default:
+		logger.Error(ctx, fmt.Sprintf("%s %d tried to view the route of ride %d they did not take part in", role, userID, rideID))
+		return nil, ErrNotRideParticipant
+	}
+
+	route, err := s.locationService.GetRideRoute(ctx, rideID)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to get route of ride %d: %v", rideID, err))
+		return nil, err
+	}
+
+	return route, nil
+}
+
+// GetActiveRide retrieves the ride the customer or driver is currently taking part in
+// For customers that is a ride that is still waiting for a driver or in progress, for drivers one they
+// have accepted, arrived at or started. Returns nil when the user has no active ride
+func (s *RideService) GetActiveRide(ctx context.Context, userID int64, role string) (*domain.Ride, error) {
+	var (
+		ride *domain.Ride
+		err  error
+	)
+	switch role {
+	case "customer":
+		ride, err = s.rideRepo.GetActiveRideByCustomerID(ctx, userID)
+	case "driver":
+		ride, err = s.rideRepo.GetActiveRideByDriverID(ctx, userID)
+	default:
+		logger.Error(ctx, fmt.Sprintf("%s %d has no rides", role, userID))
+		return nil, ErrNoRidesForRole
+	}
+	if err != nil {
+		if errors.Is(err, repository.ErrRideNotFound) {
+			return nil, nil
+		}
+		logger.Error(ctx, fmt.Sprintf("Failed to get active ride for %s %d: %v", role, userID, err))
+		return nil, err
+	}
+
+	return ride, nil
+}
+
+// GetRideForCustomer retrieves a ride, returns ErrRideForbidden if it belongs to another customer
+func (s *RideService) GetRideForCustomer(ctx context.Context, rideID, customerID int64) (*domain.Ride, error) {
+	return s.getCustomerRide(ctx, rideID, customerID)
+}
+
+// getCustomerRide retrieves a ride and verifies it belongs to the customer
+func (s *RideService) getCustomerRide(ctx context.Context, rideID, customerID int64) (*domain.Ride, error) {
+	ride, err := s.rideRepo.GetByID(ctx, rideID)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to get ride %d: %v", rideID, err))
+		return nil, ErrRideNotFound
+	}
+
+	if ride.CustomerID != customerID {
+		logger.Error(ctx, fmt.Sprintf("Customer %d tried to access ride %d belonging to customer %d", customerID, rideID, ride.CustomerID))
+		return nil, ErrRideForbidden
+	}
+
+	return ride, nil
+}
+
+// GetRideETA estimates from the driver's latest location how long the ride's driver needs to reach the pickup,
+// or the dropoff once the ride has started
+// Returns ErrRideNoDriver before a driver accepts the ride and ErrRideNotInProgress once it is completed or cancelled
+func (s *RideService) GetRideETA(ctx context.Context, rideID, customerID int64) (*RideETA, error) {
+	ride, err := s.getCustomerRide(ctx, rideID, customerID)
+	if err != nil {
+		return nil, err
+	}
+	if ride.DriverID == nil {
+		return nil, ErrRideNoDriver
+	}
+
+	eta := &RideETA{RideID: ride.ID, DriverID: *ride.DriverID}
+	switch ride.Status {
+	case domain.RideStatusAccepted, domain.RideStatusArrived:
+		eta.Target = ETATargetPickup
+		eta.TargetLat, eta.TargetLng = ride.PickupLat, ride.PickupLng
+	case domain.RideStatusStarted:
+		eta.Target = ETATargetDropoff
+		eta.TargetLat, eta.TargetLng = ride.DropoffLat, ride.DropoffLng
+	default:
+		return nil, ErrRideNotInProgress
+	}
+
+	lat, lng, updatedAt, err := s.locationService.GetDriverLocation(ctx, eta.DriverID)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to get driver location for driver %d: %v", eta.DriverID, err))
+		return nil, ErrDriverLocationMissing
+	}
+	eta.DriverLat, eta.DriverLng = lat, lng
+	eta.LocationUpdatedAt = updatedAt
+
+	current := domain.Location{Latitude: lat, Longitude: lng}
+	eta.DistanceMeters = current.DistanceTo(domain.Location{Latitude: eta.TargetLat, Longitude: eta.TargetLng})
+	if minutes, ok := etaMinutes(eta.DistanceMeters, s.averageSpeedKmh); ok {
+		eta.EtaMinutes = &minutes
+	}
+
+	return eta, nil
+}
+
+// getDriverInfoWithLocation retrieves information about the ride's driver including current location
+// While the driver is on the way to the pickup the ETA is included if the driver's location is recent
+func (s *RideService) getDriverInfoWithLocation(ctx context.Context, ride *domain.Ride) (*DriverInfo, error) {
+	driverID := *ride.DriverID
 	driver, err := s.driverService.GetByID(ctx, driverID)
 	if err != nil {
 		logger.Error(ctx, fmt.Sprintf("Failed to get driver %d: %v", driverID, err))
@@ -283,27 +1324,65 @@ func (s *RideService) getDriverInfoWithLocation(ctx context.Context, driverID in
 			pingStr := lastPingAt.Format("2006-01-02 15:04:05")
 			driverInfo.LastPingAt = &pingStr
 		}
+
+		if ride.Status == domain.RideStatusAccepted && lastPingAt != nil && time.Since(*lastPingAt) <= driverLocationFreshness {
+			current := domain.Location{Latitude: currentLat, Longitude: currentLng}
+			pickup := domain.Location{Latitude: ride.PickupLat, Longitude: ride.PickupLng}
+			if eta, ok := etaMinutes(current.DistanceTo(pickup), s.averageSpeedKmh); ok {
+				driverInfo.EtaMinutes = &eta
+			}
+		}
 	}
 
 	return driverInfo, nil
 }
 
+// RideListSort is the sort of the admin ride list, the cursors it hands out are only accepted by it
+const RideListSort = "requested_at"
+
+// RideHistoryPage is one page of a user's rides along with the total across all pages
+// NextCursor fetches the page after it, it is only set by ListRides and is empty on the last page
+type RideHistoryPage struct {
+	Rides      []*domain.Ride `json:"rides"`
+	Total      int64          `json:"total"`
+	Limit      int            `json:"limit"`
+	Offset     int            `json:"offset"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+// RevenueReport is the platform's commission on rides completed over a date range
+type RevenueReport struct {
+	From                time.Time `json:"from"`
+	To                  time.Time `json:"to"`
+	Currency            string    `json:"currency"`
+	RideCount           int       `json:"ride_count"`
+	GrossFare           float64   `json:"gross_fare"`
+	Commission          float64   `json:"commission"`
+	CommissionFormatted string    `json:"commission_formatted"` // commission with its currency code, e.g. "BDT 34.00"
+}
+
 // RideStatusResponse contains ride status with driver information
 type RideStatusResponse struct {
-	RideID      int64       `json:"ride_id"`
-	CustomerID  int64       `json:"customer_id"`
-	PickupLat   float64     `json:"pickup_lat"`
-	PickupLng   float64     `json:"pickup_lng"`
-	DropoffLat  float64     `json:"dropoff_lat"`
-	DropoffLng  float64     `json:"dropoff_lng"`
-	Status      string      `json:"status"`
-	Fare        *float64    `json:"fare,omitempty"`
-	RequestedAt string      `json:"requested_at"`
-	AcceptedAt  *string     `json:"accepted_at,omitempty"`
-	StartedAt   *string     `json:"started_at,omitempty"`
-	CompletedAt *string     `json:"completed_at,omitempty"`
-	CancelledAt *string     `json:"cancelled_at,omitempty"`
-	Driver      *DriverInfo `json:"driver,omitempty"`
+	RideID             int64             `json:"ride_id"`
+	CustomerID         int64             `json:"customer_id"`
+	PickupLat          float64           `json:"pickup_lat"`
+	PickupLng          float64           `json:"pickup_lng"`
+	DropoffLat         float64           `json:"dropoff_lat"`
+	DropoffLng         float64           `json:"dropoff_lng"`
+	Waypoints          []domain.Location `json:"waypoints,omitempty"`
+	Status             string            `json:"status"`
+	Fare               *float64          `json:"fare,omitempty"`
+	FareFormatted      *string           `json:"fare_formatted,omitempty"` // fare with its currency code, e.g. "BDT 170.00"
+	Currency           string            `json:"currency"`
+	RequestedAt        string            `json:"requested_at"`
+	AcceptedAt         *string           `json:"accepted_at,omitempty"`
+	ArrivedAt          *string           `json:"arrived_at,omitempty"`
+	StartedAt          *string           `json:"started_at,omitempty"`
+	CompletedAt        *string           `json:"completed_at,omitempty"`
+	CancelledAt        *string           `json:"cancelled_at,omitempty"`
+	CancelledBy        string            `json:"cancelled_by,omitempty"`
+	CancellationReason string            `json:"cancellation_reason,omitempty"`
+	Driver             *DriverInfo       `json:"driver,omitempty"`
 }
 
 // DriverInfo contains driver details and current location
@@ -315,4 +1394,35 @@ type DriverInfo struct {
 	CurrentLat *float64 `json:"current_lat,omitempty"`
 	CurrentLng *float64 `json:"current_lng,omitempty"`
 	LastPingAt *string  `json:"last_ping_at,omitempty"`
+	EtaMinutes *int     `json:"eta_minutes,omitempty"` // driver to pickup, only while the ride is accepted
+}
+
+// Where a RideETA estimates the driver's arrival
+const (
+	ETATargetPickup  = "pickup"
+	ETATargetDropoff = "dropoff"
+)
+
+// RideETA is how far the ride's driver is from their next stop and how long they should take to get there
+type RideETA struct {
+	RideID            int64      `json:"ride_id"`
+	DriverID          int64      `json:"driver_id"`
+	Target            string     `json:"target"` // pickup until the ride starts, dropoff after
+	TargetLat         float64    `json:"target_lat"`
+	TargetLng         float64    `json:"target_lng"`
+	DriverLat         float64    `json:"driver_lat"`
+	DriverLng         float64    `json:"driver_lng"`
+	DistanceMeters    float64    `json:"distance_meters"`
+	EtaMinutes        *int       `json:"eta_minutes,omitempty"`
+	LocationUpdatedAt *time.Time `json:"location_updated_at,omitempty"` // when the driver location the ETA is based on was reported
+}
+
+// etaMinutes estimates the whole minutes needed to cover distanceMeters at speedKmh
+// Returns false when the speed is not positive
+func etaMinutes(distanceMeters, speedKmh float64) (int, bool) {
+	if speedKmh <= 0 {
+		return 0, false
+	}
+	minutes := (distanceMeters / 1000) / speedKmh * 60
+	return int(math.Ceil(minutes)), true
 }
@@ -1,17 +1,380 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/repository"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/config"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/metrics"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/pagination"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/testutil"
 )
 
-// Note: These tests are simplified unit tests that test the domain logic
+// Note: Most of these tests are simplified unit tests that test the domain logic
 // For full integration tests with MongoDB, see the repository layer tests
-// The RideService has concrete dependencies (not interfaces), so we focus on
-// testing the business logic and domain model behavior here
+// Service level tests use MockRideRepository in place of the MongoDB ride repository
+
+// MockRideRepository is a mock implementation of the ride repository
+type MockRideRepository struct {
+	mock.Mock
+}
+
+func (m *MockRideRepository) Create(ctx context.Context, ride *domain.Ride) error {
+	args := m.Called(ctx, ride)
+	return args.Error(0)
+}
+
+func (m *MockRideRepository) GetByID(ctx context.Context, id int64) (*domain.Ride, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Ride), args.Error(1)
+}
+
+func (m *MockRideRepository) Update(ctx context.Context, ride *domain.Ride, from domain.RideStatus) error {
+	args := m.Called(ctx, ride, from)
+	return args.Error(0)
+}
+
+func (m *MockRideRepository) GetRequestedRides(ctx context.Context) ([]*domain.Ride, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Ride), args.Error(1)
+}
+
+func (m *MockRideRepository) GetNearbyRequestedRides(ctx context.Context, driverID int64, rideType domain.RideType, lat, lng, maxDistanceMeters float64, freshness time.Duration, limit int) ([]*domain.Ride, error) {
+	args := m.Called(ctx, driverID, rideType, lat, lng, maxDistanceMeters, freshness, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Ride), args.Error(1)
+}
+
+func (m *MockRideRepository) GetNearbyRequestedRidesByGeohash(ctx context.Context, driverID int64, rideType domain.RideType, lat, lng, maxDistanceMeters float64, freshness time.Duration, limit int) ([]*domain.Ride, error) {
+	args := m.Called(ctx, driverID, rideType, lat, lng, maxDistanceMeters, freshness, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Ride), args.Error(1)
+}
+
+func (m *MockRideRepository) AddDeclinedDriver(ctx context.Context, rideID, driverID int64) error {
+	args := m.Called(ctx, rideID, driverID)
+	return args.Error(0)
+}
+
+// WithTransaction runs fn directly, the mock has no transactions to roll back
+func (m *MockRideRepository) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func (m *MockRideRepository) AcceptRide(ctx context.Context, rideID, driverID int64, acceptedAt time.Time) error {
+	args := m.Called(ctx, rideID, driverID, acceptedAt)
+	return args.Error(0)
+}
+
+func (m *MockRideRepository) ListRides(ctx context.Context, filter repository.RideFilter, page repository.Page) ([]*domain.Ride, int64, error) {
+	args := m.Called(ctx, filter, page)
+	if args.Get(0) == nil {
+		return nil, args.Get(1).(int64), args.Error(2)
+	}
+	return args.Get(0).([]*domain.Ride), args.Get(1).(int64), args.Error(2)
+}
+
+func (m *MockRideRepository) SetRideOffer(ctx context.Context, rideID int64, driverID *int64, expiresAt time.Time) error {
+	args := m.Called(ctx, rideID, driverID, expiresAt)
+	return args.Error(0)
+}
+
+func (m *MockRideRepository) AbandonRide(ctx context.Context, rideID int64, abandonment domain.RideAbandonment) error {
+	args := m.Called(ctx, rideID, abandonment)
+	return args.Error(0)
+}
+
+func (m *MockRideRepository) GetRidesAwaitingDispatch(ctx context.Context, now time.Time) ([]*domain.Ride, error) {
+	args := m.Called(ctx, now)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Ride), args.Error(1)
+}
+
+func (m *MockRideRepository) GetByCustomerID(ctx context.Context, customerID int64, status domain.RideStatus, limit, offset int) ([]*domain.Ride, int64, error) {
+	args := m.Called(ctx, customerID, status, limit, offset)
+	if args.Get(0) == nil {
+		return nil, args.Get(1).(int64), args.Error(2)
+	}
+	return args.Get(0).([]*domain.Ride), args.Get(1).(int64), args.Error(2)
+}
+
+func (m *MockRideRepository) GetCompletedByDriverID(ctx context.Context, driverID int64, from, to time.Time) ([]*domain.Ride, error) {
+	args := m.Called(ctx, driverID, from, to)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Ride), args.Error(1)
+}
+
+func (m *MockRideRepository) GetCompletedRides(ctx context.Context, from, to time.Time) ([]*domain.Ride, error) {
+	args := m.Called(ctx, from, to)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Ride), args.Error(1)
+}
+
+func (m *MockRideRepository) GetActiveRideByCustomerID(ctx context.Context, customerID int64) (*domain.Ride, error) {
+	args := m.Called(ctx, customerID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Ride), args.Error(1)
+}
+
+// expectNoActiveRide lets every customer request a ride
+func expectNoActiveRide(rideRepo *MockRideRepository) {
+	rideRepo.On("GetActiveRideByCustomerID", mock.Anything, mock.Anything).Return(nil, repository.ErrRideNotFound)
+}
+
+func (m *MockRideRepository) GetActiveRideByDriverID(ctx context.Context, driverID int64) (*domain.Ride, error) {
+	args := m.Called(ctx, driverID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Ride), args.Error(1)
+}
+
+func (m *MockRideRepository) GetStaleAcceptedRides(ctx context.Context, cutoff time.Time) ([]*domain.Ride, error) {
+	args := m.Called(ctx, cutoff)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Ride), args.Error(1)
+}
+
+func (m *MockRideRepository) GetBusyDriverIDs(ctx context.Context, driverIDs []int64) ([]int64, error) {
+	args := m.Called(ctx, driverIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]int64), args.Error(1)
+}
+
+func (m *MockRideRepository) GetByDriverID(ctx context.Context, driverID int64, limit, offset int) ([]*domain.Ride, int64, error) {
+	args := m.Called(ctx, driverID, limit, offset)
+	if args.Get(0) == nil {
+		return nil, args.Get(1).(int64), args.Error(2)
+	}
+	return args.Get(0).([]*domain.Ride), args.Get(1).(int64), args.Error(2)
+}
+
+func (m *MockRideRepository) GetStaleRequestedRides(ctx context.Context, cutoff time.Time) ([]*domain.Ride, error) {
+	args := m.Called(ctx, cutoff)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Ride), args.Error(1)
+}
+
+func (m *MockRideRepository) ExpireRide(ctx context.Context, ride *domain.Ride, cutoff time.Time) error {
+	args := m.Called(ctx, ride, cutoff)
+	return args.Error(0)
+}
+
+// fakeRideEventRepository keeps ride events in memory in the order they were recorded
+type fakeRideEventRepository struct {
+	events []*domain.RideEvent
+}
+
+func (r *fakeRideEventRepository) Create(ctx context.Context, event *domain.RideEvent) error {
+	r.events = append(r.events, event)
+	return nil
+}
+
+func (r *fakeRideEventRepository) GetByRideID(ctx context.Context, rideID int64) ([]*domain.RideEvent, error) {
+	events := []*domain.RideEvent{}
+	for _, event := range r.events {
+		if event.RideID == rideID {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+// testNearbyFreshness is the default nearby ride freshness of newTestRideService
+const testNearbyFreshness = 5 * time.Minute
+
+// newTestRideService builds a RideService on the mocks, new rides find no driver to be offered to
+func newTestRideService(rideRepo *MockRideRepository, onlineRepo *MockOnlineStatusRepository, locationRepo *MockLocationRepository) *RideService {
+	locationService := &LocationService{repo: locationRepo}
+	driverService := &DriverService{onlineStatusRepo: onlineRepo, locationService: locationService}
+	redisClient, _ := testutil.NewFakeRedis()
+
+	locationRepo.On("FindNearestDrivers", mock.Anything, mock.Anything, mock.Anything, testDispatchRadius, dispatchCandidateLimit).Return([]int64{}, nil).Maybe()
+	rideRepo.On("SetRideOffer", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+	eventRepo := &fakeRideEventRepository{}
+
+	return &RideService{
+		rideRepo:          rideRepo,
+		eventRepo:         eventRepo,
+		locationService:   locationService,
+		driverService:     driverService,
+		fareService:       newTestFareService(locationRepo),
+		surgeService:      NewSurgeService(testSurgeConfig, rideRepo, locationService),
+		dispatchService:   NewDispatchService(rideRepo, eventRepo, locationService, driverService, testDispatchRadius, 0, 0, testOfferTimeout, nil),
+		nearbyFreshness:   testNearbyFreshness,
+		metrics:           metrics.NewRideMetrics(prometheus.NewRegistry()),
+		redis:             redisClient,
+		idempotencyKeyTTL: time.Hour,
+		notifier:          NoopNotifier{},
+	}
+}
+
+func TestRideService_AcceptRide_DriverOnline(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	onlineRepo := new(MockOnlineStatusRepository)
+	service := newTestRideService(rideRepo, onlineRepo, new(MockLocationRepository))
+
+	ctx := context.Background()
+	driverID := int64(456)
+	ride := &domain.Ride{
+		ID:          1,
+		CustomerID:  123,
+		Status:      domain.RideStatusRequested,
+		RequestedAt: time.Now(),
+	}
+	ride.OfferTo(driverID, time.Now().Add(time.Minute))
+
+	onlineRepo.On("IsDriverOnline", ctx, driverID).Return(true, nil)
+	rideRepo.On("GetByID", ctx, int64(1)).Return(ride, nil)
+	rideRepo.On("AcceptRide", ctx, int64(1), driverID, mock.AnythingOfType("time.Time")).Return(nil)
+
+	err := service.AcceptRide(ctx, 1, driverID)
+
+	assert.NoError(t, err)
+	assert.Equal(t, domain.RideStatusAccepted, ride.Status)
+	assert.Equal(t, driverID, *ride.DriverID)
+	onlineRepo.AssertExpectations(t)
+	rideRepo.AssertExpectations(t)
+}
+
+func TestRideService_AcceptRide_DriverPingingForMoreThanTheCutoff(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	locationRepo := new(MockLocationRepository)
+	driverRepo := new(MockDriverRepository)
+	service := newTestRideService(rideRepo, nil, locationRepo)
+
+	clock := time.Now()
+	now := func() time.Time { return clock }
+	onlineRepo := newFakeOnlineStatusRepository(now)
+	service.driverService.onlineStatusRepo = onlineRepo
+	service.driverService.driverRepo = driverRepo
+	service.driverService.rideRepo = rideRepo
+	service.driverService.now = now
+
+	ctx := context.Background()
+	driverID := int64(456)
+	require.NoError(t, onlineRepo.UpsertOnlineDriver(ctx, driverID, 23.8100, 90.4120))
+
+	locationRepo.On("UpdateDriverLocation", ctx, driverID, 23.8100, 90.4120).Return(nil)
+	rideRepo.On("GetActiveRideByDriverID", ctx, driverID).Return(nil, repository.ErrRideNotFound)
+	driverRepo.On("UpdatePing", ctx, driverID, 23.8100, 90.4120, mock.AnythingOfType("time.Time")).Return(nil)
+
+	// The driver went online 5 minutes ago and has pinged every minute since
+	for i := 0; i < 5; i++ {
+		clock = clock.Add(time.Minute)
+		require.NoError(t, service.driverService.UpdateLocation(ctx, driverID, 23.8100, 90.4120))
+	}
+
+	ride := &domain.Ride{
+		ID:          1,
+		CustomerID:  123,
+		Status:      domain.RideStatusRequested,
+		RequestedAt: time.Now(),
+	}
+	ride.OfferTo(driverID, time.Now().Add(time.Minute))
+	rideRepo.On("GetByID", ctx, int64(1)).Return(ride, nil)
+	rideRepo.On("AcceptRide", ctx, int64(1), driverID, mock.AnythingOfType("time.Time")).Return(nil)
+
+	err := service.AcceptRide(ctx, 1, driverID)
+
+	assert.NoError(t, err)
+	assert.Equal(t, domain.RideStatusAccepted, ride.Status)
+}
+
+func TestRideService_AcceptRide_AlreadyAcceptedByAnotherDriver(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	onlineRepo := new(MockOnlineStatusRepository)
+	service := newTestRideService(rideRepo, onlineRepo, new(MockLocationRepository))
+
+	ctx := context.Background()
+	driverID := int64(456)
+	ride := &domain.Ride{
+		ID:          1,
+		CustomerID:  123,
+		Status:      domain.RideStatusRequested,
+		RequestedAt: time.Now(),
+	}
+	ride.OfferTo(driverID, time.Now().Add(time.Minute))
+
+	onlineRepo.On("IsDriverOnline", ctx, driverID).Return(true, nil)
+	rideRepo.On("GetByID", ctx, int64(1)).Return(ride, nil)
+	rideRepo.On("AcceptRide", ctx, int64(1), driverID, mock.AnythingOfType("time.Time")).Return(repository.ErrRideNotAcceptable)
+
+	err := service.AcceptRide(ctx, 1, driverID)
+
+	assert.ErrorIs(t, err, ErrRideAlreadyAccepted)
+	assert.Equal(t, float64(0), promtestutil.ToFloat64(service.metrics.RidesAccepted))
+	rideRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRideService_AcceptRide_DriverOffline(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	onlineRepo := new(MockOnlineStatusRepository)
+	service := newTestRideService(rideRepo, onlineRepo, new(MockLocationRepository))
+
+	ctx := context.Background()
+	driverID := int64(456)
+
+	onlineRepo.On("IsDriverOnline", ctx, driverID).Return(false, nil)
+
+	err := service.AcceptRide(ctx, 1, driverID)
+
+	assert.ErrorIs(t, err, ErrDriverNotOnline)
+	assert.EqualError(t, err, "driver must be online to accept rides")
+	rideRepo.AssertNotCalled(t, "GetByID", mock.Anything, mock.Anything)
+	rideRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRideService_AcceptRide_OnlineCheckError(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	onlineRepo := new(MockOnlineStatusRepository)
+	service := newTestRideService(rideRepo, onlineRepo, new(MockLocationRepository))
+
+	ctx := context.Background()
+	driverID := int64(456)
+
+	onlineRepo.On("IsDriverOnline", ctx, driverID).Return(false, errors.New("database error"))
+
+	err := service.AcceptRide(ctx, 1, driverID)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "database error")
+	rideRepo.AssertNotCalled(t, "GetByID", mock.Anything, mock.Anything)
+}
 
 func TestRide_Accept(t *testing.T) {
 	ride := &domain.Ride{
@@ -48,6 +411,33 @@ func TestRide_Accept_Pending(t *testing.T) {
 	assert.Equal(t, driverID, *ride.DriverID)
 }
 
+func TestRide_OfferTo_Pending(t *testing.T) {
+	ride := &domain.Ride{ID: 1, CustomerID: 123, Status: domain.RideStatusRequested, RequestedAt: time.Now()}
+
+	// A ride is pending while it is offered to a driver
+	ride.OfferTo(456, time.Now().Add(time.Minute))
+	assert.Equal(t, domain.RideStatusPending, ride.Status)
+
+	// and requested again once the offer is withdrawn
+	ride.ClearOffer(time.Now().Add(time.Minute))
+	assert.Equal(t, domain.RideStatusRequested, ride.Status)
+
+	ride.OfferTo(789, time.Now().Add(time.Minute))
+	require.NoError(t, ride.Accept(789))
+	assert.Equal(t, domain.RideStatusAccepted, ride.Status)
+}
+
+func TestRideStatus_Pending(t *testing.T) {
+	assert.Equal(t, domain.RideStatus("pending"), domain.RideStatusPending)
+	assert.True(t, domain.RideStatusPending.IsValid())
+	assert.False(t, domain.RideStatusPending.IsTerminal())
+
+	assert.True(t, domain.RideStatusRequested.IsAwaitingDriver())
+	assert.True(t, domain.RideStatusPending.IsAwaitingDriver())
+	assert.False(t, domain.RideStatusAccepted.IsAwaitingDriver())
+	assert.False(t, domain.RideStatusCancelled.IsAwaitingDriver())
+}
+
 func TestRide_Accept_AlreadyAccepted(t *testing.T) {
 	existingDriverID := int64(789)
 	ride := &domain.Ride{
@@ -75,13 +465,79 @@ func TestRide_Start(t *testing.T) {
 		DriverID:   &driverID,
 	}
 
-	err := ride.Start()
+	err := ride.Start(false)
 
 	assert.NoError(t, err)
 	assert.Equal(t, domain.RideStatusStarted, ride.Status)
 	assert.NotNil(t, ride.StartedAt)
 }
 
+func TestRide_Start_RequiresArrival(t *testing.T) {
+	driverID := int64(456)
+	ride := &domain.Ride{
+		ID:         1,
+		CustomerID: 123,
+		Status:     domain.RideStatusAccepted,
+		DriverID:   &driverID,
+	}
+
+	err := ride.Start(true)
+
+	assert.ErrorIs(t, err, domain.ErrRideNotArrived)
+	assert.Equal(t, domain.RideStatusAccepted, ride.Status)
+	assert.Nil(t, ride.StartedAt)
+
+	require.NoError(t, ride.MarkArrived(driverID, time.Now()))
+	require.NoError(t, ride.Start(true))
+	assert.Equal(t, domain.RideStatusStarted, ride.Status)
+	assert.NotNil(t, ride.StartedAt)
+}
+
+func TestRide_MarkArrived(t *testing.T) {
+	driverID := int64(456)
+	ride := &domain.Ride{
+		ID:         1,
+		CustomerID: 123,
+		Status:     domain.RideStatusAccepted,
+		DriverID:   &driverID,
+	}
+	now := time.Now()
+
+	err := ride.MarkArrived(driverID, now)
+
+	require.NoError(t, err)
+	assert.Equal(t, domain.RideStatusArrived, ride.Status)
+	require.NotNil(t, ride.ArrivedAt)
+	assert.True(t, now.Equal(*ride.ArrivedAt))
+}
+
+func TestRide_MarkArrived_Invalid(t *testing.T) {
+	driverID := int64(456)
+
+	tests := []struct {
+		name     string
+		status   domain.RideStatus
+		driverID int64
+	}{
+		{"requested", domain.RideStatusRequested, driverID},
+		{"already arrived", domain.RideStatusArrived, driverID},
+		{"started", domain.RideStatusStarted, driverID},
+		{"other driver", domain.RideStatusAccepted, 789},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ride := &domain.Ride{ID: 1, CustomerID: 123, Status: tt.status, DriverID: &driverID}
+
+			err := ride.MarkArrived(tt.driverID, time.Now())
+
+			assert.ErrorIs(t, err, domain.ErrRideNotArrivable)
+			assert.Equal(t, tt.status, ride.Status)
+			assert.Nil(t, ride.ArrivedAt)
+		})
+	}
+}
+
 func TestRide_Start_NotAccepted(t *testing.T) {
 	ride := &domain.Ride{
 		ID:         1,
@@ -89,7 +545,7 @@ func TestRide_Start_NotAccepted(t *testing.T) {
 		Status:     domain.RideStatusRequested,
 	}
 
-	err := ride.Start()
+	err := ride.Start(false)
 
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "ride must be accepted before starting")
@@ -138,7 +594,7 @@ func TestRide_Cancel_Requested(t *testing.T) {
 		RequestedAt: time.Now(),
 	}
 
-	err := ride.Cancel()
+	err := ride.Cancel(domain.CancelledByCustomer, "")
 
 	assert.NoError(t, err)
 	assert.Equal(t, domain.RideStatusCancelled, ride.Status)
@@ -156,11 +612,13 @@ func TestRide_Cancel_Accepted(t *testing.T) {
 		AcceptedAt: &now,
 	}
 
-	err := ride.Cancel()
+	err := ride.Cancel(domain.CancelledByDriver, "customer not at pickup")
 
 	assert.NoError(t, err)
 	assert.Equal(t, domain.RideStatusCancelled, ride.Status)
 	assert.NotNil(t, ride.CancelledAt)
+	assert.Equal(t, domain.CancelledByDriver, ride.CancelledBy)
+	assert.Equal(t, "customer not at pickup", ride.CancellationReason)
 }
 
 func TestRide_Cancel_AlreadyCompleted(t *testing.T) {
@@ -174,13 +632,43 @@ func TestRide_Cancel_AlreadyCompleted(t *testing.T) {
 		CompletedAt: &now,
 	}
 
-	err := ride.Cancel()
+	err := ride.Cancel(domain.CancelledByCustomer, "")
 
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "cannot cancel completed ride")
 	assert.Equal(t, domain.RideStatusCompleted, ride.Status)
 }
 
+func TestRide_Cancel_InvalidCancelledBy(t *testing.T) {
+	ride := &domain.Ride{
+		ID:          1,
+		CustomerID:  123,
+		Status:      domain.RideStatusRequested,
+		RequestedAt: time.Now(),
+	}
+
+	err := ride.Cancel("admin", "changed my mind")
+
+	assert.ErrorIs(t, err, domain.ErrInvalidCancelledBy)
+	assert.Equal(t, domain.RideStatusRequested, ride.Status)
+	assert.Nil(t, ride.CancelledAt)
+}
+
+func TestRide_Cancel_InvalidReason(t *testing.T) {
+	ride := &domain.Ride{
+		ID:          1,
+		CustomerID:  123,
+		Status:      domain.RideStatusRequested,
+		RequestedAt: time.Now(),
+	}
+
+	err := ride.Cancel(domain.CancelledByCustomer, strings.Repeat("a", domain.MaxCancellationReasonLength+1))
+
+	assert.ErrorIs(t, err, domain.ErrInvalidCancellationReason)
+	assert.Equal(t, domain.RideStatusRequested, ride.Status)
+	assert.Empty(t, ride.CancellationReason)
+}
+
 func TestValidateDriver(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -285,8 +773,12 @@ func TestRideStatusTransitions(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, domain.RideStatusAccepted, ride.Status)
 
-	// Step 2: Start ride
-	err = ride.Start()
+	// Step 2: Arrive at pickup and start ride
+	err = ride.MarkArrived(driverID, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, domain.RideStatusArrived, ride.Status)
+
+	err = ride.Start(true)
 	assert.NoError(t, err)
 	assert.Equal(t, domain.RideStatusStarted, ride.Status)
 
@@ -318,7 +810,7 @@ func TestRideStatusTransitions_CancelAfterAccept(t *testing.T) {
 	assert.Equal(t, domain.RideStatusAccepted, ride.Status)
 
 	// Cancel ride
-	err = ride.Cancel()
+	err = ride.Cancel(domain.CancelledByCustomer, "")
 	assert.NoError(t, err)
 	assert.Equal(t, domain.RideStatusCancelled, ride.Status)
 	assert.NotNil(t, ride.CancelledAt)
@@ -363,11 +855,11 @@ func TestRideStatusTransitions_InvalidTransitions(t *testing.T) {
 			var err error
 			switch tt.action {
 			case "start":
-				err = ride.Start()
+				err = ride.Start(false)
 			case "complete":
 				err = ride.Complete()
 			case "cancel":
-				err = ride.Cancel()
+				err = ride.Cancel(domain.CancelledByCustomer, "")
 			}
 
 			if tt.shouldErr {
@@ -378,3 +870,2268 @@ func TestRideStatusTransitions_InvalidTransitions(t *testing.T) {
 		})
 	}
 }
+
+func TestRideService_CompleteRide_Started(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	locationRepo := new(MockLocationRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), locationRepo)
+
+	ctx := context.Background()
+	driverID := int64(456)
+	startedAt := time.Now().Add(-15 * time.Minute)
+	ride := &domain.Ride{
+		ID:          1,
+		CustomerID:  123,
+		DriverID:    &driverID,
+		PickupLat:   23.8100,
+		PickupLng:   90.4120,
+		DropoffLat:  23.7509,
+		DropoffLng:  90.3761,
+		Status:      domain.RideStatusStarted,
+		RequestedAt: startedAt.Add(-5 * time.Minute),
+		StartedAt:   &startedAt,
+	}
+
+	rideRepo.On("GetByID", ctx, int64(1)).Return(ride, nil)
+	locationRepo.On("GetRideLocationHistory", ctx, int64(1)).Return(nil, nil)
+	rideRepo.On("Update", ctx, ride, mock.Anything).Return(nil)
+
+	err := service.CompleteRide(ctx, 1, driverID)
+
+	assert.NoError(t, err)
+	assert.Equal(t, domain.RideStatusCompleted, ride.Status)
+	assert.NotNil(t, ride.CompletedAt)
+	assert.NotNil(t, ride.Fare)
+	require.NotNil(t, ride.CommissionRate, "The commission rate in effect is recorded on the ride")
+	assert.Equal(t, 0.2, *ride.CommissionRate)
+	rideRepo.AssertExpectations(t)
+}
+
+func TestRideService_CompleteRide_Accepted(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+
+	ctx := context.Background()
+	driverID := int64(456)
+	ride := &domain.Ride{
+		ID:          1,
+		CustomerID:  123,
+		DriverID:    &driverID,
+		Status:      domain.RideStatusAccepted,
+		RequestedAt: time.Now(),
+	}
+
+	rideRepo.On("GetByID", ctx, int64(1)).Return(ride, nil)
+
+	err := service.CompleteRide(ctx, 1, driverID)
+
+	assert.EqualError(t, err, "ride must be started before completing")
+	assert.Equal(t, domain.RideStatusAccepted, ride.Status)
+	rideRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRideService_DeclineRide(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+
+	ctx := context.Background()
+	driverID := int64(456)
+	ride := &domain.Ride{
+		ID:          1,
+		CustomerID:  123,
+		Status:      domain.RideStatusRequested,
+		RequestedAt: time.Now(),
+	}
+
+	rideRepo.On("GetByID", ctx, int64(1)).Return(ride, nil)
+	rideRepo.On("AddDeclinedDriver", ctx, int64(1), driverID).Return(nil)
+
+	err := service.DeclineRide(ctx, 1, driverID)
+
+	assert.NoError(t, err)
+	assert.Equal(t, domain.RideStatusRequested, ride.Status)
+	rideRepo.AssertExpectations(t)
+}
+
+func TestRideService_DeclineRide_AlreadyAccepted(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+
+	ctx := context.Background()
+	otherDriverID := int64(789)
+	ride := &domain.Ride{
+		ID:          1,
+		CustomerID:  123,
+		DriverID:    &otherDriverID,
+		Status:      domain.RideStatusAccepted,
+		RequestedAt: time.Now(),
+	}
+
+	rideRepo.On("GetByID", ctx, int64(1)).Return(ride, nil)
+
+	err := service.DeclineRide(ctx, 1, 456)
+
+	assert.Error(t, err)
+	rideRepo.AssertNotCalled(t, "AddDeclinedDriver", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRideService_CancelRide_Driver(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+
+	ctx := context.Background()
+	driverID := int64(456)
+	acceptedAt := time.Now()
+	startedAt := time.Now()
+	ride := &domain.Ride{
+		ID:          1,
+		CustomerID:  123,
+		DriverID:    &driverID,
+		Status:      domain.RideStatusStarted,
+		RequestedAt: time.Now(),
+		AcceptedAt:  &acceptedAt,
+		StartedAt:   &startedAt,
+	}
+
+	rideRepo.On("GetByID", ctx, int64(1)).Return(ride, nil)
+	rideRepo.On("Update", ctx, ride, mock.Anything).Return(nil)
+
+	err := service.CancelRide(ctx, 1, driverID, "vehicle breakdown")
+
+	assert.NoError(t, err)
+	assert.Equal(t, domain.RideStatusCancelled, ride.Status)
+	assert.Equal(t, domain.CancelledByDriver, ride.CancelledBy)
+	assert.Equal(t, "vehicle breakdown", ride.CancellationReason)
+	rideRepo.AssertExpectations(t)
+}
+
+func TestRideService_DriverActions_OtherDriver(t *testing.T) {
+	driverID := int64(456)
+	otherDriverID := int64(789)
+
+	tests := []struct {
+		name   string
+		status domain.RideStatus
+		act    func(s *RideService, ctx context.Context) error
+	}{
+		{"start", domain.RideStatusArrived, func(s *RideService, ctx context.Context) error {
+			return s.StartRide(ctx, 1, otherDriverID)
+		}},
+		{"complete", domain.RideStatusStarted, func(s *RideService, ctx context.Context) error {
+			return s.CompleteRide(ctx, 1, otherDriverID)
+		}},
+		{"cancel started", domain.RideStatusStarted, func(s *RideService, ctx context.Context) error {
+			return s.CancelRide(ctx, 1, otherDriverID, "")
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rideRepo := new(MockRideRepository)
+			service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+
+			ctx := context.Background()
+			ride := &domain.Ride{
+				ID:          1,
+				CustomerID:  123,
+				DriverID:    &driverID,
+				Status:      tt.status,
+				RequestedAt: time.Now(),
+			}
+			rideRepo.On("GetByID", ctx, int64(1)).Return(ride, nil)
+
+			err := tt.act(service, ctx)
+
+			assert.ErrorIs(t, err, ErrNotRideDriver)
+			assert.Equal(t, tt.status, ride.Status)
+			rideRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestRideService_CancelRide_Unassigned(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+
+	ctx := context.Background()
+	ride := &domain.Ride{
+		ID:          1,
+		CustomerID:  123,
+		Status:      domain.RideStatusRequested,
+		RequestedAt: time.Now(),
+	}
+	rideRepo.On("GetByID", ctx, int64(1)).Return(ride, nil)
+
+	// Drivers decline rides they were not assigned, they cannot cancel them for the customer
+	err := service.CancelRide(ctx, 1, 456, "")
+
+	assert.ErrorIs(t, err, ErrNotRideDriver)
+	assert.Equal(t, domain.RideStatusRequested, ride.Status)
+	rideRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRideService_CancelRide_AcceptedIsRedispatched(t *testing.T) {
+	now := time.Now()
+	dispatch, m := newTestDispatchService(now)
+	rideRepo := new(MockRideRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+	service.dispatchService = dispatch
+
+	ctx := context.Background()
+	driverID := int64(456)
+	acceptedAt := time.Now()
+	ride := &domain.Ride{
+		ID:          1,
+		CustomerID:  123,
+		DriverID:    &driverID,
+		Status:      domain.RideStatusAccepted,
+		RideType:    domain.RideTypeEconomy,
+		PickupLat:   23.8100,
+		PickupLng:   90.4120,
+		RequestedAt: time.Now(),
+		AcceptedAt:  &acceptedAt,
+	}
+
+	rideRepo.On("GetByID", ctx, int64(1)).Return(ride, nil)
+	rideRepo.On("AbandonRide", ctx, int64(1), mock.MatchedBy(func(a domain.RideAbandonment) bool {
+		return a.DriverID == driverID && a.Reason == "vehicle breakdown"
+	})).Return(nil)
+	// The abandoning driver is nearest but must not be offered the ride again
+	m.locationRepo.On("FindNearestDrivers", ctx, 23.8100, 90.4120, testDispatchRadius, dispatchCandidateLimit).Return([]int64{456, 789}, nil)
+	m.onlineRepo.On("GetOnlineDriversByIDs", ctx, []int64{789}).Return([]int64{789}, nil)
+	m.rideRepo.On("GetBusyDriverIDs", ctx, []int64{789}).Return([]int64{}, nil)
+	m.driverRepo.On("GetByID", ctx, int64(789)).Return(&domain.Driver{ID: 789}, nil)
+	m.rideRepo.On("SetRideOffer", ctx, int64(1), offeredTo(789), now.Add(testOfferTimeout)).Return(nil)
+
+	err := service.CancelRide(ctx, 1, driverID, "vehicle breakdown")
+
+	require.NoError(t, err)
+	assert.Equal(t, domain.RideStatusPending, ride.Status)
+	assert.Nil(t, ride.DriverID)
+	assert.Nil(t, ride.AcceptedAt)
+	assert.Nil(t, ride.CancelledAt)
+	assert.Contains(t, ride.DeclinedBy, driverID)
+	require.Len(t, ride.Abandonments, 1)
+	assert.Equal(t, driverID, ride.Abandonments[0].DriverID)
+	require.NotNil(t, ride.RequeuedAt, "The request timeout starts over from the abandonment")
+	assert.Equal(t, ride.Abandonments[0].AbandonedAt, *ride.RequeuedAt)
+	assert.Equal(t, float64(1), promtestutil.ToFloat64(service.metrics.RidesAbandoned))
+	rideRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything, mock.Anything)
+	rideRepo.AssertExpectations(t)
+	m.rideRepo.AssertExpectations(t)
+}
+
+func TestRideService_DriverAbandonRide_NotDriver(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+
+	ctx := context.Background()
+	driverID := int64(456)
+	ride := &domain.Ride{
+		ID:          1,
+		CustomerID:  123,
+		DriverID:    &driverID,
+		Status:      domain.RideStatusAccepted,
+		RequestedAt: time.Now(),
+	}
+
+	rideRepo.On("GetByID", ctx, int64(1)).Return(ride, nil)
+
+	err := service.DriverAbandonRide(ctx, 1, 789, "")
+
+	assert.ErrorIs(t, err, ErrRideNotAbandonable)
+	assert.Equal(t, domain.RideStatusAccepted, ride.Status)
+	rideRepo.AssertNotCalled(t, "AbandonRide", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRideService_DriverAbandonRide_AlreadyStarted(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+
+	ctx := context.Background()
+	driverID := int64(456)
+	ride := &domain.Ride{
+		ID:          1,
+		CustomerID:  123,
+		DriverID:    &driverID,
+		Status:      domain.RideStatusAccepted,
+		RequestedAt: time.Now(),
+	}
+
+	// The ride was started between reading and writing it
+	rideRepo.On("GetByID", ctx, int64(1)).Return(ride, nil)
+	rideRepo.On("AbandonRide", ctx, int64(1), mock.Anything).Return(repository.ErrRideNotAbandonable)
+
+	err := service.DriverAbandonRide(ctx, 1, driverID, "")
+
+	assert.ErrorIs(t, err, ErrRideNotAbandonable)
+	rideRepo.AssertNotCalled(t, "SetRideOffer", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRideService_AcceptRide_AfterAbandoning(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	onlineRepo := new(MockOnlineStatusRepository)
+	service := newTestRideService(rideRepo, onlineRepo, new(MockLocationRepository))
+
+	ctx := context.Background()
+	driverID := int64(456)
+	ride := &domain.Ride{
+		ID:          1,
+		CustomerID:  123,
+		DriverID:    &driverID,
+		Status:      domain.RideStatusAccepted,
+		RequestedAt: time.Now(),
+	}
+	require.NoError(t, ride.Abandon(driverID, "", time.Now()))
+
+	onlineRepo.On("IsDriverOnline", ctx, driverID).Return(true, nil)
+	rideRepo.On("GetByID", ctx, int64(1)).Return(ride, nil)
+
+	err := service.AcceptRide(ctx, 1, driverID)
+
+	assert.ErrorIs(t, err, ErrRideNotOffered)
+	rideRepo.AssertNotCalled(t, "AcceptRide", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRideService_MarkArrived(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+
+	ctx := context.Background()
+	driverID := int64(456)
+	acceptedAt := time.Now()
+	ride := &domain.Ride{
+		ID:          1,
+		CustomerID:  123,
+		DriverID:    &driverID,
+		Status:      domain.RideStatusAccepted,
+		RequestedAt: time.Now(),
+		AcceptedAt:  &acceptedAt,
+	}
+
+	rideRepo.On("GetByID", ctx, int64(1)).Return(ride, nil)
+	rideRepo.On("Update", ctx, ride, mock.Anything).Return(nil)
+
+	err := service.MarkArrived(ctx, 1, driverID)
+
+	require.NoError(t, err)
+	assert.Equal(t, domain.RideStatusArrived, ride.Status)
+	assert.NotNil(t, ride.ArrivedAt)
+	rideRepo.AssertExpectations(t)
+}
+
+func TestRideService_MarkArrived_OtherDriver(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+
+	ctx := context.Background()
+	driverID := int64(456)
+	ride := &domain.Ride{
+		ID:          1,
+		CustomerID:  123,
+		DriverID:    &driverID,
+		Status:      domain.RideStatusAccepted,
+		RequestedAt: time.Now(),
+	}
+
+	rideRepo.On("GetByID", ctx, int64(1)).Return(ride, nil)
+
+	err := service.MarkArrived(ctx, 1, 789)
+
+	assert.ErrorIs(t, err, domain.ErrRideNotArrivable)
+	rideRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRideService_StartRide_RequiresArrival(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+	service.requireArrival = true
+
+	ctx := context.Background()
+	driverID := int64(456)
+	ride := &domain.Ride{
+		ID:          1,
+		CustomerID:  123,
+		DriverID:    &driverID,
+		Status:      domain.RideStatusAccepted,
+		RequestedAt: time.Now(),
+	}
+
+	rideRepo.On("GetByID", ctx, int64(1)).Return(ride, nil)
+	rideRepo.On("Update", ctx, ride, mock.Anything).Return(nil)
+
+	err := service.StartRide(ctx, 1, driverID)
+	assert.ErrorIs(t, err, domain.ErrRideNotArrived)
+	rideRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything, mock.Anything)
+
+	require.NoError(t, service.MarkArrived(ctx, 1, driverID))
+	require.NoError(t, service.StartRide(ctx, 1, driverID))
+	assert.Equal(t, domain.RideStatusStarted, ride.Status)
+}
+
+func TestRideService_EstimateFare(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	locationRepo := new(MockLocationRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), locationRepo)
+	service.averageSpeedKmh = 20
+	expectNoSurge(rideRepo, locationRepo)
+	expectNoActiveRide(rideRepo)
+
+	ctx := context.Background()
+	estimate, err := service.EstimateFare(ctx, 123, "", 23.8100, 90.4120, 23.7509, 90.3761)
+
+	require.NoError(t, err)
+	assert.Equal(t, domain.RideTypeEconomy, estimate.RideType)
+	assert.InDelta(t, 7500, estimate.DistanceMeters, 200) // ~7.5km straight line
+	assert.Equal(t, 23, estimate.DurationMinutes)         // ~7.5km at 20km/h
+	assert.Equal(t, NoSurge, estimate.SurgeMultiplier)
+	assert.Less(t, estimate.MinFare, estimate.MaxFare)
+	assert.Equal(t, DefaultFareCurrency, estimate.Currency)
+	assert.Equal(t, service.fareService.FormatFare(estimate.MinFare), estimate.MinFareFormatted)
+	assert.Equal(t, service.fareService.FormatFare(estimate.MaxFare), estimate.MaxFareFormatted)
+	rideRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+
+	// The same route quotes the same estimate
+	again, err := service.EstimateFare(ctx, 123, domain.RideTypeEconomy, 23.8100, 90.4120, 23.7509, 90.3761)
+	require.NoError(t, err)
+	assert.Equal(t, estimate, again)
+
+	// A longer route costs more
+	longer, err := service.EstimateFare(ctx, 123, domain.RideTypeEconomy, 23.8100, 90.4120, 23.6500, 90.3000)
+	require.NoError(t, err)
+	assert.Greater(t, longer.DistanceMeters, estimate.DistanceMeters)
+	assert.Greater(t, longer.DurationMinutes, estimate.DurationMinutes)
+	assert.Greater(t, longer.MinFare, estimate.MinFare)
+	assert.Greater(t, longer.MaxFare, estimate.MaxFare)
+}
+
+func TestRideService_EstimateFare_Surge(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	locationRepo := new(MockLocationRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), locationRepo)
+	expectSurgeDemand(rideRepo, locationRepo, 3, 2)
+
+	estimate, err := service.EstimateFare(context.Background(), 123, domain.RideTypeEconomy, 23.8100, 90.4120, 23.7509, 90.3761)
+
+	require.NoError(t, err)
+	assert.Equal(t, 2.0, estimate.SurgeMultiplier)
+	minFare, maxFare := service.fareService.EstimateFareRange(estimate.DistanceMeters, 0, NoSurge)
+	assert.InDelta(t, 2*minFare, estimate.MinFare, 0.1)
+	assert.InDelta(t, 2*maxFare, estimate.MaxFare, 0.1)
+}
+
+func TestRideService_EstimateFare_Invalid(t *testing.T) {
+	service := newTestRideService(new(MockRideRepository), new(MockOnlineStatusRepository), new(MockLocationRepository))
+	ctx := context.Background()
+
+	_, err := service.EstimateFare(ctx, 123, "helicopter", 23.8100, 90.4120, 23.7509, 90.3761)
+	assert.ErrorIs(t, err, domain.ErrInvalidRideType)
+
+	_, err = service.EstimateFare(ctx, 123, domain.RideTypeEconomy, 23.8100, 90.4120, 23.8100, 90.4120)
+	assert.ErrorIs(t, err, domain.ErrSamePickupDropoff)
+
+	_, err = service.EstimateFare(ctx, 123, domain.RideTypeEconomy, 123, 90.4120, 23.7509, 90.3761)
+	assert.ErrorIs(t, err, domain.ErrInvalidLatitude)
+}
+
+func TestRideService_CancelRideForCustomer(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+
+	ctx := context.Background()
+	ride := &domain.Ride{
+		ID:          1,
+		CustomerID:  123,
+		Status:      domain.RideStatusRequested,
+		RequestedAt: time.Now(),
+	}
+
+	rideRepo.On("GetByID", ctx, int64(1)).Return(ride, nil)
+	rideRepo.On("Update", ctx, ride, domain.RideStatusRequested).Return(nil)
+
+	cancellation, err := service.CancelRideForCustomer(ctx, 1, 123, "changed my mind")
+
+	require.NoError(t, err)
+	assert.Equal(t, domain.RideStatusCancelled, ride.Status)
+	assert.Equal(t, domain.CancelledByCustomer, ride.CancelledBy)
+	assert.Equal(t, "changed my mind", ride.CancellationReason)
+	assert.Nil(t, ride.Fare, "No fee within the free window before a driver accepted")
+	assert.Zero(t, cancellation.CancellationFee)
+	assert.Equal(t, "BDT 0.00", cancellation.CancellationFeeFormatted)
+	rideRepo.AssertExpectations(t)
+}
+
+func TestRideService_CancelRideForCustomer_AcceptedMeanwhile(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+
+	ctx := context.Background()
+	driverID := int64(456)
+	ride := &domain.Ride{
+		ID:              1,
+		CustomerID:      123,
+		Status:          domain.RideStatusPending,
+		OfferedDriverID: &driverID,
+		RequestedAt:     time.Now(),
+	}
+
+	// The offered driver accepted the ride after the customer's cancel read it
+	rideRepo.On("GetByID", ctx, int64(1)).Return(ride, nil)
+	rideRepo.On("Update", ctx, ride, domain.RideStatusPending).Return(repository.ErrRideStatusChanged)
+
+	_, err := service.CancelRideForCustomer(ctx, 1, 123, "")
+
+	assert.ErrorIs(t, err, repository.ErrRideStatusChanged)
+	assert.ErrorIs(t, err, domain.ErrConflict)
+	assert.Equal(t, float64(0), promtestutil.ToFloat64(service.metrics.RidesCancelled.WithLabelValues(domain.CancelledByCustomer)))
+}
+
+func TestRideService_CancelRideForCustomer_AfterFreeWindow(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+
+	ctx := context.Background()
+	ride := &domain.Ride{
+		ID:          1,
+		CustomerID:  123,
+		Status:      domain.RideStatusRequested,
+		RequestedAt: time.Now().Add(-5 * time.Minute),
+	}
+
+	rideRepo.On("GetByID", ctx, int64(1)).Return(ride, nil)
+	rideRepo.On("Update", ctx, ride, mock.Anything).Return(nil)
+
+	cancellation, err := service.CancelRideForCustomer(ctx, 1, 123, "")
+
+	require.NoError(t, err)
+	assert.Equal(t, 50.0, cancellation.CancellationFee)
+	require.NotNil(t, ride.Fare)
+	assert.Equal(t, 50.0, *ride.Fare)
+}
+
+func TestRideService_CancelRideForCustomer_AfterAcceptance(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+
+	ctx := context.Background()
+	driverID := int64(456)
+	acceptedAt := time.Now()
+	ride := &domain.Ride{
+		ID:          1,
+		CustomerID:  123,
+		DriverID:    &driverID,
+		Status:      domain.RideStatusAccepted,
+		RequestedAt: time.Now().Add(-20 * time.Second),
+		AcceptedAt:  &acceptedAt,
+	}
+
+	rideRepo.On("GetByID", ctx, int64(1)).Return(ride, nil)
+	rideRepo.On("Update", ctx, ride, mock.Anything).Return(nil)
+
+	cancellation, err := service.CancelRideForCustomer(ctx, 1, 123, "driver too far")
+
+	require.NoError(t, err)
+	assert.Equal(t, domain.RideStatusCancelled, ride.Status)
+	assert.Equal(t, int64(1), cancellation.RideID)
+	assert.Equal(t, 50.0, cancellation.CancellationFee, "Charged once a driver accepted, even within the free window")
+	assert.Equal(t, "BDT", cancellation.Currency)
+	assert.Equal(t, "BDT 50.00", cancellation.CancellationFeeFormatted)
+	require.NotNil(t, ride.Fare)
+	assert.Equal(t, 50.0, *ride.Fare)
+}
+
+func TestRideService_CancelRideForCustomer_NotOwner(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+
+	ctx := context.Background()
+	ride := &domain.Ride{
+		ID:          1,
+		CustomerID:  123,
+		Status:      domain.RideStatusRequested,
+		RequestedAt: time.Now(),
+	}
+
+	rideRepo.On("GetByID", ctx, int64(1)).Return(ride, nil)
+
+	_, err := service.CancelRideForCustomer(ctx, 1, 999, "changed my mind")
+
+	assert.ErrorIs(t, err, ErrRideForbidden)
+	assert.Equal(t, domain.RideStatusRequested, ride.Status)
+	rideRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRideService_CancelRide_InvalidReason(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+
+	ctx := context.Background()
+	ride := &domain.Ride{
+		ID:          1,
+		CustomerID:  123,
+		Status:      domain.RideStatusRequested,
+		RequestedAt: time.Now(),
+	}
+
+	rideRepo.On("GetByID", ctx, int64(1)).Return(ride, nil)
+
+	_, err := service.CancelRideForCustomer(ctx, 1, 123, strings.Repeat("a", domain.MaxCancellationReasonLength+1))
+
+	assert.ErrorIs(t, err, domain.ErrInvalidCancellationReason)
+	rideRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRideService_CancelRideForCustomer_Completed(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+
+	ctx := context.Background()
+	driverID := int64(456)
+	completedAt := time.Now()
+	ride := &domain.Ride{
+		ID:          1,
+		CustomerID:  123,
+		DriverID:    &driverID,
+		Status:      domain.RideStatusCompleted,
+		RequestedAt: time.Now(),
+		CompletedAt: &completedAt,
+	}
+
+	rideRepo.On("GetByID", ctx, int64(1)).Return(ride, nil)
+
+	_, err := service.CancelRideForCustomer(ctx, 1, 123, "")
+
+	assert.ErrorIs(t, err, ErrRideCannotBeCancelled)
+	assert.Equal(t, domain.RideStatusCompleted, ride.Status)
+	rideRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRideService_CancelRideForCustomer_NotFound(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+
+	ctx := context.Background()
+
+	rideRepo.On("GetByID", ctx, int64(1)).Return(nil, errors.New("ride not found"))
+
+	_, err := service.CancelRideForCustomer(ctx, 1, 123, "")
+
+	assert.ErrorIs(t, err, ErrRideNotFound)
+	rideRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRideService_GetCustomerRideHistory(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+
+	ctx := context.Background()
+	rides := []*domain.Ride{
+		{ID: 3, CustomerID: 123, Status: domain.RideStatusRequested},
+		{ID: 2, CustomerID: 123, Status: domain.RideStatusCompleted},
+	}
+
+	rideRepo.On("GetByCustomerID", ctx, int64(123), domain.RideStatus(""), 2, 0).Return(rides, int64(5), nil)
+
+	page, err := service.GetCustomerRideHistory(ctx, 123, "", 2, 0)
+
+	assert.NoError(t, err)
+	assert.Equal(t, rides, page.Rides)
+	assert.Equal(t, int64(5), page.Total)
+	assert.Equal(t, 2, page.Limit)
+	assert.Equal(t, 0, page.Offset)
+	rideRepo.AssertExpectations(t)
+}
+
+func TestRideService_GetDriverRideHistory_Empty(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+
+	ctx := context.Background()
+
+	rideRepo.On("GetByDriverID", ctx, int64(456), 20, 40).Return(nil, int64(3), nil)
+
+	page, err := service.GetDriverRideHistory(ctx, 456, 20, 40)
+
+	assert.NoError(t, err)
+	assert.NotNil(t, page.Rides, "Empty page should serialise as an empty list")
+	assert.Empty(t, page.Rides)
+	assert.Equal(t, int64(3), page.Total)
+	rideRepo.AssertExpectations(t)
+}
+
+func TestRideService_GetCustomerRideHistory_Error(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+
+	ctx := context.Background()
+
+	rideRepo.On("GetByCustomerID", ctx, int64(123), domain.RideStatus(""), 20, 0).Return(nil, int64(0), errors.New("database error"))
+
+	page, err := service.GetCustomerRideHistory(ctx, 123, "", 20, 0)
+
+	assert.Error(t, err)
+	assert.Nil(t, page)
+}
+
+func TestRideService_GetCustomerRideHistory_Empty(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+
+	ctx := context.Background()
+
+	rideRepo.On("GetByCustomerID", ctx, int64(123), domain.RideStatus(""), 20, 0).Return(nil, int64(0), nil)
+
+	page, err := service.GetCustomerRideHistory(ctx, 123, "", 20, 0)
+
+	assert.NoError(t, err)
+	assert.NotNil(t, page.Rides)
+	assert.Empty(t, page.Rides)
+	assert.Equal(t, int64(0), page.Total)
+	rideRepo.AssertExpectations(t)
+}
+
+func TestRideService_GetCustomerRideHistory_StatusFilter(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+
+	ctx := context.Background()
+	fare := 150.0
+	completedAt := time.Now()
+	rides := []*domain.Ride{
+		{ID: 1, CustomerID: 123, Status: domain.RideStatusCompleted, Fare: &fare, RequestedAt: time.Now(), CompletedAt: &completedAt},
+	}
+
+	// Only the authenticated customer's ID is passed to the repository
+	rideRepo.On("GetByCustomerID", ctx, int64(123), domain.RideStatusCompleted, 20, 0).Return(rides, int64(1), nil)
+
+	page, err := service.GetCustomerRideHistory(ctx, 123, domain.RideStatusCompleted, 20, 0)
+
+	assert.NoError(t, err)
+	require.Len(t, page.Rides, 1)
+	assert.Equal(t, int64(123), page.Rides[0].CustomerID)
+	assert.Equal(t, domain.RideStatusCompleted, page.Rides[0].Status)
+	assert.Equal(t, &fare, page.Rides[0].Fare)
+	rideRepo.AssertExpectations(t)
+}
+
+func TestRideService_GetCustomerRideHistory_InvalidStatus(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+
+	ctx := context.Background()
+
+	page, err := service.GetCustomerRideHistory(ctx, 123, domain.RideStatus("finished"), 20, 0)
+
+	assert.ErrorIs(t, err, domain.ErrInvalidRideStatus)
+	assert.Nil(t, page)
+	rideRepo.AssertNotCalled(t, "GetByCustomerID", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRideService_RequestRide_InvalidRideType(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+
+	ride, err := service.RequestRide(context.Background(), 123, domain.RideType("helicopter"), 23.8100, 90.4120, 23.7509, 90.3761, nil, "", "")
+
+	assert.ErrorIs(t, err, domain.ErrInvalidRideType)
+	assert.Nil(t, ride)
+	rideRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestRideService_GetNearbyRides_UsesDriverVehicleType(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	driverRepo := new(MockDriverRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+	service.driverService.driverRepo = driverRepo
+
+	ctx := context.Background()
+	driverID := int64(456)
+	bikeRide := &domain.Ride{ID: 1, PickupLat: 23.8100, PickupLng: 90.4120, RideType: domain.RideTypeBike}
+	bikeRide.OfferTo(driverID, time.Now().Add(time.Minute))
+
+	driverRepo.On("GetByID", ctx, driverID).Return(&domain.Driver{ID: driverID, VehicleType: domain.RideTypeBike}, nil)
+	rideRepo.On("GetNearbyRequestedRides", ctx, driverID, domain.RideTypeBike, 23.8103, 90.4125, 10000.0, testNearbyFreshness, 10).Return([]*domain.Ride{bikeRide}, nil)
+
+	rides, err := service.GetNearbyRides(ctx, driverID, 23.8103, 90.4125, 10000.0, 0, 10)
+
+	assert.NoError(t, err)
+	require.Len(t, rides, 1)
+	assert.Greater(t, rides[0].DistanceFromDriver, 0.0)
+	rideRepo.AssertExpectations(t)
+}
+
+func TestRideService_GetNearbyRides_ByGeohash(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	driverRepo := new(MockDriverRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+	service.driverService.driverRepo = driverRepo
+	service.nearbyByGeohash = true
+
+	ctx := context.Background()
+	driverID := int64(456)
+	ride := &domain.Ride{ID: 1, PickupLat: 23.8100, PickupLng: 90.4120}
+	ride.OfferTo(driverID, time.Now().Add(time.Minute))
+
+	driverRepo.On("GetByID", ctx, driverID).Return(&domain.Driver{ID: driverID}, nil)
+	rideRepo.On("GetNearbyRequestedRidesByGeohash", ctx, driverID, domain.RideTypeEconomy, 23.8103, 90.4125, 10000.0, testNearbyFreshness, 10).Return([]*domain.Ride{ride}, nil)
+
+	rides, err := service.GetNearbyRides(ctx, driverID, 23.8103, 90.4125, 10000.0, 0, 10)
+
+	require.NoError(t, err)
+	require.Len(t, rides, 1)
+	rideRepo.AssertNotCalled(t, "GetNearbyRequestedRides", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRideService_GetNearbyRides_ConfiguredDefaults(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	driverRepo := new(MockDriverRepository)
+	locationRepo := new(MockLocationRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), locationRepo)
+	service.driverService.driverRepo = driverRepo
+	service.locationService = NewLocationService(locationRepo, config.LocationConfig{RideSearchRadiusMeters: 7000, RideSearchLimit: 20})
+
+	ctx := context.Background()
+	driverID := int64(456)
+	driverRepo.On("GetByID", ctx, driverID).Return(&domain.Driver{ID: driverID, VehicleType: domain.RideTypeEconomy}, nil)
+	rideRepo.On("GetNearbyRequestedRides", ctx, driverID, domain.RideTypeEconomy, 23.8103, 90.4125, 7000.0, testNearbyFreshness, 20).Return([]*domain.Ride{}, nil).Once()
+	rideRepo.On("GetNearbyRequestedRides", ctx, driverID, domain.RideTypeEconomy, 23.8103, 90.4125, 7000.0, testNearbyFreshness, MaxRideSearchLimit).Return([]*domain.Ride{}, nil).Once()
+
+	// Zero distance and limit fall back to the configured defaults
+	_, err := service.GetNearbyRides(ctx, driverID, 23.8103, 90.4125, 0, 0, 0)
+	require.NoError(t, err)
+
+	// Limits above the maximum are capped
+	_, err = service.GetNearbyRides(ctx, driverID, 23.8103, 90.4125, 0, 0, 500)
+	require.NoError(t, err)
+
+	rideRepo.AssertExpectations(t)
+}
+
+func TestRideService_GetNearbyRides_DriverWithoutVehicleTypeSeesEconomy(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	driverRepo := new(MockDriverRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+	service.driverService.driverRepo = driverRepo
+
+	ctx := context.Background()
+	driverID := int64(456)
+
+	driverRepo.On("GetByID", ctx, driverID).Return(&domain.Driver{ID: driverID}, nil)
+	rideRepo.On("GetNearbyRequestedRides", ctx, driverID, domain.RideTypeEconomy, 23.8103, 90.4125, 10000.0, testNearbyFreshness, 10).Return(nil, nil)
+
+	_, err := service.GetNearbyRides(ctx, driverID, 23.8103, 90.4125, 10000.0, 0, 10)
+
+	assert.NoError(t, err)
+	rideRepo.AssertExpectations(t)
+}
+
+func TestRideService_GetNearbyRides_UsesRequestedFreshness(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	driverRepo := new(MockDriverRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+	service.driverService.driverRepo = driverRepo
+
+	ctx := context.Background()
+	driverID := int64(456)
+
+	driverRepo.On("GetByID", ctx, driverID).Return(&domain.Driver{ID: driverID}, nil)
+	rideRepo.On("GetNearbyRequestedRides", ctx, driverID, domain.RideTypeEconomy, 23.8103, 90.4125, 10000.0, 90*time.Second, 10).Return(nil, nil)
+
+	_, err := service.GetNearbyRides(ctx, driverID, 23.8103, 90.4125, 10000.0, 90*time.Second, 10)
+
+	assert.NoError(t, err)
+	rideRepo.AssertExpectations(t)
+}
+
+func TestRideService_GetNearbyRides_InvalidFreshness(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+
+	for _, freshness := range []time.Duration{-time.Second, MaxNearbyFreshness + time.Second} {
+		_, err := service.GetNearbyRides(context.Background(), 456, 23.8103, 90.4125, 10000.0, freshness, 10)
+
+		assert.ErrorIs(t, err, ErrInvalidNearbyFreshness, freshness)
+	}
+	rideRepo.AssertNotCalled(t, "GetNearbyRequestedRides", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRideService_RequestRide_InvalidWaypoint(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+
+	waypoints := []domain.Location{{Latitude: 23.7806, Longitude: 190}}
+
+	ride, err := service.RequestRide(context.Background(), 123, domain.RideTypeEconomy, 23.8100, 90.4120, 23.7509, 90.3761, waypoints, "", "")
+
+	assert.ErrorIs(t, err, domain.ErrInvalidLongitude)
+	assert.Nil(t, ride)
+	rideRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestRideService_RequestRide_WithWaypoints(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	locationRepo := new(MockLocationRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), locationRepo)
+	expectNoSurge(rideRepo, locationRepo)
+	expectNoActiveRide(rideRepo)
+
+	ctx := context.Background()
+	waypoints := []domain.Location{{Latitude: 23.7806, Longitude: 90.4193}}
+
+	rideRepo.On("Create", ctx, mock.AnythingOfType("*domain.Ride")).Return(nil)
+
+	ride, err := service.RequestRide(ctx, 123, domain.RideTypeEconomy, 23.8100, 90.4120, 23.7509, 90.3761, waypoints, "", "")
+
+	require.NoError(t, err)
+	assert.Equal(t, waypoints, ride.Waypoints)
+	require.NotNil(t, ride.Fare)
+	assert.Equal(t, service.fareService.EstimateFare(ride), *ride.Fare)
+	rideRepo.AssertExpectations(t)
+}
+
+func TestEtaMinutes(t *testing.T) {
+	tests := []struct {
+		name           string
+		distanceMeters float64
+		speedKmh       float64
+		expected       int
+		ok             bool
+	}{
+		{name: "same place", distanceMeters: 0, speedKmh: 20, expected: 0, ok: true},
+		{name: "1km at 20km/h", distanceMeters: 1000, speedKmh: 20, expected: 3, ok: true},
+		{name: "5km at 30km/h", distanceMeters: 5000, speedKmh: 30, expected: 10, ok: true},
+		{name: "partial minute rounds up", distanceMeters: 1100, speedKmh: 60, expected: 2, ok: true},
+		{name: "10km at 15km/h", distanceMeters: 10000, speedKmh: 15, expected: 40, ok: true},
+		{name: "zero speed", distanceMeters: 1000, speedKmh: 0, ok: false},
+		{name: "negative speed", distanceMeters: 1000, speedKmh: -10, ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			eta, ok := etaMinutes(tt.distanceMeters, tt.speedKmh)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.expected, eta)
+		})
+	}
+}
+
+func newTestRideStatusService(rideRepo *MockRideRepository, driverRepo *MockDriverRepository, locationRepo *MockLocationRepository) *RideService {
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), locationRepo)
+	service.driverService.driverRepo = driverRepo
+	service.averageSpeedKmh = 20
+	return service
+}
+
+func TestRideService_GetRideStatusForCustomer_Arrived(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	driverRepo := new(MockDriverRepository)
+	locationRepo := new(MockLocationRepository)
+	service := newTestRideStatusService(rideRepo, driverRepo, locationRepo)
+
+	ctx := context.Background()
+	driverID := int64(456)
+	arrivedAt := time.Date(2025, 3, 1, 9, 5, 30, 0, time.Local)
+	fare := 182.5
+	ride := &domain.Ride{
+		ID:          1,
+		CustomerID:  123,
+		DriverID:    &driverID,
+		PickupLat:   23.8100,
+		PickupLng:   90.4120,
+		Status:      domain.RideStatusArrived,
+		Fare:        &fare,
+		RequestedAt: time.Now(),
+		ArrivedAt:   &arrivedAt,
+	}
+	recent := time.Now().Add(-10 * time.Second)
+
+	rideRepo.On("GetByID", ctx, int64(1)).Return(ride, nil)
+	driverRepo.On("GetByID", ctx, driverID).Return(&domain.Driver{ID: driverID, Name: "Test Driver"}, nil)
+	locationRepo.On("GetDriverLocation", ctx, driverID).Return(23.8101, 90.4121, &recent, nil)
+
+	status, err := service.GetRideStatusForCustomer(ctx, 1, 123)
+
+	require.NoError(t, err)
+	assert.Equal(t, string(domain.RideStatusArrived), status.Status)
+	assert.Equal(t, DefaultFareCurrency, status.Currency)
+	require.NotNil(t, status.FareFormatted)
+	assert.Equal(t, "BDT 182.50", *status.FareFormatted)
+	require.NotNil(t, status.ArrivedAt)
+	assert.Equal(t, "2025-03-01 09:05:30", *status.ArrivedAt)
+	require.NotNil(t, status.Driver)
+	assert.Nil(t, status.Driver.EtaMinutes, "No ETA once the driver is at the pickup")
+}
+
+func TestRideService_GetRideStatusForCustomer_IncludesETA(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	driverRepo := new(MockDriverRepository)
+	locationRepo := new(MockLocationRepository)
+	service := newTestRideStatusService(rideRepo, driverRepo, locationRepo)
+
+	ctx := context.Background()
+	driverID := int64(456)
+	ride := &domain.Ride{
+		ID:          1,
+		CustomerID:  123,
+		DriverID:    &driverID,
+		PickupLat:   23.8100,
+		PickupLng:   90.4120,
+		Status:      domain.RideStatusAccepted,
+		RequestedAt: time.Now(),
+	}
+	recent := time.Now().Add(-10 * time.Second)
+	driverLocation := domain.Location{Latitude: 23.8189, Longitude: 90.4120} // just under 1km north of pickup
+
+	rideRepo.On("GetByID", ctx, int64(1)).Return(ride, nil)
+	driverRepo.On("GetByID", ctx, driverID).Return(&domain.Driver{ID: driverID, Name: "Test Driver"}, nil)
+	locationRepo.On("GetDriverLocation", ctx, driverID).Return(driverLocation.Latitude, driverLocation.Longitude, &recent, nil)
+
+	status, err := service.GetRideStatusForCustomer(ctx, 1, 123)
+
+	require.NoError(t, err)
+	require.NotNil(t, status.Driver)
+	require.NotNil(t, status.Driver.EtaMinutes)
+	assert.Equal(t, 3, *status.Driver.EtaMinutes)
+}
+
+func TestRideService_GetRideStatusForCustomer_NoETAWithStaleLocation(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	driverRepo := new(MockDriverRepository)
+	locationRepo := new(MockLocationRepository)
+	service := newTestRideStatusService(rideRepo, driverRepo, locationRepo)
+
+	ctx := context.Background()
+	driverID := int64(456)
+	ride := &domain.Ride{
+		ID:          1,
+		CustomerID:  123,
+		DriverID:    &driverID,
+		PickupLat:   23.8100,
+		PickupLng:   90.4120,
+		Status:      domain.RideStatusAccepted,
+		RequestedAt: time.Now(),
+	}
+	stale := time.Now().Add(-10 * time.Minute)
+
+	rideRepo.On("GetByID", ctx, int64(1)).Return(ride, nil)
+	driverRepo.On("GetByID", ctx, driverID).Return(&domain.Driver{ID: driverID}, nil)
+	locationRepo.On("GetDriverLocation", ctx, driverID).Return(23.8190, 90.4120, &stale, nil)
+
+	status, err := service.GetRideStatusForCustomer(ctx, 1, 123)
+
+	require.NoError(t, err)
+	require.NotNil(t, status.Driver)
+	assert.NotNil(t, status.Driver.CurrentLat, "Stale location is still reported")
+	assert.Nil(t, status.Driver.EtaMinutes)
+}
+
+func TestRideService_GetRideStatusForCustomer_NoETAOnceStarted(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	driverRepo := new(MockDriverRepository)
+	locationRepo := new(MockLocationRepository)
+	service := newTestRideStatusService(rideRepo, driverRepo, locationRepo)
+
+	ctx := context.Background()
+	driverID := int64(456)
+	ride := &domain.Ride{
+		ID:          1,
+		CustomerID:  123,
+		DriverID:    &driverID,
+		PickupLat:   23.8100,
+		PickupLng:   90.4120,
+		Status:      domain.RideStatusStarted,
+		RequestedAt: time.Now(),
+	}
+	recent := time.Now()
+
+	rideRepo.On("GetByID", ctx, int64(1)).Return(ride, nil)
+	driverRepo.On("GetByID", ctx, driverID).Return(&domain.Driver{ID: driverID}, nil)
+	locationRepo.On("GetDriverLocation", ctx, driverID).Return(23.8190, 90.4120, &recent, nil)
+
+	status, err := service.GetRideStatusForCustomer(ctx, 1, 123)
+
+	require.NoError(t, err)
+	assert.Nil(t, status.Driver.EtaMinutes)
+}
+
+func TestRideService_StreamRideStatusForCustomer_EndsWhenCompleted(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	driverRepo := new(MockDriverRepository)
+	locationRepo := new(MockLocationRepository)
+	service := newTestRideStatusService(rideRepo, driverRepo, locationRepo)
+	service.statusStreamInterval = 10 * time.Millisecond
+
+	ctx := context.Background()
+	driverID := int64(456)
+	recent := time.Now()
+	started := &domain.Ride{ID: 1, CustomerID: 123, DriverID: &driverID, Status: domain.RideStatusStarted, RequestedAt: time.Now()}
+	completed := &domain.Ride{ID: 1, CustomerID: 123, DriverID: &driverID, Status: domain.RideStatusCompleted, RequestedAt: time.Now()}
+
+	rideRepo.On("GetByID", ctx, int64(1)).Return(started, nil).Twice()
+	rideRepo.On("GetByID", ctx, int64(1)).Return(completed, nil)
+	driverRepo.On("GetByID", ctx, driverID).Return(&domain.Driver{ID: driverID, Name: "Test Driver"}, nil)
+	locationRepo.On("GetDriverLocation", ctx, driverID).Return(23.8100, 90.4120, &recent, nil)
+
+	var statuses []string
+	done := make(chan error, 1)
+	go func() {
+		done <- service.StreamRideStatusForCustomer(ctx, 1, 123, func(status *RideStatusResponse) error {
+			statuses = append(statuses, status.Status)
+			return nil
+		})
+	}()
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("stream did not end after the ride completed")
+	}
+
+	assert.Equal(t, []string{"started", "started", "completed"}, statuses)
+	rideRepo.AssertExpectations(t)
+}
+
+func TestRideService_StreamRideStatusForCustomer_Forbidden(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+	service.statusStreamInterval = 10 * time.Millisecond
+
+	ctx := context.Background()
+	ride := &domain.Ride{ID: 1, CustomerID: 999, Status: domain.RideStatusRequested, RequestedAt: time.Now()}
+	rideRepo.On("GetByID", ctx, int64(1)).Return(ride, nil)
+
+	sent := 0
+	err := service.StreamRideStatusForCustomer(ctx, 1, 123, func(status *RideStatusResponse) error {
+		sent++
+		return nil
+	})
+
+	assert.ErrorIs(t, err, ErrRideForbidden)
+	assert.Zero(t, sent, "Nothing should be streamed for another customer's ride")
+}
+
+func TestRideService_GetRideRoute(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	locationRepo := new(MockLocationRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), locationRepo)
+
+	ctx := context.Background()
+	driverID := int64(456)
+	ride := &domain.Ride{ID: 1, CustomerID: 123, DriverID: &driverID, Status: domain.RideStatusCompleted}
+	first := time.Now().Add(-10 * time.Minute)
+	points := []repository.RideLocation{
+		{RideID: 1, DriverID: driverID, Location: repository.GeoJSON{Type: "Point", Coordinates: []float64{90.4120, 23.8100}}, RecordedAt: first},
+		{RideID: 1, DriverID: driverID, Location: repository.GeoJSON{Type: "Point", Coordinates: []float64{90.4125, 23.8105}}, RecordedAt: first.Add(time.Minute)},
+	}
+
+	rideRepo.On("GetByID", ctx, int64(1)).Return(ride, nil)
+	locationRepo.On("GetRideLocationHistory", ctx, int64(1)).Return(points, nil)
+
+	for _, viewer := range []struct {
+		id   int64
+		role string
+	}{{123, "customer"}, {driverID, "driver"}} {
+		route, err := service.GetRideRoute(ctx, 1, viewer.id, viewer.role)
+
+		require.NoError(t, err, viewer.role)
+		require.Len(t, route, 2)
+		assert.Equal(t, TrailPoint{Lat: 23.8100, Lng: 90.4120, RecordedAt: first}, route[0])
+		assert.Equal(t, TrailPoint{Lat: 23.8105, Lng: 90.4125, RecordedAt: first.Add(time.Minute)}, route[1])
+	}
+}
+
+func TestRideService_GetActiveRide(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+
+	ctx := context.Background()
+	driverID := int64(456)
+	waiting := &domain.Ride{ID: 1, CustomerID: 123, Status: domain.RideStatusRequested}
+	started := &domain.Ride{ID: 2, CustomerID: 789, DriverID: &driverID, Status: domain.RideStatusStarted}
+
+	rideRepo.On("GetActiveRideByCustomerID", ctx, int64(123)).Return(waiting, nil)
+	rideRepo.On("GetActiveRideByDriverID", ctx, driverID).Return(started, nil)
+
+	ride, err := service.GetActiveRide(ctx, 123, "customer")
+	require.NoError(t, err)
+	assert.Equal(t, waiting, ride, "A customer's ride is active while it waits for a driver")
+
+	ride, err = service.GetActiveRide(ctx, driverID, "driver")
+	require.NoError(t, err)
+	assert.Equal(t, started, ride)
+}
+
+func TestRideService_GetActiveRide_NoActiveRide(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+
+	ctx := context.Background()
+	rideRepo.On("GetActiveRideByCustomerID", ctx, int64(123)).Return(nil, repository.ErrRideNotFound)
+	rideRepo.On("GetActiveRideByDriverID", ctx, int64(456)).Return(nil, repository.ErrRideNotFound)
+
+	ride, err := service.GetActiveRide(ctx, 123, "customer")
+	assert.NoError(t, err)
+	assert.Nil(t, ride)
+
+	ride, err = service.GetActiveRide(ctx, 456, "driver")
+	assert.NoError(t, err)
+	assert.Nil(t, ride)
+
+	_, err = service.GetActiveRide(ctx, 1, "admin")
+	assert.ErrorIs(t, err, ErrNoRidesForRole)
+}
+
+func TestRideService_GetRideRoute_NotParticipant(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	locationRepo := new(MockLocationRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), locationRepo)
+
+	ctx := context.Background()
+	driverID := int64(456)
+	ride := &domain.Ride{ID: 1, CustomerID: 123, DriverID: &driverID, Status: domain.RideStatusStarted}
+	rideRepo.On("GetByID", ctx, int64(1)).Return(ride, nil)
+
+	_, err := service.GetRideRoute(ctx, 1, 999, "customer")
+	assert.ErrorIs(t, err, ErrNotRideParticipant)
+
+	_, err = service.GetRideRoute(ctx, 1, 789, "driver")
+	assert.ErrorIs(t, err, ErrNotRideParticipant)
+
+	_, err = service.GetRideRoute(ctx, 1, 123, "driver")
+	assert.ErrorIs(t, err, ErrNotRideParticipant, "Role must match")
+
+	locationRepo.AssertNotCalled(t, "GetRideLocationHistory", mock.Anything, mock.Anything)
+}
+
+func TestRideService_RequestRideFromSavedLocation(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	savedLocationRepo := new(MockSavedLocationRepository)
+	locationRepo := new(MockLocationRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), locationRepo)
+	expectNoSurge(rideRepo, locationRepo)
+	expectNoActiveRide(rideRepo)
+	service.savedLocationService = NewSavedLocationService(savedLocationRepo)
+
+	ctx := context.Background()
+
+	savedLocationRepo.On("GetByID", ctx, int64(7), int64(123)).Return(&domain.SavedLocation{ID: 7, CustomerID: 123, Label: "Home", Lat: 23.8100, Lng: 90.4120}, nil)
+	rideRepo.On("Create", ctx, mock.MatchedBy(func(r *domain.Ride) bool {
+		return r.CustomerID == 123 && r.PickupLat == 23.8100 && r.PickupLng == 90.4120
+	})).Return(nil)
+
+	ride, err := service.RequestRideFromSavedLocation(ctx, 123, 7, domain.RideTypeEconomy, 23.7509, 90.3761, nil, "", "")
+
+	require.NoError(t, err)
+	assert.Equal(t, 23.8100, ride.PickupLat)
+	assert.Equal(t, 90.4120, ride.PickupLng)
+	assert.Equal(t, 23.7509, ride.DropoffLat)
+	rideRepo.AssertExpectations(t)
+}
+
+func TestRideService_RequestRideFromSavedLocation_OtherCustomersLocation(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	savedLocationRepo := new(MockSavedLocationRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+	service.savedLocationService = NewSavedLocationService(savedLocationRepo)
+
+	ctx := context.Background()
+
+	savedLocationRepo.On("GetByID", ctx, int64(7), int64(123)).Return(nil, repository.ErrSavedLocationNotFound)
+
+	ride, err := service.RequestRideFromSavedLocation(ctx, 123, 7, domain.RideTypeEconomy, 23.7509, 90.3761, nil, "", "")
+
+	assert.ErrorIs(t, err, ErrSavedLocationNotFound)
+	assert.Nil(t, ride)
+	rideRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestRideService_RequestRide_CoordinatesOutOfRange(t *testing.T) {
+	tests := []struct {
+		name                                         string
+		pickupLat, pickupLng, dropoffLat, dropoffLng float64
+		expected                                     error
+	}{
+		{name: "pickup latitude", pickupLat: 91, pickupLng: 90.4120, dropoffLat: 23.7509, dropoffLng: 90.3761, expected: domain.ErrInvalidLatitude},
+		{name: "pickup longitude", pickupLat: 23.8100, pickupLng: -181, dropoffLat: 23.7509, dropoffLng: 90.3761, expected: domain.ErrInvalidLongitude},
+		{name: "dropoff latitude", pickupLat: 23.8100, pickupLng: 90.4120, dropoffLat: -90.5, dropoffLng: 90.3761, expected: domain.ErrInvalidLatitude},
+		{name: "dropoff longitude", pickupLat: 23.8100, pickupLng: 90.4120, dropoffLat: 23.7509, dropoffLng: 190, expected: domain.ErrInvalidLongitude},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rideRepo := new(MockRideRepository)
+			service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+
+			ride, err := service.RequestRide(context.Background(), 123, domain.RideTypeEconomy, tt.pickupLat, tt.pickupLng, tt.dropoffLat, tt.dropoffLng, nil, "", "")
+
+			assert.ErrorIs(t, err, tt.expected)
+			assert.Nil(t, ride)
+			rideRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestRideService_RequestRide_SamePickupAndDropoff(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+
+	ride, err := service.RequestRide(context.Background(), 123, domain.RideTypeEconomy, 23.8100, 90.4120, 23.8100, 90.4120, nil, "", "")
+
+	assert.ErrorIs(t, err, domain.ErrSamePickupDropoff)
+	assert.Nil(t, ride)
+	rideRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestRideService_RequestRide_OneActiveRidePerCustomer(t *testing.T) {
+	for _, finished := range []domain.RideStatus{domain.RideStatusCompleted, domain.RideStatusCancelled} {
+		t.Run(string(finished), func(t *testing.T) {
+			rideRepo := new(MockRideRepository)
+			locationRepo := new(MockLocationRepository)
+			service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), locationRepo)
+			expectNoSurge(rideRepo, locationRepo)
+
+			ctx := context.Background()
+			rideRepo.On("Create", ctx, mock.AnythingOfType("*domain.Ride")).Return(nil)
+
+			rideRepo.On("GetActiveRideByCustomerID", ctx, int64(123)).Return(nil, repository.ErrRideNotFound).Once()
+			first, err := service.RequestRide(ctx, 123, domain.RideTypeEconomy, 23.8100, 90.4120, 23.7509, 90.3761, nil, "", "")
+			require.NoError(t, err)
+
+			rideRepo.On("GetActiveRideByCustomerID", ctx, int64(123)).Return(first, nil).Once()
+			second, err := service.RequestRide(ctx, 123, domain.RideTypeEconomy, 23.8100, 90.4120, 23.7509, 90.3761, nil, "", "")
+			assert.ErrorIs(t, err, ErrActiveRideExists)
+			assert.ErrorIs(t, err, domain.ErrConflict)
+			assert.Nil(t, second)
+			rideRepo.AssertNumberOfCalls(t, "Create", 1)
+
+			// Once the first ride is finished it is no longer active
+			first.Status = finished
+			rideRepo.On("GetActiveRideByCustomerID", ctx, int64(123)).Return(nil, repository.ErrRideNotFound).Once()
+			third, err := service.RequestRide(ctx, 123, domain.RideTypeEconomy, 23.8100, 90.4120, 23.7509, 90.3761, nil, "", "")
+			require.NoError(t, err)
+			assert.NotNil(t, third)
+			rideRepo.AssertNumberOfCalls(t, "Create", 2)
+		})
+	}
+}
+
+func TestRideService_RequestRide_ActiveRideCreatedConcurrently(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	locationRepo := new(MockLocationRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), locationRepo)
+	expectNoSurge(rideRepo, locationRepo)
+	expectNoActiveRide(rideRepo)
+
+	// Another request of the customer created its ride after this one checked for an active ride
+	ctx := context.Background()
+	rideRepo.On("Create", ctx, mock.AnythingOfType("*domain.Ride")).Return(repository.ErrCustomerHasActiveRide)
+
+	ride, err := service.RequestRide(ctx, 123, domain.RideTypeEconomy, 23.8100, 90.4120, 23.7509, 90.3761, nil, "", "")
+
+	assert.ErrorIs(t, err, ErrActiveRideExists)
+	assert.Nil(t, ride)
+}
+
+func TestRideService_RequestRide_ActiveRideLookupFails(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+
+	ctx := context.Background()
+	rideRepo.On("GetActiveRideByCustomerID", ctx, int64(123)).Return(nil, errors.New("database error"))
+
+	ride, err := service.RequestRide(ctx, 123, domain.RideTypeEconomy, 23.8100, 90.4120, 23.7509, 90.3761, nil, "", "")
+
+	assert.Error(t, err)
+	assert.Nil(t, ride)
+	rideRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestRideService_Metrics_RequestAndAccept(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	onlineRepo := new(MockOnlineStatusRepository)
+	locationRepo := new(MockLocationRepository)
+	service := newTestRideService(rideRepo, onlineRepo, locationRepo)
+	expectNoSurge(rideRepo, locationRepo)
+	expectNoActiveRide(rideRepo)
+
+	ctx := context.Background()
+	driverID := int64(456)
+
+	rideRepo.On("Create", ctx, mock.AnythingOfType("*domain.Ride")).Return(nil)
+
+	ride, err := service.RequestRide(ctx, 123, domain.RideTypeEconomy, 23.8100, 90.4120, 23.7509, 90.3761, nil, "", "")
+	require.NoError(t, err)
+	assert.Equal(t, float64(1), promtestutil.ToFloat64(service.metrics.RidesRequested))
+	assert.Equal(t, float64(0), promtestutil.ToFloat64(service.metrics.RidesAccepted))
+
+	ride.ID = 1
+	ride.OfferTo(driverID, time.Now().Add(time.Minute))
+	onlineRepo.On("IsDriverOnline", ctx, driverID).Return(true, nil)
+	rideRepo.On("GetByID", ctx, int64(1)).Return(ride, nil)
+	rideRepo.On("AcceptRide", ctx, int64(1), driverID, mock.AnythingOfType("time.Time")).Return(nil)
+
+	require.NoError(t, service.AcceptRide(ctx, 1, driverID))
+	assert.Equal(t, float64(1), promtestutil.ToFloat64(service.metrics.RidesAccepted))
+	assert.Equal(t, 1, promtestutil.CollectAndCount(service.metrics.AcceptLatency))
+}
+
+func TestRideService_Metrics_StartCompleteCancel(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	locationRepo := new(MockLocationRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), locationRepo)
+
+	ctx := context.Background()
+	driverID := int64(456)
+	acceptedAt := time.Now().Add(-20 * time.Minute)
+	ride := &domain.Ride{
+		ID:          1,
+		CustomerID:  123,
+		DriverID:    &driverID,
+		PickupLat:   23.8100,
+		PickupLng:   90.4120,
+		DropoffLat:  23.7509,
+		DropoffLng:  90.3761,
+		Status:      domain.RideStatusAccepted,
+		RequestedAt: acceptedAt.Add(-time.Minute),
+		AcceptedAt:  &acceptedAt,
+	}
+	cancelled := &domain.Ride{
+		ID:          2,
+		CustomerID:  123,
+		Status:      domain.RideStatusRequested,
+		RequestedAt: time.Now(),
+	}
+
+	rideRepo.On("GetByID", ctx, int64(1)).Return(ride, nil)
+	rideRepo.On("GetByID", ctx, int64(2)).Return(cancelled, nil)
+	rideRepo.On("Update", ctx, mock.AnythingOfType("*domain.Ride"), mock.Anything).Return(nil)
+	locationRepo.On("GetRideLocationHistory", ctx, int64(1)).Return(nil, nil)
+
+	require.NoError(t, service.StartRide(ctx, 1, driverID))
+	assert.Equal(t, float64(1), promtestutil.ToFloat64(service.metrics.RidesStarted))
+
+	require.NoError(t, service.CompleteRide(ctx, 1, driverID))
+	assert.Equal(t, float64(1), promtestutil.ToFloat64(service.metrics.RidesCompleted))
+
+	_, err := service.CancelRideForCustomer(ctx, 2, 123, "changed my mind")
+	require.NoError(t, err)
+	assert.Equal(t, float64(1), promtestutil.ToFloat64(service.metrics.RidesCancelled.WithLabelValues(domain.CancelledByCustomer)))
+	assert.Equal(t, float64(0), promtestutil.ToFloat64(service.metrics.RidesCancelled.WithLabelValues(domain.CancelledByDriver)))
+}
+
+func TestRideService_Metrics_NotCountedOnFailure(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+
+	ctx := context.Background()
+	driverID := int64(456)
+	acceptedAt := time.Now()
+	ride := &domain.Ride{
+		ID:          1,
+		CustomerID:  123,
+		DriverID:    &driverID,
+		Status:      domain.RideStatusAccepted,
+		RequestedAt: acceptedAt.Add(-time.Minute),
+		AcceptedAt:  &acceptedAt,
+	}
+
+	rideRepo.On("GetByID", ctx, int64(1)).Return(ride, nil)
+	rideRepo.On("Update", ctx, ride, mock.Anything).Return(errors.New("database error"))
+
+	require.Error(t, service.StartRide(ctx, 1, driverID))
+	assert.Equal(t, float64(0), promtestutil.ToFloat64(service.metrics.RidesStarted))
+}
+
+// createRidesWithIDs makes the mocked Create assign sequential ride IDs starting at 1
+func createRidesWithIDs(rideRepo *MockRideRepository) *int64 {
+	var created int64
+	rideRepo.On("Create", mock.Anything, mock.AnythingOfType("*domain.Ride")).Run(func(args mock.Arguments) {
+		created++
+		ride := args.Get(1).(*domain.Ride)
+		ride.ID = created
+		rideRepo.On("GetByID", mock.Anything, ride.ID).Return(ride, nil)
+	}).Return(nil)
+	return &created
+}
+
+func TestRideService_RequestRide_SameIdempotencyKeyCreatesOneRide(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	locationRepo := new(MockLocationRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), locationRepo)
+	expectNoSurge(rideRepo, locationRepo)
+	expectNoActiveRide(rideRepo)
+	created := createRidesWithIDs(rideRepo)
+
+	ctx := context.Background()
+	first, err := service.RequestRide(ctx, 123, domain.RideTypeEconomy, 23.8100, 90.4120, 23.7509, 90.3761, nil, "", "key-1")
+	require.NoError(t, err)
+	second, err := service.RequestRide(ctx, 123, domain.RideTypeEconomy, 23.8100, 90.4120, 23.7509, 90.3761, nil, "", "key-1")
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(1), *created)
+	assert.Equal(t, first.ID, second.ID)
+	rideRepo.AssertNumberOfCalls(t, "Create", 1)
+}
+
+func TestRideService_RequestRide_DifferentIdempotencyKeysCreateTwoRides(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	locationRepo := new(MockLocationRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), locationRepo)
+	expectNoSurge(rideRepo, locationRepo)
+	expectNoActiveRide(rideRepo)
+	created := createRidesWithIDs(rideRepo)
+
+	ctx := context.Background()
+	first, err := service.RequestRide(ctx, 123, domain.RideTypeEconomy, 23.8100, 90.4120, 23.7509, 90.3761, nil, "", "key-1")
+	require.NoError(t, err)
+	second, err := service.RequestRide(ctx, 123, domain.RideTypeEconomy, 23.8100, 90.4120, 23.7509, 90.3761, nil, "", "key-2")
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(2), *created)
+	assert.NotEqual(t, first.ID, second.ID)
+}
+
+func TestRideService_RequestRide_IdempotencyKeyOfAnotherCustomer(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	locationRepo := new(MockLocationRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), locationRepo)
+	expectNoSurge(rideRepo, locationRepo)
+	expectNoActiveRide(rideRepo)
+	createRidesWithIDs(rideRepo)
+
+	ctx := context.Background()
+	_, err := service.RequestRide(ctx, 123, domain.RideTypeEconomy, 23.8100, 90.4120, 23.7509, 90.3761, nil, "", "key-1")
+	require.NoError(t, err)
+
+	ride, err := service.RequestRide(ctx, 456, domain.RideTypeEconomy, 23.8100, 90.4120, 23.7509, 90.3761, nil, "", "key-1")
+
+	assert.ErrorIs(t, err, ErrIdempotencyKeyReused)
+	assert.Nil(t, ride)
+	rideRepo.AssertNumberOfCalls(t, "Create", 1)
+}
+
+func TestRideService_RequestRide_FailedRequestReleasesIdempotencyKey(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	locationRepo := new(MockLocationRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), locationRepo)
+	expectNoSurge(rideRepo, locationRepo)
+	expectNoActiveRide(rideRepo)
+
+	ctx := context.Background()
+	rideRepo.On("Create", ctx, mock.AnythingOfType("*domain.Ride")).Return(errors.New("database error")).Once()
+	_, err := service.RequestRide(ctx, 123, domain.RideTypeEconomy, 23.8100, 90.4120, 23.7509, 90.3761, nil, "", "key-1")
+	require.Error(t, err)
+
+	created := createRidesWithIDs(rideRepo)
+	ride, err := service.RequestRide(ctx, 123, domain.RideTypeEconomy, 23.8100, 90.4120, 23.7509, 90.3761, nil, "", "key-1")
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), *created)
+	assert.Equal(t, int64(1), ride.ID)
+}
+
+func TestRideService_RequestRide_IdempotencyKeyTooLong(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+
+	ride, err := service.RequestRide(context.Background(), 123, domain.RideTypeEconomy, 23.8100, 90.4120, 23.7509, 90.3761, nil, "", strings.Repeat("k", MaxIdempotencyKeyLength+1))
+
+	assert.ErrorIs(t, err, ErrInvalidIdempotencyKey)
+	assert.Nil(t, ride)
+	rideRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestCanViewRideDetails(t *testing.T) {
+	now := time.Now()
+	assignedDriverID := int64(456)
+	ride := &domain.Ride{ID: 1, CustomerID: 123, DriverID: &assignedDriverID, Status: domain.RideStatusAccepted}
+	offeredRide := &domain.Ride{ID: 2, CustomerID: 123, Status: domain.RideStatusRequested}
+	offeredRide.OfferTo(789, now.Add(time.Minute))
+
+	tests := []struct {
+		name     string
+		ride     *domain.Ride
+		userID   int64
+		role     string
+		expected bool
+	}{
+		{name: "assigned driver", ride: ride, userID: assignedDriverID, role: "driver", expected: true},
+		{name: "offered driver", ride: offeredRide, userID: 789, role: "driver", expected: true},
+		{name: "owning customer", ride: ride, userID: 123, role: "customer", expected: true},
+		{name: "admin", ride: ride, userID: 1, role: "admin", expected: true},
+		{name: "unrelated driver", ride: ride, userID: 999, role: "driver", expected: false},
+		{name: "driver not offered the ride", ride: offeredRide, userID: assignedDriverID, role: "driver", expected: false},
+		{name: "unrelated customer", ride: ride, userID: 999, role: "customer", expected: false},
+		{name: "customer ID used with driver role", ride: ride, userID: 123, role: "driver", expected: false},
+		{name: "unknown role", ride: ride, userID: 123, role: "guest", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, canViewRideDetails(tt.ride, tt.userID, tt.role, now))
+		})
+	}
+}
+
+func TestRideService_GetRideDetailsWithCustomer_UnrelatedUser(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+
+	ctx := context.Background()
+	driverID := int64(456)
+	rideRepo.On("GetByID", ctx, int64(1)).Return(&domain.Ride{ID: 1, CustomerID: 123, DriverID: &driverID, Status: domain.RideStatusAccepted}, nil)
+
+	// customerRepo is not set, reaching the customer lookup would panic
+	details, err := service.GetRideDetailsWithCustomer(ctx, 1, 999, "customer")
+
+	assert.ErrorIs(t, err, ErrNotRideParticipant)
+	assert.Nil(t, details)
+}
+
+func TestRideService_GetRideDetailsWithCustomer_DeletedCustomer(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	customerRepo := new(MockCustomerRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+	service.customerRepo = customerRepo
+
+	ctx := context.Background()
+	driverID := int64(456)
+	deletedAt := time.Now()
+	customer := newTestCustomer()
+	customer.DeletedAt = &deletedAt
+	rideRepo.On("GetByID", ctx, int64(1)).Return(&domain.Ride{ID: 1, CustomerID: customer.ID, DriverID: &driverID, Status: domain.RideStatusCompleted}, nil)
+	customerRepo.On("GetByIDWithDeleted", ctx, customer.ID).Return(customer, nil)
+
+	details, err := service.GetRideDetailsWithCustomer(ctx, 1, driverID, "driver")
+
+	require.NoError(t, err)
+	assert.Equal(t, "Rahim", details.CustomerName)
+	customerRepo.AssertNotCalled(t, "GetByID", mock.Anything, mock.Anything)
+}
+
+func TestRideService_GetRideDetailsWithCustomer_AssignedDriverSeesContact(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	customerRepo := new(MockCustomerRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+	service.customerRepo = customerRepo
+
+	ctx := context.Background()
+	driverID := int64(456)
+	rideRepo.On("GetByID", ctx, int64(1)).Return(&domain.Ride{ID: 1, CustomerID: 123, DriverID: &driverID, Status: domain.RideStatusAccepted}, nil)
+	customerRepo.On("GetByIDWithDeleted", ctx, int64(123)).Return(newTestCustomer(), nil)
+
+	details, err := service.GetRideDetailsWithCustomer(ctx, 1, driverID, "driver")
+
+	require.NoError(t, err)
+	assert.Equal(t, "Rahim", details.CustomerName)
+	assert.Equal(t, "+8801711000000", details.CustomerPhone)
+}
+
+func TestRideService_GetRideDetailsWithCustomer_OfferedDriverSeesMaskedContact(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	customerRepo := new(MockCustomerRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+	service.customerRepo = customerRepo
+
+	ctx := context.Background()
+	ride := &domain.Ride{ID: 1, CustomerID: 123, Status: domain.RideStatusRequested}
+	ride.OfferTo(789, time.Now().Add(time.Minute))
+	rideRepo.On("GetByID", ctx, int64(1)).Return(ride, nil)
+	customerRepo.On("GetByIDWithDeleted", ctx, int64(123)).Return(newTestCustomer(), nil)
+
+	details, err := service.GetRideDetailsWithCustomer(ctx, 1, 789, "driver")
+
+	require.NoError(t, err)
+	assert.Equal(t, "Rahim", details.CustomerName)
+	assert.Equal(t, "+**********000", details.CustomerPhone)
+}
+
+func TestCanSeeCustomerContact(t *testing.T) {
+	assignedDriverID := int64(456)
+	ride := &domain.Ride{ID: 1, CustomerID: 123, DriverID: &assignedDriverID, Status: domain.RideStatusAccepted}
+	offeredRide := &domain.Ride{ID: 2, CustomerID: 123, Status: domain.RideStatusRequested}
+	offeredRide.OfferTo(789, time.Now().Add(time.Minute))
+
+	assert.True(t, canSeeCustomerContact(ride, assignedDriverID, "driver"))
+	assert.True(t, canSeeCustomerContact(ride, 123, "customer"))
+	assert.True(t, canSeeCustomerContact(ride, 1, "admin"))
+	assert.False(t, canSeeCustomerContact(offeredRide, 789, "driver"))
+	assert.False(t, canSeeCustomerContact(ride, 999, "driver"))
+	assert.False(t, canSeeCustomerContact(ride, 999, "customer"))
+}
+
+func TestRideService_GetRideByID(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+
+	ctx := context.Background()
+	ride := &domain.Ride{ID: 1, CustomerID: 123, Status: domain.RideStatusRequested, RequestedAt: time.Now()}
+	rideRepo.On("GetByID", ctx, int64(1)).Return(ride, nil)
+	rideRepo.On("GetByID", ctx, int64(2)).Return(nil, repository.ErrRideNotFound)
+	rideRepo.On("GetByID", ctx, int64(3)).Return(nil, errors.New("database error"))
+
+	got, err := service.GetRideByID(ctx, 1)
+	require.NoError(t, err)
+	assert.Same(t, ride, got)
+
+	_, err = service.GetRideByID(ctx, 2)
+	assert.ErrorIs(t, err, ErrRideNotFound, "A missing ride is reported as not found by the service")
+
+	_, err = service.GetRideByID(ctx, 3)
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrRideNotFound, "Database errors are not mistaken for a missing ride")
+}
+
+func TestRideService_GetRideDetailsWithCustomer_NotFound(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+
+	ctx := context.Background()
+	rideRepo.On("GetByID", ctx, int64(1)).Return(nil, errors.New("ride not found"))
+
+	_, err := service.GetRideDetailsWithCustomer(ctx, 1, 1, "admin")
+
+	assert.ErrorIs(t, err, ErrRideNotFound)
+}
+
+func TestRideService_RideEvents_FullLifecycle(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	onlineRepo := new(MockOnlineStatusRepository)
+	locationRepo := new(MockLocationRepository)
+	service := newTestRideService(rideRepo, onlineRepo, locationRepo)
+	service.requireArrival = true
+	expectNoSurge(rideRepo, locationRepo)
+	expectNoActiveRide(rideRepo)
+	createRidesWithIDs(rideRepo)
+
+	ctx := context.Background()
+	customerID := int64(123)
+	driverID := int64(456)
+	onlineRepo.On("IsDriverOnline", ctx, driverID).Return(true, nil)
+	rideRepo.On("AcceptRide", ctx, int64(1), driverID, mock.AnythingOfType("time.Time")).Return(nil)
+	rideRepo.On("Update", ctx, mock.AnythingOfType("*domain.Ride"), mock.Anything).Return(nil)
+	locationRepo.On("GetRideLocationHistory", ctx, int64(1)).Return(nil, nil)
+
+	ride, err := service.RequestRide(ctx, customerID, domain.RideTypeEconomy, 23.8100, 90.4120, 23.7509, 90.3761, nil, "", "")
+	require.NoError(t, err)
+	ride.OfferTo(driverID, time.Now().Add(time.Minute))
+	require.NoError(t, service.AcceptRide(ctx, ride.ID, driverID))
+	require.NoError(t, service.MarkArrived(ctx, ride.ID, driverID))
+	require.NoError(t, service.StartRide(ctx, ride.ID, driverID))
+	require.NoError(t, service.CompleteRide(ctx, ride.ID, driverID))
+
+	events, err := service.GetRideEvents(ctx, ride.ID, customerID, "customer")
+	require.NoError(t, err)
+
+	type change struct {
+		from, to  domain.RideStatus
+		actorRole string
+		actorID   int64
+	}
+	var changes []change
+	for i, event := range events {
+		assert.Equal(t, ride.ID, event.RideID)
+		if i > 0 {
+			assert.False(t, event.OccurredAt.Before(events[i-1].OccurredAt), "Events are in the order they happened")
+		}
+		changes = append(changes, change{event.FromStatus, event.ToStatus, event.ActorRole, event.ActorID})
+	}
+	assert.Equal(t, []change{
+		{"", domain.RideStatusRequested, "customer", customerID},
+		{domain.RideStatusPending, domain.RideStatusAccepted, "driver", driverID},
+		{domain.RideStatusAccepted, domain.RideStatusArrived, "driver", driverID},
+		{domain.RideStatusArrived, domain.RideStatusStarted, "driver", driverID},
+		{domain.RideStatusStarted, domain.RideStatusCompleted, "driver", driverID},
+	}, changes)
+}
+
+func TestRideService_RideEvents_OfferedThenExpired(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	onlineRepo := new(MockOnlineStatusRepository)
+	locationRepo := new(MockLocationRepository)
+	driverRepo := new(MockDriverRepository)
+	notifier := new(MockNotifier)
+
+	ctx := context.Background()
+	customerID := int64(123)
+	driverID := int64(456)
+
+	// Registered before newTestRideService so the driver near the pickup is found
+	locationRepo.On("FindNearestDrivers", ctx, 23.8100, 90.4120, testDispatchRadius, dispatchCandidateLimit).Return([]int64{driverID}, nil)
+	service := newTestRideService(rideRepo, onlineRepo, locationRepo)
+	service.driverService.driverRepo = driverRepo
+	service.driverService.rideRepo = rideRepo
+	service.notifier = notifier
+	expectNoSurge(rideRepo, locationRepo)
+	expectNoActiveRide(rideRepo)
+	createRidesWithIDs(rideRepo)
+
+	onlineRepo.On("GetOnlineDriversByIDs", ctx, []int64{driverID}).Return([]int64{driverID}, nil)
+	rideRepo.On("GetBusyDriverIDs", ctx, []int64{driverID}).Return([]int64{}, nil)
+	driverRepo.On("GetByID", ctx, driverID).Return(&domain.Driver{ID: driverID}, nil)
+
+	ride, err := service.RequestRide(ctx, customerID, domain.RideTypeEconomy, 23.8100, 90.4120, 23.7509, 90.3761, nil, "", "")
+	require.NoError(t, err)
+	require.Equal(t, domain.RideStatusPending, ride.Status, "The ride is offered to the driver near the pickup")
+
+	// The driver never answers and the ride outlives the request timeout
+	cutoff := time.Now()
+	rideRepo.On("GetStaleRequestedRides", ctx, cutoff).Return([]*domain.Ride{ride}, nil)
+	rideRepo.On("ExpireRide", ctx, ride, cutoff).Return(nil)
+	notifier.On("NotifyCustomerRideExpired", ctx, ride).Return(nil)
+
+	expired, err := service.ExpireStaleRequestedRides(ctx, cutoff)
+	require.NoError(t, err)
+	assert.Equal(t, 1, expired)
+	assert.Equal(t, domain.RideStatusCancelled, ride.Status)
+	assert.Equal(t, domain.CancelledBySystem, ride.CancelledBy)
+	notifier.AssertExpectations(t)
+
+	events, err := service.GetRideEvents(ctx, ride.ID, customerID, "customer")
+	require.NoError(t, err)
+
+	type change struct {
+		from, to  domain.RideStatus
+		actorRole string
+		actorID   int64
+		reason    string
+	}
+	var changes []change
+	for _, event := range events {
+		changes = append(changes, change{event.FromStatus, event.ToStatus, event.ActorRole, event.ActorID, event.Reason})
+	}
+	assert.Equal(t, []change{
+		{"", domain.RideStatusRequested, "customer", customerID, ""},
+		{domain.RideStatusRequested, domain.RideStatusPending, domain.CancelledBySystem, 0, ""},
+		{domain.RideStatusPending, domain.RideStatusCancelled, domain.CancelledBySystem, 0, rideExpiredReason},
+	}, changes)
+}
+
+func TestRideService_ExpireStaleRequestedRides_SkipsRideAcceptedMeanwhile(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	notifier := new(MockNotifier)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+	service.notifier = notifier
+
+	ctx := context.Background()
+	cutoff := time.Now()
+	ride := &domain.Ride{ID: 1, CustomerID: 123, Status: domain.RideStatusRequested, RequestedAt: cutoff.Add(-time.Hour)}
+	rideRepo.On("GetStaleRequestedRides", ctx, cutoff).Return([]*domain.Ride{ride}, nil)
+	rideRepo.On("ExpireRide", ctx, ride, cutoff).Return(repository.ErrRideNotExpirable)
+	rideRepo.On("GetByID", ctx, int64(1)).Return(ride, nil)
+
+	expired, err := service.ExpireStaleRequestedRides(ctx, cutoff)
+	require.NoError(t, err)
+	assert.Equal(t, 0, expired)
+	notifier.AssertNotCalled(t, "NotifyCustomerRideExpired", mock.Anything, mock.Anything)
+
+	events, err := service.GetRideEvents(ctx, 1, 123, "customer")
+	require.NoError(t, err)
+	assert.Empty(t, events)
+}
+
+func TestRideService_RideEvents_CancelAndAbandon(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+
+	ctx := context.Background()
+	driverID := int64(456)
+	acceptedAt := time.Now()
+	ride := &domain.Ride{
+		ID:          1,
+		CustomerID:  123,
+		DriverID:    &driverID,
+		Status:      domain.RideStatusAccepted,
+		RequestedAt: acceptedAt.Add(-time.Minute),
+		AcceptedAt:  &acceptedAt,
+	}
+	rideRepo.On("GetByID", ctx, int64(1)).Return(ride, nil)
+	rideRepo.On("AbandonRide", ctx, int64(1), mock.AnythingOfType("domain.RideAbandonment")).Return(nil)
+	rideRepo.On("Update", ctx, ride, mock.Anything).Return(nil)
+
+	require.NoError(t, service.DriverAbandonRide(ctx, 1, driverID, "vehicle broke down"))
+	_, err := service.CancelRideForCustomer(ctx, 1, 123, "changed my mind")
+	require.NoError(t, err)
+
+	events, err := service.GetRideEvents(ctx, 1, 123, "customer")
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, domain.RideStatusAccepted, events[0].FromStatus)
+	assert.Equal(t, domain.RideStatusRequested, events[0].ToStatus)
+	assert.Equal(t, "driver", events[0].ActorRole)
+	assert.Equal(t, "vehicle broke down", events[0].Reason)
+	assert.Equal(t, domain.RideStatusRequested, events[1].FromStatus)
+	assert.Equal(t, domain.RideStatusCancelled, events[1].ToStatus)
+	assert.Equal(t, domain.CancelledByCustomer, events[1].ActorRole)
+	assert.Equal(t, int64(123), events[1].ActorID)
+}
+
+func TestRideService_RideEvents_NotRecordedWhenUpdateFails(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+
+	ctx := context.Background()
+	driverID := int64(456)
+	acceptedAt := time.Now()
+	ride := &domain.Ride{ID: 1, CustomerID: 123, DriverID: &driverID, Status: domain.RideStatusAccepted, AcceptedAt: &acceptedAt}
+	rideRepo.On("GetByID", ctx, int64(1)).Return(ride, nil)
+	rideRepo.On("Update", ctx, ride, mock.Anything).Return(errors.New("database error"))
+
+	require.Error(t, service.StartRide(ctx, 1, driverID))
+
+	events, err := service.GetRideEvents(ctx, 1, driverID, "driver")
+	require.NoError(t, err)
+	assert.Empty(t, events)
+}
+
+func TestRideService_GetRideEvents_NotParticipant(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+
+	ctx := context.Background()
+	driverID := int64(456)
+	rideRepo.On("GetByID", ctx, int64(1)).Return(&domain.Ride{ID: 1, CustomerID: 123, DriverID: &driverID}, nil)
+	rideRepo.On("GetByID", ctx, int64(2)).Return(nil, repository.ErrRideNotFound)
+
+	_, err := service.GetRideEvents(ctx, 1, 999, "customer")
+	assert.ErrorIs(t, err, ErrNotRideParticipant)
+	_, err = service.GetRideEvents(ctx, 1, 123, "driver")
+	assert.ErrorIs(t, err, ErrNotRideParticipant)
+	_, err = service.GetRideEvents(ctx, 2, 123, "customer")
+	assert.ErrorIs(t, err, ErrRideNotFound)
+}
+
+func TestRideService_ListRides(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+
+	ctx := context.Background()
+	filter := repository.RideFilter{Status: domain.RideStatusCompleted, DriverID: 456}
+	rides := []*domain.Ride{{ID: 3, Status: domain.RideStatusCompleted}}
+	rideRepo.On("ListRides", ctx, filter, repository.Page{Limit: 20, Offset: 40}).Return(rides, int64(41), nil)
+
+	page, err := service.ListRides(ctx, filter, repository.Page{Limit: 20, Offset: 40})
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(41), page.Total)
+	assert.Equal(t, rides, page.Rides)
+	assert.Equal(t, 40, page.Offset)
+}
+
+func TestRideService_ListRides_NextCursor(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+
+	ctx := context.Background()
+	requestedAt := time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)
+	rides := []*domain.Ride{{ID: 8, RequestedAt: requestedAt.Add(time.Minute)}, {ID: 7, RequestedAt: requestedAt}}
+	cursor := &pagination.Cursor{Sort: RideListSort, Value: requestedAt.Add(time.Hour), ID: 9}
+	rideRepo.On("ListRides", ctx, repository.RideFilter{}, repository.Page{Limit: 2, Cursor: cursor}).Return(rides, int64(5), nil)
+	rideRepo.On("ListRides", ctx, repository.RideFilter{}, repository.Page{Limit: 3, Cursor: cursor}).Return(rides, int64(5), nil)
+
+	page, err := service.ListRides(ctx, repository.RideFilter{}, repository.Page{Limit: 2, Cursor: cursor})
+	require.NoError(t, err)
+	next, err := pagination.Decode(page.NextCursor, RideListSort)
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), next.ID, "A full page continues after its last ride")
+	assert.True(t, requestedAt.Equal(next.Value))
+
+	page, err = service.ListRides(ctx, repository.RideFilter{}, repository.Page{Limit: 3, Cursor: cursor})
+	require.NoError(t, err)
+	assert.Empty(t, page.NextCursor, "A short page is the last one")
+}
+
+func TestRideService_ListRides_InvalidFilter(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+
+	ctx := context.Background()
+	day := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+
+	_, err := service.ListRides(ctx, repository.RideFilter{Status: "lost"}, repository.Page{Limit: 20})
+	assert.ErrorIs(t, err, domain.ErrInvalidRideStatus)
+
+	_, err = service.ListRides(ctx, repository.RideFilter{From: day, To: day}, repository.Page{Limit: 20})
+	assert.ErrorIs(t, err, ErrInvalidDateRange)
+
+	rideRepo.AssertNotCalled(t, "ListRides", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRideService_GetNearbyRides_ClampsRadius(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	driverRepo := new(MockDriverRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+	service.driverService.driverRepo = driverRepo
+	service.locationService.maxSearchRadius = 50000
+
+	ctx := context.Background()
+	driverID := int64(456)
+	driverRepo.On("GetByID", ctx, driverID).Return(&domain.Driver{ID: driverID}, nil)
+	rideRepo.On("GetNearbyRequestedRides", ctx, driverID, domain.RideTypeEconomy, 23.8103, 90.4125, 50000.0, testNearbyFreshness, 10).Return([]*domain.Ride{}, nil)
+
+	_, err := service.GetNearbyRides(ctx, driverID, 23.8103, 90.4125, 10000000, 0, 10)
+
+	require.NoError(t, err)
+	rideRepo.AssertExpectations(t)
+}
+
+func completedRideWithCommission(id int64, fare float64, commissionRate *float64, completedAt time.Time) *domain.Ride {
+	return &domain.Ride{
+		ID:             id,
+		CustomerID:     123,
+		Status:         domain.RideStatusCompleted,
+		Fare:           &fare,
+		CommissionRate: commissionRate,
+		RequestedAt:    completedAt.Add(-30 * time.Minute),
+		CompletedAt:    &completedAt,
+	}
+}
+
+func TestRideService_GetRevenueReport_DateBounded(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+
+	ctx := context.Background()
+	from := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 3, 8, 0, 0, 0, 0, time.UTC)
+	rate := 0.2
+	rideRepo.On("GetCompletedRides", ctx, from, to).Return([]*domain.Ride{
+		completedRideWithCommission(2, 150, &rate, from.Add(48*time.Hour)),
+		completedRideWithCommission(1, 170.5, &rate, from.Add(time.Hour)),
+	}, nil)
+
+	report, err := service.GetRevenueReport(ctx, from, to)
+
+	require.NoError(t, err)
+	assert.Equal(t, from, report.From)
+	assert.Equal(t, to, report.To)
+	assert.Equal(t, "BDT", report.Currency)
+	assert.Equal(t, 2, report.RideCount)
+	assert.Equal(t, 320.5, report.GrossFare)
+	assert.Equal(t, 64.1, report.Commission)
+	assert.Equal(t, "BDT 64.10", report.CommissionFormatted)
+	rideRepo.AssertExpectations(t)
+}
+
+func TestRideService_GetRevenueReport_MixedCommissionRates(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+
+	ctx := context.Background()
+	from := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	oldRate, newRate := 0.15, 0.25
+	rideRepo.On("GetCompletedRides", ctx, from, to).Return([]*domain.Ride{
+		completedRideWithCommission(3, 200, &newRate, from.Add(20*24*time.Hour)),
+		completedRideWithCommission(2, 100, &oldRate, from.Add(5*24*time.Hour)),
+		completedRideWithCommission(1, 80, nil, from.Add(time.Hour)), // completed before rates were recorded
+	}, nil)
+
+	report, err := service.GetRevenueReport(ctx, from, to)
+
+	require.NoError(t, err)
+	assert.Equal(t, 3, report.RideCount)
+	assert.Equal(t, 380.0, report.GrossFare)
+	assert.Equal(t, 65.0, report.Commission, "Each ride is charged the rate recorded when it completed, not the current 20%")
+}
+
+func TestRideService_GetRevenueReport_InvalidDateRange(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+
+	day := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	_, err := service.GetRevenueReport(context.Background(), day, day)
+
+	assert.ErrorIs(t, err, ErrInvalidDateRange)
+	rideRepo.AssertNotCalled(t, "GetCompletedRides", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRideService_GetRideETA_BeforeStartTargetsPickup(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	locationRepo := new(MockLocationRepository)
+	service := newTestRideStatusService(rideRepo, new(MockDriverRepository), locationRepo)
+
+	ctx := context.Background()
+	driverID := int64(456)
+	ride := &domain.Ride{
+		ID:          1,
+		CustomerID:  123,
+		DriverID:    &driverID,
+		PickupLat:   23.8100,
+		PickupLng:   90.4120,
+		DropoffLat:  23.7509,
+		DropoffLng:  90.3761,
+		Status:      domain.RideStatusAccepted,
+		RequestedAt: time.Now(),
+	}
+	updatedAt := time.Now().Add(-10 * time.Second)
+	driverLocation := domain.Location{Latitude: 23.8189, Longitude: 90.4120} // just under 1km north of pickup
+
+	rideRepo.On("GetByID", ctx, int64(1)).Return(ride, nil)
+	locationRepo.On("GetDriverLocation", ctx, driverID).Return(driverLocation.Latitude, driverLocation.Longitude, &updatedAt, nil)
+
+	eta, err := service.GetRideETA(ctx, 1, 123)
+
+	require.NoError(t, err)
+	assert.Equal(t, ETATargetPickup, eta.Target)
+	assert.Equal(t, ride.PickupLat, eta.TargetLat)
+	assert.InDelta(t, driverLocation.DistanceTo(domain.Location{Latitude: ride.PickupLat, Longitude: ride.PickupLng}), eta.DistanceMeters, 0.001)
+	require.NotNil(t, eta.EtaMinutes)
+	assert.Equal(t, 3, *eta.EtaMinutes)
+	assert.Equal(t, &updatedAt, eta.LocationUpdatedAt)
+}
+
+func TestRideService_GetRideETA_AfterStartTargetsDropoff(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	locationRepo := new(MockLocationRepository)
+	service := newTestRideStatusService(rideRepo, new(MockDriverRepository), locationRepo)
+
+	ctx := context.Background()
+	driverID := int64(456)
+	ride := &domain.Ride{
+		ID:          1,
+		CustomerID:  123,
+		DriverID:    &driverID,
+		PickupLat:   23.8100,
+		PickupLng:   90.4120,
+		DropoffLat:  23.7509,
+		DropoffLng:  90.3761,
+		Status:      domain.RideStatusStarted,
+		RequestedAt: time.Now(),
+	}
+	updatedAt := time.Now()
+	driverLocation := domain.Location{Latitude: 23.8100, Longitude: 90.4120} // still at the pickup
+
+	rideRepo.On("GetByID", ctx, int64(1)).Return(ride, nil)
+	locationRepo.On("GetDriverLocation", ctx, driverID).Return(driverLocation.Latitude, driverLocation.Longitude, &updatedAt, nil)
+
+	eta, err := service.GetRideETA(ctx, 1, 123)
+
+	require.NoError(t, err)
+	assert.Equal(t, ETATargetDropoff, eta.Target)
+	assert.Equal(t, ride.DropoffLat, eta.TargetLat)
+	assert.Equal(t, ride.DropoffLng, eta.TargetLng)
+	dropoffDistance := driverLocation.DistanceTo(domain.Location{Latitude: ride.DropoffLat, Longitude: ride.DropoffLng})
+	assert.InDelta(t, dropoffDistance, eta.DistanceMeters, 0.001)
+	expected, _ := etaMinutes(dropoffDistance, service.averageSpeedKmh)
+	require.NotNil(t, eta.EtaMinutes)
+	assert.Equal(t, expected, *eta.EtaMinutes)
+}
+
+func TestRideService_GetRideETA_NoDriver(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	locationRepo := new(MockLocationRepository)
+	service := newTestRideStatusService(rideRepo, new(MockDriverRepository), locationRepo)
+
+	ctx := context.Background()
+	ride := &domain.Ride{ID: 1, CustomerID: 123, Status: domain.RideStatusRequested, RequestedAt: time.Now()}
+	rideRepo.On("GetByID", ctx, int64(1)).Return(ride, nil)
+
+	_, err := service.GetRideETA(ctx, 1, 123)
+
+	assert.ErrorIs(t, err, ErrRideNoDriver)
+	locationRepo.AssertNotCalled(t, "GetDriverLocation", mock.Anything, mock.Anything)
+}
+
+func TestRideService_GetRideETA_FinishedRide(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := newTestRideStatusService(rideRepo, new(MockDriverRepository), new(MockLocationRepository))
+
+	ctx := context.Background()
+	driverID := int64(456)
+	ride := &domain.Ride{ID: 1, CustomerID: 123, DriverID: &driverID, Status: domain.RideStatusCompleted, RequestedAt: time.Now()}
+	rideRepo.On("GetByID", ctx, int64(1)).Return(ride, nil)
+
+	_, err := service.GetRideETA(ctx, 1, 123)
+
+	assert.ErrorIs(t, err, ErrRideNotInProgress)
+}
+
+func TestRideService_GetRideETA_OtherCustomer(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := newTestRideStatusService(rideRepo, new(MockDriverRepository), new(MockLocationRepository))
+
+	ctx := context.Background()
+	driverID := int64(456)
+	ride := &domain.Ride{ID: 1, CustomerID: 123, DriverID: &driverID, Status: domain.RideStatusAccepted, RequestedAt: time.Now()}
+	rideRepo.On("GetByID", ctx, int64(1)).Return(ride, nil)
+
+	_, err := service.GetRideETA(ctx, 1, 999)
+
+	assert.ErrorIs(t, err, ErrRideForbidden)
+}
+
+func TestRideService_ReleaseStaleAcceptedRides_ReleasesStaleRide(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+	eventRepo := &fakeRideEventRepository{}
+	service.eventRepo = eventRepo
+
+	ctx := context.Background()
+	now := time.Now()
+	cutoff := now.Add(-20 * time.Minute)
+	driverID := int64(456)
+	acceptedAt := now.Add(-30 * time.Minute)
+	stale := &domain.Ride{ID: 1, CustomerID: 123, DriverID: &driverID, Status: domain.RideStatusAccepted, RequestedAt: acceptedAt.Add(-time.Minute), AcceptedAt: &acceptedAt}
+
+	rideRepo.On("GetStaleAcceptedRides", ctx, cutoff).Return([]*domain.Ride{stale}, nil)
+	rideRepo.On("AbandonRide", ctx, int64(1), mock.MatchedBy(func(a domain.RideAbandonment) bool {
+		return a.DriverID == driverID && a.Reason == acceptanceTimeoutReason
+	})).Return(nil)
+
+	released, err := service.ReleaseStaleAcceptedRides(ctx, cutoff)
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, released)
+	assert.Nil(t, stale.DriverID)
+	assert.Contains(t, stale.DeclinedBy, driverID, "The driver who let the ride go stale is not offered it again")
+	require.Len(t, eventRepo.events, 1)
+	assert.Equal(t, domain.RideStatusAccepted, eventRepo.events[0].FromStatus)
+	assert.Equal(t, domain.RideStatusRequested, eventRepo.events[0].ToStatus)
+	assert.Equal(t, domain.CancelledBySystem, eventRepo.events[0].ActorRole)
+	rideRepo.AssertExpectations(t)
+}
+
+func TestRideService_ReleaseStaleAcceptedRides_KeepsFreshRide(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+
+	ctx := context.Background()
+	now := time.Now()
+	cutoff := now.Add(-20 * time.Minute)
+	driverID := int64(456)
+	acceptedAt := now.Add(-5 * time.Minute)
+	fresh := &domain.Ride{ID: 1, CustomerID: 123, DriverID: &driverID, Status: domain.RideStatusAccepted, RequestedAt: acceptedAt.Add(-time.Minute), AcceptedAt: &acceptedAt}
+
+	// A ride accepted after the cutoff is left alone even if the repository returns it
+	rideRepo.On("GetStaleAcceptedRides", ctx, cutoff).Return([]*domain.Ride{fresh}, nil)
+
+	released, err := service.ReleaseStaleAcceptedRides(ctx, cutoff)
+
+	require.NoError(t, err)
+	assert.Equal(t, 0, released)
+	assert.Equal(t, domain.RideStatusAccepted, fresh.Status)
+	rideRepo.AssertNotCalled(t, "AbandonRide", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRideService_ReleaseStaleAcceptedRides_StartedInTheMeantime(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+
+	ctx := context.Background()
+	now := time.Now()
+	cutoff := now.Add(-20 * time.Minute)
+	driverID := int64(456)
+	acceptedAt := now.Add(-30 * time.Minute)
+	ride := &domain.Ride{ID: 1, CustomerID: 123, DriverID: &driverID, Status: domain.RideStatusAccepted, RequestedAt: acceptedAt.Add(-time.Minute), AcceptedAt: &acceptedAt}
+
+	rideRepo.On("GetStaleAcceptedRides", ctx, cutoff).Return([]*domain.Ride{ride}, nil)
+	rideRepo.On("AbandonRide", ctx, int64(1), mock.AnythingOfType("domain.RideAbandonment")).Return(repository.ErrRideNotAbandonable)
+
+	released, err := service.ReleaseStaleAcceptedRides(ctx, cutoff)
+
+	require.NoError(t, err)
+	assert.Equal(t, 0, released)
+	rideRepo.AssertNotCalled(t, "SetRideOffer", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
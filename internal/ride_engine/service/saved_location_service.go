@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/repository"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/logger"
+)
+
+// MaxSavedLocationsPerCustomer caps how many places a customer can save
+const MaxSavedLocationsPerCustomer = 20
+
+var (
+	ErrSavedLocationNotFound   = domain.NewError(domain.ErrNotFound, "saved location not found")
+	ErrSavedLocationLabelTaken = domain.NewError(domain.ErrConflict, "a saved location with this label already exists")
+	ErrTooManySavedLocations   = domain.NewError(domain.ErrValidation, "saved location limit reached")
+)
+
+type SavedLocationService struct {
+	repo repository.SavedLocationRepository
+}
+
+func NewSavedLocationService(repo repository.SavedLocationRepository) *SavedLocationService {
+	return &SavedLocationService{repo: repo}
+}
+
+// Create stores a labelled place for the customer, labels are unique per customer
+func (s *SavedLocationService) Create(ctx context.Context, customerID int64, label string, lat, lng float64) (*domain.SavedLocation, error) {
+	location := &domain.SavedLocation{
+		CustomerID: customerID,
+		Label:      strings.TrimSpace(label),
+		Lat:        lat,
+		Lng:        lng,
+		CreatedAt:  time.Now(),
+	}
+	if err := domain.ValidateSavedLocation(location); err != nil {
+		logger.Error(ctx, fmt.Sprintf("invalid saved location for customer %d: %v", customerID, err))
+		return nil, err
+	}
+
+	existing, err := s.repo.ListByCustomer(ctx, customerID)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to list saved locations for customer %d: %v", customerID, err))
+		return nil, err
+	}
+	if len(existing) >= MaxSavedLocationsPerCustomer {
+		logger.Error(ctx, fmt.Sprintf("customer %d already has %d saved locations", customerID, len(existing)))
+		return nil, ErrTooManySavedLocations
+	}
+	for _, l := range existing {
+		if strings.EqualFold(l.Label, location.Label) {
+			return nil, ErrSavedLocationLabelTaken
+		}
+	}
+
+	if err := s.repo.Create(ctx, location); err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to save location for customer %d: %v", customerID, err))
+		if errors.Is(err, repository.ErrSavedLocationLabelExists) {
+			return nil, ErrSavedLocationLabelTaken
+		}
+		return nil, err
+	}
+
+	return location, nil
+}
+
+// List returns the customer's saved locations
+func (s *SavedLocationService) List(ctx context.Context, customerID int64) ([]*domain.SavedLocation, error) {
+	locations, err := s.repo.ListByCustomer(ctx, customerID)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to list saved locations for customer %d: %v", customerID, err))
+		return nil, err
+	}
+	return locations, nil
+}
+
+// Get returns one of the customer's saved locations
+func (s *SavedLocationService) Get(ctx context.Context, customerID, id int64) (*domain.SavedLocation, error) {
+	location, err := s.repo.GetByID(ctx, id, customerID)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to get saved location %d for customer %d: %v", id, customerID, err))
+		if errors.Is(err, repository.ErrSavedLocationNotFound) {
+			return nil, ErrSavedLocationNotFound
+		}
+		return nil, err
+	}
+	return location, nil
+}
+
+// Delete removes one of the customer's saved locations
+func (s *SavedLocationService) Delete(ctx context.Context, customerID, id int64) error {
+	if err := s.repo.Delete(ctx, id, customerID); err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to delete saved location %d for customer %d: %v", id, customerID, err))
+		if errors.Is(err, repository.ErrSavedLocationNotFound) {
+			return ErrSavedLocationNotFound
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/repository"
+)
+
+// MockSavedLocationRepository is a mock implementation of the saved location repository
+type MockSavedLocationRepository struct {
+	mock.Mock
+}
+
+func (m *MockSavedLocationRepository) Create(ctx context.Context, location *domain.SavedLocation) error {
+	args := m.Called(ctx, location)
+	return args.Error(0)
+}
+
+func (m *MockSavedLocationRepository) GetByID(ctx context.Context, id, customerID int64) (*domain.SavedLocation, error) {
+	args := m.Called(ctx, id, customerID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.SavedLocation), args.Error(1)
+}
+
+func (m *MockSavedLocationRepository) ListByCustomer(ctx context.Context, customerID int64) ([]*domain.SavedLocation, error) {
+	args := m.Called(ctx, customerID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.SavedLocation), args.Error(1)
+}
+
+func (m *MockSavedLocationRepository) Delete(ctx context.Context, id, customerID int64) error {
+	args := m.Called(ctx, id, customerID)
+	return args.Error(0)
+}
+
+func (m *MockSavedLocationRepository) DeleteByCustomer(ctx context.Context, customerID int64) error {
+	args := m.Called(ctx, customerID)
+	return args.Error(0)
+}
+
+func TestSavedLocationService_Create(t *testing.T) {
+	repo := new(MockSavedLocationRepository)
+	service := NewSavedLocationService(repo)
+
+	ctx := context.Background()
+
+	repo.On("ListByCustomer", ctx, int64(123)).Return([]*domain.SavedLocation{{ID: 1, CustomerID: 123, Label: "Work"}}, nil)
+	repo.On("Create", ctx, mock.MatchedBy(func(l *domain.SavedLocation) bool {
+		return l.CustomerID == 123 && l.Label == "Home" && l.Lat == 23.8103 && l.Lng == 90.4125
+	})).Run(func(args mock.Arguments) {
+		args.Get(1).(*domain.SavedLocation).ID = 2
+	}).Return(nil)
+
+	location, err := service.Create(ctx, 123, "  Home ", 23.8103, 90.4125)
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), location.ID)
+	assert.Equal(t, "Home", location.Label)
+	repo.AssertExpectations(t)
+}
+
+func TestSavedLocationService_Create_DuplicateLabel(t *testing.T) {
+	repo := new(MockSavedLocationRepository)
+	service := NewSavedLocationService(repo)
+
+	ctx := context.Background()
+
+	repo.On("ListByCustomer", ctx, int64(123)).Return([]*domain.SavedLocation{{ID: 1, CustomerID: 123, Label: "Home"}}, nil)
+
+	_, err := service.Create(ctx, 123, "home", 23.8103, 90.4125)
+
+	assert.ErrorIs(t, err, ErrSavedLocationLabelTaken)
+	repo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestSavedLocationService_Create_LabelSavedConcurrently(t *testing.T) {
+	repo := new(MockSavedLocationRepository)
+	service := NewSavedLocationService(repo)
+
+	ctx := context.Background()
+
+	// Another request saved the label after this one listed the customer's locations
+	repo.On("ListByCustomer", ctx, int64(123)).Return([]*domain.SavedLocation{}, nil)
+	repo.On("Create", ctx, mock.Anything).Return(repository.ErrSavedLocationLabelExists)
+
+	_, err := service.Create(ctx, 123, "Home", 23.8103, 90.4125)
+
+	assert.ErrorIs(t, err, ErrSavedLocationLabelTaken)
+}
+
+func TestSavedLocationService_Create_InvalidCoordinates(t *testing.T) {
+	repo := new(MockSavedLocationRepository)
+	service := NewSavedLocationService(repo)
+
+	_, err := service.Create(context.Background(), 123, "Home", 123.0, 90.4125)
+
+	assert.ErrorIs(t, err, domain.ErrInvalidLatitude)
+	repo.AssertNotCalled(t, "ListByCustomer", mock.Anything, mock.Anything)
+}
+
+func TestSavedLocationService_ListScopedToCustomer(t *testing.T) {
+	repo := new(MockSavedLocationRepository)
+	service := NewSavedLocationService(repo)
+
+	ctx := context.Background()
+
+	repo.On("ListByCustomer", ctx, int64(123)).Return([]*domain.SavedLocation{
+		{ID: 1, CustomerID: 123, Label: "Home"},
+		{ID: 2, CustomerID: 123, Label: "Work"},
+	}, nil)
+	repo.On("GetByID", ctx, int64(3), int64(123)).Return(nil, repository.ErrSavedLocationNotFound)
+
+	locations, err := service.List(ctx, 123)
+
+	require.NoError(t, err)
+	require.Len(t, locations, 2)
+	for _, l := range locations {
+		assert.Equal(t, int64(123), l.CustomerID)
+	}
+
+	// Another customer's location is not visible
+	_, err = service.Get(ctx, 123, 3)
+	assert.ErrorIs(t, err, ErrSavedLocationNotFound)
+	repo.AssertExpectations(t)
+}
+
+func TestSavedLocationService_Delete_NotFound(t *testing.T) {
+	repo := new(MockSavedLocationRepository)
+	service := NewSavedLocationService(repo)
+
+	ctx := context.Background()
+
+	repo.On("Delete", ctx, int64(3), int64(123)).Return(repository.ErrSavedLocationNotFound)
+
+	err := service.Delete(ctx, 123, 3)
+
+	assert.ErrorIs(t, err, ErrSavedLocationNotFound)
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"vcs.technonext.com/carrybee/ride_engine/pkg/config"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/logger"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/utils"
+)
+
+// SMSSender delivers a text message to a phone number
+type SMSSender interface {
+	Send(ctx context.Context, phone, message string) error
+}
+
+// SMSMessage is the request sent to the SMS provider
+type SMSMessage struct {
+	To      string `json:"to"`
+	From    string `json:"from,omitempty"`
+	Message string `json:"message"`
+}
+
+// HTTPSMSSender sends text messages through an HTTP SMS gateway
+type HTTPSMSSender struct {
+	providerURL string
+	apiKey      string
+	senderID    string
+	client      *http.Client
+}
+
+func NewHTTPSMSSender(cfg config.SMSConfig) *HTTPSMSSender {
+	return &HTTPSMSSender{
+		providerURL: cfg.ProviderURL,
+		apiKey:      cfg.APIKey,
+		senderID:    cfg.SenderID,
+		client:      &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+// Send posts the message to the provider, any non 2xx response is an error
+func (s *HTTPSMSSender) Send(ctx context.Context, phone, message string) error {
+	payload, err := json.Marshal(SMSMessage{To: phone, From: s.senderID, Message: message})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.providerURL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+s.apiKey)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to send SMS to %s: %v", utils.MaskPhone(phone), err))
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		err := fmt.Errorf("sms provider responded with status %d", resp.StatusCode)
+		logger.Error(ctx, fmt.Sprintf("Failed to send SMS to %s: %v", utils.MaskPhone(phone), err))
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/config"
+)
+
+func TestHTTPSMSSender_Send(t *testing.T) {
+	var received SMSMessage
+	var authorization string
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+	}))
+	defer provider.Close()
+
+	sender := NewHTTPSMSSender(config.SMSConfig{ProviderURL: provider.URL, APIKey: "test-key", SenderID: "Carrybee", Timeout: time.Second})
+
+	err := sender.Send(context.Background(), "+8801700000000", "Your code is 123456")
+
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer test-key", authorization)
+	assert.Equal(t, SMSMessage{To: "+8801700000000", From: "Carrybee", Message: "Your code is 123456"}, received)
+}
+
+func TestHTTPSMSSender_ProviderError(t *testing.T) {
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer provider.Close()
+
+	sender := NewHTTPSMSSender(config.SMSConfig{ProviderURL: provider.URL, Timeout: time.Second})
+
+	err := sender.Send(context.Background(), "+8801700000000", "Your code is 123456")
+
+	assert.Error(t, err)
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/logger"
+
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/repository"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/config"
+)
+
+// NoSurge is the multiplier when drivers keep up with demand
+const NoSurge = 1.0
+
+// surgeSampleLimit caps how many rides and drivers are counted, the multiplier reaches its cap well before this
+const surgeSampleLimit = 100
+
+// surgeDemandWindow is how recently a ride request must have been updated to count as demand
+const surgeDemandWindow = 5 * time.Minute
+
+type SurgeService struct {
+	rideRepo        repository.RideRepository
+	locationService *LocationService
+	radiusMeters    float64
+	maxMultiplier   float64
+}
+
+func NewSurgeService(cfg config.FareConfig, rideRepo repository.RideRepository, locationService *LocationService) *SurgeService {
+	return &SurgeService{
+		rideRepo:        rideRepo,
+		locationService: locationService,
+		radiusMeters:    cfg.SurgeRadiusMeters,
+		maxMultiplier:   cfg.MaxSurgeMultiplier,
+	}
+}
+
+// GetMultiplier returns the fare multiplier for a pickup at lat, lng
+// Demand is the open ride requests around the pickup plus the one being priced, supply is the online drivers around it
+func (s *SurgeService) GetMultiplier(ctx context.Context, lat, lng float64) (float64, error) {
+	demand := 1
+	for _, rideType := range domain.RideTypes {
+		rides, err := s.rideRepo.GetNearbyRequestedRides(ctx, 0, rideType, lat, lng, s.radiusMeters, surgeDemandWindow, surgeSampleLimit)
+		if err != nil {
+			logger.Error(ctx, fmt.Sprintf("Failed to count %s ride requests near (%f, %f): %v", rideType, lat, lng, err))
+			return NoSurge, err
+		}
+		demand += len(rides)
+	}
+
+	drivers, err := s.locationService.FindNearestDrivers(ctx, lat, lng, s.radiusMeters, surgeSampleLimit)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to count drivers near (%f, %f): %v", lat, lng, err))
+		return NoSurge, err
+	}
+
+	return surgeMultiplier(demand, len(drivers), s.maxMultiplier), nil
+}
+
+// surgeMultiplier is the demand to supply ratio rounded to one decimal, between NoSurge and maxMultiplier
+func surgeMultiplier(demand, supply int, maxMultiplier float64) float64 {
+	if supply == 0 {
+		return maxMultiplier
+	}
+	multiplier := math.Round(float64(demand)/float64(supply)*10) / 10
+	return math.Min(math.Max(multiplier, NoSurge), maxMultiplier)
+}
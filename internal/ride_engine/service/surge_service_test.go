@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/config"
+)
+
+var testSurgeConfig = config.FareConfig{SurgeRadiusMeters: 3000, MaxSurgeMultiplier: 3}
+
+// expectSurgeDemand makes the mocks report requested economy rides and online drivers around any pickup
+func expectSurgeDemand(rideRepo *MockRideRepository, locationRepo *MockLocationRepository, requested, drivers int) {
+	rides := make([]*domain.Ride, requested)
+	for i := range rides {
+		rides[i] = &domain.Ride{ID: int64(i + 1), Status: domain.RideStatusRequested, RideType: domain.RideTypeEconomy}
+	}
+	driverIDs := make([]int64, drivers)
+	for i := range driverIDs {
+		driverIDs[i] = int64(i + 1)
+	}
+
+	rideRepo.On("GetNearbyRequestedRides", mock.Anything, int64(0), domain.RideTypeEconomy, mock.Anything, mock.Anything, testSurgeConfig.SurgeRadiusMeters, surgeDemandWindow, surgeSampleLimit).Return(rides, nil)
+	rideRepo.On("GetNearbyRequestedRides", mock.Anything, int64(0), mock.Anything, mock.Anything, mock.Anything, testSurgeConfig.SurgeRadiusMeters, surgeDemandWindow, surgeSampleLimit).Return([]*domain.Ride{}, nil)
+	locationRepo.On("FindNearestDrivers", mock.Anything, mock.Anything, mock.Anything, testSurgeConfig.SurgeRadiusMeters, surgeSampleLimit).Return(driverIDs, nil)
+}
+
+// expectNoSurge makes the mocks report as many drivers as open requests around any pickup
+func expectNoSurge(rideRepo *MockRideRepository, locationRepo *MockLocationRepository) {
+	expectSurgeDemand(rideRepo, locationRepo, 0, 1)
+}
+
+func TestSurgeService_GetMultiplier_HighDemand(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	locationRepo := new(MockLocationRepository)
+	service := NewSurgeService(testSurgeConfig, rideRepo, &LocationService{repo: locationRepo})
+	expectSurgeDemand(rideRepo, locationRepo, 3, 2)
+
+	multiplier, err := service.GetMultiplier(context.Background(), 23.8100, 90.4120)
+
+	require.NoError(t, err)
+	assert.Equal(t, 2.0, multiplier) // 3 open requests plus this one for 2 drivers
+}
+
+func TestSurgeService_GetMultiplier_Balanced(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	locationRepo := new(MockLocationRepository)
+	service := NewSurgeService(testSurgeConfig, rideRepo, &LocationService{repo: locationRepo})
+	expectSurgeDemand(rideRepo, locationRepo, 2, 5)
+
+	multiplier, err := service.GetMultiplier(context.Background(), 23.8100, 90.4120)
+
+	require.NoError(t, err)
+	assert.Equal(t, NoSurge, multiplier)
+}
+
+func TestSurgeService_GetMultiplier_Capped(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	locationRepo := new(MockLocationRepository)
+	service := NewSurgeService(testSurgeConfig, rideRepo, &LocationService{repo: locationRepo})
+	expectSurgeDemand(rideRepo, locationRepo, 20, 1)
+
+	multiplier, err := service.GetMultiplier(context.Background(), 23.8100, 90.4120)
+
+	require.NoError(t, err)
+	assert.Equal(t, testSurgeConfig.MaxSurgeMultiplier, multiplier)
+}
+
+func TestSurgeMultiplier(t *testing.T) {
+	tests := []struct {
+		name     string
+		demand   int
+		supply   int
+		expected float64
+	}{
+		{name: "no drivers", demand: 1, supply: 0, expected: 3},
+		{name: "more drivers than requests", demand: 1, supply: 4, expected: 1},
+		{name: "equal", demand: 4, supply: 4, expected: 1},
+		{name: "rounded to one decimal", demand: 4, supply: 3, expected: 1.3},
+		{name: "capped", demand: 10, supply: 2, expected: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, surgeMultiplier(tt.demand, tt.supply, 3))
+		})
+	}
+}
+
+func TestRideService_RequestRide_AppliesSurge(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	locationRepo := new(MockLocationRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), locationRepo)
+	expectSurgeDemand(rideRepo, locationRepo, 3, 1)
+	expectNoActiveRide(rideRepo)
+
+	ctx := context.Background()
+	rideRepo.On("Create", ctx, mock.AnythingOfType("*domain.Ride")).Return(nil)
+
+	ride, err := service.RequestRide(ctx, 123, domain.RideTypeEconomy, 23.8100, 90.4120, 23.7509, 90.3761, nil, "", "")
+
+	require.NoError(t, err)
+	assert.Equal(t, 3.0, ride.SurgeMultiplier)
+	require.NotNil(t, ride.Fare)
+	flatFare := service.fareService.CalculateFare(plannedDistance(ride), 0)
+	assert.InDelta(t, flatFare*3, *ride.Fare, 0.01)
+}
+
+func TestRideService_RequestRide_NoSurgeWhenDemandUnavailable(t *testing.T) {
+	rideRepo := new(MockRideRepository)
+	service := newTestRideService(rideRepo, new(MockOnlineStatusRepository), new(MockLocationRepository))
+	expectNoActiveRide(rideRepo)
+
+	ctx := context.Background()
+	rideRepo.On("GetNearbyRequestedRides", ctx, int64(0), domain.RideTypeEconomy, 23.8100, 90.4120, testSurgeConfig.SurgeRadiusMeters, surgeDemandWindow, surgeSampleLimit).Return(nil, errors.New("database error"))
+	rideRepo.On("Create", ctx, mock.AnythingOfType("*domain.Ride")).Return(nil)
+
+	ride, err := service.RequestRide(ctx, 123, domain.RideTypeEconomy, 23.8100, 90.4120, 23.7509, 90.3761, nil, "", "")
+
+	require.NoError(t, err)
+	assert.Equal(t, NoSurge, ride.SurgeMultiplier)
+	require.NotNil(t, ride.Fare)
+	assert.Equal(t, service.fareService.CalculateFare(plannedDistance(ride), 0), *ride.Fare)
+}
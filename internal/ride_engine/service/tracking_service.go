@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/repository"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/logger"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/utils"
+)
+
+// rideTrackingStatusInterval is how often a tracking stream checks whether the ride has finished
+const rideTrackingStatusInterval = 5 * time.Second
+
+// Tracking event types
+const (
+	TrackingEventLocation = "location"
+	TrackingEventStatus   = "status" // sent once when the ride finishes, right before the stream ends
+)
+
+// RideTrackingEvent is a message streamed to a customer tracking their ride
+type RideTrackingEvent struct {
+	Type     string    `json:"type"`
+	RideID   int64     `json:"ride_id"`
+	DriverID int64     `json:"driver_id,omitempty"`
+	Lat      *float64  `json:"lat,omitempty"`
+	Lng      *float64  `json:"lng,omitempty"`
+	Status   string    `json:"status,omitempty"`
+	At       time.Time `json:"at"`
+}
+
+type TrackingService struct {
+	redis          *redis.Client
+	rideRepo       repository.RideRepository
+	statusInterval time.Duration
+}
+
+func NewTrackingService(redis *redis.Client, rideRepo repository.RideRepository) *TrackingService {
+	return &TrackingService{
+		redis:          redis,
+		rideRepo:       rideRepo,
+		statusInterval: rideTrackingStatusInterval,
+	}
+}
+
+// PublishRideLocation pushes the driver's location to the customer tracking the ride
+func (s *TrackingService) PublishRideLocation(ctx context.Context, ride *domain.Ride, driverID int64, lat, lng float64) error {
+	payload, err := json.Marshal(RideTrackingEvent{
+		Type:     TrackingEventLocation,
+		RideID:   ride.ID,
+		DriverID: driverID,
+		Lat:      &lat,
+		Lng:      &lng,
+		At:       time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := s.redis.Publish(ctx, utils.RideLocationChannel(ride.ID), payload).Err(); err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to publish location for ride %d: %v", ride.ID, err))
+		return err
+	}
+
+	return nil
+}
+
+// StreamRideLocation calls send with every location published for the ride until the ride
+// finishes, send fails or ctx is cancelled. A final status event is sent when the ride finishes
+func (s *TrackingService) StreamRideLocation(ctx context.Context, rideID int64, send func(payload []byte) error) error {
+	pubsub := s.redis.Subscribe(ctx, utils.RideLocationChannel(rideID))
+	defer pubsub.Close()
+
+	// Wait for the subscription to be confirmed so no update published after this call is missed
+	if _, err := pubsub.Receive(ctx); err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to subscribe to ride %d location: %v", rideID, err))
+		return err
+	}
+
+	ticker := time.NewTicker(s.statusInterval)
+	defer ticker.Stop()
+
+	updates := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-updates:
+			if !ok {
+				return nil
+			}
+			if err := send([]byte(msg.Payload)); err != nil {
+				return err
+			}
+		case <-ticker.C:
+			ride, err := s.rideRepo.GetByID(ctx, rideID)
+			if err != nil {
+				logger.Error(ctx, fmt.Sprintf("Failed to get ride %d: %v", rideID, err))
+				continue
+			}
+			if !ride.IsTerminal() {
+				continue
+			}
+
+			payload, err := json.Marshal(RideTrackingEvent{
+				Type:   TrackingEventStatus,
+				RideID: rideID,
+				Status: string(ride.Status),
+				At:     time.Now(),
+			})
+			if err != nil {
+				return err
+			}
+			return send(payload)
+		}
+	}
+}
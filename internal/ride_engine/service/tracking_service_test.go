@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/testutil"
+)
+
+// startTrackingStream runs StreamRideLocation in the background and returns the received events
+func startTrackingStream(t *testing.T, ctx context.Context, service *TrackingService, rideID int64) (<-chan RideTrackingEvent, <-chan error) {
+	t.Helper()
+
+	events := make(chan RideTrackingEvent, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- service.StreamRideLocation(ctx, rideID, func(payload []byte) error {
+			var event RideTrackingEvent
+			if err := json.Unmarshal(payload, &event); err != nil {
+				return err
+			}
+			events <- event
+			return nil
+		})
+	}()
+
+	return events, done
+}
+
+func TestTrackingService_PublishedLocationReachesSubscriber(t *testing.T) {
+	redisClient, _ := testutil.NewFakeRedis()
+	rideRepo := new(MockRideRepository)
+	service := NewTrackingService(redisClient, rideRepo)
+	service.statusInterval = time.Hour
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	driverID := int64(7)
+	ride := &domain.Ride{ID: 42, DriverID: &driverID, Status: domain.RideStatusStarted}
+
+	events, done := startTrackingStream(t, ctx, service, ride.ID)
+
+	// Keep publishing until the stream has subscribed, earlier messages have no receiver
+	var event RideTrackingEvent
+	require.Eventually(t, func() bool {
+		assert.NoError(t, service.PublishRideLocation(ctx, ride, driverID, 23.8103, 90.4125))
+		select {
+		case event = <-events:
+			return true
+		case <-time.After(20 * time.Millisecond):
+			return false
+		}
+	}, 2*time.Second, 10*time.Millisecond, "published location did not reach the subscriber")
+
+	assert.Equal(t, TrackingEventLocation, event.Type)
+	assert.Equal(t, int64(42), event.RideID)
+	assert.Equal(t, driverID, event.DriverID)
+	require.NotNil(t, event.Lat)
+	require.NotNil(t, event.Lng)
+	assert.Equal(t, 23.8103, *event.Lat)
+	assert.Equal(t, 90.4125, *event.Lng)
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("stream did not stop after the context was cancelled")
+	}
+}
+
+func TestTrackingService_StreamEndsWhenRideCompletes(t *testing.T) {
+	redisClient, _ := testutil.NewFakeRedis()
+	rideRepo := new(MockRideRepository)
+	service := NewTrackingService(redisClient, rideRepo)
+	service.statusInterval = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ride := &domain.Ride{ID: 42, Status: domain.RideStatusCompleted}
+	rideRepo.On("GetByID", mock.Anything, int64(42)).Return(ride, nil)
+
+	events, done := startTrackingStream(t, ctx, service, ride.ID)
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("stream did not stop after the ride completed")
+	}
+
+	require.Len(t, events, 1)
+	event := <-events
+	assert.Equal(t, TrackingEventStatus, event.Type)
+	assert.Equal(t, string(domain.RideStatusCompleted), event.Status)
+}
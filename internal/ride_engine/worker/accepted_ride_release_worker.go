@@ -0,0 +1,63 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"vcs.technonext.com/carrybee/ride_engine/pkg/logger"
+)
+
+// StaleRideReleaser releases rides accepted before cutoff that their driver has not started
+type StaleRideReleaser interface {
+	ReleaseStaleAcceptedRides(ctx context.Context, cutoff time.Time) (int, error)
+}
+
+// AcceptedRideReleaseWorker periodically returns rides a driver accepted but did not start within timeout to the pool
+type AcceptedRideReleaseWorker struct {
+	releaser StaleRideReleaser
+	timeout  time.Duration
+	interval time.Duration
+	now      func() time.Time
+}
+
+func NewAcceptedRideReleaseWorker(releaser StaleRideReleaser, timeout, interval time.Duration) *AcceptedRideReleaseWorker {
+	return &AcceptedRideReleaseWorker{
+		releaser: releaser,
+		timeout:  timeout,
+		interval: interval,
+		now:      time.Now,
+	}
+}
+
+// Start runs the worker until ctx is cancelled
+func (w *AcceptedRideReleaseWorker) Start(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	logger.Info(ctx, fmt.Sprintf("Accepted ride release worker started (timeout: %s, interval: %s)", w.timeout, w.interval))
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info(context.Background(), "Accepted ride release worker stopped")
+			return
+		case <-ticker.C:
+			w.releaseStaleRides(ctx)
+		}
+	}
+}
+
+func (w *AcceptedRideReleaseWorker) releaseStaleRides(ctx context.Context) {
+	cutoff := w.now().Add(-w.timeout)
+
+	released, err := w.releaser.ReleaseStaleAcceptedRides(ctx, cutoff)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to release stale accepted rides: %v", err))
+		return
+	}
+
+	if released > 0 {
+		logger.Info(ctx, fmt.Sprintf("Released %d accepted rides that were not started in time", released))
+	}
+}
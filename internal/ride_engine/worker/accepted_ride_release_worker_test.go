@@ -0,0 +1,56 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type mockStaleRideReleaser struct {
+	mock.Mock
+}
+
+func (m *mockStaleRideReleaser) ReleaseStaleAcceptedRides(ctx context.Context, cutoff time.Time) (int, error) {
+	args := m.Called(ctx, cutoff)
+	return args.Int(0), args.Error(1)
+}
+
+func TestAcceptedRideReleaseWorker_UsesTimeout(t *testing.T) {
+	releaser := new(mockStaleRideReleaser)
+	w := NewAcceptedRideReleaseWorker(releaser, 15*time.Minute, time.Minute)
+
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	w.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	releaser.On("ReleaseStaleAcceptedRides", ctx, now.Add(-15*time.Minute)).Return(1, nil)
+
+	w.releaseStaleRides(ctx)
+
+	releaser.AssertExpectations(t)
+}
+
+func TestAcceptedRideReleaseWorker_StopsOnCancel(t *testing.T) {
+	releaser := new(mockStaleRideReleaser)
+	releaser.On("ReleaseStaleAcceptedRides", mock.Anything, mock.AnythingOfType("time.Time")).Return(0, errors.New("mongo down")).Maybe()
+	w := NewAcceptedRideReleaseWorker(releaser, 15*time.Minute, time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		w.Start(ctx)
+		close(done)
+	}()
+
+	time.Sleep(5 * time.Millisecond)
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("worker did not stop after the context was cancelled")
+	}
+}
@@ -0,0 +1,57 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"vcs.technonext.com/carrybee/ride_engine/pkg/logger"
+)
+
+// RideDispatcher moves ride offers that expired on to the next driver
+type RideDispatcher interface {
+	AdvanceExpiredOffers(ctx context.Context) (int, error)
+}
+
+// DispatchWorker periodically offers rides whose offer expired or was never made to the next nearest driver
+type DispatchWorker struct {
+	dispatcher RideDispatcher
+	interval   time.Duration
+}
+
+func NewDispatchWorker(dispatcher RideDispatcher, interval time.Duration) *DispatchWorker {
+	return &DispatchWorker{
+		dispatcher: dispatcher,
+		interval:   interval,
+	}
+}
+
+// Start runs the worker until ctx is cancelled
+func (w *DispatchWorker) Start(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	logger.Info(ctx, fmt.Sprintf("Dispatch worker started (interval: %s)", w.interval))
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info(context.Background(), "Dispatch worker stopped")
+			return
+		case <-ticker.C:
+			w.advanceExpiredOffers(ctx)
+		}
+	}
+}
+
+func (w *DispatchWorker) advanceExpiredOffers(ctx context.Context) {
+	dispatched, err := w.dispatcher.AdvanceExpiredOffers(ctx)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to advance expired ride offers: %v", err))
+		return
+	}
+
+	if dispatched > 0 {
+		logger.Info(ctx, fmt.Sprintf("Dispatched %d rides with expired offers", dispatched))
+	}
+}
@@ -0,0 +1,53 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type mockRideDispatcher struct {
+	mock.Mock
+}
+
+func (m *mockRideDispatcher) AdvanceExpiredOffers(ctx context.Context) (int, error) {
+	args := m.Called(ctx)
+	return args.Int(0), args.Error(1)
+}
+
+func TestDispatchWorker_AdvancesExpiredOffers(t *testing.T) {
+	dispatcher := new(mockRideDispatcher)
+	w := NewDispatchWorker(dispatcher, 5*time.Second)
+
+	ctx := context.Background()
+	dispatcher.On("AdvanceExpiredOffers", ctx).Return(2, nil)
+
+	w.advanceExpiredOffers(ctx)
+
+	dispatcher.AssertExpectations(t)
+}
+
+func TestDispatchWorker_StopsOnCancel(t *testing.T) {
+	dispatcher := new(mockRideDispatcher)
+	dispatcher.On("AdvanceExpiredOffers", mock.Anything).Return(0, errors.New("mongo down")).Maybe()
+	w := NewDispatchWorker(dispatcher, time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		w.Start(ctx)
+		close(done)
+	}()
+
+	time.Sleep(5 * time.Millisecond)
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("worker did not stop after the context was cancelled")
+	}
+}
@@ -0,0 +1,84 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/repository"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/logger"
+)
+
+// InactiveDriverWorker periodically takes drivers offline once they stop sending location pings
+type InactiveDriverWorker struct {
+	onlineStatusRepo repository.OnlineStatusRepository
+	driverRepo       repository.DriverRepository
+	sessionRepo      repository.DriverSessionRepository
+	tx               repository.Transactor
+	cutoff           time.Duration
+	interval         time.Duration
+	now              func() time.Time
+}
+
+func NewInactiveDriverWorker(onlineStatusRepo repository.OnlineStatusRepository, driverRepo repository.DriverRepository, sessionRepo repository.DriverSessionRepository, tx repository.Transactor, cutoff, interval time.Duration) *InactiveDriverWorker {
+	return &InactiveDriverWorker{
+		onlineStatusRepo: onlineStatusRepo,
+		driverRepo:       driverRepo,
+		sessionRepo:      sessionRepo,
+		tx:               tx,
+		cutoff:           cutoff,
+		interval:         interval,
+		now:              time.Now,
+	}
+}
+
+// Start runs the worker until ctx is cancelled
+func (w *InactiveDriverWorker) Start(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	logger.Info(ctx, fmt.Sprintf("Inactive driver worker started (cutoff: %s, interval: %s)", w.cutoff, w.interval))
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info(context.Background(), "Inactive driver worker stopped")
+			return
+		case <-ticker.C:
+			w.removeInactiveDrivers(ctx)
+		}
+	}
+}
+
+// removeInactiveDrivers drops stale online rows, archiving each as a session that ended at the driver's last ping,
+// and clears the online flag on the driver record
+// Both steps run even if the other fails so one bad table does not leave the other stale
+func (w *InactiveDriverWorker) removeInactiveDrivers(ctx context.Context) {
+	cutoff := w.now().Add(-w.cutoff)
+
+	err := w.tx.WithTransaction(ctx, func(ctx context.Context) error {
+		removed, err := w.onlineStatusRepo.RemoveInactiveDrivers(ctx, cutoff)
+		if err != nil {
+			return err
+		}
+		for _, driver := range removed {
+			session := &domain.DriverSession{
+				DriverID:  driver.DriverID,
+				StartedAt: driver.WentOnlineAt,
+				EndedAt:   driver.LastPingAt,
+			}
+			if err := w.sessionRepo.Create(ctx, session); err != nil {
+				return fmt.Errorf("archive session of driver %d: %w", driver.DriverID, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to remove inactive online drivers: %v", err))
+	}
+
+	if err := w.driverRepo.MarkOfflineIfInactive(ctx, cutoff); err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to mark inactive drivers offline: %v", err))
+	}
+}
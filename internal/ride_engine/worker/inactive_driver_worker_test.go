@@ -0,0 +1,151 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/domain"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/repository"
+)
+
+// mockOnlineStatusRepository mocks the calls the worker makes, other methods are left unimplemented
+type mockOnlineStatusRepository struct {
+	repository.OnlineStatusRepository
+	mock.Mock
+}
+
+func (m *mockOnlineStatusRepository) RemoveInactiveDrivers(ctx context.Context, cutoffTime time.Time) ([]repository.OnlineDriver, error) {
+	args := m.Called(ctx, cutoffTime)
+	removed, _ := args.Get(0).([]repository.OnlineDriver)
+	return removed, args.Error(1)
+}
+
+// mockDriverRepository mocks the calls the worker makes, other methods are left unimplemented
+type mockDriverRepository struct {
+	repository.DriverRepository
+	mock.Mock
+}
+
+func (m *mockDriverRepository) MarkOfflineIfInactive(ctx context.Context, cutoff time.Time) error {
+	args := m.Called(ctx, cutoff)
+	return args.Error(0)
+}
+
+// fakeDriverSessionRepository keeps the archived sessions in memory
+type fakeDriverSessionRepository struct {
+	repository.DriverSessionRepository
+	sessions []*domain.DriverSession
+}
+
+func (f *fakeDriverSessionRepository) Create(ctx context.Context, session *domain.DriverSession) error {
+	f.sessions = append(f.sessions, session)
+	return nil
+}
+
+// fakeTransactor runs fn directly without a transaction
+type fakeTransactor struct{}
+
+func (fakeTransactor) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func newTestInactiveDriverWorker(onlineRepo *mockOnlineStatusRepository, driverRepo *mockDriverRepository, interval time.Duration) (*InactiveDriverWorker, *fakeDriverSessionRepository) {
+	sessionRepo := &fakeDriverSessionRepository{}
+	return NewInactiveDriverWorker(onlineRepo, driverRepo, sessionRepo, fakeTransactor{}, 2*time.Minute, interval), sessionRepo
+}
+
+func TestInactiveDriverWorker_UsesCutoff(t *testing.T) {
+	onlineRepo := new(mockOnlineStatusRepository)
+	driverRepo := new(mockDriverRepository)
+	w, _ := newTestInactiveDriverWorker(onlineRepo, driverRepo, time.Minute)
+
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	w.now = func() time.Time { return now }
+	expectedCutoff := now.Add(-2 * time.Minute)
+
+	ctx := context.Background()
+	onlineRepo.On("RemoveInactiveDrivers", ctx, expectedCutoff).Return(nil, nil)
+	driverRepo.On("MarkOfflineIfInactive", ctx, expectedCutoff).Return(nil)
+
+	w.removeInactiveDrivers(ctx)
+
+	onlineRepo.AssertExpectations(t)
+	driverRepo.AssertExpectations(t)
+}
+
+func TestInactiveDriverWorker_MarksOfflineWhenRemoveFails(t *testing.T) {
+	onlineRepo := new(mockOnlineStatusRepository)
+	driverRepo := new(mockDriverRepository)
+	w, _ := newTestInactiveDriverWorker(onlineRepo, driverRepo, time.Minute)
+
+	ctx := context.Background()
+	onlineRepo.On("RemoveInactiveDrivers", ctx, mock.AnythingOfType("time.Time")).Return(nil, errors.New("db down"))
+	driverRepo.On("MarkOfflineIfInactive", ctx, mock.AnythingOfType("time.Time")).Return(nil)
+
+	w.removeInactiveDrivers(ctx)
+
+	driverRepo.AssertExpectations(t)
+}
+
+func TestInactiveDriverWorker_ArchivesRemovedDriversAsSessions(t *testing.T) {
+	onlineRepo := new(mockOnlineStatusRepository)
+	driverRepo := new(mockDriverRepository)
+	w, sessionRepo := newTestInactiveDriverWorker(onlineRepo, driverRepo, time.Minute)
+
+	wentOnline := time.Date(2025, 1, 1, 8, 0, 0, 0, time.UTC)
+	lastPing := wentOnline.Add(3 * time.Hour)
+	ctx := context.Background()
+	onlineRepo.On("RemoveInactiveDrivers", ctx, mock.AnythingOfType("time.Time")).
+		Return([]repository.OnlineDriver{{DriverID: 7, WentOnlineAt: wentOnline, LastPingAt: lastPing}}, nil)
+	driverRepo.On("MarkOfflineIfInactive", ctx, mock.AnythingOfType("time.Time")).Return(nil)
+
+	w.removeInactiveDrivers(ctx)
+
+	if assert.Len(t, sessionRepo.sessions, 1) {
+		session := sessionRepo.sessions[0]
+		assert.Equal(t, int64(7), session.DriverID)
+		assert.Equal(t, wentOnline, session.StartedAt)
+		assert.Equal(t, lastPing, session.EndedAt)
+	}
+}
+
+func TestInactiveDriverWorker_StartRunsOnTickAndStops(t *testing.T) {
+	onlineRepo := new(mockOnlineStatusRepository)
+	driverRepo := new(mockDriverRepository)
+	w, _ := newTestInactiveDriverWorker(onlineRepo, driverRepo, 10*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ran := make(chan struct{}, 1)
+	onlineRepo.On("RemoveInactiveDrivers", ctx, mock.AnythingOfType("time.Time")).Return(nil, nil)
+	driverRepo.On("MarkOfflineIfInactive", ctx, mock.AnythingOfType("time.Time")).
+		Run(func(mock.Arguments) {
+			select {
+			case ran <- struct{}{}:
+			default:
+			}
+		}).
+		Return(nil)
+
+	done := make(chan struct{})
+	go func() {
+		w.Start(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Fatal("worker did not run on tick")
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("worker did not stop after cancel")
+	}
+}
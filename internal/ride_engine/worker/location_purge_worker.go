@@ -0,0 +1,63 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/repository"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/logger"
+)
+
+// LocationPurgeWorker periodically deletes driver and ride location points past the retention period
+type LocationPurgeWorker struct {
+	locationRepo repository.LocationRepository
+	retention    time.Duration
+	interval     time.Duration
+	now          func() time.Time
+}
+
+func NewLocationPurgeWorker(locationRepo repository.LocationRepository, retention, interval time.Duration) *LocationPurgeWorker {
+	return &LocationPurgeWorker{
+		locationRepo: locationRepo,
+		retention:    retention,
+		interval:     interval,
+		now:          time.Now,
+	}
+}
+
+// Start runs the worker until ctx is cancelled
+func (w *LocationPurgeWorker) Start(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	logger.Info(ctx, fmt.Sprintf("Location purge worker started (retention: %s, interval: %s)", w.retention, w.interval))
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info(context.Background(), "Location purge worker stopped")
+			return
+		case <-ticker.C:
+			w.purgeOldLocations(ctx)
+		}
+	}
+}
+
+func (w *LocationPurgeWorker) purgeOldLocations(ctx context.Context) {
+	cutoff := w.now().Add(-w.retention)
+
+	driverDeleted, err := w.locationRepo.DeleteOldDriverLocations(ctx, cutoff)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to purge old driver locations: %v", err))
+	} else {
+		logger.Info(ctx, fmt.Sprintf("Purged %d driver location points older than %s", driverDeleted, cutoff.Format(time.RFC3339)))
+	}
+
+	rideDeleted, err := w.locationRepo.DeleteOldRideLocations(ctx, cutoff)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to purge old ride locations: %v", err))
+	} else {
+		logger.Info(ctx, fmt.Sprintf("Purged %d ride location points older than %s", rideDeleted, cutoff.Format(time.RFC3339)))
+	}
+}
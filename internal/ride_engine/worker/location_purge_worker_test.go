@@ -0,0 +1,57 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"vcs.technonext.com/carrybee/ride_engine/internal/ride_engine/repository"
+)
+
+// mockLocationRepository mocks the calls the worker makes, other methods are left unimplemented
+type mockLocationRepository struct {
+	repository.LocationRepository
+	mock.Mock
+}
+
+func (m *mockLocationRepository) DeleteOldDriverLocations(ctx context.Context, before time.Time) (int64, error) {
+	args := m.Called(ctx, before)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *mockLocationRepository) DeleteOldRideLocations(ctx context.Context, before time.Time) (int64, error) {
+	args := m.Called(ctx, before)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func TestLocationPurgeWorker_UsesRetention(t *testing.T) {
+	locationRepo := new(mockLocationRepository)
+	w := NewLocationPurgeWorker(locationRepo, 30*24*time.Hour, 24*time.Hour)
+
+	now := time.Date(2025, 3, 31, 3, 0, 0, 0, time.UTC)
+	w.now = func() time.Time { return now }
+	expectedCutoff := time.Date(2025, 3, 1, 3, 0, 0, 0, time.UTC)
+
+	ctx := context.Background()
+	locationRepo.On("DeleteOldDriverLocations", ctx, expectedCutoff).Return(int64(120), nil)
+	locationRepo.On("DeleteOldRideLocations", ctx, expectedCutoff).Return(int64(40), nil)
+
+	w.purgeOldLocations(ctx)
+
+	locationRepo.AssertExpectations(t)
+}
+
+func TestLocationPurgeWorker_PurgesRidesWhenDriverPurgeFails(t *testing.T) {
+	locationRepo := new(mockLocationRepository)
+	w := NewLocationPurgeWorker(locationRepo, 30*24*time.Hour, 24*time.Hour)
+
+	ctx := context.Background()
+	locationRepo.On("DeleteOldDriverLocations", ctx, mock.AnythingOfType("time.Time")).Return(int64(0), errors.New("mongo down"))
+	locationRepo.On("DeleteOldRideLocations", ctx, mock.AnythingOfType("time.Time")).Return(int64(3), nil)
+
+	w.purgeOldLocations(ctx)
+
+	locationRepo.AssertExpectations(t)
+}
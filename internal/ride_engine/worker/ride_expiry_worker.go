@@ -0,0 +1,61 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"vcs.technonext.com/carrybee/ride_engine/pkg/logger"
+)
+
+// StaleRideExpirer cancels rides still waiting for a driver that were requested before cutoff
+type StaleRideExpirer interface {
+	ExpireStaleRequestedRides(ctx context.Context, cutoff time.Time) (int, error)
+}
+
+// RideExpiryWorker periodically cancels ride requests that no driver accepted in time
+type RideExpiryWorker struct {
+	expirer  StaleRideExpirer
+	timeout  time.Duration
+	interval time.Duration
+	now      func() time.Time
+}
+
+func NewRideExpiryWorker(expirer StaleRideExpirer, timeout, interval time.Duration) *RideExpiryWorker {
+	return &RideExpiryWorker{
+		expirer:  expirer,
+		timeout:  timeout,
+		interval: interval,
+		now:      time.Now,
+	}
+}
+
+// Start runs the worker until ctx is cancelled
+func (w *RideExpiryWorker) Start(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	logger.Info(ctx, fmt.Sprintf("Ride expiry worker started (timeout: %s, interval: %s)", w.timeout, w.interval))
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info(context.Background(), "Ride expiry worker stopped")
+			return
+		case <-ticker.C:
+			w.expireStaleRides(ctx)
+		}
+	}
+}
+
+func (w *RideExpiryWorker) expireStaleRides(ctx context.Context) {
+	cutoff := w.now().Add(-w.timeout)
+
+	expired, err := w.expirer.ExpireStaleRequestedRides(ctx, cutoff)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Failed to expire stale ride requests: %v", err))
+		return
+	}
+
+	logger.Info(ctx, fmt.Sprintf("Expired %d stale ride requests", expired))
+}
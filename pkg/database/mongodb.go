@@ -17,22 +17,18 @@ type MongoDB struct {
 	Database *mongo.Database
 }
 
-func NewMongoDB(cfg config.MongoDBConfig) (*MongoDB, error) {
+// NewMongoDB connects to MongoDB, retrying with backoff while it is not reachable yet
+func NewMongoDB(cfg config.MongoDBConfig, retry config.ConnectRetryConfig) (*MongoDB, error) {
+	return connectWithRetry(context.Background(), "MongoDB", retry, func() (*MongoDB, error) {
+		return openMongoDB(cfg)
+	})
+}
+
+func openMongoDB(cfg config.MongoDBConfig) (*MongoDB, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	commandMonitor := &event.CommandMonitor{
-		Started: func(ctx context.Context, evt *event.CommandStartedEvent) {
-			fmt.Printf("MongoDB Query: %s\n", evt.Command.String())
-		},
-	}
-
-	clientOptions := options.Client().ApplyURI(cfg.URI)
-	clientOptions.SetMaxPoolSize(50)
-	clientOptions.SetMinPoolSize(10)
-	clientOptions.SetMonitor(commandMonitor)
-
-	client, err := mongo.Connect(ctx, clientOptions)
+	client, err := mongo.Connect(ctx, newMongoClientOptions(cfg))
 	if err != nil {
 		logger.Error(ctx, err)
 		return nil, err
@@ -40,6 +36,7 @@ func NewMongoDB(cfg config.MongoDBConfig) (*MongoDB, error) {
 
 	if err := client.Ping(ctx, nil); err != nil {
 		logger.Error(ctx, err)
+		client.Disconnect(ctx)
 		return nil, err
 	}
 
@@ -52,6 +49,32 @@ func NewMongoDB(cfg config.MongoDBConfig) (*MongoDB, error) {
 	}, nil
 }
 
+func newMongoClientOptions(cfg config.MongoDBConfig) *options.ClientOptions {
+	clientOptions := options.Client().ApplyURI(cfg.URI)
+	clientOptions.SetMaxPoolSize(50)
+	clientOptions.SetMinPoolSize(10)
+	if cfg.OperationTimeout > 0 {
+		// Operations whose context has no deadline fail after the timeout instead of waiting on a slow server
+		clientOptions.SetTimeout(cfg.OperationTimeout)
+	}
+	if monitor := newCommandMonitor(cfg.Debug); monitor != nil {
+		clientOptions.SetMonitor(monitor)
+	}
+	return clientOptions
+}
+
+// newCommandMonitor logs every command at debug level when debug is on, it returns nil otherwise
+func newCommandMonitor(debug bool) *event.CommandMonitor {
+	if !debug {
+		return nil
+	}
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, evt *event.CommandStartedEvent) {
+			logger.Debug(fmt.Sprintf("MongoDB Query: %s", evt.Command.String()))
+		},
+	}
+}
+
 func (m *MongoDB) Close() error {
 	logger.Info(context.Background(), "Closing MongoDB connection...")
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -0,0 +1,41 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/config"
+)
+
+func TestNewCommandMonitor_DebugOff(t *testing.T) {
+	assert.Nil(t, newCommandMonitor(false))
+}
+
+func TestNewCommandMonitor_DebugOn(t *testing.T) {
+	monitor := newCommandMonitor(true)
+
+	if assert.NotNil(t, monitor) {
+		assert.NotNil(t, monitor.Started)
+	}
+}
+
+func TestNewMongoClientOptions_MonitorFollowsDebug(t *testing.T) {
+	cfg := config.MongoDBConfig{URI: "mongodb://localhost:27017", Database: "ride_engine"}
+
+	assert.Nil(t, newMongoClientOptions(cfg).Monitor)
+
+	cfg.Debug = true
+	assert.NotNil(t, newMongoClientOptions(cfg).Monitor)
+}
+
+func TestNewMongoClientOptions_OperationTimeout(t *testing.T) {
+	cfg := config.MongoDBConfig{URI: "mongodb://localhost:27017", Database: "ride_engine"}
+
+	assert.Nil(t, newMongoClientOptions(cfg).Timeout)
+
+	cfg.OperationTimeout = 5 * time.Second
+	if timeout := newMongoClientOptions(cfg).Timeout; assert.NotNil(t, timeout) {
+		assert.Equal(t, 5*time.Second, *timeout)
+	}
+}
@@ -2,6 +2,7 @@ package database
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"gorm.io/driver/postgres"
@@ -15,22 +16,46 @@ type PostgresDB struct {
 	*gorm.DB
 }
 
-func NewPostgresDB(cfg config.PostgresConfig) (*PostgresDB, error) {
+// NewPostgresDB connects to PostgreSQL, retrying with backoff while it is not reachable yet
+func NewPostgresDB(cfg config.PostgresConfig, retry config.ConnectRetryConfig) (*PostgresDB, error) {
+	return connectWithRetry(context.Background(), "PostgreSQL", retry, func() (*PostgresDB, error) {
+		return openPostgres(cfg)
+	})
+}
+
+// OpenPostgres opens gorm on dialector with the settings every PostgreSQL connection of the service uses
+// NewPostgresDB opens it on the configured server, tests can open it on a fake connection
+func OpenPostgres(dialector gorm.Dialector, operationTimeout time.Duration) (*PostgresDB, error) {
 	gormConfig := &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Info),
 		NowFunc: func() time.Time {
 			return time.Now().UTC()
 		},
 		PrepareStmt: true,
+		// Lets repositories match constraint violations with gorm.ErrDuplicatedKey and the like
+		TranslateError: true,
 	}
 
-	db, err := gorm.Open(postgres.Open(cfg.DSN()), gormConfig)
+	db, err := gorm.Open(dialector, gormConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := registerOperationTimeout(db, operationTimeout); err != nil {
+		return nil, err
+	}
+
+	return &PostgresDB{db}, nil
+}
+
+func openPostgres(cfg config.PostgresConfig) (*PostgresDB, error) {
+	db, err := OpenPostgres(postgres.Open(cfg.DSN()), cfg.OperationTimeout)
 	if err != nil {
 		log.Error(context.Background(), err)
 		return nil, err
 	}
 
-	sqlDB, err := db.DB()
+	sqlDB, err := db.DB.DB()
 	if err != nil {
 		log.Error(context.Background(), err)
 		return nil, err
@@ -46,11 +71,32 @@ func NewPostgresDB(cfg config.PostgresConfig) (*PostgresDB, error) {
 
 	if err := sqlDB.PingContext(ctx); err != nil {
 		log.Error(context.Background(), err)
+		sqlDB.Close()
 		return nil, err
 	}
 
 	log.Info(context.Background(), "PostgreSQL connected successfully with GORM")
-	return &PostgresDB{db}, nil
+	return db, nil
+}
+
+// txContextKey carries the transaction started by WithTransaction on the context passed to its fn
+type txContextKey struct{}
+
+// WithTransaction runs fn in a transaction, repositories using Conn with the ctx fn is given all write in it
+// The transaction is rolled back when fn returns an error or panics and committed otherwise
+// Called inside another transaction it runs fn in a savepoint of that transaction
+func (db *PostgresDB) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return db.Conn(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(context.WithValue(ctx, txContextKey{}, tx))
+	})
+}
+
+// Conn returns the transaction WithTransaction runs on ctx, or a session on the pool bound to ctx outside a transaction
+func (db *PostgresDB) Conn(ctx context.Context) *gorm.DB {
+	if tx, ok := ctx.Value(txContextKey{}).(*gorm.DB); ok {
+		return tx.WithContext(ctx)
+	}
+	return db.WithContext(ctx)
 }
 
 func (db *PostgresDB) Close() error {
@@ -69,3 +115,50 @@ func (db *PostgresDB) HealthCheck(ctx context.Context) error {
 	}
 	return sqlDB.PingContext(ctx)
 }
+
+// operationTimeoutKey stores the operationTimeout of a statement between the gorm callbacks
+const operationTimeoutKey = "ride_engine:operation_timeout"
+
+// operationTimeout is the context a statement had before its timeout was applied and the cancel function of the timeout
+type operationTimeout struct {
+	parent context.Context
+	cancel context.CancelFunc
+}
+
+// registerOperationTimeout bounds every create, query, update, delete and raw exec to timeout through gorm callbacks,
+// so repositories get the timeout without wrapping each call
+// Rows and Row are left out since their rows are read after the callbacks finish
+// The statement gets its own context back afterwards, since a chain like Count then Find runs several operations on it
+func registerOperationTimeout(db *gorm.DB, timeout time.Duration) error {
+	if timeout <= 0 {
+		return nil
+	}
+
+	start := func(tx *gorm.DB) {
+		parent := tx.Statement.Context
+		ctx, cancel := context.WithTimeout(parent, timeout)
+		tx.Statement.Context = ctx
+		tx.InstanceSet(operationTimeoutKey, operationTimeout{parent: parent, cancel: cancel})
+	}
+	stop := func(tx *gorm.DB) {
+		if value, ok := tx.InstanceGet(operationTimeoutKey); ok {
+			timeout := value.(operationTimeout)
+			timeout.cancel()
+			tx.Statement.Context = timeout.parent
+		}
+	}
+
+	callbacks := db.Callback()
+	return errors.Join(
+		callbacks.Create().Before("*").Register("ride_engine:start_timeout", start),
+		callbacks.Create().After("*").Register("ride_engine:stop_timeout", stop),
+		callbacks.Query().Before("*").Register("ride_engine:start_timeout", start),
+		callbacks.Query().After("*").Register("ride_engine:stop_timeout", stop),
+		callbacks.Update().Before("*").Register("ride_engine:start_timeout", start),
+		callbacks.Update().After("*").Register("ride_engine:stop_timeout", stop),
+		callbacks.Delete().Before("*").Register("ride_engine:start_timeout", start),
+		callbacks.Delete().After("*").Register("ride_engine:stop_timeout", stop),
+		callbacks.Raw().Before("*").Register("ride_engine:start_timeout", start),
+		callbacks.Raw().After("*").Register("ride_engine:stop_timeout", stop),
+	)
+}
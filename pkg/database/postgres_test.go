@@ -0,0 +1,228 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// slowConnPool never answers, every call waits until its context is done
+type slowConnPool struct{}
+
+func (slowConnPool) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (slowConnPool) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (slowConnPool) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (slowConnPool) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	<-ctx.Done()
+	return nil
+}
+
+func newSlowTestDB(t *testing.T, timeout time.Duration) *gorm.DB {
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: slowConnPool{}}), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, registerOperationTimeout(db, timeout))
+	return db
+}
+
+func TestRegisterOperationTimeout_QueryExceedsTimeout(t *testing.T) {
+	db := newSlowTestDB(t, 20*time.Millisecond)
+
+	var names []string
+	started := time.Now()
+	err := db.WithContext(context.Background()).Table("drivers").Pluck("name", &names).Error
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(started), time.Second)
+}
+
+func TestRegisterOperationTimeout_ExecExceedsTimeout(t *testing.T) {
+	db := newSlowTestDB(t, 20*time.Millisecond)
+
+	err := db.WithContext(context.Background()).Exec("UPDATE drivers SET is_online = false").Error
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestRegisterOperationTimeout_ShorterRequestDeadlineWins(t *testing.T) {
+	db := newSlowTestDB(t, time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	started := time.Now()
+	err := db.WithContext(ctx).Exec("UPDATE drivers SET is_online = false").Error
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(started), time.Second)
+}
+
+// emptyConnector opens connections that answer every query with no rows
+type emptyConnector struct{}
+
+func (emptyConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return emptyConn{}, nil
+}
+
+func (emptyConnector) Driver() driver.Driver {
+	return nil
+}
+
+type emptyConn struct{}
+
+func (emptyConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepare is not supported")
+}
+
+func (emptyConn) Close() error {
+	return nil
+}
+
+func (emptyConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions are not supported")
+}
+
+func (emptyConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return emptyRows{}, nil
+}
+
+type emptyRows struct{}
+
+func (emptyRows) Columns() []string {
+	return []string{"count"}
+}
+
+func (emptyRows) Close() error {
+	return nil
+}
+
+func (emptyRows) Next(dest []driver.Value) error {
+	return io.EOF
+}
+
+func TestRegisterOperationTimeout_SeveralOperationsOnOneChain(t *testing.T) {
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sql.OpenDB(emptyConnector{})}), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, registerOperationTimeout(db, time.Minute))
+
+	// Like a paginated listing, the total is counted and then the page is read with the same chain
+	query := db.WithContext(context.Background()).Table("otps").Where("phone = ?", "+8801700000000")
+
+	var total int64
+	require.NoError(t, query.Count(&total).Error)
+
+	var phones []string
+	assert.NoError(t, query.Limit(10).Pluck("phone", &phones).Error, "The first operation's timeout should not carry over to the next")
+}
+
+// recordingConnPool answers every exec and logs the statements and transaction boundaries it is sent
+type recordingConnPool struct {
+	log *[]string
+}
+
+func (p recordingConnPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (gorm.ConnPool, error) {
+	*p.log = append(*p.log, "BEGIN")
+	return &recordingTx{p}, nil
+}
+
+func (p recordingConnPool) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return nil, errors.New("prepare is not supported")
+}
+
+func (p recordingConnPool) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	*p.log = append(*p.log, query)
+	return driver.RowsAffected(1), nil
+}
+
+func (p recordingConnPool) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return nil, errors.New("query is not supported")
+}
+
+func (p recordingConnPool) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return nil
+}
+
+type recordingTx struct {
+	recordingConnPool
+}
+
+func (tx *recordingTx) Commit() error {
+	*tx.log = append(*tx.log, "COMMIT")
+	return nil
+}
+
+func (tx *recordingTx) Rollback() error {
+	*tx.log = append(*tx.log, "ROLLBACK")
+	return nil
+}
+
+func newRecordingTestDB(t *testing.T) (*PostgresDB, *[]string) {
+	log := &[]string{}
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: recordingConnPool{log: log}}), &gorm.Config{})
+	require.NoError(t, err)
+	return &PostgresDB{db}, log
+}
+
+func TestPostgresDB_WithTransaction_ErrorRollsBack(t *testing.T) {
+	db, log := newRecordingTestDB(t)
+	errSecondStep := errors.New("saved location could not be created")
+
+	err := db.WithTransaction(context.Background(), func(ctx context.Context) error {
+		if err := db.Conn(ctx).Exec("INSERT INTO customers (name) VALUES ('Rahim')").Error; err != nil {
+			return err
+		}
+		return errSecondStep
+	})
+
+	assert.ErrorIs(t, err, errSecondStep)
+	assert.Equal(t, []string{"BEGIN", "INSERT INTO customers (name) VALUES ('Rahim')", "ROLLBACK"}, *log)
+}
+
+func TestPostgresDB_WithTransaction_Commits(t *testing.T) {
+	db, log := newRecordingTestDB(t)
+
+	err := db.WithTransaction(context.Background(), func(ctx context.Context) error {
+		if err := db.Conn(ctx).Exec("INSERT INTO customers (name) VALUES ('Rahim')").Error; err != nil {
+			return err
+		}
+		return db.Conn(ctx).Exec("INSERT INTO saved_locations (label) VALUES ('Home')").Error
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"BEGIN",
+		"INSERT INTO customers (name) VALUES ('Rahim')",
+		"INSERT INTO saved_locations (label) VALUES ('Home')",
+		"COMMIT",
+	}, *log)
+}
+
+func TestPostgresDB_Conn_OutsideTransaction(t *testing.T) {
+	db, log := newRecordingTestDB(t)
+
+	require.NoError(t, db.Conn(context.Background()).Exec("DELETE FROM device_tokens").Error)
+
+	assert.Equal(t, []string{"DELETE FROM device_tokens"}, *log)
+}
@@ -13,7 +13,14 @@ type RedisDB struct {
 	Client *redis.Client
 }
 
-func NewRedisDB(cfg config.RedisConfig) (*RedisDB, error) {
+// NewRedisDB connects to Redis, retrying with backoff while it is not reachable yet
+func NewRedisDB(cfg config.RedisConfig, retry config.ConnectRetryConfig) (*RedisDB, error) {
+	return connectWithRetry(context.Background(), "Redis", retry, func() (*RedisDB, error) {
+		return openRedis(cfg)
+	})
+}
+
+func openRedis(cfg config.RedisConfig) (*RedisDB, error) {
 	client := redis.NewClient(&redis.Options{
 		Addr:         cfg.Addr,
 		Password:     cfg.Password,
@@ -30,6 +37,7 @@ func NewRedisDB(cfg config.RedisConfig) (*RedisDB, error) {
 	defer cancel()
 
 	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
 		return nil, fmt.Errorf("failed to connect to redis: %w", err)
 	}
 
@@ -0,0 +1,48 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"vcs.technonext.com/carrybee/ride_engine/pkg/config"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/logger"
+)
+
+// maxConnectRetryDelay caps the wait between connection attempts
+const maxConnectRetryDelay = 30 * time.Second
+
+// connectWithRetry calls connect until it succeeds or cfg.Attempts attempts have failed
+// The wait before the second attempt is cfg.BaseDelay and doubles after every failure, up to maxConnectRetryDelay
+// The error of the last attempt is returned when every attempt fails
+func connectWithRetry[T any](ctx context.Context, name string, cfg config.ConnectRetryConfig, connect func() (T, error)) (T, error) {
+	attempts := cfg.Attempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	delay := cfg.BaseDelay
+
+	var conn T
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		logger.Info(ctx, fmt.Sprintf("Connecting to %s, attempt %d of %d", name, attempt, attempts))
+		conn, err = connect()
+		if err == nil {
+			return conn, nil
+		}
+		if attempt == attempts {
+			break
+		}
+
+		logger.Error(ctx, fmt.Sprintf("Failed to connect to %s on attempt %d of %d, retrying in %s: %v", name, attempt, attempts, delay, err))
+		select {
+		case <-ctx.Done():
+			return conn, ctx.Err()
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxConnectRetryDelay)
+	}
+
+	logger.Error(ctx, fmt.Sprintf("Giving up connecting to %s after %d attempts: %v", name, attempts, err))
+	return conn, err
+}
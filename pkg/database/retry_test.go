@@ -0,0 +1,70 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/config"
+)
+
+// fakeDialer fails until its succeedOn-th attempt
+type fakeDialer struct {
+	succeedOn int
+	attempts  int
+	dialedAt  []time.Time
+}
+
+func (d *fakeDialer) dial() (string, error) {
+	d.attempts++
+	d.dialedAt = append(d.dialedAt, time.Now())
+	if d.attempts < d.succeedOn {
+		return "", errors.New("connection refused")
+	}
+	return "connection", nil
+}
+
+func TestConnectWithRetry_SucceedsOnNthAttempt(t *testing.T) {
+	dialer := &fakeDialer{succeedOn: 3}
+
+	conn, err := connectWithRetry(context.Background(), "fake", config.ConnectRetryConfig{Attempts: 5, BaseDelay: 10 * time.Millisecond}, dialer.dial)
+
+	require.NoError(t, err)
+	assert.Equal(t, "connection", conn)
+	assert.Equal(t, 3, dialer.attempts)
+	// The wait doubles after every failure
+	assert.GreaterOrEqual(t, dialer.dialedAt[1].Sub(dialer.dialedAt[0]), 10*time.Millisecond)
+	assert.GreaterOrEqual(t, dialer.dialedAt[2].Sub(dialer.dialedAt[1]), 20*time.Millisecond)
+}
+
+func TestConnectWithRetry_GivesUpAfterAttempts(t *testing.T) {
+	dialer := &fakeDialer{succeedOn: 10}
+
+	_, err := connectWithRetry(context.Background(), "fake", config.ConnectRetryConfig{Attempts: 3, BaseDelay: time.Millisecond}, dialer.dial)
+
+	assert.EqualError(t, err, "connection refused")
+	assert.Equal(t, 3, dialer.attempts)
+}
+
+func TestConnectWithRetry_AtLeastOneAttempt(t *testing.T) {
+	dialer := &fakeDialer{succeedOn: 1}
+
+	_, err := connectWithRetry(context.Background(), "fake", config.ConnectRetryConfig{}, dialer.dial)
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, dialer.attempts)
+}
+
+func TestConnectWithRetry_StopsWhenContextDone(t *testing.T) {
+	dialer := &fakeDialer{succeedOn: 10}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := connectWithRetry(ctx, "fake", config.ConnectRetryConfig{Attempts: 5, BaseDelay: time.Minute}, dialer.dial)
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 1, dialer.attempts)
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"github.com/getsentry/sentry-go"
-	"google.golang.org/grpc/metadata"
 	"os"
 	"runtime"
 	"strings"
@@ -54,6 +53,28 @@ func DebugWithFields(l interface{}, f Fields) {
 	}
 }
 
+// DebugWithContext logs a message at level Debug with the trace and user fields carried by ctx
+func DebugWithContext(ctx context.Context, args ...interface{}) {
+	if logger.Level < logrus.DebugLevel {
+		return
+	}
+
+	fields := logrus.Fields{
+		"service": os.Getenv("POD_CONTAINER"),
+		"file":    fileInfo(2),
+	}
+
+	if traceID := GetTraceID(ctx); traceID != "" {
+		fields[TraceId] = traceID
+	}
+
+	if userId := GetUserId(ctx); userId != "" {
+		fields[UserId] = userId
+	}
+
+	logger.WithFields(fields).Debug(args...)
+}
+
 // Println Info logs a message at level Info on the standard logger.
 func Println(args ...interface{}) {
 	Info(context.Background(), args...)
@@ -192,28 +213,42 @@ func fileInfo(skip int) string {
 	return fmt.Sprintf("%s:%d", file, line)
 }
 
+type contextKey string
+
+const (
+	traceIDKey  contextKey = TraceId
+	userIDKey   contextKey = UserId
+	userNameKey contextKey = UserName
+)
+
+// WithTraceID returns a copy of ctx carrying traceID, which is then added to every log written with it
+func WithTraceID(ctx context.Context, traceID string) context.Context {
+	return context.WithValue(ctx, traceIDKey, traceID)
+}
+
+// WithUserID returns a copy of ctx carrying userID for Info logs
+func WithUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, userIDKey, userID)
+}
+
+// WithUserName returns a copy of ctx carrying userName for Error logs
+func WithUserName(ctx context.Context, userName string) context.Context {
+	return context.WithValue(ctx, userNameKey, userName)
+}
+
 func GetTraceID(ctx context.Context) string {
-	traceId := metadata.ValueFromIncomingContext(ctx, TraceId)
-	if len(traceId) == 0 {
-		return ""
-	}
-	return traceId[0]
+	traceID, _ := ctx.Value(traceIDKey).(string)
+	return traceID
 }
 
 func GetUserId(ctx context.Context) string {
-	userId := metadata.ValueFromIncomingContext(ctx, UserId)
-	if len(userId) == 0 {
-		return ""
-	}
-	return userId[0]
+	userId, _ := ctx.Value(userIDKey).(string)
+	return userId
 }
 
 func GetUserName(ctx context.Context) string {
-	userName := metadata.ValueFromIncomingContext(ctx, UserName)
-	if len(userName) == 0 {
-		return ""
-	}
-	return userName[0]
+	userName, _ := ctx.Value(userNameKey).(string)
+	return userName
 }
 
 func AddContextFields(ctx context.Context, flds logrus.Fields) logrus.Fields {
@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/logger"
+)
+
+const namespace = "ride_engine"
+
+// onlineDriversCountTimeout bounds the database query made on every scrape
+const onlineDriversCountTimeout = 2 * time.Second
+
+// RideMetrics holds the counters and histograms for the ride lifecycle
+type RideMetrics struct {
+	RidesRequested prometheus.Counter
+	RidesAccepted  prometheus.Counter
+	RidesStarted   prometheus.Counter
+	RidesCompleted prometheus.Counter
+	RidesCancelled *prometheus.CounterVec
+	RidesAbandoned prometheus.Counter
+	AcceptLatency  prometheus.Histogram
+}
+
+// NewRideMetrics creates the ride lifecycle metrics and registers them with reg
+func NewRideMetrics(reg prometheus.Registerer) *RideMetrics {
+	factory := promauto.With(reg)
+	return &RideMetrics{
+		RidesRequested: factory.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rides_requested_total",
+			Help:      "Number of rides requested by customers.",
+		}),
+		RidesAccepted: factory.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rides_accepted_total",
+			Help:      "Number of rides accepted by drivers.",
+		}),
+		RidesStarted: factory.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rides_started_total",
+			Help:      "Number of rides started.",
+		}),
+		RidesCompleted: factory.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rides_completed_total",
+			Help:      "Number of rides completed.",
+		}),
+		RidesCancelled: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rides_cancelled_total",
+			Help:      "Number of rides cancelled, by who cancelled them.",
+		}, []string{"cancelled_by"}),
+		RidesAbandoned: factory.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rides_abandoned_total",
+			Help:      "Number of accepted rides released by their driver before pickup.",
+		}),
+		AcceptLatency: factory.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "ride_accept_latency_seconds",
+			Help:      "Time from a ride being requested to a driver accepting it.",
+			Buckets:   []float64{5, 15, 30, 60, 120, 300, 600},
+		}),
+	}
+}
+
+// ObserveAccepted counts an accepted ride and records how long it waited for a driver
+func (m *RideMetrics) ObserveAccepted(requestedAt, acceptedAt time.Time) {
+	m.RidesAccepted.Inc()
+	m.AcceptLatency.Observe(acceptedAt.Sub(requestedAt).Seconds())
+}
+
+// CacheMetrics counts lookups served from a Redis cache and lookups that missed it, by cache name
+type CacheMetrics struct {
+	Hits   *prometheus.CounterVec
+	Misses *prometheus.CounterVec
+}
+
+// NewCacheMetrics creates the cache hit and miss counters and registers them with reg
+func NewCacheMetrics(reg prometheus.Registerer) *CacheMetrics {
+	factory := promauto.With(reg)
+	return &CacheMetrics{
+		Hits: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "cache_hits_total",
+			Help:      "Number of lookups served from the Redis cache, by cache.",
+		}, []string{"cache"}),
+		Misses: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "cache_misses_total",
+			Help:      "Number of lookups not found in the Redis cache, by cache.",
+		}, []string{"cache"}),
+	}
+}
+
+// NewOnlineDriversGauge registers a gauge that reports count on every scrape
+// A failed count is reported as NaN rather than zero so it is not mistaken for no drivers online
+func NewOnlineDriversGauge(reg prometheus.Registerer, count func(ctx context.Context) (int64, error)) prometheus.GaugeFunc {
+	return promauto.With(reg).NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "online_drivers",
+		Help:      "Number of drivers currently online.",
+	}, func() float64 {
+		ctx, cancel := context.WithTimeout(context.Background(), onlineDriversCountTimeout)
+		defer cancel()
+
+		n, err := count(ctx)
+		if err != nil {
+			logger.Error(ctx, fmt.Sprintf("Failed to count online drivers: %v", err))
+			return math.NaN()
+		}
+		return float64(n)
+	})
+}
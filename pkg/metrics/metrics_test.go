@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRideMetrics_ObserveAccepted(t *testing.T) {
+	m := NewRideMetrics(prometheus.NewRegistry())
+	requestedAt := time.Now().Add(-45 * time.Second)
+
+	m.ObserveAccepted(requestedAt, requestedAt.Add(45*time.Second))
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.RidesAccepted))
+	assert.Equal(t, 1, testutil.CollectAndCount(m.AcceptLatency))
+}
+
+func TestNewOnlineDriversGauge(t *testing.T) {
+	count := int64(7)
+	gauge := NewOnlineDriversGauge(prometheus.NewRegistry(), func(ctx context.Context) (int64, error) {
+		return count, nil
+	})
+
+	assert.Equal(t, float64(7), testutil.ToFloat64(gauge))
+
+	count = 3
+	assert.Equal(t, float64(3), testutil.ToFloat64(gauge))
+}
+
+func TestNewOnlineDriversGauge_CountError(t *testing.T) {
+	gauge := NewOnlineDriversGauge(prometheus.NewRegistry(), func(ctx context.Context) (int64, error) {
+		return 0, errors.New("database error")
+	})
+
+	assert.True(t, math.IsNaN(testutil.ToFloat64(gauge)))
+}
+
+func TestNewCacheMetrics(t *testing.T) {
+	m := NewCacheMetrics(prometheus.NewRegistry())
+
+	m.Hits.WithLabelValues("driver_profile").Inc()
+	m.Misses.WithLabelValues("driver_profile").Inc()
+	m.Misses.WithLabelValues("driver_profile").Inc()
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.Hits.WithLabelValues("driver_profile")))
+	assert.Equal(t, float64(2), testutil.ToFloat64(m.Misses.WithLabelValues("driver_profile")))
+}
@@ -19,17 +19,18 @@ const (
 	UserIDKey   contextKey = "user_id"
 	UserRoleKey contextKey = "user_role"
 	DriverIdKey contextKey = "driver_id"
+	SessionKey  contextKey = "session_id"
 )
 
 type AuthMiddleware struct {
-	redis     *redis.Client
-	jwtSecret string
+	redis   *redis.Client
+	jwtKeys utils.JWTKeys
 }
 
-func NewAuthMiddleware(redisClient *redis.Client, jwtSecret string) *AuthMiddleware {
+func NewAuthMiddleware(redisClient *redis.Client, jwtKeys utils.JWTKeys) *AuthMiddleware {
 	return &AuthMiddleware{
-		redis:     redisClient,
-		jwtSecret: jwtSecret,
+		redis:   redisClient,
+		jwtKeys: jwtKeys,
 	}
 }
 
@@ -53,15 +54,15 @@ func (m *AuthMiddleware) Auth(next http.Handler) http.Handler {
 
 		token := parts[1]
 
-		claims, err := utils.ValidateJWT(token, m.jwtSecret)
+		claims, err := utils.ValidateJWT(token, m.jwtKeys)
 		if err != nil {
 			logger.Error(cctx, "Invalid token")
 			sendError(w, http.StatusUnauthorized, fmt.Sprintf("invalid token: %v", err))
 			return
 		}
 
-		key := fmt.Sprintf("jwt:%s:%d", claims.Role, claims.UserID)
-		storedToken, err := m.redis.Get(r.Context(), key).Result()
+		key := utils.JWTRedisKey(claims.Role, claims.UserID)
+		storedToken, err := m.redis.HGet(r.Context(), key, claims.SessionID).Result()
 		if err == redis.Nil {
 			logger.Error(cctx, "Token not found")
 			sendError(w, http.StatusUnauthorized, "token expired or logged out")
@@ -81,8 +82,8 @@ func (m *AuthMiddleware) Auth(next http.Handler) http.Handler {
 		ctx := context.WithValue(r.Context(), UserIDKey, claims.UserID)
 		ctx = context.WithValue(ctx, UserRoleKey, claims.Role)
 		ctx = context.WithValue(ctx, DriverIdKey, claims.UserID)
+		ctx = context.WithValue(ctx, SessionKey, claims.SessionID)
 
-		fmt.Println("driver id from JWT:", claims.UserID)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
@@ -105,16 +106,16 @@ func (m *AuthMiddleware) AuthEcho(next echo.HandlerFunc) echo.HandlerFunc {
 
 		token := parts[1]
 
-		claims, err := utils.ValidateJWT(token, m.jwtSecret)
+		claims, err := utils.ValidateJWT(token, m.jwtKeys)
 		if err != nil {
 			logger.Error(cctx, "Invalid token")
 			return c.JSON(http.StatusUnauthorized, map[string]string{"error": fmt.Sprintf("invalid token: %v", err)})
 		}
 
-		key := fmt.Sprintf("jwt:%s:%d", claims.Role, claims.UserID)
-		storedToken, err := m.redis.Get(c.Request().Context(), key).Result()
+		key := utils.JWTRedisKey(claims.Role, claims.UserID)
+		storedToken, err := m.redis.HGet(c.Request().Context(), key, claims.SessionID).Result()
 		if err == redis.Nil {
-			logger.Error(cctx, fmt.Sprintf("Token not found in Redis for key: %s", key))
+			logger.Error(cctx, fmt.Sprintf("Session %s not found in Redis for key: %s", claims.SessionID, key))
 			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "token expired or logged out"})
 		}
 		if err != nil {
@@ -122,7 +123,7 @@ func (m *AuthMiddleware) AuthEcho(next echo.HandlerFunc) echo.HandlerFunc {
 			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to verify token"})
 		}
 		if storedToken != token {
-			logger.Error(cctx, fmt.Sprintf("Token mismatch for user %d. Stored: %s..., Received: %s...", claims.UserID, storedToken[:20], token[:20]))
+			logger.Error(cctx, fmt.Sprintf("Token mismatch for %s %d", claims.Role, claims.UserID))
 			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "token mismatch"})
 		}
 
@@ -130,8 +131,8 @@ func (m *AuthMiddleware) AuthEcho(next echo.HandlerFunc) echo.HandlerFunc {
 		c.Set("user_id", claims.UserID)
 		c.Set("user_role", claims.Role)
 		c.Set("driver_id", claims.UserID)
+		c.Set("session_id", claims.SessionID)
 
-		fmt.Println("user id from JWT:", claims.UserID, " role: ", claims.Role)
 		return next(c)
 	}
 }
@@ -159,6 +160,28 @@ func (m *AuthMiddleware) RequireRole(role string) func(http.Handler) http.Handle
 	}
 }
 
+// RequireRoleEcho middleware to check user role for Echo framework routes
+// Must run after AuthEcho, which sets the role in the Echo context
+func (m *AuthMiddleware) RequireRoleEcho(role string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			cctx := c.Request().Context()
+			userRole, ok := GetUserRoleFromEcho(c)
+			if !ok {
+				logger.Error(cctx, "User role not found")
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			}
+
+			if userRole != role {
+				logger.Error(cctx, fmt.Sprintf("User role mismatch: want %s, got %s", role, userRole))
+				return c.JSON(http.StatusForbidden, map[string]string{"error": "insufficient permissions"})
+			}
+
+			return next(c)
+		}
+	}
+}
+
 // GetUserID extracts user ID from context
 func GetUserID(ctx context.Context) (int64, bool) {
 	userID, ok := ctx.Value(UserIDKey).(int64)
@@ -171,6 +194,12 @@ func GetUserRole(ctx context.Context) (string, bool) {
 	return role, ok
 }
 
+// GetSessionID extracts the login session ID of the token from context
+func GetSessionID(ctx context.Context) (string, bool) {
+	sessionID, ok := ctx.Value(SessionKey).(string)
+	return sessionID, ok
+}
+
 // Helper function to send JSON error response
 func sendError(w http.ResponseWriter, status int, message string) {
 	w.Header().Set("Content-Type", "application/json")
@@ -198,3 +227,8 @@ func GetDriverIDFromEcho(c echo.Context) (int64, bool) {
 	driverID, ok := c.Get("driver_id").(int64)
 	return driverID, ok
 }
+
+func GetSessionIDFromEcho(c echo.Context) (string, bool) {
+	sessionID, ok := c.Get("session_id").(string)
+	return sessionID, ok
+}
@@ -0,0 +1,144 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/testutil"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/utils"
+)
+
+var testJWTKeys = utils.JWTKeys{Secret: "test-secret"}
+
+func okHandler(c echo.Context) error {
+	return c.String(http.StatusOK, "ok")
+}
+
+// login stores a token for a new session of the user like the login endpoints do
+func login(t *testing.T, redisClient *redis.Client, userID int64, role string, expiration int) string {
+	t.Helper()
+
+	sessionID := utils.GenerateID()
+	token, err := utils.GenerateJWT(userID, role, sessionID, testJWTKeys, expiration)
+	require.NoError(t, err)
+	require.NoError(t, redisClient.HSet(context.Background(), utils.JWTRedisKey(role, userID), sessionID, token).Err())
+	return token
+}
+
+func serveWithRole(t *testing.T, m *AuthMiddleware, requiredRole, userRole string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	if userRole != "" {
+		c.Set("user_role", userRole)
+	}
+
+	require.NoError(t, m.RequireRoleEcho(requiredRole)(okHandler)(c))
+	return rec
+}
+
+func TestRequireRoleEcho_WrongRoleForbidden(t *testing.T) {
+	m := NewAuthMiddleware(nil, testJWTKeys)
+
+	rec := serveWithRole(t, m, "driver", "customer")
+
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.JSONEq(t, `{"error":"insufficient permissions"}`, rec.Body.String())
+}
+
+func TestRequireRoleEcho_RightRolePassesThrough(t *testing.T) {
+	m := NewAuthMiddleware(nil, testJWTKeys)
+
+	rec := serveWithRole(t, m, "driver", "driver")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "ok", rec.Body.String())
+}
+
+func TestRequireRoleEcho_MissingRoleUnauthorized(t *testing.T) {
+	m := NewAuthMiddleware(nil, testJWTKeys)
+
+	rec := serveWithRole(t, m, "customer", "")
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestRequireRoleEcho_AfterAuthEcho(t *testing.T) {
+	redisClient, _ := testutil.NewFakeRedis()
+	m := NewAuthMiddleware(redisClient, testJWTKeys)
+
+	e := echo.New()
+	e.GET("/driver-only", okHandler, m.AuthEcho, m.RequireRoleEcho("driver"))
+
+	tests := []struct {
+		name     string
+		token    string
+		expected int
+	}{
+		{name: "driver", token: login(t, redisClient, 1, "driver", 1), expected: http.StatusOK},
+		{name: "customer", token: login(t, redisClient, 2, "customer", 1), expected: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/driver-only", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			rec := httptest.NewRecorder()
+
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expected, rec.Code)
+		})
+	}
+}
+
+func TestAuthEcho_DoesNotWriteToStdout(t *testing.T) {
+	redisClient, _ := testutil.NewFakeRedis()
+	m := NewAuthMiddleware(redisClient, testJWTKeys)
+
+	token := login(t, redisClient, 7, "driver", 1)
+
+	e := echo.New()
+	e.GET("/", okHandler, m.AuthEcho)
+
+	serve := func(token string) int {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	claims, err := utils.ValidateJWT(token, testJWTKeys)
+	require.NoError(t, err)
+	otherToken, err := utils.GenerateJWT(7, "driver", claims.SessionID, testJWTKeys, 2)
+	require.NoError(t, err)
+
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	stdout := os.Stdout
+	os.Stdout = w
+
+	okCode := serve(token)
+	mismatchCode := serve(otherToken)
+
+	os.Stdout = stdout
+	require.NoError(t, w.Close())
+	out, err := io.ReadAll(r)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, okCode)
+	assert.Equal(t, http.StatusUnauthorized, mismatchCode)
+	assert.Empty(t, string(out))
+}
@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// BodyLimitEcho rejects requests declaring a body larger than maxBytes with 413
+// Bodies sent without a Content-Length are cut off at maxBytes, so reading past it fails instead of buffering it all
+func BodyLimitEcho(maxBytes int64) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			if req.ContentLength > maxBytes {
+				return c.JSON(http.StatusRequestEntityTooLarge, map[string]string{"error": fmt.Sprintf("request body must not be larger than %d bytes", maxBytes)})
+			}
+			if req.Body != nil {
+				req.Body = http.MaxBytesReader(c.Response(), req.Body, maxBytes)
+			}
+			return next(c)
+		}
+	}
+}
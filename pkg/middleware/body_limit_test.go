@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serveWithBodyLimit sends body through BodyLimitEcho to a handler that reads it all and echoes how much it got
+func serveWithBodyLimit(t *testing.T, maxBytes int64, body io.Reader, contentLength int64) *httptest.ResponseRecorder {
+	t.Helper()
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/customers/login", body)
+	req.ContentLength = contentLength
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	handler := BodyLimitEcho(maxBytes)(func(c echo.Context) error {
+		read, err := io.ReadAll(c.Request().Body)
+		if err != nil {
+			return c.String(http.StatusBadRequest, err.Error())
+		}
+		return c.String(http.StatusOK, string(read))
+	})
+	require.NoError(t, handler(c))
+	return rec
+}
+
+func TestBodyLimitEcho_AllowsBodyWithinLimit(t *testing.T) {
+	body := `{"email":"rahim@example.com"}`
+
+	rec := serveWithBodyLimit(t, 64, strings.NewReader(body), int64(len(body)))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, body, rec.Body.String())
+}
+
+func TestBodyLimitEcho_RejectsDeclaredOversizedBody(t *testing.T) {
+	body := strings.Repeat("a", 65)
+
+	rec := serveWithBodyLimit(t, 64, strings.NewReader(body), int64(len(body)))
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	assert.JSONEq(t, `{"error":"request body must not be larger than 64 bytes"}`, rec.Body.String())
+}
+
+func TestBodyLimitEcho_CutsOffBodyWithoutContentLength(t *testing.T) {
+	// A chunked body has no Content-Length, it fails once more than the limit is read
+	rec := serveWithBodyLimit(t, 64, strings.NewReader(strings.Repeat("a", 1000)), -1)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "request body too large")
+}
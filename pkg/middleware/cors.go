@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/config"
+)
+
+// CORSEcho answers cross origin requests from the configured origins, "*" allows any origin
+// Requests from any other origin are rejected with 403 instead of reaching the handler without CORS headers
+func CORSEcho(cfg config.CORSConfig) echo.MiddlewareFunc {
+	allowed := func(origin string) bool {
+		return slices.Contains(cfg.AllowedOrigins, "*") || slices.Contains(cfg.AllowedOrigins, origin)
+	}
+
+	cors := middleware.CORSWithConfig(middleware.CORSConfig{
+		AllowOriginFunc: func(origin string) (bool, error) { return allowed(origin), nil },
+		AllowMethods:    cfg.AllowedMethods,
+		AllowHeaders:    cfg.AllowedHeaders,
+	})
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		withCORS := cors(next)
+		return func(c echo.Context) error {
+			// Same origin and non browser requests carry no Origin
+			origin := c.Request().Header.Get(echo.HeaderOrigin)
+			if origin != "" && !allowed(origin) {
+				return c.JSON(http.StatusForbidden, map[string]string{"error": "origin not allowed"})
+			}
+			return withCORS(c)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/config"
+)
+
+func serveCORS(cfg config.CORSConfig, method, origin string) *httptest.ResponseRecorder {
+	e := echo.New()
+	e.Use(CORSEcho(cfg))
+	e.POST("/", okHandler)
+
+	req := httptest.NewRequest(method, "/", nil)
+	if origin != "" {
+		req.Header.Set(echo.HeaderOrigin, origin)
+	}
+	if method == http.MethodOptions {
+		req.Header.Set(echo.HeaderAccessControlRequestMethod, http.MethodPost)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+var testCORSConfig = config.CORSConfig{
+	AllowedOrigins: []string{"https://app.example.com"},
+	AllowedMethods: []string{http.MethodGet, http.MethodPost},
+	AllowedHeaders: []string{echo.HeaderContentType, echo.HeaderAuthorization},
+}
+
+func TestCORSEcho_AllowedOriginPasses(t *testing.T) {
+	rec := serveCORS(testCORSConfig, http.MethodPost, "https://app.example.com")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "https://app.example.com", rec.Header().Get(echo.HeaderAccessControlAllowOrigin))
+}
+
+func TestCORSEcho_AllowedOriginPreflight(t *testing.T) {
+	rec := serveCORS(testCORSConfig, http.MethodOptions, "https://app.example.com")
+
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, "https://app.example.com", rec.Header().Get(echo.HeaderAccessControlAllowOrigin))
+	assert.Equal(t, "GET,POST", rec.Header().Get(echo.HeaderAccessControlAllowMethods))
+	assert.Equal(t, "Content-Type,Authorization", rec.Header().Get(echo.HeaderAccessControlAllowHeaders))
+}
+
+func TestCORSEcho_DisallowedOriginRejected(t *testing.T) {
+	for _, method := range []string{http.MethodPost, http.MethodOptions} {
+		rec := serveCORS(testCORSConfig, method, "https://evil.example.com")
+
+		assert.Equal(t, http.StatusForbidden, rec.Code, method)
+		assert.Empty(t, rec.Header().Get(echo.HeaderAccessControlAllowOrigin), method)
+		assert.JSONEq(t, `{"error":"origin not allowed"}`, rec.Body.String(), method)
+	}
+}
+
+func TestCORSEcho_RequestWithoutOriginPasses(t *testing.T) {
+	rec := serveCORS(testCORSConfig, http.MethodPost, "")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get(echo.HeaderAccessControlAllowOrigin))
+}
+
+func TestCORSEcho_WildcardAllowsAnyOrigin(t *testing.T) {
+	cfg := testCORSConfig
+	cfg.AllowedOrigins = []string{"*"}
+
+	rec := serveCORS(cfg, http.MethodPost, "https://any.example.com")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "https://any.example.com", rec.Header().Get(echo.HeaderAccessControlAllowOrigin))
+}
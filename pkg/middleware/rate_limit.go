@@ -0,0 +1,142 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/redis/go-redis/v9"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/config"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/logger"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/utils"
+)
+
+// rateLimitTxAttempts is how often a bucket update is retried when another request changed the bucket first
+const rateLimitTxAttempts = 3
+
+// RateLimiter limits requests per user or IP with token buckets kept in Redis, so the limit holds across instances
+type RateLimiter struct {
+	redis *redis.Client
+	now   func() time.Time
+}
+
+func NewRateLimiter(redisClient *redis.Client) *RateLimiter {
+	return &RateLimiter{redis: redisClient, now: time.Now}
+}
+
+// Limit allows each user limit.Requests requests to the route at once, refilled evenly over limit.Window
+// Authenticated requests are counted per user, so it must run after AuthEcho, and anonymous ones per client IP
+// Requests over the limit get 429 with Retry-After, requests are let through when Redis cannot be reached
+func (l *RateLimiter) Limit(route string, limit config.RateLimit) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		if limit.Requests <= 0 || limit.Window <= 0 {
+			return next
+		}
+
+		return func(c echo.Context) error {
+			ctx := c.Request().Context()
+			key := utils.RateLimitKey(route, rateLimitSubject(c))
+
+			allowed, retryAfter, err := l.take(ctx, key, limit)
+			if err != nil {
+				logger.Error(ctx, fmt.Sprintf("Failed to check rate limit %s: %v", key, err))
+				return next(c)
+			}
+			if !allowed {
+				seconds := int(math.Ceil(retryAfter.Seconds()))
+				c.Response().Header().Set("Retry-After", strconv.Itoa(seconds))
+				return c.JSON(http.StatusTooManyRequests, map[string]string{"error": fmt.Sprintf("too many requests, try again in %d seconds", seconds)})
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// rateLimitSubject identifies who a request is counted against, e.g. driver:42 or ip:10.0.0.1
+func rateLimitSubject(c echo.Context) string {
+	if userID, ok := GetUserIDFromEcho(c); ok {
+		role, _ := GetUserRoleFromEcho(c)
+		return fmt.Sprintf("%s:%d", role, userID)
+	}
+	return "ip:" + c.RealIP()
+}
+
+// take removes a token from the bucket at key, refilling it for the time since it was last used
+// When the bucket is empty it returns how long until the next token
+func (l *RateLimiter) take(ctx context.Context, key string, limit config.RateLimit) (bool, time.Duration, error) {
+	capacity := float64(limit.Requests)
+	perToken := limit.Window / time.Duration(limit.Requests)
+
+	var allowed bool
+	var retryAfter time.Duration
+	update := func(tx *redis.Tx) error {
+		now := l.now()
+		tokens := capacity
+
+		state, err := tx.Get(ctx, key).Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return err
+		}
+		if err == nil {
+			remaining, updatedAt, ok := parseBucket(state)
+			if ok {
+				refilled := float64(now.Sub(updatedAt)) / float64(perToken)
+				tokens = math.Min(capacity, remaining+math.Max(0, refilled))
+			}
+		}
+
+		if tokens < 1 {
+			allowed = false
+			retryAfter = time.Duration((1 - tokens) * float64(perToken))
+			return nil
+		}
+
+		allowed = true
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			// An unused bucket is full again after the window, so it can expire then
+			pipe.Set(ctx, key, formatBucket(tokens-1, now), limit.Window)
+			return nil
+		})
+		return err
+	}
+
+	var err error
+	for attempt := 0; attempt < rateLimitTxAttempts; attempt++ {
+		err = l.redis.Watch(ctx, update, key)
+		if !errors.Is(err, redis.TxFailedErr) {
+			break
+		}
+	}
+	if err != nil {
+		return false, 0, err
+	}
+	return allowed, retryAfter, nil
+}
+
+// formatBucket encodes the tokens left in a bucket and when it was last updated, e.g. 4.5:1700000000000000
+func formatBucket(tokens float64, updatedAt time.Time) string {
+	return strconv.FormatFloat(tokens, 'f', -1, 64) + ":" + strconv.FormatInt(updatedAt.UnixMicro(), 10)
+}
+
+func parseBucket(state string) (float64, time.Time, bool) {
+	tokensStr, updatedAtStr, found := strings.Cut(state, ":")
+	if !found {
+		return 0, time.Time{}, false
+	}
+	tokens, err := strconv.ParseFloat(tokensStr, 64)
+	if err != nil {
+		return 0, time.Time{}, false
+	}
+	updatedAt, err := strconv.ParseInt(updatedAtStr, 10, 64)
+	if err != nil {
+		return 0, time.Time{}, false
+	}
+	return tokens, time.UnixMicro(updatedAt), true
+}
@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/config"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/testutil"
+)
+
+// newTestRateLimiter returns a limiter on fake Redis whose clock is moved by advancing the returned time
+func newTestRateLimiter() (*RateLimiter, *time.Time) {
+	redisClient, _ := testutil.NewFakeRedis()
+	now := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiter(redisClient)
+	limiter.now = func() time.Time { return now }
+	return limiter, &now
+}
+
+// serveLimited sends one request from ip through the limiter, as userID when it is not zero
+func serveLimited(limiter *RateLimiter, limit config.RateLimit, ip string, userID int64) *httptest.ResponseRecorder {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.RemoteAddr = ip + ":52000"
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	if userID != 0 {
+		c.Set("user_id", userID)
+		c.Set("user_role", "driver")
+	}
+
+	_ = limiter.Limit("test", limit)(okHandler)(c)
+	return rec
+}
+
+func TestRateLimiter_RejectsRequestOverLimitUntilWindowPasses(t *testing.T) {
+	limiter, now := newTestRateLimiter()
+	limit := config.RateLimit{Requests: 3, Window: time.Minute}
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, serveLimited(limiter, limit, "10.0.0.1", 0).Code, "request %d", i+1)
+	}
+
+	rec := serveLimited(limiter, limit, "10.0.0.1", 0)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	// One token comes back every 20 seconds
+	assert.Equal(t, "20", rec.Header().Get("Retry-After"))
+	assert.JSONEq(t, `{"error":"too many requests, try again in 20 seconds"}`, rec.Body.String())
+
+	*now = now.Add(time.Minute)
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, serveLimited(limiter, limit, "10.0.0.1", 0).Code, "request %d after the window", i+1)
+	}
+	assert.Equal(t, http.StatusTooManyRequests, serveLimited(limiter, limit, "10.0.0.1", 0).Code)
+}
+
+func TestRateLimiter_RefillsOneTokenAtATime(t *testing.T) {
+	limiter, now := newTestRateLimiter()
+	limit := config.RateLimit{Requests: 2, Window: time.Minute}
+
+	serveLimited(limiter, limit, "10.0.0.1", 0)
+	serveLimited(limiter, limit, "10.0.0.1", 0)
+
+	*now = now.Add(20 * time.Second)
+	rec := serveLimited(limiter, limit, "10.0.0.1", 0)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "10", rec.Header().Get("Retry-After"))
+
+	*now = now.Add(10 * time.Second)
+	assert.Equal(t, http.StatusOK, serveLimited(limiter, limit, "10.0.0.1", 0).Code)
+	assert.Equal(t, http.StatusTooManyRequests, serveLimited(limiter, limit, "10.0.0.1", 0).Code)
+}
+
+func TestRateLimiter_CountsUsersAndIPsSeparately(t *testing.T) {
+	limiter, _ := newTestRateLimiter()
+	limit := config.RateLimit{Requests: 1, Window: time.Minute}
+
+	assert.Equal(t, http.StatusOK, serveLimited(limiter, limit, "10.0.0.1", 0).Code)
+	assert.Equal(t, http.StatusTooManyRequests, serveLimited(limiter, limit, "10.0.0.1", 0).Code)
+	assert.Equal(t, http.StatusOK, serveLimited(limiter, limit, "10.0.0.2", 0).Code)
+
+	// Authenticated users have their own budget wherever they connect from
+	assert.Equal(t, http.StatusOK, serveLimited(limiter, limit, "10.0.0.1", 42).Code)
+	assert.Equal(t, http.StatusTooManyRequests, serveLimited(limiter, limit, "10.0.0.2", 42).Code)
+	assert.Equal(t, http.StatusOK, serveLimited(limiter, limit, "10.0.0.1", 43).Code)
+}
+
+func TestRateLimiter_ZeroRequestsDisablesLimit(t *testing.T) {
+	limiter, _ := newTestRateLimiter()
+
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, http.StatusOK, serveLimited(limiter, config.RateLimit{}, "10.0.0.1", 0).Code)
+	}
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/labstack/echo/v4"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/logger"
+)
+
+// maxRequestIDLength caps client supplied request IDs so they cannot bloat the logs
+const maxRequestIDLength = 128
+
+// RequestIDEcho reuses the X-Request-ID header sent by the client or generates a new ID
+// The ID is stored as the trace ID of the request context, so logger.Info and logger.Error include it,
+// and echoed in the X-Request-ID response header
+func RequestIDEcho(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := c.Request()
+
+		requestID := req.Header.Get(echo.HeaderXRequestID)
+		if requestID == "" || len(requestID) > maxRequestIDLength {
+			requestID = generateRequestID()
+		}
+
+		c.SetRequest(req.WithContext(logger.WithTraceID(req.Context(), requestID)))
+		c.Response().Header().Set(echo.HeaderXRequestID, requestID)
+
+		return next(c)
+	}
+}
+
+// GetRequestIDFromEcho returns the request ID assigned by RequestIDEcho
+func GetRequestIDFromEcho(c echo.Context) string {
+	return logger.GetTraceID(c.Request().Context())
+}
+
+func generateRequestID() string {
+	b := make([]byte, 16)
+	// crypto/rand.Read never returns an error
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"vcs.technonext.com/carrybee/ride_engine/pkg/logger"
+)
+
+// captureLogs sends the logger's output to a buffer as JSON for the duration of the test
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+
+	l := logger.DefaultLogger()
+	out, formatter := l.Out, l.Formatter
+	t.Cleanup(func() {
+		l.SetOutput(out)
+		l.SetFormatter(formatter)
+	})
+
+	var buf bytes.Buffer
+	l.SetOutput(&buf)
+	l.SetFormatter(&logrus.JSONFormatter{})
+	return &buf
+}
+
+func logEntries(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+
+	var entries []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &entry))
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func serveWithRequestID(requestID string) *httptest.ResponseRecorder {
+	e := echo.New()
+	e.Use(RequestIDEcho)
+	e.GET("/", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		logger.Info(ctx, "handling request")
+		logger.Error(ctx, "request failed")
+		return c.String(http.StatusOK, GetRequestIDFromEcho(c))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if requestID != "" {
+		req.Header.Set(echo.HeaderXRequestID, requestID)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRequestIDEcho_GeneratesTraceID(t *testing.T) {
+	buf := captureLogs(t)
+
+	rec := serveWithRequestID("")
+
+	requestID := rec.Header().Get(echo.HeaderXRequestID)
+	require.Len(t, requestID, 32)
+	assert.Equal(t, requestID, rec.Body.String())
+
+	entries := logEntries(t, buf)
+	require.Len(t, entries, 2)
+	for _, entry := range entries {
+		assert.Equal(t, requestID, entry[logger.TraceId], entry["msg"])
+	}
+}
+
+func TestRequestIDEcho_ReusesClientRequestID(t *testing.T) {
+	buf := captureLogs(t)
+
+	rec := serveWithRequestID("client-request-1")
+
+	assert.Equal(t, "client-request-1", rec.Header().Get(echo.HeaderXRequestID))
+	for _, entry := range logEntries(t, buf) {
+		assert.Equal(t, "client-request-1", entry[logger.TraceId])
+	}
+}
+
+func TestRequestIDEcho_ReplacesOversizedRequestID(t *testing.T) {
+	captureLogs(t)
+
+	rec := serveWithRequestID(strings.Repeat("a", maxRequestIDLength+1))
+
+	assert.Len(t, rec.Header().Get(echo.HeaderXRequestID), 32)
+}
+
+func TestRequestIDEcho_UniquePerRequest(t *testing.T) {
+	captureLogs(t)
+
+	first := serveWithRequestID("").Header().Get(echo.HeaderXRequestID)
+	second := serveWithRequestID("").Header().Get(echo.HeaderXRequestID)
+
+	assert.NotEqual(t, first, second)
+}
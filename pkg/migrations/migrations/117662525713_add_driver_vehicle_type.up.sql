@@ -0,0 +1 @@
+ALTER TABLE drivers ADD COLUMN vehicle_type VARCHAR(20) NOT NULL DEFAULT 'economy';
@@ -0,0 +1,9 @@
+CREATE TABLE saved_locations (
+     id serial primary key,
+     customer_id INTEGER NOT NULL REFERENCES customers(id) ON DELETE CASCADE,
+     label VARCHAR(50) NOT NULL,
+     lat DOUBLE PRECISION NOT NULL,
+     lng DOUBLE PRECISION NOT NULL,
+     created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+     UNIQUE (customer_id, label)
+);
@@ -0,0 +1,22 @@
+CREATE TABLE promo_codes (
+     id serial primary key,
+     code VARCHAR(32) NOT NULL UNIQUE,
+     discount_type VARCHAR(20) NOT NULL,
+     discount_value DOUBLE PRECISION NOT NULL,
+     max_uses_per_customer INTEGER NOT NULL DEFAULT 1,
+     active BOOLEAN NOT NULL DEFAULT TRUE,
+     expires_at TIMESTAMP,
+     created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE promo_code_redemptions (
+     id serial primary key,
+     promo_code_id INTEGER NOT NULL REFERENCES promo_codes(id) ON DELETE CASCADE,
+     customer_id INTEGER NOT NULL REFERENCES customers(id) ON DELETE CASCADE,
+     ride_id BIGINT NOT NULL,
+     discount DOUBLE PRECISION NOT NULL,
+     redeemed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+     UNIQUE (promo_code_id, ride_id)
+);
+
+CREATE INDEX idx_promo_code_redemptions_customer ON promo_code_redemptions (promo_code_id, customer_id);
@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS idx_customers_email_active;
+DROP INDEX IF EXISTS idx_customers_phone_active;
+
+-- Fails while a deleted customer's email or phone was registered again
+ALTER TABLE customers ADD CONSTRAINT customers_email_key UNIQUE (email);
+ALTER TABLE customers ADD CONSTRAINT customers_phone_key UNIQUE (phone);
+
+DROP INDEX IF EXISTS idx_customers_deleted_at;
+
+ALTER TABLE customers DROP COLUMN IF EXISTS deleted_at;
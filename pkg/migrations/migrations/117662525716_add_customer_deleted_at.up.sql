@@ -0,0 +1,10 @@
+ALTER TABLE customers ADD COLUMN deleted_at TIMESTAMP;
+
+CREATE INDEX idx_customers_deleted_at ON customers (deleted_at);
+
+-- A deleted customer's email and phone can be registered again, so they are only unique among customers that are not deleted
+ALTER TABLE customers DROP CONSTRAINT customers_email_key;
+ALTER TABLE customers DROP CONSTRAINT customers_phone_key;
+
+CREATE UNIQUE INDEX idx_customers_email_active ON customers (email) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX idx_customers_phone_active ON customers (phone) WHERE deleted_at IS NULL;
@@ -0,0 +1,10 @@
+CREATE TABLE device_tokens (
+     id serial primary key,
+     user_role VARCHAR(20) NOT NULL,
+     user_id INTEGER NOT NULL,
+     token VARCHAR(512) NOT NULL UNIQUE,
+     created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+     updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_device_tokens_user ON device_tokens (user_role, user_id);
@@ -0,0 +1,9 @@
+CREATE TABLE driver_sessions (
+     id serial primary key,
+     driver_id INTEGER NOT NULL REFERENCES drivers(id) ON DELETE CASCADE,
+     started_at TIMESTAMP NOT NULL,
+     ended_at TIMESTAMP NOT NULL,
+     created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_driver_sessions_driver_started ON driver_sessions (driver_id, started_at);
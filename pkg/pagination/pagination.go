@@ -0,0 +1,87 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidCursor is returned for a token that was not made by Encode, or was made for a list with another sort
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor marks the last item seen of a list sorted newest first, items with the same sort value are sorted by ID descending
+// Unlike an offset it keeps its place when items are added to the front of the list between pages
+type Cursor struct {
+	Sort  string    `json:"s"`  // the sort field of the list, a cursor is only accepted by the list it came from
+	Value time.Time `json:"v"`  // sort value of the last item seen
+	ID    int64     `json:"id"` // ID of the last item seen
+}
+
+// Encode returns the cursor as an opaque URL safe token, base64 of its JSON
+func Encode(c Cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Decode parses a token made by Encode for a list sorted by sort, an empty token is the first page and returns nil
+func Decode(token, sort string) (*Cursor, error) {
+	if token == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c Cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.Sort != sort {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// After reports whether an item with the sort value and ID comes after the cursor, i.e. on a later page
+func (c *Cursor) After(value time.Time, id int64) bool {
+	return value.Before(c.Value) || (value.Equal(c.Value) && id < c.ID)
+}
+
+// Next returns the token of the page after items, key gives an item's sort value and ID
+// A page shorter than limit is the last one and gets no token, nor does an unlimited page
+func Next[T any](items []T, limit int, sort string, key func(T) (time.Time, int64)) string {
+	if limit <= 0 || len(items) < limit {
+		return ""
+	}
+	value, id := key(items[len(items)-1])
+	return Encode(Cursor{Sort: sort, Value: value, ID: id})
+}
+
+// ApplyGorm sorts query newest first by sortColumn then idColumn and, when c is set, starts it after c
+func ApplyGorm(query *gorm.DB, c *Cursor, sortColumn, idColumn string) *gorm.DB {
+	query = query.Order(fmt.Sprintf("%s DESC, %s DESC", sortColumn, idColumn))
+	if c == nil {
+		return query
+	}
+	return query.Where(fmt.Sprintf("(%s < ? OR (%s = ? AND %s < ?))", sortColumn, sortColumn, idColumn), c.Value, c.Value, c.ID)
+}
+
+// MongoFilter narrows filter to the documents after c, a nil c returns filter as it is
+func MongoFilter(filter bson.M, c *Cursor, sortField, idField string) bson.M {
+	if c == nil {
+		return filter
+	}
+	after := bson.M{"$or": bson.A{
+		bson.M{sortField: bson.M{"$lt": c.Value}},
+		bson.M{sortField: c.Value, idField: bson.M{"$lt": c.ID}},
+	}}
+	return bson.M{"$and": bson.A{filter, after}}
+}
+
+// MongoSort sorts newest first by sortField then idField, the order MongoFilter pages through
+func MongoSort(sortField, idField string) bson.D {
+	return bson.D{{Key: sortField, Value: -1}, {Key: idField, Value: -1}}
+}
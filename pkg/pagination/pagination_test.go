@@ -0,0 +1,136 @@
+package pagination
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+type item struct {
+	ID        int64
+	CreatedAt time.Time
+}
+
+func itemKey(i item) (time.Time, int64) {
+	return i.CreatedAt, i.ID
+}
+
+// page returns up to limit of items, which are sorted newest first, starting after token
+func page(t *testing.T, items []item, token string, limit int) ([]item, string) {
+	c, err := Decode(token, "created_at")
+	require.NoError(t, err)
+
+	var result []item
+	for _, i := range items {
+		if c != nil && !c.After(i.CreatedAt, i.ID) {
+			continue
+		}
+		if len(result) == limit {
+			break
+		}
+		result = append(result, i)
+	}
+	return result, Next(result, limit, "created_at", itemKey)
+}
+
+func TestEncodeDecode_RoundTrip(t *testing.T) {
+	c := Cursor{Sort: "created_at", Value: time.Date(2025, 3, 10, 9, 30, 15, 123456789, time.UTC), ID: 42}
+
+	decoded, err := Decode(Encode(c), "created_at")
+
+	require.NoError(t, err)
+	assert.Equal(t, c.Sort, decoded.Sort)
+	assert.True(t, c.Value.Equal(decoded.Value))
+	assert.Equal(t, c.ID, decoded.ID)
+}
+
+func TestDecode_EmptyTokenIsFirstPage(t *testing.T) {
+	c, err := Decode("", "created_at")
+
+	require.NoError(t, err)
+	assert.Nil(t, c)
+}
+
+func TestDecode_InvalidToken(t *testing.T) {
+	for _, token := range []string{"not base64!", "bm90IGpzb24", Encode(Cursor{Sort: "requested_at", ID: 1})} {
+		_, err := Decode(token, "created_at")
+
+		assert.ErrorIs(t, err, ErrInvalidCursor, token)
+	}
+}
+
+func TestNext_NoTokenOnLastPage(t *testing.T) {
+	items := []item{{ID: 2}, {ID: 1}}
+
+	assert.Empty(t, Next(items, 3, "created_at", itemKey))
+	assert.Empty(t, Next(items, 0, "created_at", itemKey))
+	assert.NotEmpty(t, Next(items, 2, "created_at", itemKey))
+}
+
+func TestCursor_StableOrderingAcrossPages(t *testing.T) {
+	base := time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)
+	// Newest first, with runs of items sharing a timestamp that straddle the page boundaries
+	items := []item{
+		{ID: 9, CreatedAt: base.Add(3 * time.Minute)},
+		{ID: 8, CreatedAt: base.Add(2 * time.Minute)},
+		{ID: 7, CreatedAt: base.Add(2 * time.Minute)},
+		{ID: 6, CreatedAt: base.Add(2 * time.Minute)},
+		{ID: 5, CreatedAt: base.Add(2 * time.Minute)},
+		{ID: 4, CreatedAt: base.Add(time.Minute)},
+		{ID: 3, CreatedAt: base.Add(time.Minute)},
+		{ID: 2, CreatedAt: base},
+	}
+
+	var seen []int64
+	token := ""
+	for pages := 0; ; pages++ {
+		require.Less(t, pages, len(items), "Paging did not end")
+		result, next := page(t, items, token, 3)
+		for _, i := range result {
+			seen = append(seen, i.ID)
+		}
+		if next == "" {
+			break
+		}
+
+		// A newer item arriving between pages does not shift the following pages
+		items = append([]item{{ID: int64(100 + pages), CreatedAt: base.Add(time.Hour)}}, items...)
+		token = next
+	}
+
+	assert.Equal(t, []int64{9, 8, 7, 6, 5, 4, 3, 2}, seen)
+}
+
+func TestApplyGorm(t *testing.T) {
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	require.NoError(t, err)
+	value := time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)
+
+	var rows []map[string]interface{}
+	stmt := ApplyGorm(db.Table("otps"), &Cursor{Sort: "created_at", Value: value, ID: 7}, "created_at", "id").Limit(3).Find(&rows).Statement
+
+	assert.Equal(t, `SELECT * FROM "otps" WHERE (created_at < $1 OR (created_at = $2 AND id < $3)) ORDER BY created_at DESC, id DESC LIMIT $4`, stmt.SQL.String())
+	assert.Equal(t, []interface{}{value, value, int64(7), 3}, stmt.Vars)
+
+	stmt = ApplyGorm(db.Table("otps"), nil, "created_at", "id").Find(&rows).Statement
+	assert.Equal(t, `SELECT * FROM "otps" ORDER BY created_at DESC, id DESC`, stmt.SQL.String())
+}
+
+func TestMongoFilter(t *testing.T) {
+	value := time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)
+	filter := bson.M{"status": "completed"}
+
+	assert.Equal(t, filter, MongoFilter(filter, nil, "requested_at", "ride_id"))
+	assert.Equal(t, bson.M{"$and": bson.A{
+		filter,
+		bson.M{"$or": bson.A{
+			bson.M{"requested_at": bson.M{"$lt": value}},
+			bson.M{"requested_at": value, "ride_id": bson.M{"$lt": int64(7)}},
+		}},
+	}}, MongoFilter(filter, &Cursor{Sort: "requested_at", Value: value, ID: 7}, "requested_at", "ride_id"))
+}
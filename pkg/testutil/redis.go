@@ -0,0 +1,425 @@
+package testutil
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// FakeRedis is an in-memory stand-in for Redis used in unit tests
+// It answers commands from a redis.Client hook, so no server connection is made
+// Supported commands: GET, SET (EX/PX), DEL, EXISTS, INCR, EXPIRE (NX), TTL, HSET, HGET, HDEL, PUBLISH, and WATCH, UNWATCH, MULTI and EXEC
+// for transactions, which always succeed since the fake serves one process and keys cannot change under a watch
+// Pub/sub connections are served in-process over a net.Pipe and support SUBSCRIBE, UNSUBSCRIBE and PING
+type FakeRedis struct {
+	mu          sync.Mutex
+	values      map[string]string
+	hashes      map[string]map[string]string
+	expires     map[string]time.Time
+	subscribers map[*fakeSubscriber]struct{}
+}
+
+// NewFakeRedis returns a redis client backed by an in-memory FakeRedis
+func NewFakeRedis() (*redis.Client, *FakeRedis) {
+	fake := &FakeRedis{
+		values:      make(map[string]string),
+		hashes:      make(map[string]map[string]string),
+		expires:     make(map[string]time.Time),
+		subscribers: make(map[*fakeSubscriber]struct{}),
+	}
+
+	client := redis.NewClient(&redis.Options{
+		Addr:            "fake-redis:6379",
+		Protocol:        2,
+		DisableIdentity: true,
+	})
+	client.AddHook(fake)
+
+	return client, fake
+}
+
+// DialHook is only reached by pub/sub, regular commands are answered by ProcessHook
+func (f *FakeRedis) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go f.serveSubscriber(server)
+		return client, nil
+	}
+}
+
+func (f *FakeRedis) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		f.process(cmd)
+		return cmd.Err()
+	}
+}
+
+func (f *FakeRedis) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		for _, cmd := range cmds {
+			f.process(cmd)
+		}
+		return nil
+	}
+}
+
+// Expire sets the remaining lifetime of a key, useful to simulate time passing
+func (f *FakeRedis) Expire(key string, ttl time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.expires[key] = time.Now().Add(ttl)
+}
+
+func (f *FakeRedis) process(cmd redis.Cmder) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	args := make([]string, len(cmd.Args()))
+	for i, arg := range cmd.Args() {
+		if b, ok := arg.([]byte); ok {
+			args[i] = string(b)
+			continue
+		}
+		args[i] = fmt.Sprint(arg)
+	}
+
+	switch strings.ToLower(cmd.Name()) {
+	case "hello":
+		cmd.(*redis.MapStringInterfaceCmd).SetVal(map[string]interface{}{"server": "fake-redis", "proto": int64(2)})
+	case "publish":
+		cmd.(*redis.IntCmd).SetVal(f.publish(args[1], args[2]))
+	case "get":
+		value, ok := f.get(args[1])
+		if !ok {
+			cmd.SetErr(redis.Nil)
+			return
+		}
+		cmd.(*redis.StringCmd).SetVal(value)
+	case "set":
+		if hasArg(args[3:], "nx") {
+			if _, ok := f.get(args[1]); ok {
+				if boolCmd, ok := cmd.(*redis.BoolCmd); ok {
+					boolCmd.SetVal(false)
+					return
+				}
+				cmd.SetErr(redis.Nil)
+				return
+			}
+		}
+		f.values[args[1]] = args[2]
+		delete(f.hashes, args[1])
+		delete(f.expires, args[1])
+		if len(args) >= 5 {
+			amount, _ := strconv.ParseInt(args[4], 10, 64)
+			switch strings.ToLower(args[3]) {
+			case "ex":
+				f.expires[args[1]] = time.Now().Add(time.Duration(amount) * time.Second)
+			case "px":
+				f.expires[args[1]] = time.Now().Add(time.Duration(amount) * time.Millisecond)
+			}
+		}
+		if boolCmd, ok := cmd.(*redis.BoolCmd); ok {
+			boolCmd.SetVal(true)
+			return
+		}
+		cmd.(*redis.StatusCmd).SetVal("OK")
+	case "del":
+		var deleted int64
+		for _, key := range args[1:] {
+			if f.exists(key) {
+				deleted++
+			}
+			delete(f.values, key)
+			delete(f.hashes, key)
+			delete(f.expires, key)
+		}
+		cmd.(*redis.IntCmd).SetVal(deleted)
+	case "exists":
+		var count int64
+		for _, key := range args[1:] {
+			if f.exists(key) {
+				count++
+			}
+		}
+		cmd.(*redis.IntCmd).SetVal(count)
+	case "incr":
+		value, _ := f.get(args[1])
+		n, _ := strconv.ParseInt(value, 10, 64)
+		n++
+		f.values[args[1]] = strconv.FormatInt(n, 10)
+		cmd.(*redis.IntCmd).SetVal(n)
+	case "expire":
+		if !f.exists(args[1]) {
+			cmd.(*redis.BoolCmd).SetVal(false)
+			return
+		}
+		if _, hasExpiry := f.expires[args[1]]; hasExpiry && hasArg(args[3:], "nx") {
+			cmd.(*redis.BoolCmd).SetVal(false)
+			return
+		}
+		seconds, _ := strconv.ParseInt(args[2], 10, 64)
+		f.expires[args[1]] = time.Now().Add(time.Duration(seconds) * time.Second)
+		cmd.(*redis.BoolCmd).SetVal(true)
+	case "ttl":
+		if !f.exists(args[1]) {
+			cmd.(*redis.DurationCmd).SetVal(-2)
+			return
+		}
+		expiresAt, ok := f.expires[args[1]]
+		if !ok {
+			cmd.(*redis.DurationCmd).SetVal(-1)
+			return
+		}
+		cmd.(*redis.DurationCmd).SetVal(time.Until(expiresAt).Round(time.Second))
+	case "hset":
+		f.exists(args[1])
+		hash, ok := f.hashes[args[1]]
+		if !ok {
+			hash = make(map[string]string)
+			f.hashes[args[1]] = hash
+		}
+		var added int64
+		for i := 2; i+1 < len(args); i += 2 {
+			if _, ok := hash[args[i]]; !ok {
+				added++
+			}
+			hash[args[i]] = args[i+1]
+		}
+		cmd.(*redis.IntCmd).SetVal(added)
+	case "hget":
+		f.exists(args[1])
+		value, ok := f.hashes[args[1]][args[2]]
+		if !ok {
+			cmd.SetErr(redis.Nil)
+			return
+		}
+		cmd.(*redis.StringCmd).SetVal(value)
+	case "hgetall":
+		f.exists(args[1])
+		fields := make(map[string]string, len(f.hashes[args[1]]))
+		for field, value := range f.hashes[args[1]] {
+			fields[field] = value
+		}
+		cmd.(*redis.MapStringStringCmd).SetVal(fields)
+	case "hdel":
+		f.exists(args[1])
+		var deleted int64
+		hash := f.hashes[args[1]]
+		for _, field := range args[2:] {
+			if _, ok := hash[field]; ok {
+				deleted++
+				delete(hash, field)
+			}
+		}
+		// Like Redis, a hash without fields no longer exists
+		if hash != nil && len(hash) == 0 {
+			delete(f.hashes, args[1])
+			delete(f.expires, args[1])
+		}
+		cmd.(*redis.IntCmd).SetVal(deleted)
+	case "watch", "unwatch", "multi":
+		cmd.(*redis.StatusCmd).SetVal("OK")
+	case "exec":
+		// The queued commands were already answered in order by the pipeline hook
+		cmd.(*redis.SliceCmd).SetVal([]interface{}{})
+	default:
+		cmd.SetErr(fmt.Errorf("fake redis: unsupported command %q", cmd.Name()))
+	}
+}
+
+// hasArg reports whether the command options contain flag, ignoring case
+func hasArg(args []string, flag string) bool {
+	for _, arg := range args {
+		if strings.EqualFold(arg, flag) {
+			return true
+		}
+	}
+	return false
+}
+
+// get returns the value of a key, evicting it first if it has expired
+func (f *FakeRedis) get(key string) (string, bool) {
+	f.evictExpired(key)
+	value, ok := f.values[key]
+	return value, ok
+}
+
+// exists reports whether a string or hash key is set, evicting it first if it has expired
+func (f *FakeRedis) exists(key string) bool {
+	f.evictExpired(key)
+	_, isValue := f.values[key]
+	_, isHash := f.hashes[key]
+	return isValue || isHash
+}
+
+func (f *FakeRedis) evictExpired(key string) {
+	if expiresAt, ok := f.expires[key]; ok && !time.Now().Before(expiresAt) {
+		delete(f.values, key)
+		delete(f.hashes, key)
+		delete(f.expires, key)
+	}
+}
+
+// fakeSubscriber is one pub/sub connection, replies are queued so PUBLISH never blocks on a slow reader
+type fakeSubscriber struct {
+	channels map[string]struct{}
+	out      chan []byte
+}
+
+// publish queues the message for every subscriber of channel and returns how many received it
+// Must be called with f.mu held
+func (f *FakeRedis) publish(channel, payload string) int64 {
+	var receivers int64
+	for subscriber := range f.subscribers {
+		if _, ok := subscriber.channels[channel]; !ok {
+			continue
+		}
+		select {
+		case subscriber.out <- respArray("message", channel, payload):
+			receivers++
+		default:
+		}
+	}
+	return receivers
+}
+
+func (f *FakeRedis) serveSubscriber(conn net.Conn) {
+	subscriber := &fakeSubscriber{
+		channels: make(map[string]struct{}),
+		out:      make(chan []byte, 64),
+	}
+
+	f.mu.Lock()
+	f.subscribers[subscriber] = struct{}{}
+	f.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case reply := <-subscriber.out:
+				if _, err := conn.Write(reply); err != nil {
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	defer func() {
+		f.mu.Lock()
+		delete(f.subscribers, subscriber)
+		f.mu.Unlock()
+		close(done)
+		conn.Close()
+	}()
+
+	reader := bufio.NewReader(conn)
+	for {
+		args, err := readRESPCommand(reader)
+		if err != nil {
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		f.mu.Lock()
+		switch strings.ToLower(args[0]) {
+		case "subscribe":
+			for _, channel := range args[1:] {
+				subscriber.channels[channel] = struct{}{}
+				subscriber.out <- respArray("subscribe", channel, len(subscriber.channels))
+			}
+		case "unsubscribe":
+			channels := args[1:]
+			if len(channels) == 0 {
+				for channel := range subscriber.channels {
+					channels = append(channels, channel)
+				}
+			}
+			for _, channel := range channels {
+				delete(subscriber.channels, channel)
+				subscriber.out <- respArray("unsubscribe", channel, len(subscriber.channels))
+			}
+		case "ping":
+			payload := ""
+			if len(args) > 1 {
+				payload = args[1]
+			}
+			subscriber.out <- respArray("pong", payload)
+		default:
+			subscriber.out <- []byte(fmt.Sprintf("-ERR fake redis: unsupported pub/sub command '%s'\r\n", args[0]))
+		}
+		f.mu.Unlock()
+	}
+}
+
+// readRESPCommand reads one command sent by the client as a RESP array of bulk strings
+func readRESPCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := readRESPLine(reader)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return nil, fmt.Errorf("fake redis: unexpected command %q", line)
+	}
+	count, err := strconv.Atoi(line[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		header, err := readRESPLine(reader)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(header, "$") {
+			return nil, fmt.Errorf("fake redis: unexpected argument %q", header)
+		}
+		size, err := strconv.Atoi(header[1:])
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2) // value followed by \r\n
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			return nil, err
+		}
+		args = append(args, string(buf[:size]))
+	}
+	return args, nil
+}
+
+func readRESPLine(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// respArray encodes strings as bulk strings and ints as integers
+func respArray(items ...interface{}) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(items))
+	for _, item := range items {
+		switch v := item.(type) {
+		case int:
+			fmt.Fprintf(&b, ":%d\r\n", v)
+		default:
+			s := fmt.Sprint(v)
+			fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(s), s)
+		}
+	}
+	return []byte(b.String())
+}
@@ -0,0 +1,36 @@
+package utils
+
+import "fmt"
+
+// RideLocationChannel returns the Redis pub/sub channel carrying the driver's location during a ride, e.g. ride_location:42
+func RideLocationChannel(rideID int64) string {
+	return fmt.Sprintf("ride_location:%d", rideID)
+}
+
+// RideIdempotencyKey returns the Redis key mapping a ride request's Idempotency-Key header to the ride it created
+func RideIdempotencyKey(idempotencyKey string) string {
+	return fmt.Sprintf("ride_idempotency:%s", idempotencyKey)
+}
+
+// OnlineDriversCountKey is the Redis key caching the number of online drivers
+const OnlineDriversCountKey = "online_drivers_count"
+
+// DriverProfileKey returns the Redis key caching a driver's profile, e.g. driver_profile:42
+func DriverProfileKey(driverID int64) string {
+	return fmt.Sprintf("driver_profile:%d", driverID)
+}
+
+// DriverNotificationChannel returns the Redis pub/sub channel carrying ride notifications for a driver, e.g. driver_notifications:42
+func DriverNotificationChannel(driverID int64) string {
+	return fmt.Sprintf("driver_notifications:%d", driverID)
+}
+
+// CustomerNotificationChannel returns the Redis pub/sub channel carrying ride notifications for a customer, e.g. customer_notifications:42
+func CustomerNotificationChannel(customerID int64) string {
+	return fmt.Sprintf("customer_notifications:%d", customerID)
+}
+
+// RateLimitKey returns the Redis key holding the token bucket of a rate limited route for a user or IP, e.g. rate_limit:login:ip:10.0.0.1
+func RateLimitKey(route, subject string) string {
+	return fmt.Sprintf("rate_limit:%s:%s", route, subject)
+}
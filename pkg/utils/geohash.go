@@ -0,0 +1,152 @@
+package utils
+
+import (
+	"math"
+	"strings"
+)
+
+// MaxGeohashPrecision is the longest geohash produced, its cells are under 5m across
+const MaxGeohashPrecision = 9
+
+const geohashBase32 = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// metersPerDegree is the length of one degree of latitude, and of longitude at the equator
+const metersPerDegree = 111320.0
+
+// Geohash encodes the coordinates as a geohash of precision characters, capped at MaxGeohashPrecision
+func Geohash(lat, lng float64, precision int) string {
+	if precision < 1 {
+		precision = 1
+	}
+	if precision > MaxGeohashPrecision {
+		precision = MaxGeohashPrecision
+	}
+
+	minLat, maxLat := -90.0, 90.0
+	minLng, maxLng := -180.0, 180.0
+
+	var hash strings.Builder
+	bits, ch := 0, 0
+	evenBit := true // bits alternate between longitude and latitude, starting with longitude
+	for hash.Len() < precision {
+		if evenBit {
+			mid := (minLng + maxLng) / 2
+			if lng >= mid {
+				ch = ch<<1 | 1
+				minLng = mid
+			} else {
+				ch <<= 1
+				maxLng = mid
+			}
+		} else {
+			mid := (minLat + maxLat) / 2
+			if lat >= mid {
+				ch = ch<<1 | 1
+				minLat = mid
+			} else {
+				ch <<= 1
+				maxLat = mid
+			}
+		}
+		evenBit = !evenBit
+
+		bits++
+		if bits == 5 {
+			hash.WriteByte(geohashBase32[ch])
+			bits, ch = 0, 0
+		}
+	}
+
+	return hash.String()
+}
+
+// GeohashCellSize returns the height and width in degrees of the cells of a geohash precision
+func GeohashCellSize(precision int) (latDegrees, lngDegrees float64) {
+	totalBits := 5 * precision
+	lngBits := (totalBits + 1) / 2
+	latBits := totalBits / 2
+	return 180 / math.Pow(2, float64(latBits)), 360 / math.Pow(2, float64(lngBits))
+}
+
+// GeohashPrecisionForRadius returns the finest precision whose cells around lat are at least radiusMeters tall and wide,
+// so a circle of that radius around a point fits inside the point's cell and its eight neighbors
+func GeohashPrecisionForRadius(lat, radiusMeters float64) int {
+	// Cells narrow towards the poles, size them for the edge of the circle nearest the pole
+	edgeLat := math.Min(math.Abs(lat)+radiusMeters/metersPerDegree, 90)
+	lngScale := math.Cos(edgeLat * math.Pi / 180)
+	for precision := MaxGeohashPrecision; precision > 1; precision-- {
+		latDegrees, lngDegrees := GeohashCellSize(precision)
+		if latDegrees*metersPerDegree >= radiusMeters && lngDegrees*metersPerDegree*lngScale >= radiusMeters {
+			return precision
+		}
+	}
+	return 1
+}
+
+// GeohashNeighborhood returns the geohash cell of the coordinates along with its eight neighbors
+// Cells past the poles are left out, cells across the antimeridian wrap around
+func GeohashNeighborhood(lat, lng float64, precision int) []string {
+	center := Geohash(lat, lng, precision)
+	latDegrees, lngDegrees := GeohashCellSize(len(center))
+
+	// Step from the middle of the center cell so every step lands inside a neighbor
+	minLat, minLng := geohashCellOrigin(center)
+	midLat := minLat + latDegrees/2
+	midLng := minLng + lngDegrees/2
+
+	cells := []string{center}
+	seen := map[string]bool{center: true}
+	for _, dLat := range []float64{-1, 0, 1} {
+		for _, dLng := range []float64{-1, 0, 1} {
+			cellLat := midLat + dLat*latDegrees
+			if cellLat < -90 || cellLat > 90 {
+				continue
+			}
+			cellLng := midLng + dLng*lngDegrees
+			if cellLng < -180 {
+				cellLng += 360
+			} else if cellLng >= 180 {
+				cellLng -= 360
+			}
+			cell := Geohash(cellLat, cellLng, len(center))
+			if !seen[cell] {
+				seen[cell] = true
+				cells = append(cells, cell)
+			}
+		}
+	}
+
+	return cells
+}
+
+// geohashCellOrigin returns the south west corner of a geohash cell
+func geohashCellOrigin(hash string) (lat, lng float64) {
+	minLat, maxLat := -90.0, 90.0
+	minLng, maxLng := -180.0, 180.0
+
+	evenBit := true
+	for i := 0; i < len(hash); i++ {
+		ch := strings.IndexByte(geohashBase32, hash[i])
+		for bit := 4; bit >= 0; bit-- {
+			set := ch>>bit&1 == 1
+			if evenBit {
+				mid := (minLng + maxLng) / 2
+				if set {
+					minLng = mid
+				} else {
+					maxLng = mid
+				}
+			} else {
+				mid := (minLat + maxLat) / 2
+				if set {
+					minLat = mid
+				} else {
+					maxLat = mid
+				}
+			}
+			evenBit = !evenBit
+		}
+	}
+
+	return minLat, minLng
+}
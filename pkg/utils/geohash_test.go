@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGeohash(t *testing.T) {
+	tests := []struct {
+		name      string
+		lat, lng  float64
+		precision int
+		expected  string
+	}{
+		{name: "dhaka", lat: 23.8103, lng: 90.4125, precision: 7, expected: "wh0r3qs"},
+		{name: "capped at max precision", lat: 57.64911, lng: 10.40744, precision: 11, expected: "u4pruydqq"},
+		{name: "southern hemisphere", lat: -33.8688, lng: 151.2093, precision: 5, expected: "r3gx2"},
+		{name: "zero precision is one character", lat: 0, lng: 0, precision: 0, expected: "s"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, Geohash(tt.lat, tt.lng, tt.precision))
+		})
+	}
+}
+
+func TestGeohashPrecisionForRadius(t *testing.T) {
+	// Cells at precision 5 are about 4.9km square, at 6 about 1.2km wide and 0.6km tall
+	assert.Equal(t, 5, GeohashPrecisionForRadius(0, 4000))
+	assert.Equal(t, 6, GeohashPrecisionForRadius(0, 500))
+	// Cells narrow away from the equator so a coarser precision is needed
+	assert.Equal(t, 4, GeohashPrecisionForRadius(60, 4000))
+	assert.Equal(t, 1, GeohashPrecisionForRadius(0, 10000000))
+}
+
+func TestGeohashNeighborhood(t *testing.T) {
+	cells := GeohashNeighborhood(23.8103, 90.4125, 6)
+
+	assert.Len(t, cells, 9)
+	assert.Equal(t, Geohash(23.8103, 90.4125, 6), cells[0], "The center cell comes first")
+	// Points just across each edge of the center cell are in a neighbor
+	latDegrees, lngDegrees := GeohashCellSize(6)
+	for _, p := range [][2]float64{
+		{23.8103 + latDegrees, 90.4125},
+		{23.8103 - latDegrees, 90.4125},
+		{23.8103, 90.4125 + lngDegrees},
+		{23.8103, 90.4125 - lngDegrees},
+		{23.8103 + latDegrees, 90.4125 + lngDegrees},
+	} {
+		assert.Contains(t, cells, Geohash(p[0], p[1], 6))
+	}
+}
+
+func TestGeohashNeighborhood_Antimeridian(t *testing.T) {
+	cells := GeohashNeighborhood(0.1, 179.99, 5)
+
+	assert.Len(t, cells, 9)
+	assert.Contains(t, cells, Geohash(0.1, -179.99, 5), "Cells wrap across the antimeridian")
+}
+
+func TestGeohashNeighborhood_Pole(t *testing.T) {
+	cells := GeohashNeighborhood(89.99, 0, 5)
+
+	assert.Len(t, cells, 6, "Cells past the pole are left out")
+}
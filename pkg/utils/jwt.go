@@ -3,6 +3,7 @@ package utils
 import (
 	"context"
 	"errors"
+	"fmt"
 	"time"
 	"vcs.technonext.com/carrybee/ride_engine/pkg/logger"
 
@@ -10,30 +11,98 @@ import (
 )
 
 var (
-	ErrInvalidToken = errors.New("invalid token")
-	ErrExpiredToken = errors.New("token has expired")
+	ErrInvalidToken            = errors.New("invalid token")
+	ErrExpiredToken            = errors.New("token has expired")
+	ErrUnsupportedJWTAlgorithm = errors.New("unsupported JWT algorithm, expected HS256, HS384 or HS512")
 )
 
+// DefaultJWTAlgorithm is used when JWTKeys has no algorithm
+const DefaultJWTAlgorithm = "HS256"
+
+// JWTKeys are the secrets tokens are signed and validated with
+// Tokens are signed with Secret only and validated against Secret and PreviousSecrets,
+// so after rotating the secret the tokens signed with the old one stay valid until they expire
+type JWTKeys struct {
+	Algorithm       string // HS256, HS384 or HS512
+	Secret          string
+	PreviousSecrets []string
+}
+
+// Validate reports whether tokens can be signed with the keys
+func (k JWTKeys) Validate() error {
+	if k.Secret == "" {
+		return errors.New("JWT secret is empty")
+	}
+	_, err := k.signingMethod()
+	return err
+}
+
+// signingMethod returns the HMAC signing method of the configured algorithm
+func (k JWTKeys) signingMethod() (*jwt.SigningMethodHMAC, error) {
+	switch k.Algorithm {
+	case "", jwt.SigningMethodHS256.Alg():
+		return jwt.SigningMethodHS256, nil
+	case jwt.SigningMethodHS384.Alg():
+		return jwt.SigningMethodHS384, nil
+	case jwt.SigningMethodHS512.Alg():
+		return jwt.SigningMethodHS512, nil
+	}
+	return nil, ErrUnsupportedJWTAlgorithm
+}
+
+// validSecrets returns the current secret followed by the previous ones that are set
+func (k JWTKeys) validSecrets() []string {
+	secrets := []string{k.Secret}
+	for _, secret := range k.PreviousSecrets {
+		if secret != "" && secret != k.Secret {
+			secrets = append(secrets, secret)
+		}
+	}
+	return secrets
+}
+
 type Claims struct {
-	UserID int64  `json:"user_id"`
-	Role   string `json:"role"` // "customer" or "driver"
+	UserID    int64  `json:"user_id"`
+	Role      string `json:"role"`       // "customer", "driver" or "admin"
+	SessionID string `json:"session_id"` // one per login, so each device can be logged out on its own
 	jwt.RegisteredClaims
 }
 
-func GenerateJWT(userID int64, role string, secret string, expiration int) (string, error) {
+// JWTRedisKey returns the Redis hash holding the active tokens of a user by session ID, e.g. jwt:driver:42
+// It is shared by login, logout and the auth middleware so they always agree on the key
+// Deleting it logs the user out on every device
+func JWTRedisKey(role string, userID int64) string {
+	return fmt.Sprintf("jwt:%s:%d", role, userID)
+}
+
+// JWTExpiry converts the configured expiration (in hours) to a duration
+// Both the token claim and the Redis entry lifetime must be derived from it
+func JWTExpiry(expiration int) time.Duration {
+	return time.Duration(expiration) * time.Hour
+}
+
+// GenerateJWT issues a token for the user's session signed with the current secret
+func GenerateJWT(userID int64, role, sessionID string, keys JWTKeys, expiration int) (string, error) {
+	method, err := keys.signingMethod()
+	if err != nil {
+		logger.Error(context.Background(), err.Error())
+		return "", err
+	}
+
 	now := time.Now()
 	claims := Claims{
-		UserID: userID,
-		Role:   role,
+		UserID:    userID,
+		Role:      role,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(now.Add(time.Duration(expiration) * time.Hour)),
+			ExpiresAt: jwt.NewNumericDate(now.Add(JWTExpiry(expiration))),
 			IssuedAt:  jwt.NewNumericDate(now),
 			NotBefore: jwt.NewNumericDate(now),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, err := token.SignedString([]byte(secret))
+	token := jwt.NewWithClaims(method, claims)
+	signedToken, err := token.SignedString([]byte(keys.Secret))
 	if err != nil {
 		logger.Error(context.Background(), err.Error())
 		return "", err
@@ -42,14 +111,37 @@ func GenerateJWT(userID int64, role string, secret string, expiration int) (stri
 	return signedToken, nil
 }
 
-func ValidateJWT(tokenString string, secret string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			logger.Error(context.Background(), "error signing method")
-			return nil, errors.New("unexpected signing method")
+// JWTExpiresAt returns when a token issued by GenerateJWT expires, without checking its signature
+// Only use it on tokens the server stored itself, like the ones in the session hash, never on tokens sent by clients
+func JWTExpiresAt(tokenString string) (time.Time, error) {
+	claims := &Claims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(tokenString, claims); err != nil {
+		return time.Time{}, err
+	}
+	if claims.ExpiresAt == nil {
+		return time.Time{}, ErrInvalidToken
+	}
+	return claims.ExpiresAt.Time, nil
+}
+
+// ValidateJWT parses a token signed with the configured algorithm by the current or any previous secret
+func ValidateJWT(tokenString string, keys JWTKeys) (*Claims, error) {
+	method, err := keys.signingMethod()
+	if err != nil {
+		logger.Error(context.Background(), err.Error())
+		return nil, err
+	}
+
+	var token *jwt.Token
+	for _, secret := range keys.validSecrets() {
+		token, err = jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
+			return []byte(secret), nil
+		}, jwt.WithValidMethods([]string{method.Alg()}))
+		// Only a signature mismatch can be fixed by another secret
+		if !errors.Is(err, jwt.ErrTokenSignatureInvalid) {
+			break
 		}
-		return []byte(secret), nil
-	})
+	}
 
 	if err != nil {
 		logger.Error(context.Background(), err.Error())
@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateJWT_CurrentSecret(t *testing.T) {
+	keys := JWTKeys{Secret: "current"}
+
+	token, err := GenerateJWT(42, "driver", "session", keys, 1)
+	require.NoError(t, err)
+
+	claims, err := ValidateJWT(token, keys)
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), claims.UserID)
+	assert.Equal(t, "driver", claims.Role)
+	assert.Equal(t, "session", claims.SessionID)
+}
+
+func TestValidateJWT_PreviousSecretStillValid(t *testing.T) {
+	oldToken, err := GenerateJWT(42, "customer", "session", JWTKeys{Secret: "old"}, 1)
+	require.NoError(t, err)
+
+	rotated := JWTKeys{Secret: "new", PreviousSecrets: []string{"old"}}
+	claims, err := ValidateJWT(oldToken, rotated)
+	require.NoError(t, err, "Tokens signed before the rotation stay valid")
+	assert.Equal(t, int64(42), claims.UserID)
+
+	// New tokens are signed with the current secret only
+	newToken, err := GenerateJWT(42, "customer", "session", rotated, 1)
+	require.NoError(t, err)
+	_, err = ValidateJWT(newToken, JWTKeys{Secret: "old"})
+	assert.ErrorIs(t, err, jwt.ErrTokenSignatureInvalid)
+}
+
+func TestValidateJWT_UnknownSecretFails(t *testing.T) {
+	token, err := GenerateJWT(42, "customer", "session", JWTKeys{Secret: "attacker"}, 1)
+	require.NoError(t, err)
+
+	_, err = ValidateJWT(token, JWTKeys{Secret: "new", PreviousSecrets: []string{"old"}})
+	assert.ErrorIs(t, err, jwt.ErrTokenSignatureInvalid)
+}
+
+func TestValidateJWT_ConfiguredAlgorithm(t *testing.T) {
+	keys := JWTKeys{Algorithm: "HS512", Secret: "current"}
+
+	token, err := GenerateJWT(42, "customer", "session", keys, 1)
+	require.NoError(t, err)
+
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &Claims{})
+	require.NoError(t, err)
+	assert.Equal(t, "HS512", parsed.Method.Alg())
+
+	_, err = ValidateJWT(token, keys)
+	assert.NoError(t, err)
+
+	// A token signed with another algorithm is rejected even with the right secret
+	_, err = ValidateJWT(token, JWTKeys{Algorithm: "HS256", Secret: "current"})
+	assert.Error(t, err)
+}
+
+func TestValidateJWT_ExpiredTokenNotRetriedWithOtherSecrets(t *testing.T) {
+	token, err := GenerateJWT(42, "customer", "session", JWTKeys{Secret: "current"}, -1)
+	require.NoError(t, err)
+
+	_, err = ValidateJWT(token, JWTKeys{Secret: "current", PreviousSecrets: []string{"old"}})
+	assert.ErrorIs(t, err, jwt.ErrTokenExpired)
+}
+
+func TestJWTExpiresAt(t *testing.T) {
+	before := time.Now().Add(-time.Second)
+	token, err := GenerateJWT(42, "customer", "session", JWTKeys{Secret: "current"}, 2)
+	require.NoError(t, err)
+
+	// The expiry can be read without the secret and is read even once the token expired
+	expiresAt, err := JWTExpiresAt(token)
+	require.NoError(t, err)
+	assert.WithinDuration(t, before.Add(2*time.Hour), expiresAt, 2*time.Second)
+
+	expired, err := GenerateJWT(42, "customer", "session", JWTKeys{Secret: "current"}, -1)
+	require.NoError(t, err)
+	expiresAt, err = JWTExpiresAt(expired)
+	require.NoError(t, err)
+	assert.True(t, expiresAt.Before(time.Now()))
+
+	_, err = JWTExpiresAt("not-a-token")
+	assert.Error(t, err)
+}
+
+func TestJWTKeys_Validate(t *testing.T) {
+	assert.NoError(t, JWTKeys{Secret: "current"}.Validate())
+	assert.NoError(t, JWTKeys{Algorithm: "HS384", Secret: "current"}.Validate())
+	assert.ErrorIs(t, JWTKeys{Algorithm: "RS256", Secret: "current"}.Validate(), ErrUnsupportedJWTAlgorithm)
+	assert.Error(t, JWTKeys{}.Validate())
+}
@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+// MaskPhone hides the digits of a phone number except the last few, e.g. "+8801711000000" becomes "+**********000"
+// Separators such as '+', spaces and dashes are kept so the number stays recognisable,
+// short numbers show only their last 2 digits and numbers of up to 4 digits are masked entirely
+func MaskPhone(phone string) string {
+	digits := 0
+	for _, r := range phone {
+		if unicode.IsDigit(r) {
+			digits++
+		}
+	}
+	if digits == 0 {
+		// Not a phone number, hide it all rather than guess which part is sensitive
+		return strings.Repeat("*", len([]rune(phone)))
+	}
+
+	visible := 3
+	switch {
+	case digits <= 4:
+		visible = 0
+	case digits < 8:
+		visible = 2
+	}
+
+	var masked strings.Builder
+	seen := 0
+	for _, r := range phone {
+		if !unicode.IsDigit(r) {
+			masked.WriteRune(r)
+			continue
+		}
+		seen++
+		if seen > digits-visible {
+			masked.WriteRune(r)
+		} else {
+			masked.WriteByte('*')
+		}
+	}
+	return masked.String()
+}
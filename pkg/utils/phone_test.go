@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaskPhone(t *testing.T) {
+	tests := []struct {
+		name     string
+		phone    string
+		expected string
+	}{
+		{name: "international", phone: "+8801711000000", expected: "+**********000"},
+		{name: "local", phone: "01711000123", expected: "********123"},
+		{name: "with separators", phone: "+880 1711-000456", expected: "+*** ****-***456"},
+		{name: "with parentheses", phone: "(555) 123-4567", expected: "(***) ***-*567"},
+		{name: "short", phone: "123456", expected: "****56"},
+		{name: "too short to show any digits", phone: "1234", expected: "****"},
+		{name: "no digits", phone: "unknown", expected: "*******"},
+		{name: "empty", phone: "", expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, MaskPhone(tt.phone))
+		})
+	}
+}